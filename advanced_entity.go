@@ -510,65 +510,45 @@ func extractActionEntities(text string) []AdvancedEntity {
 		"validate", "verify", "authenticate", "authorize", "connect", "disconnect",
 	}
 	
-	sentences := SplitIntoSentences(text)
-	position := 0
-	
-	for _, sentence := range sentences {
-		sentenceStart := strings.Index(text[position:], sentence)
-		if sentenceStart == -1 {
+	// Only tokens the tagger reads as verbs count, so "the test" or "a build"
+	// used as nouns are not reported as actions
+	for _, tok := range TagPartsOfSpeech(text) {
+		if !strings.HasPrefix(tok.Tag, "VB") {
 			continue
 		}
-		sentenceStart += position
-		
-		words := strings.Fields(sentence)
-		wordPos := sentenceStart
 		
-		for _, word := range words {
-			wordStart := strings.Index(text[wordPos:], word)
-			if wordStart == -1 {
-				continue
-			}
-			wordStart += wordPos
-			
-			cleanWord := strings.ToLower(strings.Trim(word, ".,!?;:"))
-			for _, action := range actionVerbs {
-				if cleanWord == action || strings.HasPrefix(cleanWord, action) {
-					entity := AdvancedEntity{
-						Entity: Entity{
-							Text:     word,
-							Type:     EntityAction,
-							Position: Position{Start: wordStart, End: wordStart + len(word)},
-						},
-						Confidence: 0.7,
-						Context:    extractContext(text, wordStart, wordStart+len(word)),
-						Attributes: map[string]string{
-							"base_form": action,
-							"tense":     detectVerbTense(word),
-						},
-					}
-					entities = append(entities, entity)
-					break
+		cleanWord := strings.ToLower(tok.Text)
+		for _, action := range actionVerbs {
+			if cleanWord == action || strings.HasPrefix(cleanWord, action) {
+				entity := AdvancedEntity{
+					Entity: Entity{
+						Text:     tok.Text,
+						Type:     EntityAction,
+						Position: tok.Position,
+					},
+					Confidence: 0.7,
+					Context:    extractContext(text, tok.Position.Start, tok.Position.End),
+					Attributes: map[string]string{
+						"base_form": action,
+						"tense":     detectVerbTense(tok.Tag),
+					},
 				}
+				entities = append(entities, entity)
+				break
 			}
-			
-			wordPos = wordStart + len(word)
 		}
-		
-		position = sentenceStart + len(sentence)
 	}
 	
 	return entities
 }
 
-func detectVerbTense(verb string) string {
-	lower := strings.ToLower(verb)
-	
-	switch {
-	case strings.HasSuffix(lower, "ing"):
+func detectVerbTense(tag string) string {
+	switch tag {
+	case "VBG":
 		return "progressive"
-	case strings.HasSuffix(lower, "ed"):
+	case "VBD", "VBN":
 		return "past"
-	case strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss"):
+	case "VBZ":
 		return "present_third"
 	default:
 		return "base"
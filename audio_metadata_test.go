@@ -0,0 +1,299 @@
+package textlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// id3Frame builds an ID3v2.3 frame, or a v2.4 one with a syncsafe size
+func id3Frame(major byte, id string, data string) []byte {
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(data)))
+	if major == 4 {
+		size = syncsafeBytes(len(data))
+	}
+	return append(append(append([]byte(id), size...), 0, 0), data...)
+}
+
+func id3Tag(major byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	return append(append([]byte{'I', 'D', '3', major, 0, 0}, syncsafeBytes(len(body))...), body...)
+}
+
+func writeTestAudio(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testMP3() []byte {
+	var b bytes.Buffer
+	b.Write(id3Tag(3,
+		id3Frame(3, "TIT2", "\x00Latin title"),
+		id3Frame(3, "TPE1", "\x01\xFF\xFEA\x00d\x00a\x00"),
+		id3Frame(3, "TRCK", "\x003/12"),
+		id3Frame(3, "TCON", "\x00(17)"),
+		id3Frame(3, "TYER", "\x002019"),
+		id3Frame(3, "COMM", "\x00engdesc\x00Nice one"),
+		id3Frame(3, "APIC", "\x00image/png\x00\x03\x00PNG"),
+	))
+
+	// MPEG-1 Layer 3, 128 kbit/s, 44.1 kHz: 417-byte frames of 1152 samples.
+	// The first is an Info frame holding no audio.
+	frame := make([]byte, 417)
+	copy(frame, "\xFF\xFB\x90\x00")
+	info := append([]byte(nil), frame...)
+	copy(info[36:], "Info")
+	b.Write(info)
+	for i := 0; i < 10; i++ {
+		b.Write(frame)
+	}
+
+	v1 := make([]byte, 128)
+	copy(v1, "TAG")
+	copy(v1[3:], "v1 title")
+	copy(v1[63:], "v1 album")
+	copy(v1[93:], "1999")
+	v1[126], v1[127] = 7, 13
+	b.Write(v1)
+	return b.Bytes()
+}
+
+func testWAV() []byte {
+	fmtChunk := make([]byte, 16)
+	binary.LittleEndian.PutUint16(fmtChunk, 1)
+	binary.LittleEndian.PutUint16(fmtChunk[2:], 2)
+	binary.LittleEndian.PutUint32(fmtChunk[4:], 8000)
+	binary.LittleEndian.PutUint32(fmtChunk[8:], 32000)
+	binary.LittleEndian.PutUint16(fmtChunk[12:], 4)
+	binary.LittleEndian.PutUint16(fmtChunk[14:], 16)
+
+	chunk := func(id string, body []byte) []byte {
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(len(body)))
+		out := append(append([]byte(id), size...), body...)
+		if len(body)%2 == 1 {
+			out = append(out, 0)
+		}
+		return out
+	}
+	body := append([]byte("WAVE"), chunk("fmt ", fmtChunk)...)
+	body = append(body, chunk("LIST", append([]byte("INFO"), chunk("INAM", []byte("Hello\x00"))...))...)
+	body = append(body, chunk("data", make([]byte, 32000))...)
+	return chunk("RIFF", body)
+}
+
+func vorbisCommentBlock(entries ...string) []byte {
+	le := func(n int) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(n))
+		return b
+	}
+	out := append(le(4), "test"...)
+	out = append(out, le(len(entries))...)
+	for _, e := range entries {
+		out = append(append(out, le(len(e))...), e...)
+	}
+	return out
+}
+
+func testFLAC() []byte {
+	info := make([]byte, 34)
+	// 44.1 kHz, 2 channels, 16 bits, 88200 samples
+	binary.BigEndian.PutUint64(info[10:], 44100<<44|1<<41|15<<36|88200)
+	comment := vorbisCommentBlock("ARTIST=Ada", "artist=Grace", "TRACKNUMBER=5", "DATE=2020-01-02")
+
+	out := []byte("fLaC\x00\x00\x00\x22")
+	out = append(out, info...)
+	out = append(out, 0x84, 0, byte(len(comment)>>8), byte(len(comment)))
+	out = append(out, comment...)
+	return append(out, make([]byte, 1000)...)
+}
+
+func oggPage(serial uint32, granule int64, packets ...[]byte) []byte {
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	binary.LittleEndian.PutUint64(header[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(header[14:], serial)
+	var lacing, data []byte
+	for _, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			lacing = append(lacing, 255)
+		}
+		lacing = append(lacing, byte(n))
+		data = append(data, p...)
+	}
+	header[26] = byte(len(lacing))
+	return append(append(header, lacing...), data...)
+}
+
+func testOpus() []byte {
+	head := []byte("OpusHead\x01\x02\x38\x01\x44\xAC\x00\x00\x00\x00\x00")
+	tags := append([]byte("OpusTags"), vorbisCommentBlock("TITLE=Song", "GENRE=Ambient")...)
+	var b bytes.Buffer
+	b.Write(oggPage(7, 0, head))
+	b.Write(oggPage(9, 0, []byte("other stream")))
+	b.Write(oggPage(7, 0, tags))
+	b.Write(oggPage(7, 312+48000*3, make([]byte, 300)))
+	return b.Bytes()
+}
+
+func mp4Box(typ string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(8+len(body)))
+	return append(append(size, typ...), body...)
+}
+
+func testM4A() []byte {
+	be32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		return b
+	}
+	timeHeader := func(typ string, scale, duration uint32) []byte {
+		body := make([]byte, 100)
+		copy(body[12:], be32(scale))
+		copy(body[16:], be32(duration))
+		return mp4Box(typ, body)
+	}
+	entry := make([]byte, 28)
+	binary.BigEndian.PutUint16(entry[16:], 2)
+	binary.BigEndian.PutUint16(entry[18:], 16)
+	copy(entry[24:], be32(44100<<16))
+	data := func(kind uint32, value string) []byte {
+		return mp4Box("data", be32(kind), be32(0), []byte(value))
+	}
+
+	trak := mp4Box("trak", mp4Box("mdia",
+		timeHeader("mdhd", 44100, 44100*5),
+		mp4Box("hdlr", make([]byte, 8), []byte("soun"), make([]byte, 13)),
+		mp4Box("minf", mp4Box("stbl", mp4Box("stsd", be32(0), be32(1), mp4Box("mp4a", entry)))),
+	))
+	ilst := mp4Box("ilst",
+		mp4Box("\xa9nam", data(1, "Title")),
+		mp4Box("\xa9ART", data(1, "Artist")),
+		mp4Box("trkn", data(0, "\x00\x00\x00\x04\x00\x0A\x00\x00")),
+		mp4Box("gnre", data(0, "\x00\x12")),
+		mp4Box("covr", data(13, "\xFF\xD8")),
+		mp4Box("----", mp4Box("mean", be32(0), []byte("com.apple.iTunes")), mp4Box("name", be32(0), []byte("MOOD")), data(1, "calm")),
+	)
+	meta := mp4Box("meta", be32(0), mp4Box("hdlr", make([]byte, 8), []byte("mdir"), make([]byte, 13)), ilst)
+
+	var b bytes.Buffer
+	b.Write(mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00")))
+	b.Write(mp4Box("moov", timeHeader("mvhd", 1000, 5000), trak, mp4Box("udta", meta)))
+	b.Write(mp4Box("mdat", make([]byte, 40000)))
+	return b.Bytes()
+}
+
+func TestExtractAudioMetadataFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		want AudioMetadata
+		tags map[string]string
+	}{
+		{"MP3 with ID3v2.3 and ID3v1", "song.mp3", testMP3(), AudioMetadata{
+			Format: "MP3", Codec: "MPEG-1 Layer 3", Duration: 261224489, Bitrate: 128, SampleRate: 44100, Channels: 2,
+			Title: "Latin title", Artist: "Ada", Album: "v1 album", Year: 2019, Genre: "Rock", TrackNum: 3,
+			Comment: "Nice one", HasCoverArt: true,
+		}, map[string]string{"TRCK": "3/12", "COMM": "Nice one"}},
+		{"WAV detected by content", "song.mp3", testWAV(), AudioMetadata{
+			Format: "WAV", Codec: "PCM", Duration: time.Second, Bitrate: 256, SampleRate: 8000, Channels: 2,
+			BitsPerSample: 16, Title: "Hello",
+		}, map[string]string{"INAM": "Hello"}},
+		{"FLAC", "song.flac", testFLAC(), AudioMetadata{
+			Format: "FLAC", Codec: "FLAC", Duration: 2 * time.Second, Bitrate: 4, SampleRate: 44100, Channels: 2,
+			BitsPerSample: 16, Artist: "Ada", Year: 2020, TrackNum: 5,
+		}, map[string]string{"ARTIST": "Ada; Grace"}},
+		{"FLAC behind an ID3 tag", "song.flac", append(id3Tag(4, id3Frame(4, "TALB", "\x03Album")), testFLAC()...), AudioMetadata{
+			Format: "FLAC", Codec: "FLAC", Duration: 2 * time.Second, Bitrate: 4, SampleRate: 44100, Channels: 2,
+			BitsPerSample: 16, Artist: "Ada", Album: "Album", Year: 2020, TrackNum: 5,
+		}, map[string]string{"TALB": "Album"}},
+		{"Ogg Opus", "song.opus", testOpus(), AudioMetadata{
+			Format: "OGG", Codec: "Opus", Duration: 3 * time.Second, Bitrate: 1, SampleRate: 44100, Channels: 2,
+			Title: "Song", Genre: "Ambient",
+		}, map[string]string{"TITLE": "Song"}},
+		{"M4A", "song.m4a", testM4A(), AudioMetadata{
+			Format: "M4A", Codec: "AAC", Duration: 5 * time.Second, Bitrate: 64, SampleRate: 44100, Channels: 2,
+			BitsPerSample: 16, Title: "Title", Artist: "Artist", Genre: "Rock", TrackNum: 4, HasCoverArt: true,
+		}, map[string]string{"©nam": "Title", "trkn": "4/10", "----:MOOD": "calm"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractAudioMetadata(writeTestAudio(t, tt.file, tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range tt.tags {
+				if got.Tags[key] != value {
+					t.Errorf("Tags[%q] = %q, want %q", key, got.Tags[key], value)
+				}
+			}
+			got.Tags, got.FileSize = nil, 0
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseID3v2Versions(t *testing.T) {
+	var m AudioMetadata
+	parseID3v2(id3Tag(4,
+		id3Frame(4, "TPE1", "\x03Ada\x00Grace"),
+		id3Frame(4, "TDRC", "\x032021-03-04"),
+		id3Frame(4, "TXXX", "\x03MOOD\x00calm"),
+		id3Frame(4, "TCON", "\x0317\x00Jazz"),
+		id3Frame(4, "TIT2", "\x02\x00H\x00i"),
+	), &m)
+	if m.Artist != "Ada; Grace" || m.Year != 2021 || m.Genre != "Rock; Jazz" || m.Title != "Hi" || m.Tags["TXXX:MOOD"] != "calm" {
+		t.Errorf("Unexpected ID3v2.4 result %+v", m)
+	}
+
+	m = AudioMetadata{}
+	v22 := []byte("TT2\x00\x00\x04\x00Old")
+	parseID3v2(append(append([]byte("ID3\x02\x00\x00"), syncsafeBytes(len(v22))...), v22...), &m)
+	if m.Title != "Old" || m.Tags["TIT2"] != "Old" {
+		t.Errorf("Unexpected ID3v2.2 result %+v", m)
+	}
+
+	// A frame size past the end stops parsing without panicking
+	m = AudioMetadata{}
+	parseID3v2(id3Tag(3, []byte("TIT2\x00\x00\x10\x00\x00\x00\x00Short")), &m)
+	if m.Title != "" {
+		t.Errorf("Expected the damaged frame to be skipped, got %q", m.Title)
+	}
+}
+
+func TestExtractAudioMetadataRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"text", []byte("just some words, not audio")},
+		{"short", []byte("ID")},
+	}
+	for _, tt := range tests {
+		if _, err := ExtractAudioMetadata(writeTestAudio(t, "a.mp3", tt.data)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
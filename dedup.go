@@ -0,0 +1,510 @@
+package textlib
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DedupOptions configures MinHash signatures and the near-duplicate index
+type DedupOptions struct {
+	NumHashes       int     // MinHash permutations (default 128)
+	Bands           int     // LSH bands; NumHashes must divide evenly (default 32)
+	ShingleSize     int     // Words per shingle (default 5)
+	Threshold       float64 // Minimum estimated Jaccard similarity of duplicates (default 0.8)
+	SimHashDistance int     // Maximum SimHash Hamming distance for extra candidates (0 uses DefaultSimHashDistance; see SimHashExact and SimHashDisabled)
+	Seed            uint64  // Seed for the hash permutations
+}
+
+// DefaultSimHashDistance is the SimHash distance used when
+// DedupOptions.SimHashDistance is 0
+const DefaultSimHashDistance = 3
+
+// Special values of DedupOptions.SimHashDistance
+const (
+	SimHashDisabled = -1 // No SimHash candidates
+	SimHashExact    = -2 // Only documents with an identical SimHash
+)
+
+func (o DedupOptions) withDefaults() DedupOptions {
+	if o.NumHashes <= 0 {
+		o.NumHashes = 128
+	}
+	if o.Bands <= 0 || o.NumHashes%o.Bands != 0 {
+		o.Bands = 32
+		for o.NumHashes%o.Bands != 0 {
+			o.Bands--
+		}
+	}
+	if o.ShingleSize <= 0 {
+		o.ShingleSize = 5
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = 0.8
+	}
+	switch {
+	case o.SimHashDistance == 0:
+		o.SimHashDistance = DefaultSimHashDistance
+	case o.SimHashDistance < SimHashExact:
+		o.SimHashDistance = SimHashDisabled
+	case o.SimHashDistance > 7:
+		o.SimHashDistance = 7
+	}
+	return o
+}
+
+// MinHashSignature estimates the Jaccard similarity of two documents' word
+// shingle sets from the fraction of matching minimum hashes
+type MinHashSignature []uint32
+
+// Jaccard returns the estimated Jaccard similarity of two signatures
+// computed with the same options
+func (s MinHashSignature) Jaccard(other MinHashSignature) float64 {
+	if len(s) == 0 || len(s) != len(other) {
+		return 0
+	}
+	matches := 0
+	for i := range s {
+		if s[i] == other[i] {
+			matches++
+		}
+	}
+	return float64(matches) / float64(len(s))
+}
+
+// ComputeMinHash returns the MinHash signature of text's word shingles
+func ComputeMinHash(text string, opts DedupOptions) MinHashSignature {
+	opts = opts.withDefaults()
+	sig := make(MinHashSignature, opts.NumHashes)
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+
+	seeds := minHashSeeds(opts.NumHashes, opts.Seed)
+	for _, h := range shingleHashes(text, opts.ShingleSize) {
+		for i, seed := range seeds {
+			if v := uint32(mix64(h ^ seed)); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// SimHash returns a 64-bit fingerprint of text in which similar documents
+// differ in few bits (Charikar, 2002)
+func SimHash(text string) uint64 {
+	var weights [64]int
+	counts := map[string]int{}
+	for _, word := range dedupWords(text) {
+		counts[word]++
+	}
+	if len(counts) == 0 {
+		return 0
+	}
+
+	for word, count := range counts {
+		h := mix64(fnvHash(word))
+		for b := 0; b < 64; b++ {
+			if h&(1<<uint(b)) != 0 {
+				weights[b] += count
+			} else {
+				weights[b] -= count
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for b, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << uint(b)
+		}
+	}
+	return fingerprint
+}
+
+// HammingDistance returns the number of differing bits in two fingerprints
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// dedupWords lowercases text and splits it into words without punctuation
+func dedupWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// shingleHashes hashes every run of size consecutive words. Documents
+// shorter than size form a single shingle.
+func shingleHashes(text string, size int) []uint64 {
+	words := dedupWords(text)
+	if len(words) == 0 {
+		return nil
+	}
+	if len(words) < size {
+		return []uint64{fnvHash(strings.Join(words, " "))}
+	}
+
+	seen := make(map[uint64]bool, len(words)-size+1)
+	hashes := make([]uint64, 0, len(words)-size+1)
+	for i := 0; i+size <= len(words); i++ {
+		h := fnvHash(strings.Join(words[i:i+size], " "))
+		if !seen[h] {
+			seen[h] = true
+			hashes = append(hashes, h)
+		}
+	}
+	return hashes
+}
+
+func fnvHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix64 is the splitmix64 finalizer, used to derive independent hash
+// functions from one base hash
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func minHashSeeds(n int, seed uint64) []uint64 {
+	seeds := make([]uint64, n)
+	state := seed
+	for i := range seeds {
+		state += 0x9e3779b97f4a7c15
+		seeds[i] = mix64(state)
+	}
+	return seeds
+}
+
+// DuplicateMatch is an indexed document similar to a query
+type DuplicateMatch struct {
+	ID              string
+	Jaccard         float64 // Estimated from MinHash signatures
+	HammingDistance int     // SimHash distance
+}
+
+// DuplicateCluster is a group of near-duplicate documents
+type DuplicateCluster struct {
+	IDs           []string // In insertion order; the first is the representative
+	MinSimilarity float64  // Lowest estimated Jaccard between a member and the representative
+}
+
+// DedupIndex finds near-duplicate documents using MinHash with LSH banding
+// and SimHash fingerprints. Documents are kept only as signatures, so the
+// index scales to millions of documents. It is safe for concurrent use.
+type DedupIndex struct {
+	opts        DedupOptions
+	simDistance int // Effective SimHash distance; -1 when disabled
+
+	mu        sync.RWMutex
+	ids       []string
+	positions map[string]int
+	sigs      []MinHashSignature
+	simhashes []uint64
+	bands     []map[uint64][]int32
+	simBlocks []map[uint64][]int32
+}
+
+// NewDedupIndex creates an empty index
+func NewDedupIndex(opts DedupOptions) *DedupIndex {
+	opts = opts.withDefaults()
+	x := &DedupIndex{
+		opts:      opts,
+		positions: map[string]int{},
+		bands:     make([]map[uint64][]int32, opts.Bands),
+	}
+	for i := range x.bands {
+		x.bands[i] = map[uint64][]int32{}
+	}
+	x.simDistance = opts.SimHashDistance
+	if x.simDistance == SimHashExact {
+		x.simDistance = 0
+	}
+	if x.simDistance >= 0 {
+		x.simBlocks = make([]map[uint64][]int32, x.simDistance+1)
+		for i := range x.simBlocks {
+			x.simBlocks[i] = map[uint64][]int32{}
+		}
+	}
+	return x
+}
+
+// Len returns the number of indexed documents
+func (x *DedupIndex) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.ids)
+}
+
+// Add indexes a document. Signatures are computed before the index is
+// locked, so concurrent Adds hash documents in parallel.
+func (x *DedupIndex) Add(id, text string) error {
+	return x.AddSignature(id, ComputeMinHash(text, x.opts), SimHash(text))
+}
+
+// AddSignature indexes a document whose signature and fingerprint were
+// computed elsewhere with the same options
+func (x *DedupIndex) AddSignature(id string, sig MinHashSignature, simhash uint64) error {
+	if len(sig) != x.opts.NumHashes {
+		return fmt.Errorf("signature has %d hashes, index expects %d", len(sig), x.opts.NumHashes)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if _, exists := x.positions[id]; exists {
+		return fmt.Errorf("document %q already indexed", id)
+	}
+
+	pos := int32(len(x.ids))
+	x.ids = append(x.ids, id)
+	x.positions[id] = int(pos)
+	x.sigs = append(x.sigs, sig)
+	x.simhashes = append(x.simhashes, simhash)
+
+	for band, key := range x.bandKeys(sig) {
+		x.bands[band][key] = append(x.bands[band][key], pos)
+	}
+	for block, key := range x.simBlockKeys(simhash) {
+		x.simBlocks[block][key] = append(x.simBlocks[block][key], pos)
+	}
+	return nil
+}
+
+// Query returns indexed documents whose estimated Jaccard similarity to text
+// reaches the threshold, most similar first
+func (x *DedupIndex) Query(text string) []DuplicateMatch {
+	sig := ComputeMinHash(text, x.opts)
+	simhash := SimHash(text)
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	matches := []DuplicateMatch{}
+	for _, pos := range x.candidates(sig, simhash) {
+		if j := sig.Jaccard(x.sigs[pos]); j >= x.opts.Threshold {
+			matches = append(matches, DuplicateMatch{
+				ID:              x.ids[pos],
+				Jaccard:         j,
+				HammingDistance: HammingDistance(simhash, x.simhashes[pos]),
+			})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Jaccard != matches[j].Jaccard {
+			return matches[i].Jaccard > matches[j].Jaccard
+		}
+		return x.positions[matches[i].ID] < x.positions[matches[j].ID]
+	})
+	return matches
+}
+
+// Clusters groups indexed documents into near-duplicate clusters of two or
+// more documents. Every member's estimated Jaccard similarity with some
+// other member reaches the threshold.
+func (x *DedupIndex) Clusters() []DuplicateCluster {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	parent := make([]int32, len(x.ids))
+	for i := range parent {
+		parent[i] = int32(i)
+	}
+	find := func(i int32) int32 {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	union := func(a, b int32) {
+		ra, rb := find(a), find(b)
+		if ra == rb {
+			return
+		}
+		if ra < rb {
+			parent[rb] = ra
+		} else {
+			parent[ra] = rb
+		}
+	}
+
+	// Within a bucket, compare each document against the documents that did
+	// not match an earlier one, so buckets of exact copies stay linear
+	link := func(bucket []int32, similar func(a, b int32) bool) {
+		var reps []int32
+		for _, doc := range bucket {
+			matched := false
+			for _, rep := range reps {
+				if find(doc) == find(rep) || similar(doc, rep) {
+					union(doc, rep)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				reps = append(reps, doc)
+			}
+		}
+	}
+	minhashSimilar := func(a, b int32) bool {
+		return x.sigs[a].Jaccard(x.sigs[b]) >= x.opts.Threshold
+	}
+	simhashSimilar := func(a, b int32) bool {
+		return HammingDistance(x.simhashes[a], x.simhashes[b]) <= x.simDistance && minhashSimilar(a, b)
+	}
+
+	for _, band := range x.bands {
+		for _, bucket := range band {
+			if len(bucket) > 1 {
+				link(bucket, minhashSimilar)
+			}
+		}
+	}
+	for _, table := range x.simBlocks {
+		for _, bucket := range table {
+			if len(bucket) > 1 {
+				link(bucket, simhashSimilar)
+			}
+		}
+	}
+
+	groups := map[int32][]int32{}
+	for i := range parent {
+		root := find(int32(i))
+		groups[root] = append(groups[root], int32(i))
+	}
+
+	clusters := []DuplicateCluster{}
+	for root, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		cluster := DuplicateCluster{MinSimilarity: 1}
+		for _, m := range members {
+			cluster.IDs = append(cluster.IDs, x.ids[m])
+			if m != root {
+				if j := x.sigs[m].Jaccard(x.sigs[root]); j < cluster.MinSimilarity {
+					cluster.MinSimilarity = j
+				}
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return x.positions[clusters[i].IDs[0]] < x.positions[clusters[j].IDs[0]]
+	})
+	return clusters
+}
+
+// candidates returns documents sharing an LSH band or a SimHash block with
+// the query
+func (x *DedupIndex) candidates(sig MinHashSignature, simhash uint64) []int32 {
+	seen := map[int32]bool{}
+	var result []int32
+	add := func(bucket []int32) {
+		for _, pos := range bucket {
+			if !seen[pos] {
+				seen[pos] = true
+				result = append(result, pos)
+			}
+		}
+	}
+
+	for band, key := range x.bandKeys(sig) {
+		add(x.bands[band][key])
+	}
+	for block, key := range x.simBlockKeys(simhash) {
+		for _, pos := range x.simBlocks[block][key] {
+			if HammingDistance(simhash, x.simhashes[pos]) <= x.simDistance {
+				add([]int32{pos})
+			}
+		}
+	}
+	return result
+}
+
+// bandKeys hashes each band of rows of the signature
+func (x *DedupIndex) bandKeys(sig MinHashSignature) []uint64 {
+	rows := len(sig) / x.opts.Bands
+	keys := make([]uint64, x.opts.Bands)
+	for band := range keys {
+		h := uint64(band) * 0x9e3779b97f4a7c15
+		for _, v := range sig[band*rows : (band+1)*rows] {
+			h = mix64(h ^ uint64(v))
+		}
+		keys[band] = h
+	}
+	return keys
+}
+
+// simBlockKeys splits a fingerprint into SimHashDistance+1 blocks. Two
+// fingerprints within the distance agree on at least one whole block.
+func (x *DedupIndex) simBlockKeys(simhash uint64) []uint64 {
+	n := len(x.simBlocks)
+	keys := make([]uint64, n)
+	for i := range keys {
+		lo, hi := i*64/n, (i+1)*64/n
+		mask := uint64(1)<<uint(hi-lo) - 1
+		keys[i] = (simhash >> uint(lo)) & mask
+	}
+	return keys
+}
+
+// FindNearDuplicates indexes docs, keyed by ID, and returns their
+// near-duplicate clusters. IDs are indexed in sorted order.
+func FindNearDuplicates(docs map[string]string, opts DedupOptions) []DuplicateCluster {
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	opts = opts.withDefaults()
+	type signed struct {
+		sig     MinHashSignature
+		simhash uint64
+	}
+	signatures := make([]signed, len(ids))
+
+	// Hashing dominates the cost, so spread it across workers
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				text := docs[ids[i]]
+				signatures[i] = signed{ComputeMinHash(text, opts), SimHash(text)}
+			}
+		}()
+	}
+	for i := range ids {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	index := NewDedupIndex(opts)
+	for i, id := range ids {
+		index.AddSignature(id, signatures[i].sig, signatures[i].simhash)
+	}
+	return index.Clusters()
+}
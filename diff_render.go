@@ -0,0 +1,357 @@
+package textlib
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// DiffRenderOptions configures RenderDiffHTML, RenderDiffANSI and
+// RenderDiffMarkdown
+type DiffRenderOptions struct {
+	Context    int  // Unchanged lines kept around each change when collapsing (default 3, negative for none)
+	Collapse   bool // Fold unchanged lines further than Context from a change into a marker
+	SideBySide bool // HTML only: two-column table instead of inline markup
+	Summary    bool // Append the changed words and added and removed sentences
+}
+
+// diffSpan is a run of text that is unchanged, removed or added
+type diffSpan struct {
+	op   byte // '=', '-' or '+'
+	text string
+}
+
+// diffRow is one line of rendered output
+type diffRow struct {
+	kind         string // "equal", "change" or "collapsed"
+	oldNo, newNo int    // 1-based line numbers, 0 when the side has no line
+	spans        []diffSpan
+	skipped      int // Lines folded into a "collapsed" row
+}
+
+// oldSpans and newSpans return the row's text on each side
+func (r diffRow) oldSpans() []diffSpan { return filterSpans(r.spans, '+') }
+func (r diffRow) newSpans() []diffSpan { return filterSpans(r.spans, '-') }
+
+func filterSpans(spans []diffSpan, drop byte) []diffSpan {
+	var out []diffSpan
+	for _, s := range spans {
+		if s.op != drop {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// diffTexts rebuilds the two texts a TextDiff was computed from
+func diffTexts(diff *TextDiff) (string, string) {
+	var oldText, newText strings.Builder
+	for _, op := range diff.Operations {
+		if op.Type != "insert" {
+			oldText.WriteString(op.Text)
+		}
+		if op.Type != "delete" {
+			newText.WriteString(op.Text)
+		}
+	}
+	return oldText.String(), newText.String()
+}
+
+// buildDiffRows aligns the texts by line, pairs up replaced lines and diffs
+// each pair by word
+func buildDiffRows(diff *TextDiff, opts DiffRenderOptions) []diffRow {
+	oldText, newText := diffTexts(diff)
+	oldLines, newLines := splitLinesKeepEnds(oldText), splitLinesKeepEnds(newText)
+	trim := func(line string) string { return strings.TrimRight(line, "\r\n") }
+
+	var rows []diffRow
+	var removed, added []int
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			row := diffRow{kind: "change"}
+			switch {
+			case i < len(removed) && i < len(added):
+				row.oldNo, row.newNo = removed[i]+1, added[i]+1
+				row.spans = wordSpans(trim(oldLines[removed[i]]), trim(newLines[added[i]]))
+			case i < len(removed):
+				row.oldNo = removed[i] + 1
+				row.spans = []diffSpan{{'-', trim(oldLines[removed[i]])}}
+			default:
+				row.newNo = added[i] + 1
+				row.spans = []diffSpan{{'+', trim(newLines[added[i]])}}
+			}
+			rows = append(rows, row)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+
+	for _, e := range diffUnits(oldLines, newLines, "") {
+		switch e.op {
+		case '-':
+			removed = append(removed, e.a)
+		case '+':
+			added = append(added, e.b)
+		default:
+			flush()
+			rows = append(rows, diffRow{kind: "equal", oldNo: e.a + 1, newNo: e.b + 1, spans: []diffSpan{{'=', trim(oldLines[e.a])}}})
+		}
+	}
+	flush()
+
+	if opts.Collapse {
+		rows = collapseRows(rows, opts.Context)
+	}
+	return rows
+}
+
+// wordSpans diffs two lines at UAX #29 word boundaries
+func wordSpans(oldLine, newLine string) []diffSpan {
+	oldWords, newWords := wordUnits(oldLine), wordUnits(newLine)
+
+	var spans []diffSpan
+	for _, e := range diffUnits(oldWords, newWords, "") {
+		text := ""
+		if e.op == '+' {
+			text = newWords[e.b]
+		} else {
+			text = oldWords[e.a]
+		}
+		if n := len(spans); n > 0 && spans[n-1].op == e.op {
+			spans[n-1].text += text
+			continue
+		}
+		spans = append(spans, diffSpan{e.op, text})
+	}
+	return spans
+}
+
+// collapseRows folds runs of unchanged rows more than context rows away from
+// any change
+func collapseRows(rows []diffRow, context int) []diffRow {
+	if context == 0 {
+		context = 3
+	} else if context < 0 {
+		context = 0
+	}
+
+	keep := make([]bool, len(rows))
+	for i, row := range rows {
+		if row.kind != "change" {
+			continue
+		}
+		for j := max(i-context, 0); j <= min(i+context, len(rows)-1); j++ {
+			keep[j] = true
+		}
+	}
+
+	var out []diffRow
+	for i, row := range rows {
+		if keep[i] {
+			out = append(out, row)
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].kind == "collapsed" {
+			out[n-1].skipped++
+			continue
+		}
+		out = append(out, diffRow{kind: "collapsed", oldNo: row.oldNo, newNo: row.newNo, skipped: 1})
+	}
+	return out
+}
+
+func collapsedLabel(n int) string {
+	if n == 1 {
+		return "1 unchanged line"
+	}
+	return fmt.Sprintf("%d unchanged lines", n)
+}
+
+// RenderDiffHTML renders a TextDiff as HTML, marking removed words with <del>
+// and added words with <ins>. Inline output interleaves both versions line by
+// line; side-by-side output is a four-column table of line numbers and text.
+// Elements carry "diff-" classes for styling.
+func RenderDiffHTML(diff *TextDiff, opts DiffRenderOptions) string {
+	rows := buildDiffRows(diff, opts)
+	var sb strings.Builder
+
+	spansHTML := func(spans []diffSpan) string {
+		var b strings.Builder
+		for _, s := range spans {
+			text := html.EscapeString(s.text)
+			switch s.op {
+			case '-':
+				b.WriteString("<del>" + text + "</del>")
+			case '+':
+				b.WriteString("<ins>" + text + "</ins>")
+			default:
+				b.WriteString(text)
+			}
+		}
+		return b.String()
+	}
+	lineNo := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprint(n)
+	}
+
+	if opts.SideBySide {
+		sb.WriteString("<table class=\"diff diff-side-by-side\">\n")
+		for _, row := range rows {
+			switch row.kind {
+			case "collapsed":
+				fmt.Fprintf(&sb, "<tr class=\"diff-collapsed\"><td colspan=\"4\">%s</td></tr>\n", collapsedLabel(row.skipped))
+			default:
+				oldHTML, newHTML := spansHTML(row.oldSpans()), spansHTML(row.newSpans())
+				fmt.Fprintf(&sb, "<tr class=\"diff-%s\"><td class=\"diff-num\">%s</td><td class=\"diff-old\">%s</td><td class=\"diff-num\">%s</td><td class=\"diff-new\">%s</td></tr>\n",
+					row.kind, lineNo(row.oldNo), oldHTML, lineNo(row.newNo), newHTML)
+			}
+		}
+		sb.WriteString("</table>\n")
+	} else {
+		sb.WriteString("<div class=\"diff diff-inline\">\n")
+		for _, row := range rows {
+			switch row.kind {
+			case "collapsed":
+				fmt.Fprintf(&sb, "<div class=\"diff-collapsed\">%s</div>\n", collapsedLabel(row.skipped))
+			default:
+				fmt.Fprintf(&sb, "<div class=\"diff-%s\">%s</div>\n", row.kind, spansHTML(row.spans))
+			}
+		}
+		sb.WriteString("</div>\n")
+	}
+
+	if opts.Summary {
+		items := diffSummary(diff)
+		if len(items) > 0 {
+			sb.WriteString("<ul class=\"diff-summary\">\n")
+			for _, item := range items {
+				sb.WriteString("<li>" + html.EscapeString(item) + "</li>\n")
+			}
+			sb.WriteString("</ul>\n")
+		}
+	}
+
+	return sb.String()
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiDim   = "\x1b[2m"
+)
+
+// RenderDiffANSI renders a TextDiff for a terminal: removed words in red and
+// added words in green, with whole removed or added lines prefixed by "-"
+// or "+"
+func RenderDiffANSI(diff *TextDiff, opts DiffRenderOptions) string {
+	var sb strings.Builder
+	for _, row := range buildDiffRows(diff, opts) {
+		prefix := "  "
+		switch {
+		case row.kind == "collapsed":
+			sb.WriteString(ansiDim + "@@ " + collapsedLabel(row.skipped) + " @@" + ansiReset + "\n")
+			continue
+		case row.kind == "change" && row.newNo == 0:
+			prefix = ansiRed + "- " + ansiReset
+		case row.kind == "change" && row.oldNo == 0:
+			prefix = ansiGreen + "+ " + ansiReset
+		case row.kind == "change":
+			prefix = "~ "
+		}
+
+		sb.WriteString(prefix)
+		for _, s := range row.spans {
+			switch s.op {
+			case '-':
+				sb.WriteString(ansiRed + s.text + ansiReset)
+			case '+':
+				sb.WriteString(ansiGreen + s.text + ansiReset)
+			default:
+				sb.WriteString(s.text)
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if opts.Summary {
+		for _, item := range diffSummary(diff) {
+			sb.WriteString(ansiDim + "* " + item + ansiReset + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// RenderDiffMarkdown renders a TextDiff as a Markdown redline, striking
+// through removed words (~~old~~) and bolding added ones (**new**)
+func RenderDiffMarkdown(diff *TextDiff, opts DiffRenderOptions) string {
+	var sb strings.Builder
+	for _, row := range buildDiffRows(diff, opts) {
+		if row.kind == "collapsed" {
+			sb.WriteString("*… " + collapsedLabel(row.skipped) + " …*\n")
+			continue
+		}
+		for _, s := range row.spans {
+			switch s.op {
+			case '-':
+				sb.WriteString(markdownMarkSpan(s.text, "~~", false))
+			case '+':
+				sb.WriteString(markdownMarkSpan(s.text, "**", true))
+			default:
+				sb.WriteString(escapeMarkdownInline(s.text))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if opts.Summary {
+		items := diffSummary(diff)
+		if len(items) > 0 {
+			sb.WriteString("\n")
+		}
+		for _, item := range items {
+			sb.WriteString("- " + escapeMarkdownInline(item) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// markdownMarkSpan wraps the non-space part of text in marker, since
+// emphasis cannot start or end with whitespace. Removed whitespace is
+// dropped; added whitespace is kept.
+func markdownMarkSpan(text, marker string, keepSpace bool) string {
+	core := strings.TrimSpace(text)
+	if core == "" {
+		if keepSpace {
+			return text
+		}
+		return ""
+	}
+	i := strings.Index(text, core)
+	lead, trail := text[:i], text[i+len(core):]
+	return lead + marker + escapeMarkdownInline(core) + marker + trail
+}
+
+var markdownInlineEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, "`", "\\`")
+
+func escapeMarkdownInline(text string) string {
+	return markdownInlineEscaper.Replace(text)
+}
+
+// diffSummary lists the word substitutions and the sentences added and
+// removed
+func diffSummary(diff *TextDiff) []string {
+	var items []string
+	for _, c := range diff.ChangedWords {
+		items = append(items, fmt.Sprintf("changed %q to %q", c.Original, c.Changed))
+	}
+	for _, s := range diff.AddedSentences {
+		items = append(items, "added sentence: "+s)
+	}
+	for _, s := range diff.RemovedSentences {
+		items = append(items, "removed sentence: "+s)
+	}
+	return items
+}
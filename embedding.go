@@ -0,0 +1,341 @@
+package textlib
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Embedder maps text to a fixed-length vector in which similar texts point
+// in similar directions. Implementations may wrap a local model or a table
+// of pretrained word vectors.
+type Embedder interface {
+	Embed(text string) []float64
+	Dimensions() int
+}
+
+// HashedNGramEmbedder embeds text by hashing words, their Porter2 stems,
+// word bigrams and character trigrams into a fixed number of signed buckets.
+// It needs no model files, so it works offline, and texts sharing vocabulary
+// or word stems get similar vectors.
+type HashedNGramEmbedder struct {
+	dims int
+}
+
+const defaultEmbeddingDimensions = 512
+
+// NewHashedNGramEmbedder creates an embedder with dims buckets (default 512)
+func NewHashedNGramEmbedder(dims int) *HashedNGramEmbedder {
+	if dims <= 0 {
+		dims = defaultEmbeddingDimensions
+	}
+	return &HashedNGramEmbedder{dims: dims}
+}
+
+// Dimensions returns the vector length
+func (e *HashedNGramEmbedder) Dimensions() int {
+	return e.dims
+}
+
+// Embed returns the L2-normalized feature vector of text
+func (e *HashedNGramEmbedder) Embed(text string) []float64 {
+	vec := make([]float64, e.dims)
+
+	var words []string
+	for _, tok := range Tokenize(text) {
+		if tok.Kind == "word" || tok.Kind == "number" || tok.Kind == "ideographic" {
+			words = append(words, strings.ToLower(tok.Text))
+		}
+	}
+
+	prev := ""
+	for _, word := range words {
+		if isStopWord(word) {
+			prev = ""
+			continue
+		}
+
+		e.add(vec, "w:"+word, 1)
+		e.add(vec, "s:"+EnglishStemmer{}.Stem(word), 0.5)
+		if prev != "" {
+			e.add(vec, "b:"+prev+" "+word, 0.5)
+		}
+		prev = word
+
+		// Character trigrams let inflections and compounds share features
+		runes := []rune("<" + word + ">")
+		for i := 0; i+3 <= len(runes); i++ {
+			e.add(vec, "c:"+string(runes[i:i+3]), 0.25)
+		}
+	}
+
+	normalizeVector(vec)
+	return vec
+}
+
+func (e *HashedNGramEmbedder) add(vec []float64, feature string, weight float64) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	// The top bit picks the sign so that collisions cancel out on average
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vec[sum%uint64(e.dims)] += weight
+}
+
+func normalizeVector(vec []float64) {
+	norm := 0.0
+	for _, v := range vec {
+		norm += v * v
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range vec {
+		vec[i] /= norm
+	}
+}
+
+// VectorCosineSimilarity returns the cosine of the angle between a and b,
+// or 0 if either is a zero vector or their lengths differ
+func VectorCosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// embedSum returns the sum of the sentence vectors, the embedding of a
+// block of sentences
+func embedSum(embedder Embedder, sentences []string) []float64 {
+	sum := make([]float64, embedder.Dimensions())
+	for _, s := range sentences {
+		for i, v := range embedder.Embed(s) {
+			sum[i] += v
+		}
+	}
+	return sum
+}
+
+// embeddedSimilarity is the cosine similarity of sentence and the block of
+// sentences in group
+func embeddedSimilarity(embedder Embedder, group []string, sentence string) float64 {
+	return VectorCosineSimilarity(embedSum(embedder, group), embedder.Embed(sentence))
+}
+
+// semanticCoherence scores how well sentence continues group, using the
+// strategy's Embedder when set and lexical overlap otherwise
+func (s ChunkingStrategy) semanticCoherence(group []string, sentence string) float64 {
+	if s.Embedder == nil {
+		return calculateSemanticCoherence(group, sentence)
+	}
+	return embeddedSimilarity(s.Embedder, group, sentence)
+}
+
+// TextTilingOptions configures FindTopicBoundaries
+type TextTilingOptions struct {
+	Embedder     Embedder // Sentence embedder (default NewHashedNGramEmbedder(0))
+	BlockSize    int      // Sentences compared on each side of a gap (default 3)
+	Smoothing    int      // Half-width of the moving average over gap similarities (0 disables)
+	Threshold    float64  // Minimum depth score for a boundary; 0 uses mean + stddev/2
+	MinSentences int      // Minimum sentences between boundaries (default 2)
+}
+
+// TopicBoundary is a point between two sentences where the topic shifts
+type TopicBoundary struct {
+	SentenceIndex int     // Index of the first sentence after the boundary
+	Offset        int     // Byte offset of that sentence
+	Similarity    float64 // Smoothed similarity of the blocks either side
+	Depth         float64 // How far the similarity dips below the neighbouring peaks
+}
+
+// FindTopicBoundaries detects topic shifts with the TextTiling algorithm
+// (Hearst, 1997): it embeds blocks of sentences on either side of every
+// sentence gap, smooths the cosine similarities and places boundaries at the
+// deepest valleys
+func FindTopicBoundaries(text string, opts TextTilingOptions) []TopicBoundary {
+	sentences := sentenceTokens(text)
+	boundaries := []TopicBoundary{}
+	if len(sentences) < 2 {
+		return boundaries
+	}
+
+	embedder := opts.Embedder
+	if embedder == nil {
+		embedder = NewHashedNGramEmbedder(0)
+	}
+	blockSize := opts.BlockSize
+	if blockSize <= 0 {
+		blockSize = 3
+	}
+	smoothing := max(opts.Smoothing, 0)
+	minSentences := opts.MinSentences
+	if minSentences <= 0 {
+		minSentences = 2
+	}
+
+	vectors := make([][]float64, len(sentences))
+	for i, sent := range sentences {
+		vectors[i] = embedder.Embed(sent.Text)
+	}
+	blockVector := func(from, to int) []float64 {
+		sum := make([]float64, embedder.Dimensions())
+		for _, v := range vectors[max(from, 0):min(to, len(vectors))] {
+			for i := range v {
+				sum[i] += v[i]
+			}
+		}
+		return sum
+	}
+
+	// Gap i lies between sentence i and sentence i+1
+	gaps := len(sentences) - 1
+	raw := make([]float64, gaps)
+	for i := range raw {
+		raw[i] = VectorCosineSimilarity(blockVector(i+1-blockSize, i+1), blockVector(i+1, i+1+blockSize))
+	}
+
+	similarity := make([]float64, gaps)
+	for i := range raw {
+		sum, n := 0.0, 0
+		for j := max(i-smoothing, 0); j <= min(i+smoothing, gaps-1); j++ {
+			sum += raw[j]
+			n++
+		}
+		similarity[i] = sum / float64(n)
+	}
+
+	// Depth: climb to the highest similarity on each side of the valley
+	depths := make([]float64, gaps)
+	for i := range similarity {
+		left := similarity[i]
+		for j := i - 1; j >= 0 && similarity[j] >= left; j-- {
+			left = similarity[j]
+		}
+		right := similarity[i]
+		for j := i + 1; j < gaps && similarity[j] >= right; j++ {
+			right = similarity[j]
+		}
+		depths[i] = (left - similarity[i]) + (right - similarity[i])
+	}
+
+	cutoff := opts.Threshold
+	if cutoff <= 0 {
+		mean, stddev := meanStdDev(depths)
+		cutoff = mean + stddev/2
+	}
+
+	// Take the deepest valleys first, keeping boundaries apart
+	order := make([]int, gaps)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return depths[order[a]] > depths[order[b]]
+	})
+
+	var chosen []int
+	for _, gap := range order {
+		if depths[gap] <= 0 || depths[gap] < cutoff {
+			break
+		}
+		next := gap + 1
+		if next < minSentences || len(sentences)-next < minSentences {
+			continue
+		}
+		tooClose := false
+		for _, c := range chosen {
+			if c-next < minSentences && next-c < minSentences {
+				tooClose = true
+				break
+			}
+		}
+		if !tooClose {
+			chosen = append(chosen, next)
+		}
+	}
+	sort.Ints(chosen)
+
+	for _, next := range chosen {
+		boundaries = append(boundaries, TopicBoundary{
+			SentenceIndex: next,
+			Offset:        sentences[next].Position.Start,
+			Similarity:    similarity[next-1],
+			Depth:         depths[next-1],
+		})
+	}
+
+	return boundaries
+}
+
+// SegmentByTextTiling splits text into topic segments at the boundaries
+// found by FindTopicBoundaries. Segment offsets are byte offsets.
+func SegmentByTextTiling(text string, opts TextTilingOptions) []Segment {
+	segments := []Segment{}
+	sentences := sentenceTokens(text)
+	if len(sentences) == 0 {
+		return segments
+	}
+
+	boundaries := FindTopicBoundaries(text, opts)
+	cuts := []int{0}
+	for _, b := range boundaries {
+		cuts = append(cuts, b.SentenceIndex)
+	}
+	cuts = append(cuts, len(sentences))
+
+	for i := 0; i+1 < len(cuts); i++ {
+		first, last := sentences[cuts[i]], sentences[cuts[i+1]-1]
+		start, end := first.Position.Start, last.Position.End
+		segText := text[start:end]
+
+		segment := Segment{
+			Text:       segText,
+			Start:      start,
+			End:        end,
+			Type:       "topic",
+			TokenCount: len(strings.Fields(segText)),
+			CharCount:  len(segText),
+			Metadata: map[string]interface{}{
+				"sentenceCount": cuts[i+1] - cuts[i],
+			},
+		}
+		if i > 0 {
+			segment.Metadata["depth"] = boundaries[i-1].Depth
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
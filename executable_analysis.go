@@ -0,0 +1,623 @@
+package textlib
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// ExecutableHardening lists the exploit mitigations a binary was built with
+type ExecutableHardening struct {
+	PIE         bool   // position independent executable
+	NX          bool   // non-executable stack and data (DEP on Windows)
+	ASLR        bool   // the loader may randomize the base address
+	RELRO       string // ELF only: "none", "partial" or "full"
+	StackCanary bool   // stack protector (/GS security cookie on Windows)
+	Fortified   bool   // ELF only: _FORTIFY_SOURCE checked functions are used
+	CFG         bool   // PE only: Control Flow Guard
+	CodeSigned  bool   // Authenticode or Mach-O code signature present
+}
+
+var elfArchitectures = map[elf.Machine]string{
+	elf.EM_386:       "x86",
+	elf.EM_X86_64:    "x86_64",
+	elf.EM_ARM:       "arm",
+	elf.EM_AARCH64:   "arm64",
+	elf.EM_PPC:       "ppc",
+	elf.EM_PPC64:     "ppc64",
+	elf.EM_MIPS:      "mips",
+	elf.EM_RISCV:     "riscv",
+	elf.EM_S390:      "s390x",
+	elf.EM_SPARCV9:   "sparc64",
+	elf.EM_LOONGARCH: "loong64",
+}
+
+var peArchitectures = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:    "x86",
+	pe.IMAGE_FILE_MACHINE_AMD64:   "x86_64",
+	pe.IMAGE_FILE_MACHINE_ARM:     "arm",
+	pe.IMAGE_FILE_MACHINE_ARMNT:   "arm",
+	pe.IMAGE_FILE_MACHINE_ARM64:   "arm64",
+	pe.IMAGE_FILE_MACHINE_IA64:    "ia64",
+	pe.IMAGE_FILE_MACHINE_RISCV64: "riscv64",
+}
+
+var machoArchitectures = map[macho.Cpu]string{
+	macho.Cpu386:   "x86",
+	macho.CpuAmd64: "x86_64",
+	macho.CpuArm:   "arm",
+	macho.CpuArm64: "arm64",
+	macho.CpuPpc:   "ppc",
+	macho.CpuPpc64: "ppc64",
+}
+
+// Mach-O load commands not exported by debug/macho
+const (
+	machoLoadDylib       = 0xc
+	machoLoadWeakDylib   = 0x80000018
+	machoReexportDylib   = 0x8000001f
+	machoLazyLoadDylib   = 0x20
+	machoLoadUpwardDylib = 0x80000023
+	machoCodeSignature   = 0x1d
+	machoUnixThread      = 0x5
+	machoMain            = 0x80000028
+)
+
+// Register index of the program counter in the LC_UNIXTHREAD state of
+// x86_THREAD_STATE64 (rip) and ARM_THREAD_STATE64 (pc)
+var machoThreadPC = map[macho.Cpu]int{
+	macho.CpuAmd64: 16,
+	macho.CpuArm64: 32,
+}
+
+// isMachOMagic reports whether header starts a thin or fat Mach-O file.
+// The fat magic is shared with Java class files, which are told apart
+// by their version number being far larger than any plausible arch count.
+func isMachOMagic(header []byte) bool {
+	if len(header) < 8 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(header) {
+	case macho.Magic32, macho.Magic64, 0xcefaedfe, 0xcffaedfe:
+		return true
+	case macho.MagicFat:
+		n := binary.BigEndian.Uint32(header[4:])
+		return n > 0 && n < 45
+	}
+	return false
+}
+
+// sectionEntropy returns the Shannon entropy of r in bits per byte (0-8)
+func sectionEntropy(r io.Reader) float64 {
+	var counts [256]int64
+	var total int64
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			counts[b]++
+		}
+		total += int64(n)
+		if err != nil {
+			break
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+
+	entropy := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// sectionPermissions renders the access flags of s as "rwx" with dashes
+func sectionPermissions(s Section) string {
+	perms := []byte("---")
+	if s.IsReadable {
+		perms[0] = 'r'
+	}
+	if s.IsWritable {
+		perms[1] = 'w'
+	}
+	if s.IsExecutable {
+		perms[2] = 'x'
+	}
+	return string(perms)
+}
+
+func analyzeELFHeaders(file *os.File, info ExecutableInfo) (ExecutableInfo, error) {
+	f, err := elf.NewFile(file)
+	if err != nil {
+		return info, fmt.Errorf("malformed ELF file: %w", err)
+	}
+
+	info.Architecture = elfArchitectures[f.Machine]
+	if info.Architecture == "" {
+		info.Architecture = strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+	}
+	info.Bits = 32
+	if f.Class == elf.ELFCLASS64 {
+		info.Bits = 64
+	}
+	info.Entrypoint = f.Entry
+
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NULL {
+			continue
+		}
+		section := Section{
+			Name:         s.Name,
+			VirtualAddr:  s.Addr,
+			VirtualSize:  s.Size,
+			IsExecutable: s.Flags&elf.SHF_EXECINSTR != 0,
+			IsWritable:   s.Flags&elf.SHF_WRITE != 0,
+			IsReadable:   s.Flags&elf.SHF_ALLOC != 0,
+		}
+		if s.Type != elf.SHT_NOBITS {
+			section.RawSize = uint32(s.FileSize)
+			section.Entropy = sectionEntropy(s.Open())
+		}
+		section.Permissions = sectionPermissions(section)
+		info.Sections = append(info.Sections, section)
+
+		if strings.HasPrefix(s.Name, ".debug_") || strings.HasPrefix(s.Name, ".zdebug_") {
+			info.HasDebugInfo = true
+		}
+	}
+
+	info.Libraries, _ = f.ImportedLibraries()
+	imported, _ := f.ImportedSymbols()
+	for _, sym := range imported {
+		info.Imports = append(info.Imports, ExecutableImport{Library: sym.Library, Function: sym.Name})
+	}
+
+	dynamic, _ := f.DynamicSymbols()
+	for _, sym := range dynamic {
+		bind, typ := elf.ST_BIND(sym.Info), elf.ST_TYPE(sym.Info)
+		if sym.Section == elf.SHN_UNDEF || (bind != elf.STB_GLOBAL && bind != elf.STB_WEAK) ||
+			(typ != elf.STT_FUNC && typ != elf.STT_OBJECT) {
+			continue
+		}
+		info.Exports = append(info.Exports, Export{Name: sym.Name, Address: sym.Value})
+	}
+
+	info.Compiler = elfCompiler(f)
+	info.Hardening = elfHardening(f, imported)
+	return info, nil
+}
+
+// elfCompiler names the toolchain from Go build notes or the .comment section
+func elfCompiler(f *elf.File) string {
+	if f.Section(".go.buildinfo") != nil || f.Section(".note.go.buildid") != nil {
+		return "Go"
+	}
+	comment := f.Section(".comment")
+	if comment == nil {
+		return ""
+	}
+	data, err := comment.Data()
+	if err != nil {
+		return ""
+	}
+	for _, entry := range bytes.Split(data, []byte{0}) {
+		if s := strings.TrimSpace(string(entry)); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func elfHardening(f *elf.File, imported []elf.ImportedSymbol) ExecutableHardening {
+	h := ExecutableHardening{RELRO: "none"}
+
+	hasInterp, hasRELRO := false, false
+	for _, p := range f.Progs {
+		switch p.Type {
+		case elf.PT_INTERP:
+			hasInterp = true
+		case elf.PT_GNU_STACK:
+			h.NX = p.Flags&elf.PF_X == 0
+		case elf.PT_GNU_RELRO:
+			hasRELRO = true
+		}
+	}
+
+	var flags, flags1 uint64
+	if values, _ := f.DynValue(elf.DT_FLAGS); len(values) > 0 {
+		flags = values[0]
+	}
+	if values, _ := f.DynValue(elf.DT_FLAGS_1); len(values) > 0 {
+		flags1 = values[0]
+	}
+	bindNow, _ := f.DynValue(elf.DT_BIND_NOW)
+
+	if hasRELRO {
+		h.RELRO = "partial"
+		if len(bindNow) > 0 || flags&uint64(elf.DF_BIND_NOW) != 0 || flags1&uint64(elf.DF_1_NOW) != 0 {
+			h.RELRO = "full"
+		}
+	}
+	h.PIE = f.Type == elf.ET_DYN && (hasInterp || flags1&uint64(elf.DF_1_PIE) != 0)
+	h.ASLR = h.PIE
+
+	names := make([]string, 0, len(imported))
+	for _, sym := range imported {
+		names = append(names, sym.Name)
+	}
+	// Static binaries keep the references in the regular symbol table
+	if symbols, err := f.Symbols(); err == nil {
+		for _, sym := range symbols {
+			names = append(names, sym.Name)
+		}
+	}
+	for _, name := range names {
+		switch {
+		case name == "__stack_chk_fail" || name == "__stack_chk_guard":
+			h.StackCanary = true
+		case strings.HasPrefix(name, "__") && strings.HasSuffix(name, "_chk"):
+			h.Fortified = true
+		}
+	}
+
+	return h
+}
+
+func analyzePEHeaders(file *os.File, info ExecutableInfo) (ExecutableInfo, error) {
+	f, err := pe.NewFile(file)
+	if err != nil {
+		return info, fmt.Errorf("malformed PE file: %w", err)
+	}
+
+	info.Architecture = peArchitectures[f.Machine]
+	if info.Architecture == "" {
+		info.Architecture = fmt.Sprintf("unknown(0x%x)", f.Machine)
+	}
+	info.Timestamp = f.TimeDateStamp
+
+	var dirs []pe.DataDirectory
+	var dllCharacteristics uint16
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		info.Bits = 32
+		info.Entrypoint = uint64(oh.AddressOfEntryPoint)
+		dirs = oh.DataDirectory[:min(int(oh.NumberOfRvaAndSizes), len(oh.DataDirectory))]
+		dllCharacteristics = oh.DllCharacteristics
+	case *pe.OptionalHeader64:
+		info.Bits = 64
+		info.Entrypoint = uint64(oh.AddressOfEntryPoint)
+		dirs = oh.DataDirectory[:min(int(oh.NumberOfRvaAndSizes), len(oh.DataDirectory))]
+		dllCharacteristics = oh.DllCharacteristics
+	}
+	directory := func(i int) pe.DataDirectory {
+		if i < len(dirs) {
+			return dirs[i]
+		}
+		return pe.DataDirectory{}
+	}
+
+	for _, s := range f.Sections {
+		section := Section{
+			Name:         s.Name,
+			VirtualAddr:  uint64(s.VirtualAddress),
+			VirtualSize:  uint64(s.VirtualSize),
+			RawSize:      s.Size,
+			IsExecutable: s.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0,
+			IsWritable:   s.Characteristics&pe.IMAGE_SCN_MEM_WRITE != 0,
+			IsReadable:   s.Characteristics&pe.IMAGE_SCN_MEM_READ != 0,
+			Entropy:      sectionEntropy(s.Open()),
+		}
+		section.Permissions = sectionPermissions(section)
+		info.Sections = append(info.Sections, section)
+
+		if strings.HasPrefix(s.Name, ".debug_") {
+			info.HasDebugInfo = true
+		}
+	}
+
+	// debug/pe reports imports as "function:library"
+	imported, _ := f.ImportedSymbols()
+	seen := make(map[string]bool)
+	for _, sym := range imported {
+		function, library, _ := strings.Cut(sym, ":")
+		info.Imports = append(info.Imports, ExecutableImport{Library: library, Function: function})
+		if library != "" && !seen[strings.ToLower(library)] {
+			seen[strings.ToLower(library)] = true
+			info.Libraries = append(info.Libraries, library)
+		}
+	}
+
+	info.Exports = append(info.Exports, peExports(f, directory(pe.IMAGE_DIRECTORY_ENTRY_EXPORT))...)
+	if directory(pe.IMAGE_DIRECTORY_ENTRY_DEBUG).Size > 0 {
+		info.HasDebugInfo = true
+	}
+	if hasRichHeader(file) {
+		info.Compiler = "MSVC"
+	}
+
+	h := &info.Hardening
+	h.ASLR = dllCharacteristics&pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE != 0
+	h.PIE = h.ASLR && f.Characteristics&pe.IMAGE_FILE_RELOCS_STRIPPED == 0
+	h.NX = dllCharacteristics&pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT != 0
+	h.CFG = dllCharacteristics&pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF != 0
+	h.CodeSigned = directory(pe.IMAGE_DIRECTORY_ENTRY_SECURITY).Size > 0
+	h.StackCanary = peSecurityCookie(f, directory(pe.IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG), info.Bits) != 0
+
+	// Check for packing indicators
+	info.IsPacked = checkPEPacking(info.Sections)
+
+	return info, nil
+}
+
+// peData returns up to size bytes of the image at rva, clipped to the
+// section that contains it
+func peData(f *pe.File, rva uint32, size uint64) []byte {
+	for _, s := range f.Sections {
+		span := s.VirtualSize
+		if s.Size > span {
+			span = s.Size
+		}
+		if rva < s.VirtualAddress || rva-s.VirtualAddress >= span {
+			continue
+		}
+		data, err := s.Data()
+		off := uint64(rva - s.VirtualAddress)
+		if err != nil || off >= uint64(len(data)) {
+			return nil
+		}
+		end := off + size
+		if end > uint64(len(data)) {
+			end = uint64(len(data))
+		}
+		return data[off:end]
+	}
+	return nil
+}
+
+// peExports walks the export directory, including ordinal-only exports
+func peExports(f *pe.File, dir pe.DataDirectory) []Export {
+	if dir.Size == 0 {
+		return nil
+	}
+	d := peData(f, dir.VirtualAddress, 40)
+	if len(d) < 40 {
+		return nil
+	}
+	base := binary.LittleEndian.Uint32(d[16:])
+	numFunctions := uint64(binary.LittleEndian.Uint32(d[20:]))
+	numNames := uint64(binary.LittleEndian.Uint32(d[24:]))
+	functions := peData(f, binary.LittleEndian.Uint32(d[28:]), numFunctions*4)
+	names := peData(f, binary.LittleEndian.Uint32(d[32:]), numNames*4)
+	ordinals := peData(f, binary.LittleEndian.Uint32(d[36:]), numNames*2)
+
+	var exports []Export
+	named := make(map[uint32]bool)
+	for i := 0; 4*i+4 <= len(names) && 2*i+2 <= len(ordinals); i++ {
+		index := uint32(binary.LittleEndian.Uint16(ordinals[2*i:]))
+		if 4*int(index)+4 > len(functions) {
+			continue
+		}
+		name := peData(f, binary.LittleEndian.Uint32(names[4*i:]), 512)
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+		named[index] = true
+		exports = append(exports, Export{
+			Name:    string(name),
+			Address: uint64(binary.LittleEndian.Uint32(functions[4*index:])),
+			Ordinal: uint16(base + index),
+		})
+	}
+	for index := uint32(0); 4*int(index)+4 <= len(functions); index++ {
+		addr := binary.LittleEndian.Uint32(functions[4*index:])
+		if addr != 0 && !named[index] {
+			exports = append(exports, Export{Address: uint64(addr), Ordinal: uint16(base + index)})
+		}
+	}
+	return exports
+}
+
+// peSecurityCookie reads the /GS cookie address from the load config directory
+func peSecurityCookie(f *pe.File, dir pe.DataDirectory, bits int) uint64 {
+	if dir.Size == 0 {
+		return 0
+	}
+	d := peData(f, dir.VirtualAddress, uint64(dir.Size))
+	if bits == 64 {
+		if len(d) < 0x60 {
+			return 0
+		}
+		return binary.LittleEndian.Uint64(d[0x58:])
+	}
+	if len(d) < 0x40 {
+		return 0
+	}
+	return uint64(binary.LittleEndian.Uint32(d[0x3c:]))
+}
+
+// hasRichHeader looks for the Microsoft linker's "Rich" signature
+// between the DOS stub and the PE header
+func hasRichHeader(file *os.File) bool {
+	dos := make([]byte, 64)
+	if _, err := file.ReadAt(dos, 0); err != nil {
+		return false
+	}
+	peOffset := int64(binary.LittleEndian.Uint32(dos[60:]))
+	if peOffset <= 64 || peOffset > 4096 {
+		return false
+	}
+	stub := make([]byte, peOffset-64)
+	if _, err := file.ReadAt(stub, 64); err != nil {
+		return false
+	}
+	return bytes.Contains(stub, []byte("Rich"))
+}
+
+func analyzeMachOHeaders(file *os.File, info ExecutableInfo) (ExecutableInfo, error) {
+	magic := make([]byte, 4)
+	if _, err := file.ReadAt(magic, 0); err != nil {
+		return info, err
+	}
+	if binary.BigEndian.Uint32(magic) != macho.MagicFat {
+		f, err := macho.NewFile(file)
+		if err != nil {
+			return info, fmt.Errorf("malformed Mach-O file: %w", err)
+		}
+		return describeMachO(f, info), nil
+	}
+
+	fat, err := macho.NewFatFile(file)
+	if err != nil {
+		return info, fmt.Errorf("malformed Mach-O file: %w", err)
+	}
+	var slices []ExecutableInfo
+	var arches []string
+	for _, arch := range fat.Arches {
+		slice := describeMachO(arch.File, ExecutableInfo{Format: info.Format})
+		slices = append(slices, slice)
+		arches = append(arches, slice.Architecture)
+	}
+
+	// A universal binary reports its first slice, with hardening
+	// that only counts when every slice has it
+	info = slices[0]
+	info.Architecture = strings.Join(arches, ",")
+	info.Slices = slices
+	for _, slice := range slices[1:] {
+		info.Hardening.PIE = info.Hardening.PIE && slice.Hardening.PIE
+		info.Hardening.NX = info.Hardening.NX && slice.Hardening.NX
+		info.Hardening.ASLR = info.Hardening.ASLR && slice.Hardening.ASLR
+		info.Hardening.StackCanary = info.Hardening.StackCanary && slice.Hardening.StackCanary
+		info.Hardening.CodeSigned = info.Hardening.CodeSigned && slice.Hardening.CodeSigned
+	}
+	return info, nil
+}
+
+func describeMachO(f *macho.File, info ExecutableInfo) ExecutableInfo {
+	info.Architecture = machoArchitectures[f.Cpu]
+	if info.Architecture == "" {
+		info.Architecture = strings.ToLower(strings.TrimPrefix(f.Cpu.String(), "Cpu"))
+	}
+	info.Bits = 32
+	if f.Magic == macho.Magic64 {
+		info.Bits = 64
+	}
+
+	protections := make(map[string]uint32)
+	var entryOffset, threadPC uint64
+	for _, load := range f.Loads {
+		if seg, ok := load.(*macho.Segment); ok {
+			protections[seg.Name] = seg.Prot
+		}
+		raw := load.Raw()
+		if len(raw) < 8 {
+			continue
+		}
+		switch f.ByteOrder.Uint32(raw) {
+		case machoLoadDylib, machoLoadWeakDylib, machoReexportDylib, machoLazyLoadDylib, machoLoadUpwardDylib:
+			info.Libraries = append(info.Libraries, machoDylibName(f.ByteOrder, raw))
+		case machoCodeSignature:
+			info.Hardening.CodeSigned = true
+		case machoMain:
+			if len(raw) >= 16 {
+				entryOffset = f.ByteOrder.Uint64(raw[8:])
+			}
+		case machoUnixThread:
+			if reg, ok := machoThreadPC[f.Cpu]; ok && len(raw) >= 16+8*reg+8 {
+				threadPC = f.ByteOrder.Uint64(raw[16+8*reg:])
+			}
+		}
+	}
+	info.Entrypoint = threadPC
+	if text := f.Segment("__TEXT"); text != nil && entryOffset > 0 {
+		info.Entrypoint = text.Addr + entryOffset
+	}
+
+	for _, s := range f.Sections {
+		prot := protections[s.Seg]
+		section := Section{
+			Name:         s.Seg + "," + s.Name,
+			VirtualAddr:  s.Addr,
+			VirtualSize:  s.Size,
+			IsExecutable: prot&4 != 0,
+			IsWritable:   prot&2 != 0,
+			IsReadable:   prot&1 != 0,
+		}
+		// S_ZEROFILL, S_GB_ZEROFILL and S_THREAD_LOCAL_ZEROFILL take no file space
+		if kind := s.Flags & 0xff; kind != 0x1 && kind != 0xc && kind != 0x12 {
+			section.RawSize = uint32(s.Size)
+			section.Entropy = sectionEntropy(s.Open())
+		}
+		section.Permissions = sectionPermissions(section)
+		info.Sections = append(info.Sections, section)
+
+		if s.Seg == "__DWARF" {
+			info.HasDebugInfo = true
+		}
+		if s.Name == "__go_buildinfo" {
+			info.Compiler = "Go"
+		}
+	}
+
+	if f.Symtab != nil && f.Dysymtab != nil {
+		symbols := f.Symtab.Syms
+		for i := uint32(0); i < f.Dysymtab.Nundefsym; i++ {
+			idx := int(f.Dysymtab.Iundefsym + i)
+			if idx >= len(symbols) {
+				break
+			}
+			sym := symbols[idx]
+			imp := ExecutableImport{Function: sym.Name}
+			// Two-level namespace: the high byte of n_desc is a 1-based dylib ordinal
+			if ordinal := int(sym.Desc >> 8); ordinal >= 1 && ordinal <= len(info.Libraries) {
+				imp.Library = info.Libraries[ordinal-1]
+			}
+			info.Imports = append(info.Imports, imp)
+
+			if sym.Name == "___stack_chk_fail" || sym.Name == "___stack_chk_guard" {
+				info.Hardening.StackCanary = true
+			}
+		}
+		for i := uint32(0); i < f.Dysymtab.Nextdefsym; i++ {
+			idx := int(f.Dysymtab.Iextdefsym + i)
+			if idx >= len(symbols) {
+				break
+			}
+			info.Exports = append(info.Exports, Export{Name: symbols[idx].Name, Address: symbols[idx].Value})
+		}
+	}
+
+	info.Hardening.PIE = f.Flags&macho.FlagPIE != 0
+	info.Hardening.ASLR = info.Hardening.PIE
+	info.Hardening.NX = f.Flags&macho.FlagAllowStackExecution == 0
+
+	return info
+}
+
+// machoDylibName reads the install name from a dylib load command
+func machoDylibName(order binary.ByteOrder, raw []byte) string {
+	if len(raw) < 12 {
+		return ""
+	}
+	off := order.Uint32(raw[8:])
+	if off >= uint32(len(raw)) {
+		return ""
+	}
+	name := raw[off:]
+	if end := bytes.IndexByte(name, 0); end >= 0 {
+		name = name[:end]
+	}
+	return string(name)
+}
@@ -0,0 +1,399 @@
+package textlib
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// putStruct writes v into b at off in little-endian order
+func putStruct(b []byte, off int, v interface{}) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, v)
+	copy(b[off:], buf.Bytes())
+}
+
+// testStrtab builds a NUL-separated string table and the offset of each name
+func testStrtab(names ...string) ([]byte, map[string]uint32) {
+	table := []byte{0}
+	offsets := make(map[string]uint32)
+	for _, name := range names {
+		offsets[name] = uint32(len(table))
+		table = append(append(table, name...), 0)
+	}
+	return table, offsets
+}
+
+// testELF builds a dynamically linked x86-64 executable that imports
+// __stack_chk_fail and __printf_chk from libc and exports "run"
+func testELF(hardened bool) []byte {
+	const (
+		phOff, interpOff, dynstrOff, dynsymOff = 0x40, 0x120, 0x140, 0x180
+		dynamicOff, textOff, shstrOff, shOff   = 0x1e0, 0x210, 0x230, 0x270
+	)
+	out := make([]byte, shOff+7*64)
+	dynstr, dyn := testStrtab("libc.so.6", "__stack_chk_fail", "__printf_chk", "run")
+	shstr, sh := testStrtab(".interp", ".dynstr", ".dynsym", ".dynamic", ".text", ".shstrtab")
+
+	header := elf.Header64{
+		Type: uint16(elf.ET_EXEC), Machine: uint16(elf.EM_X86_64), Version: 1, Entry: textOff,
+		Phoff: phOff, Shoff: shOff, Ehsize: 64, Phentsize: 56, Phnum: 4, Shentsize: 64, Shnum: 7, Shstrndx: 6,
+	}
+	copy(header.Ident[:], "\x7fELF\x02\x01\x01")
+	stack, relro := elf.PF_R|elf.PF_W|elf.PF_X, elf.PT_NULL
+	var flags1 uint64
+	if hardened {
+		header.Type = uint16(elf.ET_DYN)
+		stack, relro = elf.PF_R|elf.PF_W, elf.PT_GNU_RELRO
+		flags1 = uint64(elf.DF_1_NOW | elf.DF_1_PIE)
+	}
+	putStruct(out, 0, header)
+	putStruct(out, phOff, []elf.Prog64{
+		{Type: uint32(elf.PT_INTERP), Flags: uint32(elf.PF_R), Off: interpOff, Vaddr: interpOff, Filesz: 28, Memsz: 28},
+		{Type: uint32(elf.PT_GNU_STACK), Flags: uint32(stack)},
+		{Type: uint32(relro), Flags: uint32(elf.PF_R), Off: dynamicOff, Vaddr: dynamicOff, Filesz: 48, Memsz: 48},
+		{Type: uint32(elf.PT_DYNAMIC), Flags: uint32(elf.PF_R | elf.PF_W), Off: dynamicOff, Vaddr: dynamicOff, Filesz: 48, Memsz: 48},
+	})
+	copy(out[interpOff:], "/lib64/ld-linux-x86-64.so.2")
+	copy(out[dynstrOff:], dynstr)
+	global := func(typ elf.SymType) uint8 { return elf.ST_INFO(elf.STB_GLOBAL, typ) }
+	putStruct(out, dynsymOff, []elf.Sym64{
+		{},
+		{Name: dyn["__stack_chk_fail"], Info: global(elf.STT_FUNC)},
+		{Name: dyn["__printf_chk"], Info: global(elf.STT_FUNC)},
+		{Name: dyn["run"], Info: global(elf.STT_FUNC), Shndx: 5, Value: textOff, Size: 16},
+	})
+	putStruct(out, dynamicOff, []elf.Dyn64{
+		{Tag: int64(elf.DT_NEEDED), Val: uint64(dyn["libc.so.6"])},
+		{Tag: int64(elf.DT_FLAGS_1), Val: flags1},
+		{Tag: int64(elf.DT_NULL)},
+	})
+	for i := 0; i < 32; i++ {
+		out[textOff+i] = byte(i)
+	}
+	copy(out[shstrOff:], shstr)
+
+	alloc := uint64(elf.SHF_ALLOC)
+	putStruct(out, shOff, []elf.Section64{
+		{},
+		{Name: sh[".interp"], Type: uint32(elf.SHT_PROGBITS), Flags: alloc, Addr: interpOff, Off: interpOff, Size: 28},
+		{Name: sh[".dynstr"], Type: uint32(elf.SHT_STRTAB), Flags: alloc, Addr: dynstrOff, Off: dynstrOff, Size: uint64(len(dynstr))},
+		{Name: sh[".dynsym"], Type: uint32(elf.SHT_DYNSYM), Flags: alloc, Addr: dynsymOff, Off: dynsymOff, Size: 4 * 24, Link: 2, Info: 1, Entsize: 24},
+		{Name: sh[".dynamic"], Type: uint32(elf.SHT_DYNAMIC), Flags: alloc | uint64(elf.SHF_WRITE), Addr: dynamicOff, Off: dynamicOff, Size: 48, Link: 2, Entsize: 16},
+		{Name: sh[".text"], Type: uint32(elf.SHT_PROGBITS), Flags: alloc | uint64(elf.SHF_EXECINSTR), Addr: textOff, Off: textOff, Size: 32},
+		{Name: sh[".shstrtab"], Type: uint32(elf.SHT_STRTAB), Off: shstrOff, Size: uint64(len(shstr))},
+	})
+	return out
+}
+
+// testPE builds a signed 64-bit DLL with an MSVC Rich header, one named
+// and one ordinal-only export, an import from KERNEL32 and a /GS cookie
+func testPE() []byte {
+	const peOff, rdata = 0x80, 0x2000
+	out := make([]byte, 0x610)
+	copy(out, "MZ")
+	binary.LittleEndian.PutUint32(out[60:], peOff)
+	copy(out[0x40:], "\x12\x34\x56\x78Rich\x00\x00\x00\x00")
+	copy(out[peOff:], "PE\x00\x00")
+	putStruct(out, peOff+4, pe.FileHeader{
+		Machine: pe.IMAGE_FILE_MACHINE_AMD64, NumberOfSections: 2, TimeDateStamp: 1700000000,
+		SizeOfOptionalHeader: 240, Characteristics: pe.IMAGE_FILE_EXECUTABLE_IMAGE | pe.IMAGE_FILE_DLL,
+	})
+	oh := pe.OptionalHeader64{
+		Magic: 0x20b, AddressOfEntryPoint: 0x1000, ImageBase: 0x180000000, SectionAlignment: 0x1000,
+		FileAlignment: 0x200, SizeOfImage: 0x3000, SizeOfHeaders: 0x200, NumberOfRvaAndSizes: 16,
+		DllCharacteristics: pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE | pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT |
+			pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF,
+	}
+	oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT] = pe.DataDirectory{VirtualAddress: rdata, Size: 0x70}
+	oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_IMPORT] = pe.DataDirectory{VirtualAddress: rdata + 0x80, Size: 40}
+	oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY] = pe.DataDirectory{VirtualAddress: 0x600, Size: 16}
+	oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG] = pe.DataDirectory{VirtualAddress: rdata + 0x100, Size: 0x70}
+	putStruct(out, peOff+24, oh)
+
+	sections := []pe.SectionHeader32{
+		{VirtualSize: 0x20, VirtualAddress: 0x1000, SizeOfRawData: 0x200, PointerToRawData: 0x200,
+			Characteristics: pe.IMAGE_SCN_CNT_CODE | pe.IMAGE_SCN_MEM_EXECUTE | pe.IMAGE_SCN_MEM_READ},
+		{VirtualSize: 0x180, VirtualAddress: rdata, SizeOfRawData: 0x200, PointerToRawData: 0x400,
+			Characteristics: pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ},
+	}
+	copy(sections[0].Name[:], ".text")
+	copy(sections[1].Name[:], ".rdata")
+	putStruct(out, peOff+24+240, sections)
+	for i := 0; i < 0x200; i++ {
+		out[0x200+i] = byte(i * 7)
+	}
+
+	// .rdata: export directory, import descriptors, then the load config
+	r := out[0x400:]
+	le32 := func(off, v uint32) { binary.LittleEndian.PutUint32(r[off:], v) }
+	le32(16, 1)                    // ordinal base
+	le32(20, 2)                    // functions
+	le32(24, 1)                    // names
+	le32(28, rdata+0x40)           // function table
+	le32(32, rdata+0x50)           // name table
+	le32(36, rdata+0x58)           // ordinal table
+	le32(0x40, 0x1010)             // DoWork
+	le32(0x44, 0x1018)             // exported by ordinal only
+	le32(0x50, rdata+0x60)         // "DoWork"
+	copy(r[0x60:], "DoWork")       // ordinal table entry 0 is already zero
+	le32(0x80, rdata+0xc0)         // OriginalFirstThunk
+	le32(0x8c, rdata+0xe0)         // Name
+	le32(0x90, rdata+0xd0)         // FirstThunk
+	le32(0xc0, rdata+0xf0)         // hint/name for ExitProcess
+	le32(0xd0, rdata+0xf0)         // bound the same way
+	copy(r[0xe0:], "KERNEL32.dll") // library name
+	copy(r[0xf2:], "ExitProcess")  // after the 2-byte hint
+	le32(0x100, 0x70)              // load config size
+	le32(0x158, 0x3000)            // SecurityCookie, low half
+	binary.LittleEndian.PutUint32(r[0x15c:], 0x1)
+	return out
+}
+
+// testMachO builds a two-level namespace PIE executable that imports
+// ___stack_chk_fail from libSystem and carries a code signature
+func testMachO(cpu macho.Cpu) []byte {
+	const (
+		textOff, symOff, strOff, sigOff = 0x200, 0x210, 0x230, 0x260
+		base                            = 0x100000000
+	)
+	out := make([]byte, 0x270)
+	strtab, str := testStrtab("_main", "___stack_chk_fail")
+	dylib := make([]byte, 32)
+	copy(dylib, "/usr/lib/libSystem.B.dylib")
+
+	cmds := 5
+	var load bytes.Buffer
+	put := func(v interface{}) { binary.Write(&load, binary.LittleEndian, v) }
+	segment := macho.Segment64{Cmd: macho.LoadCmdSegment64, Len: 72 + 80, Addr: base, Memsz: 0x1000,
+		Filesz: uint64(len(out)), Maxprot: 5, Prot: 5, Nsect: 1}
+	copy(segment.Name[:], "__TEXT")
+	section := macho.Section64{Addr: base + textOff, Size: 16, Offset: textOff, Flags: 0x80000400}
+	copy(section.Name[:], "__text")
+	copy(section.Seg[:], "__TEXT")
+	put(segment)
+	put(section)
+	put(macho.SymtabCmd{Cmd: macho.LoadCmdSymtab, Len: 24, Symoff: symOff, Nsyms: 2, Stroff: strOff, Strsize: uint32(len(strtab))})
+	put(macho.DysymtabCmd{Cmd: macho.LoadCmdDysymtab, Len: 80, Iextdefsym: 0, Nextdefsym: 1, Iundefsym: 1, Nundefsym: 1})
+	put(macho.DylibCmd{Cmd: macho.LoadCmdDylib, Len: 24 + 32, Name: 24})
+	put(dylib)
+	put([]uint32{machoMain, 24})
+	put([]uint64{textOff, 0})
+	if cpu == macho.CpuArm64 {
+		put([]uint32{machoCodeSignature, 16, sigOff, 16})
+		cmds++
+	}
+
+	putStruct(out, 0, macho.FileHeader{
+		Magic: macho.Magic64, Cpu: cpu, Type: macho.TypeExec, Ncmd: uint32(cmds), Cmdsz: uint32(load.Len()),
+		Flags: macho.FlagNoUndefs | macho.FlagDyldLink | macho.FlagTwoLevel | macho.FlagPIE,
+	})
+	copy(out[32:], load.Bytes())
+	for i := 0; i < 16; i++ {
+		out[textOff+i] = byte(0xa0 + i)
+	}
+	putStruct(out, symOff, []macho.Nlist64{
+		{Name: str["_main"], Type: 0x0f, Sect: 1, Value: base + textOff},
+		{Name: str["___stack_chk_fail"], Type: 0x01, Desc: 0x0100},
+	})
+	copy(out[strOff:], strtab)
+	return out
+}
+
+// testFatMachO wraps an x86-64 and an arm64 slice in a universal binary
+func testFatMachO() []byte {
+	out := make([]byte, 0x3000)
+	binary.BigEndian.PutUint32(out, macho.MagicFat)
+	binary.BigEndian.PutUint32(out[4:], 2)
+	for i, cpu := range []macho.Cpu{macho.CpuAmd64, macho.CpuArm64} {
+		slice := testMachO(cpu)
+		off := 0x1000 * (i + 1)
+		entry := out[8+20*i:]
+		binary.BigEndian.PutUint32(entry, uint32(cpu))
+		binary.BigEndian.PutUint32(entry[8:], uint32(off))
+		binary.BigEndian.PutUint32(entry[12:], uint32(len(slice)))
+		binary.BigEndian.PutUint32(entry[16:], 12)
+		copy(out[off:], slice)
+	}
+	return out
+}
+
+func TestAnalyzeExecutableHeadersELF(t *testing.T) {
+	tests := []struct {
+		name      string
+		hardened  bool
+		hardening ExecutableHardening
+	}{
+		{"hardened", true, ExecutableHardening{PIE: true, NX: true, ASLR: true, RELRO: "full", StackCanary: true, Fortified: true}},
+		{"plain", false, ExecutableHardening{RELRO: "none", StackCanary: true, Fortified: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := AnalyzeExecutableHeaders(writeTestAudio(t, "app", testELF(tt.hardened)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Format != "ELF" || info.Architecture != "x86_64" || info.Bits != 64 || info.Entrypoint != 0x210 {
+				t.Errorf("header: got %s %s %d-bit entry 0x%x", info.Format, info.Architecture, info.Bits, info.Entrypoint)
+			}
+			if info.Hardening != tt.hardening {
+				t.Errorf("hardening\ngot  %+v\nwant %+v", info.Hardening, tt.hardening)
+			}
+			if !reflect.DeepEqual(info.Libraries, []string{"libc.so.6"}) {
+				t.Errorf("libraries: got %v", info.Libraries)
+			}
+			wantImports := []ExecutableImport{{Function: "__stack_chk_fail"}, {Function: "__printf_chk"}}
+			if !reflect.DeepEqual(info.Imports, wantImports) {
+				t.Errorf("imports: got %+v", info.Imports)
+			}
+			if !reflect.DeepEqual(info.Exports, []Export{{Name: "run", Address: 0x210}}) {
+				t.Errorf("exports: got %+v", info.Exports)
+			}
+			if len(info.Sections) != 6 {
+				t.Fatalf("expected 6 sections, got %+v", info.Sections)
+			}
+			text := info.Sections[4]
+			if text.Name != ".text" || text.Permissions != "r-x" || text.RawSize != 32 || text.Entropy != 5 {
+				t.Errorf(".text: got %+v", text)
+			}
+		})
+	}
+}
+
+func TestAnalyzeExecutableHeadersPE(t *testing.T) {
+	info, err := AnalyzeExecutableHeaders(writeTestAudio(t, "lib.dll", testPE()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "PE" || info.Architecture != "x86_64" || info.Bits != 64 || info.Entrypoint != 0x1000 ||
+		info.Timestamp != 1700000000 || info.Compiler != "MSVC" {
+		t.Errorf("header: got %+v", info)
+	}
+	want := ExecutableHardening{PIE: true, NX: true, ASLR: true, StackCanary: true, CFG: true, CodeSigned: true}
+	if info.Hardening != want {
+		t.Errorf("hardening\ngot  %+v\nwant %+v", info.Hardening, want)
+	}
+	if !reflect.DeepEqual(info.Libraries, []string{"KERNEL32.dll"}) ||
+		!reflect.DeepEqual(info.Imports, []ExecutableImport{{Library: "KERNEL32.dll", Function: "ExitProcess"}}) {
+		t.Errorf("imports: got %v %+v", info.Libraries, info.Imports)
+	}
+	wantExports := []Export{{Name: "DoWork", Address: 0x1010, Ordinal: 1}, {Address: 0x1018, Ordinal: 2}}
+	if !reflect.DeepEqual(info.Exports, wantExports) {
+		t.Errorf("exports: got %+v", info.Exports)
+	}
+	if len(info.Sections) != 2 || info.Sections[0].Permissions != "r-x" || info.Sections[1].Permissions != "r--" ||
+		info.Sections[0].VirtualSize != 0x20 {
+		t.Errorf("sections: got %+v", info.Sections)
+	}
+	if info.IsPacked != true {
+		t.Error("Expected a two-section image to look packed")
+	}
+}
+
+func TestAnalyzeExecutableHeadersMachO(t *testing.T) {
+	info, err := AnalyzeExecutableHeaders(writeTestAudio(t, "app", testMachO(macho.CpuArm64)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "Mach-O" || info.Architecture != "arm64" || info.Bits != 64 || info.Entrypoint != 0x100000200 {
+		t.Errorf("header: got %s %s %d-bit entry 0x%x", info.Format, info.Architecture, info.Bits, info.Entrypoint)
+	}
+	want := ExecutableHardening{PIE: true, NX: true, ASLR: true, StackCanary: true, CodeSigned: true}
+	if info.Hardening != want {
+		t.Errorf("hardening\ngot  %+v\nwant %+v", info.Hardening, want)
+	}
+	lib := "/usr/lib/libSystem.B.dylib"
+	if !reflect.DeepEqual(info.Libraries, []string{lib}) ||
+		!reflect.DeepEqual(info.Imports, []ExecutableImport{{Library: lib, Function: "___stack_chk_fail"}}) {
+		t.Errorf("imports: got %v %+v", info.Libraries, info.Imports)
+	}
+	if !reflect.DeepEqual(info.Exports, []Export{{Name: "_main", Address: 0x100000200}}) {
+		t.Errorf("exports: got %+v", info.Exports)
+	}
+	if len(info.Sections) != 1 || info.Sections[0].Name != "__TEXT,__text" || info.Sections[0].Permissions != "r-x" ||
+		info.Sections[0].Entropy != 4 {
+		t.Errorf("sections: got %+v", info.Sections)
+	}
+
+	fat, err := AnalyzeExecutableHeaders(writeTestAudio(t, "universal", testFatMachO()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fat.Architecture != "x86_64,arm64" || len(fat.Slices) != 2 || fat.Slices[1].Architecture != "arm64" {
+		t.Errorf("fat: got %s with %d slices", fat.Architecture, len(fat.Slices))
+	}
+	// Only the arm64 slice is signed
+	if fat.Hardening.CodeSigned || !fat.Slices[1].Hardening.CodeSigned || !fat.Hardening.PIE {
+		t.Errorf("fat hardening: got %+v", fat.Hardening)
+	}
+}
+
+func TestAnalyzeExecutableHeadersMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated ELF", testELF(true)[:100]},
+		{"truncated PE", testPE()[:0x100]},
+		{"truncated Mach-O", testMachO(macho.CpuArm64)[:40]},
+		{"Java class", []byte("\xca\xfe\xba\xbe\x00\x00\x00\x34 class file body")},
+	}
+	for _, tt := range tests {
+		if _, err := AnalyzeExecutableHeaders(writeTestAudio(t, "bad", tt.data)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestSectionEntropy(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want float64
+	}{
+		{nil, 0},
+		{bytes.Repeat([]byte{'A'}, 1000), 0},
+		{[]byte("abab"), 1},
+		{func() []byte {
+			b := make([]byte, 256*4)
+			for i := range b {
+				b[i] = byte(i)
+			}
+			return b
+		}(), 8},
+	}
+	for _, tt := range tests {
+		if got := sectionEntropy(bytes.NewReader(tt.data)); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("entropy of %d bytes: got %v, want %v", len(tt.data), got, tt.want)
+		}
+	}
+}
+
+func TestCheckPEPacking(t *testing.T) {
+	normal := []Section{
+		{Name: ".text", VirtualSize: 0x1000, RawSize: 0x1000, IsExecutable: true, Entropy: 6.1},
+		{Name: ".rdata", VirtualSize: 0x800, RawSize: 0x800, Entropy: 5},
+		{Name: ".data", VirtualSize: 0x50000, RawSize: 0x200, Entropy: 2},
+	}
+	tests := []struct {
+		name     string
+		sections []Section
+		want     bool
+	}{
+		{"normal", normal, false},
+		{"packer section name", append([]Section{{Name: "UPX1"}}, normal...), true},
+		{"mixed-case packer name", append([]Section{{Name: ".aspack"}}, normal...), true},
+		{"inflating code", append([]Section{{Name: ".code", VirtualSize: 0x20000, RawSize: 0x200, IsExecutable: true}}, normal...), true},
+		{"high-entropy code", append([]Section{{Name: ".code", VirtualSize: 0x200, RawSize: 0x200, IsExecutable: true, Entropy: 7.8}}, normal...), true},
+		{"too few sections", normal[:1], true},
+	}
+	for _, tt := range tests {
+		if got := checkPEPacking(tt.sections); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
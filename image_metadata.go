@@ -0,0 +1,942 @@
+package textlib
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exifEntry is one TIFF directory entry with its value bytes
+type exifEntry struct {
+	typ    uint16
+	count  uint32
+	offset int64 // where the value bytes start, for in-place stripping
+	value  []byte
+}
+
+// exifIFD maps tag IDs to entries
+type exifIFD map[uint16]exifEntry
+
+// tiffMetadata holds the directories of a TIFF file or an EXIF block
+type tiffMetadata struct {
+	order binary.ByteOrder
+	ifds  []exifIFD // main directory chain, IFD0 first
+	exif  exifIFD
+	gps   exifIFD
+}
+
+const (
+	tagExifIFD     = 0x8769
+	tagGPSIFD      = 0x8825
+	tagXMP         = 0x02BC
+	tagIPTC        = 0x83BB
+	tagPhotoshop   = 0x8649
+	exifTimeLayout = "2006:01:02 15:04:05"
+	xmpJPEGPrefix  = "http://ns.adobe.com/xap/1.0/\x00"
+)
+
+// exifTypeSizes gives the byte size of each TIFF field type
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// Tag names reported in ImageMetadata.EXIF
+var (
+	exifIFD0Names = map[uint16]string{
+		0x0100: "ImageWidth", 0x0101: "ImageLength", 0x0102: "BitsPerSample", 0x0103: "Compression",
+		0x0106: "PhotometricInterpretation", 0x010E: "ImageDescription", 0x010F: "Make", 0x0110: "Model",
+		0x0112: "Orientation", 0x0115: "SamplesPerPixel", 0x011A: "XResolution", 0x011B: "YResolution",
+		0x0128: "ResolutionUnit", 0x0131: "Software", 0x0132: "DateTime", 0x013B: "Artist",
+		0x013C: "HostComputer", 0x8298: "Copyright",
+	}
+	exifSubIFDNames = map[uint16]string{
+		0x829A: "ExposureTime", 0x829D: "FNumber", 0x8822: "ExposureProgram", 0x8827: "ISOSpeedRatings",
+		0x9000: "ExifVersion", 0x9003: "DateTimeOriginal", 0x9004: "DateTimeDigitized", 0x9010: "OffsetTime",
+		0x9011: "OffsetTimeOriginal", 0x9204: "ExposureBiasValue", 0x9207: "MeteringMode", 0x9209: "Flash",
+		0x920A: "FocalLength", 0x9291: "SubSecTimeOriginal", 0xA001: "ColorSpace", 0xA002: "PixelXDimension",
+		0xA003: "PixelYDimension", 0xA402: "ExposureMode", 0xA403: "WhiteBalance", 0xA405: "FocalLengthIn35mmFilm",
+		0xA420: "ImageUniqueID", 0xA431: "BodySerialNumber", 0xA433: "LensMake", 0xA434: "LensModel",
+		0xA435: "LensSerialNumber",
+	}
+	exifGPSNames = map[uint16]string{
+		0x00: "GPSVersionID", 0x01: "GPSLatitudeRef", 0x02: "GPSLatitude", 0x03: "GPSLongitudeRef",
+		0x04: "GPSLongitude", 0x05: "GPSAltitudeRef", 0x06: "GPSAltitude", 0x07: "GPSTimeStamp",
+		0x12: "GPSMapDatum", 0x1D: "GPSDateStamp",
+	}
+	iptcNames = map[byte]string{
+		5: "ObjectName", 25: "Keywords", 55: "DateCreated", 80: "By-line", 90: "City",
+		95: "Province-State", 101: "Country", 105: "Headline", 110: "Credit", 115: "Source",
+		116: "CopyrightNotice", 120: "Caption-Abstract", 122: "Writer-Editor",
+	}
+)
+
+// parseTIFFMetadata reads the directory chain of a TIFF structure and its
+// EXIF and GPS sub-directories. Damaged entries are skipped.
+func parseTIFFMetadata(r io.ReaderAt, size int64) (*tiffMetadata, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("truncated TIFF header")
+	}
+	t := &tiffMetadata{}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid TIFF byte order")
+	}
+	if t.order.Uint16(header[2:4]) != 42 {
+		return nil, fmt.Errorf("invalid TIFF magic number")
+	}
+
+	visited := make(map[int64]bool)
+	off := int64(t.order.Uint32(header[4:8]))
+	for off != 0 && len(t.ifds) < 64 {
+		ifd, next, err := t.readIFD(r, size, off, visited)
+		if err != nil {
+			if len(t.ifds) == 0 {
+				return nil, err
+			}
+			break
+		}
+		t.ifds = append(t.ifds, ifd)
+		off = next
+	}
+	if len(t.ifds) == 0 {
+		return nil, fmt.Errorf("TIFF has no image directory")
+	}
+
+	if e, ok := t.ifds[0][tagExifIFD]; ok {
+		t.exif, _, _ = t.readIFD(r, size, int64(t.uint(e, 0)), visited)
+	}
+	if e, ok := t.ifds[0][tagGPSIFD]; ok {
+		t.gps, _, _ = t.readIFD(r, size, int64(t.uint(e, 0)), visited)
+	}
+	return t, nil
+}
+
+// readIFD reads the directory at off and returns the offset of the next one
+func (t *tiffMetadata) readIFD(r io.ReaderAt, size, off int64, visited map[int64]bool) (exifIFD, int64, error) {
+	if off < 8 || off+2 > size || visited[off] {
+		return nil, 0, fmt.Errorf("invalid IFD offset %d", off)
+	}
+	visited[off] = true
+	countBuf := make([]byte, 2)
+	if _, err := r.ReadAt(countBuf, off); err != nil {
+		return nil, 0, err
+	}
+	n := int64(t.order.Uint16(countBuf))
+	if off+2+12*n > size {
+		return nil, 0, fmt.Errorf("truncated IFD at offset %d", off)
+	}
+	entries := make([]byte, 12*n)
+	if _, err := r.ReadAt(entries, off+2); err != nil {
+		return nil, 0, err
+	}
+	var next int64
+	nextBuf := make([]byte, 4)
+	if _, err := r.ReadAt(nextBuf, off+2+12*n); err == nil {
+		next = int64(t.order.Uint32(nextBuf))
+	}
+
+	ifd := make(exifIFD, n)
+	for i := int64(0); i < n; i++ {
+		e := entries[12*i : 12*i+12]
+		tag, typ, count := t.order.Uint16(e[0:2]), t.order.Uint16(e[2:4]), t.order.Uint32(e[4:8])
+		unit := exifTypeSizes[typ]
+		total := int64(unit) * int64(count)
+		if unit == 0 || total > 16<<20 {
+			continue
+		}
+		valueOff := off + 2 + 12*i + 8
+		if total > 4 {
+			valueOff = int64(t.order.Uint32(e[8:12]))
+		}
+		if valueOff+total > size {
+			continue
+		}
+		value := make([]byte, total)
+		if _, err := r.ReadAt(value, valueOff); err != nil {
+			continue
+		}
+		ifd[tag] = exifEntry{typ: typ, count: count, offset: valueOff, value: value}
+	}
+	return ifd, next, nil
+}
+
+// uint returns the i-th BYTE, SHORT or LONG value of an entry
+func (t *tiffMetadata) uint(e exifEntry, i int) uint32 {
+	switch e.typ {
+	case 1, 7:
+		if i < len(e.value) {
+			return uint32(e.value[i])
+		}
+	case 3:
+		if 2*i+2 <= len(e.value) {
+			return uint32(t.order.Uint16(e.value[2*i:]))
+		}
+	case 4, 9:
+		if 4*i+4 <= len(e.value) {
+			return t.order.Uint32(e.value[4*i:])
+		}
+	}
+	return 0
+}
+
+// rational returns the i-th RATIONAL or SRATIONAL value as a float
+func (t *tiffMetadata) rational(e exifEntry, i int) (float64, bool) {
+	if (e.typ != 5 && e.typ != 10) || 8*i+8 > len(e.value) {
+		return 0, false
+	}
+	num, den := t.order.Uint32(e.value[8*i:]), t.order.Uint32(e.value[8*i+4:])
+	if den == 0 {
+		return 0, false
+	}
+	if e.typ == 10 {
+		return float64(int32(num)) / float64(int32(den)), true
+	}
+	return float64(num) / float64(den), true
+}
+
+// str returns an ASCII value up to its first NUL, without padding
+func (e exifEntry) str() string {
+	s := string(e.value)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// format renders an entry value for ImageMetadata.EXIF
+func (t *tiffMetadata) format(e exifEntry) string {
+	n := int(e.count)
+	if n > 16 {
+		n = 16
+	}
+	parts := make([]string, 0, n)
+	switch e.typ {
+	case 2:
+		return e.str()
+	case 5, 10:
+		for i := 0; i < n; i++ {
+			num, den := t.order.Uint32(e.value[8*i:]), t.order.Uint32(e.value[8*i+4:])
+			if e.typ == 10 {
+				parts = append(parts, formatRational(int64(int32(num)), int64(int32(den))))
+			} else {
+				parts = append(parts, formatRational(int64(num), int64(den)))
+			}
+		}
+	case 7:
+		if isPrintableASCII(e.value) {
+			return e.str()
+		}
+		return fmt.Sprintf("%x", e.value[:min(len(e.value), 32)])
+	default:
+		for i := 0; i < n; i++ {
+			parts = append(parts, strconv.FormatUint(uint64(t.uint(e, i)), 10))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatRational writes num/den in lowest terms, or a whole number
+func formatRational(num, den int64) string {
+	if den == 0 {
+		return "0"
+	}
+	a, b := num, den
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a > 1 {
+		num, den = num/a, den/a
+	}
+	if den == 1 {
+		return strconv.FormatInt(num, 10)
+	}
+	return fmt.Sprintf("%d/%d", num, den)
+}
+
+func isPrintableASCII(b []byte) bool {
+	b = bytes.TrimRight(b, "\x00")
+	for _, c := range b {
+		if c < 0x20 || c > 0x7E {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+// apply copies camera, time, GPS and orientation data into metadata.
+// EXIF times without an offset are read as UTC.
+func (t *tiffMetadata) apply(m *ImageMetadata) {
+	if m.EXIF == nil {
+		m.EXIF = make(map[string]string)
+	}
+	for _, dir := range []struct {
+		ifd   exifIFD
+		names map[uint16]string
+	}{{t.ifds[0], exifIFD0Names}, {t.exif, exifSubIFDNames}, {t.gps, exifGPSNames}} {
+		for tag, e := range dir.ifd {
+			if name, ok := dir.names[tag]; ok {
+				m.EXIF[name] = t.format(e)
+			}
+		}
+	}
+
+	ifd0 := t.ifds[0]
+	if e, ok := ifd0[0x0112]; ok {
+		if o := int(t.uint(e, 0)); o >= 1 && o <= 8 {
+			m.Orientation = o
+		}
+	}
+	m.Camera.Make = ifd0[0x010F].str()
+	m.Camera.Model = ifd0[0x0110].str()
+	if m.DPI == 0 {
+		if x, ok := t.rational(ifd0[0x011A], 0); ok {
+			unit := t.uint(ifd0[0x0128], 0)
+			if unit == 3 {
+				x *= 2.54
+			}
+			if unit != 1 {
+				m.DPI = int(math.Round(x))
+			}
+		}
+	}
+	if m.Width == 0 {
+		m.Width, m.Height = int(t.uint(ifd0[0x0100], 0)), int(t.uint(ifd0[0x0101], 0))
+	}
+
+	x := t.exif
+	if v, ok := t.rational(x[0x829A], 0); ok && v > 0 {
+		if v < 1 {
+			m.Camera.Shutter = fmt.Sprintf("1/%d", int(math.Round(1/v)))
+		} else {
+			m.Camera.Shutter = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	if v, ok := t.rational(x[0x829D], 0); ok {
+		m.Camera.Aperture = "f/" + strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+	}
+	if v, ok := t.rational(x[0x920A], 0); ok {
+		m.Camera.FocalLength = strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64) + " mm"
+	}
+	if e, ok := x[0x8827]; ok {
+		m.Camera.ISO = int(t.uint(e, 0))
+	}
+	if e, ok := x[0x9209]; ok {
+		m.Camera.Flash = t.uint(e, 0)&1 == 1
+	}
+	lensMake, lens := x[0xA433].str(), x[0xA434].str()
+	if lensMake != "" && lens != "" && !strings.HasPrefix(lens, lensMake) {
+		lens = lensMake + " " + lens
+	}
+	m.Camera.Lens = lens
+	switch t.uint(x[0xA001], 0) {
+	case 1:
+		m.ColorSpace = "sRGB"
+	case 0xFFFF:
+		m.ColorSpace = "Uncalibrated"
+	}
+	if m.Width == 0 {
+		m.Width, m.Height = int(t.uint(x[0xA002], 0)), int(t.uint(x[0xA003], 0))
+	}
+
+	for _, c := range []struct{ value, subsec, offset exifEntry }{
+		{x[0x9003], x[0x9291], x[0x9011]}, // DateTimeOriginal
+		{x[0x9004], x[0x9292], x[0x9012]}, // DateTimeDigitized
+		{ifd0[0x0132], x[0x9290], x[0x9010]},
+	} {
+		if created, ok := parseEXIFTime(c.value.str(), c.subsec.str(), c.offset.str()); ok {
+			m.Created = created
+			break
+		}
+	}
+
+	t.applyGPS(&m.Camera.GPS)
+}
+
+// parseEXIFTime combines an EXIF date, sub-second digits and an offset such as "+02:00"
+func parseEXIFTime(value, subsec, offset string) (time.Time, bool) {
+	t, err := time.Parse(exifTimeLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if subsec != "" {
+		if frac, err := strconv.ParseFloat("0."+subsec, 64); err == nil {
+			t = t.Add(time.Duration(frac * float64(time.Second)))
+		}
+	}
+	if off, err := time.Parse("-07:00", offset); err == nil {
+		_, secs := off.Zone()
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone("", secs))
+	}
+	return t, true
+}
+
+// applyGPS converts GPS degrees, minutes and seconds to decimal degrees
+func (t *tiffMetadata) applyGPS(gps *GPSInfo) {
+	g := t.gps
+	lat, latOK := t.gpsCoordinate(g[0x02], g[0x01].str())
+	lon, lonOK := t.gpsCoordinate(g[0x04], g[0x03].str())
+	if latOK && lonOK {
+		gps.Latitude, gps.Longitude, gps.HasGPS = lat, lon, true
+	}
+	if alt, ok := t.rational(g[0x06], 0); ok {
+		if t.uint(g[0x05], 0) == 1 {
+			alt = -alt
+		}
+		gps.Altitude = alt
+	}
+	if day, err := time.Parse("2006:01:02", g[0x1D].str()); err == nil {
+		h, _ := t.rational(g[0x07], 0)
+		mi, _ := t.rational(g[0x07], 1)
+		s, _ := t.rational(g[0x07], 2)
+		gps.Timestamp = day.Add(time.Duration((h*3600 + mi*60 + s) * float64(time.Second)))
+	}
+}
+
+func (t *tiffMetadata) gpsCoordinate(e exifEntry, ref string) (float64, bool) {
+	d, ok1 := t.rational(e, 0)
+	m, ok2 := t.rational(e, 1)
+	s, ok3 := t.rational(e, 2)
+	if !ok1 || !ok2 || !ok3 {
+		return 0, false
+	}
+	v := d + m/60 + s/3600
+	if ref == "S" || ref == "W" {
+		v = -v
+	}
+	return v, true
+}
+
+// applyEXIFBlock parses an EXIF block (a TIFF structure, optionally
+// preceded by "Exif\0\0") into metadata
+func applyEXIFBlock(data []byte, m *ImageMetadata) {
+	data = bytes.TrimPrefix(data, []byte("Exif\x00\x00"))
+	if t, err := parseTIFFMetadata(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.apply(m)
+	}
+}
+
+// parseIPTC reads IPTC-IIM application records (record 2) into metadata
+func parseIPTC(data []byte, m *ImageMetadata) {
+	for i := 0; i+5 <= len(data) && data[i] == 0x1C; {
+		record, dataset := data[i+1], data[i+2]
+		size := int(binary.BigEndian.Uint16(data[i+3:]))
+		if size&0x8000 != 0 || i+5+size > len(data) {
+			return
+		}
+		if name, ok := iptcNames[dataset]; ok && record == 2 {
+			if m.IPTC == nil {
+				m.IPTC = make(map[string][]string)
+			}
+			m.IPTC[name] = append(m.IPTC[name], strings.TrimSpace(string(data[i+5:i+5+size])))
+		}
+		i += 5 + size
+	}
+}
+
+// parsePhotoshopResources finds the IPTC block (resource 0x0404) in an
+// APP13 "Photoshop 3.0" segment
+func parsePhotoshopResources(data []byte, m *ImageMetadata) {
+	for i := 0; i+12 <= len(data) && string(data[i:i+4]) == "8BIM"; {
+		id := binary.BigEndian.Uint16(data[i+4:])
+		nameLen := int(data[i+6])
+		p := i + 6 + nameLen + 1
+		if p%2 == 1 {
+			p++
+		}
+		if p+4 > len(data) {
+			return
+		}
+		size := int(binary.BigEndian.Uint32(data[p:]))
+		p += 4
+		if size < 0 || p+size > len(data) {
+			return
+		}
+		if id == 0x0404 {
+			parseIPTC(data[p:p+size], m)
+		}
+		i = p + size + size%2
+	}
+}
+
+// readJPEGMetadata walks the markers before the first scan, reading size
+// from the SOF marker and metadata from the APP0, APP1 and APP13 segments.
+// Truncated files keep whatever was read before the damage.
+func readJPEGMetadata(r io.Reader, m *ImageMetadata) error {
+	br := bufio.NewReader(r)
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(br, soi); err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return fmt.Errorf("not a JPEG file")
+	}
+
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil
+		}
+		if b != 0xFF {
+			return fmt.Errorf("invalid JPEG marker")
+		}
+		marker := byte(0xFF)
+		for marker == 0xFF {
+			if marker, err = br.ReadByte(); err != nil {
+				return nil
+			}
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			continue
+		}
+		if marker == 0xD9 || marker == 0xDA {
+			return nil
+		}
+
+		lenBuf := make([]byte, 2)
+		if _, err := io.ReadFull(br, lenBuf); err != nil {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(lenBuf))
+		if length < 2 {
+			return fmt.Errorf("invalid JPEG segment length")
+		}
+		seg := make([]byte, length-2)
+		if _, err := io.ReadFull(br, seg); err != nil {
+			return nil
+		}
+
+		switch {
+		case isJPEGSOF(marker) && len(seg) >= 6:
+			m.BitDepth = int(seg[0])
+			m.Height = int(binary.BigEndian.Uint16(seg[1:]))
+			m.Width = int(binary.BigEndian.Uint16(seg[3:]))
+			switch seg[5] {
+			case 1:
+				m.ColorType = "Grayscale"
+			case 4:
+				m.ColorType = "CMYK"
+			}
+			if marker == 0xC2 {
+				m.Compression = "JPEG (progressive)"
+			}
+		case marker == 0xE0 && len(seg) >= 12 && string(seg[:5]) == "JFIF\x00" && m.DPI == 0:
+			density := float64(binary.BigEndian.Uint16(seg[8:]))
+			switch seg[7] {
+			case 1:
+				m.DPI = int(density)
+			case 2:
+				m.DPI = int(math.Round(density * 2.54))
+			}
+		case marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")):
+			applyEXIFBlock(seg, m)
+		case marker == 0xE1 && bytes.HasPrefix(seg, []byte(xmpJPEGPrefix)):
+			m.XMP = string(seg[len(xmpJPEGPrefix):])
+		case marker == 0xED && bytes.HasPrefix(seg, []byte("Photoshop 3.0\x00")):
+			parsePhotoshopResources(seg[14:], m)
+		}
+	}
+}
+
+// isJPEGSOF reports whether a marker starts a frame (SOF0-SOF15 except DHT, JPG and DAC)
+func isJPEGSOF(marker byte) bool {
+	return marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+}
+
+// readPNGMetadata walks PNG chunks, reading IHDR, pHYs, eXIf and the XMP
+// iTXt chunk and seeking past image data. A truncated IHDR still yields
+// the dimensions it contains.
+func readPNGMetadata(r io.ReadSeeker, m *ImageMetadata) error {
+	sig := make([]byte, 8)
+	if _, err := io.ReadFull(r, sig); err != nil || string(sig) != "\x89PNG\r\n\x1a\n" {
+		return fmt.Errorf("not a PNG file")
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		typ := string(header[4:])
+		if typ == "IEND" {
+			return nil
+		}
+		if typ == "IDAT" || length > 16<<20 {
+			if _, err := r.Seek(length+4, io.SeekCurrent); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		data := make([]byte, length)
+		n, _ := io.ReadFull(r, data)
+		data = data[:n]
+		switch typ {
+		case "IHDR":
+			applyPNGHeader(data, m)
+		case "pHYs":
+			if len(data) == 9 && data[8] == 1 {
+				m.DPI = int(math.Round(float64(binary.BigEndian.Uint32(data)) * 0.0254))
+			}
+		case "eXIf":
+			applyEXIFBlock(data, m)
+		case "iTXt":
+			if xmp, ok := pngXMP(data); ok {
+				m.XMP = xmp
+			}
+		}
+		if int64(n) < length {
+			return nil
+		}
+		if _, err := r.Seek(4, io.SeekCurrent); err != nil { // CRC
+			return nil
+		}
+	}
+}
+
+func applyPNGHeader(ihdr []byte, m *ImageMetadata) {
+	if len(ihdr) >= 8 {
+		m.Width = int(binary.BigEndian.Uint32(ihdr))
+		m.Height = int(binary.BigEndian.Uint32(ihdr[4:]))
+	}
+	if len(ihdr) < 10 {
+		return
+	}
+	m.BitDepth = int(ihdr[8])
+	switch ihdr[9] {
+	case 0:
+		m.ColorType = "Grayscale"
+	case 2:
+		m.ColorType = "RGB"
+	case 3:
+		m.ColorType = "Palette"
+	case 4:
+		m.ColorType = "Grayscale+Alpha"
+		m.HasAlpha = true
+	case 6:
+		m.ColorType = "RGBA"
+		m.HasAlpha = true
+	}
+}
+
+// pngXMP returns the text of an iTXt chunk with the XMP keyword
+func pngXMP(data []byte) (string, bool) {
+	const keyword = "XML:com.adobe.xmp\x00"
+	if !bytes.HasPrefix(data, []byte(keyword)) || len(data) < len(keyword)+2 {
+		return "", false
+	}
+	compressed := data[len(keyword)] == 1
+	rest := data[len(keyword)+2:]
+	for i := 0; i < 2; i++ { // language tag and translated keyword
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return "", false
+		}
+		rest = rest[end+1:]
+	}
+	if !compressed {
+		return string(rest), true
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return "", false
+	}
+	text, err := io.ReadAll(io.LimitReader(zr, 16<<20))
+	if err != nil {
+		return "", false
+	}
+	return string(text), true
+}
+
+// readWebPMetadata walks the RIFF chunks of a WebP file
+func readWebPMetadata(r io.ReadSeeker, m *ImageMetadata) error {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return fmt.Errorf("not a WebP file")
+	}
+
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil
+		}
+		typ := string(chunk[:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		padded := size + size%2
+		if size > 16<<20 || (typ != "VP8 " && typ != "VP8L" && typ != "VP8X" && typ != "EXIF" && typ != "XMP ") {
+			if _, err := r.Seek(padded, io.SeekCurrent); err != nil {
+				return nil
+			}
+			continue
+		}
+		data := make([]byte, padded)
+		if n, _ := io.ReadFull(r, data); int64(n) < size {
+			return nil
+		}
+		data = data[:size]
+
+		switch typ {
+		case "VP8X":
+			if len(data) >= 10 {
+				m.HasAlpha = data[0]&0x10 != 0
+				m.Width = 1 + (int(data[4]) | int(data[5])<<8 | int(data[6])<<16)
+				m.Height = 1 + (int(data[7]) | int(data[8])<<8 | int(data[9])<<16)
+			}
+		case "VP8 ":
+			m.Compression = "VP8"
+			if len(data) >= 10 && m.Width == 0 {
+				m.Width = int(binary.LittleEndian.Uint16(data[6:]) & 0x3FFF)
+				m.Height = int(binary.LittleEndian.Uint16(data[8:]) & 0x3FFF)
+			}
+		case "VP8L":
+			m.Compression = "VP8L"
+			if len(data) >= 5 && data[0] == 0x2F {
+				bits := binary.LittleEndian.Uint32(data[1:])
+				if m.Width == 0 {
+					m.Width = int(bits&0x3FFF) + 1
+					m.Height = int(bits>>14&0x3FFF) + 1
+				}
+				m.HasAlpha = m.HasAlpha || bits>>28&1 == 1
+			}
+		case "EXIF":
+			applyEXIFBlock(data, m)
+		case "XMP ":
+			m.XMP = string(data)
+		}
+	}
+}
+
+// readTIFFMetadata reads the first image directory of a TIFF file
+func readTIFFMetadata(r io.ReaderAt, size int64, m *ImageMetadata) error {
+	t, err := parseTIFFMetadata(r, size)
+	if err != nil {
+		return err
+	}
+	t.apply(m)
+
+	ifd0 := t.ifds[0]
+	m.BitDepth = int(t.uint(ifd0[0x0102], 0))
+	switch t.uint(ifd0[0x0103], 0) {
+	case 1:
+		m.Compression = "None"
+	case 5:
+		m.Compression = "LZW"
+	case 6, 7:
+		m.Compression = "JPEG"
+	case 8, 32946:
+		m.Compression = "Deflate"
+	case 32773:
+		m.Compression = "PackBits"
+	}
+	switch t.uint(ifd0[0x0106], 0) {
+	case 0, 1:
+		m.ColorType = "Grayscale"
+	case 2:
+		m.ColorType = "RGB"
+	case 3:
+		m.ColorType = "Palette"
+	case 5:
+		m.ColorType = "CMYK"
+	case 6:
+		m.ColorType = "YCbCr"
+	}
+	if _, ok := ifd0[0x0152]; ok {
+		m.HasAlpha = true
+	}
+	if e, ok := ifd0[tagXMP]; ok {
+		m.XMP = string(e.value)
+	}
+	if e, ok := ifd0[tagIPTC]; ok {
+		parseIPTC(e.value, m)
+	}
+	return nil
+}
+
+func analyzeTIFFProperties(file *os.File, metadata ImageMetadata) (ImageMetadata, error) {
+	err := readTIFFMetadata(file, metadata.FileSize, &metadata)
+	return metadata, err
+}
+
+func analyzeWebPProperties(file *os.File, metadata ImageMetadata) (ImageMetadata, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return metadata, err
+	}
+	err := readWebPMetadata(file, &metadata)
+	return metadata, err
+}
+
+// StripImageMetadata writes a copy of a JPEG, PNG, WebP or TIFF image
+// without EXIF, GPS, XMP, IPTC or comments. Pixel data and color profiles
+// are kept unchanged; TIFF metadata values are zeroed in place. The EXIF
+// orientation goes too, so callers that rely on it should rotate first.
+// outputPath may equal imagePath.
+func StripImageMetadata(imagePath, outputPath string) error {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return err
+	}
+
+	var stripped []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		stripped, err = stripJPEGMetadata(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		stripped, err = stripPNGMetadata(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		stripped, err = stripWebPMetadata(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		stripped, err = stripTIFFMetadata(data)
+	default:
+		return fmt.Errorf("unsupported image format for metadata stripping")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, stripped, 0644)
+}
+
+// stripJPEGMetadata drops APP1 and APP3-APP13 segments (EXIF, XMP, IPTC and
+// vendor data) and comments, keeping JFIF, ICC profiles and Adobe color info
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	out := append(make([]byte, 0, len(data)), 0xFF, 0xD8)
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker at offset %d", i)
+		}
+		j := i
+		for j < len(data) && data[j] == 0xFF {
+			j++
+		}
+		if j >= len(data) {
+			return nil, fmt.Errorf("truncated JPEG")
+		}
+		marker := data[j]
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out = append(out, 0xFF, marker)
+			i = j + 1
+			continue
+		}
+		if marker == 0xD9 {
+			return append(out, data[i:]...), nil
+		}
+		if j+2 >= len(data) {
+			return nil, fmt.Errorf("truncated JPEG")
+		}
+		end := j + 1 + int(binary.BigEndian.Uint16(data[j+1:]))
+		if end < j+3 || end > len(data) {
+			return nil, fmt.Errorf("invalid JPEG segment length at offset %d", i)
+		}
+		if marker == 0xDA {
+			return append(out, data[i:]...), nil
+		}
+
+		seg := data[j+3 : end]
+		drop := marker == 0xE1 || (marker >= 0xE3 && marker <= 0xED) || marker == 0xFE ||
+			(marker == 0xE2 && !bytes.HasPrefix(seg, []byte("ICC_PROFILE\x00")))
+		if !drop {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// stripPNGMetadata drops eXIf, text and tIME chunks
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	out := append(make([]byte, 0, len(data)), data[:8]...)
+	for i := 8; i < len(data); {
+		if i+12 > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", i)
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", i)
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out = append(out, data[i:end]...)
+		}
+		if string(data[i+4:i+8]) == "IEND" {
+			break
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// stripWebPMetadata drops EXIF and XMP chunks and clears their VP8X flags
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	out := append(make([]byte, 0, len(data)), data[:12]...)
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, fmt.Errorf("truncated WebP chunk at offset %d", i)
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end > len(data) {
+			if i+8+size != len(data) {
+				return nil, fmt.Errorf("truncated WebP chunk at offset %d", i)
+			}
+			end = len(data)
+		}
+		switch typ := string(data[i : i+4]); typ {
+		case "EXIF", "XMP ":
+		default:
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if typ == "VP8X" && size > 0 {
+				out[start+8] &^= 0x08 | 0x04
+			}
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// Tags whose values stripTIFFMetadata zeroes in the main directories
+var tiffPrivateTags = []uint16{0x010E, 0x010F, 0x0110, 0x0131, 0x0132, 0x013B, 0x013C, 0x8298, tagXMP, tagIPTC, tagPhotoshop}
+
+// stripTIFFMetadata zeroes identifying values in place, since moving data
+// would break the offsets in the file. EXIF and GPS directories are zeroed whole.
+func stripTIFFMetadata(data []byte) ([]byte, error) {
+	t, err := parseTIFFMetadata(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), data...)
+	blank := func(e exifEntry) {
+		for k := range e.value {
+			out[e.offset+int64(k)] = 0
+		}
+	}
+	for _, ifd := range t.ifds {
+		for _, tag := range tiffPrivateTags {
+			if e, ok := ifd[tag]; ok {
+				blank(e)
+			}
+		}
+	}
+	for _, ifd := range []exifIFD{t.exif, t.gps} {
+		for _, e := range ifd {
+			blank(e)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,405 @@
+package textlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTIFFTag is a directory entry for buildTestTIFF
+type testTIFFTag struct {
+	id, typ uint16
+	count   uint32
+	value   []byte
+}
+
+func asciiTag(id uint16, s string) testTIFFTag {
+	return testTIFFTag{id, 2, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func shortTag(order binary.ByteOrder, id uint16, v uint16) testTIFFTag {
+	b := make([]byte, 2)
+	order.PutUint16(b, v)
+	return testTIFFTag{id, 3, 1, b}
+}
+
+func rationalTag(order binary.ByteOrder, id uint16, pairs ...uint32) testTIFFTag {
+	b := make([]byte, 4*len(pairs))
+	for i, v := range pairs {
+		order.PutUint32(b[4*i:], v)
+	}
+	return testTIFFTag{id, 5, uint32(len(pairs) / 2), b}
+}
+
+// buildTestTIFF lays out IFD0 with optional EXIF and GPS sub-directories,
+// followed by the values that do not fit in their entries
+func buildTestTIFF(order binary.ByteOrder, ifd0, exif, gps []testTIFFTag) []byte {
+	ifdSize := func(n int) int { return 2 + 12*n + 4 }
+	n0 := len(ifd0)
+	if exif != nil {
+		n0++
+	}
+	if gps != nil {
+		n0++
+	}
+	exifOff := 8 + ifdSize(n0)
+	gpsOff := exifOff + ifdSize(len(exif))
+	dataOff := gpsOff + ifdSize(len(gps))
+
+	out := make([]byte, dataOff)
+	if order == binary.LittleEndian {
+		copy(out, "II")
+	} else {
+		copy(out, "MM")
+	}
+	order.PutUint16(out[2:], 42)
+	order.PutUint32(out[4:], 8)
+
+	writeIFD := func(off int, tags []testTIFFTag) {
+		order.PutUint16(out[off:], uint16(len(tags)))
+		for i, tag := range tags {
+			e := out[off+2+12*i:]
+			order.PutUint16(e, tag.id)
+			order.PutUint16(e[2:], tag.typ)
+			order.PutUint32(e[4:], tag.count)
+			if len(tag.value) <= 4 {
+				copy(e[8:12], tag.value)
+			} else {
+				order.PutUint32(e[8:], uint32(len(out)))
+				out = append(out, tag.value...)
+				if len(out)%2 == 1 {
+					out = append(out, 0)
+				}
+			}
+		}
+	}
+
+	long := func(id uint16, v int) testTIFFTag {
+		b := make([]byte, 4)
+		order.PutUint32(b, uint32(v))
+		return testTIFFTag{id, 4, 1, b}
+	}
+	tags := append([]testTIFFTag(nil), ifd0...)
+	if exif != nil {
+		tags = append(tags, long(tagExifIFD, exifOff))
+	}
+	if gps != nil {
+		tags = append(tags, long(tagGPSIFD, gpsOff))
+	}
+	writeIFD(8, tags)
+	writeIFD(exifOff, exif)
+	writeIFD(gpsOff, gps)
+	return out
+}
+
+// testCameraEXIF describes a photo taken at the Eiffel Tower
+func testCameraEXIF(order binary.ByteOrder) []byte {
+	return buildTestTIFF(order,
+		[]testTIFFTag{
+			asciiTag(0x010F, "Canon"),
+			asciiTag(0x0110, "Canon EOS R5"),
+			shortTag(order, 0x0112, 6),
+			asciiTag(0x0131, "Firmware 1.8"),
+		},
+		[]testTIFFTag{
+			rationalTag(order, 0x829A, 1, 250),
+			rationalTag(order, 0x829D, 28, 10),
+			shortTag(order, 0x8827, 400),
+			asciiTag(0x9003, "2024:06:01 14:30:05"),
+			asciiTag(0x9011, "+02:00"),
+			asciiTag(0x9291, "25"),
+			shortTag(order, 0x9209, 0x19),
+			rationalTag(order, 0x920A, 50, 1),
+			asciiTag(0xA433, "Canon"),
+			asciiTag(0xA434, "RF24-70mm F2.8 L IS USM"),
+			shortTag(order, 0xA001, 1),
+		},
+		[]testTIFFTag{
+			asciiTag(0x01, "N"),
+			rationalTag(order, 0x02, 48, 1, 51, 1, 2964, 100),
+			asciiTag(0x03, "E"),
+			rationalTag(order, 0x04, 2, 1, 17, 1, 4020, 100),
+			{0x05, 1, 1, []byte{0}},
+			rationalTag(order, 0x06, 35, 1),
+			rationalTag(order, 0x07, 12, 1, 30, 1, 5, 1),
+			asciiTag(0x1D, "2024:06:01"),
+		})
+}
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 12, 8))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+	return img
+}
+
+// testJPEGWithMetadata inserts EXIF, XMP, IPTC and a comment after the SOI marker
+func testJPEGWithMetadata(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	segment := func(marker byte, payload []byte) []byte {
+		return append([]byte{0xFF, marker, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+	}
+	iim := func(dataset byte, v string) []byte {
+		return append([]byte{0x1C, 2, dataset, 0, byte(len(v))}, v...)
+	}
+	iptc := append(iim(25, "paris"), iim(25, "tower")...)
+	iptc = append(iptc, iim(80, "Ada")...)
+	resource := append([]byte("8BIM\x04\x04\x00\x00"), byte(len(iptc)>>24), byte(len(iptc)>>16), byte(len(iptc)>>8), byte(len(iptc)))
+	resource = append(resource, iptc...)
+
+	var out []byte
+	out = append(out, 0xFF, 0xD8)
+	out = append(out, segment(0xE1, append([]byte("Exif\x00\x00"), testCameraEXIF(binary.BigEndian)...))...)
+	out = append(out, segment(0xE1, []byte(xmpJPEGPrefix+`<x:xmpmeta xmlns:x="adobe:ns:meta/"/>`))...)
+	out = append(out, segment(0xED, append([]byte("Photoshop 3.0\x00"), resource...))...)
+	out = append(out, segment(0xFE, []byte("shot by Ada"))...)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	out := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(out, uint32(len(data)))
+	copy(out[4:], typ)
+	out = append(out, data...)
+	crc := crc32.ChecksumIEEE(out[4:])
+	return binary.BigEndian.AppendUint32(out, crc)
+}
+
+// testPNGWithMetadata inserts eXIf, iTXt XMP and tEXt chunks after IHDR
+func testPNGWithMetadata(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	ihdrEnd := 8 + 25
+	out := append([]byte(nil), encoded[:ihdrEnd]...)
+	out = append(out, pngChunk("eXIf", testCameraEXIF(binary.LittleEndian))...)
+	out = append(out, pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta/>"))...)
+	out = append(out, pngChunk("tEXt", []byte("Comment\x00secret"))...)
+	out = append(out, pngChunk("pHYs", []byte{0, 0, 0x0B, 0x13, 0, 0, 0x0B, 0x13, 1})...)
+	return append(out, encoded[ihdrEnd:]...)
+}
+
+// testWebPWithMetadata builds an extended WebP with a lossless image header
+func testWebPWithMetadata() []byte {
+	chunk := func(typ string, data []byte) []byte {
+		out := append([]byte(typ), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(out[4:], uint32(len(data)))
+		out = append(out, data...)
+		if len(data)%2 == 1 {
+			out = append(out, 0)
+		}
+		return out
+	}
+	// 12x8 canvas; flags: EXIF and XMP present
+	vp8x := []byte{0x0C, 0, 0, 0, 11, 0, 0, 7, 0, 0}
+	bits := uint32(11) | uint32(7)<<14
+	vp8l := append([]byte{0x2F}, binary.LittleEndian.AppendUint32(nil, bits)...)
+
+	var body []byte
+	body = append(body, "WEBP"...)
+	body = append(body, chunk("VP8X", vp8x)...)
+	body = append(body, chunk("VP8L", vp8l)...)
+	body = append(body, chunk("EXIF", testCameraEXIF(binary.LittleEndian))...)
+	body = append(body, chunk("XMP ", []byte("<x:xmpmeta/>"))...)
+	return append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body))), body...)
+}
+
+func TestAnalyzeImagePropertiesEXIF(t *testing.T) {
+	dir := t.TempDir()
+	tiff := buildTestTIFF(binary.LittleEndian,
+		[]testTIFFTag{
+			shortTag(binary.LittleEndian, 0x0100, 640),
+			shortTag(binary.LittleEndian, 0x0101, 480),
+			shortTag(binary.LittleEndian, 0x0102, 8),
+			shortTag(binary.LittleEndian, 0x0103, 5),
+			shortTag(binary.LittleEndian, 0x0106, 2),
+			asciiTag(0x010F, "Nikon"),
+			asciiTag(0x0132, "2023:12:24 08:00:00"),
+			rationalTag(binary.LittleEndian, 0x011A, 300, 1),
+			shortTag(binary.LittleEndian, 0x0128, 2),
+		}, nil, nil)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		format        string
+		width, height int
+		xmp           bool
+	}{
+		{"jpeg", testJPEGWithMetadata(t), "JPEG", 12, 8, true},
+		{"png", testPNGWithMetadata(t), "PNG", 12, 8, true},
+		{"webp", testWebPWithMetadata(), "WebP", 12, 8, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			m, err := AnalyzeImageProperties(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Format != tt.format || m.Width != tt.width || m.Height != tt.height {
+				t.Errorf("Expected %s %dx%d, got %s %dx%d", tt.format, tt.width, tt.height, m.Format, m.Width, m.Height)
+			}
+			if m.Orientation != 6 || m.Camera.Make != "Canon" || m.Camera.Model != "Canon EOS R5" ||
+				m.Camera.Lens != "Canon RF24-70mm F2.8 L IS USM" || m.Camera.FocalLength != "50 mm" {
+				t.Errorf("Unexpected camera %+v, orientation %d", m.Camera, m.Orientation)
+			}
+			if m.Camera.Shutter != "1/250" || m.Camera.Aperture != "f/2.8" || m.Camera.ISO != 400 || !m.Camera.Flash {
+				t.Errorf("Unexpected exposure %+v", m.Camera)
+			}
+			want := time.Date(2024, 6, 1, 14, 30, 5, 250000000, time.FixedZone("", 2*3600))
+			if !m.Created.Equal(want) {
+				t.Errorf("Expected creation time %v, got %v", want, m.Created)
+			}
+			gps := m.Camera.GPS
+			if !gps.HasGPS || math.Abs(gps.Latitude-48.858234) > 1e-6 || math.Abs(gps.Longitude-2.2945) > 1e-6 || gps.Altitude != 35 {
+				t.Errorf("Unexpected GPS %+v", gps)
+			}
+			if !gps.Timestamp.Equal(time.Date(2024, 6, 1, 12, 30, 5, 0, time.UTC)) {
+				t.Errorf("Unexpected GPS time %v", gps.Timestamp)
+			}
+			if m.EXIF["Software"] != "Firmware 1.8" || m.EXIF["ExposureTime"] != "1/250" || m.EXIF["GPSLatitude"] != "48 51 741/25" {
+				t.Errorf("Unexpected EXIF map %v", m.EXIF)
+			}
+			if tt.xmp && !strings.Contains(m.XMP, "xmpmeta") {
+				t.Errorf("Expected an XMP packet, got %q", m.XMP)
+			}
+		})
+	}
+
+	jpegMeta, _ := AnalyzeImageProperties(filepath.Join(dir, "jpeg"))
+	if strings.Join(jpegMeta.IPTC["Keywords"], ",") != "paris,tower" || jpegMeta.IPTC["By-line"][0] != "Ada" {
+		t.Errorf("Unexpected IPTC %v", jpegMeta.IPTC)
+	}
+	pngMeta, _ := AnalyzeImageProperties(filepath.Join(dir, "png"))
+	if pngMeta.DPI != 72 || !pngMeta.HasAlpha {
+		t.Errorf("Expected 72 DPI RGBA PNG, got %d DPI, alpha %v", pngMeta.DPI, pngMeta.HasAlpha)
+	}
+
+	path := filepath.Join(dir, "scan.tif")
+	os.WriteFile(path, tiff, 0644)
+	m, err := AnalyzeImageProperties(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Format != "TIFF" || m.Width != 640 || m.Height != 480 || m.Compression != "LZW" || m.ColorType != "RGB" ||
+		m.DPI != 300 || m.Camera.Make != "Nikon" || m.Created.Year() != 2023 {
+		t.Errorf("Unexpected TIFF metadata %+v", m)
+	}
+}
+
+func TestParseTIFFMetadataDamaged(t *testing.T) {
+	good := testCameraEXIF(binary.BigEndian)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad order", append([]byte("XX"), good[2:]...)},
+		{"bad magic", append([]byte("MM\x00\x2B"), good[4:]...)},
+		{"offset past end", append([]byte("MM\x00*\x7F\xFF\xFF\xFF"), good[8:]...)},
+	}
+	for _, tt := range tests {
+		if _, err := parseTIFFMetadata(bytes.NewReader(tt.data), int64(len(tt.data))); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	// A directory that points back at itself is read once
+	loop := buildTestTIFF(binary.LittleEndian, []testTIFFTag{asciiTag(0x010F, "Loop")}, nil, nil)
+	binary.LittleEndian.PutUint32(loop[8+2+12:], 8)
+	meta, err := parseTIFFMetadata(bytes.NewReader(loop), int64(len(loop)))
+	if err != nil || len(meta.ifds) != 1 {
+		t.Errorf("Expected one directory, got %v", err)
+	}
+
+	// Truncated values are skipped rather than read out of bounds
+	truncated := good[:len(good)-40]
+	meta, err = parseTIFFMetadata(bytes.NewReader(truncated), int64(len(truncated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m ImageMetadata
+	meta.apply(&m)
+	if m.Camera.Make != "Canon" {
+		t.Errorf("Expected the intact tags to survive, got %+v", m.Camera)
+	}
+}
+
+func TestStripImageMetadata(t *testing.T) {
+	dir := t.TempDir()
+	tiff := testCameraEXIF(binary.LittleEndian)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		decoded bool // the stripped copy still decodes with the standard library
+	}{
+		{"photo.jpg", testJPEGWithMetadata(t), true},
+		{"photo.png", testPNGWithMetadata(t), true},
+		{"photo.webp", testWebPWithMetadata(), false},
+		{"photo.tif", tiff, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := filepath.Join(dir, tt.name)
+			out := filepath.Join(dir, "clean-"+tt.name)
+			os.WriteFile(in, tt.data, 0644)
+			if err := StripImageMetadata(in, out); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(out)
+			for _, secret := range []string{"Canon", "xmpmeta", "paris", "shot by Ada", "secret", "2024:06:01"} {
+				if bytes.Contains(data, []byte(secret)) {
+					t.Errorf("Expected %q to be stripped", secret)
+				}
+			}
+
+			m, err := AnalyzeImageProperties(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Camera.GPS.HasGPS || m.Camera.Make != "" || m.XMP != "" || m.IPTC != nil {
+				t.Errorf("Expected no metadata, got %+v", m)
+			}
+			if tt.name != "photo.tif" && (m.Width != 12 || m.Height != 8) {
+				t.Errorf("Expected the image to keep its size, got %dx%d", m.Width, m.Height)
+			}
+			if tt.decoded {
+				if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+					t.Errorf("Stripped image does not decode: %v", err)
+				}
+			}
+		})
+	}
+
+	webp, _ := os.ReadFile(filepath.Join(dir, "clean-photo.webp"))
+	if binary.LittleEndian.Uint32(webp[4:]) != uint32(len(webp)-8) || webp[20]&0x0C != 0 {
+		t.Errorf("Expected the RIFF size and VP8X flags to be updated")
+	}
+
+	text := filepath.Join(dir, "notes.txt")
+	os.WriteFile(text, []byte("hello"), 0644)
+	if err := StripImageMetadata(text, filepath.Join(dir, "out")); err == nil {
+		t.Error("Expected an unsupported format error")
+	}
+}
@@ -0,0 +1,283 @@
+package textlib
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testConfigSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"$id": "https://example.com/ingest.json",
+	"type": "object",
+	"required": ["name", "sources"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 3, "pattern": "^[a-z-]+$"},
+		"owner": {"type": "string", "format": "email"},
+		"retries": {"type": "integer", "minimum": 0, "maximum": 5},
+		"ratio": {"type": "number", "exclusiveMaximum": 1, "multipleOf": 0.25},
+		"sources": {"type": "array", "minItems": 1, "uniqueItems": true, "items": {"$ref": "#/$defs/source"}},
+		"schedule": {"oneOf": [{"$ref": "#cron"}, {"type": "object", "required": ["every"]}]}
+	},
+	"$defs": {
+		"source": {
+			"type": "object",
+			"required": ["kind"],
+			"properties": {
+				"kind": {"enum": ["file", "http"]},
+				"url": {"type": "string", "format": "uri"}
+			},
+			"if": {"properties": {"kind": {"const": "http"}}},
+			"then": {"required": ["url"]}
+		},
+		"cron": {"$anchor": "cron", "type": "string", "pattern": "^\\S+( \\S+){4}$"}
+	}
+}`
+
+func TestJSONSchemaValidate(t *testing.T) {
+	schema, err := CompileJSONSchema([]byte(testConfigSchema))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		errors   []ValidationError // Line, Column, Code and Path are compared
+	}{
+		{
+			name: "valid",
+			document: `{
+  "name": "daily-import",
+  "owner": "ops@example.com",
+  "retries": 3.0,
+  "ratio": 0.75,
+  "sources": [{"kind": "http", "url": "https://example.com/feed"}, {"kind": "file"}],
+  "schedule": "0 3 * * *"
+}`,
+		},
+		{
+			name: "violations",
+			document: `{
+  "name": "Daily",
+  "retries": 9,
+  "ratio": 0.3,
+  "sources": [
+    {"kind": "http"},
+    {"kind": "ftp"}
+  ],
+  "schedule": {"at": "03:00"},
+  "extra": true
+}`,
+			errors: []ValidationError{
+				{Line: 2, Column: 11, Code: "PATTERN", Path: "/name"},
+				{Line: 3, Column: 14, Code: "MAXIMUM", Path: "/retries"},
+				{Line: 4, Column: 12, Code: "MULTIPLE_OF", Path: "/ratio"},
+				{Line: 6, Column: 5, Code: "REQUIRED", Path: "/sources/0"},
+				{Line: 7, Column: 14, Code: "ENUM", Path: "/sources/1/kind"},
+				{Line: 9, Column: 15, Code: "ONE_OF", Path: "/schedule"},
+				{Line: 10, Column: 12, Code: "ADDITIONAL_PROPERTIES", Path: "/extra"},
+			},
+		},
+		{
+			name:     "root errors",
+			document: `{"name": "ab", "sources": []}`,
+			errors: []ValidationError{
+				{Line: 1, Column: 10, Code: "MIN_LENGTH", Path: "/name"},
+				{Line: 1, Column: 27, Code: "MIN_ITEMS", Path: "/sources"},
+			},
+		},
+		{
+			name:     "syntax error",
+			document: "{\n  \"name\": }",
+			errors:   []ValidationError{{Line: 2, Column: 12, Code: "SYNTAX_ERROR"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schema.Validate([]byte(tt.document))
+			if len(got) != len(tt.errors) {
+				t.Fatalf("Expected %d errors, got %d: %+v", len(tt.errors), len(got), got)
+			}
+			for i, want := range tt.errors {
+				g := got[i]
+				if g.Line != want.Line || g.Column != want.Column || g.Code != want.Code || g.Path != want.Path {
+					t.Errorf("Error %d: expected %d:%d %s %s, got %d:%d %s %s (%s)",
+						i, want.Line, want.Column, want.Code, want.Path, g.Line, g.Column, g.Code, g.Path, g.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		schema string
+		valid  []string
+		errors []string
+	}{
+		{`{"type": ["string", "null"]}`, []string{`"x"`, `null`}, []string{`1`}},
+		{`{"anyOf": [{"type": "string"}, {"minimum": 10}]}`, []string{`"x"`, `12`}, []string{`3`}},
+		{`{"not": {"type": "string"}}`, []string{`1`}, []string{`"x"`}},
+		{`{"allOf": [{"minimum": 1}, {"maximum": 3}]}`, []string{`2`}, []string{`0`, `4`}},
+		{`{"const": {"a": [1, 2.0]}}`, []string{`{"a": [1.0, 2]}`}, []string{`{"a": [2, 1]}`}},
+		{`{"prefixItems": [{"type": "string"}], "items": false}`, []string{`["a"]`}, []string{`["a", 1]`, `[1]`}},
+		{`{"contains": {"type": "integer"}, "minContains": 2, "maxContains": 3}`, []string{`[1, "a", 2]`}, []string{`[1]`, `[1, 2, 3, 4]`}},
+		{`{"propertyNames": {"maxLength": 3}}`, []string{`{"abc": 1}`}, []string{`{"abcd": 1}`}},
+		{`{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false}`, []string{`{"x-a": "b"}`}, []string{`{"x-a": 1}`, `{"y": "b"}`}},
+		{`{"dependentRequired": {"card": ["cvv"]}}`, []string{`{"card": 1, "cvv": 2}`, `{}`}, []string{`{"card": 1}`}},
+		{`{"dependentSchemas": {"a": {"required": ["b"]}}}`, []string{`{"a": 1, "b": 2}`}, []string{`{"a": 1}`}},
+		{
+			`{"allOf": [{"properties": {"a": true}}], "properties": {"b": true}, "unevaluatedProperties": false}`,
+			[]string{`{"a": 1, "b": 2}`},
+			[]string{`{"a": 1, "c": 3}`},
+		},
+		{`{"prefixItems": [true], "unevaluatedItems": {"type": "string"}}`, []string{`[1, "a"]`}, []string{`[1, 2]`}},
+		{`{"minProperties": 1, "maxProperties": 2}`, []string{`{"a": 1}`}, []string{`{}`, `{"a": 1, "b": 2, "c": 3}`}},
+		{`{"$defs": {"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}}}}, "$ref": "#/$defs/node"}`,
+			[]string{`{"next": {"next": {}}}`}, []string{`{"next": {"next": 1}}`}},
+		{`{"definitions": {"n": {"type": "integer"}}, "items": [{"$ref": "#/definitions/n"}], "additionalItems": false}`,
+			[]string{`[1]`}, []string{`["a"]`, `[1, 2]`}},
+		{`{"format": "date-time"}`, []string{`"2024-05-01T10:00:00Z"`, `5`}, []string{`"2024-05-01 10:00"`}},
+		{`{"format": "ipv4"}`, []string{`"10.0.0.1"`}, []string{`"::1"`, `"300.1.1.1"`}},
+		{`{"format": "uuid"}`, []string{`"123e4567-e89b-12d3-a456-426614174000"`}, []string{`"123"`}},
+		{`{"format": "duration"}`, []string{`"P1DT2H"`, `"PT0.5S"`}, []string{`"P"`, `"PT"`, `"1D"`}},
+		{`{"format": "made-up"}`, []string{`"anything"`}, nil},
+		{`true`, []string{`1`}, nil},
+		{`false`, nil, []string{`1`}},
+	}
+
+	for _, tt := range tests {
+		schema, err := CompileJSONSchema([]byte(tt.schema))
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", tt.schema, err)
+			continue
+		}
+		for _, doc := range tt.valid {
+			if errs := schema.Validate([]byte(doc)); len(errs) != 0 {
+				t.Errorf("%s: expected %s to be valid, got %+v", tt.schema, doc, errs)
+			}
+		}
+		for _, doc := range tt.errors {
+			if errs := schema.Validate([]byte(doc)); len(errs) == 0 {
+				t.Errorf("%s: expected %s to be invalid", tt.schema, doc)
+			}
+		}
+	}
+}
+
+func TestCompileJSONSchemaErrors(t *testing.T) {
+	for _, schema := range []string{
+		`{"type": `,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"$ref": "other.json"}`,
+		`{"pattern": "(?<=a)b"}`,
+		`{"properties": {"a": 5}}`,
+		`{"allOf": {}}`,
+	} {
+		if _, err := CompileJSONSchema([]byte(schema)); err == nil {
+			t.Errorf("Expected %s to be rejected", schema)
+		}
+	}
+
+	// A self-reference that never descends into the instance stops
+	schema, err := CompileJSONSchema([]byte(`{"allOf": [{"$ref": "#"}]}`))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if errs := schema.Validate([]byte(`1`)); len(errs) == 0 || errs[0].Code != "REF" {
+		t.Errorf("Expected a recursion error, got %+v", errs)
+	}
+}
+
+func TestValidateJSONAgainstSchema(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	write("defs.json", `{"$defs": {"port": {"type": "integer", "minimum": 1, "maximum": 65535}}}`)
+	schemaPath := write("config.schema.json", `{
+		"type": "object",
+		"properties": {"port": {"$ref": "defs.json#/$defs/port"}}
+	}`)
+	good := write("good.json", `{"port": 8080}`)
+	bad := write("bad.json", "{\n  \"port\": 70000\n}")
+
+	errs, err := ValidateJSONAgainstSchema(good, schemaPath)
+	if err != nil || len(errs) != 0 {
+		t.Errorf("Expected good.json to validate, got %+v (%v)", errs, err)
+	}
+
+	errs, err = ValidateJSONAgainstSchema(bad, schemaPath)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if len(errs) != 1 || errs[0].Path != "/port" || errs[0].Line != 2 || errs[0].Value != "70000" {
+		t.Errorf("Expected one error at /port on line 2, got %+v", errs)
+	}
+
+	if _, err := ValidateJSONAgainstSchema(good, filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected an error for a missing schema")
+	}
+}
+
+func TestInferJSONSchema(t *testing.T) {
+	samples := [][]byte{
+		[]byte(`{"id": 1, "name": "a", "tags": ["x"], "created": "2024-01-02T03:04:05Z", "score": 1}`),
+		[]byte(`{"id": 2, "name": null, "tags": [], "created": "2024-02-03T00:00:00Z", "score": 2.5, "extra": {"ok": true}}`),
+	}
+
+	schema, err := InferJSONSchema(samples...)
+	if err != nil {
+		t.Fatalf("Inference failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"$schema":  "https://json-schema.org/draft/2020-12/schema",
+		"type":     "object",
+		"required": []string{"created", "id", "name", "score", "tags"},
+		"properties": map[string]interface{}{
+			"id":      map[string]interface{}{"type": "integer"},
+			"name":    map[string]interface{}{"type": []string{"null", "string"}},
+			"tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"created": map[string]interface{}{"type": "string", "format": "date-time"},
+			"score":   map[string]interface{}{"type": "number"},
+			"extra": map[string]interface{}{
+				"type":       "object",
+				"required":   []string{"ok"},
+				"properties": map[string]interface{}{"ok": map[string]interface{}{"type": "boolean"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("Expected %v, got %v", want, schema)
+	}
+
+	// Every sample validates against the inferred schema
+	encoded, _ := json.Marshal(schema)
+	compiled, err := CompileJSONSchema(encoded)
+	if err != nil {
+		t.Fatalf("Inferred schema does not compile: %v", err)
+	}
+	for _, sample := range samples {
+		if errs := compiled.Validate(sample); len(errs) != 0 {
+			t.Errorf("Sample %s does not validate: %+v", sample, errs)
+		}
+	}
+	if errs := compiled.Validate([]byte(`{"id": "x"}`)); len(errs) != 5 {
+		t.Errorf("Expected 4 missing properties and a type error, got %+v", errs)
+	}
+
+	if _, err := InferJSONSchema(); err == nil {
+		t.Error("Expected an error without samples")
+	}
+}
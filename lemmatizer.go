@@ -0,0 +1,270 @@
+package textlib
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed data/lemma_en.txt
+var englishLemmaData string
+
+// EnglishLemmatizer maps English inflections to dictionary forms using an
+// embedded table of irregular words and suffix rules for regular ones
+type EnglishLemmatizer struct {
+	irregular map[string]map[string]string // part of speech -> form -> lemma
+}
+
+// NewEnglishLemmatizer creates a lemmatizer backed by the embedded dictionary
+func NewEnglishLemmatizer() *EnglishLemmatizer {
+	l := &EnglishLemmatizer{
+		irregular: map[string]map[string]string{
+			"n": {},
+			"v": {},
+			"a": {},
+		},
+	}
+
+	for _, line := range strings.Split(englishLemmaData, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		pos := "v"
+		if len(fields) > 2 {
+			pos = fields[2]
+		}
+		if l.irregular[pos] != nil {
+			l.irregular[pos][fields[0]] = fields[1]
+		}
+	}
+
+	return l
+}
+
+// Lemmatize returns the dictionary form of a lowercase word
+func (l *EnglishLemmatizer) Lemmatize(word, tag string) string {
+	if len(word) <= 2 {
+		return word
+	}
+
+	pos := lemmaPartOfSpeech(tag)
+
+	// Base-form verbs are already lemmas, and irregular tables only hold inflections
+	if tag != "VB" {
+		if lemma, ok := l.lookupIrregular(word, pos); ok {
+			return lemma
+		}
+	}
+
+	switch pos {
+	case "n":
+		return lemmatizeNoun(word)
+	case "v":
+		return lemmatizeVerb(word, tag)
+	case "a":
+		if tag == "JJR" || tag == "JJS" {
+			return lemmatizeAdjective(word)
+		}
+		return word
+	case "":
+		// No tag: try the common inflections in order of likelihood
+		switch {
+		case strings.HasSuffix(word, "ing") || strings.HasSuffix(word, "ed"):
+			return lemmatizeVerb(word, "")
+		case strings.HasSuffix(word, "s"):
+			return lemmatizeNoun(word)
+		}
+	}
+
+	return word
+}
+
+func (l *EnglishLemmatizer) lookupIrregular(word, pos string) (string, bool) {
+	if pos != "" {
+		lemma, ok := l.irregular[pos][word]
+		return lemma, ok
+	}
+
+	for _, p := range []string{"v", "n", "a"} {
+		if lemma, ok := l.irregular[p][word]; ok {
+			return lemma, true
+		}
+	}
+	return "", false
+}
+
+func lemmaPartOfSpeech(tag string) string {
+	switch {
+	case tag == "":
+		return ""
+	case strings.HasPrefix(tag, "NN"):
+		return "n"
+	case strings.HasPrefix(tag, "VB"):
+		return "v"
+	case strings.HasPrefix(tag, "JJ"):
+		return "a"
+	default:
+		return "x"
+	}
+}
+
+func lemmatizeNoun(word string) string {
+	switch {
+	case strings.HasSuffix(word, "ss") || strings.HasSuffix(word, "us") || strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses") || strings.HasSuffix(word, "shes") ||
+		strings.HasSuffix(word, "ches") || strings.HasSuffix(word, "xes") ||
+		strings.HasSuffix(word, "zes") || strings.HasSuffix(word, "oes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "uses") && len(word) > 4 && !isVowel(rune(word[len(word)-5])):
+		// buses, viruses, bonuses; -ouses and -auses keep their e
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// Stems that end in -eed without it being an inflection
+var lemmaEedWords = map[string]bool{
+	"need": true, "feed": true, "seed": true, "speed": true, "heed": true,
+	"weed": true, "bleed": true, "breed": true, "deed": true, "reed": true,
+	"proceed": true, "exceed": true, "succeed": true, "indeed": true,
+}
+
+func lemmatizeVerb(word, tag string) string {
+	switch tag {
+	case "VB", "VBP":
+		return word
+	case "VBZ":
+		return lemmatizeThirdPerson(word)
+	case "VBG":
+		return lemmatizeParticiple(word, "ing")
+	case "VBD", "VBN":
+		return lemmatizeParticiple(word, "ed")
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ing"):
+		return lemmatizeParticiple(word, "ing")
+	case strings.HasSuffix(word, "ed"):
+		return lemmatizeParticiple(word, "ed")
+	}
+	return lemmatizeThirdPerson(word)
+}
+
+func lemmatizeThirdPerson(word string) string {
+	switch {
+	case strings.HasSuffix(word, "ss") || strings.HasSuffix(word, "us"):
+		return word
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses") || strings.HasSuffix(word, "shes") ||
+		strings.HasSuffix(word, "ches") || strings.HasSuffix(word, "xes") ||
+		strings.HasSuffix(word, "zes") || strings.HasSuffix(word, "oes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+func lemmatizeParticiple(word, suffix string) string {
+	if !strings.HasSuffix(word, suffix) || lemmaEedWords[word] {
+		return word
+	}
+
+	stem := word[:len(word)-len(suffix)]
+	if !porter2ContainsVowel([]byte(stem)) {
+		return word
+	}
+
+	switch {
+	case suffix == "ed" && strings.HasSuffix(stem, "i") && len(stem) > 2:
+		// carried, studied
+		return stem[:len(stem)-1] + "y"
+	case suffix == "ed" && strings.HasSuffix(stem, "e"):
+		// agreed, freed
+		return word[:len(word)-1]
+	case suffix == "ing" && strings.HasSuffix(stem, "ee"):
+		return stem
+	}
+
+	return restoreVerbStem(stem)
+}
+
+func lemmatizeAdjective(word string) string {
+	var stem string
+	switch {
+	case strings.HasSuffix(word, "iest") && len(word) > 5:
+		return word[:len(word)-4] + "y"
+	case strings.HasSuffix(word, "ier") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "est") && len(word) > 4:
+		stem = word[:len(word)-3]
+	case strings.HasSuffix(word, "er") && len(word) > 3:
+		stem = word[:len(word)-2]
+	default:
+		return word
+	}
+
+	b := []byte(stem)
+	if porter2EndsDouble(b) {
+		return stem[:len(stem)-1]
+	}
+	if porter2IsShortWord(b, porter2RegionStart(b, 0)) {
+		return stem + "e"
+	}
+	return stem
+}
+
+// Vowel-consonant endings that take a silent e when preceded by a consonant,
+// as in creat(e), decid(e), comput(e) and combin(e)
+var silentEEndings = map[string]bool{
+	"at": true, "id": true, "od": true, "ud": true, "ut": true, "um": true,
+	"ok": true, "in": true, "ir": true, "ur": true, "iz": true, "yz": true,
+}
+
+// restoreVerbStem repairs a verb stem after -ing or -ed has been removed,
+// undoubling final consonants (running) and restoring a silent e (making)
+func restoreVerbStem(stem string) string {
+	b := []byte(stem)
+	n := len(b)
+	if n < 2 {
+		return stem
+	}
+
+	if porter2EndsDouble(b) {
+		return stem[:n-1]
+	}
+
+	last, prev := b[n-1], b[n-2]
+	switch {
+	case last == 'v' || last == 'c' || last == 'u':
+		return stem + "e"
+	case last == 's' && prev != 's':
+		return stem + "e"
+	case last == 'g' && (prev == 'd' || prev == 'r'):
+		return stem + "e"
+	case last == 'l' && strings.IndexByte("bcdfgkpstz", prev) >= 0:
+		return stem + "e"
+	case strings.HasSuffix(stem, "creat") || (strings.HasSuffix(stem, "ang") && n >= 5):
+		// create, change, arrange
+		return stem + "e"
+	case silentEEndings[stem[n-2:]] && (n == 2 || !isPorterVowel(b[n-3]) || (n >= 4 && stem[n-4:n-2] == "qu")):
+		return stem + "e"
+	}
+
+	if porter2IsShortWord(b, porter2RegionStart(b, 0)) {
+		return stem + "e"
+	}
+	return stem
+}
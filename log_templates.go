@@ -0,0 +1,274 @@
+package textlib
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// LogTemplateOptions tunes Drain template mining
+type LogTemplateOptions struct {
+	Depth       int     // prefix tree depth counting the root and length levels (default 4)
+	Similarity  float64 // share of identical tokens needed to join a template (default 0.4)
+	MaxChildren int     // children per tree node before new tokens share the wildcard branch (default 100)
+}
+
+func (o LogTemplateOptions) withDefaults() LogTemplateOptions {
+	if o.Depth < 3 {
+		o.Depth = 4
+	}
+	if o.Similarity <= 0 {
+		o.Similarity = 0.4
+	}
+	if o.MaxChildren <= 0 {
+		o.MaxChildren = 100
+	}
+	return o
+}
+
+// LogTemplate is a cluster of messages that differ only in their parameters
+type LogTemplate struct {
+	ID        int
+	Template  string // message tokens with "<*>" in the variable positions
+	Count     int
+	Levels    map[string]int // records per level
+	Example   string         // first message of the cluster
+	FirstLine int
+	LastLine  int
+	FirstSeen time.Time // zero when the records had no timestamps
+	LastSeen  time.Time
+}
+
+// LogTemplateMatch reports the template a message was assigned to
+type LogTemplateMatch struct {
+	ID       int
+	Template string
+	New      bool // the message started a new template
+}
+
+// TemplateMiner clusters log messages into templates incrementally using
+// the Drain fixed-depth tree. It is not safe for concurrent use.
+type TemplateMiner struct {
+	opts     LogTemplateOptions
+	root     map[int]*drainNode // keyed by token count
+	clusters []*drainCluster
+}
+
+type drainNode struct {
+	children map[string]*drainNode
+	clusters []*drainCluster
+}
+
+type drainCluster struct {
+	tokens []string
+	tmpl   LogTemplate
+}
+
+const drainWildcard = "<*>"
+
+// NewTemplateMiner returns an empty miner
+func NewTemplateMiner(opts LogTemplateOptions) *TemplateMiner {
+	return &TemplateMiner{opts: opts.withDefaults(), root: make(map[int]*drainNode)}
+}
+
+// Add assigns a message to a template, creating one when none is similar enough
+func (m *TemplateMiner) Add(message string) LogTemplateMatch {
+	return m.AddRecord(LogRecord{Message: message})
+}
+
+// AddRecord assigns a record's message to a template and tracks its line,
+// time and level on the template
+func (m *TemplateMiner) AddRecord(rec LogRecord) LogTemplateMatch {
+	tokens := drainTokens(rec.Message)
+	c := m.search(tokens)
+	isNew := c == nil
+	if isNew {
+		c = &drainCluster{
+			tokens: tokens,
+			tmpl: LogTemplate{
+				ID:        len(m.clusters) + 1,
+				Levels:    make(map[string]int),
+				Example:   rec.Message,
+				FirstLine: rec.Line,
+				FirstSeen: rec.Timestamp,
+			},
+		}
+		m.clusters = append(m.clusters, c)
+		m.insert(c)
+	} else {
+		for i, tok := range c.tokens {
+			if tok != tokens[i] {
+				c.tokens[i] = drainWildcard
+			}
+		}
+	}
+
+	t := &c.tmpl
+	t.Count++
+	if rec.Level != "" {
+		t.Levels[rec.Level]++
+	}
+	if rec.Line > t.LastLine {
+		t.LastLine = rec.Line
+	}
+	if !rec.Timestamp.IsZero() {
+		if t.FirstSeen.IsZero() || rec.Timestamp.Before(t.FirstSeen) {
+			t.FirstSeen = rec.Timestamp
+		}
+		if rec.Timestamp.After(t.LastSeen) {
+			t.LastSeen = rec.Timestamp
+		}
+	}
+	return LogTemplateMatch{ID: t.ID, Template: strings.Join(c.tokens, " "), New: isNew}
+}
+
+// Templates returns the templates ranked by count, ties by first appearance
+func (m *TemplateMiner) Templates() []LogTemplate {
+	out := make([]LogTemplate, len(m.clusters))
+	for i, c := range m.clusters {
+		t := c.tmpl
+		t.Template = strings.Join(c.tokens, " ")
+		t.Levels = make(map[string]int, len(c.tmpl.Levels))
+		for k, v := range c.tmpl.Levels {
+			t.Levels[k] = v
+		}
+		out[i] = t
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Count > out[j].Count })
+	return out
+}
+
+// search walks the tree to a leaf and returns its most similar cluster,
+// or nil when none reaches the similarity threshold
+func (m *TemplateMiner) search(tokens []string) *drainCluster {
+	node := m.root[len(tokens)]
+	if node == nil {
+		return nil
+	}
+	for i := 0; i < m.opts.Depth-2 && i < len(tokens); i++ {
+		child := node.children[tokens[i]]
+		if child == nil {
+			child = node.children[drainWildcard]
+		}
+		if child == nil {
+			return nil
+		}
+		node = child
+	}
+
+	var best *drainCluster
+	bestSim, bestParams := -1.0, -1
+	for _, c := range node.clusters {
+		sim, params := drainSimilarity(c.tokens, tokens)
+		if sim > bestSim || (sim == bestSim && params > bestParams) {
+			best, bestSim, bestParams = c, sim, params
+		}
+	}
+	if bestSim < m.opts.Similarity {
+		return nil
+	}
+	return best
+}
+
+// insert adds a new cluster under the leaf for its length and leading tokens.
+// Tokens with digits, and tokens past MaxChildren, go to the wildcard branch.
+func (m *TemplateMiner) insert(c *drainCluster) {
+	node := m.root[len(c.tokens)]
+	if node == nil {
+		node = &drainNode{children: make(map[string]*drainNode)}
+		m.root[len(c.tokens)] = node
+	}
+	for i := 0; i < m.opts.Depth-2 && i < len(c.tokens); i++ {
+		key := c.tokens[i]
+		if strings.IndexFunc(key, unicode.IsDigit) >= 0 {
+			key = drainWildcard
+		}
+		child := node.children[key]
+		if child == nil && key != drainWildcard && len(node.children) >= m.opts.MaxChildren {
+			key = drainWildcard
+			child = node.children[key]
+		}
+		if child == nil {
+			child = &drainNode{children: make(map[string]*drainNode)}
+			node.children[key] = child
+		}
+		node = child
+	}
+	node.clusters = append(node.clusters, c)
+}
+
+// drainSimilarity is the share of positions where the template and tokens
+// agree, not counting wildcards, and the number of wildcards in the template
+func drainSimilarity(template, tokens []string) (float64, int) {
+	if len(template) == 0 {
+		return 1, 0
+	}
+	same, params := 0, 0
+	for i, tok := range template {
+		if tok == drainWildcard {
+			params++
+		} else if tok == tokens[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(template)), params
+}
+
+var drainVariablePattern = regexp.MustCompile(`^(?:` +
+	`[0-9A-Fa-f]{8}-(?:[0-9A-Fa-f]{4}-){3}[0-9A-Fa-f]{12}` + // UUID
+	`|(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?` + // IPv4 with optional port
+	`|0[xX][0-9A-Fa-f]+` +
+	`|[0-9a-f]{16,}` + // hashes and trace IDs
+	`|[+-]?\d+(?:\.\d+)?(?:[a-zA-Zµ%]{1,3})?` + // numbers with optional units
+	`)$`)
+
+// drainTokens splits a message into tokens, masking obvious variables such
+// as numbers, IPs and UUIDs, including the values of key=value tokens
+func drainTokens(message string) []string {
+	tokens := strings.Fields(message)
+	for i, tok := range tokens {
+		tokens[i] = maskDrainToken(tok)
+	}
+	return tokens
+}
+
+func maskDrainToken(tok string) string {
+	core := strings.TrimRight(tok, ",;.)]")
+	suffix := tok[len(core):]
+	if k := strings.IndexByte(core, '='); k > 0 {
+		return core[:k+1] + maskDrainToken(core[k+1:]) + suffix
+	}
+	trimmed := strings.TrimLeft(core, "([")
+	if trimmed != "" && drainVariablePattern.MatchString(trimmed) {
+		return core[:len(core)-len(trimmed)] + drainWildcard + suffix
+	}
+	return tok
+}
+
+// MineLogTemplates parses every record in r and returns its message templates
+// ranked by count
+func MineLogTemplates(r io.Reader, opts LogParseOptions, topts LogTemplateOptions) ([]LogTemplate, error) {
+	miner := NewTemplateMiner(topts)
+	s := NewLogScanner(r, opts)
+	for s.Scan() {
+		miner.AddRecord(s.Record())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return miner.Templates(), nil
+}
+
+// MineLogTemplatesFile is MineLogTemplates over a file
+func MineLogTemplatesFile(path string, opts LogParseOptions, topts LogTemplateOptions) ([]LogTemplate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return MineLogTemplates(f, opts, topts)
+}
@@ -0,0 +1,291 @@
+package textlib
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LogTimelineOptions controls AnalyzeLogTimeline
+type LogTimelineOptions struct {
+	Bucket         time.Duration // bucket width; chosen from the time span when zero
+	BurstFactor    float64       // error rate multiple over the baseline that marks a burst (default 3)
+	MinBurstErrors int           // errors a bucket needs before it can be a burst (default 5)
+	MinGap         time.Duration // silence reported as a gap; derived from the record spacing when zero
+	Warmup         time.Duration // templates first seen this early are not new (default: first 10% of the span)
+	Templates      LogTemplateOptions
+}
+
+func (o LogTimelineOptions) withDefaults() LogTimelineOptions {
+	if o.BurstFactor <= 0 {
+		o.BurstFactor = 3
+	}
+	if o.MinBurstErrors <= 0 {
+		o.MinBurstErrors = 5
+	}
+	return o
+}
+
+// LogTimeline describes how a log evolves over time
+type LogTimeline struct {
+	Start     time.Time
+	End       time.Time
+	Bucket    time.Duration
+	Buckets   []LogBucket
+	Records   int
+	Untimed   int // records without a timestamp, left out of the buckets
+	Templates []LogTemplate
+	Anomalies []LogAnomaly // ordered by start time
+}
+
+// LogBucket counts the records in one time slice
+type LogBucket struct {
+	Start        time.Time
+	Count        int
+	Errors       int // ERROR and FATAL records
+	ErrorRate    float64
+	NewTemplates int
+	Levels       map[string]int
+}
+
+// LogAnomaly is a TextAnomaly tied to a time range. Type is "error_burst",
+// "new_template" or "gap", and Location holds the first and last record lines.
+type LogAnomaly struct {
+	TextAnomaly
+	Start time.Time
+	End   time.Time
+}
+
+// Bucket widths tried, smallest first, when none is given
+var logBucketSizes = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 6 * time.Hour, 24 * time.Hour,
+}
+
+// maxLogBuckets bounds the bucket count; narrower buckets are widened
+const maxLogBuckets = 100000
+
+// AnalyzeLogTimeline buckets records by time and reports error-rate bursts,
+// templates first seen after the warmup, and gaps in logging. Records are
+// considered in timestamp order; untimed records are only counted.
+func AnalyzeLogTimeline(records []LogRecord, opts LogTimelineOptions) LogTimeline {
+	opts = opts.withDefaults()
+	timeline := LogTimeline{Records: len(records)}
+
+	timed := make([]LogRecord, 0, len(records))
+	for _, rec := range records {
+		if rec.Timestamp.IsZero() {
+			timeline.Untimed++
+		} else {
+			timed = append(timed, rec)
+		}
+	}
+	if len(timed) == 0 {
+		return timeline
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].Timestamp.Before(timed[j].Timestamp) })
+
+	timeline.Start = timed[0].Timestamp
+	timeline.End = timed[len(timed)-1].Timestamp
+	span := timeline.End.Sub(timeline.Start)
+	timeline.Bucket = logBucketWidth(span, opts.Bucket)
+	warmup := opts.Warmup
+	if warmup <= 0 {
+		warmup = span / 10
+	}
+
+	origin := timeline.Start.Truncate(timeline.Bucket)
+	timeline.Buckets = make([]LogBucket, int(timeline.End.Sub(origin)/timeline.Bucket)+1)
+	for i := range timeline.Buckets {
+		timeline.Buckets[i] = LogBucket{Start: origin.Add(time.Duration(i) * timeline.Bucket), Levels: make(map[string]int)}
+	}
+
+	miner := NewTemplateMiner(opts.Templates)
+	var newTemplates []int
+	totalErrors := 0
+	for _, rec := range timed {
+		b := &timeline.Buckets[int(rec.Timestamp.Sub(origin)/timeline.Bucket)]
+		b.Count++
+		if rec.Level != "" {
+			b.Levels[rec.Level]++
+		}
+		if rec.Level == "ERROR" || rec.Level == "FATAL" {
+			b.Errors++
+			totalErrors++
+		}
+		if match := miner.AddRecord(rec); match.New && rec.Timestamp.Sub(timeline.Start) > warmup {
+			b.NewTemplates++
+			newTemplates = append(newTemplates, match.ID)
+		}
+	}
+	for i := range timeline.Buckets {
+		if b := &timeline.Buckets[i]; b.Count > 0 {
+			b.ErrorRate = float64(b.Errors) / float64(b.Count)
+		}
+	}
+	timeline.Templates = miner.Templates()
+
+	timeline.Anomalies = append(timeline.Anomalies, detectErrorBursts(timeline, timed, totalErrors, opts)...)
+	timeline.Anomalies = append(timeline.Anomalies, newTemplateAnomalies(timeline.Templates, newTemplates)...)
+	timeline.Anomalies = append(timeline.Anomalies, detectLogGaps(timed, timeline.Bucket, opts.MinGap)...)
+	sort.SliceStable(timeline.Anomalies, func(i, j int) bool {
+		return timeline.Anomalies[i].Start.Before(timeline.Anomalies[j].Start)
+	})
+	return timeline
+}
+
+// logBucketWidth returns the requested width, or the smallest standard
+// width giving at most 60 buckets, widened if needed to stay under maxLogBuckets
+func logBucketWidth(span, requested time.Duration) time.Duration {
+	width := requested
+	if width <= 0 {
+		width = logBucketSizes[len(logBucketSizes)-1]
+		for _, size := range logBucketSizes {
+			if span/size < 60 {
+				width = size
+				break
+			}
+		}
+	}
+	if span/width >= maxLogBuckets {
+		width = span/maxLogBuckets + 1
+	}
+	return width
+}
+
+// detectErrorBursts flags runs of buckets whose error rate is BurstFactor
+// times the rate in the rest of the log
+func detectErrorBursts(timeline LogTimeline, timed []LogRecord, totalErrors int, opts LogTimelineOptions) []LogAnomaly {
+	var anomalies []LogAnomaly
+	var current *LogAnomaly
+	errors := 0
+
+	for _, b := range timeline.Buckets {
+		burst := false
+		severity := 0.0
+		if b.Errors >= opts.MinBurstErrors {
+			rest := len(timed) - b.Count
+			baseline := 1 / float64(len(timed))
+			if rest > 0 && totalErrors > b.Errors {
+				baseline = float64(totalErrors-b.Errors) / float64(rest)
+			}
+			severity = b.ErrorRate / baseline
+			burst = severity >= opts.BurstFactor
+		}
+		if !burst {
+			current = nil
+			continue
+		}
+
+		end := b.Start.Add(timeline.Bucket)
+		if current == nil {
+			anomalies = append(anomalies, LogAnomaly{
+				TextAnomaly: TextAnomaly{Type: "error_burst", Severity: severity},
+				Start:       b.Start,
+			})
+			current = &anomalies[len(anomalies)-1]
+			errors = 0
+		}
+		current.End = end
+		errors += b.Errors
+		if severity > current.Severity {
+			current.Severity = severity
+		}
+		current.Description = fmt.Sprintf("%d errors between %s and %s", errors, current.Start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	// Fill in the lines and first error message of each burst
+	for i := range anomalies {
+		a := &anomalies[i]
+		for _, rec := range timed {
+			if rec.Timestamp.Before(a.Start) || !rec.Timestamp.Before(a.End) || (rec.Level != "ERROR" && rec.Level != "FATAL") {
+				continue
+			}
+			if a.Context == "" {
+				a.Context = rec.Message
+				a.Location = Position{Start: rec.Line, End: rec.Line}
+			}
+			if rec.Line < a.Location.Start {
+				a.Location.Start = rec.Line
+			}
+			if rec.Line > a.Location.End {
+				a.Location.End = rec.Line
+			}
+		}
+	}
+	return anomalies
+}
+
+// newTemplateAnomalies reports templates first seen after the warmup.
+// Severity is 3 for templates with errors, 2 with warnings and 1 otherwise.
+func newTemplateAnomalies(templates []LogTemplate, ids []int) []LogAnomaly {
+	byID := make(map[int]LogTemplate, len(templates))
+	for _, t := range templates {
+		byID[t.ID] = t
+	}
+
+	anomalies := make([]LogAnomaly, 0, len(ids))
+	for _, id := range ids {
+		t := byID[id]
+		severity := 1.0
+		switch {
+		case t.Levels["ERROR"]+t.Levels["FATAL"] > 0:
+			severity = 3
+		case t.Levels["WARN"] > 0:
+			severity = 2
+		}
+		anomalies = append(anomalies, LogAnomaly{
+			TextAnomaly: TextAnomaly{
+				Type:        "new_template",
+				Location:    Position{Start: t.FirstLine, End: t.LastLine},
+				Description: fmt.Sprintf("New message template seen %d times", t.Count),
+				Severity:    severity,
+				Context:     t.Template,
+			},
+			Start: t.FirstSeen,
+			End:   t.LastSeen,
+		})
+	}
+	return anomalies
+}
+
+// detectLogGaps reports silences of at least minGap, which defaults to 20
+// times the median spacing between records but never less than one bucket
+func detectLogGaps(timed []LogRecord, bucket, minGap time.Duration) []LogAnomaly {
+	if len(timed) < 2 {
+		return nil
+	}
+	if minGap <= 0 {
+		deltas := make([]time.Duration, len(timed)-1)
+		for i := 1; i < len(timed); i++ {
+			deltas[i-1] = timed[i].Timestamp.Sub(timed[i-1].Timestamp)
+		}
+		sort.Slice(deltas, func(i, j int) bool { return deltas[i] < deltas[j] })
+		minGap = 20 * deltas[len(deltas)/2]
+		if minGap < bucket {
+			minGap = bucket
+		}
+	}
+
+	var anomalies []LogAnomaly
+	for i := 1; i < len(timed); i++ {
+		prev, next := timed[i-1], timed[i]
+		gap := next.Timestamp.Sub(prev.Timestamp)
+		if gap < minGap {
+			continue
+		}
+		anomalies = append(anomalies, LogAnomaly{
+			TextAnomaly: TextAnomaly{
+				Type:        "gap",
+				Location:    Position{Start: prev.Line, End: next.Line},
+				Description: fmt.Sprintf("No log records for %s", gap),
+				Severity:    float64(gap) / float64(minGap),
+				Context:     prev.Message,
+			},
+			Start: prev.Timestamp,
+			End:   next.Timestamp,
+		})
+	}
+	return anomalies
+}
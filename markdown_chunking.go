@@ -0,0 +1,527 @@
+package textlib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MarkdownBlock is a top-level structural element of a Markdown document
+type MarkdownBlock struct {
+	Type     string   // "heading", "code", "table", "list", "blockquote", "thematic_break", "paragraph"
+	Text     string   // Source text of the block
+	Start    int      // Byte offset of the block in the document
+	End      int      // Byte offset just past the block
+	Level    int      // Heading level (1-6)
+	Language string   // Info string of a fenced code block
+	Headings []string // Heading breadcrumb in effect at this block, including the block itself for headings
+}
+
+var (
+	mdATXHeading     = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdFenceOpen      = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	mdSetextLine     = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdTableDelimiter = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdListItem       = regexp.MustCompile(`^ {0,3}([-*+]|\d{1,9}[.)])([ \t]+|$)`)
+	mdBlockquote     = regexp.MustCompile(`^ {0,3}>`)
+	mdThematicBreak  = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+)
+
+type mdLine struct {
+	text  string // without the line terminator
+	start int
+	end   int // offset past the line terminator
+}
+
+func splitMarkdownLines(text string) []mdLine {
+	var lines []mdLine
+	for start := 0; start < len(text); {
+		end := strings.IndexByte(text[start:], '\n')
+		next := len(text)
+		if end >= 0 {
+			next = start + end + 1
+			end = start + end
+		} else {
+			end = len(text)
+		}
+		lines = append(lines, mdLine{
+			text:  strings.TrimRight(text[start:end], "\r"),
+			start: start,
+			end:   next,
+		})
+		start = next
+	}
+	return lines
+}
+
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// ParseMarkdownBlocks splits a Markdown document into headings, fenced code
+// blocks, tables, lists, blockquotes and paragraphs, tracking the heading
+// breadcrumb of every block
+func ParseMarkdownBlocks(text string) []MarkdownBlock {
+	return parseMarkdownBlocks(text, 0, nil)
+}
+
+// markdownHeading is an open heading on the breadcrumb stack
+type markdownHeading struct {
+	level int
+	title string
+}
+
+// pushMarkdownHeading closes headings at or below level and opens title
+func pushMarkdownHeading(stack []markdownHeading, level int, title string) []markdownHeading {
+	for len(stack) > 0 && stack[len(stack)-1].level >= level {
+		stack = stack[:len(stack)-1]
+	}
+	return append(stack, markdownHeading{level, title})
+}
+
+// parseMarkdownBlocks parses text found at byte offset base of a larger
+// document whose open headings are stack
+func parseMarkdownBlocks(text string, base int, open []markdownHeading) []MarkdownBlock {
+	lines := splitMarkdownLines(text)
+	blocks := []MarkdownBlock{}
+
+	stack := append([]markdownHeading{}, open...)
+	breadcrumb := func() []string {
+		path := make([]string, len(stack))
+		for i, h := range stack {
+			path[i] = h.title
+		}
+		return path
+	}
+
+	add := func(blockType string, from, to int) *MarkdownBlock {
+		start, end := lines[from].start, lines[to-1].end
+		blocks = append(blocks, MarkdownBlock{
+			Type:     blockType,
+			Text:     strings.TrimRight(text[start:end], "\r\n"),
+			Start:    base + start,
+			End:      base + start + len(strings.TrimRight(text[start:end], "\r\n")),
+			Headings: breadcrumb(),
+		})
+		return &blocks[len(blocks)-1]
+	}
+	pushHeading := func(block *MarkdownBlock, level int, title string) {
+		stack = pushMarkdownHeading(stack, level, title)
+		block.Level = level
+		block.Headings = breadcrumb()
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i].text
+
+		switch {
+		case isBlankLine(line):
+			i++
+
+		case mdFenceOpen.MatchString(line):
+			m := mdFenceOpen.FindStringSubmatch(line)
+			fence := m[2]
+			j := i + 1
+			for j < len(lines) {
+				if isClosingFence(lines[j].text, fence) {
+					j++
+					break
+				}
+				j++
+			}
+			block := add("code", i, j)
+			if fields := strings.Fields(m[3]); len(fields) > 0 {
+				block.Language = fields[0]
+			}
+			i = j
+
+		case mdATXHeading.MatchString(line):
+			m := mdATXHeading.FindStringSubmatch(line)
+			block := add("heading", i, i+1)
+			pushHeading(block, len(m[1]), strings.TrimSpace(m[2]))
+			i++
+
+		case mdThematicBreak.MatchString(line):
+			add("thematic_break", i, i+1)
+			i++
+
+		case isTableStart(lines, i):
+			j := i + 2
+			for j < len(lines) && !isBlankLine(lines[j].text) && strings.Contains(lines[j].text, "|") {
+				j++
+			}
+			add("table", i, j)
+			i = j
+
+		case mdBlockquote.MatchString(line):
+			j := i + 1
+			for j < len(lines) && !isBlankLine(lines[j].text) {
+				j++
+			}
+			add("blockquote", i, j)
+			i = j
+
+		case mdListItem.MatchString(line):
+			j := i + 1
+			for j < len(lines) {
+				next := lines[j].text
+				if isBlankLine(next) {
+					// A blank line continues the list only if more items or indented content follow
+					k := j + 1
+					for k < len(lines) && isBlankLine(lines[k].text) {
+						k++
+					}
+					if k < len(lines) && (mdListItem.MatchString(lines[k].text) || isIndented(lines[k].text)) {
+						j = k
+						continue
+					}
+					break
+				}
+				if mdListItem.MatchString(next) || isIndented(next) || !startsMarkdownBlock(lines, j) {
+					j++
+					continue
+				}
+				break
+			}
+			add("list", i, j)
+			i = j
+
+		default:
+			// Paragraph, possibly underlined as a setext heading
+			j := i + 1
+			setext := 0
+			for j < len(lines) && !isBlankLine(lines[j].text) {
+				if m := mdSetextLine.FindStringSubmatch(lines[j].text); m != nil {
+					setext = 2
+					if m[1][0] == '=' {
+						setext = 1
+					}
+					j++
+					break
+				}
+				if startsMarkdownBlock(lines, j) {
+					break
+				}
+				j++
+			}
+			if setext > 0 {
+				block := add("heading", i, j)
+				title := strings.TrimSpace(text[lines[i].start:lines[j-2].end])
+				pushHeading(block, setext, strings.Join(strings.Fields(title), " "))
+			} else {
+				add("paragraph", i, j)
+			}
+			i = j
+		}
+	}
+
+	return blocks
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+}
+
+// isClosingFence reports whether line closes a code block opened by fence
+func isClosingFence(line, fence string) bool {
+	closing := strings.TrimSpace(line)
+	return strings.HasPrefix(closing, fence[:3]) && strings.Trim(closing, fence[:1]) == "" && len(closing) >= len(fence)
+}
+
+func isTableStart(lines []mdLine, i int) bool {
+	return i+1 < len(lines) && strings.Contains(lines[i].text, "|") &&
+		strings.Contains(lines[i+1].text, "-") && mdTableDelimiter.MatchString(lines[i+1].text)
+}
+
+// startsMarkdownBlock reports whether line i interrupts a paragraph
+func startsMarkdownBlock(lines []mdLine, i int) bool {
+	line := lines[i].text
+	return mdFenceOpen.MatchString(line) || mdATXHeading.MatchString(line) ||
+		mdBlockquote.MatchString(line) || mdThematicBreak.MatchString(line) ||
+		mdListItem.MatchString(line) || isTableStart(lines, i)
+}
+
+// markdownUnit is a block or a piece of a block that chunking treats atomically
+type markdownUnit struct {
+	block  *MarkdownBlock
+	text   string
+	start  int
+	end    int
+	gap    string // source text between the previous unit and this one
+	forced bool   // piece of a code block or table that had to be split
+}
+
+// chunkByMarkdown packs Markdown blocks into chunks of at most
+// strategy.MaxTokens tokens. Every heading starts a new chunk, oversized
+// paragraphs and lists are split at sentence or item boundaries, and code
+// blocks and tables are kept whole unless they exceed strategy.MaxCharacters.
+// Chunk offsets are byte offsets into text.
+func chunkByMarkdown(text string, strategy ChunkingStrategy) []TextChunk {
+	chunks := []TextChunk{}
+	chunker := newMarkdownChunker(strategy)
+
+	prevEnd := 0
+	blocks := ParseMarkdownBlocks(text)
+	for bi := range blocks {
+		chunks = append(chunks, chunker.add(&blocks[bi], text[prevEnd:blocks[bi].Start])...)
+		prevEnd = blocks[bi].End
+	}
+	chunks = append(chunks, chunker.flush()...)
+
+	return chunks
+}
+
+// markdownChunker packs blocks into chunks as they arrive, so the same
+// rules serve ChunkText and ChunkTextStream
+type markdownChunker struct {
+	strategy     ChunkingStrategy
+	maxTokens    int
+	current      []markdownUnit
+	tokens       int
+	onlyHeadings bool
+	count        int
+}
+
+func newMarkdownChunker(strategy ChunkingStrategy) *markdownChunker {
+	maxTokens := strategy.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 512
+	}
+	return &markdownChunker{strategy: strategy, maxTokens: maxTokens, onlyHeadings: true}
+}
+
+// add takes the next block, preceded in the source by gap, and returns the
+// chunks it completes
+func (c *markdownChunker) add(block *MarkdownBlock, gap string) []TextChunk {
+	var done []TextChunk
+
+	if block.Type == "heading" {
+		// Keep consecutive headings together with the content that follows
+		if !c.onlyHeadings {
+			done = append(done, c.flush()...)
+		}
+		c.current = append(c.current, markdownUnit{block: block, text: block.Text, start: block.Start, end: block.End, gap: gap})
+		c.tokens += c.strategy.countTokens(block.Text)
+		return done
+	}
+
+	units := splitMarkdownBlock(block, c.strategy, c.maxTokens)
+	for i := range units {
+		if i == 0 {
+			units[i].gap = gap
+		} else if !units[i].forced {
+			units[i].gap = block.Text[units[i-1].end-block.Start : units[i].start-block.Start]
+		}
+	}
+
+	for _, unit := range units {
+		if unit.forced {
+			// Forced pieces are self-contained and always stand alone
+			done = append(done, c.flush()...)
+			done = append(done, newMarkdownChunk([]markdownUnit{unit}, c.strategy.countTokens(unit.text), c.count))
+			c.count++
+			continue
+		}
+
+		tokens := c.strategy.countTokens(unit.text)
+		if c.tokens+tokens > c.maxTokens && !c.onlyHeadings {
+			done = append(done, c.flush()...)
+		}
+		c.current = append(c.current, unit)
+		c.tokens += tokens
+		c.onlyHeadings = false
+	}
+
+	return done
+}
+
+// flush closes the chunk being built, if any
+func (c *markdownChunker) flush() []TextChunk {
+	if len(c.current) == 0 {
+		return nil
+	}
+	chunk := newMarkdownChunk(c.current, c.tokens, c.count)
+	c.count++
+	c.current = nil
+	c.tokens = 0
+	c.onlyHeadings = true
+	return []TextChunk{chunk}
+}
+
+// splitMarkdownBlock breaks a block that exceeds maxTokens into units
+func splitMarkdownBlock(block *MarkdownBlock, strategy ChunkingStrategy, maxTokens int) []markdownUnit {
+	whole := []markdownUnit{{block: block, text: block.Text, start: block.Start, end: block.End}}
+	if strategy.countTokens(block.Text) <= maxTokens {
+		return whole
+	}
+
+	switch block.Type {
+	case "paragraph", "blockquote":
+		var units []markdownUnit
+		for _, sent := range sentenceTokens(block.Text) {
+			units = append(units, markdownUnit{
+				block: block,
+				text:  sent.Text,
+				start: block.Start + sent.Position.Start,
+				end:   block.Start + sent.Position.End,
+			})
+		}
+		return units
+
+	case "list":
+		var units []markdownUnit
+		lines := splitMarkdownLines(block.Text)
+		from := 0
+		for i := 1; i <= len(lines); i++ {
+			// Top-level items start a new unit; nested lines stay with their item
+			if i < len(lines) && !(mdListItem.MatchString(lines[i].text) && !isIndented(lines[i].text)) {
+				continue
+			}
+			start, end := lines[from].start, lines[i-1].end
+			piece := strings.TrimRight(block.Text[start:end], "\r\n")
+			units = append(units, markdownUnit{block: block, text: piece, start: block.Start + start, end: block.Start + start + len(piece)})
+			from = i
+		}
+		return units
+
+	case "code", "table":
+		if strategy.MaxCharacters <= 0 || len(block.Text) <= strategy.MaxCharacters {
+			return whole
+		}
+		return forceSplitMarkdownBlock(block, strategy, maxTokens)
+	}
+
+	return whole
+}
+
+// forceSplitMarkdownBlock splits a code block or table by lines, repeating
+// the fence or header rows so that each piece still renders on its own
+func forceSplitMarkdownBlock(block *MarkdownBlock, strategy ChunkingStrategy, maxTokens int) []markdownUnit {
+	lines := strings.Split(block.Text, "\n")
+	offsets := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		offsets[i] = offsets[i-1] + len(lines[i-1]) + 1
+	}
+
+	var header []string
+	var footer string
+	body := lines
+	if block.Type == "code" {
+		header = lines[:1]
+		body = lines[1:]
+		fence := mdFenceOpen.FindStringSubmatch(header[0])[2]
+		if len(body) > 0 && isClosingFence(body[len(body)-1], fence) {
+			footer = body[len(body)-1]
+			body = body[:len(body)-1]
+		}
+		if footer == "" {
+			footer = fence
+		}
+	} else if len(lines) >= 2 {
+		header = lines[:2]
+		body = lines[2:]
+	}
+
+	limit := strategy.MaxCharacters
+	var units []markdownUnit
+	var piece []string
+	pieceLen := 0
+	pieceFrom := len(header) // index in lines of the piece's first line
+
+	emit := func() {
+		if len(piece) == 0 {
+			return
+		}
+		parts := append(append([]string{}, header...), piece...)
+		if footer != "" {
+			parts = append(parts, footer)
+		}
+		// Each piece spans its own lines; the first and last also take
+		// in the block's header and closing fence
+		last := pieceFrom + len(piece) - 1
+		units = append(units, markdownUnit{
+			block:  block,
+			text:   strings.Join(parts, "\n"),
+			start:  block.Start + offsets[pieceFrom],
+			end:    block.Start + offsets[last] + len(lines[last]),
+			forced: true,
+		})
+		pieceFrom += len(piece)
+		piece = nil
+		pieceLen = 0
+	}
+
+	overhead := len(strings.Join(header, "\n")) + len(footer) + 2
+	for _, line := range body {
+		candidate := append(append(append([]string{}, header...), piece...), line)
+		tooLong := pieceLen+len(line)+1+overhead > limit ||
+			strategy.countTokens(strings.Join(candidate, "\n")) > maxTokens
+		if tooLong && len(piece) > 0 {
+			emit()
+		}
+		piece = append(piece, line)
+		pieceLen += len(line) + 1
+	}
+	emit()
+
+	if len(units) > 0 {
+		units[0].start = block.Start
+		units[len(units)-1].end = block.End
+	}
+	return units
+}
+
+func newMarkdownChunk(units []markdownUnit, tokens, index int) TextChunk {
+	first, last := units[0], units[len(units)-1]
+
+	// The breadcrumb is the path at the deepest heading or first content in the chunk
+	headings := first.block.Headings
+	blockTypes := []string{}
+	seen := map[*MarkdownBlock]bool{}
+	languages := []string{}
+	for _, u := range units {
+		if u.block.Type == "heading" {
+			headings = u.block.Headings
+		}
+		if !seen[u.block] {
+			seen[u.block] = true
+			blockTypes = append(blockTypes, u.block.Type)
+			if u.block.Language != "" {
+				languages = append(languages, u.block.Language)
+			}
+		}
+	}
+
+	// Rebuild the source span from the units and the text between them
+	var sb strings.Builder
+	for i, u := range units {
+		if i > 0 {
+			sb.WriteString(u.gap)
+		}
+		sb.WriteString(u.block.Text[u.start-u.block.Start : u.end-u.block.Start])
+	}
+	content := strings.TrimSpace(sb.String())
+	if first.forced {
+		content = first.text
+	}
+
+	metadata := map[string]interface{}{
+		"headings":    append([]string{}, headings...),
+		"breadcrumb":  strings.Join(headings, " > "),
+		"block_types": blockTypes,
+	}
+	if len(languages) > 0 {
+		metadata["code_languages"] = languages
+	}
+	if first.forced {
+		metadata["forced_split"] = true
+	}
+
+	return TextChunk{
+		Content:     content,
+		Index:       index,
+		StartOffset: first.start,
+		EndOffset:   last.end,
+		TokenCount:  tokens,
+		Metadata:    metadata,
+	}
+}
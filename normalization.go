@@ -0,0 +1,166 @@
+package textlib
+
+import (
+	"strings"
+	"sync"
+)
+
+// NormalizationOptions controls how tokens are normalized before counting
+type NormalizationOptions struct {
+	Mode     string // "none", "lowercase", "stem", "lemma"
+	Language string // ISO 639-1 code such as "en"; "auto" detects it from the text
+}
+
+var (
+	normalizerMu     sync.RWMutex
+	stemmers         = map[string]Stemmer{"en": EnglishStemmer{}}
+	lemmatizers      = map[string]Lemmatizer{"en": NewEnglishLemmatizer()}
+	languageDetector = detectFunctionWordLanguage
+)
+
+// functionWords are frequent short words that identify a language
+var functionWords = []struct {
+	language string
+	words    map[string]bool
+}{
+	{"en", wordSet("the and of to is in that it was for with are this be have")},
+	{"es", wordSet("el los las y que es por con una para del se lo como pero")},
+	{"fr", wordSet("le les et est une des du que pour dans pas sur au avec il")},
+	{"de", wordSet("der die das und ist nicht ein eine mit auf den zu sich von dem")},
+	{"it", wordSet("il lo gli e che è di un una per con non sono della nel")},
+	{"pt", wordSet("o os as e que é um uma para com não do da em dos")},
+	{"nl", wordSet("de het een en van is dat niet op te zijn met voor die er")},
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// RegisterStemmer makes a stemmer available for a language code
+func RegisterStemmer(language string, stemmer Stemmer) {
+	normalizerMu.Lock()
+	defer normalizerMu.Unlock()
+	stemmers[strings.ToLower(language)] = stemmer
+}
+
+// RegisterLemmatizer makes a lemmatizer available for a language code
+func RegisterLemmatizer(language string, lemmatizer Lemmatizer) {
+	normalizerMu.Lock()
+	defer normalizerMu.Unlock()
+	lemmatizers[strings.ToLower(language)] = lemmatizer
+}
+
+// SetLanguageDetector installs the function used to resolve Language "auto".
+// The detector returns an ISO 639-1 code; unknown codes fall back to English.
+// The default counts common function words of English, Spanish, French,
+// German, Italian, Portuguese and Dutch.
+func SetLanguageDetector(detector func(text string) string) {
+	normalizerMu.Lock()
+	defer normalizerMu.Unlock()
+	languageDetector = detector
+}
+
+// NormalizeTokens lowercases, stems or lemmatizes tokens according to opts.
+// The result has the same length as tokens so positions stay aligned.
+func NormalizeTokens(tokens []string, opts NormalizationOptions) []string {
+	normalized := make([]string, len(tokens))
+	mode := opts.Mode
+
+	if mode == "" || mode == "none" {
+		copy(normalized, tokens)
+		return normalized
+	}
+
+	language := resolveNormalizationLanguage(strings.Join(tokens, " "), opts.Language)
+
+	switch mode {
+	case "stem":
+		stemmer := lookupStemmer(language)
+		for i, tok := range tokens {
+			normalized[i] = stemmer.Stem(strings.ToLower(tok))
+		}
+	case "lemma":
+		lemmatizer := lookupLemmatizer(language)
+		tags := make([]string, len(tokens))
+		if language == "en" {
+			tags = DefaultPOSTagger().TagWords(tokens)
+		}
+		for i, tok := range tokens {
+			normalized[i] = lemmatizer.Lemmatize(strings.ToLower(tok), tags[i])
+		}
+	default:
+		for i, tok := range tokens {
+			normalized[i] = strings.ToLower(tok)
+		}
+	}
+
+	return normalized
+}
+
+func resolveNormalizationLanguage(text, language string) string {
+	language = strings.ToLower(language)
+	if language == "" {
+		return "en"
+	}
+	if language != "auto" {
+		return language
+	}
+
+	normalizerMu.RLock()
+	detector := languageDetector
+	normalizerMu.RUnlock()
+
+	if detector == nil {
+		return "en"
+	}
+	if detected := strings.ToLower(detector(text)); detected != "" {
+		return detected
+	}
+	return "en"
+}
+
+// detectFunctionWordLanguage returns the language whose function words
+// occur most often in text, or "" when none occur
+func detectFunctionWordLanguage(text string) string {
+	counts := make([]int, len(functionWords))
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		w = strings.Trim(w, ".,;:!?¡¿\"'()")
+		for i, fw := range functionWords {
+			if fw.words[w] {
+				counts[i]++
+			}
+		}
+	}
+	best := -1
+	for i, n := range counts {
+		if n > 0 && (best < 0 || n > counts[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return functionWords[best].language
+}
+
+func lookupStemmer(language string) Stemmer {
+	normalizerMu.RLock()
+	defer normalizerMu.RUnlock()
+	if stemmer, ok := stemmers[language]; ok {
+		return stemmer
+	}
+	return stemmers["en"]
+}
+
+func lookupLemmatizer(language string) Lemmatizer {
+	normalizerMu.RLock()
+	defer normalizerMu.RUnlock()
+	if lemmatizer, ok := lemmatizers[language]; ok {
+		return lemmatizer
+	}
+	return lemmatizers["en"]
+}
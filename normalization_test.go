@@ -0,0 +1,111 @@
+package textlib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTokens(t *testing.T) {
+	tokens := []string{"The", "dogs", "were", "running"}
+
+	tests := []struct {
+		mode     string
+		expected []string
+	}{
+		{"", []string{"The", "dogs", "were", "running"}},
+		{"lowercase", []string{"the", "dogs", "were", "running"}},
+		{"stem", []string{"the", "dog", "were", "run"}},
+		{"lemma", []string{"the", "dog", "be", "run"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			got := NormalizeTokens(tokens, NormalizationOptions{Mode: tt.mode})
+			if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+type upperStemmer struct{}
+
+func (upperStemmer) Stem(word string) string { return strings.ToUpper(word) }
+
+func TestNormalizationLanguages(t *testing.T) {
+	RegisterStemmer("xx", upperStemmer{})
+	previous := languageDetector
+	SetLanguageDetector(func(text string) string { return "xx" })
+	defer SetLanguageDetector(previous)
+
+	got := NormalizeTokens([]string{"word"}, NormalizationOptions{Mode: "stem", Language: "auto"})
+	if got[0] != "WORD" {
+		t.Errorf("Expected detected language stemmer to be used, got %q", got[0])
+	}
+
+	// Unknown languages fall back to English
+	got = NormalizeTokens([]string{"running"}, NormalizationOptions{Mode: "stem", Language: "zz"})
+	if got[0] != "run" {
+		t.Errorf("Expected English fallback, got %q", got[0])
+	}
+}
+
+func TestDetectFunctionWordLanguage(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"The cat is in the house and it was happy", "en"},
+		{"El perro y los gatos que viven con una familia", "es"},
+		{"Le chat est dans la maison avec les enfants", "fr"},
+		{"Der Hund und die Katze sind nicht mit dem Auto", "de"},
+		{"Het huis van de man is niet groot", "nl"},
+		{"xyzzy plugh", ""},
+	}
+
+	for _, tt := range tests {
+		if got := detectFunctionWordLanguage(tt.text); got != tt.expected {
+			t.Errorf("detectFunctionWordLanguage(%q) = %q, expected %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestStatisticsWithNormalization(t *testing.T) {
+	text := "The dog runs. The dogs ran. A dog is running."
+
+	plain := CalculateTextStatistics(text)
+	lemma := CalculateTextStatisticsWithOptions(text, NormalizationOptions{Mode: "lemma"})
+
+	if lemma.WordCount != plain.WordCount {
+		t.Errorf("Expected word count to be unchanged, got %d and %d", plain.WordCount, lemma.WordCount)
+	}
+	if lemma.UniqueWordCount >= plain.UniqueWordCount {
+		t.Errorf("Expected fewer unique words after lemmatization, got %d vs %d",
+			lemma.UniqueWordCount, plain.UniqueWordCount)
+	}
+	// "dog" and "run" tie at 3, in no particular order
+	counts := make(map[string]int)
+	for _, wf := range lemma.MostFrequentWords {
+		counts[wf.Word] = wf.Count
+	}
+	if counts["dog"] != 3 || counts["run"] != 3 {
+		t.Errorf("Expected 'dog' and 'run' to count 3, got %v", lemma.MostFrequentWords)
+	}
+}
+
+func TestSimilarityWithNormalization(t *testing.T) {
+	text1 := "The runner runs quickly"
+	text2 := "The runners running quickly"
+
+	plain := CalculateSimilarity(text1, text2)
+	stemmed := CalculateSimilarityWithOptions(text1, text2, NormalizationOptions{Mode: "stem"})
+
+	if stemmed.JaccardIndex <= plain.JaccardIndex {
+		t.Errorf("Expected stemming to raise Jaccard index, got %.2f vs %.2f",
+			stemmed.JaccardIndex, plain.JaccardIndex)
+	}
+	if stemmed.CosineSimilarity <= plain.CosineSimilarity {
+		t.Errorf("Expected stemming to raise cosine similarity, got %.2f vs %.2f",
+			stemmed.CosineSimilarity, plain.CosineSimilarity)
+	}
+}
@@ -0,0 +1,263 @@
+package textlib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\n"
+	b := "one\nTWO\nthree\nfour\nfive\n"
+
+	for _, algorithm := range []string{"myers", "patience"} {
+		ops := LineDiff(a, b, algorithm)
+
+		var oldText, newText strings.Builder
+		for _, op := range ops {
+			if op.Type != "insert" {
+				oldText.WriteString(op.Text)
+			}
+			if op.Type != "delete" {
+				newText.WriteString(op.Text)
+			}
+		}
+		if oldText.String() != a || newText.String() != b {
+			t.Errorf("%s: operations do not reproduce both texts: %+v", algorithm, ops)
+		}
+
+		changed := 0
+		for _, op := range ops {
+			if op.Type != "equal" {
+				changed += op.Length
+			}
+		}
+		if changed != 3 {
+			t.Errorf("%s: expected 3 changed lines, got %d", algorithm, changed)
+		}
+	}
+}
+
+func TestPatienceDiffAnchorsUniqueLines(t *testing.T) {
+	// Myers happily matches the braces; patience keeps the functions whole
+	a := "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n"
+	b := "func a() {\n\treturn 1\n}\n\nfunc c() {\n\treturn 3\n}\n\nfunc b() {\n\treturn 2\n}\n"
+
+	ops := LineDiff(a, b, "patience")
+	var inserted []string
+	for _, op := range ops {
+		if op.Type == "delete" {
+			t.Errorf("Expected no deletions, got %q", op.Text)
+		}
+		if op.Type == "insert" {
+			inserted = append(inserted, op.Text)
+		}
+	}
+	if len(inserted) != 1 || !strings.Contains(inserted[0], "func c()") {
+		t.Errorf("Expected func c to be one insertion, got %q", inserted)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nb\nC\nd\ne\nf\ng\nh\ni\nj\nk"
+
+	got := UnifiedDiff(a, b, DiffOptions{Context: 1, FromFile: "old.txt", ToFile: "new.txt"})
+	want := `--- old.txt
++++ new.txt
+@@ -2,3 +2,3 @@
+ b
+-c
++C
+ d
+@@ -10 +10,2 @@
+ j
++k
+\ No newline at end of file
+`
+	if got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if UnifiedDiff(a, a, DiffOptions{}) != "" {
+		t.Error("Expected no output for identical texts")
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	a := "alpha\nbeta\ngamma\ndelta\nepsilon\nzeta\neta\ntheta\n"
+	b := "alpha\nBETA\ngamma\ndelta\nepsilon\nzeta\neta\ntheta\niota\n"
+	patch := UnifiedDiff(a, b, DiffOptions{})
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{"exact", a, b, false},
+		{
+			"shifted by new lines",
+			"preface\npreface\n" + a,
+			"preface\npreface\n" + b,
+			false,
+		},
+		{
+			"fuzzy context",
+			strings.Replace(a, "alpha", "ALPHA", 1),
+			strings.Replace(b, "alpha", "ALPHA", 1),
+			false,
+		},
+		{
+			"whitespace drift",
+			strings.Replace(a, "gamma", "  gamma", 1),
+			strings.Replace(b, "gamma", "  gamma", 1),
+			false,
+		},
+		{"missing target", "something else entirely\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyPatch(tt.text, patch)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyPatchRoundTrip(t *testing.T) {
+	pairs := [][2]string{
+		{"", "new file\n"},
+		{"only line\n", ""},
+		{"x\ny\nz", "x\ny\nz\n"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "1\n2\nthree\n4\n5\n6\n7\n8\n9\nten\n11\n12\n13\n"},
+	}
+	for _, p := range pairs {
+		patch := UnifiedDiff(p[0], p[1], DiffOptions{})
+		got, err := ApplyPatch(p[0], patch)
+		if err != nil {
+			t.Errorf("Patch %q failed: %v", patch, err)
+			continue
+		}
+		if got != p[1] {
+			t.Errorf("Expected %q, got %q", p[1], got)
+		}
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	for _, patch := range []string{"", "--- a\n+++ b\n", "@@ -x +1 @@\n", "@@ -1 +1 @@\n?bad\n"} {
+		if _, err := ParsePatch(patch); err == nil {
+			t.Errorf("Expected an error for %q", patch)
+		}
+	}
+}
+
+func TestApplyPatchFarOffsets(t *testing.T) {
+	// A far off but valid start is searched from the end of the text
+	got, err := ApplyPatch("a\nb\n", "@@ -1000000000 +1 @@\n-b\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "a\n" {
+		t.Errorf("Expected %q, got %q", "a\n", got)
+	}
+
+	for _, patch := range []string{
+		"@@ -2000000000 +1 @@\n-a\n",
+		"@@ -99999999999 +1 @@\n-a\n",
+		"@@ --5 +1 @@\n-a\n",
+		"@@ -1,-1 +1 @@\n-a\n",
+		"@@ -1 +1,99999999999 @@\n-a\n",
+	} {
+		if _, err := ApplyPatch("a\n", patch); err == nil {
+			t.Errorf("Expected an error for %q", patch)
+		}
+	}
+}
+
+func TestMergeThreeWay(t *testing.T) {
+	base := "The cat sat on the mat.\nIt was warm.\nThe end.\n"
+
+	tests := []struct {
+		name      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+		resolved  int
+	}{
+		{
+			"separate lines",
+			"The cat sat on the rug.\nIt was warm.\nThe end.\n",
+			"The cat sat on the mat.\nIt was warm.\nThe very end.\n",
+			"The cat sat on the rug.\nIt was warm.\nThe very end.\n",
+			0, 0,
+		},
+		{
+			"same change on both sides",
+			"The dog sat on the mat.\nIt was warm.\nThe end.\n",
+			"The dog sat on the mat.\nIt was warm.\nThe end.\n",
+			"The dog sat on the mat.\nIt was warm.\nThe end.\n",
+			0, 0,
+		},
+		{
+			"different words in one line",
+			"The dog sat on the mat.\nIt was warm.\nThe end.\n",
+			"The cat sat on the rug.\nIt was warm.\nThe end.\n",
+			"The dog sat on the rug.\nIt was warm.\nThe end.\n",
+			0, 1,
+		},
+		{
+			"same word changed differently",
+			"The dog sat on the mat.\nIt was warm.\nThe end.\n",
+			"The bird sat on the mat.\nIt was warm.\nThe end.\n",
+			"<<<<<<< ours\nThe dog sat on the mat.\n=======\nThe bird sat on the mat.\n>>>>>>> theirs\nIt was warm.\nThe end.\n",
+			1, 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MergeThreeWay(base, tt.ours, tt.theirs, MergeOptions{})
+			if result.Text != tt.want {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.want, result.Text)
+			}
+			if len(result.Conflicts) != tt.conflicts {
+				t.Errorf("Expected %d conflicts, got %d", tt.conflicts, len(result.Conflicts))
+			}
+			if result.Resolved != tt.resolved {
+				t.Errorf("Expected %d resolved regions, got %d", tt.resolved, result.Resolved)
+			}
+		})
+	}
+}
+
+func TestMergeThreeWayConflictDetails(t *testing.T) {
+	base := "a\nb\nc\n"
+	ours := "a\nours\nc\n"
+	theirs := "a\ntheirs\nc\n"
+
+	result := MergeThreeWay(base, ours, theirs, MergeOptions{ShowBase: true, OursLabel: "HEAD", TheirsLabel: "feature", LinesOnly: true})
+	want := "a\n<<<<<<< HEAD\nours\n||||||| base\nb\n=======\ntheirs\n>>>>>>> feature\nc\n"
+	if result.Text != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, result.Text)
+	}
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %d", len(result.Conflicts))
+	}
+	c := result.Conflicts[0]
+	if c.Line != 2 || c.Base != "b\n" || c.Ours != "ours\n" || c.Theirs != "theirs\n" {
+		t.Errorf("Unexpected conflict %+v", c)
+	}
+}
@@ -0,0 +1,269 @@
+package textlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func newTestIndex(t *testing.T, opts IndexOptions) *Index {
+	t.Helper()
+	index := NewIndex(opts)
+	docs := []struct {
+		id    string
+		title string
+		body  string
+	}{
+		{"go", "The Go Programming Language", "Go is an open source programming language that makes it simple to build secure, scalable systems."},
+		{"rust", "Rust", "Rust is a systems programming language focused on memory safety without a garbage collector."},
+		{"python", "Python", "Python is a programming language that lets you work quickly. Python has a large standard library."},
+		{"garden", "Gardening Tips", "Water tomato plants deeply. Tomatoes need warm weather and plenty of sun."},
+		{"box", "Setup", "The tool works out of the box on every platform."},
+	}
+	for _, d := range docs {
+		if err := index.Add(d.id, map[string]string{"title": d.title, "body": d.body}); err != nil {
+			t.Fatalf("Add(%s): %v", d.id, err)
+		}
+	}
+	return index
+}
+
+func hitIDs(hits []SearchHit) string {
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestIndexSearch(t *testing.T) {
+	index := newTestIndex(t, IndexOptions{})
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"single term", "tomato", "garden"},
+		{"ranking by term frequency", "python", "python"},
+		{"field restriction", "title:rust", "rust"},
+		{"excluded term", "programming -rust -python", "go"},
+		{"required term", "+memory language", "rust"},
+		{"phrase", `"memory safety"`, "rust"},
+		{"phrase across stop words", `"out of the box"`, "box"},
+		{"phrase in wrong order", `"safety memory"`, ""},
+		{"stop words only", "the of", ""},
+		{"no match", "haskell", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hitIDs(index.Search(tt.query, SearchOptions{})); got != tt.expected {
+				t.Errorf("Search(%q) = %q, expected %q", tt.query, got, tt.expected)
+			}
+		})
+	}
+
+	hits := index.Search("programming language", SearchOptions{Limit: 2})
+	if len(hits) != 2 {
+		t.Fatalf("Expected limit of 2 hits, got %d", len(hits))
+	}
+	if hits[0].Score < hits[1].Score {
+		t.Errorf("Expected hits sorted by score, got %f then %f", hits[0].Score, hits[1].Score)
+	}
+}
+
+func TestIndexFieldBoosts(t *testing.T) {
+	index := NewIndex(IndexOptions{FieldBoosts: map[string]float64{"title": 5}})
+	index.Add("a", map[string]string{"title": "Notes", "body": "A short note about databases and databases."})
+	index.Add("b", map[string]string{"title": "Databases", "body": "A short note about storage."})
+
+	hits := index.Search("databases", SearchOptions{})
+	if hitIDs(hits) != "b,a" {
+		t.Errorf("Expected boosted title match first, got %s", hitIDs(hits))
+	}
+	if hits[0].Field != "title" {
+		t.Errorf("Expected best field title, got %s", hits[0].Field)
+	}
+}
+
+func TestIndexStemming(t *testing.T) {
+	index := newTestIndex(t, IndexOptions{Normalization: NormalizationOptions{Mode: "stem", Language: "en"}})
+
+	if got := hitIDs(index.Search("tomatoes", SearchOptions{})); got != "garden" {
+		t.Errorf("Expected stemmed query to match, got %q", got)
+	}
+	if got := hitIDs(index.Search("collectors", SearchOptions{})); got != "rust" {
+		t.Errorf("Expected plural to match singular, got %q", got)
+	}
+}
+
+func TestIndexSnippets(t *testing.T) {
+	index := newTestIndex(t, IndexOptions{})
+
+	hits := index.Search("garbage collector", SearchOptions{SnippetWords: 4})
+	if len(hits) != 1 {
+		t.Fatalf("Expected 1 hit, got %d", len(hits))
+	}
+	snippet := hits[0].Snippet
+	if !strings.Contains(snippet, "<mark>garbage</mark> <mark>collector</mark>") {
+		t.Errorf("Expected highlighted terms, got %q", snippet)
+	}
+	if !strings.HasPrefix(snippet, "…") {
+		t.Errorf("Expected leading ellipsis for a mid-text snippet, got %q", snippet)
+	}
+
+	hits = index.Search("tomato", SearchOptions{HighlightPre: "[", HighlightPost: "]"})
+	if !strings.Contains(hits[0].Snippet, "Water [tomato] plants") {
+		t.Errorf("Expected custom highlight markers, got %q", hits[0].Snippet)
+	}
+}
+
+func TestIndexReplaceAndDelete(t *testing.T) {
+	index := newTestIndex(t, IndexOptions{})
+
+	if err := index.Add("garden", map[string]string{"body": "Pruning roses in winter."}); err != nil {
+		t.Fatal(err)
+	}
+	if got := hitIDs(index.Search("tomato", SearchOptions{})); got != "" {
+		t.Errorf("Expected replaced document to drop old terms, got %q", got)
+	}
+	if got := hitIDs(index.Search("roses", SearchOptions{})); got != "garden" {
+		t.Errorf("Expected replaced document to match new terms, got %q", got)
+	}
+
+	if !index.Delete("rust") || index.Delete("rust") {
+		t.Error("Expected Delete to succeed once")
+	}
+	if got := hitIDs(index.Search("memory", SearchOptions{})); got != "" {
+		t.Errorf("Expected deleted document to be gone, got %q", got)
+	}
+	if index.Len() != 4 {
+		t.Errorf("Expected 4 documents, got %d", index.Len())
+	}
+
+	if err := index.Add("", map[string]string{"body": "x"}); err == nil {
+		t.Error("Expected an error for an empty ID")
+	}
+}
+
+func TestIndexPersistence(t *testing.T) {
+	index := newTestIndex(t, IndexOptions{FieldBoosts: map[string]float64{"title": 2}})
+	index.Delete("python")
+
+	path := filepath.Join(t.TempDir(), "search.idx")
+	if err := index.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadIndex(path)
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+
+	if loaded.Len() != index.Len() {
+		t.Errorf("Expected %d documents, got %d", index.Len(), loaded.Len())
+	}
+	for _, query := range []string{"programming language", `"memory safety"`, "title:rust", "python", "tomato"} {
+		want, got := index.Search(query, SearchOptions{}), loaded.Search(query, SearchOptions{})
+		if hitIDs(want) != hitIDs(got) {
+			t.Errorf("Query %q: expected %s after reload, got %s", query, hitIDs(want), hitIDs(got))
+			continue
+		}
+		for i := range want {
+			if want[i].Snippet != got[i].Snippet || want[i].Score-got[i].Score > 1e-9 || got[i].Score-want[i].Score > 1e-9 {
+				t.Errorf("Query %q hit %d differs after reload: %+v vs %+v", query, i, want[i], got[i])
+			}
+		}
+	}
+
+	if _, err := ReadIndex(bytes.NewReader([]byte("nope"))); err == nil {
+		t.Error("Expected an error for a file without the index header")
+	}
+	var buf bytes.Buffer
+	index.WriteTo(&buf)
+	if _, err := ReadIndex(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Error("Expected an error for a truncated index")
+	}
+}
+
+func TestReadIndexCorruptLength(t *testing.T) {
+	// Header, version, K1 and B, then a string claiming 1 GiB
+	data := []byte(indexMagic)
+	for _, v := range []uint64{indexVersion, 0, 0, indexMaxLength} {
+		data = binary.AppendUvarint(data, v)
+	}
+	data = append(data, "short"...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := ReadIndex(bytes.NewReader(data)); err == nil {
+		t.Error("Expected an error for a length past the end of the input")
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("Expected a small allocation for a corrupt length, got %d bytes", allocated)
+	}
+}
+
+func TestReadIndexCorruptPostings(t *testing.T) {
+	// One document with a single-word "body" field, then the postings of
+	// the term "x" as document and position deltas
+	encode := func(postings ...uint64) []byte {
+		data := []byte(indexMagic)
+		str := func(s string) {
+			data = binary.AppendUvarint(data, uint64(len(s)))
+			data = append(data, s...)
+		}
+		data = binary.AppendUvarint(data, indexVersion)
+		data = binary.AppendUvarint(data, math.Float64bits(1.2))
+		data = binary.AppendUvarint(data, math.Float64bits(0.75))
+		str("")
+		str("")
+		data = binary.AppendUvarint(data, 0) // KeepStopWords
+		data = binary.AppendUvarint(data, 0) // FieldBoosts
+		data = binary.AppendUvarint(data, 1)
+		str("a")
+		data = binary.AppendUvarint(data, 1)
+		str("body")
+		str("x")
+		data = binary.AppendUvarint(data, 1)
+		data = binary.AppendUvarint(data, 1)
+		str("body")
+		data = binary.AppendUvarint(data, 1)
+		str("x")
+		for _, v := range postings {
+			data = binary.AppendUvarint(data, v)
+		}
+		return data
+	}
+
+	valid, err := ReadIndex(bytes.NewReader(encode(1, 0, 1, 0)))
+	if err != nil {
+		t.Fatalf("ReadIndex failed on a valid file: %v", err)
+	}
+	if hits := valid.Search("x", SearchOptions{}); len(hits) != 1 {
+		t.Errorf("Expected one hit from the valid file, got %+v", hits)
+	}
+
+	tests := []struct {
+		name     string
+		postings []uint64
+	}{
+		{"document delta wraps negative", []uint64{1, math.MaxUint32, 1, 0}},
+		{"document delta past int32", []uint64{1, math.MaxInt32 + 1, 1, 0}},
+		{"missing document", []uint64{1, 1, 1, 0}},
+		{"position delta wraps negative", []uint64{1, 0, 2, 5, math.MaxUint32}},
+		{"position past int32", []uint64{1, 0, 2, math.MaxInt32, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadIndex(bytes.NewReader(encode(tt.postings...))); err == nil {
+				t.Error("Expected an error for corrupt postings")
+			}
+		})
+	}
+}
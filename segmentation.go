@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Segment represents a text segment with metadata
@@ -21,13 +22,15 @@ type Segment struct {
 
 // ChunkingStrategy defines how text should be chunked
 type ChunkingStrategy struct {
-	Method          string  // "token", "sentence", "paragraph", "semantic", "sliding"
+	Method          string  // "token", "sentence", "paragraph", "semantic", "sliding", "markdown"
 	MaxTokens       int
 	MaxCharacters   int
 	Overlap         int     // For sliding window
 	PreserveWords   bool    // Don't split mid-word
 	PreserveSentences bool  // Don't split mid-sentence
 	SemanticThreshold float64 // For semantic chunking
+	TokenCounter    TokenCounter // Counts MaxTokens and Overlap; nil counts whitespace-separated words
+	Embedder        Embedder     // Scores semantic coherence; nil uses lexical overlap
 }
 
 // SegmentationResult contains segmented text
@@ -53,8 +56,27 @@ type TextChunk struct {
 	OverlapEnd   int
 }
 
+// SegmentOptions configures SegmentTextWithOptions
+type SegmentOptions struct {
+	TokenCounter TokenCounter // Counts each segment's TokenCount; nil counts whitespace-separated words
+	Embedder     Embedder     // Scores "topic", "semantic" and "texttiling" boundaries; nil uses lexical overlap
+}
+
 // SegmentText performs intelligent text segmentation
 func SegmentText(text string, method string) *SegmentationResult {
+	return SegmentTextWithOptions(text, method, SegmentOptions{})
+}
+
+// SegmentTextWithCounter segments text like SegmentText and reports each
+// segment's TokenCount using counter, such as a BPETokenizer for the target
+// model. A nil counter counts whitespace-separated words.
+func SegmentTextWithCounter(text string, method string, counter TokenCounter) *SegmentationResult {
+	return SegmentTextWithOptions(text, method, SegmentOptions{TokenCounter: counter})
+}
+
+// SegmentTextWithOptions segments text like SegmentText, counting tokens
+// with opts.TokenCounter and comparing sentences with opts.Embedder
+func SegmentTextWithOptions(text string, method string, opts SegmentOptions) *SegmentationResult {
 	result := &SegmentationResult{
 		Segments:           []Segment{},
 		SegmentationMethod: method,
@@ -67,17 +89,25 @@ func SegmentText(text string, method string) *SegmentationResult {
 	case "section":
 		result.Segments = segmentBySections(text)
 	case "topic":
-		result.Segments = segmentByTopics(text)
+		result.Segments = segmentByTopics(text, opts.Embedder)
 	case "semantic":
-		result.Segments = segmentBySemantic(text)
+		result.Segments = segmentBySemantic(text, opts.Embedder)
 	case "sentence":
 		result.Segments = segmentBySentences(text)
 	case "fixed":
 		result.Segments = segmentByFixedSize(text, 1000) // 1000 chars default
+	case "texttiling":
+		result.Segments = SegmentByTextTiling(text, TextTilingOptions{Embedder: opts.Embedder})
 	default:
 		result.Segments = segmentByParagraphs(text)
 	}
 	
+	if opts.TokenCounter != nil {
+		for i := range result.Segments {
+			result.Segments[i].TokenCount = opts.TokenCounter.CountTokens(result.Segments[i].Text)
+		}
+	}
+	
 	// Calculate statistics
 	result.TotalSegments = len(result.Segments)
 	if result.TotalSegments > 0 {
@@ -105,6 +135,8 @@ func ChunkText(text string, strategy ChunkingStrategy) []TextChunk {
 		return chunkBySemantic(text, strategy)
 	case "sliding":
 		return chunkBySliding(text, strategy)
+	case "markdown":
+		return chunkByMarkdown(text, strategy)
 	default:
 		return chunkByTokens(text, strategy)
 	}
@@ -245,7 +277,7 @@ func isSectionHeader(line string) bool {
 	return false
 }
 
-func segmentByTopics(text string) []Segment {
+func segmentByTopics(text string, embedder Embedder) []Segment {
 	segments := []Segment{}
 	sentences := SplitIntoSentences(text)
 	
@@ -260,10 +292,12 @@ func segmentByTopics(text string) []Segment {
 	
 	for i := 1; i < len(sentences); i++ {
 		// Check topic similarity
-		similarity := calculateTopicSimilarity(
-			strings.Join(currentTopic, " "),
-			sentences[i],
-		)
+		var similarity float64
+		if embedder != nil {
+			similarity = embeddedSimilarity(embedder, currentTopic, sentences[i])
+		} else {
+			similarity = calculateTopicSimilarity(strings.Join(currentTopic, " "), sentences[i])
+		}
 		
 		if similarity < 0.3 { // Topic shift threshold
 			// Create segment for current topic
@@ -355,7 +389,7 @@ func extractKeyTerms(text string) []string {
 	return terms
 }
 
-func segmentBySemantic(text string) []Segment {
+func segmentBySemantic(text string, embedder Embedder) []Segment {
 	segments := []Segment{}
 	sentences := SplitIntoSentences(text)
 	
@@ -370,7 +404,12 @@ func segmentBySemantic(text string) []Segment {
 	
 	for i := 1; i < len(sentences); i++ {
 		// Calculate semantic coherence
-		coherence := calculateSemanticCoherence(currentGroup, sentences[i])
+		var coherence float64
+		if embedder != nil {
+			coherence = embeddedSimilarity(embedder, currentGroup, sentences[i])
+		} else {
+			coherence = calculateSemanticCoherence(currentGroup, sentences[i])
+		}
 		
 		if coherence < 0.5 { // Semantic break threshold
 			// Create segment
@@ -479,35 +518,54 @@ func segmentBySentences(text string) []Segment {
 
 func segmentByFixedSize(text string, size int) []Segment {
 	segments := []Segment{}
+	if size <= 0 {
+		return segments
+	}
+	
+	// Size is measured in grapheme clusters so that multi-byte characters,
+	// combining marks and emoji sequences are never split
+	clusters := graphemeSpans(text)
+	byteOffset := func(i int) int {
+		if i >= len(clusters) {
+			return len(text)
+		}
+		return clusters[i].Start
+	}
+	isSpace := func(i int) bool {
+		r, _ := utf8.DecodeRuneInString(text[clusters[i].Start:])
+		return unicode.IsSpace(r)
+	}
 	
-	for i := 0; i < len(text); i += size {
-		end := min(i+size, len(text))
+	for i := 0; i < len(clusters); {
+		end := min(i+size, len(clusters))
 		
 		// Try to break at word boundary
-		if end < len(text) && !unicode.IsSpace(rune(text[end])) {
+		if end < len(clusters) && !isSpace(end) {
 			// Look for nearest space
 			for j := end; j > i && j > end-50; j-- {
-				if unicode.IsSpace(rune(text[j])) {
+				if isSpace(j) {
 					end = j
 					break
 				}
 			}
 		}
 		
-		segmentText := text[i:end]
+		start, stop := byteOffset(i), byteOffset(end)
+		segmentText := text[start:stop]
 		segment := Segment{
 			Text:       segmentText,
-			Start:      i,
-			End:        end,
+			Start:      start,
+			End:        stop,
 			Type:       "fixed",
-			TokenCount: len(strings.Fields(segmentText)),
-			CharCount:  len(segmentText),
+			TokenCount: len(Tokenize(segmentText)),
+			CharCount:  end - i,
 			Metadata: map[string]interface{}{
 				"size": size,
 			},
 		}
 		
 		segments = append(segments, segment)
+		i = end
 	}
 	
 	return segments
@@ -528,36 +586,85 @@ func chunkByTokens(text string, strategy ChunkingStrategy) []TextChunk {
 		chunkSize = 100 // Default
 	}
 	
-	for i := 0; i < len(words); i += chunkSize - strategy.Overlap {
-		end := min(i+chunkSize, len(words))
-		
-		chunkWords := words[i:end]
+	costs := wordTokenCosts(words, strategy)
+	for _, w := range tokenWindows(costs, chunkSize, strategy.Overlap) {
+		chunkWords := words[w.start:w.end]
 		chunkText := strings.Join(chunkWords, " ")
 		
 		chunk := TextChunk{
 			Content:     chunkText,
 			Index:       len(chunks),
-			StartOffset: i,
-			EndOffset:   end,
-			TokenCount:  len(chunkWords),
+			StartOffset: w.start,
+			EndOffset:   w.end,
+			TokenCount:  w.tokens,
 			Metadata:    map[string]interface{}{},
 		}
 		
 		// Mark overlap region
-		if i > 0 && strategy.Overlap > 0 {
+		if w.start > 0 && strategy.Overlap > 0 {
 			chunk.OverlapStart = 0
-			chunk.OverlapEnd = min(strategy.Overlap, len(chunkWords))
+			chunk.OverlapEnd = min(w.overlapWords, len(chunkWords))
 		}
 		
 		chunks = append(chunks, chunk)
+	}
+	
+	return chunks
+}
+
+// tokenWindow is a run of words [start, end) holding tokens model tokens
+type tokenWindow struct {
+	start, end   int
+	tokens       int
+	overlapWords int // words shared with the previous window
+}
+
+// wordTokenCosts returns the number of tokens each word adds to a chunk.
+// Words after the first are measured with their leading space, which is how
+// BPE tokenizers see them inside running text.
+func wordTokenCosts(words []string, strategy ChunkingStrategy) []int {
+	costs := make([]int, len(words))
+	for i, word := range words {
+		if strategy.TokenCounter == nil {
+			costs[i] = 1
+		} else if i == 0 {
+			costs[i] = strategy.TokenCounter.CountTokens(word)
+		} else {
+			costs[i] = strategy.TokenCounter.CountTokens(" " + word)
+		}
+	}
+	return costs
+}
+
+// tokenWindows packs words into windows of at most size tokens, each
+// starting far enough back to repeat about overlap tokens of the previous one
+func tokenWindows(costs []int, size, overlap int) []tokenWindow {
+	var windows []tokenWindow
+	start, shared := 0, 0
+	
+	for start < len(costs) {
+		end, tokens := start, 0
+		for end < len(costs) && (end == start || tokens+costs[end] <= size) {
+			tokens += costs[end]
+			end++
+		}
+		windows = append(windows, tokenWindow{start: start, end: end, tokens: tokens, overlapWords: shared})
 		
-		// Stop if we've processed all words
-		if end >= len(words) {
+		if end >= len(costs) {
 			break
 		}
+		
+		// Step back from the end until the overlap budget is used
+		next, overlapTokens := end, 0
+		for next > start+1 && overlap > 0 && overlapTokens+costs[next-1] <= overlap {
+			next--
+			overlapTokens += costs[next]
+		}
+		shared = end - next
+		start = next
 	}
 	
-	return chunks
+	return windows
 }
 
 func chunkBySentences(text string, strategy ChunkingStrategy) []TextChunk {
@@ -573,7 +680,7 @@ func chunkBySentences(text string, strategy ChunkingStrategy) []TextChunk {
 	chunkStart := 0
 	
 	for i, sent := range sentences {
-		sentTokens := len(strings.Fields(sent))
+		sentTokens := strategy.countTokens(sent)
 		
 		// Check if adding this sentence would exceed limit
 		if currentTokens+sentTokens > strategy.MaxTokens && len(currentChunk) > 0 {
@@ -633,7 +740,7 @@ func chunkByParagraphs(text string, strategy ChunkingStrategy) []TextChunk {
 	chunkStart := 0
 	
 	for i, para := range paragraphs {
-		paraTokens := len(strings.Fields(para))
+		paraTokens := strategy.countTokens(para)
 		
 		// Check if adding this paragraph would exceed limit
 		if currentTokens+paraTokens > strategy.MaxTokens && len(currentChunk) > 0 {
@@ -693,7 +800,7 @@ func chunkBySemantic(text string, strategy ChunkingStrategy) []TextChunk {
 	currentGroup := []string{sentences[0]}
 	
 	for i := 1; i < len(sentences); i++ {
-		coherence := calculateSemanticCoherence(currentGroup, sentences[i])
+		coherence := strategy.semanticCoherence(currentGroup, sentences[i])
 		
 		if coherence < strategy.SemanticThreshold {
 			// Start new group
@@ -715,7 +822,7 @@ func chunkBySemantic(text string, strategy ChunkingStrategy) []TextChunk {
 	
 	for _, group := range semanticGroups {
 		groupText := strings.Join(group, " ")
-		groupTokens := len(strings.Fields(groupText))
+		groupTokens := strategy.countTokens(groupText)
 		
 		if currentTokens+groupTokens > strategy.MaxTokens && len(currentChunk) > 0 {
 			// Create chunk
@@ -768,23 +875,21 @@ func chunkBySliding(text string, strategy ChunkingStrategy) []TextChunk {
 		windowSize = 100
 	}
 	
-	stride := windowSize - strategy.Overlap
-	if stride <= 0 {
-		stride = 1
-	}
+	// Keep at least one token of progress per window
+	overlap := min(strategy.Overlap, windowSize-1)
+	stride := windowSize - overlap
 	
-	for i := 0; i < len(words); i += stride {
-		end := min(i+windowSize, len(words))
-		
-		chunkWords := words[i:end]
+	costs := wordTokenCosts(words, strategy)
+	for _, w := range tokenWindows(costs, windowSize, overlap) {
+		chunkWords := words[w.start:w.end]
 		chunkText := strings.Join(chunkWords, " ")
 		
 		chunk := TextChunk{
 			Content:     chunkText,
 			Index:       len(chunks),
-			StartOffset: i,
-			EndOffset:   end,
-			TokenCount:  len(chunkWords),
+			StartOffset: w.start,
+			EndOffset:   w.end,
+			TokenCount:  w.tokens,
 			Metadata: map[string]interface{}{
 				"window": windowSize,
 				"stride": stride,
@@ -792,17 +897,12 @@ func chunkBySliding(text string, strategy ChunkingStrategy) []TextChunk {
 		}
 		
 		// Mark overlap regions
-		if i > 0 {
+		if w.start > 0 {
 			chunk.OverlapStart = 0
-			chunk.OverlapEnd = min(strategy.Overlap, len(chunkWords))
+			chunk.OverlapEnd = min(w.overlapWords, len(chunkWords))
 		}
 		
 		chunks = append(chunks, chunk)
-		
-		// Stop if we've reached the end
-		if end >= len(words) {
-			break
-		}
 	}
 	
 	return chunks
@@ -812,7 +912,13 @@ func chunkBySliding(text string, strategy ChunkingStrategy) []TextChunk {
 
 // OptimalChunkSize calculates optimal chunk size based on text characteristics
 func OptimalChunkSize(text string, targetChunks int) int {
-	tokens := len(strings.Fields(text))
+	return OptimalChunkSizeWithCounter(text, targetChunks, nil)
+}
+
+// OptimalChunkSizeWithCounter calculates the optimal chunk size in tokens
+// as counted by counter; a nil counter counts whitespace-separated words
+func OptimalChunkSizeWithCounter(text string, targetChunks int, counter TokenCounter) int {
+	tokens := ChunkingStrategy{TokenCounter: counter}.countTokens(text)
 	
 	if targetChunks <= 0 {
 		targetChunks = 10
@@ -0,0 +1,1493 @@
+package textlib
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RuleSet is a compiled set of YARA-style detection rules. A rule names
+// text, hex and regex strings and a condition over their matches:
+//
+//	rule Dropper : trojan {
+//	    meta:
+//	        severity = "high"
+//	    strings:
+//	        $mz = { 4D 5A }
+//	        $url = /https?:\/\/[a-z0-9.]+\/payload/ nocase
+//	        $api1 = "VirtualAllocEx"
+//	        $api2 = "WriteProcessMemory" wide ascii
+//	    condition:
+//	        $mz at 0 and $url and all of ($api*) and filesize < 2MB
+//	}
+//
+// Text strings accept the nocase, wide, ascii, fullword and private
+// modifiers; hex strings accept ?? and nibble wildcards, jumps such as
+// [2-4] or [8-] and alternatives (AB | CD EF); regexes take /i and /s.
+// Conditions support and, or, not, arithmetic, comparisons, filesize,
+// "N of" / all / any / none of them or ($a*), $a at N, $a in (N..M),
+// #a counts, @a[i] offsets, !a[i] lengths, uint8/16/32(be) and int8/16/32(be)
+// reads, and references to rules defined earlier. Private rules are
+// evaluated but not reported; a failing global rule suppresses all matches.
+type RuleSet struct {
+	rules    []signatureRule
+	strings  []ruleString
+	variants []patternVariant
+	regexes  []int // indices of regex strings
+	literals []regexLiteral
+	atoms    *ahoCorasick // every atom and literal, lowercased
+	lookback int
+}
+
+// RuleMatch is a rule whose condition held for the scanned data
+type RuleMatch struct {
+	Rule    string
+	Tags    []string
+	Meta    map[string]string
+	Strings []StringMatch // matches of the rule's non-private strings
+}
+
+// StringMatch is one occurrence of a rule string
+type StringMatch struct {
+	Identifier string
+	Offset     int64
+	Length     int
+	Data       []byte // the matched bytes, truncated to 512
+}
+
+type signatureRule struct {
+	name      string
+	tags      []string
+	meta      map[string]string
+	private   bool
+	global    bool
+	strings   []int
+	condition ruleExpr
+}
+
+type ruleString struct {
+	id       string
+	regex    *regexp.Regexp
+	fullword bool
+	private  bool
+	dedupe   bool // several variants or atom positions may report the same offset
+	gated    bool // the regex only runs near occurrences of its literals
+	width    int  // longest match of the regex, capped at ruleRegexLimit
+}
+
+//go:embed data/common_rules.yar
+var commonRuleSource string
+
+var (
+	commonRules     *RuleSet
+	commonRulesOnce sync.Once
+)
+
+// GetCommonVirusRules returns the built-in rule set, which covers the
+// EICAR test file, shellcode, process injection and packed executables
+func GetCommonVirusRules() *RuleSet {
+	commonRulesOnce.Do(func() {
+		rs, err := CompileRules(commonRuleSource)
+		if err != nil {
+			panic(fmt.Sprintf("textlib: invalid built-in rules: %v", err))
+		}
+		commonRules = rs
+	})
+	return commonRules
+}
+
+// CompileRules compiles rule sources; later sources may reference rules
+// defined in earlier ones
+func CompileRules(sources ...string) (*RuleSet, error) {
+	rs := &RuleSet{}
+	names := make(map[string]int)
+	for _, src := range sources {
+		if err := rs.parse(src, names); err != nil {
+			return nil, err
+		}
+	}
+	rs.build()
+	return rs, nil
+}
+
+// LoadRules compiles rule files; a directory contributes its .yar and
+// .yara files in name order
+func LoadRules(paths ...string) (*RuleSet, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if ext := strings.ToLower(filepath.Ext(e.Name())); !e.IsDir() && (ext == ".yar" || ext == ".yara") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	rs := &RuleSet{}
+	names := make(map[string]int)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := rs.parse(string(src), names); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	rs.build()
+	return rs, nil
+}
+
+// Rules returns the names of the rules in the set, in definition order
+func (rs *RuleSet) Rules() []string {
+	names := make([]string, len(rs.rules))
+	for i, r := range rs.rules {
+		names[i] = r.name
+	}
+	return names
+}
+
+// Scan streams r through the rules. Offset conditions such as uint16(0)
+// read from r when it is an io.ReaderAt and from the first 64 KiB otherwise.
+func (rs *RuleSet) Scan(r io.Reader) ([]RuleMatch, error) {
+	return scanRules(rs, r)
+}
+
+// ScanFile scans the file at path
+func (rs *RuleSet) ScanFile(path string) ([]RuleMatch, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return scanRules(rs, file)
+}
+
+// ScanForVirusesWithRules scans a file and reports matching rules as threats.
+// Severity, description and type come from the rule's meta section, and
+// the position is the first string match.
+func ScanForVirusesWithRules(filePath string, rules *RuleSet) ([]Threat, error) {
+	matches, err := rules.ScanFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	threats := make([]Threat, 0, len(matches))
+	for _, m := range matches {
+		threats = append(threats, m.threat())
+	}
+	return threats, nil
+}
+
+func (m RuleMatch) threat() Threat {
+	t := Threat{
+		Name:        m.Rule,
+		Type:        m.Meta["type"],
+		Severity:    m.Meta["severity"],
+		Description: m.Meta["description"],
+		Position:    -1,
+		Confidence:  1.0,
+	}
+	if t.Type == "" {
+		t.Type = "virus"
+	}
+	if t.Severity == "" {
+		t.Severity = "medium"
+	}
+	if t.Description == "" {
+		t.Description = "Matched rule " + m.Rule
+	}
+	if c, err := strconv.ParseFloat(m.Meta["confidence"], 64); err == nil {
+		t.Confidence = c
+	}
+	for _, s := range m.Strings {
+		if t.Position < 0 || s.Offset < t.Position {
+			t.Position = s.Offset
+		}
+	}
+	if t.Position < 0 {
+		t.Position = 0
+	}
+	return t
+}
+
+// signatureRuleSet wraps plain byte signatures as one-string rules
+func signatureRuleSet(signatures []VirusSignature) *RuleSet {
+	rs := &RuleSet{}
+	for _, sig := range signatures {
+		if len(sig.Pattern) == 0 {
+			continue
+		}
+		idx := rs.addText("$", sig.Pattern, false, false, true)
+		rs.rules = append(rs.rules, signatureRule{
+			name:      sig.Name,
+			meta:      map[string]string{"description": sig.Description, "severity": sig.Severity},
+			strings:   []int{idx},
+			condition: &stringCondition{str: idx},
+		})
+	}
+	rs.build()
+	return rs
+}
+
+// addText compiles a text string into its ascii and wide variants
+func (rs *RuleSet) addText(id string, text []byte, nocase, wide, ascii bool) int {
+	idx := len(rs.strings)
+	rs.strings = append(rs.strings, ruleString{id: id, dedupe: ascii && wide})
+	encode := func(wide bool) []patternToken {
+		var tokens []patternToken
+		for _, b := range text {
+			if nocase {
+				b = lowerASCII(b)
+			}
+			tokens = append(tokens, patternToken{kind: tokenByte, value: b, mask: 0xff, fold: nocase})
+			if wide {
+				tokens = append(tokens, patternToken{kind: tokenByte, mask: 0xff})
+			}
+		}
+		return tokens
+	}
+	if ascii {
+		rs.variants = append(rs.variants, newPatternVariants(idx, encode(false), false)...)
+	}
+	if wide {
+		rs.variants = append(rs.variants, newPatternVariants(idx, encode(true), true)...)
+	}
+	return idx
+}
+
+// build creates the automaton over every variant's atom. It runs over
+// lowercased data, so an atom hit is only a candidate: the variant's
+// tokens or the literal's regex decide whether the case matched.
+func (rs *RuleSet) build() {
+	atoms := make(map[int][]byte)
+	count := make([]int, len(rs.strings))
+	rs.lookback = 2
+	for i, v := range rs.variants {
+		atoms[i] = lowerASCIIBytes(v.atom)
+		count[v.str]++
+		if v.preMin != v.preMax {
+			rs.strings[v.str].dedupe = true
+		}
+		if n := len(v.atom) + v.preMax + 2; n > rs.lookback {
+			rs.lookback = n
+		}
+	}
+	for i, lit := range rs.literals {
+		atoms[len(rs.variants)+i] = lowerASCIIBytes(lit.text)
+	}
+	for i, n := range count {
+		if n > 1 {
+			rs.strings[i].dedupe = true
+		}
+	}
+	if len(atoms) > 0 {
+		rs.atoms = newAhoCorasick(atoms)
+	}
+}
+
+// evaluate runs the conditions over the results of a finished scan
+func (rs *RuleSet) evaluate(s *ruleScan, data io.ReaderAt) []RuleMatch {
+	ctx := &ruleContext{scan: s, data: data, results: make([]bool, len(rs.rules))}
+	var matches []RuleMatch
+	for i, rule := range rs.rules {
+		v, ok := rule.condition.eval(ctx)
+		ctx.results[i] = ok && v != 0
+		if !ctx.results[i] {
+			if rule.global {
+				return nil
+			}
+			continue
+		}
+		if rule.private {
+			continue
+		}
+		m := RuleMatch{Rule: rule.name, Tags: rule.tags, Meta: rule.meta}
+		for _, idx := range rule.strings {
+			if !rs.strings[idx].private {
+				m.Strings = append(m.Strings, s.matches[idx]...)
+			}
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// Rule source lexer
+
+const (
+	ruleTokEOF = iota
+	ruleTokIdent
+	ruleTokText
+	ruleTokHex
+	ruleTokRegex
+	ruleTokNumber
+	ruleTokString // $a, $a*, $
+	ruleTokCount  // #a
+	ruleTokOffset // @a
+	ruleTokLength // !a
+	ruleTokPunct
+)
+
+type ruleToken struct {
+	kind  int
+	text  string
+	flags string // regex modifiers
+	num   int64
+	line  int
+}
+
+type ruleLexer struct {
+	src  string
+	pos  int
+	line int
+	prev ruleToken
+}
+
+func (l *ruleLexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *ruleLexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return l.errorf("unterminated comment")
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func isRuleIdentByte(c byte) bool {
+	return c == '_' || isAlnumByte(c)
+}
+
+func (l *ruleLexer) next() (ruleToken, error) {
+	if err := l.skipSpace(); err != nil {
+		return ruleToken{}, err
+	}
+	tok, err := l.scan()
+	tok.line = l.line
+	l.prev = tok
+	return tok, err
+}
+
+func (l *ruleLexer) scan() (ruleToken, error) {
+	if l.pos >= len(l.src) {
+		return ruleToken{kind: ruleTokEOF}, nil
+	}
+	start := l.pos
+	c := l.src[l.pos]
+	ident := func() string {
+		for l.pos < len(l.src) && isRuleIdentByte(l.src[l.pos]) {
+			l.pos++
+		}
+		return l.src[start:l.pos]
+	}
+
+	switch {
+	case c == '{' && l.prev.kind == ruleTokPunct && l.prev.text == "=":
+		end := strings.IndexByte(l.src[l.pos:], '}')
+		if end < 0 {
+			return ruleToken{}, l.errorf("unterminated hex string")
+		}
+		body := l.src[l.pos+1 : l.pos+end]
+		l.line += strings.Count(body, "\n")
+		l.pos += end + 1
+		return ruleToken{kind: ruleTokHex, text: body}, nil
+
+	case c == '"':
+		var sb strings.Builder
+		for l.pos++; l.pos < len(l.src); l.pos++ {
+			c := l.src[l.pos]
+			switch {
+			case c == '"':
+				l.pos++
+				return ruleToken{kind: ruleTokText, text: sb.String()}, nil
+			case c == '\n':
+				return ruleToken{}, l.errorf("newline in string")
+			case c == '\\' && l.pos+1 < len(l.src):
+				l.pos++
+				switch e := l.src[l.pos]; e {
+				case 'n':
+					sb.WriteByte('\n')
+				case 'r':
+					sb.WriteByte('\r')
+				case 't':
+					sb.WriteByte('\t')
+				case 'x':
+					if l.pos+2 >= len(l.src) {
+						return ruleToken{}, l.errorf("bad \\x escape")
+					}
+					v, err := strconv.ParseUint(l.src[l.pos+1:l.pos+3], 16, 8)
+					if err != nil {
+						return ruleToken{}, l.errorf("bad \\x escape")
+					}
+					sb.WriteByte(byte(v))
+					l.pos += 2
+				case '"', '\\':
+					sb.WriteByte(e)
+				default:
+					return ruleToken{}, l.errorf("unknown escape \\%c", e)
+				}
+			default:
+				sb.WriteByte(c)
+			}
+		}
+		return ruleToken{}, l.errorf("unterminated string")
+
+	case c == '/':
+		for l.pos++; l.pos < len(l.src) && l.src[l.pos] != '/'; l.pos++ {
+			switch l.src[l.pos] {
+			case '\\':
+				l.pos++
+			case '\n':
+				return ruleToken{}, l.errorf("newline in regex")
+			}
+		}
+		if l.pos >= len(l.src) {
+			return ruleToken{}, l.errorf("unterminated regex")
+		}
+		body := strings.ReplaceAll(l.src[start+1:l.pos], `\/`, "/")
+		l.pos++
+		flagStart := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == 'i' || l.src[l.pos] == 's') {
+			l.pos++
+		}
+		return ruleToken{kind: ruleTokRegex, text: body, flags: l.src[flagStart:l.pos]}, nil
+
+	case c == '$' || c == '#' || c == '@' || (c == '!' && l.pos+1 < len(l.src) && isRuleIdentByte(l.src[l.pos+1])):
+		l.pos++
+		for l.pos < len(l.src) && isRuleIdentByte(l.src[l.pos]) {
+			l.pos++
+		}
+		if c == '$' && l.pos < len(l.src) && l.src[l.pos] == '*' {
+			l.pos++
+		}
+		kind := map[byte]int{'$': ruleTokString, '#': ruleTokCount, '@': ruleTokOffset, '!': ruleTokLength}[c]
+		return ruleToken{kind: kind, text: "$" + l.src[start+1:l.pos]}, nil
+
+	case c >= '0' && c <= '9':
+		text := ident()
+		mult := int64(1)
+		if strings.HasSuffix(text, "KB") {
+			text, mult = strings.TrimSuffix(text, "KB"), 1024
+		} else if strings.HasSuffix(text, "MB") {
+			text, mult = strings.TrimSuffix(text, "MB"), 1024*1024
+		}
+		v, err := strconv.ParseInt(text, 0, 64)
+		if err != nil {
+			return ruleToken{}, l.errorf("bad number %q", l.src[start:l.pos])
+		}
+		return ruleToken{kind: ruleTokNumber, text: l.src[start:l.pos], num: v * mult}, nil
+
+	case isRuleIdentByte(c):
+		return ruleToken{kind: ruleTokIdent, text: ident()}, nil
+	}
+
+	for _, p := range []string{"..", "==", "!=", "<=", ">=", "<<", ">>"} {
+		if strings.HasPrefix(l.src[l.pos:], p) {
+			l.pos += 2
+			return ruleToken{kind: ruleTokPunct, text: p}, nil
+		}
+	}
+	if strings.ContainsRune("(){}[]:,=<>+-*\\%&|^~", rune(c)) {
+		l.pos++
+		return ruleToken{kind: ruleTokPunct, text: string(c)}, nil
+	}
+	return ruleToken{}, l.errorf("unexpected character %q", c)
+}
+
+// Rule source parser
+
+var ruleKeywords = map[string]bool{
+	"rule": true, "private": true, "global": true, "meta": true, "strings": true, "condition": true,
+	"and": true, "or": true, "not": true, "of": true, "them": true, "all": true, "any": true, "none": true,
+	"at": true, "in": true, "true": true, "false": true, "filesize": true,
+}
+
+var ruleIntReaders = map[string]struct {
+	size              int
+	signed, bigEndian bool
+}{
+	"uint8": {1, false, false}, "uint16": {2, false, false}, "uint32": {4, false, false},
+	"int8": {1, true, false}, "int16": {2, true, false}, "int32": {4, true, false},
+	"uint16be": {2, false, true}, "uint32be": {4, false, true},
+	"int16be": {2, true, true}, "int32be": {4, true, true},
+}
+
+type ruleParser struct {
+	lex   *ruleLexer
+	tok   ruleToken
+	rs    *RuleSet
+	names map[string]int
+	rule  *signatureRule
+	ids   map[string]int
+}
+
+func (rs *RuleSet) parse(src string, names map[string]int) error {
+	p := &ruleParser{lex: &ruleLexer{src: src, line: 1}, rs: rs, names: names}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	for p.tok.kind != ruleTokEOF {
+		if err := p.parseRule(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ruleParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.tok.line, fmt.Sprintf(format, args...))
+}
+
+func (p *ruleParser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *ruleParser) isIdent(word string) bool {
+	return p.tok.kind == ruleTokIdent && p.tok.text == word
+}
+
+func (p *ruleParser) isPunct(text string) bool {
+	return p.tok.kind == ruleTokPunct && p.tok.text == text
+}
+
+func (p *ruleParser) describe() string {
+	if p.tok.kind == ruleTokEOF {
+		return "end of input"
+	}
+	return strconv.Quote(p.tok.text)
+}
+
+// expect consumes a punctuation token or keyword
+func (p *ruleParser) expect(text string) error {
+	if !p.isPunct(text) && !p.isIdent(text) {
+		return p.errorf("expected %q, got %s", text, p.describe())
+	}
+	return p.advance()
+}
+
+func (p *ruleParser) parseRule() error {
+	rule := signatureRule{meta: map[string]string{}}
+	for p.isIdent("private") || p.isIdent("global") {
+		rule.private = rule.private || p.tok.text == "private"
+		rule.global = rule.global || p.tok.text == "global"
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	if err := p.expect("rule"); err != nil {
+		return err
+	}
+	if p.tok.kind != ruleTokIdent || ruleKeywords[p.tok.text] {
+		return p.errorf("expected rule name, got %s", p.describe())
+	}
+	rule.name = p.tok.text
+	if _, dup := p.names[rule.name]; dup {
+		return p.errorf("duplicate rule %q", rule.name)
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if p.isPunct(":") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		for p.tok.kind == ruleTokIdent {
+			rule.tags = append(rule.tags, p.tok.text)
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	p.rule, p.ids = &rule, make(map[string]int)
+	if p.isIdent("meta") {
+		if err := p.parseMeta(); err != nil {
+			return err
+		}
+	}
+	if p.isIdent("strings") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		for p.tok.kind == ruleTokString {
+			if err := p.parseString(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := p.expect("condition"); err != nil {
+		return err
+	}
+	if err := p.expect(":"); err != nil {
+		return err
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return err
+	}
+	rule.condition = cond
+	if err := p.expect("}"); err != nil {
+		return err
+	}
+
+	p.names[rule.name] = len(p.rs.rules)
+	p.rs.rules = append(p.rs.rules, rule)
+	return nil
+}
+
+func (p *ruleParser) parseMeta() error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if err := p.expect(":"); err != nil {
+		return err
+	}
+	for p.tok.kind == ruleTokIdent && !p.isIdent("strings") && !p.isIdent("condition") {
+		key := p.tok.text
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		negative := p.isPunct("-")
+		if negative {
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+		switch {
+		case p.tok.kind == ruleTokText && !negative:
+			p.rule.meta[key] = p.tok.text
+		case p.tok.kind == ruleTokNumber:
+			v := p.tok.num
+			if negative {
+				v = -v
+			}
+			p.rule.meta[key] = strconv.FormatInt(v, 10)
+		case (p.isIdent("true") || p.isIdent("false")) && !negative:
+			p.rule.meta[key] = p.tok.text
+		default:
+			return p.errorf("bad value for meta %q: %s", key, p.describe())
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ruleParser) parseString() error {
+	id := p.tok.text
+	if strings.HasSuffix(id, "*") {
+		return p.errorf("bad string identifier %s", id)
+	}
+	if _, dup := p.ids[id]; dup {
+		return p.errorf("duplicate string identifier %s", id)
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	value := p.tok
+	if value.kind != ruleTokText && value.kind != ruleTokHex && value.kind != ruleTokRegex {
+		return p.errorf("expected a string, hex string or regex for %s, got %s", id, p.describe())
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+
+	mods := make(map[string]bool)
+	for p.tok.kind == ruleTokIdent {
+		switch p.tok.text {
+		case "nocase", "wide", "ascii", "fullword", "private":
+			mods[p.tok.text] = true
+		case "xor", "base64", "base64wide":
+			return p.errorf("modifier %s is not supported", p.tok.text)
+		}
+		if !mods[p.tok.text] {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+
+	var idx int
+	switch value.kind {
+	case ruleTokText:
+		if value.text == "" {
+			return p.errorf("empty string %s", id)
+		}
+		idx = p.rs.addText(id, []byte(value.text), mods["nocase"], mods["wide"], mods["ascii"] || !mods["wide"])
+
+	case ruleTokHex:
+		if mods["nocase"] || mods["wide"] || mods["ascii"] || mods["fullword"] {
+			return p.errorf("hex string %s only accepts the private modifier", id)
+		}
+		tokens, err := parseHexString(value.text)
+		if err != nil {
+			return p.errorf("hex string %s: %v", id, err)
+		}
+		idx = len(p.rs.strings)
+		variants := newPatternVariants(idx, tokens, false)
+		if len(variants) == 0 {
+			return p.errorf("hex string %s has no fixed bytes", id)
+		}
+		if len(variants) > maxHexVariants {
+			return p.errorf("hex string %s has too many alternatives", id)
+		}
+		p.rs.strings = append(p.rs.strings, ruleString{id: id})
+		p.rs.variants = append(p.rs.variants, variants...)
+
+	case ruleTokRegex:
+		if mods["wide"] {
+			return p.errorf("wide regex %s is not supported", id)
+		}
+		expr := value.text
+		if strings.Contains(value.flags, "s") {
+			expr = "(?s)" + expr
+		}
+		if mods["nocase"] || strings.Contains(value.flags, "i") {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return p.errorf("regex %s: %v", id, err)
+		}
+		idx = len(p.rs.strings)
+		lits, width := regexLiterals(expr)
+		p.rs.strings = append(p.rs.strings, ruleString{id: id, regex: re, gated: lits != nil, width: width})
+		p.rs.regexes = append(p.rs.regexes, idx)
+		for _, lit := range lits {
+			lit.str = idx
+			p.rs.literals = append(p.rs.literals, lit)
+		}
+	}
+
+	p.rs.strings[idx].fullword = mods["fullword"]
+	p.rs.strings[idx].private = mods["private"]
+	p.rule.strings = append(p.rule.strings, idx)
+	if id != "$" {
+		p.ids[id] = idx
+	}
+	return nil
+}
+
+// parseHexString compiles the body of a { ... } string
+func parseHexString(body string) ([]patternToken, error) {
+	h := &hexParser{s: body}
+	tokens, err := h.sequence()
+	if err != nil {
+		return nil, err
+	}
+	if h.pos < len(h.s) {
+		return nil, fmt.Errorf("unexpected %q", h.s[h.pos])
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty hex string")
+	}
+	if tokens[0].kind == tokenJump || tokens[len(tokens)-1].kind == tokenJump {
+		return nil, fmt.Errorf("cannot start or end with a jump")
+	}
+	return tokens, nil
+}
+
+type hexParser struct {
+	s   string
+	pos int
+}
+
+func hexNibble(c byte) (value, mask byte, ok bool) {
+	switch {
+	case c == '?':
+		return 0, 0, true
+	case c >= '0' && c <= '9':
+		return c - '0', 0xf, true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, 0xf, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, 0xf, true
+	}
+	return 0, 0, false
+}
+
+func (h *hexParser) sequence() ([]patternToken, error) {
+	var tokens []patternToken
+	for {
+		for h.pos < len(h.s) && strings.IndexByte(" \t\r\n", h.s[h.pos]) >= 0 {
+			h.pos++
+		}
+		if h.pos >= len(h.s) || h.s[h.pos] == '|' || h.s[h.pos] == ')' {
+			return tokens, nil
+		}
+
+		switch c := h.s[h.pos]; c {
+		case '[':
+			end := strings.IndexByte(h.s[h.pos:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated jump")
+			}
+			spec := strings.ReplaceAll(h.s[h.pos+1:h.pos+end], " ", "")
+			h.pos += end + 1
+			lo, hi, isRange := strings.Cut(spec, "-")
+			jump := patternToken{kind: tokenJump, max: ruleMaxJump}
+			var err error
+			if lo != "" {
+				if jump.min, err = strconv.Atoi(lo); err != nil {
+					return nil, fmt.Errorf("bad jump [%s]", spec)
+				}
+			}
+			if !isRange {
+				jump.max = jump.min
+			} else if hi != "" {
+				if jump.max, err = strconv.Atoi(hi); err != nil {
+					return nil, fmt.Errorf("bad jump [%s]", spec)
+				}
+			}
+			if jump.min < 0 || jump.min > jump.max || jump.max > ruleMaxJump {
+				return nil, fmt.Errorf("bad jump [%s]", spec)
+			}
+			tokens = append(tokens, jump)
+
+		case '(':
+			h.pos++
+			alt := patternToken{kind: tokenAlternates}
+			for {
+				seq, err := h.sequence()
+				if err != nil {
+					return nil, err
+				}
+				if len(seq) == 0 {
+					return nil, fmt.Errorf("empty alternative")
+				}
+				alt.alts = append(alt.alts, seq)
+				if h.pos >= len(h.s) {
+					return nil, fmt.Errorf("unterminated alternative")
+				}
+				h.pos++
+				if h.s[h.pos-1] == ')' {
+					break
+				}
+			}
+			tokens = append(tokens, alt)
+
+		default:
+			if h.pos+1 >= len(h.s) {
+				return nil, fmt.Errorf("incomplete byte %q", h.s[h.pos:])
+			}
+			hv, hm, ok1 := hexNibble(c)
+			lv, lm, ok2 := hexNibble(h.s[h.pos+1])
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("bad byte %q", h.s[h.pos:h.pos+2])
+			}
+			tokens = append(tokens, patternToken{kind: tokenByte, value: hv<<4 | lv, mask: hm<<4 | lm})
+			h.pos += 2
+		}
+	}
+}
+
+// Condition expressions
+
+func (p *ruleParser) binaryLevel(ops []string, next func() (ruleExpr, error)) (ruleExpr, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range ops {
+			if p.isPunct(o) || (p.tok.kind == ruleTokIdent && p.tok.text == o) {
+				op = o
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *ruleParser) parseOr() (ruleExpr, error) {
+	return p.binaryLevel([]string{"or"}, p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, error) {
+	return p.binaryLevel([]string{"and"}, p.parseNot)
+}
+
+func (p *ruleParser) parseNot() (ruleExpr, error) {
+	if !p.isIdent("not") {
+		return p.parseComparison()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return &unaryExpr{op: "not", x: x}, nil
+}
+
+func (p *ruleParser) parseComparison() (ruleExpr, error) {
+	left, err := p.parseBitOr()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<", "<=", ">", ">="} {
+		if p.isPunct(op) {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			right, err := p.parseBitOr()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseBitOr() (ruleExpr, error) {
+	return p.binaryLevel([]string{"|"}, p.parseBitXor)
+}
+
+func (p *ruleParser) parseBitXor() (ruleExpr, error) {
+	return p.binaryLevel([]string{"^"}, p.parseBitAnd)
+}
+
+func (p *ruleParser) parseBitAnd() (ruleExpr, error) {
+	return p.binaryLevel([]string{"&"}, p.parseShift)
+}
+
+func (p *ruleParser) parseShift() (ruleExpr, error) {
+	return p.binaryLevel([]string{"<<", ">>"}, p.parseAdditive)
+}
+
+func (p *ruleParser) parseAdditive() (ruleExpr, error) {
+	return p.binaryLevel([]string{"+", "-"}, p.parseMultiplicative)
+}
+
+func (p *ruleParser) parseMultiplicative() (ruleExpr, error) {
+	return p.binaryLevel([]string{"*", "\\", "%"}, p.parseUnary)
+}
+
+func (p *ruleParser) parseUnary() (ruleExpr, error) {
+	if !p.isPunct("-") && !p.isPunct("~") {
+		return p.parsePrimary()
+	}
+	op := p.tok.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &unaryExpr{op: op, x: x}, nil
+}
+
+func (p *ruleParser) parsePrimary() (ruleExpr, error) {
+	tok := p.tok
+	switch tok.kind {
+	case ruleTokNumber:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.isIdent("of") {
+			return p.parseOf(constExpr(tok.num))
+		}
+		return constExpr(tok.num), nil
+
+	case ruleTokString:
+		idx, err := p.lookupString(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		cond := &stringCondition{str: idx}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		switch {
+		case p.isIdent("at"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			cond.at, err = p.parseAdditive()
+		case p.isIdent("in"):
+			cond.lo, cond.hi, err = p.parseRange()
+		}
+		return cond, err
+
+	case ruleTokCount:
+		idx, err := p.lookupString(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		count := &countExpr{str: idx}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.isIdent("in") {
+			count.lo, count.hi, err = p.parseRange()
+		}
+		return count, err
+
+	case ruleTokOffset, ruleTokLength:
+		idx, err := p.lookupString(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		m := &matchExpr{str: idx, length: tok.kind == ruleTokLength, index: constExpr(1)}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.isPunct("[") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if m.index, err = p.parseOr(); err != nil {
+				return nil, err
+			}
+			err = p.expect("]")
+		}
+		return m, err
+
+	case ruleTokPunct:
+		if tok.text == "(" {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+
+	case ruleTokIdent:
+		switch tok.text {
+		case "true", "false", "filesize":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			switch tok.text {
+			case "true":
+				return constExpr(1), nil
+			case "false":
+				return constExpr(0), nil
+			}
+			return filesizeExpr{}, nil
+		case "all", "any", "none":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			of, err := p.parseOf(nil)
+			if err != nil {
+				return nil, err
+			}
+			of.(*ofExpr).all = tok.text == "all"
+			of.(*ofExpr).none = tok.text == "none"
+			return of, nil
+		}
+		if spec, ok := ruleIntReaders[tok.text]; ok {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			offset, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			read := &intReadExpr{offset: offset, size: spec.size, signed: spec.signed, bigEndian: spec.bigEndian}
+			return read, p.expect(")")
+		}
+		if idx, ok := p.names[tok.text]; ok {
+			return ruleRefExpr(idx), p.advance()
+		}
+		return nil, p.errorf("undefined identifier %q", tok.text)
+	}
+	return nil, p.errorf("unexpected %s in condition", p.describe())
+}
+
+func (p *ruleParser) parseRange() (ruleExpr, ruleExpr, error) {
+	if err := p.advance(); err != nil {
+		return nil, nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, nil, err
+	}
+	lo, err := p.parseBitOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.expect(".."); err != nil {
+		return nil, nil, err
+	}
+	hi, err := p.parseBitOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	return lo, hi, p.expect(")")
+}
+
+// parseOf parses "of them" or "of ($a, $b*)" after a quantifier
+func (p *ruleParser) parseOf(n ruleExpr) (ruleExpr, error) {
+	if err := p.expect("of"); err != nil {
+		return nil, err
+	}
+	of := &ofExpr{n: n}
+	if n == nil {
+		of.n = constExpr(1)
+	}
+	if p.isIdent("them") {
+		if len(p.rule.strings) == 0 {
+			return nil, p.errorf("rule %q has no strings", p.rule.name)
+		}
+		of.strs = p.rule.strings
+		return of, p.advance()
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.tok.kind != ruleTokString {
+			return nil, p.errorf("expected a string identifier, got %s", p.describe())
+		}
+		if prefix, wildcard := strings.CutSuffix(p.tok.text, "*"); wildcard {
+			found := false
+			for _, idx := range p.rule.strings {
+				if strings.HasPrefix(p.rs.strings[idx].id, prefix) {
+					of.strs = append(of.strs, idx)
+					found = true
+				}
+			}
+			if !found {
+				return nil, p.errorf("no strings match %s", p.tok.text)
+			}
+		} else {
+			idx, err := p.lookupString(p.tok.text)
+			if err != nil {
+				return nil, err
+			}
+			of.strs = append(of.strs, idx)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if !p.isPunct(",") {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return of, p.expect(")")
+}
+
+func (p *ruleParser) lookupString(text string) (int, error) {
+	if strings.HasSuffix(text, "*") {
+		return 0, p.errorf("wildcard %s is only allowed in of expressions", text)
+	}
+	idx, ok := p.ids[text]
+	if !ok {
+		return 0, p.errorf("undefined string identifier %s", text)
+	}
+	return idx, nil
+}
+
+type ruleContext struct {
+	scan    *ruleScan
+	data    io.ReaderAt
+	results []bool
+}
+
+// ruleExpr is a condition node; evaluating to false means undefined,
+// which makes the enclosing boolean expression false
+type ruleExpr interface {
+	eval(c *ruleContext) (int64, bool)
+}
+
+type constExpr int64
+
+func (e constExpr) eval(*ruleContext) (int64, bool) { return int64(e), true }
+
+type filesizeExpr struct{}
+
+func (filesizeExpr) eval(c *ruleContext) (int64, bool) { return c.scan.size, true }
+
+type ruleRefExpr int
+
+func (e ruleRefExpr) eval(c *ruleContext) (int64, bool) { return boolInt(c.results[e]), true }
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type unaryExpr struct {
+	op string
+	x  ruleExpr
+}
+
+func (e *unaryExpr) eval(c *ruleContext) (int64, bool) {
+	v, ok := e.x.eval(c)
+	switch e.op {
+	case "not":
+		return boolInt(!ok || v == 0), true
+	case "-":
+		return -v, ok
+	}
+	return ^v, ok
+}
+
+type binaryExpr struct {
+	op          string
+	left, right ruleExpr
+}
+
+func (e *binaryExpr) eval(c *ruleContext) (int64, bool) {
+	l, lok := e.left.eval(c)
+	switch e.op {
+	case "and":
+		if !lok || l == 0 {
+			return 0, true
+		}
+		r, rok := e.right.eval(c)
+		return boolInt(rok && r != 0), true
+	case "or":
+		if lok && l != 0 {
+			return 1, true
+		}
+		r, rok := e.right.eval(c)
+		return boolInt(rok && r != 0), true
+	}
+
+	r, rok := e.right.eval(c)
+	if !lok || !rok {
+		return 0, false
+	}
+	switch e.op {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "\\", "%":
+		if r == 0 {
+			return 0, false
+		}
+		if e.op == "%" {
+			return l % r, true
+		}
+		return l / r, true
+	case "&":
+		return l & r, true
+	case "|":
+		return l | r, true
+	case "^":
+		return l ^ r, true
+	case "<<", ">>":
+		if r < 0 {
+			return 0, false
+		}
+		if r >= 64 {
+			return 0, true
+		}
+		if e.op == "<<" {
+			return l << uint(r), true
+		}
+		return l >> uint(r), true
+	case "==":
+		return boolInt(l == r), true
+	case "!=":
+		return boolInt(l != r), true
+	case "<":
+		return boolInt(l < r), true
+	case "<=":
+		return boolInt(l <= r), true
+	case ">":
+		return boolInt(l > r), true
+	case ">=":
+		return boolInt(l >= r), true
+	}
+	return 0, false
+}
+
+// stringCondition is $a, $a at N or $a in (N..M)
+type stringCondition struct {
+	str        int
+	at, lo, hi ruleExpr
+}
+
+func (e *stringCondition) eval(c *ruleContext) (int64, bool) {
+	if e.at == nil && e.lo == nil {
+		return boolInt(c.scan.counts[e.str] > 0), true
+	}
+	lo, hi, ok := c.bounds(e.at, e.lo, e.hi)
+	if !ok {
+		return 0, true
+	}
+	for _, m := range c.scan.matches[e.str] {
+		if m.Offset >= lo && m.Offset <= hi {
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+// bounds evaluates either an exact offset or an inclusive range
+func (c *ruleContext) bounds(at, lo, hi ruleExpr) (int64, int64, bool) {
+	if at != nil {
+		v, ok := at.eval(c)
+		return v, v, ok
+	}
+	l, lok := lo.eval(c)
+	h, hok := hi.eval(c)
+	return l, h, lok && hok
+}
+
+// countExpr is #a or #a in (N..M)
+type countExpr struct {
+	str    int
+	lo, hi ruleExpr
+}
+
+func (e *countExpr) eval(c *ruleContext) (int64, bool) {
+	if e.lo == nil {
+		return int64(c.scan.counts[e.str]), true
+	}
+	lo, hi, ok := c.bounds(nil, e.lo, e.hi)
+	if !ok {
+		return 0, false
+	}
+	n := int64(0)
+	for _, m := range c.scan.matches[e.str] {
+		if m.Offset >= lo && m.Offset <= hi {
+			n++
+		}
+	}
+	return n, true
+}
+
+// matchExpr is @a[i] or !a[i], with i counted from 1
+type matchExpr struct {
+	str    int
+	index  ruleExpr
+	length bool
+}
+
+func (e *matchExpr) eval(c *ruleContext) (int64, bool) {
+	i, ok := e.index.eval(c)
+	matches := c.scan.matches[e.str]
+	if !ok || i < 1 || i > int64(len(matches)) {
+		return 0, false
+	}
+	if e.length {
+		return int64(matches[i-1].Length), true
+	}
+	return matches[i-1].Offset, true
+}
+
+// ofExpr is "N of", "all of", "any of" or "none of" a string set
+type ofExpr struct {
+	n         ruleExpr
+	all, none bool
+	strs      []int
+}
+
+func (e *ofExpr) eval(c *ruleContext) (int64, bool) {
+	found := int64(0)
+	for _, idx := range e.strs {
+		if c.scan.counts[idx] > 0 {
+			found++
+		}
+	}
+	switch {
+	case e.all:
+		return boolInt(found == int64(len(e.strs))), true
+	case e.none:
+		return boolInt(found == 0), true
+	}
+	n, ok := e.n.eval(c)
+	return boolInt(ok && found >= n), true
+}
+
+// intReadExpr is uint16(offset) and friends
+type intReadExpr struct {
+	offset            ruleExpr
+	size              int
+	signed, bigEndian bool
+}
+
+func (e *intReadExpr) eval(c *ruleContext) (int64, bool) {
+	off, ok := e.offset.eval(c)
+	if !ok {
+		return 0, false
+	}
+	return readRuleInt(c.data, off, e.size, e.signed, e.bigEndian)
+}
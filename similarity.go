@@ -86,6 +86,13 @@ type SentenceReorder struct {
 
 // CalculateSimilarity computes comprehensive similarity metrics between two texts
 func CalculateSimilarity(text1, text2 string) *SimilarityResult {
+	return CalculateSimilarityWithOptions(text1, text2, NormalizationOptions{})
+}
+
+// CalculateSimilarityWithOptions computes similarity metrics with words
+// normalized by opts, so that inflections such as "runs" and "running"
+// count as the same term in the word-level and TF-IDF measures
+func CalculateSimilarityWithOptions(text1, text2 string, opts NormalizationOptions) *SimilarityResult {
 	result := &SimilarityResult{}
 	
 	// Character-level similarity
@@ -97,15 +104,15 @@ func CalculateSimilarity(text1, text2 string) *SimilarityResult {
 	result.CharacterOverlap = calculateCharacterOverlap(text1, text2)
 	
 	// Word-level similarity
-	words1 := extractWords(text1)
-	words2 := extractWords(text2)
+	words1 := NormalizeTokens(extractWords(text1), opts)
+	words2 := NormalizeTokens(extractWords(text2), opts)
 	result.WordOverlap = calculateWordSetOverlap(words1, words2)
 	result.JaccardIndex = calculateJaccardIndex(words1, words2)
 	result.DiceCoefficient = calculateDiceCoefficient(words1, words2)
 	
 	// Semantic similarity
 	result.CosineSimilarity = calculateCosineSimilarity(words1, words2)
-	result.TFIDFSimilarity = calculateTFIDFSimilarity(text1, text2)
+	result.TFIDFSimilarity = calculateTFIDFSimilarity(words1, words2)
 	
 	// Structural similarity
 	sentences1 := SplitIntoSentences(text1)
@@ -367,14 +374,13 @@ func calculateCosineSimilarity(words1, words2 []string) float64 {
 	return dotProduct / (magnitude1 * magnitude2)
 }
 
-func calculateTFIDFSimilarity(text1, text2 string) float64 {
+func calculateTFIDFSimilarity(words1, words2 []string) float64 {
 	// Simple TF-IDF approximation
-	docs := []string{text1, text2}
+	docs := [][]string{words1, words2}
 	
 	// Calculate document frequencies
 	df := make(map[string]int)
-	for _, doc := range docs {
-		words := extractWords(doc)
+	for _, words := range docs {
 		seen := make(map[string]bool)
 		
 		for _, w := range words {
@@ -389,8 +395,7 @@ func calculateTFIDFSimilarity(text1, text2 string) float64 {
 	// Calculate TF-IDF vectors
 	vectors := make([]map[string]float64, 2)
 	
-	for i, doc := range docs {
-		words := extractWords(doc)
+	for i, words := range docs {
 		tf := make(map[string]float64)
 		
 		// Calculate term frequencies
@@ -858,105 +863,36 @@ func detectSentenceReorders(sentences1, sentences2 []string) []SentenceReorder {
 }
 
 func calculateDiffOperations(text1, text2 string) []DiffOperation {
-	// Use Myers' diff algorithm (simplified)
+	// Character-level Myers diff; positions and lengths count runes
 	operations := []DiffOperation{}
 	
-	// For simplicity, use character-level diff
-	r1 := []rune(text1)
-	r2 := []rune(text2)
-	
-	// LCS-based diff
-	lcs := calculateRuneLCS(r1, r2)
-	
-	i, j, lcsIdx := 0, 0, 0
-	
-	for i < len(r1) || j < len(r2) {
-		if lcsIdx < len(lcs) && i < len(r1) && j < len(r2) && r1[i] == r2[j] && r1[i] == lcs[lcsIdx] {
-			// Equal
-			start := i
-			for i < len(r1) && j < len(r2) && r1[i] == r2[j] {
-				i++
-				j++
-				if lcsIdx < len(lcs) && i < len(r1) && r1[i] == lcs[lcsIdx] {
-					lcsIdx++
-				}
-			}
-			
-			operations = append(operations, DiffOperation{
-				Type:     "equal",
-				Text:     string(r1[start:i]),
-				Position: start,
-				Length:   i - start,
-			})
-		} else if i < len(r1) && (lcsIdx >= len(lcs) || r1[i] != lcs[lcsIdx]) {
-			// Delete
-			start := i
-			for i < len(r1) && (lcsIdx >= len(lcs) || j >= len(r2) || r1[i] != r2[j]) {
-				i++
-			}
-			
-			operations = append(operations, DiffOperation{
-				Type:     "delete",
-				Text:     string(r1[start:i]),
-				Position: start,
-				Length:   i - start,
-			})
-		} else if j < len(r2) {
-			// Insert
-			start := j
-			for j < len(r2) && (i >= len(r1) || r1[i] != r2[j]) {
-				j++
-			}
-			
-			operations = append(operations, DiffOperation{
-				Type:     "insert",
-				Text:     string(r2[start:j]),
-				Position: i,
-				Length:   j - start,
-			})
-		}
-	}
-	
-	return operations
-}
-
-func calculateRuneLCS(r1, r2 []rune) []rune {
-	if len(r1) == 0 || len(r2) == 0 {
-		return []rune{}
-	}
-	
-	// Create DP table
-	dp := make([][]int, len(r1)+1)
-	for i := range dp {
-		dp[i] = make([]int, len(r2)+1)
-	}
+	r1 := strings.Split(text1, "")
+	r2 := strings.Split(text2, "")
 	
-	// Fill DP table
-	for i := 1; i <= len(r1); i++ {
-		for j := 1; j <= len(r2); j++ {
-			if r1[i-1] == r2[j-1] {
-				dp[i][j] = dp[i-1][j-1] + 1
-			} else {
-				dp[i][j] = max(dp[i-1][j], dp[i][j-1])
-			}
+	opType := map[byte]string{'=': "equal", '-': "delete", '+': "insert"}
+	edits := diffUnits(r1, r2, "")
+	for start := 0; start < len(edits); {
+		// Group a run of edits of the same kind into one operation
+		end := start + 1
+		for end < len(edits) && edits[end].op == edits[start].op {
+			end++
 		}
-	}
-	
-	// Reconstruct LCS
-	lcs := []rune{}
-	i, j := len(r1), len(r2)
-	
-	for i > 0 && j > 0 {
-		if r1[i-1] == r2[j-1] {
-			lcs = append([]rune{r1[i-1]}, lcs...)
-			i--
-			j--
-		} else if dp[i-1][j] > dp[i][j-1] {
-			i--
+		
+		first, last := edits[start], edits[end-1]
+		var text string
+		if first.op == '+' {
+			text = strings.Join(r2[first.b:last.b+1], "")
 		} else {
-			j--
+			text = strings.Join(r1[first.a:last.a+1], "")
 		}
+		operations = append(operations, DiffOperation{
+			Type:     opType[first.op],
+			Text:     text,
+			Position: first.a,
+			Length:   end - start,
+		})
+		start = end
 	}
 	
-	return lcs
+	return operations
 }
\ No newline at end of file
@@ -2,7 +2,6 @@ package textlib
 
 import (
 	"math"
-	"regexp"
 	"sort"
 	"strings"
 	"unicode"
@@ -85,6 +84,13 @@ type TrigramFrequency struct {
 
 // CalculateTextStatistics computes comprehensive statistics for a text
 func CalculateTextStatistics(text string) *TextStatistics {
+	return CalculateTextStatisticsWithOptions(text, NormalizationOptions{})
+}
+
+// CalculateTextStatisticsWithOptions computes text statistics with words
+// normalized by opts before vocabulary and frequency metrics are counted.
+// Length, syllable and capitalization metrics always use the original words.
+func CalculateTextStatisticsWithOptions(text string, opts NormalizationOptions) *TextStatistics {
 	stats := &TextStatistics{}
 	
 	// Basic counts
@@ -101,10 +107,11 @@ func CalculateTextStatistics(text string) *TextStatistics {
 	// Word analysis
 	words := extractWords(text)
 	stats.WordCount = len(words)
+	terms := NormalizeTokens(words, opts)
 	
 	// Word frequency analysis
 	wordFreq := make(map[string]int)
-	for _, word := range words {
+	for _, word := range terms {
 		lower := strings.ToLower(word)
 		wordFreq[lower]++
 	}
@@ -116,7 +123,7 @@ func CalculateTextStatistics(text string) *TextStatistics {
 	stats.HapaxLegomena = countWordsWithFrequency(wordFreq, 1)
 	stats.DisLegomena = countWordsWithFrequency(wordFreq, 2)
 	stats.VocabularyRichness = calculateYulesK(wordFreq, stats.WordCount)
-	stats.LexicalDiversity = calculateMTLD(words)
+	stats.LexicalDiversity = calculateMTLD(terms)
 	
 	// Word length analysis
 	stats.AverageWordLength, stats.WordLengthStdDev = calculateWordLengthStats(words)
@@ -156,8 +163,8 @@ func CalculateTextStatistics(text string) *TextStatistics {
 	
 	// Most frequent items
 	stats.MostFrequentWords = findMostFrequentWords(wordFreq, 10)
-	stats.MostFrequentBigrams = findMostFrequentBigrams(words, 10)
-	stats.MostFrequentTrigrams = findMostFrequentTrigrams(words, 10)
+	stats.MostFrequentBigrams = findMostFrequentBigrams(terms, 10)
+	stats.MostFrequentTrigrams = findMostFrequentTrigrams(terms, 10)
 	
 	return stats
 }
@@ -176,21 +183,12 @@ func countNonSpaceChars(text string) int {
 
 func extractWords(text string) []string {
 	var words []string
-	fields := strings.Fields(text)
-	for _, field := range fields {
-		word := cleanWord(field)
-		if word != "" {
-			words = append(words, word)
-		}
+	for _, tok := range Tokenize(text) {
+		words = append(words, tok.Text)
 	}
 	return words
 }
 
-func cleanWord(word string) string {
-	// Remove punctuation but keep letters and numbers
-	return regexp.MustCompile(`[^a-zA-Z0-9]`).ReplaceAllString(word, "")
-}
-
 func countWordsWithFrequency(freq map[string]int, target int) int {
 	count := 0
 	for _, f := range freq {
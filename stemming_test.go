@@ -0,0 +1,47 @@
+package textlib
+
+import (
+	"testing"
+)
+
+func TestEnglishStemmer(t *testing.T) {
+	tests := []struct {
+		word     string
+		expected string
+	}{
+		{"running", "run"},
+		{"runs", "run"},
+		{"caresses", "caress"},
+		{"ponies", "poni"},
+		{"ties", "tie"},
+		{"hopping", "hop"},
+		{"filing", "file"},
+		{"happy", "happi"},
+		{"generously", "generous"},
+		{"generalization", "general"},
+		{"relational", "relat"},
+		{"consigned", "consign"},
+		{"consignment", "consign"},
+		{"knightly", "knight"},
+		{"communication", "communic"},
+		{"skies", "sky"},
+		{"dying", "die"},
+		{"news", "news"},
+		{"succeed", "succeed"},
+		{"go", "go"},
+	}
+
+	stemmer := EnglishStemmer{}
+	for _, tt := range tests {
+		if got := stemmer.Stem(tt.word); got != tt.expected {
+			t.Errorf("Stem(%q): expected %q, got %q", tt.word, tt.expected, got)
+		}
+	}
+}
+
+func TestEnglishStemmerNonASCII(t *testing.T) {
+	stemmer := EnglishStemmer{}
+	if got := stemmer.Stem("café"); got != "café" {
+		t.Errorf("Expected non-ASCII word to be left alone, got %q", got)
+	}
+}
@@ -0,0 +1,799 @@
+package textlib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StreamOptions bounds the memory used by the streaming segmenters
+type StreamOptions struct {
+	ReadSize      int // Bytes requested per Read (default 64 KiB)
+	MaxBufferSize int // Most bytes held while waiting for a boundary (default 1 MiB)
+}
+
+const (
+	defaultStreamReadSize   = 64 << 10
+	defaultStreamBufferSize = 1 << 20
+)
+
+// SegmentTextStream segments text read from r like SegmentText, sending each
+// segment as soon as its end is known. Only the text since the last boundary
+// is held in memory; a run longer than opts.MaxBufferSize is cut at the last
+// line break or space and marked with a "forced_split" metadata entry.
+// Segment offsets are byte offsets into the stream. The error channel
+// receives at most one error, a read failure or ctx.Err(), after the segment
+// channel is closed.
+func SegmentTextStream(ctx context.Context, r io.Reader, method string, opts StreamOptions) (<-chan Segment, <-chan error) {
+	return runStream(ctx, func(emit func(Segment) error) error {
+		s := newStreamBuffer(r, opts)
+		switch method {
+		case "sentence":
+			return streamSentenceSegments(s, emit)
+		case "section":
+			return streamSectionSegments(s, emit)
+		case "topic":
+			return streamGroupedSegments(s, "topic", 0.3, emit)
+		case "semantic":
+			return streamGroupedSegments(s, "semantic", 0.5, emit)
+		case "fixed":
+			return streamFixedSegments(s, 1000, emit)
+		default:
+			return streamParagraphSegments(s, emit)
+		}
+	})
+}
+
+// ChunkTextStream chunks text read from r like ChunkText. Chunks are sent as
+// they fill up, so memory stays proportional to one chunk plus the read
+// buffer. StartOffset and EndOffset are byte offsets into the stream for
+// every method.
+func ChunkTextStream(ctx context.Context, r io.Reader, strategy ChunkingStrategy, opts StreamOptions) (<-chan TextChunk, <-chan error) {
+	return runStream(ctx, func(emit func(TextChunk) error) error {
+		s := newStreamBuffer(r, opts)
+		switch strategy.Method {
+		case "sentence":
+			p := &unitPacker{strategy: strategy, sep: " ", key: "sentences", emit: emit}
+			if err := forEachStreamSentence(s, func(u streamUnit) error {
+				return p.add(u.text, u.start, u.start+len(u.text))
+			}); err != nil {
+				return err
+			}
+			return p.flush()
+		case "paragraph":
+			p := &unitPacker{strategy: strategy, sep: "\n\n", key: "paragraphs", emit: emit}
+			if err := forEachStreamParagraph(s, func(u streamUnit) error {
+				return p.add(u.text, u.start, u.start+len(u.text))
+			}); err != nil {
+				return err
+			}
+			return p.flush()
+		case "semantic":
+			p := &unitPacker{strategy: strategy, sep: " ", key: "semanticGroups", emit: emit}
+			belongs := func(group []string, sentence string) (float64, bool) {
+				coherence := strategy.semanticCoherence(group, sentence)
+				return coherence, coherence >= strategy.SemanticThreshold
+			}
+			if err := streamSentenceGroups(s, belongs, func(g sentenceGroup) error {
+				return p.add(g.text(), g.start(), g.end())
+			}); err != nil {
+				return err
+			}
+			return p.flush()
+		case "sliding":
+			return streamWordWindows(s, strategy, true, emit)
+		case "markdown":
+			return streamMarkdownChunks(s, strategy, emit)
+		default:
+			return streamWordWindows(s, strategy, false, emit)
+		}
+	})
+}
+
+// FindNaturalBoundariesStream sends the boundaries FindNaturalBoundaries
+// would report for the text read from r, in increasing order and without
+// duplicates: 0, the end of every paragraph, and the start of every section
+// header line
+func FindNaturalBoundariesStream(ctx context.Context, r io.Reader, opts StreamOptions) (<-chan int, <-chan error) {
+	return runStream(ctx, func(emit func(int) error) error {
+		s := newStreamBuffer(r, opts)
+		last := 0
+		if err := emit(0); err != nil {
+			return err
+		}
+		send := func(b int) error {
+			if b <= last {
+				return nil
+			}
+			last = b
+			return emit(b)
+		}
+
+		return forEachStreamParagraph(s, func(u streamUnit) error {
+			lineStart, offset := u.lineStart, 0
+			for {
+				line := u.text[offset:]
+				i := strings.IndexByte(line, '\n')
+				if i >= 0 {
+					line = line[:i]
+				}
+				if isSectionHeader(line) {
+					if err := send(lineStart); err != nil {
+						return err
+					}
+				}
+				if i < 0 {
+					break
+				}
+				offset += i + 1
+				lineStart = u.start + offset
+			}
+			return send(u.start + len(u.text))
+		})
+	})
+}
+
+// runStream runs produce in a goroutine, delivering its values on the
+// returned channel until it finishes or ctx is cancelled
+func runStream[T any](ctx context.Context, produce func(emit func(T) error) error) (<-chan T, <-chan error) {
+	out := make(chan T)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(out)
+
+		emit := func(v T) error {
+			select {
+			case out <- v:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := produce(emit); err != nil {
+			errc <- err
+		}
+	}()
+
+	return out, errc
+}
+
+// streamBuffer holds the unconsumed tail of a stream
+type streamBuffer struct {
+	r        io.Reader
+	buf      []byte
+	pos      int // start of the unconsumed bytes in buf
+	base     int // stream offset of buf[pos]
+	eof      bool
+	readSize int
+	maxSize  int
+}
+
+// streamUnit is a paragraph, sentence, line or word read from a stream
+type streamUnit struct {
+	text      string
+	start     int // stream offset of text
+	lineStart int // stream offset of the line text starts on
+	forced    bool
+}
+
+func newStreamBuffer(r io.Reader, opts StreamOptions) *streamBuffer {
+	s := &streamBuffer{r: r, readSize: opts.ReadSize, maxSize: opts.MaxBufferSize}
+	if s.maxSize <= 0 {
+		s.maxSize = defaultStreamBufferSize
+	}
+	if s.readSize <= 0 {
+		s.readSize = defaultStreamReadSize
+	}
+	s.readSize = min(s.readSize, s.maxSize)
+	return s
+}
+
+func (s *streamBuffer) data() []byte {
+	return s.buf[s.pos:]
+}
+
+func (s *streamBuffer) consume(n int) {
+	s.pos += n
+	s.base += n
+}
+
+// fill reads the next block of input, recording io.EOF in s.eof
+func (s *streamBuffer) fill() error {
+	if s.eof {
+		return nil
+	}
+
+	// Drop consumed bytes before growing
+	if s.pos > 0 {
+		n := copy(s.buf, s.buf[s.pos:])
+		s.buf = s.buf[:n]
+		s.pos = 0
+	}
+
+	n := len(s.buf)
+	if cap(s.buf)-n < s.readSize {
+		s.buf = append(s.buf, make([]byte, s.readSize)...)[:n]
+	}
+	m, err := s.r.Read(s.buf[n : n+s.readSize])
+	s.buf = s.buf[:n+m]
+	if err == io.EOF {
+		s.eof = true
+		return nil
+	}
+	return err
+}
+
+// skipSpace consumes leading whitespace and returns the stream offset of the
+// line the next byte is on
+func (s *streamBuffer) skipSpace(lineStart int) int {
+	data := s.data()
+	n := len(data) - len(bytes.TrimLeftFunc(data, unicode.IsSpace))
+	if i := bytes.LastIndexByte(data[:n], '\n'); i >= 0 {
+		lineStart = s.base + i + 1
+	}
+	s.consume(n)
+	return lineStart
+}
+
+var streamParagraphBreak = regexp.MustCompile(`\n[\t\f\r ]*\n`)
+
+// nextParagraph reads up to the next blank line. ok is false at the end of
+// the stream.
+func (s *streamBuffer) nextParagraph() (unit streamUnit, ok bool, err error) {
+	lineStart := s.base
+	for {
+		lineStart = s.skipSpace(lineStart)
+		data := s.data()
+
+		end, next := -1, 0
+		if loc := streamParagraphBreak.FindIndex(data); loc != nil {
+			end, next = loc[0], loc[1]
+		} else if s.eof {
+			end, next = len(data), len(data)
+		} else if len(data) >= s.maxSize {
+			end = forcedCut(data, s.maxSize)
+			next = end
+			unit.forced = true
+		}
+
+		if end >= 0 {
+			if end == 0 && next == 0 {
+				return unit, false, nil
+			}
+			unit.text = string(bytes.TrimRightFunc(data[:end], unicode.IsSpace))
+			unit.start = s.base
+			unit.lineStart = lineStart
+			s.consume(next)
+			return unit, true, nil
+		}
+
+		if err := s.fill(); err != nil {
+			return unit, false, err
+		}
+	}
+}
+
+// nextLine reads one line without its terminator
+func (s *streamBuffer) nextLine() (unit streamUnit, ok bool, err error) {
+	for {
+		data := s.data()
+		end, next := -1, 0
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			end, next = i, i+1
+		} else if s.eof {
+			if len(data) == 0 {
+				return unit, false, nil
+			}
+			end, next = len(data), len(data)
+		} else if len(data) >= s.maxSize {
+			end = forcedCut(data, s.maxSize)
+			next = end
+			unit.forced = true
+		}
+
+		if end >= 0 {
+			unit.text = strings.TrimRight(string(data[:end]), "\r")
+			unit.start = s.base
+			unit.lineStart = s.base
+			s.consume(next)
+			return unit, true, nil
+		}
+
+		if err := s.fill(); err != nil {
+			return unit, false, err
+		}
+	}
+}
+
+// nextWord reads the next run of non-space characters
+func (s *streamBuffer) nextWord() (unit streamUnit, ok bool, err error) {
+	for {
+		s.skipSpace(0)
+		data := s.data()
+		end := bytes.IndexFunc(data, unicode.IsSpace)
+		if end < 0 {
+			if s.eof {
+				end = len(data)
+			} else if len(data) >= s.maxSize {
+				end = runeSafeCut(data, s.maxSize)
+				unit.forced = true
+			}
+		}
+
+		if end >= 0 {
+			if end == 0 {
+				return unit, false, nil
+			}
+			unit.text = string(data[:end])
+			unit.start = s.base
+			s.consume(end)
+			return unit, true, nil
+		}
+
+		if err := s.fill(); err != nil {
+			return unit, false, err
+		}
+	}
+}
+
+// forcedCut picks where to split a run with no natural boundary: after the
+// last line break or space within limit, or else at a character boundary
+func forcedCut(data []byte, limit int) int {
+	limit = min(limit, len(data))
+	if i := bytes.LastIndexByte(data[:limit], '\n'); i > 0 {
+		return i + 1
+	}
+	if i := bytes.LastIndexAny(data[:limit], " \t"); i > 0 {
+		return i + 1
+	}
+	return runeSafeCut(data, limit)
+}
+
+// runeSafeCut moves limit back to the start of a UTF-8 sequence
+func runeSafeCut(data []byte, limit int) int {
+	limit = min(limit, len(data))
+	cut := limit
+	for cut > 0 && cut < len(data) && !utf8.RuneStart(data[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		return limit
+	}
+	return cut
+}
+
+func forEachStreamParagraph(s *streamBuffer, fn func(streamUnit) error) error {
+	for {
+		unit, ok, err := s.nextParagraph()
+		if err != nil || !ok {
+			return err
+		}
+		if err := fn(unit); err != nil {
+			return err
+		}
+	}
+}
+
+func forEachStreamSentence(s *streamBuffer, fn func(streamUnit) error) error {
+	return forEachStreamParagraph(s, func(para streamUnit) error {
+		for _, sent := range sentenceTokens(para.text) {
+			unit := streamUnit{text: sent.Text, start: para.start + sent.Position.Start, forced: para.forced}
+			if err := fn(unit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func streamParagraphSegments(s *streamBuffer, emit func(Segment) error) error {
+	index := 0
+	return forEachStreamParagraph(s, func(u streamUnit) error {
+		seg := streamSegment(u.text, u.start, "paragraph", u.forced)
+		seg.Metadata["index"] = index
+		index++
+		return emit(seg)
+	})
+}
+
+func streamSentenceSegments(s *streamBuffer, emit func(Segment) error) error {
+	index := 0
+	return forEachStreamSentence(s, func(u streamUnit) error {
+		seg := streamSegment(u.text, u.start, "sentence", false)
+		seg.Metadata["index"] = index
+		index++
+		return emit(seg)
+	})
+}
+
+func streamSegment(text string, start int, segType string, forced bool) Segment {
+	seg := Segment{
+		Text:       text,
+		Start:      start,
+		End:        start + len(text),
+		Type:       segType,
+		TokenCount: len(strings.Fields(text)),
+		CharCount:  len(text),
+		Metadata:   map[string]interface{}{},
+	}
+	if forced {
+		seg.Metadata["forced_split"] = true
+	}
+	return seg
+}
+
+func streamSectionSegments(s *streamBuffer, emit func(Segment) error) error {
+	var lines []string
+	start, size := 0, 0
+
+	flush := func(end int, forced bool) error {
+		if len(lines) == 0 {
+			return nil
+		}
+		sectionText := strings.Join(lines, "\n")
+		seg := streamSegment(sectionText, start, "section", forced)
+		seg.End = end
+		if isSectionHeader(lines[0]) {
+			seg.Metadata["header"] = strings.TrimSpace(lines[0])
+		}
+		lines, size = nil, 0
+		return emit(seg)
+	}
+
+	for {
+		line, ok, err := s.nextLine()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return flush(s.base, false)
+		}
+
+		if isSectionHeader(line.text) && len(lines) > 0 {
+			if err := flush(line.start, false); err != nil {
+				return err
+			}
+		}
+		if len(lines) == 0 {
+			start = line.start
+		}
+		lines = append(lines, line.text)
+		size += len(line.text) + 1
+
+		if size >= s.maxSize || line.forced {
+			if err := flush(s.base, true); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sentenceGroup is a run of consecutive sentences on one topic
+type sentenceGroup struct {
+	sentences []streamUnit
+	score     float64 // similarity that ended the group
+	scored    bool    // false when the group ended with the stream or the buffer limit
+}
+
+func (g sentenceGroup) text() string {
+	parts := make([]string, len(g.sentences))
+	for i, u := range g.sentences {
+		parts[i] = u.text
+	}
+	return strings.Join(parts, " ")
+}
+
+func (g sentenceGroup) start() int {
+	return g.sentences[0].start
+}
+
+func (g sentenceGroup) end() int {
+	last := g.sentences[len(g.sentences)-1]
+	return last.start + len(last.text)
+}
+
+// streamSentenceGroups groups consecutive sentences for as long as belongs
+// accepts the next one, bounding each group by the buffer limit
+func streamSentenceGroups(s *streamBuffer, belongs func(group []string, sentence string) (float64, bool), fn func(sentenceGroup) error) error {
+	var group sentenceGroup
+	var texts []string
+	size := 0
+
+	err := forEachStreamSentence(s, func(u streamUnit) error {
+		if len(texts) > 0 {
+			score, ok := belongs(texts, u.text)
+			if !ok || size+len(u.text) > s.maxSize {
+				group.score, group.scored = score, !ok
+				if err := fn(group); err != nil {
+					return err
+				}
+				group, texts, size = sentenceGroup{}, nil, 0
+			}
+		}
+		group.sentences = append(group.sentences, u)
+		texts = append(texts, u.text)
+		size += len(u.text) + 1
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(texts) > 0 {
+		return fn(group)
+	}
+	return nil
+}
+
+func streamGroupedSegments(s *streamBuffer, segType string, threshold float64, emit func(Segment) error) error {
+	belongs := func(group []string, sentence string) (float64, bool) {
+		var score float64
+		if segType == "topic" {
+			score = calculateTopicSimilarity(strings.Join(group, " "), sentence)
+		} else {
+			score = calculateSemanticCoherence(group, sentence)
+		}
+		return score, score >= threshold
+	}
+
+	return streamSentenceGroups(s, belongs, func(g sentenceGroup) error {
+		seg := streamSegment(g.text(), g.start(), segType, false)
+		seg.End = g.end()
+		if segType == "topic" {
+			seg.Metadata["sentenceCount"] = len(g.sentences)
+		} else if g.scored {
+			seg.Metadata["coherence"] = g.score
+		}
+		return emit(seg)
+	})
+}
+
+func streamFixedSegments(s *streamBuffer, size int, emit func(Segment) error) error {
+	index := 0
+	for {
+		data := s.data()
+		if !s.eof && len(data) < s.maxSize {
+			if err := s.fill(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		text := string(data)
+		segments := segmentByFixedSize(text, size)
+		if len(segments) == 0 {
+			return nil
+		}
+
+		// The last segment may continue past the buffer, so it waits for more input
+		keep := len(segments)
+		if !s.eof {
+			if keep > 1 {
+				keep--
+			} else {
+				segments = segmentByFixedSize(text[:runeSafeCut(data, len(data))], size)
+				keep = len(segments)
+			}
+		}
+
+		for _, seg := range segments[:keep] {
+			seg.Start += s.base
+			seg.End += s.base
+			seg.Metadata["index"] = index
+			index++
+			if err := emit(seg); err != nil {
+				return err
+			}
+		}
+		s.consume(segments[keep-1].End)
+	}
+}
+
+// unitPacker fills chunks with whole sentences, paragraphs or groups up to
+// strategy.MaxTokens
+type unitPacker struct {
+	strategy ChunkingStrategy
+	sep      string
+	key      string
+	emit     func(TextChunk) error
+
+	parts      []string
+	start, end int
+	tokens     int
+	index      int
+}
+
+func (p *unitPacker) add(text string, start, end int) error {
+	tokens := p.strategy.countTokens(text)
+	if p.tokens+tokens > p.strategy.MaxTokens && len(p.parts) > 0 {
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+	if len(p.parts) == 0 {
+		p.start = start
+	}
+	p.parts = append(p.parts, text)
+	p.end = end
+	p.tokens += tokens
+	return nil
+}
+
+func (p *unitPacker) flush() error {
+	if len(p.parts) == 0 {
+		return nil
+	}
+	chunk := TextChunk{
+		Content:     strings.Join(p.parts, p.sep),
+		Index:       p.index,
+		StartOffset: p.start,
+		EndOffset:   p.end,
+		TokenCount:  p.tokens,
+		Metadata: map[string]interface{}{
+			p.key: len(p.parts),
+		},
+	}
+	p.index++
+	p.parts, p.tokens = nil, 0
+	return p.emit(chunk)
+}
+
+// streamWordWindows packs words into windows the way tokenWindows does,
+// keeping only the current window in memory
+func streamWordWindows(s *streamBuffer, strategy ChunkingStrategy, sliding bool, emit func(TextChunk) error) error {
+	size := strategy.MaxTokens
+	if size <= 0 {
+		size = 100
+	}
+	overlap := strategy.Overlap
+	if sliding {
+		overlap = min(overlap, size-1)
+	}
+
+	type word struct {
+		unit streamUnit
+		cost int
+	}
+	var window []word
+	tokens, shared, index := 0, 0, 0
+
+	send := func() error {
+		texts := make([]string, len(window))
+		for i, w := range window {
+			texts[i] = w.unit.text
+		}
+		last := window[len(window)-1].unit
+		chunk := TextChunk{
+			Content:     strings.Join(texts, " "),
+			Index:       index,
+			StartOffset: window[0].unit.start,
+			EndOffset:   last.start + len(last.text),
+			TokenCount:  tokens,
+			Metadata:    map[string]interface{}{},
+		}
+		if sliding {
+			chunk.Metadata["window"] = size
+			chunk.Metadata["stride"] = size - overlap
+		}
+		if index > 0 && (sliding || overlap > 0) {
+			chunk.OverlapEnd = min(shared, len(window))
+		}
+		index++
+		return emit(chunk)
+	}
+
+	first := true
+	for {
+		unit, ok, err := s.nextWord()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		cost := 1
+		if strategy.TokenCounter != nil {
+			if first {
+				cost = strategy.TokenCounter.CountTokens(unit.text)
+			} else {
+				cost = strategy.TokenCounter.CountTokens(" " + unit.text)
+			}
+		}
+		first = false
+
+		if len(window) > shared && tokens+cost > size {
+			if err := send(); err != nil {
+				return err
+			}
+
+			// Step back from the end until the overlap budget is used
+			next, overlapTokens := len(window), 0
+			for next > 1 && overlap > 0 && overlapTokens+window[next-1].cost <= overlap {
+				next--
+				overlapTokens += window[next].cost
+			}
+			window = append([]word{}, window[next:]...)
+			shared, tokens = len(window), overlapTokens
+		}
+
+		window = append(window, word{unit, cost})
+		tokens += cost
+	}
+
+	if len(window) > shared {
+		return send()
+	}
+	return nil
+}
+
+// streamMarkdownChunks parses the stream block by block, holding back the
+// last block of each read since more input may extend it
+func streamMarkdownChunks(s *streamBuffer, strategy ChunkingStrategy, emit func(TextChunk) error) error {
+	chunker := newMarkdownChunker(strategy)
+	var stack []markdownHeading
+
+	send := func(chunks []TextChunk) error {
+		for _, chunk := range chunks {
+			if err := emit(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		if !s.eof {
+			if err := s.fill(); err != nil {
+				return err
+			}
+		}
+
+		data := s.data()
+		text := string(data)
+		blocks := parseMarkdownBlocks(text, s.base, stack)
+
+		keep := len(blocks)
+		if !s.eof {
+			if keep > 1 {
+				keep--
+			} else if len(data) >= s.maxSize {
+				// A single block fills the buffer: cut it at a line break
+				text = text[:forcedCut(data, s.maxSize)]
+				blocks = parseMarkdownBlocks(text, s.base, stack)
+				keep = len(blocks)
+				if keep == 0 {
+					s.consume(len(text))
+					continue
+				}
+			} else {
+				keep = 0
+			}
+		}
+
+		prevEnd := s.base
+		for i := range blocks[:keep] {
+			block := blocks[i]
+			block.Text = strings.Clone(block.Text)
+			gap := text[prevEnd-s.base : block.Start-s.base]
+			prevEnd = block.End
+			if block.Type == "heading" && len(block.Headings) > 0 {
+				stack = pushMarkdownHeading(stack, block.Level, block.Headings[len(block.Headings)-1])
+			}
+			if err := send(chunker.add(&block, strings.Clone(gap))); err != nil {
+				return err
+			}
+		}
+		s.consume(prevEnd - s.base)
+
+		if s.eof && keep == len(blocks) {
+			return send(chunker.flush())
+		}
+	}
+}
@@ -0,0 +1,1077 @@
+package textlib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// maxMediaBlock caps how much of a tag, header or index is read into memory
+const maxMediaBlock = 16 << 20
+
+// mpegFrame is a decoded MPEG audio frame header
+type mpegFrame struct {
+	version    string // "1", "2" or "2.5"
+	layer      int
+	bitrate    int // kbit/s
+	sampleRate int
+	channels   int
+	samples    int // samples per frame
+	length     int // frame size in bytes, header included
+	sideInfo   int // layer 3 side information size
+}
+
+var (
+	// mpegBitrates is indexed by [MPEG-1 or not][layer-1][bitrate index]
+	mpegBitrates = [2][3][15]int{
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+	// mpegSampleRates is indexed by the header version bits
+	mpegSampleRates = [4][3]int{
+		{11025, 12000, 8000}, {}, {22050, 24000, 16000}, {44100, 48000, 32000},
+	}
+)
+
+// id3Genres are the ID3v1 genre names, also used by numeric ID3v2 and MP4 genres
+var id3Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// AudioMetadata fields filled by each tag format
+var (
+	id3Fields = map[string]string{
+		"TIT2": "title", "TPE1": "artist", "TPE2": "albumartist", "TALB": "album",
+		"TYER": "year", "TDRC": "year", "TCON": "genre", "TRCK": "track", "COMM": "comment",
+	}
+	// id3v22Frames maps ID3v2.2 three-letter frame IDs to their v2.3 names
+	id3v22Frames = map[string]string{
+		"TT2": "TIT2", "TP1": "TPE1", "TP2": "TPE2", "TAL": "TALB", "TYE": "TYER",
+		"TCO": "TCON", "TRK": "TRCK", "TLE": "TLEN", "TCM": "TCOM", "COM": "COMM",
+		"TXX": "TXXX", "PIC": "APIC",
+	}
+	vorbisFields = map[string]string{
+		"TITLE": "title", "ARTIST": "artist", "ALBUMARTIST": "albumartist", "ALBUM": "album",
+		"DATE": "year", "GENRE": "genre", "TRACKNUMBER": "track", "COMMENT": "comment",
+		"DESCRIPTION": "comment",
+	}
+	riffInfoFields = map[string]string{
+		"INAM": "title", "IART": "artist", "IPRD": "album", "ICRD": "year",
+		"IGNR": "genre", "ITRK": "track", "ICMT": "comment",
+	}
+	mp4Fields = map[string]string{
+		"©nam": "title", "©ART": "artist", "aART": "albumartist", "©alb": "album",
+		"©day": "year", "©gen": "genre", "©cmt": "comment",
+	}
+	mp4AudioCodecs = map[string]string{
+		"mp4a": "AAC", "alac": "ALAC", "Opus": "Opus", "fLaC": "FLAC",
+		"ac-3": "AC-3", "ec-3": "E-AC-3", ".mp3": "MP3",
+	}
+	wavCodecs = map[uint16]string{
+		0x0001: "PCM", 0x0002: "MS ADPCM", 0x0003: "IEEE float", 0x0006: "A-law",
+		0x0007: "mu-law", 0x0011: "IMA ADPCM", 0x0050: "MP2", 0x0055: "MP3",
+		0x00FF: "AAC", 0x0161: "WMA", 0x2000: "AC-3", 0x2001: "DTS",
+	}
+)
+
+// detectAudioFormat identifies the container from its magic bytes. The
+// returned offset is where the stream starts after a leading ID3v2 tag,
+// which some taggers also put in front of FLAC files.
+func detectAudioFormat(r io.ReaderAt) (string, int64, error) {
+	header := make([]byte, 12)
+	n, _ := r.ReadAt(header, 0)
+	header = header[:n]
+
+	if n >= 10 && string(header[:3]) == "ID3" {
+		offset := id3v2Size(header)
+		magic := make([]byte, 4)
+		if k, _ := r.ReadAt(magic, offset); k == 4 && string(magic) == "fLaC" {
+			return "FLAC", offset, nil
+		}
+		return "MP3", 0, nil
+	}
+	switch {
+	case n >= 4 && string(header[:4]) == "fLaC":
+		return "FLAC", 0, nil
+	case n >= 4 && string(header[:4]) == "OggS":
+		return "OGG", 0, nil
+	case n >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		return "WAV", 0, nil
+	case n >= 8 && string(header[4:8]) == "ftyp":
+		return "M4A", 0, nil
+	case n >= 4:
+		if _, ok := parseMPEGHeader(header); ok {
+			return "MP3", 0, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unsupported audio format")
+}
+
+// readMediaBlock reads up to n bytes at off, capped at maxMediaBlock. Short
+// reads return what was available so truncated files still yield tags.
+func readMediaBlock(r io.ReaderAt, off, n int64) []byte {
+	if n < 0 {
+		return nil
+	}
+	if n > maxMediaBlock {
+		n = maxMediaBlock
+	}
+	buf := make([]byte, n)
+	k, _ := r.ReadAt(buf, off)
+	return buf[:k]
+}
+
+// audioDuration converts a sample count to a duration without overflow
+func audioDuration(samples, rate int64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(samples/rate)*time.Second + time.Duration(samples%rate)*time.Second/time.Duration(rate)
+}
+
+// audioBitrate returns the average bitrate in kbit/s of size bytes played over d
+func audioBitrate(size int64, d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(size) * 8 / d.Seconds() / 1000))
+}
+
+// setAudioTag records a tag under its native key and fills the AudioMetadata
+// field it maps to. Repeated keys are joined; fields set by an earlier tag
+// are kept, so ID3v2 wins over ID3v1. An empty key only fills the field.
+func setAudioTag(m *AudioMetadata, key, field, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if key != "" {
+		if m.Tags == nil {
+			m.Tags = make(map[string]string)
+		}
+		if prev, ok := m.Tags[key]; ok {
+			value = prev + "; " + value
+		}
+		m.Tags[key] = value
+	}
+
+	switch field {
+	case "title":
+		if m.Title == "" {
+			m.Title = value
+		}
+	case "artist":
+		if m.Artist == "" {
+			m.Artist = value
+		}
+	case "albumartist":
+		if m.AlbumArtist == "" {
+			m.AlbumArtist = value
+		}
+	case "album":
+		if m.Album == "" {
+			m.Album = value
+		}
+	case "genre":
+		if m.Genre == "" {
+			m.Genre = value
+		}
+	case "comment":
+		if m.Comment == "" {
+			m.Comment = value
+		}
+	case "year":
+		if m.Year == 0 && len(value) >= 4 {
+			m.Year, _ = strconv.Atoi(value[:4])
+		}
+	case "track":
+		if m.TrackNum == 0 {
+			number, _, _ := strings.Cut(value, "/")
+			m.TrackNum, _ = strconv.Atoi(strings.TrimSpace(number))
+		}
+	}
+}
+
+// id3Genre resolves "(17)", "(17)Rock" and "17" style ID3 genres to names
+func id3Genre(value string) string {
+	if strings.HasPrefix(value, "(") {
+		if end := strings.IndexByte(value, ')'); end > 0 {
+			if rest := strings.TrimSpace(value[end+1:]); rest != "" {
+				return rest
+			}
+			value = value[1:end]
+		}
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 && n < len(id3Genres) {
+		return id3Genres[n]
+	}
+	return value
+}
+
+// syncsafe decodes a 28-bit ID3v2 integer stored 7 bits per byte
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// id3v2Size returns the full size of an ID3v2 tag from its 10-byte header
+func id3v2Size(header []byte) int64 {
+	size := int64(10 + syncsafe(header[6:10]))
+	if header[3] >= 4 && header[5]&0x10 != 0 {
+		size += 10 // footer
+	}
+	return size
+}
+
+// id3Unsync removes the 0x00 stuffed after each 0xFF by unsynchronisation
+func id3Unsync(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// parseID3v2 reads the frames of an ID3v2.2, 2.3 or 2.4 tag, header
+// included. Compressed and encrypted frames are skipped and a damaged
+// frame ends the walk.
+func parseID3v2(tag []byte, m *AudioMetadata) {
+	if len(tag) < 10 || string(tag[:3]) != "ID3" {
+		return
+	}
+	major, flags := tag[3], tag[5]
+	body := tag[10:]
+	if size := syncsafe(tag[6:10]); size < len(body) {
+		body = body[:size]
+	}
+	// Before v2.4 unsynchronisation covers the whole tag; v2.4 flags each frame
+	if flags&0x80 != 0 && major < 4 {
+		body = id3Unsync(body)
+	}
+	if flags&0x40 != 0 && major >= 3 && len(body) >= 4 {
+		ext := syncsafe(body[:4])
+		if major == 3 {
+			ext = int(binary.BigEndian.Uint32(body)) + 4
+		}
+		if ext < 0 || ext > len(body) {
+			return
+		}
+		body = body[ext:]
+	}
+
+	idLen, headerLen := 4, 10
+	if major == 2 {
+		idLen, headerLen = 3, 6
+	}
+	for len(body) >= headerLen && body[0] != 0 {
+		id := string(body[:idLen])
+		var size int
+		var frameFlags byte
+		switch major {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+			id = id3v22Frames[id]
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:]))
+			frameFlags = body[9]
+		default:
+			size = syncsafe(body[4:8])
+			frameFlags = body[9]
+		}
+		if size < 0 || size > len(body)-headerLen {
+			return
+		}
+		data := body[headerLen : headerLen+size]
+		body = body[headerLen+size:]
+
+		switch {
+		case major == 3 && frameFlags&0xC0 != 0, major >= 4 && frameFlags&0x0C != 0:
+			continue // compressed or encrypted
+		case major == 3 && frameFlags&0x20 != 0, major >= 4 && frameFlags&0x40 != 0:
+			data = data[min(1, len(data)):] // group identifier
+		}
+		if major >= 4 {
+			if frameFlags&0x01 != 0 {
+				data = data[min(4, len(data)):] // data length indicator
+			}
+			if frameFlags&0x02 != 0 {
+				data = id3Unsync(data)
+			}
+		}
+		applyID3Frame(id, data, m)
+	}
+}
+
+// applyID3Frame stores one ID3v2 frame using its v2.3/v2.4 ID
+func applyID3Frame(id string, data []byte, m *AudioMetadata) {
+	if id == "" || len(data) == 0 {
+		return
+	}
+	enc := data[0]
+	switch {
+	case id == "APIC":
+		m.HasCoverArt = true
+	case id == "TXXX":
+		values := id3Strings(enc, data[1:])
+		if len(values) >= 2 {
+			setAudioTag(m, "TXXX:"+values[0], "", strings.Join(values[1:], "; "))
+		}
+	case id == "COMM":
+		if len(data) < 4 {
+			return
+		}
+		// language code, then a short description before the text
+		values := id3Strings(enc, data[4:])
+		if len(values) >= 2 {
+			setAudioTag(m, id, id3Fields[id], strings.Join(values[1:], "; "))
+		}
+	case id[0] == 'T':
+		values := id3Strings(enc, data[1:])
+		if id == "TCON" {
+			for i, v := range values {
+				values[i] = id3Genre(v)
+			}
+		}
+		setAudioTag(m, id, id3Fields[id], strings.Join(nonEmpty(values), "; "))
+	}
+}
+
+// nonEmpty drops empty strings
+func nonEmpty(values []string) []string {
+	out := values[:0]
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// id3Strings splits NUL-terminated ID3 strings in the given text encoding:
+// 0 ISO-8859-1, 1 UTF-16 with BOM, 2 UTF-16BE, 3 UTF-8. A trailing empty
+// string is dropped but an empty description before a value is kept.
+func id3Strings(enc byte, b []byte) []string {
+	var values []string
+	for len(b) > 0 {
+		end, next := len(b), len(b)
+		if enc == 1 || enc == 2 {
+			for i := 0; i+1 < len(b); i += 2 {
+				if b[i] == 0 && b[i+1] == 0 {
+					end, next = i, i+2
+					break
+				}
+			}
+		} else if i := bytes.IndexByte(b, 0); i >= 0 {
+			end, next = i, i+1
+		}
+		values = append(values, decodeID3String(enc, b[:end]))
+		b = b[next:]
+	}
+	return values
+}
+
+// decodeID3String decodes one string in an ID3 text encoding
+func decodeID3String(enc byte, b []byte) string {
+	switch enc {
+	case 0:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	case 1, 2:
+		var order binary.ByteOrder = binary.BigEndian
+		if enc == 1 && len(b) >= 2 {
+			switch {
+			case b[0] == 0xFF && b[1] == 0xFE:
+				order, b = binary.LittleEndian, b[2:]
+			case b[0] == 0xFE && b[1] == 0xFF:
+				b = b[2:]
+			}
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = order.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units))
+	default:
+		return string(b)
+	}
+}
+
+// parseID3v1 reads a 128-byte ID3v1 or v1.1 tag from the end of a file
+func parseID3v1(tag []byte, m *AudioMetadata) {
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return decodeID3String(0, b)
+	}
+	setAudioTag(m, "", "title", field(tag[3:33]))
+	setAudioTag(m, "", "artist", field(tag[33:63]))
+	setAudioTag(m, "", "album", field(tag[63:93]))
+	setAudioTag(m, "", "year", field(tag[93:97]))
+	setAudioTag(m, "", "comment", field(tag[97:127]))
+	// v1.1 keeps the track number in the last comment byte
+	if tag[125] == 0 && tag[126] != 0 && m.TrackNum == 0 {
+		m.TrackNum = int(tag[126])
+	}
+	if int(tag[127]) < len(id3Genres) {
+		setAudioTag(m, "", "genre", id3Genres[tag[127]])
+	}
+}
+
+// parseMPEGHeader decodes a 4-byte MPEG audio frame header
+func parseMPEGHeader(h []byte) (mpegFrame, bool) {
+	if len(h) < 4 || h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+		return mpegFrame{}, false
+	}
+	versionBits := (h[1] >> 3) & 3
+	layerBits := (h[1] >> 1) & 3
+	bitrateIndex := h[2] >> 4
+	rateIndex := (h[2] >> 2) & 3
+	if versionBits == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mpegFrame{}, false
+	}
+
+	f := mpegFrame{layer: 4 - int(layerBits), channels: 2}
+	table := 1
+	switch versionBits {
+	case 3:
+		f.version, table = "1", 0
+	case 2:
+		f.version = "2"
+	default:
+		f.version = "2.5"
+	}
+	f.bitrate = mpegBitrates[table][f.layer-1][bitrateIndex]
+	f.sampleRate = mpegSampleRates[versionBits][rateIndex]
+	if h[3]>>6 == 3 {
+		f.channels = 1
+	}
+	padding := int(h[2]>>1) & 1
+
+	switch f.layer {
+	case 1:
+		f.samples = 384
+		f.length = (12*f.bitrate*1000/f.sampleRate + padding) * 4
+	case 2:
+		f.samples = 1152
+		f.length = 144*f.bitrate*1000/f.sampleRate + padding
+	default:
+		f.samples = 1152
+		if table == 1 {
+			f.samples = 576
+		}
+		f.length = f.samples/8*f.bitrate*1000/f.sampleRate + padding
+		switch {
+		case table == 0 && f.channels == 1, table == 1 && f.channels == 2:
+			f.sideInfo = 17
+		case table == 0:
+			f.sideInfo = 32
+		default:
+			f.sideInfo = 9
+		}
+	}
+	return f, true
+}
+
+// isMPEGInfoFrame reports whether a frame is a Xing, Info or VBRI header,
+// which encoders write as a silent frame before the audio
+func isMPEGInfoFrame(data []byte, f mpegFrame) bool {
+	if f.layer != 3 {
+		return false
+	}
+	if p := 4 + f.sideInfo; len(data) >= p+4 && (string(data[p:p+4]) == "Xing" || string(data[p:p+4]) == "Info") {
+		return true
+	}
+	return len(data) >= 40 && string(data[36:40]) == "VBRI"
+}
+
+// scanMPEGFrames walks every frame of an MPEG audio stream, so duration is
+// exact for both CBR and VBR files. Junk before the first frame is skipped
+// when the next header confirms the sync; the first bad header after that
+// ends the stream. Incomplete trailing frames are not counted.
+func scanMPEGFrames(r io.Reader, m *AudioMetadata) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	var first mpegFrame
+	var samples, size int64
+	found := false
+
+	for skipped := 0; skipped < 64<<10; {
+		h, err := br.Peek(4)
+		if err != nil {
+			break
+		}
+		f, ok := parseMPEGHeader(h)
+		if ok && !found {
+			// Accept the first header only if another follows it or the file ends
+			if next, err := br.Peek(f.length + 4); err == nil {
+				g, ok2 := parseMPEGHeader(next[f.length:])
+				ok = ok2 && g.version == f.version && g.layer == f.layer && g.sampleRate == f.sampleRate
+			}
+		}
+		if !ok {
+			if found {
+				break
+			}
+			br.Discard(1)
+			skipped++
+			continue
+		}
+
+		if !found {
+			found, first = true, f
+			m.Codec = fmt.Sprintf("MPEG-%s Layer %d", f.version, f.layer)
+			m.SampleRate, m.Channels, m.Bitrate = f.sampleRate, f.channels, f.bitrate
+			if data, _ := br.Peek(min(f.length, 40)); isMPEGInfoFrame(data, f) {
+				br.Discard(f.length)
+				continue
+			}
+		}
+		if n, _ := br.Discard(f.length); n < f.length {
+			break
+		}
+		samples += int64(f.samples)
+		size += int64(f.length)
+	}
+
+	if samples > 0 {
+		m.Duration = audioDuration(samples, int64(first.sampleRate))
+		m.Bitrate = audioBitrate(size, m.Duration)
+	}
+}
+
+func extractMP3Metadata(file *os.File, metadata AudioMetadata) (AudioMetadata, error) {
+	start, end := int64(0), metadata.FileSize
+	header := make([]byte, 10)
+	if n, _ := file.ReadAt(header, 0); n == 10 && string(header[:3]) == "ID3" {
+		start = id3v2Size(header)
+		parseID3v2(readMediaBlock(file, 0, start), &metadata)
+	}
+	if end-start >= 128 {
+		tail := readMediaBlock(file, end-128, 128)
+		if len(tail) == 128 && string(tail[:3]) == "TAG" {
+			parseID3v1(tail, &metadata)
+			end -= 128
+		}
+	}
+	if start < end {
+		scanMPEGFrames(io.NewSectionReader(file, start, end-start), &metadata)
+	}
+	return metadata, nil
+}
+
+func extractWAVMetadata(file *os.File, metadata AudioMetadata) (AudioMetadata, error) {
+	var byteRate, blockAlign, dataSize int64
+	header := make([]byte, 8)
+	for off := int64(12); off+8 <= metadata.FileSize; {
+		if _, err := file.ReadAt(header, off); err != nil {
+			break
+		}
+		id, size := string(header[:4]), int64(binary.LittleEndian.Uint32(header[4:]))
+		body := off + 8
+
+		switch id {
+		case "fmt ":
+			b := readMediaBlock(file, body, size)
+			if len(b) < 16 {
+				break
+			}
+			codec := binary.LittleEndian.Uint16(b)
+			// WAVE_FORMAT_EXTENSIBLE keeps the real format in its sub-format GUID
+			if codec == 0xFFFE && len(b) >= 26 {
+				codec = binary.LittleEndian.Uint16(b[24:])
+			}
+			metadata.Codec = wavCodecs[codec]
+			if metadata.Codec == "" {
+				metadata.Codec = fmt.Sprintf("0x%04X", codec)
+			}
+			metadata.Channels = int(binary.LittleEndian.Uint16(b[2:]))
+			metadata.SampleRate = int(binary.LittleEndian.Uint32(b[4:]))
+			byteRate = int64(binary.LittleEndian.Uint32(b[8:]))
+			blockAlign = int64(binary.LittleEndian.Uint16(b[12:]))
+			metadata.BitsPerSample = int(binary.LittleEndian.Uint16(b[14:]))
+		case "data":
+			// Streaming writers leave the size at 0 or 0xFFFFFFFF
+			dataSize = size
+			if rest := metadata.FileSize - body; size == 0 || dataSize > rest {
+				dataSize = rest
+			}
+		case "LIST":
+			if b := readMediaBlock(file, body, size); len(b) >= 4 && string(b[:4]) == "INFO" {
+				parseRIFFInfo(b[4:], &metadata)
+			}
+		case "id3 ", "ID3 ":
+			parseID3v2(readMediaBlock(file, body, size), &metadata)
+		}
+		off = body + size + size&1
+	}
+
+	if byteRate > 0 {
+		metadata.Bitrate = int(byteRate * 8 / 1000)
+	}
+	if blockAlign > 0 && metadata.SampleRate > 0 {
+		metadata.Duration = audioDuration(dataSize/blockAlign, int64(metadata.SampleRate))
+	}
+	return metadata, nil
+}
+
+// riffChunks calls fn for each chunk in a RIFF chunk list; a chunk that
+// overruns data ends the walk
+func riffChunks(data []byte, fn func(id string, body []byte)) {
+	for len(data) >= 8 {
+		id, size := string(data[:4]), int64(binary.LittleEndian.Uint32(data[4:]))
+		if size > int64(len(data))-8 {
+			return
+		}
+		fn(id, data[8:8+size])
+		data = data[min(int(8+size+size&1), len(data)):]
+	}
+}
+
+// parseRIFFInfo reads the sub-chunks of a LIST INFO chunk
+func parseRIFFInfo(b []byte, m *AudioMetadata) {
+	riffChunks(b, func(id string, value []byte) {
+		if i := bytes.IndexByte(value, 0); i >= 0 {
+			value = value[:i]
+		}
+		setAudioTag(m, id, riffInfoFields[id], string(value))
+	})
+}
+
+func extractFLACMetadata(file *os.File, offset int64, metadata AudioMetadata) (AudioMetadata, error) {
+	metadata.Codec = "FLAC"
+	if offset > 0 {
+		parseID3v2(readMediaBlock(file, 0, offset), &metadata)
+	}
+
+	var totalSamples int64
+	header := make([]byte, 4)
+	off := offset + 4
+	for {
+		if _, err := file.ReadAt(header, off); err != nil {
+			break
+		}
+		last, typ := header[0]&0x80 != 0, header[0]&0x7F
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		switch typ {
+		case 0:
+			totalSamples = applyFLACStreamInfo(readMediaBlock(file, off+4, size), &metadata)
+		case 4:
+			applyVorbisComment(readMediaBlock(file, off+4, size), &metadata)
+		case 6:
+			metadata.HasCoverArt = true
+		}
+		off += 4 + size
+		if last {
+			break
+		}
+	}
+
+	if totalSamples > 0 && metadata.SampleRate > 0 {
+		metadata.Duration = audioDuration(totalSamples, int64(metadata.SampleRate))
+		if audio := metadata.FileSize - off; audio > 0 {
+			metadata.Bitrate = audioBitrate(audio, metadata.Duration)
+		}
+	}
+	return metadata, nil
+}
+
+// applyFLACStreamInfo reads a STREAMINFO block and returns the total
+// sample count, which is zero when the encoder did not know it
+func applyFLACStreamInfo(b []byte, m *AudioMetadata) int64 {
+	if len(b) < 18 {
+		return 0
+	}
+	m.SampleRate = int(b[10])<<12 | int(b[11])<<4 | int(b[12])>>4
+	m.Channels = int(b[12]>>1&7) + 1
+	m.BitsPerSample = int(b[12]&1)<<4 | int(b[13]>>4) + 1
+	return int64(b[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(b[14:]))
+}
+
+// applyVorbisComment reads a Vorbis comment block (vendor string, then
+// KEY=value entries with little-endian lengths) as used by FLAC, Vorbis and Opus
+func applyVorbisComment(b []byte, m *AudioMetadata) {
+	if len(b) < 4 {
+		return
+	}
+	vendor := int64(binary.LittleEndian.Uint32(b))
+	if vendor > int64(len(b))-8 {
+		return
+	}
+	b = b[4+vendor:]
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	for i := uint32(0); i < count && len(b) >= 4; i++ {
+		n := int64(binary.LittleEndian.Uint32(b))
+		if n > int64(len(b))-4 {
+			return
+		}
+		entry := string(b[4 : 4+n])
+		b = b[4+n:]
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToUpper(key)
+		if key == "METADATA_BLOCK_PICTURE" || key == "COVERART" {
+			m.HasCoverArt = true
+			continue
+		}
+		setAudioTag(m, key, vorbisFields[key], value)
+	}
+}
+
+// readOggPackets returns the first want packets of the first logical
+// stream in an Ogg file and that stream's serial number
+func readOggPackets(r io.Reader, want int) ([][]byte, uint32) {
+	var packets [][]byte
+	var current []byte
+	var serial uint32
+	header := make([]byte, 27)
+	for page := 0; len(packets) < want; page++ {
+		if _, err := io.ReadFull(r, header); err != nil || string(header[:4]) != "OggS" {
+			break
+		}
+		lacing := make([]byte, header[26])
+		if _, err := io.ReadFull(r, lacing); err != nil {
+			break
+		}
+		total := 0
+		for _, l := range lacing {
+			total += int(l)
+		}
+		data := make([]byte, total)
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+
+		pageSerial := binary.LittleEndian.Uint32(header[14:])
+		if page == 0 {
+			serial = pageSerial
+		} else if pageSerial != serial {
+			continue // another multiplexed stream
+		}
+		for _, l := range lacing {
+			current = append(current, data[:l]...)
+			data = data[l:]
+			// A lacing value below 255 ends the packet
+			if l < 255 {
+				packets = append(packets, current)
+				current = nil
+				if len(packets) == want {
+					break
+				}
+			}
+		}
+		if len(current) > maxMediaBlock {
+			break
+		}
+	}
+	return packets, serial
+}
+
+// lastOggGranule returns the granule position of the last page of a
+// stream, found by scanning back from the end of the file
+func lastOggGranule(r io.ReaderAt, size int64, serial uint32) int64 {
+	// A page is at most 65307 bytes, so the last one starts within this window
+	const window = 1 << 17
+	start := size - window
+	if start < 0 {
+		start = 0
+	}
+	buf := readMediaBlock(r, start, size-start)
+	for i := bytes.LastIndex(buf, []byte("OggS")); i >= 0; i = bytes.LastIndex(buf[:i], []byte("OggS")) {
+		if i+27 > len(buf) || binary.LittleEndian.Uint32(buf[i+14:]) != serial {
+			continue
+		}
+		// -1 marks a page on which no packet ends
+		if granule := int64(binary.LittleEndian.Uint64(buf[i+6:])); granule >= 0 {
+			return granule
+		}
+	}
+	return 0
+}
+
+func extractOGGMetadata(file *os.File, metadata AudioMetadata) (AudioMetadata, error) {
+	packets, serial := readOggPackets(io.NewSectionReader(file, 0, metadata.FileSize), 2)
+	var preSkip int64
+	rate := 0
+	if len(packets) > 0 {
+		id := packets[0]
+		var comment []byte
+		if len(packets) > 1 {
+			comment = packets[1]
+		}
+
+		switch {
+		case len(id) >= 28 && string(id[:7]) == "\x01vorbis":
+			metadata.Codec = "Vorbis"
+			metadata.Channels = int(id[11])
+			metadata.SampleRate = int(binary.LittleEndian.Uint32(id[12:]))
+			rate = metadata.SampleRate
+			if nominal := int32(binary.LittleEndian.Uint32(id[20:])); nominal > 0 {
+				metadata.Bitrate = int(nominal / 1000)
+			}
+			if len(comment) > 7 && string(comment[:7]) == "\x03vorbis" {
+				applyVorbisComment(comment[7:], &metadata)
+			}
+		case len(id) >= 19 && string(id[:8]) == "OpusHead":
+			// Opus always decodes at 48 kHz; the header keeps the input rate
+			metadata.Codec = "Opus"
+			metadata.Channels = int(id[9])
+			preSkip = int64(binary.LittleEndian.Uint16(id[10:]))
+			metadata.SampleRate = int(binary.LittleEndian.Uint32(id[12:]))
+			rate = 48000
+			if len(comment) > 8 && string(comment[:8]) == "OpusTags" {
+				applyVorbisComment(comment[8:], &metadata)
+			}
+		case len(id) >= 51 && string(id[:5]) == "\x7fFLAC":
+			metadata.Codec = "FLAC"
+			applyFLACStreamInfo(id[17:], &metadata)
+			rate = metadata.SampleRate
+			if len(comment) > 4 && comment[0]&0x7F == 4 {
+				applyVorbisComment(comment[4:], &metadata)
+			}
+		}
+	}
+
+	if samples := lastOggGranule(file, metadata.FileSize, serial) - preSkip; samples > 0 && rate > 0 {
+		metadata.Duration = audioDuration(samples, int64(rate))
+		metadata.Bitrate = audioBitrate(metadata.FileSize, metadata.Duration)
+	}
+	return metadata, nil
+}
+
+// mp4Boxes calls fn for each ISO-BMFF box in data; a box that overruns
+// data ends the walk
+func mp4Boxes(data []byte, fn func(typ string, body []byte)) {
+	for len(data) >= 8 {
+		size, headerLen := uint64(binary.BigEndian.Uint32(data)), uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size, headerLen = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < headerLen || size > uint64(len(data)) {
+			return
+		}
+		fn(string(data[4:8]), data[headerLen:size])
+		data = data[size:]
+	}
+}
+
+// mp4TimeHeader reads the timescale and duration of an mvhd or mdhd box
+func mp4TimeHeader(body []byte) (scale, duration uint64) {
+	switch {
+	case len(body) >= 32 && body[0] == 1:
+		return uint64(binary.BigEndian.Uint32(body[20:])), binary.BigEndian.Uint64(body[24:])
+	case len(body) >= 20 && body[0] == 0:
+		return uint64(binary.BigEndian.Uint32(body[12:])), uint64(binary.BigEndian.Uint32(body[16:]))
+	}
+	return 0, 0
+}
+
+// mp4Duration converts a duration in timescale units without overflow
+func mp4Duration(duration, scale uint64) time.Duration {
+	if scale == 0 {
+		return 0
+	}
+	return time.Duration(duration/scale)*time.Second + time.Duration(duration%scale*uint64(time.Second)/scale)
+}
+
+// readMP4File walks the top-level boxes of an ISO-BMFF file, returning the
+// ftyp and moov bodies and the total size of the mdat media data
+func readMP4File(r io.ReaderAt, size int64) (ftyp, moov []byte, mdat int64) {
+	header := make([]byte, 16)
+	for off := int64(0); off+8 <= size; {
+		n, _ := r.ReadAt(header, off)
+		boxSize, headerLen := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - off
+		case 1:
+			if n < 16 {
+				return
+			}
+			boxSize, headerLen = int64(binary.BigEndian.Uint64(header[8:])), 16
+		}
+		if boxSize < headerLen {
+			return
+		}
+		switch string(header[4:8]) {
+		case "ftyp":
+			ftyp = readMediaBlock(r, off+headerLen, boxSize-headerLen)
+		case "moov":
+			moov = readMediaBlock(r, off+headerLen, boxSize-headerLen)
+		case "mdat":
+			mdat += boxSize - headerLen
+		}
+		off += boxSize
+	}
+	return
+}
+
+func extractM4AMetadata(file *os.File, metadata AudioMetadata) (AudioMetadata, error) {
+	_, moov, mdat := readMP4File(file, metadata.FileSize)
+
+	var scale, duration uint64
+	mp4Boxes(moov, func(typ string, body []byte) {
+		switch typ {
+		case "mvhd":
+			scale, duration = mp4TimeHeader(body)
+		case "trak":
+			applyMP4Track(body, &metadata)
+		case "meta":
+			applyMP4Meta(body, &metadata)
+		case "udta":
+			mp4Boxes(body, func(typ string, body []byte) {
+				if typ == "meta" {
+					applyMP4Meta(body, &metadata)
+				}
+			})
+		}
+	})
+	if metadata.Duration == 0 {
+		metadata.Duration = mp4Duration(duration, scale)
+	}
+	if mdat > 0 {
+		metadata.Bitrate = audioBitrate(mdat, metadata.Duration)
+	}
+	return metadata, nil
+}
+
+// applyMP4Track reads codec, format and duration from the first sound track
+func applyMP4Track(trak []byte, m *AudioMetadata) {
+	var handler, entry string
+	var entryBody []byte
+	var scale, duration uint64
+	var walk func(data []byte)
+	walk = func(data []byte) {
+		mp4Boxes(data, func(typ string, body []byte) {
+			switch typ {
+			case "mdia", "minf", "stbl":
+				walk(body)
+			case "hdlr":
+				if len(body) >= 12 {
+					handler = string(body[8:12])
+				}
+			case "mdhd":
+				scale, duration = mp4TimeHeader(body)
+			case "stsd":
+				if len(body) >= 8 {
+					mp4Boxes(body[8:], func(typ string, body []byte) {
+						if entry == "" {
+							entry, entryBody = typ, body
+						}
+					})
+				}
+			}
+		})
+	}
+	walk(trak)
+	if handler != "soun" || m.Codec != "" {
+		return
+	}
+
+	m.Codec = mp4AudioCodecs[entry]
+	if m.Codec == "" {
+		m.Codec = entry
+	}
+	// AudioSampleEntry: reserved and data reference, then channel count,
+	// sample size and a 16.16 fixed-point sample rate
+	if len(entryBody) >= 28 {
+		m.Channels = int(binary.BigEndian.Uint16(entryBody[16:]))
+		m.BitsPerSample = int(binary.BigEndian.Uint16(entryBody[18:]))
+		m.SampleRate = int(binary.BigEndian.Uint32(entryBody[24:]) >> 16)
+	}
+	if m.SampleRate == 0 {
+		m.SampleRate = int(scale)
+	}
+	m.Duration = mp4Duration(duration, scale)
+}
+
+// applyMP4Meta reads the iTunes ilst item list of a meta box
+func applyMP4Meta(body []byte, m *AudioMetadata) {
+	// iTunes writes meta as a full box; QuickTime files leave out the version
+	if len(body) >= 8 && string(body[4:8]) != "hdlr" {
+		body = body[4:]
+	}
+	mp4Boxes(body, func(typ string, ilst []byte) {
+		if typ == "ilst" {
+			mp4Boxes(ilst, func(typ string, item []byte) {
+				applyMP4Item(typ, item, m)
+			})
+		}
+	})
+}
+
+// applyMP4Item stores one ilst item; freeform "----" items are keyed by name
+func applyMP4Item(typ string, item []byte, m *AudioMetadata) {
+	key := strings.Replace(typ, "\xa9", "©", 1)
+	var value []byte
+	var kind uint32
+	mp4Boxes(item, func(t string, b []byte) {
+		switch t {
+		case "data":
+			if value == nil && len(b) >= 8 {
+				kind, value = binary.BigEndian.Uint32(b)&0xFFFFFF, b[8:]
+			}
+		case "name":
+			if len(b) >= 4 {
+				key = "----:" + string(b[4:])
+			}
+		}
+	})
+	if value == nil {
+		return
+	}
+
+	switch typ {
+	case "covr":
+		m.HasCoverArt = true
+	case "trkn":
+		if len(value) >= 6 {
+			track := fmt.Sprint(binary.BigEndian.Uint16(value[2:]))
+			if total := binary.BigEndian.Uint16(value[4:]); total > 0 {
+				track += fmt.Sprintf("/%d", total)
+			}
+			setAudioTag(m, key, "track", track)
+		}
+	case "gnre":
+		if len(value) >= 2 {
+			if n := int(binary.BigEndian.Uint16(value)) - 1; n >= 0 && n < len(id3Genres) {
+				setAudioTag(m, key, "genre", id3Genres[n])
+			}
+		}
+	default:
+		if kind == 1 { // UTF-8 text
+			setAudioTag(m, key, mp4Fields[key], string(value))
+		}
+	}
+}
@@ -16,6 +16,7 @@ package textlib
 
 import (
 	"testing"
+	"time"
 )
 
 // Tests for basic functions to improve coverage safely
@@ -122,7 +123,7 @@ func TestIsReadableText(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isReadableText(tt.content)
+			result := isReadableText(string(tt.content))
 			if result != tt.expected {
 				t.Errorf("isReadableText(%q) = %v, expected %v", string(tt.content), result, tt.expected)
 			}
@@ -130,32 +131,25 @@ func TestIsReadableText(t *testing.T) {
 	}
 }
 
-func TestExtractID3Field(t *testing.T) {
+func TestParseID3v2Truncated(t *testing.T) {
 	tests := []struct {
-		name   string
-		data   []byte
-		offset int
-		length int
+		name string
+		data []byte
 	}{
 		{
-			name:   "Valid ID3 data",
-			data:   []byte("ID3\x03\x00\x00\x00\x00\x00\x00TITLE"),
-			offset: 10,
-			length: 5,
+			name: "Valid ID3 data",
+			data: []byte("ID3\x03\x00\x00\x00\x00\x00\x00TITLE"),
 		},
 		{
-			name:   "Empty data",
-			data:   []byte{},
-			offset: 0,
-			length: 0,
+			name: "Empty data",
+			data: []byte{},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractID3Field(tt.data, tt.offset, tt.length)
 			// Just test that it doesn't panic
-			_ = result
+			parseID3v2(tt.data, &AudioMetadata{})
 		})
 	}
 }
@@ -208,31 +202,25 @@ func TestIsPrivateIP(t *testing.T) {
 	}
 }
 
-func TestCheckPEPacking(t *testing.T) {
+func TestCheckPEPackingEmpty(t *testing.T) {
 	tests := []struct {
-		name string
-		data []byte
+		name     string
+		sections []Section
 	}{
 		{
-			name: "PE header",
-			data: []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xFF\xFF\x00\x00"),
+			name:     "No sections",
+			sections: nil,
 		},
 		{
-			name: "Non-PE data",
-			data: []byte("Not a PE file"),
-		},
-		{
-			name: "Empty data",
-			data: []byte{},
+			name:     "Zero-sized sections",
+			sections: []Section{{Name: ".text"}, {Name: ".data"}, {Name: ".rsrc"}},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			packed, ratio := checkPEPacking(tt.data)
 			// Just test that it doesn't panic
-			_ = packed
-			_ = ratio
+			_ = checkPEPacking(tt.sections)
 		})
 	}
 }
@@ -264,9 +252,9 @@ func TestIsDirEmpty(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isDirEmpty(tt.path)
+			result, err := isDirEmpty(tt.path)
 			// Just test that it doesn't panic
-			_ = result
+			_, _ = result, err
 		})
 	}
 }
@@ -380,26 +368,31 @@ require("../utils.js");
 
 func TestGetFilesBySize(t *testing.T) {
 	t.Run("Get files by size", func(t *testing.T) {
-		result := GetFilesBySize(".", 1000) // Files larger than 1KB
+		result, err := GetFilesBySize(".", 1000) // Files larger than 1KB
 		// Just test that it doesn't panic
-		_ = result
+		_, _ = result, err
 	})
 }
 
 func TestGetFilesByAge(t *testing.T) {
 	t.Run("Get files by age", func(t *testing.T) {
-		result := GetFilesByAge(".", 30) // Files older than 30 days
+		result, err := GetFilesByAge(".", 30*24*time.Hour) // Files older than 30 days
 		// Just test that it doesn't panic
-		_ = result
+		_, _ = result, err
 	})
 }
 
 func TestAnalyzeDiskUsage(t *testing.T) {
 	t.Run("Analyze disk usage", func(t *testing.T) {
-		result := AnalyzeDiskUsage(".")
+		result, err := AnalyzeDiskUsage(".")
+		if err != nil {
+			t.Fatalf("AnalyzeDiskUsage returned error: %v", err)
+		}
 		// Just test that it doesn't panic and returns something reasonable
-		if result.TotalSize < 0 {
-			t.Error("AnalyzeDiskUsage returned negative total size")
+		for ext, size := range result {
+			if size < 0 {
+				t.Errorf("AnalyzeDiskUsage returned negative size for %q", ext)
+			}
 		}
 	})
 }
@@ -425,9 +418,9 @@ func TestExtractFileList(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ExtractFileList(tt.filename)
+			result, err := ExtractFileList(tt.filename)
 			// Just test that it doesn't panic
-			_ = result
+			_, _ = result, err
 		})
 	}
 }
\ No newline at end of file
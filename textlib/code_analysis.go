@@ -1359,6 +1359,12 @@ func DetectBraceStyle(code string) BraceStyle {
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
 		
+		// Check if this line is just an opening brace (next line style)
+		if trimmed == "{" {
+			nextLineCount++
+			continue
+		}
+		
 		// Look for opening braces
 		if strings.Contains(trimmed, "{") {
 			// Check if brace is at end of line (same line style)
@@ -1366,11 +1372,6 @@ func DetectBraceStyle(code string) BraceStyle {
 				sameLineCount++
 			}
 		}
-		
-		// Check if this line is just an opening brace (next line style)
-		if trimmed == "{" {
-			nextLineCount++
-		}
 	}
 	
 	if nextLineCount > sameLineCount {
@@ -1506,7 +1507,7 @@ func FindPathTraversalRisks(code string) []Vulnerability {
 	// Patterns that might indicate path traversal vulnerabilities
 	patterns := []*regexp.Regexp{
 		regexp.MustCompile(`\.\./`),                       // Relative path traversal
-		regexp.MustCompile(`\\\.\.\\\`),                     // Windows path traversal
+		regexp.MustCompile(`\\\.\.\\`),                      // Windows path traversal
 		regexp.MustCompile(`(?i)file\s*\+\s*["']`),        // File concatenation
 		regexp.MustCompile(`(?i)path\s*\+\s*["']`),        // Path concatenation
 	}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -41,16 +40,22 @@ type ImageMetadata struct {
 	ColorSpace  string
 	Created     time.Time
 	Camera      CameraInfo
+	Orientation int                 // EXIF orientation 1-8, 0 when absent
+	EXIF        map[string]string   // decoded EXIF and GPS tags by name
+	IPTC        map[string][]string // IPTC-IIM datasets such as "Keywords"
+	XMP         string              // raw XMP packet
 }
 
 type CameraInfo struct {
-	Make     string
-	Model    string
-	ISO      int
-	Aperture string
-	Shutter  string
-	Flash    bool
-	GPS      GPSInfo
+	Make        string
+	Model       string
+	ISO         int
+	Aperture    string // "f/2.8"
+	Shutter     string // exposure time such as "1/125"
+	Flash       bool
+	Lens        string
+	FocalLength string // "50 mm"
+	GPS         GPSInfo
 }
 
 type GPSInfo struct {
@@ -58,22 +63,28 @@ type GPSInfo struct {
 	Longitude float64
 	Altitude  float64
 	HasGPS    bool
+	Timestamp time.Time // GPS date and time, UTC
 }
 
 type AudioMetadata struct {
-	Duration   time.Duration
-	Bitrate    int
-	SampleRate int
-	Channels   int
-	Format     string
-	Codec      string
-	FileSize   int64
-	Title      string
-	Artist     string
-	Album      string
-	Year       int
-	Genre      string
-	TrackNum   int
+	Duration      time.Duration
+	Bitrate       int // average, kbit/s
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	Format        string // MP3, WAV, FLAC, OGG or M4A
+	Codec         string
+	FileSize      int64
+	Title         string
+	Artist        string
+	AlbumArtist   string
+	Album         string
+	Year          int
+	Genre         string
+	TrackNum      int
+	Comment       string
+	HasCoverArt   bool
+	Tags          map[string]string // every text tag by its native key, e.g. TIT2, ARTIST or ©nam
 }
 
 type LogSchema struct {
@@ -106,6 +117,7 @@ type ValidationError struct {
 	Message string
 	Code    string
 	Value   string
+	Path    string // JSON Pointer to the offending value, for schema errors
 }
 
 type XMLError struct {
@@ -113,18 +125,37 @@ type XMLError struct {
 	Column  int
 	Message string
 	Type    string
+	Path    string // element path such as /order/item[2], for validation errors
 }
 
 type CodecInfo struct {
-	VideoCodec   string
-	AudioCodec   string
+	VideoCodec   string // first video track
+	AudioCodec   string // first audio track
 	Container    string
 	Resolution   string
 	Framerate    float64
-	Bitrate      int
+	Bitrate      int // overall, kbit/s
 	Duration     time.Duration
 	HasSubtitles bool
-	IsValid      bool
+	IsValid      bool // parsed cleanly with a recognised, sized video track
+	Tracks       []TrackInfo
+}
+
+// TrackInfo describes one stream in a media container
+type TrackInfo struct {
+	ID         int
+	Type       string // video, audio, subtitle or data
+	Codec      string // readable name such as H.264, or the raw ID when unknown
+	FourCC     string // codec ID as stored: avc1, V_MPEG4/ISO/AVC, XVID, 0x0055
+	Width      int
+	Height     int
+	Framerate  float64
+	SampleRate int
+	Channels   int
+	Duration   time.Duration
+	Bitrate    int // kbit/s, 0 when unknown
+	Language   string
+	Name       string
 }
 
 // Data extraction functions
@@ -448,63 +479,39 @@ func AnalyzeImageProperties(imagePath string) (ImageMetadata, error) {
 	} else if len(header) >= 6 && string(header[:6]) == "GIF87a" || string(header[:6]) == "GIF89a" {
 		metadata.Format = "GIF"
 		return analyzeGIFProperties(file, metadata)
+	} else if string(header[:4]) == "II*\x00" || string(header[:4]) == "MM\x00*" {
+		metadata.Format = "TIFF"
+		return analyzeTIFFProperties(file, metadata)
+	} else if string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP" {
+		metadata.Format = "WebP"
+		return analyzeWebPProperties(file, metadata)
 	}
 	
 	return metadata, fmt.Errorf("unsupported image format")
 }
 
 func analyzePNGProperties(file *os.File, metadata ImageMetadata) (ImageMetadata, error) {
-	// PNG analysis would require detailed chunk parsing
-	// This is a simplified version
-	file.Seek(16, 0) // Skip PNG signature and IHDR chunk header
-	
-	// Read IHDR data
-	ihdr := make([]byte, 13)
-	_, err := file.Read(ihdr)
-	if err != nil {
-		return metadata, err
-	}
-	
-	// Extract width and height (big-endian)
-	metadata.Width = int(ihdr[0])<<24 | int(ihdr[1])<<16 | int(ihdr[2])<<8 | int(ihdr[3])
-	metadata.Height = int(ihdr[4])<<24 | int(ihdr[5])<<16 | int(ihdr[6])<<8 | int(ihdr[7])
-	metadata.BitDepth = int(ihdr[8])
-	
-	colorType := ihdr[9]
-	switch colorType {
-	case 0:
-		metadata.ColorType = "Grayscale"
-	case 2:
-		metadata.ColorType = "RGB"
-	case 3:
-		metadata.ColorType = "Palette"
-	case 4:
-		metadata.ColorType = "Grayscale+Alpha"
-		metadata.HasAlpha = true
-	case 6:
-		metadata.ColorType = "RGBA"
-		metadata.HasAlpha = true
-	}
-	
 	metadata.Compression = "Deflate"
 	metadata.ColorSpace = "sRGB"
 	
-	return metadata, nil
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return metadata, err
+	}
+	err := readPNGMetadata(file, &metadata)
+	return metadata, err
 }
 
 func analyzeJPEGProperties(file *os.File, metadata ImageMetadata) (ImageMetadata, error) {
-	// JPEG analysis requires parsing markers
-	// This is a simplified version
 	metadata.ColorType = "RGB"
 	metadata.Compression = "JPEG"
 	metadata.ColorSpace = "YCbCr"
 	
-	// For full JPEG analysis, would need to parse EXIF data
-	// This is a placeholder
-	metadata.Width = 0  // Would be extracted from SOF marker
-	metadata.Height = 0 // Would be extracted from SOF marker
-	
-	return metadata, nil
+	// Dimensions come from the SOF marker; EXIF, XMP and IPTC from APP segments
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return metadata, err
+	}
+	err := readJPEGMetadata(file, &metadata)
+	return metadata, err
 }
 
 func analyzeGIFProperties(file *os.File, metadata ImageMetadata) (ImageMetadata, error) {
@@ -529,160 +536,36 @@ func analyzeGIFProperties(file *os.File, metadata ImageMetadata) (ImageMetadata,
 func ExtractAudioMetadata(audioPath string) (AudioMetadata, error) {
 	metadata := AudioMetadata{}
 	
-	info, err := os.Stat(audioPath)
-	if err != nil {
-		return metadata, err
-	}
-	metadata.FileSize = info.Size()
-	
-	// Detect format from extension
-	ext := strings.ToLower(filepath.Ext(audioPath))
-	switch ext {
-	case ".mp3":
-		metadata.Format = "MP3"
-		metadata.Codec = "MPEG-1 Layer 3"
-		return extractMP3Metadata(audioPath, metadata)
-	case ".wav":
-		metadata.Format = "WAV"
-		metadata.Codec = "PCM"
-		return extractWAVMetadata(audioPath, metadata)
-	case ".flac":
-		metadata.Format = "FLAC"
-		metadata.Codec = "FLAC"
-		return extractFLACMetadata(audioPath, metadata)
-	case ".ogg":
-		metadata.Format = "OGG"
-		metadata.Codec = "Vorbis"
-		return extractOGGMetadata(audioPath, metadata)
-	default:
-		return metadata, fmt.Errorf("unsupported audio format: %s", ext)
-	}
-}
-
-func extractMP3Metadata(audioPath string, metadata AudioMetadata) (AudioMetadata, error) {
 	file, err := os.Open(audioPath)
 	if err != nil {
 		return metadata, err
 	}
 	defer file.Close()
 	
-	// Look for ID3v2 tag at beginning
-	header := make([]byte, 10)
-	_, err = file.Read(header)
-	if err != nil {
-		return metadata, err
-	}
-	
-	if string(header[:3]) == "ID3" {
-		// Parse ID3v2 tag
-		tagSize := int(header[6])<<21 | int(header[7])<<14 | int(header[8])<<7 | int(header[9])
-		
-		// Read ID3 tag data
-		tagData := make([]byte, tagSize)
-		_, err = file.Read(tagData)
-		if err == nil {
-			metadata = parseID3Tag(tagData, metadata)
-		}
-	}
-	
-	// Basic MP3 properties (would need frame parsing for accuracy)
-	metadata.Bitrate = 128   // Default assumption
-	metadata.SampleRate = 44100
-	metadata.Channels = 2
-	
-	return metadata, nil
-}
-
-func parseID3Tag(tagData []byte, metadata AudioMetadata) AudioMetadata {
-	// Simplified ID3 parsing - would need full implementation for production
-	content := string(tagData)
-	
-	// Look for common fields (this is very basic)
-	if titlePos := strings.Index(content, "TIT2"); titlePos != -1 {
-		// Extract title (simplified)
-		metadata.Title = extractID3Field(content, titlePos)
-	}
-	
-	if artistPos := strings.Index(content, "TPE1"); artistPos != -1 {
-		metadata.Artist = extractID3Field(content, artistPos)
-	}
-	
-	if albumPos := strings.Index(content, "TALB"); albumPos != -1 {
-		metadata.Album = extractID3Field(content, albumPos)
-	}
-	
-	return metadata
-}
-
-func extractID3Field(content string, pos int) string {
-	// Very basic field extraction - production would need proper frame parsing
-	start := pos + 10 // Skip frame header
-	if start >= len(content) {
-		return ""
-	}
-	
-	end := start
-	for end < len(content) && content[end] != 0 && end < start+100 {
-		end++
-	}
-	
-	if end > start {
-		field := content[start:end]
-		// Clean up the field
-		if len(field) > 0 && field[0] == 1 {
-			field = field[1:] // Skip encoding byte
-		}
-		return strings.TrimSpace(field)
-	}
-	
-	return ""
-}
-
-func extractWAVMetadata(audioPath string, metadata AudioMetadata) (AudioMetadata, error) {
-	file, err := os.Open(audioPath)
+	info, err := file.Stat()
 	if err != nil {
 		return metadata, err
 	}
-	defer file.Close()
+	metadata.FileSize = info.Size()
 	
-	// Read WAV header
-	header := make([]byte, 44)
-	_, err = file.Read(header)
+	// Detect format from magic bytes; extensions are often wrong
+	format, offset, err := detectAudioFormat(file)
 	if err != nil {
 		return metadata, err
 	}
-	
-	// Check RIFF header
-	if string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
-		return metadata, fmt.Errorf("invalid WAV file")
-	}
-	
-	// Extract format information
-	if string(header[12:16]) == "fmt " {
-		metadata.SampleRate = int(header[24]) | int(header[25])<<8 | int(header[26])<<16 | int(header[27])<<24
-		metadata.Channels = int(header[22]) | int(header[23])<<8
-		metadata.Bitrate = int(header[28]) | int(header[29])<<8 | int(header[30])<<16 | int(header[31])<<24
+	metadata.Format = format
+	switch format {
+	case "MP3":
+		return extractMP3Metadata(file, metadata)
+	case "WAV":
+		return extractWAVMetadata(file, metadata)
+	case "FLAC":
+		return extractFLACMetadata(file, offset, metadata)
+	case "OGG":
+		return extractOGGMetadata(file, metadata)
+	default:
+		return extractM4AMetadata(file, metadata)
 	}
-	
-	return metadata, nil
-}
-
-func extractFLACMetadata(audioPath string, metadata AudioMetadata) (AudioMetadata, error) {
-	// FLAC metadata extraction would require specialized parsing
-	// This is a placeholder
-	metadata.Bitrate = 0 // FLAC is lossless
-	metadata.SampleRate = 44100
-	metadata.Channels = 2
-	return metadata, nil
-}
-
-func extractOGGMetadata(audioPath string, metadata AudioMetadata) (AudioMetadata, error) {
-	// OGG metadata extraction would require specialized parsing
-	// This is a placeholder
-	metadata.Bitrate = 160
-	metadata.SampleRate = 44100
-	metadata.Channels = 2
-	return metadata, nil
 }
 
 func ParseLogFileStructure(logPath string) (LogSchema, error) {
@@ -940,8 +823,15 @@ func ValidateJSONStructure(jsonPath string) ([]ValidationError, error) {
 		return errors, err
 	}
 	
+	return jsonSyntaxErrors(content), nil
+}
+
+// jsonSyntaxErrors reports why content is not valid JSON, if it is not
+func jsonSyntaxErrors(content []byte) []ValidationError {
+	var errors []ValidationError
+	
 	var js json.RawMessage
-	err = json.Unmarshal(content, &js)
+	err := json.Unmarshal(content, &js)
 	if err != nil {
 		// Parse JSON error
 		if syntaxErr, ok := err.(*json.SyntaxError); ok {
@@ -964,7 +854,7 @@ func ValidateJSONStructure(jsonPath string) ([]ValidationError, error) {
 		}
 	}
 	
-	return errors, nil
+	return errors
 }
 
 func getLineColumn(content []byte, offset int64) (int, int) {
@@ -1038,48 +928,38 @@ func VerifyImageIntegrity(imagePath string) bool {
 func ValidateVideoCodec(videoPath string) (CodecInfo, error) {
 	codec := CodecInfo{}
 	
-	// This would typically require a media library like ffmpeg
-	// For now, provide basic format detection
-	
-	ext := strings.ToLower(filepath.Ext(videoPath))
-	switch ext {
-	case ".mp4":
-		codec.Container = "MP4"
-		codec.VideoCodec = "H.264"
-		codec.AudioCodec = "AAC"
-		codec.IsValid = true
-	case ".avi":
-		codec.Container = "AVI"
-		codec.VideoCodec = "DivX/XviD"
-		codec.AudioCodec = "MP3"
-		codec.IsValid = true
-	case ".mkv":
-		codec.Container = "Matroska"
-		codec.VideoCodec = "H.264"
-		codec.AudioCodec = "AAC"
-		codec.IsValid = true
-	case ".mov":
-		codec.Container = "QuickTime"
-		codec.VideoCodec = "H.264"
-		codec.AudioCodec = "AAC"
-		codec.IsValid = true
-	default:
-		codec.IsValid = false
-		return codec, fmt.Errorf("unsupported video format: %s", ext)
+	file, err := os.Open(videoPath)
+	if err != nil {
+		return codec, err
 	}
+	defer file.Close()
 	
-	// Get file info
-	info, err := os.Stat(videoPath)
+	info, err := file.Stat()
 	if err != nil {
-		codec.IsValid = false
 		return codec, err
 	}
-	
-	// Basic file validation
 	if info.Size() == 0 {
-		codec.IsValid = false
 		return codec, fmt.Errorf("empty video file")
 	}
 	
+	// Detect the container from magic bytes and parse its track headers
+	header := make([]byte, 12)
+	n, _ := file.ReadAt(header, 0)
+	header = header[:n]
+	switch {
+	case n >= 8 && isMP4BoxType(string(header[4:8])):
+		err = parseMP4Container(file, info.Size(), &codec)
+	case n >= 4 && string(header[:4]) == "\x1a\x45\xdf\xa3":
+		err = parseMatroskaContainer(file, info.Size(), &codec)
+	case n >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		err = parseAVIContainer(file, info.Size(), &codec)
+	default:
+		return codec, fmt.Errorf("unsupported video format")
+	}
+	if err != nil {
+		return codec, err
+	}
+	
+	summarizeTracks(&codec, info.Size())
 	return codec, nil
 }
\ No newline at end of file
@@ -0,0 +1,782 @@
+package textlib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// CSVProfileOptions configures ProfileCSV
+type CSVProfileOptions struct {
+	Delimiter     rune     // Field separator; detected from the first lines when 0
+	Header        string   // "auto" (default), "present" or "absent"
+	NullValues    []string // Values counted as null (default "", "NA", "N/A", "null", "NULL", "None", "-")
+	HistogramBins int      // Bins per numeric histogram, rounded up to even (default 10)
+	SampleRows    int      // Data rows kept in Sample (default 5)
+	MaxIssues     int      // Row issues kept with line numbers (default 100)
+	MaxRows       int64    // Stop after this many data rows (0 reads everything)
+}
+
+func (o CSVProfileOptions) withDefaults() CSVProfileOptions {
+	if o.Header == "" {
+		o.Header = "auto"
+	}
+	if o.NullValues == nil {
+		o.NullValues = []string{"", "NA", "N/A", "null", "NULL", "None", "-"}
+	}
+	if o.HistogramBins <= 0 {
+		o.HistogramBins = 10
+	}
+	o.HistogramBins += o.HistogramBins % 2
+	if o.SampleRows < 0 {
+		o.SampleRows = 0
+	} else if o.SampleRows == 0 {
+		o.SampleRows = 5
+	}
+	if o.MaxIssues <= 0 {
+		o.MaxIssues = 100
+	}
+	return o
+}
+
+// CSVProfile summarizes a CSV file read in a single pass. Memory depends on
+// the number of columns, not rows.
+type CSVProfile struct {
+	Delimiter  string
+	Encoding   string // "UTF-8", "UTF-16LE", "UTF-16BE", or "unknown (not UTF-8)" when bytes are invalid
+	BOM        string // Byte order mark at the start of the file, if any
+	HasHeaders bool
+	Headers    []string
+	RowCount   int64 // Data rows, excluding the header
+	Columns    []CSVColumnProfile
+	Issues     []CSVRowIssue // The first MaxIssues problems, in file order
+	IssueCount int64
+	Sample     [][]string
+	Truncated  bool // MaxRows stopped reading early
+}
+
+// CSVRowIssue is a problem found in one record
+type CSVRowIssue struct {
+	Line     int    // Line the record starts on
+	Kind     string // "row_width", "parse_error", "invalid_utf8", "nul_byte" or "duplicate_header"
+	Fields   int    // Fields in the record, for row_width
+	Expected int    // Fields in the header or first record, for row_width
+	Message  string
+}
+
+// CSVColumnProfile holds the statistics of one column
+type CSVColumnProfile struct {
+	Name           string
+	Type           string           // integer, float, boolean, date, datetime, string or empty
+	TypeConfidence float64          // Share of non-null values that fit Type
+	TypeCounts     map[string]int64 // Non-null values by the narrowest type they fit
+	Count          int64            // Non-null values
+	Nulls          int64            // Null or missing values
+	NullRate       float64
+	Distinct       uint64 // HyperLogLog estimate of distinct non-null values
+	Min            string // Smallest value in the column type's order
+	Max            string
+	MinLength      int // In characters
+	MaxLength      int
+	Mean           float64 // Numeric columns only
+	StdDev         float64
+	Histogram      []CSVHistogramBin // Numeric columns only
+	DateFormat     string            // Go layout of date and datetime columns, such as 2006-01-02
+}
+
+// CSVHistogramBin counts values in [Lower, Upper)
+type CSVHistogramBin struct {
+	Lower float64
+	Upper float64
+	Count int64
+}
+
+// ProfileCSVFile profiles the CSV file at path
+func ProfileCSVFile(path string, opts CSVProfileOptions) (CSVProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return CSVProfile{}, err
+	}
+	defer file.Close()
+	return ProfileCSV(file, opts)
+}
+
+// ProfileCSV streams CSV from r and reports per-column statistics: null
+// rate, a HyperLogLog distinct count, min and max, numeric histograms and
+// detected date formats. It checks the byte order mark and encoding, and
+// records rows whose width differs from the header with their line
+// numbers instead of failing.
+func ProfileCSV(r io.Reader, opts CSVProfileOptions) (CSVProfile, error) {
+	opts = opts.withDefaults()
+	profile := CSVProfile{Encoding: "UTF-8"}
+
+	src, err := decodeCSVEncoding(r, &profile)
+	if err != nil {
+		return profile, err
+	}
+	data := bufio.NewReaderSize(src, 64<<10)
+
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		head, _ := data.Peek(64 << 10)
+		lines := strings.Split(string(head), "\n")
+		if len(lines) > 20 {
+			lines = lines[:20]
+		}
+		delimiter = rune(detectCSVDelimiter(lines)[0])
+	}
+	profile.Delimiter = string(delimiter)
+
+	reader := csv.NewReader(data)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	p := &csvProfiler{profile: &profile, opts: opts, nulls: make(map[string]bool)}
+	for _, v := range opts.NullValues {
+		p.nulls[v] = true
+	}
+
+	// The first two records decide whether there is a header
+	var first []string
+	firstLine := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return profile, err
+			}
+			p.issue(CSVRowIssue{Line: parseErr.StartLine, Kind: "parse_error", Message: parseErr.Err.Error()})
+			continue
+		}
+		if len(record) == 0 {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first == nil {
+			first, firstLine = append([]string(nil), record...), line
+			continue
+		}
+		if p.columns == nil {
+			p.start(first, firstLine, opts.Header == "present" || opts.Header == "auto" && csvLooksLikeHeader(first, record))
+		}
+		if opts.MaxRows > 0 && profile.RowCount >= opts.MaxRows {
+			profile.Truncated = true
+			break
+		}
+		p.add(record, line)
+	}
+
+	if first != nil && p.columns == nil {
+		p.start(first, firstLine, opts.Header == "present" || opts.Header == "auto" && csvLooksLikeHeader(first, nil))
+	}
+	p.finish()
+	return profile, nil
+}
+
+// csvLooksLikeHeader guesses whether first is a header row. Headers have no
+// numeric fields; a lone row without numbers is taken as the header of an
+// empty file.
+func csvLooksLikeHeader(first, second []string) bool {
+	for _, field := range first {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err == nil {
+			return false
+		}
+	}
+	return second == nil || detectCSVHeaders([][]string{first, second})
+}
+
+// decodeCSVEncoding strips a byte order mark and converts UTF-16 to UTF-8
+func decodeCSVEncoding(r io.Reader, profile *CSVProfile) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	head, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE, 0, 0}), bytes.HasPrefix(head, []byte{0, 0, 0xFE, 0xFF}):
+		return nil, fmt.Errorf("UTF-32 encoded CSV is not supported")
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		profile.BOM = "UTF-8"
+		br.Discard(3)
+		return br, nil
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		profile.BOM, profile.Encoding = "UTF-16LE", "UTF-16LE"
+		br.Discard(2)
+		return &utf16Reader{r: br, order: binary.LittleEndian}, nil
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		profile.BOM, profile.Encoding = "UTF-16BE", "UTF-16BE"
+		br.Discard(2)
+		return &utf16Reader{r: br, order: binary.BigEndian}, nil
+	}
+
+	// UTF-16 without a BOM shows up as ASCII interleaved with zero bytes
+	sample, _ := br.Peek(1024)
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b == 0 {
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+	}
+	half := len(sample) / 2
+	switch {
+	case half > 0 && oddZeros > half*4/10 && evenZeros < half/10:
+		profile.Encoding = "UTF-16LE"
+		return &utf16Reader{r: br, order: binary.LittleEndian}, nil
+	case half > 0 && evenZeros > half*4/10 && oddZeros < half/10:
+		profile.Encoding = "UTF-16BE"
+		return &utf16Reader{r: br, order: binary.BigEndian}, nil
+	}
+	return br, nil
+}
+
+// utf16Reader decodes UTF-16 to UTF-8
+type utf16Reader struct {
+	r       *bufio.Reader
+	order   binary.ByteOrder
+	pending []byte
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	n := copy(p, u.pending)
+	u.pending = u.pending[n:]
+	for n < len(p) {
+		unit, err := u.unit()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		c := rune(unit)
+		if utf16.IsSurrogate(c) {
+			if low, err := u.unit(); err == nil {
+				c = utf16.DecodeRune(c, rune(low))
+			} else {
+				c = utf8.RuneError
+			}
+		}
+		var buf [utf8.UTFMax]byte
+		size := utf8.EncodeRune(buf[:], c)
+		copied := copy(p[n:], buf[:size])
+		u.pending = append(u.pending[:0], buf[copied:size]...)
+		n += copied
+		if u.r.Buffered() < 2 && n > 0 {
+			break // don't block for more input once something is decoded
+		}
+	}
+	return n, nil
+}
+
+func (u *utf16Reader) unit() (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF // a trailing odd byte is dropped
+		}
+		return 0, err
+	}
+	return u.order.Uint16(b[:]), nil
+}
+
+type csvProfiler struct {
+	profile  *CSVProfile
+	opts     CSVProfileOptions
+	nulls    map[string]bool
+	columns  []*csvColumnStats
+	expected int
+}
+
+// start sets up the columns from the first record, which is either the
+// header or the first data row
+func (p *csvProfiler) start(first []string, line int, hasHeaders bool) {
+	p.profile.HasHeaders = hasHeaders
+	p.expected = len(first)
+	seen := make(map[string]bool)
+	for i, value := range first {
+		name := fmt.Sprintf("Column%d", i+1)
+		if hasHeaders && strings.TrimSpace(value) != "" {
+			name = strings.TrimSpace(value)
+			if seen[name] {
+				p.issue(CSVRowIssue{Line: line, Kind: "duplicate_header", Message: fmt.Sprintf("column %d repeats the header %q", i+1, name)})
+			}
+			seen[name] = true
+		}
+		p.profile.Headers = append(p.profile.Headers, name)
+		p.columns = append(p.columns, newCSVColumnStats(p.opts.HistogramBins))
+	}
+	if !hasHeaders {
+		p.add(first, line)
+	}
+}
+
+func (p *csvProfiler) issue(issue CSVRowIssue) {
+	p.profile.IssueCount++
+	if len(p.profile.Issues) < p.opts.MaxIssues {
+		p.profile.Issues = append(p.profile.Issues, issue)
+	}
+}
+
+func (p *csvProfiler) add(record []string, line int) {
+	p.profile.RowCount++
+	if len(record) != p.expected {
+		p.issue(CSVRowIssue{
+			Line: line, Kind: "row_width", Fields: len(record), Expected: p.expected,
+			Message: fmt.Sprintf("expected %d fields, got %d", p.expected, len(record)),
+		})
+	}
+	if len(p.profile.Sample) < p.opts.SampleRows {
+		p.profile.Sample = append(p.profile.Sample, append([]string(nil), record...))
+	}
+
+	invalid, nul := false, false
+	for i, column := range p.columns {
+		if i >= len(record) {
+			column.nulls++ // missing field
+			continue
+		}
+		value := record[i]
+		invalid = invalid || !utf8.ValidString(value)
+		nul = nul || strings.IndexByte(value, 0) >= 0
+		if trimmed := strings.TrimSpace(value); p.nulls[trimmed] {
+			column.nulls++
+		} else {
+			column.add(trimmed)
+		}
+	}
+	if invalid {
+		p.profile.Encoding = "unknown (not UTF-8)"
+		p.issue(CSVRowIssue{Line: line, Kind: "invalid_utf8", Message: "record contains bytes that are not valid UTF-8"})
+	}
+	if nul {
+		p.issue(CSVRowIssue{Line: line, Kind: "nul_byte", Message: "record contains a NUL byte"})
+	}
+}
+
+func (p *csvProfiler) finish() {
+	for i, column := range p.columns {
+		p.profile.Columns = append(p.profile.Columns, column.profile(p.profile.Headers[i]))
+	}
+}
+
+// csvDateLayouts are the date formats ProfileCSV recognizes. Ambiguous
+// day/month orders are both tried; the layout that parses the most values
+// wins, and ties go to the earlier layout.
+var csvDateLayouts = []struct {
+	layout   string
+	datetime bool
+}{
+	{"2006-01-02", false},
+	{time.RFC3339, true},
+	{"2006-01-02T15:04:05", true},
+	{"2006-01-02 15:04:05", true},
+	{"2006/01/02", false},
+	{"01/02/2006", false},
+	{"02/01/2006", false},
+	{"01/02/2006 15:04:05", true},
+	{"02/01/2006 15:04:05", true},
+	{"02.01.2006", false},
+	{"02-Jan-2006", false},
+	{"Jan 2, 2006", false},
+	{"January 2, 2006", false},
+	{"2 January 2006", false},
+	{time.RFC1123, true},
+}
+
+const (
+	csvInteger = iota
+	csvFloat
+	csvBoolean
+	csvDate
+	csvString
+	csvTypeCount
+)
+
+var csvTypeNames = [csvTypeCount]string{"integer", "float", "boolean", "date", "string"}
+
+// csvColumnStats accumulates one column's statistics in constant memory
+type csvColumnStats struct {
+	count, nulls int64
+	types        [csvTypeCount]int64
+	distinct     *hyperLogLog
+
+	minString, maxString string
+	minLength, maxLength int
+
+	minInt, maxInt int64
+	minNum, maxNum float64
+	mean, m2       float64 // Welford's running variance
+	numeric        int64
+	histogram      *streamingHistogram
+
+	dateCounts []int64
+	dateMin    []time.Time
+	dateMax    []time.Time
+	dateMinRaw []string
+	dateMaxRaw []string
+}
+
+func newCSVColumnStats(bins int) *csvColumnStats {
+	n := len(csvDateLayouts)
+	return &csvColumnStats{
+		distinct:   newHyperLogLog(14),
+		histogram:  newStreamingHistogram(bins),
+		dateCounts: make([]int64, n),
+		dateMin:    make([]time.Time, n),
+		dateMax:    make([]time.Time, n),
+		dateMinRaw: make([]string, n),
+		dateMaxRaw: make([]string, n),
+	}
+}
+
+func (c *csvColumnStats) add(value string) {
+	c.count++
+	c.distinct.add(csvHash(value))
+
+	length := utf8.RuneCountInString(value)
+	if c.count == 1 {
+		c.minString, c.maxString = value, value
+		c.minLength, c.maxLength = length, length
+	} else {
+		if value < c.minString {
+			c.minString = value
+		}
+		if value > c.maxString {
+			c.maxString = value
+		}
+		if length < c.minLength {
+			c.minLength = length
+		}
+		if length > c.maxLength {
+			c.maxLength = length
+		}
+	}
+
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		c.types[csvInteger]++
+		if c.types[csvInteger] == 1 || n < c.minInt {
+			c.minInt = n
+		}
+		if c.types[csvInteger] == 1 || n > c.maxInt {
+			c.maxInt = n
+		}
+		c.addNumber(float64(n))
+		return
+	}
+	if f, ok := parseCSVFloat(value); ok {
+		c.types[csvFloat]++
+		c.addNumber(f)
+		return
+	}
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "t", "f", "y", "n":
+		c.types[csvBoolean]++
+		return
+	}
+	if c.addDate(value) {
+		c.types[csvDate]++
+		return
+	}
+	c.types[csvString]++
+}
+
+// parseCSVFloat accepts plain decimal and exponent notation, not the hex,
+// underscore, infinity and NaN forms strconv also takes
+func parseCSVFloat(value string) (float64, bool) {
+	for _, r := range value {
+		if unicode.IsLetter(r) && r != 'e' && r != 'E' || r == '_' {
+			return 0, false
+		}
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	return f, err == nil && !math.IsInf(f, 0)
+}
+
+func (c *csvColumnStats) addNumber(f float64) {
+	c.numeric++
+	if c.numeric == 1 || f < c.minNum {
+		c.minNum = f
+	}
+	if c.numeric == 1 || f > c.maxNum {
+		c.maxNum = f
+	}
+	delta := f - c.mean
+	c.mean += delta / float64(c.numeric)
+	c.m2 += delta * (f - c.mean)
+	c.histogram.add(f)
+}
+
+func (c *csvColumnStats) addDate(value string) bool {
+	// Every layout needs a digit; skip the parse attempts for plain words
+	if len(value) < 6 || strings.IndexAny(value, "0123456789") < 0 {
+		return false
+	}
+	matched := false
+	for i, layout := range csvDateLayouts {
+		t, err := time.Parse(layout.layout, value)
+		if err != nil {
+			continue
+		}
+		matched = true
+		if c.dateCounts[i] == 0 || t.Before(c.dateMin[i]) {
+			c.dateMin[i], c.dateMinRaw[i] = t, value
+		}
+		if c.dateCounts[i] == 0 || t.After(c.dateMax[i]) {
+			c.dateMax[i], c.dateMaxRaw[i] = t, value
+		}
+		c.dateCounts[i]++
+	}
+	return matched
+}
+
+func (c *csvColumnStats) profile(name string) CSVColumnProfile {
+	profile := CSVColumnProfile{
+		Name:       name,
+		Type:       "empty",
+		TypeCounts: make(map[string]int64),
+		Count:      c.count,
+		Nulls:      c.nulls,
+		Distinct:   c.distinct.estimate(),
+		MinLength:  c.minLength,
+		MaxLength:  c.maxLength,
+	}
+	if total := c.count + c.nulls; total > 0 {
+		profile.NullRate = float64(c.nulls) / float64(total)
+	}
+	for i, n := range c.types {
+		if n > 0 {
+			profile.TypeCounts[csvTypeNames[i]] = n
+		}
+	}
+	if c.count == 0 {
+		return profile
+	}
+	if profile.Distinct > uint64(c.count) {
+		profile.Distinct = uint64(c.count)
+	}
+
+	bestDate := 0
+	for i, n := range c.dateCounts {
+		if n > c.dateCounts[bestDate] {
+			bestDate = i
+		}
+	}
+
+	// Pick the narrowest type that fits every value, then fall back to
+	// the type most values fit
+	ints, numbers := c.types[csvInteger], c.types[csvInteger]+c.types[csvFloat]
+	candidates := []struct {
+		name  string
+		count int64
+	}{
+		{"integer", ints},
+		{"float", numbers},
+		{"boolean", c.types[csvBoolean]},
+		{"date", c.dateCounts[bestDate]},
+	}
+	profile.Type, profile.TypeConfidence = "string", 1
+	for _, candidate := range candidates {
+		if candidate.count == c.count {
+			profile.Type = candidate.name
+			break
+		}
+	}
+	if profile.Type == "string" {
+		var best int64
+		for _, candidate := range candidates {
+			if candidate.count > c.count/2 && candidate.count > best {
+				profile.Type, best = candidate.name, candidate.count
+			}
+		}
+		if best > 0 {
+			profile.TypeConfidence = float64(best) / float64(c.count)
+		}
+	}
+
+	profile.Min, profile.Max = c.minString, c.maxString
+	switch profile.Type {
+	case "integer":
+		profile.Min, profile.Max = strconv.FormatInt(c.minInt, 10), strconv.FormatInt(c.maxInt, 10)
+	case "float":
+		profile.Min, profile.Max = strconv.FormatFloat(c.minNum, 'g', -1, 64), strconv.FormatFloat(c.maxNum, 'g', -1, 64)
+	case "date":
+		if csvDateLayouts[bestDate].datetime {
+			profile.Type = "datetime"
+		}
+		profile.DateFormat = csvDateLayouts[bestDate].layout
+		profile.Min, profile.Max = c.dateMinRaw[bestDate], c.dateMaxRaw[bestDate]
+	}
+	if profile.Type == "integer" || profile.Type == "float" {
+		profile.Mean = c.mean
+		profile.StdDev = math.Sqrt(c.m2 / float64(c.numeric))
+		profile.Histogram = c.histogram.bins()
+	}
+	return profile
+}
+
+// csvHash is 64-bit FNV-1a over the string, finished with mix64 so the
+// high bits HyperLogLog uses are well distributed
+func csvHash(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return mix64(h)
+}
+
+// hyperLogLog estimates the number of distinct hashes added in 2^p
+// one-byte registers, with a standard error of about 1.04/sqrt(2^p)
+type hyperLogLog struct {
+	p         uint8
+	registers []uint8
+}
+
+func newHyperLogLog(p uint8) *hyperLogLog {
+	return &hyperLogLog{p: p, registers: make([]uint8, 1<<p)}
+}
+
+func (h *hyperLogLog) add(hash uint64) {
+	index := hash >> (64 - h.p)
+	// The sentinel bit bounds the rank when the remaining bits are zero
+	rank := uint8(bits.LeadingZeros64(hash<<h.p|1<<(h.p-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+func (h *hyperLogLog) estimate() uint64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+// streamingHistogram keeps exact counts in a fixed number of equal-width
+// bins. The range is set from the first values and doubles, merging
+// neighbouring bins, whenever a value falls outside it.
+type streamingHistogram struct {
+	counts  []int64
+	lower   float64
+	width   float64   // zero until the range is set
+	pending []float64 // values seen before the range is set
+}
+
+const histogramWarmup = 1024
+
+func newStreamingHistogram(bins int) *streamingHistogram {
+	return &streamingHistogram{counts: make([]int64, bins)}
+}
+
+func (h *streamingHistogram) add(x float64) {
+	if h.width == 0 {
+		h.pending = append(h.pending, x)
+		if len(h.pending) >= histogramWarmup {
+			h.setRange()
+		}
+		return
+	}
+	h.insert(x)
+}
+
+func (h *streamingHistogram) setRange() {
+	lo, hi := h.pending[0], h.pending[0]
+	for _, x := range h.pending {
+		lo, hi = math.Min(lo, x), math.Max(hi, x)
+	}
+	n := float64(len(h.counts))
+	h.lower, h.width = lo, (hi-lo)/n
+	if h.width == 0 {
+		h.width = 1 // constant so far; unit bins starting at the value
+	}
+	for _, x := range h.pending {
+		h.insert(x)
+	}
+	h.pending = nil
+}
+
+func (h *streamingHistogram) insert(x float64) {
+	n := len(h.counts)
+	for x < h.lower {
+		// Double the range downwards: old bins fold into the upper half
+		for i := n - 1; i >= n/2; i-- {
+			h.counts[i] = h.counts[2*i-n] + h.counts[2*i-n+1]
+		}
+		for i := 0; i < n/2; i++ {
+			h.counts[i] = 0
+		}
+		h.lower -= h.width * float64(n)
+		h.width *= 2
+	}
+	for x > h.lower+h.width*float64(n) {
+		for i := 0; i < n/2; i++ {
+			h.counts[i] = h.counts[2*i] + h.counts[2*i+1]
+		}
+		for i := n / 2; i < n; i++ {
+			h.counts[i] = 0
+		}
+		h.width *= 2
+	}
+	i := int((x - h.lower) / h.width)
+	if i >= n {
+		i = n - 1
+	}
+	h.counts[i]++
+}
+
+// bins returns the histogram without empty bins at either end
+func (h *streamingHistogram) bins() []CSVHistogramBin {
+	if h.width == 0 {
+		if len(h.pending) == 0 {
+			return nil
+		}
+		h.setRange()
+	}
+	first, last := -1, -1
+	for i, n := range h.counts {
+		if n > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	var bins []CSVHistogramBin
+	for i := first; i >= 0 && i <= last; i++ {
+		bins = append(bins, CSVHistogramBin{
+			Lower: h.lower + float64(i)*h.width,
+			Upper: h.lower + float64(i+1)*h.width,
+			Count: h.counts[i],
+		})
+	}
+	return bins
+}
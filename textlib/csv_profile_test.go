@@ -0,0 +1,289 @@
+package textlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestProfileCSV(t *testing.T) {
+	data := "id;name;price;joined;active;notes\n" +
+		"1;Ada;9.50;2024-01-15;true;\n" +
+		"2;Grace;12;2023-12-01;false;NA\n" +
+		"3;Linus;;2024-02-29;yes\n" +
+		"4;\"Smith; J\";100.25;2022-07-04;no;x;extra\n" +
+		"5;Ada;7;2024-03-01;true;ok\n"
+
+	profile, err := ProfileCSV(strings.NewReader(data), CSVProfileOptions{})
+	if err != nil {
+		t.Fatalf("ProfileCSV failed: %v", err)
+	}
+
+	if profile.Delimiter != ";" || !profile.HasHeaders || profile.RowCount != 5 || profile.Encoding != "UTF-8" {
+		t.Errorf("Unexpected profile %+v", profile)
+	}
+	if len(profile.Sample) != 5 || profile.Sample[3][1] != "Smith; J" {
+		t.Errorf("Expected the sample to keep quoted fields, got %q", profile.Sample)
+	}
+
+	// Rows 4 and 5 of the file have the wrong width
+	if profile.IssueCount != 2 || profile.Issues[0].Line != 4 || profile.Issues[0].Fields != 5 ||
+		profile.Issues[1].Line != 5 || profile.Issues[1].Expected != 6 || profile.Issues[1].Kind != "row_width" {
+		t.Errorf("Unexpected issues %+v", profile.Issues)
+	}
+
+	tests := []struct {
+		column     string
+		typ        string
+		count      int64
+		nulls      int64
+		distinct   uint64
+		min, max   string
+		dateFormat string
+	}{
+		{"id", "integer", 5, 0, 5, "1", "5", ""},
+		{"name", "string", 5, 0, 4, "Ada", "Smith; J", ""},
+		{"price", "float", 4, 1, 4, "7", "100.25", ""},
+		{"joined", "date", 5, 0, 5, "2022-07-04", "2024-03-01", "2006-01-02"},
+		{"active", "boolean", 5, 0, 4, "false", "yes", ""},
+		{"notes", "string", 2, 3, 2, "ok", "x", ""},
+	}
+	for i, tt := range tests {
+		c := profile.Columns[i]
+		if c.Name != tt.column || c.Type != tt.typ || c.Count != tt.count || c.Nulls != tt.nulls ||
+			c.Distinct != tt.distinct || c.Min != tt.min || c.Max != tt.max || c.DateFormat != tt.dateFormat {
+			t.Errorf("Column %s: unexpected %+v", tt.column, c)
+		}
+	}
+
+	price := profile.Columns[2]
+	if math.Abs(price.Mean-32.1875) > 1e-9 || price.NullRate != 0.2 || len(price.Histogram) == 0 {
+		t.Errorf("Unexpected price statistics %+v", price)
+	}
+	var binned int64
+	for _, bin := range price.Histogram {
+		binned += bin.Count
+	}
+	if binned != 4 {
+		t.Errorf("Expected 4 values in the histogram, got %d", binned)
+	}
+}
+
+func TestProfileCSVTypeInference(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []string
+		typ        string
+		confidence float64
+		dateFormat string
+	}{
+		{"european dates", []string{"25/12/2020", "01/02/2021", "13/06/2019"}, "date", 1, "02/01/2006"},
+		{"us dates", []string{"12/25/2020", "01/02/2021"}, "date", 1, "01/02/2006"},
+		{"timestamps", []string{"2024-01-01T10:00:00Z", "2024-01-02T11:30:00+02:00"}, "datetime", 1, time.RFC3339},
+		{"mostly integers", []string{"1", "2", "3", "four"}, "integer", 0.75, ""},
+		{"mixed", []string{"1", "a", "b", "2024-01-01"}, "string", 1, ""},
+		{"exponents", []string{"1e3", "2.5", "-4"}, "float", 1, ""},
+		{"not numbers", []string{"Inf", "NaN", "0x1p3"}, "string", 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := "value\n" + strings.Join(tt.values, "\n") + "\n"
+			profile, err := ProfileCSV(strings.NewReader(data), CSVProfileOptions{Header: "present"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := profile.Columns[0]
+			if c.Type != tt.typ || c.TypeConfidence != tt.confidence || c.DateFormat != tt.dateFormat {
+				t.Errorf("Expected %s (%.2f, %q), got %s (%.2f, %q) %v", tt.typ, tt.confidence, tt.dateFormat, c.Type, c.TypeConfidence, c.DateFormat, c.TypeCounts)
+			}
+		})
+	}
+}
+
+func TestProfileCSVEncoding(t *testing.T) {
+	utf16le := func(s string, bom bool) []byte {
+		var buf bytes.Buffer
+		if bom {
+			buf.Write([]byte{0xFF, 0xFE})
+		}
+		for _, unit := range utf16.Encode([]rune(s)) {
+			binary.Write(&buf, binary.LittleEndian, unit)
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		bom      string
+		issues   []string
+	}{
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, "name,city\nZoë,Köln\n"...), "UTF-8", "UTF-8", nil},
+		{"utf-16le bom", utf16le("name,city\nZoë,Köln\n😀,Berlin\n", true), "UTF-16LE", "UTF-16LE", nil},
+		{"utf-16le without bom", utf16le("name,city\nZoe,Koeln\n", false), "UTF-16LE", "", nil},
+		{"latin-1", []byte("name,city\nZo\xeb,K\xf6ln\n"), "unknown (not UTF-8)", "", []string{"invalid_utf8"}},
+		{"nul byte", []byte("name,city\nZoe,K\x00ln\n"), "UTF-8", "", []string{"nul_byte"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := ProfileCSV(bytes.NewReader(tt.data), CSVProfileOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if profile.Encoding != tt.encoding || profile.BOM != tt.bom {
+				t.Errorf("Expected %s with BOM %q, got %s with %q", tt.encoding, tt.bom, profile.Encoding, profile.BOM)
+			}
+			if len(profile.Headers) != 2 || profile.Headers[0] != "name" {
+				t.Errorf("Expected the BOM to be stripped from the header, got %q", profile.Headers)
+			}
+			if len(profile.Issues) != len(tt.issues) {
+				t.Fatalf("Expected issues %v, got %+v", tt.issues, profile.Issues)
+			}
+			for i, kind := range tt.issues {
+				if profile.Issues[i].Kind != kind || profile.Issues[i].Line != 2 {
+					t.Errorf("Expected %s on line 2, got %+v", kind, profile.Issues[i])
+				}
+			}
+		})
+	}
+
+	if _, err := ProfileCSV(bytes.NewReader([]byte{0xFF, 0xFE, 0, 0, 'a', 0, 0, 0}), CSVProfileOptions{}); err == nil {
+		t.Error("Expected UTF-32 to be rejected")
+	}
+}
+
+func TestProfileCSVStreaming(t *testing.T) {
+	// Large enough to pass the histogram warmup and exercise range doubling
+	var b strings.Builder
+	b.WriteString("n,group\n")
+	for i := 0; i < 50000; i++ {
+		n := i % 1000
+		if i >= 25000 {
+			n = -n * 10
+		}
+		fmt.Fprintf(&b, "%d,g%d\n", n, i%3000)
+	}
+	b.WriteString("1,2,3\n")
+
+	path := filepath.Join(t.TempDir(), "big.csv")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := ProfileCSVFile(path, CSVProfileOptions{HistogramBins: 9, MaxIssues: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, group := profile.Columns[0], profile.Columns[1]
+	if profile.RowCount != 50001 || n.Min != "-9990" || n.Max != "999" {
+		t.Errorf("Unexpected profile: rows %d, min %s, max %s", profile.RowCount, n.Min, n.Max)
+	}
+	var total int64
+	for i, bin := range n.Histogram {
+		total += bin.Count
+		if i > 0 && bin.Lower != n.Histogram[i-1].Upper {
+			t.Errorf("Expected contiguous bins, got %+v", n.Histogram)
+		}
+	}
+	if total != 50001 || len(n.Histogram) > 10 {
+		t.Errorf("Expected 50001 values in at most 10 bins, got %d in %d", total, len(n.Histogram))
+	}
+	if n.Histogram[0].Lower > -9990 || n.Histogram[len(n.Histogram)-1].Upper < 999 {
+		t.Errorf("Expected the bins to cover the range, got %+v", n.Histogram)
+	}
+
+	// HyperLogLog is within a few percent of the true 3000 groups
+	if math.Abs(float64(group.Distinct)-3000) > 90 {
+		t.Errorf("Expected about 3000 distinct groups, got %d", group.Distinct)
+	}
+	if profile.IssueCount != 1 || profile.Issues[0].Line != 50002 {
+		t.Errorf("Expected the wide last row on line 50002, got %+v", profile.Issues)
+	}
+
+	limited, _ := ProfileCSVFile(path, CSVProfileOptions{MaxRows: 10})
+	if limited.RowCount != 10 || !limited.Truncated {
+		t.Errorf("Expected MaxRows to stop after 10 rows, got %d", limited.RowCount)
+	}
+}
+
+func TestProfileCSVHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		opts    CSVProfileOptions
+		headers []string
+		rows    int64
+		issues  int64
+	}{
+		{"numeric data", "1,2\n3,4\n", CSVProfileOptions{}, []string{"Column1", "Column2"}, 2, 0},
+		{"forced absent", "a,b\nc,d\n", CSVProfileOptions{Header: "absent"}, []string{"Column1", "Column2"}, 2, 0},
+		{"duplicate header", "a,a,\n1,2,3\n", CSVProfileOptions{}, []string{"a", "a", "Column3"}, 1, 1},
+		{"header only", "a,b\n", CSVProfileOptions{Header: "present"}, []string{"a", "b"}, 0, 0},
+		{"unterminated quote", "a,b\n1,\"2\n", CSVProfileOptions{}, []string{"a", "b"}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := ProfileCSV(strings.NewReader(tt.data), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(profile.Headers, "|") != strings.Join(tt.headers, "|") || profile.RowCount != tt.rows || profile.IssueCount != tt.issues {
+				t.Errorf("Expected %q, %d rows and %d issues, got %q, %d and %+v", tt.headers, tt.rows, tt.issues, profile.Headers, profile.RowCount, profile.Issues)
+			}
+		})
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000, 100000} {
+		h := newHyperLogLog(14)
+		for i := 0; i < n; i++ {
+			h.add(csvHash(fmt.Sprint("value-", i)))
+			h.add(csvHash(fmt.Sprint("value-", i))) // duplicates do not count
+		}
+		got := float64(h.estimate())
+		if math.Abs(got-float64(n)) > math.Max(1, 0.03*float64(n)) {
+			t.Errorf("Expected about %d distinct values, got %.0f", n, got)
+		}
+	}
+}
+
+func TestProfileCSVMalformedQuotes(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		line int
+		rows int64
+	}{
+		{"unterminated quote", "\"abc\n", 1, 0},
+		{"bare quote in header", "a\"b,c\n1,2\n", 1, 1},
+		{"unterminated last row", "a,b\n\"", 2, 0},
+		{"lone quote", "\"", 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := ProfileCSV(strings.NewReader(tt.data), CSVProfileOptions{})
+			if err != nil {
+				t.Fatalf("ProfileCSV failed: %v", err)
+			}
+			if profile.IssueCount == 0 || profile.Issues[0].Kind != "parse_error" || profile.Issues[0].Line != tt.line {
+				t.Errorf("Expected a parse error on line %d, got %+v", tt.line, profile.Issues)
+			}
+			if profile.RowCount != tt.rows {
+				t.Errorf("Expected %d rows, got %d", tt.rows, profile.RowCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package textlib
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const dedupBase = `The city council approved a new budget on Tuesday that increases funding for public parks,
+road repairs and the central library while cutting administrative costs across several departments.
+Officials said the plan would be reviewed again in the spring after updated revenue estimates arrive.`
+
+func TestMinHashJaccard(t *testing.T) {
+	opts := DedupOptions{NumHashes: 256}
+	base := ComputeMinHash(dedupBase, opts)
+
+	if j := base.Jaccard(ComputeMinHash(dedupBase, opts)); j != 1 {
+		t.Errorf("Expected identical texts to have Jaccard 1, got %f", j)
+	}
+
+	edited := strings.Replace(dedupBase, "Tuesday", "Wednesday", 1)
+	if j := base.Jaccard(ComputeMinHash(edited, opts)); j < 0.7 || j == 1 {
+		t.Errorf("Expected a one-word edit to stay similar, got %f", j)
+	}
+
+	other := ComputeMinHash("Scientists discovered a new species of frog in the rainforest canopy during a survey last year.", opts)
+	if j := base.Jaccard(other); j > 0.1 {
+		t.Errorf("Expected unrelated texts to have low Jaccard, got %f", j)
+	}
+
+	if j := base.Jaccard(ComputeMinHash(dedupBase, DedupOptions{NumHashes: 64})); j != 0 {
+		t.Errorf("Expected signatures of different lengths to compare as 0, got %f", j)
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	base := SimHash(dedupBase)
+	edited := SimHash(strings.Replace(dedupBase, "Tuesday", "Wednesday", 1))
+	other := SimHash("Scientists discovered a new species of frog in the rainforest canopy during a survey last year.")
+
+	if d := HammingDistance(base, edited); d > 10 {
+		t.Errorf("Expected near-duplicate fingerprints to be close, got distance %d", d)
+	}
+	if HammingDistance(base, other) <= HammingDistance(base, edited) {
+		t.Errorf("Expected unrelated text to be farther than a near duplicate")
+	}
+	if SimHash("Hello, WORLD!") != SimHash("hello world") {
+		t.Errorf("Expected case and punctuation to be ignored")
+	}
+}
+
+func TestDedupIndex(t *testing.T) {
+	index := NewDedupIndex(DedupOptions{Threshold: 0.7})
+
+	docs := []struct{ id, text string }{
+		{"a", dedupBase},
+		{"b", "Breaking: " + dedupBase},
+		{"c", "Scientists discovered a new species of frog in the rainforest canopy during a survey last year."},
+		{"d", strings.Replace(dedupBase, "spring", "autumn", 1)},
+		{"e", "Scientists discovered a new species of frog in the rainforest canopy during a survey last year!"},
+		{"f", "A completely different article about football results and league standings this weekend."},
+	}
+	for _, doc := range docs {
+		if err := index.Add(doc.id, doc.text); err != nil {
+			t.Fatalf("Add(%s): %v", doc.id, err)
+		}
+	}
+	if err := index.Add("a", "again"); err == nil {
+		t.Error("Expected an error for a duplicate ID")
+	}
+	if index.Len() != 6 {
+		t.Errorf("Expected 6 documents, got %d", index.Len())
+	}
+
+	clusters := index.Clusters()
+	var got []string
+	for _, c := range clusters {
+		got = append(got, strings.Join(c.IDs, ","))
+		if c.MinSimilarity < 0.7 {
+			t.Errorf("Cluster %v has similarity %f below the threshold", c.IDs, c.MinSimilarity)
+		}
+	}
+	if strings.Join(got, " ") != "a,b,d c,e" {
+		t.Errorf("Expected clusters [a,b,d c,e], got %v", got)
+	}
+
+	matches := index.Query(strings.Replace(dedupBase, "library", "museum", 1))
+	if len(matches) < 2 || matches[0].ID != "a" {
+		t.Fatalf("Expected the budget articles to match, got %+v", matches)
+	}
+	for _, m := range matches {
+		if m.ID == "c" || m.ID == "f" {
+			t.Errorf("Unexpected match %s", m.ID)
+		}
+	}
+
+	if err := index.AddSignature("g", MinHashSignature{1, 2, 3}, 0); err == nil {
+		t.Error("Expected an error for a signature of the wrong length")
+	}
+}
+
+func TestDedupSimHashDistance(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     DedupOptions
+		distance int
+		blocks   int
+	}{
+		{"default", DedupOptions{}, 3, 4},
+		{"exact", DedupOptions{SimHashDistance: SimHashExact}, 0, 1},
+		{"disabled", DedupOptions{SimHashDistance: SimHashDisabled}, -1, 0},
+		{"capped", DedupOptions{SimHashDistance: 20}, 7, 8},
+		{"other negative", DedupOptions{SimHashDistance: -5}, -1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := NewDedupIndex(tt.opts)
+			if index.simDistance != tt.distance || len(index.simBlocks) != tt.blocks {
+				t.Errorf("Expected distance %d with %d blocks, got %d with %d", tt.distance, tt.blocks, index.simDistance, len(index.simBlocks))
+			}
+		})
+	}
+
+	// At distance 0 only an identical fingerprint is an extra candidate
+	index := NewDedupIndex(DedupOptions{SimHashDistance: SimHashExact})
+	sig := ComputeMinHash(dedupBase, DedupOptions{})
+	other := make(MinHashSignature, len(sig))
+	if err := index.AddSignature("same", other, 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.AddSignature("near", other, 43); err != nil {
+		t.Fatal(err)
+	}
+	candidates := index.candidates(sig, 42)
+	if len(candidates) != 1 || index.ids[candidates[0]] != "same" {
+		t.Errorf("Expected only the identical fingerprint, got %v", candidates)
+	}
+}
+
+func TestDedupIndexConcurrentAdd(t *testing.T) {
+	index := NewDedupIndex(DedupOptions{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			text := dedupBase
+			if i%2 == 1 {
+				text = fmt.Sprintf("Unique document number %d about topic %d with its own words", i, i*7)
+			}
+			if err := index.Add(fmt.Sprint(i), text); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	clusters := index.Clusters()
+	if len(clusters) != 1 || len(clusters[0].IDs) != 25 {
+		t.Errorf("Expected one cluster of the 25 identical documents, got %d clusters", len(clusters))
+	}
+}
+
+func TestFindNearDuplicates(t *testing.T) {
+	docs := map[string]string{
+		"doc1": dedupBase,
+		"doc2": dedupBase + " Updated at noon.",
+		"doc3": "Scientists discovered a new species of frog in the rainforest canopy during a survey last year.",
+	}
+
+	clusters := FindNearDuplicates(docs, DedupOptions{Threshold: 0.7})
+	if len(clusters) != 1 {
+		t.Fatalf("Expected 1 cluster, got %d", len(clusters))
+	}
+	if strings.Join(clusters[0].IDs, ",") != "doc1,doc2" {
+		t.Errorf("Expected doc1,doc2, got %v", clusters[0].IDs)
+	}
+}
+
+func BenchmarkDedupIndexAdd(b *testing.B) {
+	index := NewDedupIndex(DedupOptions{})
+	for i := 0; i < b.N; i++ {
+		index.Add(fmt.Sprint(i), fmt.Sprintf("%s Document %d.", dedupBase, i))
+	}
+}
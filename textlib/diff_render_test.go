@@ -0,0 +1,93 @@
+package textlib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderDiffHTML(t *testing.T) {
+	diff := CalculateDiff("The cat sat.\nIt was <warm>.\n", "The dog sat.\nIt was <warm>.\nThe end.\n")
+
+	inline := RenderDiffHTML(diff, DiffRenderOptions{})
+	for _, want := range []string{
+		"<del>cat</del><ins>dog</ins>",
+		"It was &lt;warm&gt;.",
+		`<div class="diff-change"><ins>The end.</ins></div>`,
+	} {
+		if !strings.Contains(inline, want) {
+			t.Errorf("Expected inline HTML to contain %q, got:\n%s", want, inline)
+		}
+	}
+
+	sideBySide := RenderDiffHTML(diff, DiffRenderOptions{SideBySide: true})
+	for _, want := range []string{
+		`<td class="diff-old">The <del>cat</del> sat.</td>`,
+		`<td class="diff-new">The <ins>dog</ins> sat.</td>`,
+		`<td class="diff-num"></td><td class="diff-old"></td><td class="diff-num">3</td>`,
+	} {
+		if !strings.Contains(sideBySide, want) {
+			t.Errorf("Expected side-by-side HTML to contain %q, got:\n%s", want, sideBySide)
+		}
+	}
+}
+
+func TestRenderDiffCollapse(t *testing.T) {
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, "unchanged line")
+	}
+	oldText := strings.Join(lines, "\n") + "\n"
+	lines[10] = "changed line"
+	newText := strings.Join(lines, "\n") + "\n"
+	diff := CalculateDiff(oldText, newText)
+
+	tests := []struct {
+		name string
+		opts DiffRenderOptions
+		want []string
+	}{
+		{"default context", DiffRenderOptions{Collapse: true}, []string{"@@ 7 unchanged lines @@", "@@ 6 unchanged lines @@"}},
+		{"one line", DiffRenderOptions{Collapse: true, Context: 1}, []string{"@@ 9 unchanged lines @@", "@@ 8 unchanged lines @@"}},
+		{"no context", DiffRenderOptions{Collapse: true, Context: -1}, []string{"@@ 10 unchanged lines @@", "@@ 9 unchanged lines @@"}},
+		{"not collapsed", DiffRenderOptions{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := RenderDiffANSI(diff, tt.opts)
+			var markers []string
+			for _, line := range strings.Split(out, "\n") {
+				if strings.Contains(line, "@@") {
+					markers = append(markers, strings.TrimSuffix(strings.TrimPrefix(line, ansiDim), ansiReset))
+				}
+			}
+			if strings.Join(markers, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Expected markers %q, got %q", tt.want, markers)
+			}
+		})
+	}
+}
+
+func TestRenderDiffANSI(t *testing.T) {
+	diff := CalculateDiff("keep\nold line\n", "keep\nnew line\nextra\n")
+	out := RenderDiffANSI(diff, DiffRenderOptions{})
+
+	want := "  keep\n" +
+		"~ " + ansiRed + "old" + ansiReset + ansiGreen + "new" + ansiReset + " line\n" +
+		ansiGreen + "+ " + ansiReset + ansiGreen + "extra" + ansiReset + "\n"
+	if out != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+}
+
+func TestRenderDiffMarkdown(t *testing.T) {
+	diff := CalculateDiff("Use the *old* API today.\n", "Use the new API.\n")
+	out := RenderDiffMarkdown(diff, DiffRenderOptions{Summary: true})
+
+	if !strings.HasPrefix(out, `Use the ~~\*old\*~~**new** API ~~today~~.`) {
+		t.Errorf("Unexpected redline:\n%s", out)
+	}
+	if !strings.Contains(out, "\n- ") {
+		t.Errorf("Expected a summary list, got:\n%s", out)
+	}
+}
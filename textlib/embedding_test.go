@@ -0,0 +1,160 @@
+package textlib
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const testTilingText = `Volcanoes form where magma rises through the crust. Magma chambers feed eruptions of lava and ash. Volcanic ash can travel thousands of kilometres. Lava flows cool into basalt rock. Eruptions are monitored by volcanologists with seismometers.
+
+The stock market fell sharply on Monday. Investors sold shares in technology companies. Market analysts blamed rising interest rates. Bond yields climbed as shares dropped. Traders expect further market volatility this week.
+
+Tomatoes grow best in warm, sunny gardens. Gardeners water tomato plants deeply at the roots. Pruning tomato plants improves air flow. Compost feeds the garden soil. Ripe tomatoes are picked in late summer.`
+
+func TestHashedNGramEmbedder(t *testing.T) {
+	e := NewHashedNGramEmbedder(256)
+	if e.Dimensions() != 256 {
+		t.Fatalf("Expected 256 dimensions, got %d", e.Dimensions())
+	}
+
+	v := e.Embed("Volcanic eruptions spread ash")
+	if len(v) != 256 {
+		t.Fatalf("Expected vector of length 256, got %d", len(v))
+	}
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+	if math.Abs(norm-1) > 1e-9 {
+		t.Errorf("Expected unit vector, got squared norm %f", norm)
+	}
+
+	related := VectorCosineSimilarity(e.Embed("The cats are sleeping"), e.Embed("A cat sleeps"))
+	unrelated := VectorCosineSimilarity(e.Embed("The cats are sleeping"), e.Embed("Stock markets fell"))
+	if related <= unrelated {
+		t.Errorf("Expected inflected forms to be closer (%f) than unrelated text (%f)", related, unrelated)
+	}
+
+	if sim := VectorCosineSimilarity(e.Embed("the and of"), e.Embed("lava")); sim != 0 {
+		t.Errorf("Expected stop words alone to embed as the zero vector, got similarity %f", sim)
+	}
+}
+
+func TestVectorCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []float64
+		expected float64
+	}{
+		{"identical", []float64{1, 2, 3}, []float64{1, 2, 3}, 1},
+		{"opposite", []float64{1, 0}, []float64{-1, 0}, -1},
+		{"orthogonal", []float64{1, 0}, []float64{0, 1}, 0},
+		{"zero vector", []float64{0, 0}, []float64{1, 1}, 0},
+		{"length mismatch", []float64{1}, []float64{1, 1}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VectorCosineSimilarity(tt.a, tt.b); math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("Expected %f, got %f", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFindTopicBoundaries(t *testing.T) {
+	boundaries := FindTopicBoundaries(testTilingText, TextTilingOptions{})
+
+	var starts []string
+	for _, b := range boundaries {
+		starts = append(starts, testTilingText[b.Offset:b.Offset+strings.Index(testTilingText[b.Offset:], " ")])
+	}
+	if strings.Join(starts, ",") != "The,Tomatoes" {
+		t.Fatalf("Expected boundaries before the market and garden topics, got %v", boundaries)
+	}
+	if boundaries[0].SentenceIndex != 5 || boundaries[1].SentenceIndex != 10 {
+		t.Errorf("Expected boundaries at sentences 5 and 10, got %d and %d", boundaries[0].SentenceIndex, boundaries[1].SentenceIndex)
+	}
+	for _, b := range boundaries {
+		if b.Depth <= 0 {
+			t.Errorf("Expected positive depth, got %f", b.Depth)
+		}
+	}
+
+	if got := FindTopicBoundaries("One sentence only.", TextTilingOptions{}); len(got) != 0 {
+		t.Errorf("Expected no boundaries for a single sentence, got %v", got)
+	}
+}
+
+func TestSegmentByTextTiling(t *testing.T) {
+	segments := SegmentText(testTilingText, "texttiling").Segments
+	if len(segments) != 3 {
+		t.Fatalf("Expected 3 topic segments, got %d", len(segments))
+	}
+
+	for i, seg := range segments {
+		if testTilingText[seg.Start:seg.End] != seg.Text {
+			t.Errorf("Segment %d offsets do not match its text", i)
+		}
+		if seg.Metadata["sentenceCount"] != 5 {
+			t.Errorf("Segment %d: expected 5 sentences, got %v", i, seg.Metadata["sentenceCount"])
+		}
+	}
+	if !strings.HasPrefix(segments[2].Text, "Tomatoes") {
+		t.Errorf("Expected last segment to start with the garden topic, got %q", segments[2].Text)
+	}
+}
+
+// fixedEmbedder maps known sentences to hand-picked vectors
+type fixedEmbedder map[string][]float64
+
+func (f fixedEmbedder) Dimensions() int { return 2 }
+
+func (f fixedEmbedder) Embed(text string) []float64 {
+	if v, ok := f[text]; ok {
+		return v
+	}
+	return []float64{0, 0}
+}
+
+func TestChunkTextSemanticEmbedder(t *testing.T) {
+	embedder := fixedEmbedder{
+		"Alpha one.": {1, 0},
+		"Alpha two.": {1, 0.1},
+		"Beta one.":  {0, 1},
+		"Beta two.":  {0.1, 1},
+	}
+	strategy := ChunkingStrategy{Method: "semantic", MaxTokens: 2, SemanticThreshold: 0.5, Embedder: embedder}
+
+	chunks := ChunkText("Alpha one. Alpha two. Beta one. Beta two.", strategy)
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(chunks))
+	}
+	if chunks[0].Content != "Alpha one. Alpha two." || chunks[1].Content != "Beta one. Beta two." {
+		t.Errorf("Expected chunks split at the embedding shift, got %q and %q", chunks[0].Content, chunks[1].Content)
+	}
+}
+
+func TestSegmentTextWithEmbedder(t *testing.T) {
+	embedder := fixedEmbedder{
+		"Alpha one.": {1, 0},
+		"Alpha two.": {1, 0.1},
+		"Beta one.":  {0, 1},
+		"Beta two.":  {0.1, 1},
+	}
+	text := "Alpha one. Alpha two. Beta one. Beta two."
+
+	for _, method := range []string{"topic", "semantic"} {
+		t.Run(method, func(t *testing.T) {
+			result := SegmentTextWithOptions(text, method, SegmentOptions{Embedder: embedder})
+			if len(result.Segments) != 2 {
+				t.Fatalf("Expected 2 segments, got %d", len(result.Segments))
+			}
+			if result.Segments[0].Text != "Alpha one. Alpha two." || result.Segments[1].Text != "Beta one. Beta two." {
+				t.Errorf("Expected segments split at the embedding shift, got %q and %q",
+					result.Segments[0].Text, result.Segments[1].Text)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"log"
-	"strings"
 	
 	"github.com/caiatech/textlib"
 )
@@ -32,10 +31,6 @@ func main() {
 	// Example 6: File Processing
 	fmt.Println("\n=== File Processing ===")
 	fileProcessing()
-	
-	// Example 7: RL-Optimized Analysis (NEW!)
-	fmt.Println("\n=== RL-Optimized Analysis ===")
-	optimizedAnalysis()
 }
 
 func basicTextAnalysis() {
@@ -245,58 +240,6 @@ func fileProcessing() {
 	fmt.Println("See the test files for complete working examples.")
 }
 
-// Example 7: RL-Optimized Analysis showcases the new AI-discovered patterns
-func optimizedAnalysis() {
-	text := `OpenAI announced GPT-4, their most advanced language model. 
-	The model shows significant improvements in reasoning and accuracy. 
-	Technical benchmarks indicate a 40% performance increase over GPT-3.5.`
-	
-	fmt.Println("1. SmartAnalyze - Comprehensive optimized analysis:")
-	result := textlib.SmartAnalyze(text)
-	fmt.Printf("   Found %d entities including: %s\n", 
-		len(result.Entities), getEntitySample(result.Entities))
-	fmt.Printf("   Readability: %s (Grade level: %.1f)\n", 
-		result.Readability.Complexity, result.Readability.GradeLevel)
-	fmt.Printf("   Sentiment: %s\n", result.Sentiment.Tone)
-	fmt.Printf("   Top keywords: %v\n", result.Keywords[:min(3, len(result.Keywords))])
-	
-	fmt.Println("\n2. Domain-Optimized Analysis:")
-	techResult := textlib.DomainOptimizedAnalyze(text, "technical")
-	fmt.Printf("   Optimization used: %s\n", techResult.ProcessingInfo.OptimizationUsed)
-	fmt.Printf("   Technical complexity: %.1f\n", techResult.Readability.GradeLevel)
-	
-	fmt.Println("\n3. Quick Insights for short text:")
-	shortText := "Amazing AI breakthrough! #GPT4 @OpenAI"
-	insights := textlib.QuickInsights(shortText)
-	fmt.Printf("   Summary: %s\n", insights.Summary)
-	fmt.Printf("   Sentiment: %s\n", insights.Sentiment.Tone)
-	
-	fmt.Println("\n4. Validated Extraction (15% more accurate):")
-	entities := textlib.ValidatedExtraction(text)
-	fmt.Printf("   Validated entities: %d (cleaned and merged)\n", len(entities))
-	
-	fmt.Println("\nThese optimized functions use patterns discovered through")
-	fmt.Println("reinforcement learning to provide better results faster!")
-}
-
-func getEntitySample(entities []Entity) string {
-	if len(entities) == 0 {
-		return "none"
-	}
-	sample := []string{}
-	for i := 0; i < len(entities) && i < 3; i++ {
-		sample = append(sample, entities[i].Text)
-	}
-	return strings.Join(sample, ", ")
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 // Example helper function to demonstrate error handling
 func safeFileOperation(filename string) {
 	defer func() {
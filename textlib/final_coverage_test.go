@@ -35,16 +35,16 @@ func TestSegmentTextBasic(t *testing.T) {
 }
 
 func TestChunkTextBasic(t *testing.T) {
-	result := ChunkText("The quick brown fox jumps over the lazy dog.", "tokens", 5)
-	if len(result.Chunks) == 0 {
+	result := ChunkText("The quick brown fox jumps over the lazy dog.", ChunkingStrategy{Method: "token", MaxTokens: 5})
+	if len(result) == 0 {
 		t.Error("ChunkText returned no chunks")
 	}
 }
 
 func TestCalculateSimilarityBasic(t *testing.T) {
-	result := CalculateSimilarity("hello world", "hello there", "jaccard")
-	if result < 0 || result > 1 {
-		t.Errorf("CalculateSimilarity returned out of range value: %f", result)
+	result := CalculateSimilarity("hello world", "hello there")
+	if result.JaccardIndex < 0 || result.JaccardIndex > 1 {
+		t.Errorf("CalculateSimilarity returned out of range value: %f", result.JaccardIndex)
 	}
 }
 
@@ -71,15 +71,15 @@ func TestAnalyzeDialogueBasic(t *testing.T) {
 
 func TestAnalyzeCoherenceBasic(t *testing.T) {
 	result := AnalyzeCoherence("The cat sat on the mat. It was black and white.")
-	if result.OverallScore < 0 || result.OverallScore > 1 {
-		t.Errorf("AnalyzeCoherence returned out of range score: %f", result.OverallScore)
+	if result.OverallCoherence < 0 || result.OverallCoherence > 1 {
+		t.Errorf("AnalyzeCoherence returned out of range score: %f", result.OverallCoherence)
 	}
 }
 
 func TestFindUnusedFilesBasic(t *testing.T) {
-	result := FindUnusedFiles(".")
+	result, err := FindUnusedFiles(".")
 	// Just test that it doesn't panic
-	_ = result
+	_, _ = result, err
 }
 
 func TestExpandExpressionBasic(t *testing.T) {
@@ -97,15 +97,15 @@ func TestFactorExpressionBasic(t *testing.T) {
 }
 
 func TestFindGCFBasic(t *testing.T) {
-	result := findGCF([]int{12, 18, 24})
-	if result <= 0 {
-		t.Errorf("findGCF returned invalid result: %d", result)
+	result := findGCF([]string{"12", "18", "24"})
+	if result == "" {
+		t.Errorf("findGCF returned invalid result: %q", result)
 	}
 }
 
 func TestDetectMathPatternsBasic(t *testing.T) {
 	result := DetectMathPatterns("1, 3, 5, 7, 9")
-	if len(result.ArithmeticSequences) == 0 {
+	if len(result) == 0 {
 		t.Error("DetectMathPatterns found no arithmetic sequences")
 	}
 }
@@ -119,13 +119,14 @@ func TestVerifyImageIntegrityBasic(t *testing.T) {
 
 func TestValidateVideoCodecBasic(t *testing.T) {
 	// Test with a simple path  
-	result := ValidateVideoCodec("nonexistent.mp4")
-	// Should return an error for non-existent file
-	_ = result
+	_, err := ValidateVideoCodec("nonexistent.mp4")
+	if err == nil {
+		t.Error("ValidateVideoCodec should return an error for a non-existent file")
+	}
 }
 
 func TestOptimalChunkSizeBasic(t *testing.T) {
-	result := OptimalChunkSize("This is a test text for chunking analysis.")
+	result := OptimalChunkSize("This is a test text for chunking analysis.", 2)
 	if result <= 0 {
 		t.Errorf("OptimalChunkSize returned invalid size: %d", result)
 	}
@@ -139,10 +140,10 @@ func TestFindNaturalBoundariesBasic(t *testing.T) {
 }
 
 func TestMergeSmallSegmentsBasic(t *testing.T) {
-	segments := []TextSegment{
-		{Content: "Short", WordCount: 1},
-		{Content: "Also short", WordCount: 2},
-		{Content: "This is a longer segment with more words", WordCount: 8},
+	segments := []Segment{
+		{Text: "Short", TokenCount: 1},
+		{Text: "Also short", TokenCount: 2},
+		{Text: "This is a longer segment with more words", TokenCount: 8},
 	}
 	result := MergeSmallSegments(segments, 5)
 	if len(result) == 0 {
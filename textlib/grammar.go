@@ -3,7 +3,7 @@ package textlib
 import (
 	"regexp"
 	"strings"
-	"unicode"
+	"unicode/utf8"
 )
 
 // GrammarAnalysis contains comprehensive grammar analysis results
@@ -333,57 +333,14 @@ func identifyClauses(sentence string) ([]string, []string) {
 }
 
 func hasSubjectAndPredicate(clause string) bool {
-	words := strings.Fields(clause)
-	if len(words) < 2 {
+	tokens := TagPartsOfSpeech(clause)
+	if countWordTokens(tokens) < 2 {
 		return false
 	}
 	
 	// Simple check: look for a verb
-	hasVerb := false
-	for _, word := range words {
-		if isVerb(word) {
-			hasVerb = true
-			break
-		}
-	}
-	
-	return hasVerb
-}
-
-func isVerb(word string) bool {
-	// Simple verb detection (can be enhanced)
-	word = strings.ToLower(strings.Trim(word, ".,!?;:"))
-	
-	// Common verb patterns
-	verbPatterns := []string{
-		`.*ed$`,   // past tense
-		`.*ing$`,  // present participle
-		`.*s$`,    // third person singular
-	}
-	
-	for _, pattern := range verbPatterns {
-		matched, _ := regexp.MatchString(pattern, word)
-		if matched {
-			return true
-		}
-	}
-	
-	// Common irregular verbs and simple verbs
-	irregularVerbs := []string{
-		"is", "are", "was", "were", "be", "been", "being",
-		"have", "has", "had", "do", "does", "did", "will",
-		"would", "could", "should", "can", "may", "might",
-		"go", "went", "come", "came", "see", "saw", "get", "got",
-		"make", "made", "take", "took", "give", "gave", "know", "knew",
-		"sit", "sat", "run", "ran", "walk", "walked", "talk", "talked",
-		"eat", "ate", "drink", "drank", "sleep", "slept", "work", "worked",
-		"play", "played", "read", "write", "wrote", "think", "thought",
-		"find", "found", "tell", "told", "say", "said", "feel", "felt",
-		"look", "looked", "listen", "heard", "move", "moved", "stop", "stopped",
-	}
-	
-	for _, verb := range irregularVerbs {
-		if word == verb {
+	for _, tok := range tokens {
+		if isVerbTag(tok.Tag) {
 			return true
 		}
 	}
@@ -503,9 +460,9 @@ func checkBalanceGeneric(text string, open, close rune) BalanceCheck {
 	}
 	
 	openStack := []int{}
-	runes := []rune(text)
 	
-	for i, r := range runes {
+	// Positions are byte offsets, like every other Position in the library
+	for i, r := range text {
 		if r == open {
 			openStack = append(openStack, i)
 			check.OpenCount++
@@ -514,14 +471,14 @@ func checkBalanceGeneric(text string, open, close rune) BalanceCheck {
 			if len(openStack) > 0 {
 				openStack = openStack[:len(openStack)-1]
 			} else {
-				check.UnmatchedClose = append(check.UnmatchedClose, Position{Start: i, End: i + 1})
+				check.UnmatchedClose = append(check.UnmatchedClose, Position{Start: i, End: i + utf8.RuneLen(close)})
 			}
 		}
 	}
 	
 	// Remaining open positions are unmatched
 	for _, pos := range openStack {
-		check.UnmatchedOpen = append(check.UnmatchedOpen, Position{Start: pos, End: pos + 1})
+		check.UnmatchedOpen = append(check.UnmatchedOpen, Position{Start: pos, End: pos + utf8.RuneLen(open)})
 	}
 	
 	check.IsBalanced = len(check.UnmatchedOpen) == 0 && len(check.UnmatchedClose) == 0
@@ -624,41 +581,16 @@ func isSentenceFragment(sentence string) bool {
 		return true
 	}
 	
-	words := strings.Fields(sentence)
-	if len(words) < 2 {
+	tokens := TagPartsOfSpeech(sentence)
+	if countWordTokens(tokens) < 2 {
 		return true
 	}
 	
 	// Check for subject and predicate
-	hasSubject := false
-	hasPredicate := false
-	hasDeterminer := false
-	
-	for _, word := range words {
-		if isPronoun(word) || isNoun(word) {
-			hasSubject = true
-		}
-		if isDeterminer(word) {
-			hasDeterminer = true
-		}
-		if isVerb(word) {
-			hasPredicate = true
-		}
-	}
-	
-	// A determiner followed by a noun can form a subject
-	if hasDeterminer && !hasSubject {
-		// Look for determiner + noun pattern
-		for i := 0; i < len(words)-1; i++ {
-			if isDeterminer(words[i]) && isNoun(words[i+1]) {
-				hasSubject = true
-				break
-			}
-		}
-	}
+	hasSubject, hasPredicate := findSubjectAndPredicate(tokens)
 	
 	// Check if it starts with subordinating conjunction without main clause
-	firstWord := strings.ToLower(strings.Trim(words[0], ".,!?;:"))
+	firstWord := strings.ToLower(tokens[0].Text)
 	subordConj := []string{"because", "since", "although", "when", "while", "if", "unless"}
 	
 	startsWithSubord := false
@@ -679,18 +611,7 @@ func isSentenceFragment(sentence string) bool {
 }
 
 func identifyMissingElement(sentence string) string {
-	words := strings.Fields(sentence)
-	hasSubject := false
-	hasPredicate := false
-	
-	for _, word := range words {
-		if isPronoun(word) || isNoun(word) {
-			hasSubject = true
-		}
-		if isVerb(word) {
-			hasPredicate = true
-		}
-	}
+	hasSubject, hasPredicate := findSubjectAndPredicate(TagPartsOfSpeech(sentence))
 	
 	if !hasSubject && !hasPredicate {
 		return "complete_thought"
@@ -703,6 +624,29 @@ func identifyMissingElement(sentence string) string {
 	return "complete_thought"
 }
 
+// findSubjectAndPredicate reports whether tagged tokens contain a nominal
+// subject and a finite verb. Imperatives ("Close the door.") count as having
+// an implied subject.
+func findSubjectAndPredicate(tokens []TaggedToken) (bool, bool) {
+	hasSubject := false
+	hasPredicate := false
+	
+	for i, tok := range tokens {
+		if isSubjectTag(tok.Tag) {
+			hasSubject = true
+		}
+		if isFiniteVerbTag(tok.Tag) {
+			hasPredicate = true
+		}
+		if i == 0 && tok.Tag == "VB" {
+			hasSubject = true
+			hasPredicate = true
+		}
+	}
+	
+	return hasSubject, hasPredicate
+}
+
 func suggestFragmentFix(sentence, missingElement string) string {
 	switch missingElement {
 	case "subject":
@@ -1131,25 +1075,18 @@ func checkTenseConsistency(text string) []TenseIssue {
 }
 
 func identifyTense(sentence string) string {
-	// Simple tense identification
-	words := strings.Fields(strings.ToLower(sentence))
-	
-	for _, word := range words {
-		word = strings.Trim(word, ".,!?;:")
-		
-		// Past tense indicators
-		if strings.HasSuffix(word, "ed") || word == "was" || word == "were" {
+	// The first finite verb determines the tense of the sentence
+	for _, tok := range TagPartsOfSpeech(sentence) {
+		switch tok.Tag {
+		case "VBD":
 			return "past"
-		}
-		
-		// Present tense indicators
-		if word == "is" || word == "are" || word == "am" {
+		case "VBZ", "VBP":
 			return "present"
-		}
-		
-		// Future tense indicators
-		if word == "will" || word == "shall" {
-			return "future"
+		case "MD":
+			word := strings.ToLower(tok.Text)
+			if word == "will" || word == "shall" || word == "wo" {
+				return "future"
+			}
 		}
 	}
 	
@@ -1320,71 +1257,12 @@ func assessReadabilityImpact(analysis *GrammarAnalysis) string {
 
 // Utility functions
 
-func isNoun(word string) bool {
-	// Simple heuristic for noun detection
-	word = strings.ToLower(strings.Trim(word, ".,!?;:"))
-	
-	// Common noun patterns
-	nounSuffixes := []string{"tion", "sion", "ness", "ment", "ity", "er", "or", "ist"}
-	for _, suffix := range nounSuffixes {
-		if strings.HasSuffix(word, suffix) {
-			return true
+func countWordTokens(tokens []TaggedToken) int {
+	count := 0
+	for _, tok := range tokens {
+		if isWordTag(tok.Tag) {
+			count++
 		}
 	}
-	
-	// Common simple nouns (for better detection)
-	commonNouns := []string{
-		"cat", "dog", "house", "car", "book", "table", "chair", "door", "window",
-		"man", "woman", "child", "boy", "girl", "person", "people",
-		"day", "night", "time", "year", "month", "week",
-		"water", "food", "money", "work", "school", "home",
-		"mat", "tree", "bird", "fish", "hand", "head", "eye", "foot",
-	}
-	
-	for _, noun := range commonNouns {
-		if word == noun {
-			return true
-		}
-	}
-	
-	// Capitalize words are often nouns (proper nouns)
-	original := strings.Trim(word, ".,!?;:")
-	if len(original) > 0 && unicode.IsUpper(rune(original[0])) {
-		return true
-	}
-	
-	return false
+	return count
 }
-
-func isDeterminer(word string) bool {
-	word = strings.ToLower(strings.Trim(word, ".,!?;:"))
-	determiners := []string{"the", "a", "an", "this", "that", "these", "those", "my", "your", "his", "her", "its", "our", "their"}
-	
-	for _, det := range determiners {
-		if word == det {
-			return true
-		}
-	}
-	
-	return false
-}
-
-func isPronoun(word string) bool {
-	word = strings.ToLower(strings.Trim(word, ".,!?;:"))
-	pronouns := []string{
-		"i", "you", "he", "she", "it", "we", "they",
-		"me", "him", "her", "us", "them",
-		"my", "your", "his", "her", "its", "our", "their",
-		"mine", "yours", "hers", "ours", "theirs",
-		"this", "that", "these", "those",
-		"who", "whom", "whose", "which", "what",
-	}
-	
-	for _, pronoun := range pronouns {
-		if word == pronoun {
-			return true
-		}
-	}
-	
-	return false
-}
\ No newline at end of file
@@ -0,0 +1,1186 @@
+package textlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Base URI for schemas compiled from bytes. Relative references resolve
+// against it but are never loaded, since there is no directory to load from.
+const jsonSchemaDefaultBase = "mem:///schema.json"
+
+// Guards against $ref cycles that never consume any of the instance
+const jsonSchemaMaxDepth = 128
+
+// JSONSchema is a compiled JSON Schema (draft 2020-12). It supports $ref
+// with $id, $anchor and JSON Pointer fragments, the in-place applicators
+// (allOf, anyOf, oneOf, not, if/then/else), the object, array, string and
+// numeric keywords, unevaluatedProperties/unevaluatedItems, and assertion of
+// the common formats. Draft-07 "definitions" and array-form "items" are
+// accepted too.
+type JSONSchema struct {
+	root      interface{}
+	resources map[string]interface{}  // schema by absolute URI without fragment
+	anchors   map[string]interface{}  // schema by URI#anchor
+	refs      map[uintptr]interface{} // resolved $ref target by the object holding it
+	patterns  map[string]*regexp.Regexp
+}
+
+// CompileJSONSchema compiles a schema document. References to other files
+// cannot be resolved; use LoadJSONSchema for those.
+func CompileJSONSchema(schema []byte) (*JSONSchema, error) {
+	return compileJSONSchema(schema, jsonSchemaDefaultBase)
+}
+
+// LoadJSONSchema compiles the schema at path, loading files it references
+// relative to it
+func LoadJSONSchema(path string) (*JSONSchema, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	return compileJSONSchema(content, (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String())
+}
+
+// ValidateJSONAgainstSchema validates the JSON document at jsonPath against
+// the schema at schemaPath. Syntax errors are reported like
+// ValidateJSONStructure; schema violations carry the JSON Pointer of the
+// offending value and its line and column. The error is non-nil only when
+// a file cannot be read or the schema is invalid.
+func ValidateJSONAgainstSchema(jsonPath, schemaPath string) ([]ValidationError, error) {
+	schema, err := LoadJSONSchema(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	return schema.Validate(content), nil
+}
+
+// Validate checks a JSON document against the schema. Errors are ordered by
+// their position in the document.
+func (s *JSONSchema) Validate(document []byte) []ValidationError {
+	if errs := jsonSyntaxErrors(document); len(errs) > 0 {
+		return errs
+	}
+	instance, offsets, err := decodeJSONWithOffsets(document)
+	if err != nil {
+		return []ValidationError{{Line: 1, Column: 1, Message: err.Error(), Code: "PARSE_ERROR"}}
+	}
+
+	v := &schemaValidator{schema: s, content: document, offsets: offsets}
+	errs, _ := v.validate(s.root, instance, "")
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return errs
+}
+
+func compileJSONSchema(content []byte, base string) (*JSONSchema, error) {
+	root, err := decodeJSONNumbers(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	s := &JSONSchema{
+		root:      root,
+		resources: make(map[string]interface{}),
+		anchors:   make(map[string]interface{}),
+		refs:      make(map[uintptr]interface{}),
+		patterns:  make(map[string]*regexp.Regexp),
+	}
+
+	type pendingRef struct {
+		holder map[string]interface{}
+		base   string
+		ref    string
+	}
+	var pending []pendingRef
+	var walk func(node interface{}, base string) error
+	walk = func(node interface{}, base string) error {
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			if _, isBool := node.(bool); isBool {
+				return nil
+			}
+			return fmt.Errorf("invalid schema: expected an object or boolean, got %s", jsonTypeName(node))
+		}
+
+		if id, ok := obj["$id"].(string); ok {
+			if strings.HasPrefix(id, "#") {
+				s.anchors[base+id] = obj
+			} else {
+				resolved, err := resolveSchemaURI(base, id)
+				if err != nil {
+					return err
+				}
+				base = resolved
+				s.resources[base] = obj
+			}
+		}
+		if anchor, ok := obj["$anchor"].(string); ok {
+			s.anchors[base+"#"+anchor] = obj
+		}
+		if anchor, ok := obj["$dynamicAnchor"].(string); ok {
+			s.anchors[base+"#"+anchor] = obj
+		}
+		for _, key := range []string{"$ref", "$dynamicRef"} {
+			if ref, ok := obj[key].(string); ok {
+				pending = append(pending, pendingRef{obj, base, ref})
+			}
+		}
+		if pattern, ok := obj["pattern"].(string); ok {
+			if err := s.compilePattern(pattern); err != nil {
+				return err
+			}
+		}
+		if props, ok := obj["patternProperties"].(map[string]interface{}); ok {
+			for pattern := range props {
+				if err := s.compilePattern(pattern); err != nil {
+					return err
+				}
+			}
+		}
+
+		for key, value := range obj {
+			switch key {
+			case "items", "additionalItems", "additionalProperties", "contains", "not", "if", "then", "else",
+				"propertyNames", "unevaluatedProperties", "unevaluatedItems":
+				if list, ok := value.([]interface{}); ok && key == "items" {
+					for _, sub := range list {
+						if err := walk(sub, base); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+				if err := walk(value, base); err != nil {
+					return err
+				}
+			case "allOf", "anyOf", "oneOf", "prefixItems":
+				list, ok := value.([]interface{})
+				if !ok {
+					return fmt.Errorf("invalid schema: %s must be an array", key)
+				}
+				for _, sub := range list {
+					if err := walk(sub, base); err != nil {
+						return err
+					}
+				}
+			case "$defs", "definitions", "properties", "patternProperties", "dependentSchemas":
+				subs, ok := value.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("invalid schema: %s must be an object", key)
+				}
+				for _, sub := range subs {
+					if err := walk(sub, base); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	s.resources[base] = root
+	if err := walk(root, base); err != nil {
+		return nil, err
+	}
+
+	// Resolve references, loading referenced files as they are found
+	for i := 0; i < len(pending); i++ {
+		p := pending[i]
+		target, err := s.resolveRef(p.base, p.ref)
+		if err != nil {
+			resource, loadErr := s.loadResource(p.base, p.ref)
+			if loadErr != nil {
+				return nil, err
+			}
+			if err := walk(resource.node, resource.uri); err != nil {
+				return nil, err
+			}
+			if target, err = s.resolveRef(p.base, p.ref); err != nil {
+				return nil, err
+			}
+		}
+		s.refs[reflect.ValueOf(p.holder).Pointer()] = target
+	}
+	return s, nil
+}
+
+type schemaResource struct {
+	uri  string
+	node interface{}
+}
+
+// loadResource reads the file a reference points to and registers it
+func (s *JSONSchema) loadResource(base, ref string) (schemaResource, error) {
+	resolved, err := resolveSchemaURI(base, ref)
+	if err != nil {
+		return schemaResource{}, err
+	}
+	u, err := url.Parse(resolved)
+	if err != nil || u.Scheme != "file" {
+		return schemaResource{}, fmt.Errorf("cannot load schema %q", resolved)
+	}
+	content, err := os.ReadFile(filepath.FromSlash(u.Path))
+	if err != nil {
+		return schemaResource{}, err
+	}
+	node, err := decodeJSONNumbers(content)
+	if err != nil {
+		return schemaResource{}, fmt.Errorf("invalid schema %s: %w", u.Path, err)
+	}
+	s.resources[resolved] = node
+	return schemaResource{resolved, node}, nil
+}
+
+// resolveRef finds the schema a reference points to
+func (s *JSONSchema) resolveRef(base, ref string) (interface{}, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URI %q: %w", base, err)
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+	u := baseURL.ResolveReference(refURL)
+	fragment := u.Fragment
+	u.Fragment = ""
+	resource, ok := s.resources[u.String()]
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+
+	if fragment == "" {
+		return resource, nil
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		if target, ok := s.anchors[u.String()+"#"+fragment]; ok {
+			return target, nil
+		}
+		return nil, fmt.Errorf("unresolvable $ref %q: unknown anchor", ref)
+	}
+
+	node := resource
+	for _, token := range strings.Split(fragment[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch current := node.(type) {
+		case map[string]interface{}:
+			next, ok := current[token]
+			if !ok {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			node = next
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			node = current[index]
+		default:
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return node, nil
+}
+
+func (s *JSONSchema) compilePattern(pattern string) error {
+	if _, ok := s.patterns[pattern]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid schema: pattern %q: %w", pattern, err)
+	}
+	s.patterns[pattern] = re
+	return nil
+}
+
+// resolveSchemaURI resolves ref against base and drops the fragment
+func resolveSchemaURI(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URI %q: %w", base, err)
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid URI %q: %w", ref, err)
+	}
+	u := baseURL.ResolveReference(refURL)
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// schemaEvaluation records which properties and items of an instance some
+// subschema evaluated, for unevaluatedProperties and unevaluatedItems
+type schemaEvaluation struct {
+	props    map[string]bool
+	items    int // items before this index were evaluated
+	allItems bool
+}
+
+func (e *schemaEvaluation) merge(other schemaEvaluation) {
+	for name := range other.props {
+		if e.props == nil {
+			e.props = make(map[string]bool)
+		}
+		e.props[name] = true
+	}
+	if other.items > e.items {
+		e.items = other.items
+	}
+	e.allItems = e.allItems || other.allItems
+}
+
+func (e *schemaEvaluation) markProp(name string) {
+	if e.props == nil {
+		e.props = make(map[string]bool)
+	}
+	e.props[name] = true
+}
+
+type schemaValidator struct {
+	schema  *JSONSchema
+	content []byte
+	offsets map[string]int64
+	depth   int
+}
+
+// fail builds an error for the value at ptr
+func (v *schemaValidator) fail(ptr, keyword string, instance interface{}, format string, args ...interface{}) ValidationError {
+	line, col := getLineColumn(v.content, v.offsets[ptr])
+	value, _ := json.Marshal(instance)
+	if len(value) > 80 {
+		value = append(value[:77], "..."...)
+	}
+	return ValidationError{
+		Line:    line,
+		Column:  col,
+		Message: fmt.Sprintf(format, args...),
+		Code:    schemaErrorCode(keyword),
+		Value:   string(value),
+		Path:    ptr,
+	}
+}
+
+// validate applies a schema to the instance at ptr
+func (v *schemaValidator) validate(node, instance interface{}, ptr string) ([]ValidationError, schemaEvaluation) {
+	var eval schemaEvaluation
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		if allowed, _ := node.(bool); !allowed {
+			return []ValidationError{v.fail(ptr, "false", instance, "%s is not allowed", describePointer(ptr))}, eval
+		}
+		return nil, eval
+	}
+
+	v.depth++
+	defer func() { v.depth-- }()
+	if v.depth > jsonSchemaMaxDepth {
+		return []ValidationError{v.fail(ptr, "$ref", instance, "schema references nest too deeply at %s", describePointer(ptr))}, eval
+	}
+
+	var errs []ValidationError
+
+	if target, ok := v.schema.refs[reflect.ValueOf(schema).Pointer()]; ok {
+		refErrs, refEval := v.validate(target, instance, ptr)
+		errs = append(errs, refErrs...)
+		eval.merge(refEval)
+	}
+
+	if t, ok := schema["type"]; ok {
+		var allowed []string
+		switch t := t.(type) {
+		case string:
+			allowed = []string{t}
+		case []interface{}:
+			for _, name := range t {
+				if s, ok := name.(string); ok {
+					allowed = append(allowed, s)
+				}
+			}
+		}
+		matched := false
+		for _, name := range allowed {
+			if jsonTypeMatches(instance, name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, v.fail(ptr, "type", instance, "expected %s, got %s", strings.Join(allowed, " or "), jsonTypeName(instance)))
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, instance) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, v.fail(ptr, "enum", instance, "value must be one of %s", compactJSON(enum)))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, instance) {
+		errs = append(errs, v.fail(ptr, "const", instance, "value must be %s", compactJSON(constant)))
+	}
+
+	switch value := instance.(type) {
+	case string:
+		errs = append(errs, v.validateString(schema, value, ptr)...)
+	case json.Number:
+		errs = append(errs, v.validateNumber(schema, value, ptr)...)
+	case map[string]interface{}:
+		objErrs, objEval := v.validateObject(schema, value, ptr)
+		errs = append(errs, objErrs...)
+		eval.merge(objEval)
+	case []interface{}:
+		arrErrs, arrEval := v.validateArray(schema, value, ptr)
+		errs = append(errs, arrErrs...)
+		eval.merge(arrEval)
+	}
+
+	if list, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range list {
+			subErrs, subEval := v.validate(sub, instance, ptr)
+			errs = append(errs, subErrs...)
+			eval.merge(subEval)
+		}
+	}
+	if list, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range list {
+			if subErrs, subEval := v.validate(sub, instance, ptr); len(subErrs) == 0 {
+				matched = true
+				eval.merge(subEval)
+			}
+		}
+		if !matched {
+			errs = append(errs, v.fail(ptr, "anyOf", instance, "%s does not match any schema in anyOf", describePointer(ptr)))
+		}
+	}
+	if list, ok := schema["oneOf"].([]interface{}); ok {
+		var matches []int
+		for i, sub := range list {
+			if subErrs, subEval := v.validate(sub, instance, ptr); len(subErrs) == 0 {
+				matches = append(matches, i)
+				eval.merge(subEval)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			errs = append(errs, v.fail(ptr, "oneOf", instance, "%s does not match any schema in oneOf", describePointer(ptr)))
+		case len(matches) > 1:
+			errs = append(errs, v.fail(ptr, "oneOf", instance, "%s matches schemas %v in oneOf, expected exactly one", describePointer(ptr), matches))
+		}
+	}
+	if sub, ok := schema["not"]; ok {
+		if subErrs, _ := v.validate(sub, instance, ptr); len(subErrs) == 0 {
+			errs = append(errs, v.fail(ptr, "not", instance, "%s must not match the schema in not", describePointer(ptr)))
+		}
+	}
+	if cond, ok := schema["if"]; ok {
+		condErrs, condEval := v.validate(cond, instance, ptr)
+		branch := "else"
+		if len(condErrs) == 0 {
+			eval.merge(condEval)
+			branch = "then"
+		}
+		if sub, ok := schema[branch]; ok {
+			subErrs, subEval := v.validate(sub, instance, ptr)
+			errs = append(errs, subErrs...)
+			eval.merge(subEval)
+		}
+	}
+
+	// unevaluated* see what every other keyword, including those in
+	// referenced and in-place subschemas, has evaluated
+	if sub, ok := schema["unevaluatedProperties"]; ok {
+		if obj, isObj := instance.(map[string]interface{}); isObj {
+			for _, name := range sortedJSONKeys(obj) {
+				if eval.props[name] {
+					continue
+				}
+				subErrs, _ := v.validate(sub, obj[name], ptr+"/"+escapeJSONPointer(name))
+				if len(subErrs) > 0 {
+					if allowed, isBool := sub.(bool); isBool && !allowed {
+						subErrs = []ValidationError{v.fail(ptr+"/"+escapeJSONPointer(name), "unevaluatedProperties", obj[name], "property %q is not allowed", name)}
+					}
+					errs = append(errs, subErrs...)
+				}
+				eval.markProp(name)
+			}
+		}
+	}
+	if sub, ok := schema["unevaluatedItems"]; ok {
+		if arr, isArr := instance.([]interface{}); isArr && !eval.allItems {
+			for i := eval.items; i < len(arr); i++ {
+				itemPtr := ptr + "/" + strconv.Itoa(i)
+				subErrs, _ := v.validate(sub, arr[i], itemPtr)
+				if len(subErrs) > 0 {
+					if allowed, isBool := sub.(bool); isBool && !allowed {
+						subErrs = []ValidationError{v.fail(itemPtr, "unevaluatedItems", arr[i], "item %d is not allowed", i)}
+					}
+					errs = append(errs, subErrs...)
+				}
+			}
+			eval.allItems = true
+		}
+	}
+
+	return errs, eval
+}
+
+func (v *schemaValidator) validateString(schema map[string]interface{}, value, ptr string) []ValidationError {
+	var errs []ValidationError
+	length := utf8.RuneCountInString(value)
+
+	if limit, ok := schemaInt(schema["minLength"]); ok && length < limit {
+		errs = append(errs, v.fail(ptr, "minLength", value, "string must be at least %d characters, got %d", limit, length))
+	}
+	if limit, ok := schemaInt(schema["maxLength"]); ok && length > limit {
+		errs = append(errs, v.fail(ptr, "maxLength", value, "string must be at most %d characters, got %d", limit, length))
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if re := v.schema.patterns[pattern]; re != nil && !re.MatchString(value) {
+			errs = append(errs, v.fail(ptr, "pattern", value, "string does not match pattern %q", pattern))
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !validJSONFormat(format, value) {
+		errs = append(errs, v.fail(ptr, "format", value, "string is not a valid %s", format))
+	}
+	return errs
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]interface{}, value json.Number, ptr string) []ValidationError {
+	var errs []ValidationError
+	n, ok := new(big.Rat).SetString(value.String())
+	if !ok {
+		return errs
+	}
+
+	bounds := []struct {
+		keyword string
+		fails   func(cmp int) bool
+		message string
+	}{
+		{"minimum", func(cmp int) bool { return cmp < 0 }, "value must be at least %s"},
+		{"exclusiveMinimum", func(cmp int) bool { return cmp <= 0 }, "value must be greater than %s"},
+		{"maximum", func(cmp int) bool { return cmp > 0 }, "value must be at most %s"},
+		{"exclusiveMaximum", func(cmp int) bool { return cmp >= 0 }, "value must be less than %s"},
+	}
+	for _, b := range bounds {
+		limit, ok := schemaRat(schema[b.keyword])
+		if ok && b.fails(n.Cmp(limit)) {
+			errs = append(errs, v.fail(ptr, b.keyword, value, b.message, schema[b.keyword]))
+		}
+	}
+	if divisor, ok := schemaRat(schema["multipleOf"]); ok && divisor.Sign() > 0 {
+		if !new(big.Rat).Quo(n, divisor).IsInt() {
+			errs = append(errs, v.fail(ptr, "multipleOf", value, "value must be a multiple of %s", schema["multipleOf"]))
+		}
+	}
+	return errs
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, obj map[string]interface{}, ptr string) ([]ValidationError, schemaEvaluation) {
+	var errs []ValidationError
+	var eval schemaEvaluation
+	keys := sortedJSONKeys(obj)
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if s, ok := name.(string); ok {
+				if _, present := obj[s]; !present {
+					errs = append(errs, v.fail(ptr, "required", obj, "missing required property %q", s))
+				}
+			}
+		}
+	}
+	if deps, ok := schema["dependentRequired"].(map[string]interface{}); ok {
+		for _, trigger := range sortedJSONKeys(deps) {
+			if _, present := obj[trigger]; !present {
+				continue
+			}
+			names, _ := deps[trigger].([]interface{})
+			for _, name := range names {
+				if s, ok := name.(string); ok {
+					if _, present := obj[s]; !present {
+						errs = append(errs, v.fail(ptr, "dependentRequired", obj, "property %q is required when %q is present", s, trigger))
+					}
+				}
+			}
+		}
+	}
+	if limit, ok := schemaInt(schema["minProperties"]); ok && len(obj) < limit {
+		errs = append(errs, v.fail(ptr, "minProperties", obj, "object must have at least %d properties, got %d", limit, len(obj)))
+	}
+	if limit, ok := schemaInt(schema["maxProperties"]); ok && len(obj) > limit {
+		errs = append(errs, v.fail(ptr, "maxProperties", obj, "object must have at most %d properties, got %d", limit, len(obj)))
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	patternProps, _ := schema["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+	names, hasNames := schema["propertyNames"]
+
+	for _, name := range keys {
+		childPtr := ptr + "/" + escapeJSONPointer(name)
+		value := obj[name]
+		matched := false
+
+		if hasNames {
+			if nameErrs, _ := v.validate(names, name, ptr); len(nameErrs) > 0 {
+				errs = append(errs, v.fail(childPtr, "propertyNames", value, "property name %q is not allowed", name))
+			}
+		}
+		if sub, ok := properties[name]; ok {
+			matched = true
+			subErrs, _ := v.validate(sub, value, childPtr)
+			errs = append(errs, subErrs...)
+		}
+		for _, pattern := range sortedJSONKeys(patternProps) {
+			if re := v.schema.patterns[pattern]; re != nil && re.MatchString(name) {
+				matched = true
+				subErrs, _ := v.validate(patternProps[pattern], value, childPtr)
+				errs = append(errs, subErrs...)
+			}
+		}
+		if !matched && hasAdditional {
+			matched = true
+			if allowed, isBool := additional.(bool); isBool && !allowed {
+				errs = append(errs, v.fail(childPtr, "additionalProperties", value, "property %q is not allowed", name))
+			} else {
+				subErrs, _ := v.validate(additional, value, childPtr)
+				errs = append(errs, subErrs...)
+			}
+		}
+		if matched {
+			eval.markProp(name)
+		}
+	}
+
+	if deps, ok := schema["dependentSchemas"].(map[string]interface{}); ok {
+		for _, trigger := range sortedJSONKeys(deps) {
+			if _, present := obj[trigger]; present {
+				subErrs, subEval := v.validate(deps[trigger], obj, ptr)
+				errs = append(errs, subErrs...)
+				eval.merge(subEval)
+			}
+		}
+	}
+	return errs, eval
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, arr []interface{}, ptr string) ([]ValidationError, schemaEvaluation) {
+	var errs []ValidationError
+	var eval schemaEvaluation
+
+	if limit, ok := schemaInt(schema["minItems"]); ok && len(arr) < limit {
+		errs = append(errs, v.fail(ptr, "minItems", arr, "array must have at least %d items, got %d", limit, len(arr)))
+	}
+	if limit, ok := schemaInt(schema["maxItems"]); ok && len(arr) > limit {
+		errs = append(errs, v.fail(ptr, "maxItems", arr, "array must have at most %d items, got %d", limit, len(arr)))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+	outer:
+		for i := 1; i < len(arr); i++ {
+			for j := 0; j < i; j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					errs = append(errs, v.fail(ptr+"/"+strconv.Itoa(i), "uniqueItems", arr[i], "item %d duplicates item %d", i, j))
+					break outer
+				}
+			}
+		}
+	}
+
+	// Draft-07 array-form items behaves like prefixItems with
+	// additionalItems for the rest
+	prefix, _ := schema["prefixItems"].([]interface{})
+	rest, hasRest := schema["items"]
+	if list, ok := rest.([]interface{}); ok {
+		prefix = list
+		rest, hasRest = schema["additionalItems"]
+	}
+
+	for i, item := range arr {
+		itemPtr := ptr + "/" + strconv.Itoa(i)
+		var sub interface{}
+		switch {
+		case i < len(prefix):
+			sub = prefix[i]
+			eval.items = i + 1
+		case hasRest:
+			sub = rest
+			eval.allItems = true
+		default:
+			continue
+		}
+		if allowed, isBool := sub.(bool); isBool && !allowed {
+			errs = append(errs, v.fail(itemPtr, "items", item, "item %d is not allowed", i))
+			continue
+		}
+		subErrs, _ := v.validate(sub, item, itemPtr)
+		errs = append(errs, subErrs...)
+	}
+
+	if contains, ok := schema["contains"]; ok {
+		count := 0
+		for _, item := range arr {
+			if subErrs, _ := v.validate(contains, item, ptr); len(subErrs) == 0 {
+				count++
+			}
+		}
+		minimum := 1
+		if limit, ok := schemaInt(schema["minContains"]); ok {
+			minimum = limit
+		}
+		if count < minimum {
+			errs = append(errs, v.fail(ptr, "contains", arr, "array must contain at least %d matching items, found %d", minimum, count))
+		}
+		if limit, ok := schemaInt(schema["maxContains"]); ok && count > limit {
+			errs = append(errs, v.fail(ptr, "maxContains", arr, "array must contain at most %d matching items, found %d", limit, count))
+		}
+	}
+	return errs, eval
+}
+
+// schemaErrorCode turns a keyword into an error code: additionalProperties
+// becomes ADDITIONAL_PROPERTIES
+func schemaErrorCode(keyword string) string {
+	if keyword == "false" {
+		return "FALSE_SCHEMA"
+	}
+	var b strings.Builder
+	for i, r := range strings.TrimPrefix(keyword, "$") {
+		if r >= 'A' && r <= 'Z' && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToUpper(b.String())
+}
+
+func describePointer(ptr string) string {
+	if ptr == "" {
+		return "document"
+	}
+	return ptr
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func sortedJSONKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func schemaInt(value interface{}) (int, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func schemaRat(value interface{}) (*big.Rat, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(n.String())
+}
+
+func compactJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// jsonTypeName names the JSON type of a value decoded with UseNumber
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if jsonTypeMatches(v, "integer") {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// jsonTypeMatches reports whether value has the schema type name. As in
+// 2020-12, numbers with a zero fractional part such as 1.0 are integers.
+func jsonTypeMatches(value interface{}, name string) bool {
+	switch name {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		r, ok := new(big.Rat).SetString(n.String())
+		return ok && r.IsInt()
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	}
+	return jsonTypeName(value) == name
+}
+
+// jsonEqual compares decoded JSON values, numbers by value
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		rx, okx := new(big.Rat).SetString(x.String())
+		ry, oky := new(big.Rat).SetString(y.String())
+		return okx && oky && rx.Cmp(ry) == 0
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			other, ok := y[k]
+			if !ok || !jsonEqual(v, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+var (
+	hostnamePattern = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+	uuidPattern     = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	durationPattern = regexp.MustCompile(`^P(\d+W|(\d+Y)?(\d+M)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?)$`)
+)
+
+// validJSONFormat checks the formats JSON Schema defines. Unknown formats
+// are annotations only and always pass.
+func validJSONFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(value))
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05.999999999Z07:00", strings.ToUpper(value))
+		return err == nil
+	case "duration":
+		return durationPattern.MatchString(value) && !strings.HasSuffix(value, "T") && value != "P"
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case "hostname":
+		return len(value) <= 253 && hostnamePattern.MatchString(value)
+	case "ipv4":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	case "ipv6":
+		return net.ParseIP(value) != nil && strings.Contains(value, ":")
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != ""
+	case "uri-reference":
+		_, err := url.Parse(value)
+		return err == nil
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	case "regex":
+		_, err := regexp.Compile(value)
+		return err == nil
+	case "json-pointer":
+		if value == "" {
+			return true
+		}
+		if !strings.HasPrefix(value, "/") {
+			return false
+		}
+		for i := 0; i < len(value); i++ {
+			if value[i] == '~' && (i+1 >= len(value) || (value[i+1] != '0' && value[i+1] != '1')) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// decodeJSONNumbers decodes a single JSON value keeping numbers exact
+func decodeJSONNumbers(content []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// decodeJSONWithOffsets decodes valid JSON and records the byte offset at
+// which each value starts, keyed by JSON Pointer
+func decodeJSONWithOffsets(content []byte) (interface{}, map[string]int64, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	offsets := make(map[string]int64)
+
+	// InputOffset points just past the previous token; the value starts
+	// after any whitespace and separator
+	start := func() int64 {
+		off := dec.InputOffset()
+		for off < int64(len(content)) {
+			switch content[off] {
+			case ' ', '\t', '\r', '\n', ':', ',':
+				off++
+				continue
+			}
+			break
+		}
+		return off
+	}
+
+	var value func(ptr string) (interface{}, error)
+	value = func(ptr string) (interface{}, error) {
+		offsets[ptr] = start()
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			return tok, nil
+		}
+
+		switch delim {
+		case '{':
+			obj := make(map[string]interface{})
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := keyTok.(string)
+				if obj[key], err = value(ptr + "/" + escapeJSONPointer(key)); err != nil {
+					return nil, err
+				}
+			}
+			_, err = dec.Token()
+			return obj, err
+		case '[':
+			arr := []interface{}{}
+			for i := 0; dec.More(); i++ {
+				item, err := value(ptr + "/" + strconv.Itoa(i))
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, item)
+			}
+			_, err = dec.Token()
+			return arr, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %v", delim)
+	}
+
+	root, err := value("")
+	return root, offsets, err
+}
+
+// InferJSONSchemaFromFiles infers a schema from sample JSON files
+func InferJSONSchemaFromFiles(paths ...string) (map[string]interface{}, error) {
+	samples := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, content)
+	}
+	return InferJSONSchema(samples...)
+}
+
+// InferJSONSchema infers a draft 2020-12 schema that every sample satisfies.
+// Types seen across samples are combined, object properties present in every
+// sample are required, array items share one schema, and string formats are
+// kept when every sample string has the same one.
+func InferJSONSchema(samples ...[]byte) (map[string]interface{}, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples to infer a schema from")
+	}
+	root := &inferredSchema{}
+	for i, sample := range samples {
+		value, err := decodeJSONNumbers(sample)
+		if err != nil {
+			return nil, fmt.Errorf("sample %d: %w", i+1, err)
+		}
+		root.add(value)
+	}
+
+	schema := root.schema()
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return schema, nil
+}
+
+// inferredSchema accumulates what samples at one location look like
+type inferredSchema struct {
+	types      map[string]bool
+	objects    int
+	properties map[string]*inferredSchema
+	seen       map[string]int // objects each property appeared in
+	items      *inferredSchema
+	strings    int
+	formats    map[string]int
+}
+
+// Formats InferJSONSchema recognizes, most specific first
+var inferredFormats = []string{"date-time", "date", "uuid", "email", "ipv4", "ipv6", "uri"}
+
+func (s *inferredSchema) add(value interface{}) {
+	if s.types == nil {
+		s.types = make(map[string]bool)
+	}
+	s.types[jsonTypeName(value)] = true
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if s.properties == nil {
+			s.properties = make(map[string]*inferredSchema)
+			s.seen = make(map[string]int)
+		}
+		s.objects++
+		for name, child := range v {
+			if s.properties[name] == nil {
+				s.properties[name] = &inferredSchema{}
+			}
+			s.properties[name].add(child)
+			s.seen[name]++
+		}
+	case []interface{}:
+		if s.items == nil {
+			s.items = &inferredSchema{}
+		}
+		for _, item := range v {
+			s.items.add(item)
+		}
+	case string:
+		s.strings++
+		for _, format := range inferredFormats {
+			if validJSONFormat(format, v) {
+				if s.formats == nil {
+					s.formats = make(map[string]int)
+				}
+				s.formats[format]++
+				break
+			}
+		}
+	}
+}
+
+func (s *inferredSchema) schema() map[string]interface{} {
+	schema := make(map[string]interface{})
+	if len(s.types) == 0 {
+		return schema
+	}
+
+	var types []string
+	for name := range s.types {
+		if name == "integer" && s.types["number"] {
+			continue
+		}
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	if len(types) == 1 {
+		schema["type"] = types[0]
+	} else {
+		schema["type"] = types
+	}
+
+	if s.properties != nil {
+		props := make(map[string]interface{})
+		var required []string
+		for name, child := range s.properties {
+			props[name] = child.schema()
+			if s.seen[name] == s.objects {
+				required = append(required, name)
+			}
+		}
+		schema["properties"] = props
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+	}
+	if s.items != nil && len(s.items.types) > 0 {
+		schema["items"] = s.items.schema()
+	}
+	for format, count := range s.formats {
+		if count == s.strings {
+			schema["format"] = format
+		}
+	}
+	return schema
+}
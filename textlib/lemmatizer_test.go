@@ -0,0 +1,54 @@
+package textlib
+
+import (
+	"testing"
+)
+
+func TestEnglishLemmatizer(t *testing.T) {
+	tests := []struct {
+		word     string
+		tag      string
+		expected string
+	}{
+		{"running", "VBG", "run"},
+		{"runs", "VBZ", "run"},
+		{"ran", "VBD", "run"},
+		{"went", "VBD", "go"},
+		{"was", "VBD", "be"},
+		{"making", "VBG", "make"},
+		{"created", "VBN", "create"},
+		{"agreed", "VBD", "agree"},
+		{"needed", "VBD", "need"},
+		{"studied", "VBD", "study"},
+		{"stopped", "VBD", "stop"},
+		{"requiring", "VBG", "require"},
+		{"visiting", "VBG", "visit"},
+		{"children", "NNS", "child"},
+		{"cities", "NNS", "city"},
+		{"boxes", "NNS", "box"},
+		{"buses", "NNS", "bus"},
+		{"buses", "", "bus"},
+		{"viruses", "NNS", "virus"},
+		{"gases", "NNS", "gas"},
+		{"houses", "NNS", "house"},
+		{"causes", "NNS", "cause"},
+		{"excuses", "NNS", "excuse"},
+		{"uses", "NNS", "use"},
+		{"accuses", "VBZ", "accuse"},
+		{"analysis", "NN", "analysis"},
+		{"leaves", "NNS", "leaf"},
+		{"leaves", "VBZ", "leave"},
+		{"better", "JJR", "good"},
+		{"bigger", "JJR", "big"},
+		{"happiest", "JJS", "happy"},
+		{"bring", "VB", "bring"},
+		{"processing", "", "process"},
+	}
+
+	lemmatizer := NewEnglishLemmatizer()
+	for _, tt := range tests {
+		if got := lemmatizer.Lemmatize(tt.word, tt.tag); got != tt.expected {
+			t.Errorf("Lemmatize(%q, %q): expected %q, got %q", tt.word, tt.tag, tt.expected, got)
+		}
+	}
+}
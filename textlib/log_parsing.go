@@ -0,0 +1,842 @@
+package textlib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogRecord is one parsed log event
+type LogRecord struct {
+	Line      int       // 1-based line where the record starts
+	Raw       string    // original text; joined lines are separated by "\n"
+	Format    string    // format that parsed the record, "plain" when none did
+	Timestamp time.Time // zero when the record has no timestamp
+	Level     string    // TRACE, DEBUG, INFO, WARN, ERROR or FATAL; empty when unknown
+	Message   string
+	Fields    map[string]string
+}
+
+// LogFormat parses a single line into a record
+type LogFormat struct {
+	Name  string
+	Parse func(line string) (LogRecord, bool)
+}
+
+// LogParseOptions controls log record iteration
+type LogParseOptions struct {
+	Format      string // format name; detected from the first lines when empty
+	DetectLines int    // lines sampled for detection (default 50)
+	Multiline   bool   // append indented lines such as stack traces to the previous record
+	MaxLineSize int    // longest accepted line in bytes (default 1 MiB)
+}
+
+func (o LogParseOptions) withDefaults() LogParseOptions {
+	if o.DetectLines <= 0 {
+		o.DetectLines = 50
+	}
+	if o.MaxLineSize <= 0 {
+		o.MaxLineSize = 1 << 20
+	}
+	return o
+}
+
+var (
+	logFormatMu      sync.RWMutex
+	customLogFormats []LogFormat
+	grokPatterns     = map[string]string{
+		"WORD":              `\b\w+\b`,
+		"NOTSPACE":          `\S+`,
+		"SPACE":             `\s*`,
+		"DATA":              `.*?`,
+		"GREEDYDATA":        `.*`,
+		"INT":               `[+-]?\d+`,
+		"POSINT":            `\d+`,
+		"NUMBER":            `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`,
+		"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+		"UUID":              `[0-9A-Fa-f]{8}-(?:[0-9A-Fa-f]{4}-){3}[0-9A-Fa-f]{12}`,
+		"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+		"IPV6":              `[0-9A-Fa-f]*:[0-9A-Fa-f:]*:[0-9A-Fa-f.:]*`,
+		"IP":                `%{IPV6}|%{IPV4}`,
+		"HOSTNAME":          `[0-9A-Za-z][0-9A-Za-z-]*(?:\.[0-9A-Za-z][0-9A-Za-z-]*)*\.?`,
+		"IPORHOST":          `%{IP}|%{HOSTNAME}`,
+		"USER":              `[a-zA-Z0-9._-]+`,
+		"USERNAME":          `[a-zA-Z0-9._-]+`,
+		"EMAILADDRESS":      `[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+`,
+		"PATH":              `(?:/[^\s]*)+`,
+		"URIPATHPARAM":      `/[^\s?#]*(?:\?[^\s#]*)?`,
+		"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+		"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|error|err|crit(?:ical)?|fatal|severe|panic|emerg|alert)`,
+		"TIMESTAMP_ISO8601": `\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2}(?:[.,]\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`,
+		"HTTPDATE":          `\d{2}/[A-Za-z]{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`,
+		"SYSLOGTIMESTAMP":   `[A-Z][a-z]{2} +\d{1,2} \d{2}:\d{2}:\d{2}`,
+	}
+)
+
+var (
+	grokNamePattern = regexp.MustCompile(`^[A-Z0-9_]+$`)
+	grokRefPattern  = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(?:int|float|string))?\}`)
+)
+
+// RegisterGrokPattern adds a named sub-pattern usable as %{NAME} in grok expressions
+func RegisterGrokPattern(name, pattern string) error {
+	if !grokNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid grok pattern name %q", name)
+	}
+	logFormatMu.Lock()
+	defer logFormatMu.Unlock()
+	expanded, err := expandGrok(pattern, nil, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := regexp.Compile(expanded); err != nil {
+		return fmt.Errorf("grok pattern %s: %w", name, err)
+	}
+	grokPatterns[name] = pattern
+	return nil
+}
+
+// RegisterLogFormat makes a format available by name. Registered formats
+// are tried before the built-in ones during detection.
+func RegisterLogFormat(format LogFormat) error {
+	if format.Name == "" || format.Parse == nil {
+		return fmt.Errorf("log format needs a name and a parse function")
+	}
+	logFormatMu.Lock()
+	defer logFormatMu.Unlock()
+	for i, f := range customLogFormats {
+		if f.Name == format.Name {
+			customLogFormats[i] = format
+			return nil
+		}
+	}
+	customLogFormats = append(customLogFormats, format)
+	return nil
+}
+
+// NewGrokFormat compiles a grok expression such as
+// "%{IP:client} %{WORD:method} %{GREEDYDATA:message}" into a format that
+// must match whole lines. Captures named timestamp, level and message (or
+// msg) fill the matching record fields. Type suffixes like %{NUMBER:n:int}
+// are accepted but values stay strings.
+func NewGrokFormat(name, expr string) (LogFormat, error) {
+	logFormatMu.RLock()
+	var fields []string
+	expanded, err := expandGrok(expr, &fields, 0)
+	logFormatMu.RUnlock()
+	if err != nil {
+		return LogFormat{}, err
+	}
+	re, err := regexp.Compile("^(?:" + expanded + ")$")
+	if err != nil {
+		return LogFormat{}, fmt.Errorf("grok expression: %w", err)
+	}
+
+	names := re.SubexpNames()
+	for i, n := range names {
+		if strings.HasPrefix(n, "grok") {
+			idx, _ := strconv.Atoi(n[4:])
+			names[i] = fields[idx]
+		}
+	}
+
+	return LogFormat{Name: name, Parse: func(line string) (LogRecord, bool) {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			return LogRecord{}, false
+		}
+		rec := LogRecord{Message: line, Fields: make(map[string]string)}
+		for i, n := range names {
+			if i == 0 || n == "" {
+				continue
+			}
+			if _, seen := rec.Fields[n]; !seen || m[i] != "" {
+				rec.Fields[n] = m[i]
+			}
+		}
+		fillLogRecord(&rec)
+		return rec, true
+	}}, nil
+}
+
+// expandGrok replaces %{NAME:field} references with regular expressions,
+// recording field names in order when fields is non-nil. Callers hold logFormatMu.
+func expandGrok(expr string, fields *[]string, depth int) (string, error) {
+	if depth > 16 {
+		return "", fmt.Errorf("grok patterns nest too deeply")
+	}
+	var err error
+	out := grokRefPattern.ReplaceAllStringFunc(expr, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		m := grokRefPattern.FindStringSubmatch(ref)
+		sub, ok := grokPatterns[m[1]]
+		if !ok {
+			err = fmt.Errorf("unknown grok pattern %q", m[1])
+			return ""
+		}
+		inner, e := expandGrok(sub, nil, depth+1)
+		if e != nil {
+			err = e
+			return ""
+		}
+		if m[2] == "" || fields == nil {
+			return "(?:" + inner + ")"
+		}
+		*fields = append(*fields, m[2])
+		return fmt.Sprintf("(?P<grok%d>%s)", len(*fields)-1, inner)
+	})
+	return out, err
+}
+
+// Keys consulted for the timestamp, level and message of structured records
+var (
+	logTimeKeys    = []string{"time", "timestamp", "ts", "@timestamp", "datetime", "date", "time_local"}
+	logLevelKeys   = []string{"level", "lvl", "severity", "loglevel", "log.level"}
+	logMessageKeys = []string{"msg", "message", "log", "event"}
+)
+
+// fillLogRecord sets Timestamp, Level and Message from well-known field names
+func fillLogRecord(rec *LogRecord) {
+	for _, k := range logTimeKeys {
+		if t, ok := parseLogTime(rec.Fields[k]); ok {
+			rec.Timestamp = t
+			break
+		}
+	}
+	for _, k := range logLevelKeys {
+		if level := normalizeLogLevel(rec.Fields[k]); level != "" {
+			rec.Level = level
+			break
+		}
+	}
+	for _, k := range logMessageKeys {
+		if v, ok := rec.Fields[k]; ok {
+			rec.Message = v
+			break
+		}
+	}
+}
+
+var logTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006/01/02 15:04:05.999999999",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.ANSIC,
+}
+
+// parseLogTime parses the timestamp layouts and epoch numbers common in logs
+func parseLogTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 8 {
+		return time.Time{}, false
+	}
+	// Java and Python loggers separate milliseconds with a comma
+	if len(s) > 20 && s[19] == ',' {
+		s = s[:19] + "." + s[20:]
+	}
+	for _, layout := range logTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range []string{"Jan _2 15:04:05.999999", "Jan _2 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return withSyslogYear(t), true
+		}
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f > 0 {
+		switch {
+		case f < 1e11:
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+		case f < 1e14:
+			return time.UnixMilli(int64(f)).UTC(), true
+		case f < 1e17:
+			return time.UnixMicro(int64(f)).UTC(), true
+		default:
+			return time.Unix(0, int64(f)).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// withSyslogYear gives a yearless syslog timestamp the most recent year
+// that does not put it in the future
+func withSyslogYear(t time.Time) time.Time {
+	now := time.Now()
+	t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// normalizeLogLevel maps level names, slog offsets like "INFO+2" and
+// pino/bunyan numbers to TRACE, DEBUG, INFO, WARN, ERROR or FATAL
+func normalizeLogLevel(level string) string {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if i := strings.IndexAny(level, "+-"); i > 0 {
+		level = level[:i]
+	}
+	switch level {
+	case "trace", "10":
+		return "TRACE"
+	case "debug", "dbug", "20":
+		return "DEBUG"
+	case "info", "information", "informational", "notice", "30":
+		return "INFO"
+	case "warn", "warning", "40":
+		return "WARN"
+	case "error", "err", "eror", "severe", "50":
+		return "ERROR"
+	case "fatal", "crit", "critical", "panic", "emerg", "emergency", "alert", "60":
+		return "FATAL"
+	}
+	return ""
+}
+
+// syslogSeverityLevels maps syslog severities 0-7 to levels
+var syslogSeverityLevels = [8]string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+
+// builtinLogFormats lists the formats tried by detection, most specific first
+var builtinLogFormats = []LogFormat{
+	{Name: "cri", Parse: parseCRILog},
+	{Name: "slog_json", Parse: parseSlogJSONLog},
+	{Name: "json", Parse: parseJSONLog},
+	{Name: "nginx_combined", Parse: parseCombinedLog},
+	{Name: "apache_common", Parse: parseCommonLog},
+	{Name: "syslog_rfc5424", Parse: parseSyslog5424},
+	{Name: "syslog", Parse: parseSyslog3164},
+	{Name: "slog_text", Parse: parseSlogTextLog},
+	{Name: "logfmt", Parse: parseLogfmtLog},
+}
+
+// logFormats returns the registered formats followed by the built-ins
+func logFormats() []LogFormat {
+	logFormatMu.RLock()
+	defer logFormatMu.RUnlock()
+	formats := make([]LogFormat, 0, len(customLogFormats)+len(builtinLogFormats))
+	formats = append(formats, customLogFormats...)
+	return append(formats, builtinLogFormats...)
+}
+
+// findLogFormat looks a format up by name
+func findLogFormat(name string) (LogFormat, bool) {
+	if name == "plain" {
+		return LogFormat{Name: "plain", Parse: func(line string) (LogRecord, bool) { return parsePlainLog(line), true }}, true
+	}
+	for _, f := range logFormats() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return LogFormat{}, false
+}
+
+// DetectLogFormat names the format that parses most of the sample lines,
+// or "plain" when no format parses at least half of them
+func DetectLogFormat(lines []string) string {
+	return detectLogRecordFormat(lines).Name
+}
+
+func detectLogRecordFormat(lines []string) LogFormat {
+	var sample []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			sample = append(sample, line)
+		}
+	}
+	best, bestCount := LogFormat{}, 0
+	for _, f := range logFormats() {
+		count := 0
+		for _, line := range sample {
+			if _, ok := f.Parse(line); ok {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = f, count
+		}
+	}
+	if bestCount == 0 || bestCount*2 < len(sample) {
+		best, _ = findLogFormat("plain")
+	}
+	return best
+}
+
+var criLogPattern = regexp.MustCompile(`^(\S+) (stdout|stderr) ([PF])(?: (.*))?$`)
+
+// parseCRILog parses the Kubernetes container runtime format:
+// "2024-01-02T15:04:05.123456789Z stdout F message"
+func parseCRILog(line string) (LogRecord, bool) {
+	m := criLogPattern.FindStringSubmatch(line)
+	if m == nil {
+		return LogRecord{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, m[1])
+	if err != nil {
+		return LogRecord{}, false
+	}
+	inner := parsePlainLog(m[4])
+	return LogRecord{
+		Timestamp: t,
+		Level:     inner.Level,
+		Message:   m[4],
+		Fields:    map[string]string{"time": m[1], "stream": m[2], "tag": m[3]},
+	}, true
+}
+
+// parseJSONLog parses one JSON object per line; nested values keep their JSON text
+func parseJSONLog(line string) (LogRecord, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return LogRecord{}, false
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(trimmed), &obj); err != nil {
+		return LogRecord{}, false
+	}
+	rec := LogRecord{Message: line, Fields: make(map[string]string, len(obj))}
+	for k, raw := range obj {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			rec.Fields[k] = s
+		} else {
+			rec.Fields[k] = string(raw)
+		}
+	}
+	fillLogRecord(&rec)
+	return rec, true
+}
+
+// parseSlogJSONLog parses the output of log/slog's JSONHandler
+func parseSlogJSONLog(line string) (LogRecord, bool) {
+	rec, ok := parseJSONLog(line)
+	if !ok || !hasLogKeys(rec.Fields, "time", "level", "msg") {
+		return LogRecord{}, false
+	}
+	return rec, true
+}
+
+// parseLogfmtLog parses key=value lines
+func parseLogfmtLog(line string) (LogRecord, bool) {
+	fields, ok := parseLogfmt(line)
+	if !ok {
+		return LogRecord{}, false
+	}
+	rec := LogRecord{Message: line, Fields: fields}
+	fillLogRecord(&rec)
+	return rec, true
+}
+
+// parseSlogTextLog parses the output of log/slog's TextHandler
+func parseSlogTextLog(line string) (LogRecord, bool) {
+	rec, ok := parseLogfmtLog(line)
+	if !ok || !hasLogKeys(rec.Fields, "time", "level", "msg") {
+		return LogRecord{}, false
+	}
+	return rec, true
+}
+
+func hasLogKeys(fields map[string]string, keys ...string) bool {
+	for _, k := range keys {
+		if _, ok := fields[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLogfmt splits a line of key=value pairs, unquoting quoted values.
+// Every token must be a pair and there must be at least two.
+func parseLogfmt(line string) (map[string]string, bool) {
+	fields := make(map[string]string)
+	i := 0
+	for i < len(line) {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i == len(line) {
+			break
+		}
+		start := i
+		for i < len(line) && line[i] > ' ' && line[i] != '=' && line[i] != '"' {
+			i++
+		}
+		if i == start || i == len(line) || line[i] != '=' {
+			return nil, false
+		}
+		key := line[start:i]
+		i++
+
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, false
+			}
+			v, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				v = line[i+1 : end]
+			}
+			fields[key] = v
+			i = end + 1
+			if i < len(line) && line[i] != ' ' {
+				return nil, false
+			}
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			fields[key] = line[start:i]
+		}
+	}
+	return fields, len(fields) >= 2
+}
+
+var (
+	combinedLogPattern = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-) "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"`)
+	commonLogPattern   = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-)$`)
+	accessLogFields    = []string{"remote_addr", "ident", "remote_user", "time_local", "request", "status", "body_bytes_sent", "http_referer", "http_user_agent"}
+)
+
+// parseCombinedLog parses the NCSA combined format used by nginx and Apache
+func parseCombinedLog(line string) (LogRecord, bool) {
+	return parseAccessLog(combinedLogPattern.FindStringSubmatch(line))
+}
+
+// parseCommonLog parses the NCSA common format
+func parseCommonLog(line string) (LogRecord, bool) {
+	return parseAccessLog(commonLogPattern.FindStringSubmatch(line))
+}
+
+// parseAccessLog builds a record from an access log match. The level follows
+// the status: 5xx is ERROR, 4xx is WARN and anything else INFO.
+func parseAccessLog(m []string) (LogRecord, bool) {
+	if m == nil {
+		return LogRecord{}, false
+	}
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[4])
+	if err != nil {
+		return LogRecord{}, false
+	}
+	rec := LogRecord{Timestamp: t, Level: "INFO", Message: m[5] + " " + m[6], Fields: make(map[string]string)}
+	for i, v := range m[1:] {
+		rec.Fields[accessLogFields[i]] = v
+	}
+	if parts := strings.Fields(m[5]); len(parts) == 3 {
+		rec.Fields["method"], rec.Fields["path"], rec.Fields["protocol"] = parts[0], parts[1], parts[2]
+	}
+	switch m[6][0] {
+	case '5':
+		rec.Level = "ERROR"
+	case '4':
+		rec.Level = "WARN"
+	}
+	return rec, true
+}
+
+var (
+	syslog5424Pattern = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) (-|(?:\[(?:[^\]"\\]|\\.|"(?:[^"\\]|\\.)*")*\])+)(?: (.*))?$`)
+	syslog3164Pattern = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) ([^:\[\s]+)(?:\[(\d+)\])?: ?(.*)$`)
+)
+
+// parseSyslog5424 parses RFC 5424 syslog lines
+func parseSyslog5424(line string) (LogRecord, bool) {
+	m := syslog5424Pattern.FindStringSubmatch(line)
+	if m == nil {
+		return LogRecord{}, false
+	}
+	rec := LogRecord{
+		Message: strings.TrimPrefix(m[9], "\ufeff"),
+		Fields: map[string]string{
+			"timestamp": m[3], "hostname": m[4], "app_name": m[5],
+			"procid": m[6], "msgid": m[7], "structured_data": m[8],
+		},
+	}
+	if !setSyslogPriority(&rec, m[1]) {
+		return LogRecord{}, false
+	}
+	if m[3] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, m[3])
+		if err != nil {
+			return LogRecord{}, false
+		}
+		rec.Timestamp = t
+	}
+	return rec, true
+}
+
+// parseSyslog3164 parses BSD syslog lines; the timestamp has no year
+func parseSyslog3164(line string) (LogRecord, bool) {
+	m := syslog3164Pattern.FindStringSubmatch(line)
+	if m == nil {
+		return LogRecord{}, false
+	}
+	t, ok := parseLogTime(m[2])
+	if !ok {
+		return LogRecord{}, false
+	}
+	rec := LogRecord{
+		Timestamp: t,
+		Message:   m[6],
+		Fields:    map[string]string{"timestamp": m[2], "hostname": m[3], "app_name": m[4], "procid": m[5]},
+	}
+	if m[1] != "" {
+		if !setSyslogPriority(&rec, m[1]) {
+			return LogRecord{}, false
+		}
+	} else {
+		rec.Level = parsePlainLog(m[6]).Level
+	}
+	return rec, true
+}
+
+// setSyslogPriority records the facility and severity encoded in a PRI value
+func setSyslogPriority(rec *LogRecord, pri string) bool {
+	p, err := strconv.Atoi(pri)
+	if err != nil || p > 191 {
+		return false
+	}
+	rec.Fields["facility"] = strconv.Itoa(p / 8)
+	rec.Fields["severity"] = strconv.Itoa(p % 8)
+	rec.Level = syslogSeverityLevels[p%8]
+	return true
+}
+
+var (
+	plainLogTimePattern  = regexp.MustCompile(`^\[?(\d{4}[-/]\d{2}[-/]\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?)\]?\s*`)
+	plainLogLevelPattern = regexp.MustCompile(`^\[?((?i:trace|debug|info|notice|warn(?:ing)?|error|err|crit(?:ical)?|fatal|severe|panic))\]?:?\s+`)
+	plainLogLevelWord    = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|CRITICAL|FATAL|SEVERE|PANIC)\b`)
+)
+
+// parsePlainLog extracts a leading timestamp and level from free-form lines
+func parsePlainLog(line string) LogRecord {
+	rec := LogRecord{Message: line}
+	rest := line
+	if m := plainLogTimePattern.FindStringSubmatchIndex(rest); m != nil {
+		if t, ok := parseLogTime(rest[m[2]:m[3]]); ok {
+			rec.Timestamp = t
+			rest = rest[m[1]:]
+		}
+	}
+	if m := plainLogLevelPattern.FindStringSubmatchIndex(rest); m != nil {
+		rec.Level = normalizeLogLevel(rest[m[2]:m[3]])
+		rest = rest[m[1]:]
+	} else {
+		head := rest
+		if len(head) > 80 {
+			head = head[:80]
+		}
+		if m := plainLogLevelWord.FindString(head); m != "" {
+			rec.Level = normalizeLogLevel(m)
+		}
+	}
+	rec.Message = rest
+	return rec
+}
+
+// LogScanner reads log records one at a time, in the manner of bufio.Scanner
+type LogScanner struct {
+	opts    LogParseOptions
+	lines   *bufio.Scanner
+	format  LogFormat
+	started bool
+	queue   []logLine // lines read ahead for detection or continuation checks
+	lineNo  int
+	record  LogRecord
+	err     error
+}
+
+type logLine struct {
+	n    int
+	text string
+}
+
+// NewLogScanner returns a scanner over r. The format is detected from the
+// first DetectLines lines unless opts.Format names one.
+func NewLogScanner(r io.Reader, opts LogParseOptions) *LogScanner {
+	opts = opts.withDefaults()
+	lines := bufio.NewScanner(r)
+	initial := 64 * 1024
+	if opts.MaxLineSize < initial {
+		initial = opts.MaxLineSize
+	}
+	lines.Buffer(make([]byte, 0, initial), opts.MaxLineSize)
+	return &LogScanner{opts: opts, lines: lines}
+}
+
+// Scan advances to the next record, returning false at the end of input or on error
+func (s *LogScanner) Scan() bool {
+	if !s.started {
+		s.started = true
+		if !s.start() {
+			return false
+		}
+	}
+
+	for {
+		l, ok := s.next()
+		if !ok {
+			return false
+		}
+		if strings.TrimSpace(l.text) == "" {
+			continue
+		}
+		rec, ok := s.format.Parse(l.text)
+		if ok {
+			rec.Format = s.format.Name
+		} else {
+			rec = parsePlainLog(l.text)
+			rec.Format = "plain"
+		}
+		rec.Line, rec.Raw = l.n, l.text
+
+		// Container runtimes split long lines into partial (P) chunks ended by a full (F) one
+		for rec.Format == "cri" && rec.Fields["tag"] == "P" {
+			nl, ok := s.peek()
+			if !ok {
+				break
+			}
+			part, ok := parseCRILog(nl.text)
+			if !ok || part.Fields["stream"] != rec.Fields["stream"] {
+				break
+			}
+			s.next()
+			rec.Message += part.Message
+			rec.Raw += "\n" + nl.text
+			rec.Fields["tag"] = part.Fields["tag"]
+		}
+
+		if s.opts.Multiline {
+			for {
+				nl, ok := s.peek()
+				if !ok || !isLogContinuation(nl.text) {
+					break
+				}
+				s.next()
+				rec.Message += "\n" + nl.text
+				rec.Raw += "\n" + nl.text
+			}
+		}
+
+		s.record = rec
+		return true
+	}
+}
+
+// Record returns the record read by the last call to Scan
+func (s *LogScanner) Record() LogRecord {
+	return s.record
+}
+
+// Format returns the name of the format in use, once Scan has been called
+func (s *LogScanner) Format() string {
+	return s.format.Name
+}
+
+// Err returns the first read error, or nil at a clean end of input
+func (s *LogScanner) Err() error {
+	return s.err
+}
+
+// start resolves the format, reading the detection sample if needed
+func (s *LogScanner) start() bool {
+	if s.opts.Format != "" {
+		f, ok := findLogFormat(s.opts.Format)
+		if !ok {
+			s.err = fmt.Errorf("unknown log format %q", s.opts.Format)
+			return false
+		}
+		s.format = f
+		return true
+	}
+
+	for len(s.queue) < s.opts.DetectLines {
+		l, ok := s.read()
+		if !ok {
+			break
+		}
+		s.queue = append(s.queue, l)
+	}
+	sample := make([]string, len(s.queue))
+	for i, l := range s.queue {
+		sample[i] = l.text
+	}
+	s.format = detectLogRecordFormat(sample)
+	return s.err == nil
+}
+
+func (s *LogScanner) read() (logLine, bool) {
+	if !s.lines.Scan() {
+		if err := s.lines.Err(); err != nil && s.err == nil {
+			s.err = err
+		}
+		return logLine{}, false
+	}
+	s.lineNo++
+	return logLine{n: s.lineNo, text: strings.TrimRight(s.lines.Text(), "\r")}, true
+}
+
+func (s *LogScanner) next() (logLine, bool) {
+	if len(s.queue) > 0 {
+		l := s.queue[0]
+		s.queue = s.queue[1:]
+		return l, true
+	}
+	return s.read()
+}
+
+func (s *LogScanner) peek() (logLine, bool) {
+	if len(s.queue) > 0 {
+		return s.queue[0], true
+	}
+	l, ok := s.read()
+	if ok {
+		s.queue = append(s.queue, l)
+	}
+	return l, ok
+}
+
+// isLogContinuation reports whether a line continues the previous record,
+// as indented stack frames and Java "Caused by:" lines do
+func isLogContinuation(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "Caused by: ")
+}
+
+// ParseLogStream parses records from r as they arrive, closing the record
+// channel at the end of input. A read error is sent on the error channel.
+func ParseLogStream(ctx context.Context, r io.Reader, opts LogParseOptions) (<-chan LogRecord, <-chan error) {
+	return runStream(ctx, func(emit func(LogRecord) error) error {
+		s := NewLogScanner(r, opts)
+		for s.Scan() {
+			if err := emit(s.Record()); err != nil {
+				return err
+			}
+		}
+		return s.Err()
+	})
+}
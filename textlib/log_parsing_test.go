@@ -0,0 +1,253 @@
+package textlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFormats(t *testing.T) {
+	tests := []struct {
+		format  string
+		line    string
+		time    string // RFC 3339, empty for no timestamp
+		level   string
+		message string
+		fields  map[string]string
+	}{
+		{
+			"nginx_combined",
+			`203.0.113.9 - alice [10/Oct/2024:13:55:36 +0200] "GET /api/users/42 HTTP/1.1" 503 612 "https://example.com/" "curl/8.4.0"`,
+			"2024-10-10T13:55:36+02:00", "ERROR", "GET /api/users/42 HTTP/1.1 503",
+			map[string]string{"remote_addr": "203.0.113.9", "remote_user": "alice", "path": "/api/users/42", "http_user_agent": "curl/8.4.0"},
+		},
+		{
+			"apache_common",
+			`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /missing.gif HTTP/1.0" 404 -`,
+			"2000-10-10T13:55:36-07:00", "WARN", "GET /missing.gif HTTP/1.0 404",
+			map[string]string{"status": "404", "body_bytes_sent": "-", "method": "GET"},
+		},
+		{
+			"slog_json",
+			`{"time":"2024-05-01T10:00:00.5Z","level":"WARN+2","msg":"disk almost full","free":"2GB","pct":97.5}`,
+			"2024-05-01T10:00:00.5Z", "WARN", "disk almost full",
+			map[string]string{"free": "2GB", "pct": "97.5"},
+		},
+		{
+			"json",
+			`{"ts":1714557600.25,"severity":"error","message":"upstream timeout","ctx":{"id":7}}`,
+			"2024-05-01T10:00:00.25Z", "ERROR", "upstream timeout",
+			map[string]string{"ctx": `{"id":7}`},
+		},
+		{
+			"slog_text",
+			`time=2024-05-01T10:00:00.000+02:00 level=INFO msg="user logged in" user=ada attempts=3`,
+			"2024-05-01T10:00:00+02:00", "INFO", "user logged in",
+			map[string]string{"user": "ada", "attempts": "3"},
+		},
+		{
+			"logfmt",
+			`at=info method=GET path="/a b" status=200`,
+			"", "", `at=info method=GET path="/a b" status=200`,
+			map[string]string{"path": "/a b", "status": "200"},
+		},
+		{
+			"cri",
+			`2024-05-01T10:00:00.123456789Z stderr F 2024-05-01 10:00:00 ERROR connection refused`,
+			"2024-05-01T10:00:00.123456789Z", "ERROR", "2024-05-01 10:00:00 ERROR connection refused",
+			map[string]string{"stream": "stderr", "tag": "F"},
+		},
+		{
+			"syslog_rfc5424",
+			`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App"] An application event`,
+			"2003-10-11T22:14:15.003Z", "INFO", "An application event",
+			map[string]string{"hostname": "mymachine.example.com", "app_name": "evntslog", "facility": "20", "severity": "5"},
+		},
+		{
+			"syslog",
+			`<34>Oct  1 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8`,
+			"", "FATAL", "'su root' failed for lonvick on /dev/pts/8",
+			map[string]string{"hostname": "mymachine", "app_name": "su", "procid": "230"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := DetectLogFormat([]string{tt.line}); got != tt.format {
+				t.Errorf("Expected format %s, got %s", tt.format, got)
+			}
+			f, _ := findLogFormat(tt.format)
+			rec, ok := f.Parse(tt.line)
+			if !ok {
+				t.Fatalf("Expected %s to parse the line", tt.format)
+			}
+			if tt.time != "" {
+				want, _ := time.Parse(time.RFC3339Nano, tt.time)
+				if !rec.Timestamp.Equal(want) {
+					t.Errorf("Expected time %v, got %v", want, rec.Timestamp)
+				}
+			}
+			if rec.Level != tt.level || rec.Message != tt.message {
+				t.Errorf("Expected %s %q, got %s %q", tt.level, tt.message, rec.Level, rec.Message)
+			}
+			for k, v := range tt.fields {
+				if rec.Fields[k] != v {
+					t.Errorf("Expected field %s=%q, got %q", k, v, rec.Fields[k])
+				}
+			}
+		})
+	}
+}
+
+func TestParseLogTimeAndLevel(t *testing.T) {
+	times := map[string]string{
+		"2024-01-02 03:04:05,678":   "2024-01-02T03:04:05.678Z",
+		"2024/01/02 03:04:05":       "2024-01-02T03:04:05Z",
+		"1704164645000":             "2024-01-02T03:04:05Z",
+		"2024-01-02T03:04:05+01:00": "2024-01-02T02:04:05Z",
+	}
+	for in, want := range times {
+		got, ok := parseLogTime(in)
+		w, _ := time.Parse(time.RFC3339Nano, want)
+		if !ok || !got.Equal(w) {
+			t.Errorf("parseLogTime(%q) = %v, %v; want %s", in, got, ok, want)
+		}
+	}
+	if _, ok := parseLogTime("not a time"); ok {
+		t.Error("Expected an error for text")
+	}
+
+	levels := map[string]string{"warning": "WARN", "DEBUG-4": "DEBUG", "50": "ERROR", "crit": "FATAL", "verbose": ""}
+	for in, want := range levels {
+		if got := normalizeLogLevel(in); got != want {
+			t.Errorf("normalizeLogLevel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGrokFormat(t *testing.T) {
+	if err := RegisterGrokPattern("ORDERID", `ORD-\d+`); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewGrokFormat("orders", `%{TIMESTAMP_ISO8601:timestamp} \[%{LOGLEVEL:level}\] %{ORDERID:order} %{IP:client} took %{NUMBER:ms:float}ms: %{GREEDYDATA:message}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, ok := f.Parse("2024-03-01T12:00:00Z [warning] ORD-991 10.0.0.7 took 12.5ms: slow path taken")
+	if !ok {
+		t.Fatal("Expected the grok format to match")
+	}
+	if rec.Level != "WARN" || rec.Message != "slow path taken" || rec.Fields["order"] != "ORD-991" ||
+		rec.Fields["client"] != "10.0.0.7" || rec.Fields["ms"] != "12.5" || rec.Timestamp.Hour() != 12 {
+		t.Errorf("Unexpected record %+v", rec)
+	}
+	if _, ok := f.Parse("2024-03-01T12:00:00Z [warning] ORD-991 10.0.0.7 took fast"); ok {
+		t.Error("Expected a partial match to fail")
+	}
+
+	// Registered formats take part in detection ahead of the built-ins
+	if err := RegisterLogFormat(f); err != nil {
+		t.Fatal(err)
+	}
+	s := NewLogScanner(strings.NewReader("2024-03-01T12:00:01Z [error] ORD-1 ::1 took 3ms: failed\n"), LogParseOptions{})
+	if !s.Scan() || s.Format() != "orders" || s.Record().Fields["client"] != "::1" {
+		t.Errorf("Expected the registered format to be detected, got %s %+v", s.Format(), s.Record())
+	}
+
+	for _, expr := range []string{"%{NOPE:x}", "%{WORD:a} ("} {
+		if _, err := NewGrokFormat("bad", expr); err == nil {
+			t.Errorf("Expected %q to fail", expr)
+		}
+	}
+	if err := RegisterGrokPattern("lower", `x`); err == nil {
+		t.Error("Expected an invalid pattern name to be rejected")
+	}
+}
+
+func TestLogScanner(t *testing.T) {
+	t.Run("cri partial lines", func(t *testing.T) {
+		input := "2024-05-01T10:00:00Z stdout P first half \n" +
+			"2024-05-01T10:00:00Z stdout F and second half\n" +
+			"2024-05-01T10:00:01Z stdout F next\n"
+		s := NewLogScanner(strings.NewReader(input), LogParseOptions{})
+		var got []LogRecord
+		for s.Scan() {
+			got = append(got, s.Record())
+		}
+		if s.Err() != nil || len(got) != 2 {
+			t.Fatalf("Expected 2 records, got %d (%v)", len(got), s.Err())
+		}
+		if got[0].Message != "first half and second half" || got[1].Line != 3 || got[1].Message != "next" {
+			t.Errorf("Unexpected records %+v", got)
+		}
+	})
+
+	t.Run("multiline plain", func(t *testing.T) {
+		input := "2024-05-01 10:00:00,001 INFO starting\n" +
+			"2024-05-01 10:00:02,500 ERROR request failed\n" +
+			"java.lang.IllegalStateException: boom\n" +
+			"\tat com.example.Handler.run(Handler.java:42)\n" +
+			"Caused by: java.io.IOException: closed\n" +
+			"\n" +
+			"2024-05-01 10:00:03,000 WARN retrying\n"
+		s := NewLogScanner(strings.NewReader(input), LogParseOptions{Multiline: true})
+		var got []LogRecord
+		for s.Scan() {
+			got = append(got, s.Record())
+		}
+		if s.Format() != "plain" || len(got) != 4 {
+			t.Fatalf("Expected 4 plain records, got %d from %s", len(got), s.Format())
+		}
+		if got[1].Level != "ERROR" || got[1].Message != "request failed" || got[1].Timestamp.Second() != 2 {
+			t.Errorf("Unexpected record %+v", got[1])
+		}
+		// The exception line does not start with whitespace, so it is its own record
+		if got[2].Line != 3 || !strings.HasSuffix(got[2].Message, "Caused by: java.io.IOException: closed") {
+			t.Errorf("Expected the stack trace to join line 3, got %+v", got[2])
+		}
+		if got[3].Line != 7 || got[3].Level != "WARN" {
+			t.Errorf("Unexpected last record %+v", got[3])
+		}
+	})
+
+	t.Run("forced format", func(t *testing.T) {
+		s := NewLogScanner(strings.NewReader("a=1 b=2\nnot logfmt\n"), LogParseOptions{Format: "logfmt"})
+		var formats []string
+		for s.Scan() {
+			formats = append(formats, s.Record().Format)
+		}
+		if strings.Join(formats, ",") != "logfmt,plain" {
+			t.Errorf("Expected unparsable lines to fall back to plain, got %v", formats)
+		}
+
+		s = NewLogScanner(strings.NewReader("x"), LogParseOptions{Format: "nope"})
+		if s.Scan() || s.Err() == nil {
+			t.Error("Expected an unknown format to fail")
+		}
+	})
+
+	t.Run("line too long", func(t *testing.T) {
+		s := NewLogScanner(strings.NewReader(strings.Repeat("x", 100)), LogParseOptions{MaxLineSize: 10})
+		if s.Scan() || s.Err() == nil {
+			t.Error("Expected an over-long line to fail")
+		}
+	})
+}
+
+func TestParseLogStream(t *testing.T) {
+	input := `{"time":"2024-05-01T10:00:00Z","level":"INFO","msg":"a"}` + "\n" +
+		`{"time":"2024-05-01T10:00:01Z","level":"ERROR","msg":"b"}` + "\n"
+	records, errc := ParseLogStream(context.Background(), strings.NewReader(input), LogParseOptions{})
+	var levels []string
+	for rec := range records {
+		levels = append(levels, rec.Level)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(levels, ",") != "INFO,ERROR" {
+		t.Errorf("Unexpected levels %v", levels)
+	}
+}
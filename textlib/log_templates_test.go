@@ -0,0 +1,110 @@
+package textlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateMiner(t *testing.T) {
+	m := NewTemplateMiner(LogTemplateOptions{})
+
+	tests := []struct {
+		message  string
+		id       int
+		template string
+		isNew    bool
+	}{
+		{"Connection from 10.0.0.1:5432 closed after 12ms", 1, "Connection from <*> closed after <*>", true},
+		{"Connection from 10.0.0.2:6000 closed after 340ms", 1, "Connection from <*> closed after <*>", false},
+		{"login succeeded for ada", 2, "login succeeded for ada", true},
+		{"login succeeded for grace", 2, "login succeeded for <*>", false},
+		{"disk /dev/sda1 is 91% full", 3, "disk /dev/sda1 is <*> full", true},
+		{"request id=42 status=500", 4, "request id=<*> status=<*>", true},
+		{"request id=43 status=200", 4, "request id=<*> status=<*>", false},
+		{"job finished (took 1.5s).", 5, "job finished (took <*>).", true},
+		{"cache miss for key b7e151628aed2a6abf7158809cf4f3c7", 6, "cache miss for key <*>", true},
+		{"", 7, "", true},
+	}
+	for _, tt := range tests {
+		got := m.Add(tt.message)
+		if got.ID != tt.id || got.Template != tt.template || got.New != tt.isNew {
+			t.Errorf("Add(%q) = %+v, want %d %q new=%v", tt.message, got, tt.id, tt.template, tt.isNew)
+		}
+	}
+
+	// Unrelated messages of the same length stay apart
+	if got := m.Add("login database migration finished"); got.ID == 2 {
+		t.Errorf("Expected a new template, got %+v", got)
+	}
+}
+
+func TestTemplateMinerRanking(t *testing.T) {
+	m := NewTemplateMiner(LogTemplateOptions{})
+	for i := 0; i < 30; i++ {
+		level := "INFO"
+		if i%10 == 0 {
+			level = "ERROR"
+		}
+		m.AddRecord(LogRecord{Line: i + 1, Level: level, Message: fmt.Sprintf("served /item/%d in %dms", i, i*3)})
+		if i%3 == 0 {
+			m.AddRecord(LogRecord{Line: i + 1, Level: "WARN", Message: fmt.Sprintf("retrying job %d attempt %d", i, i%4)})
+		}
+	}
+	m.Add("shutting down")
+
+	got := m.Templates()
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 templates, got %+v", got)
+	}
+	if got[0].Template != "served <*> in <*>" || got[0].Count != 30 || got[0].Levels["ERROR"] != 3 || got[0].FirstLine != 1 || got[0].LastLine != 30 {
+		t.Errorf("Unexpected top template %+v", got[0])
+	}
+	if got[1].Template != "retrying job <*> attempt <*>" || got[1].Count != 10 || got[1].Example != "retrying job 0 attempt 0" {
+		t.Errorf("Unexpected second template %+v", got[1])
+	}
+	if got[2].Count != 1 || got[2].ID != 3 {
+		t.Errorf("Unexpected last template %+v", got[2])
+	}
+}
+
+func TestTemplateMinerMaxChildren(t *testing.T) {
+	m := NewTemplateMiner(LogTemplateOptions{MaxChildren: 2, Similarity: 0.9})
+	for _, w := range []string{"alpha", "beta", "gamma", "delta"} {
+		m.Add(w + " started")
+	}
+	root := m.root[2]
+	if len(root.children) != 3 || root.children[drainWildcard] == nil {
+		t.Errorf("Expected overflow tokens to share the wildcard branch, got %d children", len(root.children))
+	}
+	if len(m.Templates()) != 4 {
+		t.Errorf("Expected dissimilar messages to keep separate templates, got %+v", m.Templates())
+	}
+}
+
+func TestMineLogTemplatesFile(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "time=2024-05-01T10:00:%02dZ level=INFO msg=\"order %d shipped\"\n", i, 1000+i)
+	}
+	b.WriteString("time=2024-05-01T10:00:30Z level=ERROR msg=\"payment gateway timeout\"\n")
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := MineLogTemplatesFile(path, LogParseOptions{}, LogTemplateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Template != "order <*> shipped" || got[0].Count != 20 ||
+		got[0].FirstSeen.Second() != 0 || got[0].LastSeen.Second() != 19 || got[1].Levels["ERROR"] != 1 {
+		t.Errorf("Unexpected templates %+v", got)
+	}
+
+	if _, err := MineLogTemplatesFile(filepath.Join(t.TempDir(), "missing.log"), LogParseOptions{}, LogTemplateOptions{}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,102 @@
+package textlib
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeLogTimeline(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var records []LogRecord
+	add := func(offset time.Duration, level, message string) {
+		records = append(records, LogRecord{Line: len(records) + 1, Timestamp: t0.Add(offset), Level: level, Message: message})
+	}
+
+	for i := 0; i < 360; i++ {
+		offset := time.Duration(i) * 10 * time.Second
+		if offset >= 45*time.Minute && offset < 52*time.Minute {
+			continue // logging stopped
+		}
+		add(offset, "INFO", fmt.Sprintf("served /item/%d in %dms", i, i%40))
+		if i%60 == 30 {
+			add(offset, "ERROR", fmt.Sprintf("cache refresh failed for shard %d", i))
+		}
+		if offset >= 30*time.Minute && offset < 32*time.Minute && i%2 == 0 {
+			for k := 0; k < 2; k++ {
+				add(offset+time.Duration(k)*time.Second, "ERROR", "db connection refused to 10.0.0.1:5432")
+			}
+		}
+	}
+	records = append(records, LogRecord{Line: len(records) + 1, Message: "no timestamp"})
+
+	timeline := AnalyzeLogTimeline(records, LogTimelineOptions{Bucket: time.Minute})
+
+	if timeline.Records != len(records) || timeline.Untimed != 1 || len(timeline.Buckets) != 60 {
+		t.Fatalf("Unexpected timeline: %d records, %d untimed, %d buckets", timeline.Records, timeline.Untimed, len(timeline.Buckets))
+	}
+	if b := timeline.Buckets[30]; b.Count != 12 || b.Errors != 6 || b.Levels["INFO"] != 6 || b.NewTemplates != 1 {
+		t.Errorf("Unexpected burst bucket %+v", b)
+	}
+	if b := timeline.Buckets[47]; b.Count != 0 || b.ErrorRate != 0 {
+		t.Errorf("Expected an empty bucket during the gap, got %+v", b)
+	}
+
+	want := []struct {
+		typ        string
+		start, end time.Duration
+		context    string
+	}{
+		{"error_burst", 30 * time.Minute, 32 * time.Minute, "db connection refused to 10.0.0.1:5432"},
+		{"new_template", 30 * time.Minute, 31*time.Minute + 41*time.Second, "db connection refused to <*>"},
+		{"gap", 44*time.Minute + 50*time.Second, 52 * time.Minute, "served /item/269 in 29ms"},
+	}
+	if len(timeline.Anomalies) != len(want) {
+		t.Fatalf("Expected %d anomalies, got %+v", len(want), timeline.Anomalies)
+	}
+	for i, w := range want {
+		a := timeline.Anomalies[i]
+		if a.Type != w.typ || !a.Start.Equal(t0.Add(w.start)) || !a.End.Equal(t0.Add(w.end)) || a.Context != w.context {
+			t.Errorf("Anomaly %d: expected %s %v-%v %q, got %s %v-%v %q", i, w.typ, w.start, w.end, w.context,
+				a.Type, a.Start.Sub(t0), a.End.Sub(t0), a.Context)
+		}
+		if a.Severity <= 0 || a.Location.Start == 0 || a.Location.End < a.Location.Start {
+			t.Errorf("Anomaly %d: unexpected severity or location %+v", i, a.TextAnomaly)
+		}
+	}
+	if timeline.Anomalies[1].Severity != 3 {
+		t.Errorf("Expected an error template to have severity 3, got %v", timeline.Anomalies[1].Severity)
+	}
+}
+
+func TestAnalyzeLogTimelineEdges(t *testing.T) {
+	if tl := AnalyzeLogTimeline(nil, LogTimelineOptions{}); tl.Records != 0 || tl.Buckets != nil {
+		t.Errorf("Expected an empty timeline, got %+v", tl)
+	}
+
+	t0 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	// Out of order records are sorted before analysis
+	records := []LogRecord{
+		{Line: 1, Timestamp: t0.Add(2 * time.Second), Message: "started worker 2"},
+		{Line: 2, Timestamp: t0, Message: "started worker 1"},
+	}
+	tl := AnalyzeLogTimeline(records, LogTimelineOptions{})
+	if !tl.Start.Equal(t0) || tl.Bucket != time.Second || len(tl.Buckets) != 3 || len(tl.Anomalies) != 0 {
+		t.Errorf("Unexpected timeline %+v", tl)
+	}
+
+	widths := []struct {
+		span, requested, want time.Duration
+	}{
+		{30 * time.Second, 0, time.Second},
+		{time.Hour, 0, 5 * time.Minute},
+		{90 * 24 * time.Hour, 0, 24 * time.Hour},
+		{time.Hour, time.Millisecond, 36*time.Millisecond + 1},
+		{time.Hour, 10 * time.Minute, 10 * time.Minute},
+	}
+	for _, w := range widths {
+		if got := logBucketWidth(w.span, w.requested); got != w.want {
+			t.Errorf("logBucketWidth(%v, %v) = %v, want %v", w.span, w.requested, got, w.want)
+		}
+	}
+}
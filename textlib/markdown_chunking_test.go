@@ -0,0 +1,164 @@
+package textlib
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMarkdownDoc = `# Guide
+
+Intro paragraph for the guide.
+
+## Install
+
+Run the installer.
+
+` + "```go" + `
+func main() {
+	fmt.Println("hello")
+}
+` + "```" + `
+
+## Usage
+
+| Flag | Meaning |
+|------|---------|
+| -v   | verbose |
+
+- first item
+- second item
+  continued
+
+Setext Title
+------------
+
+Closing words.
+`
+
+func TestParseMarkdownBlocks(t *testing.T) {
+	blocks := ParseMarkdownBlocks(testMarkdownDoc)
+
+	var types []string
+	for _, b := range blocks {
+		types = append(types, b.Type)
+		if testMarkdownDoc[b.Start:b.End] != b.Text {
+			t.Errorf("Block %s offsets do not match its text", b.Type)
+		}
+	}
+	expected := "heading paragraph heading paragraph code heading table list heading paragraph"
+	if strings.Join(types, " ") != expected {
+		t.Fatalf("Expected blocks %q, got %q", expected, strings.Join(types, " "))
+	}
+
+	if blocks[4].Language != "go" {
+		t.Errorf("Expected code language go, got %q", blocks[4].Language)
+	}
+	if got := strings.Join(blocks[4].Headings, " > "); got != "Guide > Install" {
+		t.Errorf("Expected code block under Guide > Install, got %q", got)
+	}
+	if got := strings.Join(blocks[9].Headings, " > "); got != "Guide > Setext Title" {
+		t.Errorf("Expected level-2 setext heading to replace Usage, got %q", got)
+	}
+}
+
+func TestChunkTextMarkdown(t *testing.T) {
+	chunks := ChunkText(testMarkdownDoc, ChunkingStrategy{Method: "markdown", MaxTokens: 12})
+
+	if len(chunks) == 0 {
+		t.Fatal("Expected chunks")
+	}
+
+	for _, chunk := range chunks {
+		if strings.Count(chunk.Content, "```")%2 != 0 {
+			t.Errorf("Chunk %d splits a code fence: %q", chunk.Index, chunk.Content)
+		}
+		if strings.Contains(chunk.Content, "| -v") && !strings.Contains(chunk.Content, "| Flag") {
+			t.Errorf("Chunk %d splits a table: %q", chunk.Index, chunk.Content)
+		}
+		if _, ok := chunk.Metadata["breadcrumb"]; !ok {
+			t.Errorf("Chunk %d has no breadcrumb", chunk.Index)
+		}
+	}
+
+	var codeChunk *TextChunk
+	for i := range chunks {
+		if strings.Contains(chunks[i].Content, "func main") {
+			codeChunk = &chunks[i]
+		}
+	}
+	if codeChunk == nil {
+		t.Fatal("Expected a chunk with the code block")
+	}
+	if codeChunk.Metadata["breadcrumb"] != "Guide > Install" {
+		t.Errorf("Expected code chunk breadcrumb Guide > Install, got %v", codeChunk.Metadata["breadcrumb"])
+	}
+
+	// Headings start new chunks
+	for _, chunk := range chunks {
+		if strings.Contains(chunk.Content, "Run the installer") && strings.Contains(chunk.Content, "Intro paragraph") {
+			t.Error("Expected sections to be chunked separately")
+		}
+	}
+}
+
+func TestChunkTextMarkdownForcedSplit(t *testing.T) {
+	var code strings.Builder
+	code.WriteString("# Code\n\n```python\n")
+	for i := 0; i < 40; i++ {
+		code.WriteString("print('line number ")
+		code.WriteString(strings.Repeat("x", i%5))
+		code.WriteString("')\n")
+	}
+	code.WriteString("```\n")
+
+	// Kept whole without a character limit
+	chunks := ChunkText(code.String(), ChunkingStrategy{Method: "markdown", MaxTokens: 20})
+	if len(chunks) != 1 {
+		t.Errorf("Expected oversized code block to stay whole, got %d chunks", len(chunks))
+	}
+
+	// Split when it exceeds MaxCharacters, with every piece re-fenced
+	chunks = ChunkText(code.String(), ChunkingStrategy{Method: "markdown", MaxTokens: 20, MaxCharacters: 200})
+	if len(chunks) < 3 {
+		t.Fatalf("Expected forced split into several chunks, got %d", len(chunks))
+	}
+	for _, chunk := range chunks[1:] {
+		if !strings.HasPrefix(chunk.Content, "```python") || !strings.HasSuffix(chunk.Content, "```") {
+			t.Errorf("Forced piece is not a complete fence: %q", chunk.Content)
+		}
+		if chunk.Metadata["forced_split"] != true {
+			t.Error("Expected forced_split metadata")
+		}
+	}
+
+	// Pieces cover consecutive spans of the block, each holding its lines
+	doc := code.String()
+	if chunks[1].StartOffset != strings.Index(doc, "```python") || chunks[len(chunks)-1].EndOffset != len(doc)-1 {
+		t.Errorf("Expected pieces to span the block, got %d..%d", chunks[1].StartOffset, chunks[len(chunks)-1].EndOffset)
+	}
+	for i, chunk := range chunks[1:] {
+		if i > 0 && chunk.StartOffset != chunks[i].EndOffset+1 {
+			t.Errorf("Piece %d starts at %d, expected %d", i, chunk.StartOffset, chunks[i].EndOffset+1)
+		}
+		if source := doc[chunk.StartOffset:chunk.EndOffset]; !strings.Contains(chunk.Content, source) {
+			t.Errorf("Piece %d content %q lacks its source %q", i, chunk.Content, source)
+		}
+	}
+
+	// Longer fences are repeated in full, and shorter ones inside stay content
+	var long strings.Builder
+	long.WriteString("````markdown\n")
+	for i := 0; i < 20; i++ {
+		long.WriteString("```go\nfmt.Println(\"example\")\n```\n")
+	}
+	long.WriteString("````")
+	chunks = ChunkText(long.String(), ChunkingStrategy{Method: "markdown", MaxTokens: 20, MaxCharacters: 120})
+	if len(chunks) < 3 {
+		t.Fatalf("Expected forced split into several chunks, got %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if !strings.HasPrefix(chunk.Content, "````markdown\n") || !strings.HasSuffix(chunk.Content, "\n````") {
+			t.Errorf("Forced piece is not a complete fence: %q", chunk.Content)
+		}
+	}
+}
@@ -16,6 +16,7 @@ package textlib
 
 import (
 	"testing"
+	"time"
 )
 
 // Minimal tests to improve coverage on functions with 0% coverage
@@ -64,18 +65,20 @@ func TestReadableTextDetection(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		result := isReadableText(tt.content)
+		result := isReadableText(string(tt.content))
 		if result != tt.expected {
 			t.Errorf("isReadableText(%v) = %v, expected %v", tt.content, result, tt.expected)
 		}
 	}
 }
 
-func TestExtractID3FieldSimple(t *testing.T) {
-	data := []byte("some test data for ID3")
-	result := extractID3Field(data, 0, 5)
-	if len(result) != 5 {
-		t.Errorf("extractID3Field returned wrong length: %d", len(result))
+func TestParseID3v1Simple(t *testing.T) {
+	tag := make([]byte, 128)
+	copy(tag, "TAGHello")
+	var m AudioMetadata
+	parseID3v1(tag, &m)
+	if m.Title != "Hello" {
+		t.Errorf("parseID3v1 returned wrong title: %q", m.Title)
 	}
 }
 
@@ -113,25 +116,30 @@ func TestFileAnalysisFunctions(t *testing.T) {
 	_ = refs // Just ensure it doesn't panic
 
 	// Test GetFilesBySize
-	files := GetFilesBySize(".", 1000)
+	files, _ := GetFilesBySize(".", 1000)
 	_ = files // Just ensure it doesn't panic
 
 	// Test GetFilesByAge  
-	oldFiles := GetFilesByAge(".", 30)
+	oldFiles, _ := GetFilesByAge(".", 30*24*time.Hour)
 	_ = oldFiles // Just ensure it doesn't panic
 
 	// Test AnalyzeDiskUsage
-	usage := AnalyzeDiskUsage(".")
-	if usage.TotalSize < 0 {
-		t.Error("AnalyzeDiskUsage returned negative size")
+	usage, err := AnalyzeDiskUsage(".")
+	if err != nil {
+		t.Errorf("AnalyzeDiskUsage returned error: %v", err)
+	}
+	for _, size := range usage {
+		if size < 0 {
+			t.Error("AnalyzeDiskUsage returned negative size")
+		}
 	}
 
 	// Test isDirEmpty
-	empty := isDirEmpty(".")
+	empty, _ := isDirEmpty(".")
 	_ = empty // Just ensure it doesn't panic
 
 	// Test ExtractFileList
-	fileList := ExtractFileList("test.zip")
+	fileList, _ := ExtractFileList("test.zip")
 	_ = fileList // Just ensure it doesn't panic
 }
 
@@ -143,9 +151,7 @@ func TestVirusSignatures(t *testing.T) {
 }
 
 func TestPEPackingDetection(t *testing.T) {
-	// Test with fake PE data
-	data := []byte("MZ\x90\x00test data")
-	packed, ratio := checkPEPacking(data)
+	// Test with a section table too short to judge
+	packed := checkPEPacking([]Section{{Name: ".text", RawSize: 16}})
 	_ = packed
-	_ = ratio
 }
\ No newline at end of file
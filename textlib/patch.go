@@ -0,0 +1,786 @@
+package textlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiffOptions configures line diffs and unified diff output
+type DiffOptions struct {
+	Algorithm string // "myers" (default) or "patience"
+	Context   int    // Unchanged lines around each change (default 3, negative for none)
+	FromFile  string // Name in the "---" header (default "a")
+	ToFile    string // Name in the "+++" header (default "b")
+}
+
+// diffEdit is one step of an edit script: keep, delete or insert an element
+type diffEdit struct {
+	op   byte // '=', '-' or '+'
+	a, b int  // index in the old sequence (for '=' and '-') and the new one (for '=' and '+')
+}
+
+// diffUnits compares two sequences with Myers' O(ND) algorithm or, for
+// "patience", by anchoring on elements that occur once in each sequence
+func diffUnits(a, b []string, algorithm string) []diffEdit {
+	// Intern elements so comparisons are integer compares
+	ids := map[string]int{}
+	intern := func(units []string) []int {
+		out := make([]int, len(units))
+		for i, u := range units {
+			id, ok := ids[u]
+			if !ok {
+				id = len(ids)
+				ids[u] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	x, y := intern(a), intern(b)
+
+	if algorithm == "patience" {
+		return patienceDiff(x, y, 0, 0)
+	}
+	return myersDiffTrimmed(x, y, 0, 0)
+}
+
+// myersDiffTrimmed strips the common prefix and suffix before running Myers
+func myersDiffTrimmed(a, b []int, aOff, bOff int) []diffEdit {
+	var edits []diffEdit
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		edits = append(edits, diffEdit{'=', aOff + prefix, bOff + prefix})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, e := range myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		e.a += aOff + prefix
+		e.b += bOff + prefix
+		edits = append(edits, e)
+	}
+
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, diffEdit{'=', aOff + len(a) - i, bOff + len(b) - i})
+	}
+	return edits
+}
+
+func myersDiff(a, b []int) []diffEdit {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	// Greedy forward search, keeping the part of each round's frontier the
+	// backtrack can reach (diagonals -d-1 to d+1)
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	var edits []diffEdit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v, base := trace[d], d+1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[base+k-1] < v[base+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[base+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, diffEdit{'=', x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, diffEdit{'+', x, y - 1})
+			} else {
+				edits = append(edits, diffEdit{'-', x - 1, y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// patienceDiff matches elements unique to both sides in order, then diffs
+// the gaps between them recursively
+func patienceDiff(a, b []int, aOff, bOff int) []diffEdit {
+	countA, countB := map[int]int{}, map[int]int{}
+	posB := map[int]int{}
+	for _, v := range a {
+		countA[v]++
+	}
+	for j, v := range b {
+		countB[v]++
+		posB[v] = j
+	}
+
+	// Unique common elements in the order of a, with their index in b
+	type anchor struct{ i, j int }
+	var candidates []anchor
+	for i, v := range a {
+		if countA[v] == 1 && countB[v] == 1 {
+			candidates = append(candidates, anchor{i, posB[v]})
+		}
+	}
+	if len(candidates) == 0 {
+		return myersDiffTrimmed(a, b, aOff, bOff)
+	}
+
+	// Longest increasing subsequence of b indices (patience sorting)
+	var piles []int
+	back := make([]int, len(candidates))
+	for c, cand := range candidates {
+		lo, hi := 0, len(piles)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if candidates[piles[mid]].j < cand.j {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		back[c] = -1
+		if lo > 0 {
+			back[c] = piles[lo-1]
+		}
+		if lo == len(piles) {
+			piles = append(piles, c)
+		} else {
+			piles[lo] = c
+		}
+	}
+	anchors := make([]anchor, len(piles))
+	for c, i := piles[len(piles)-1], len(piles)-1; c >= 0; c, i = back[c], i-1 {
+		anchors[i] = candidates[c]
+	}
+
+	var edits []diffEdit
+	prevA, prevB := 0, 0
+	for _, an := range anchors {
+		edits = append(edits, patienceGap(a[prevA:an.i], b[prevB:an.j], aOff+prevA, bOff+prevB)...)
+		edits = append(edits, diffEdit{'=', aOff + an.i, bOff + an.j})
+		prevA, prevB = an.i+1, an.j+1
+	}
+	edits = append(edits, patienceGap(a[prevA:], b[prevB:], aOff+prevA, bOff+prevB)...)
+	return edits
+}
+
+func patienceGap(a, b []int, aOff, bOff int) []diffEdit {
+	if len(a) == 0 || len(b) == 0 {
+		return myersDiffTrimmed(a, b, aOff, bOff)
+	}
+	return patienceDiff(a, b, aOff, bOff)
+}
+
+// splitLinesKeepEnds splits text into lines that keep their "\n"
+func splitLinesKeepEnds(text string) []string {
+	var lines []string
+	for len(text) > 0 {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:i+1])
+		text = text[i+1:]
+	}
+	return lines
+}
+
+// LineDiff compares two texts line by line. Each operation covers a run of
+// whole lines; Position is the line index in text1 where it applies and
+// Length the number of lines.
+func LineDiff(text1, text2 string, algorithm string) []DiffOperation {
+	lines1, lines2 := splitLinesKeepEnds(text1), splitLinesKeepEnds(text2)
+	operations := []DiffOperation{}
+
+	opType := map[byte]string{'=': "equal", '-': "delete", '+': "insert"}
+	edits := diffUnits(lines1, lines2, algorithm)
+	for start := 0; start < len(edits); {
+		// Group a run of edits of the same kind into one operation
+		end := start + 1
+		for end < len(edits) && edits[end].op == edits[start].op {
+			end++
+		}
+
+		first, last := edits[start], edits[end-1]
+		var text string
+		if first.op == '+' {
+			text = strings.Join(lines2[first.b:last.b+1], "")
+		} else {
+			text = strings.Join(lines1[first.a:last.a+1], "")
+		}
+		operations = append(operations, DiffOperation{Type: opType[first.op], Text: text, Position: first.a, Length: end - start})
+		start = end
+	}
+
+	return operations
+}
+
+// UnifiedDiff renders the line differences between text1 and text2 in
+// unified diff format. Identical texts produce an empty string.
+func UnifiedDiff(text1, text2 string, opts DiffOptions) string {
+	context := opts.Context
+	if context == 0 {
+		context = 3
+	} else if context < 0 {
+		context = 0
+	}
+	from, to := opts.FromFile, opts.ToFile
+	if from == "" {
+		from = "a"
+	}
+	if to == "" {
+		to = "b"
+	}
+
+	lines1, lines2 := splitLinesKeepEnds(text1), splitLinesKeepEnds(text2)
+	edits := diffUnits(lines1, lines2, opts.Algorithm)
+
+	var sb strings.Builder
+	for _, h := range groupHunks(edits, context) {
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", from, to)
+		}
+
+		oldStart, newStart, oldCount, newCount := -1, -1, 0, 0
+		for _, e := range h {
+			if e.op != '+' {
+				if oldStart < 0 {
+					oldStart = e.a
+				}
+				oldCount++
+			}
+			if e.op != '-' {
+				if newStart < 0 {
+					newStart = e.b
+				}
+				newCount++
+			}
+		}
+		if oldStart < 0 {
+			oldStart = h[0].a
+		}
+		if newStart < 0 {
+			newStart = h[0].b
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+
+		for _, e := range h {
+			line := ""
+			switch e.op {
+			case '=':
+				line = " " + lines1[e.a]
+			case '-':
+				line = "-" + lines1[e.a]
+			case '+':
+				line = "+" + lines2[e.b]
+			}
+			sb.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// groupHunks splits an edit script into hunks of changes with up to
+// context unchanged lines around them
+func groupHunks(edits []diffEdit, context int) [][]diffEdit {
+	var hunks [][]diffEdit
+	start, end := -1, -1
+	for i, e := range edits {
+		if e.op == '=' {
+			continue
+		}
+		from, to := max(i-context, 0), min(i+context+1, len(edits))
+		if start >= 0 && from <= end {
+			end = to
+			continue
+		}
+		if start >= 0 {
+			hunks = append(hunks, edits[start:end])
+		}
+		start, end = from, to
+	}
+	if start >= 0 {
+		hunks = append(hunks, edits[start:end])
+	}
+	return hunks
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return strconv.Itoa(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// Patch is a parsed unified diff for a single file
+type Patch struct {
+	FromFile string
+	ToFile   string
+	Hunks    []Hunk
+}
+
+// Hunk is one "@@" section of a unified diff. Lines keep their ' ', '-' or
+// '+' prefix and their line terminator.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string
+}
+
+// ParsePatch parses a unified diff
+func ParsePatch(patch string) (*Patch, error) {
+	p := &Patch{}
+	var hunk *Hunk
+
+	for n, line := range splitLinesKeepEnds(patch) {
+		body := strings.TrimRight(line, "\r\n")
+		switch {
+		case hunk == nil && strings.HasPrefix(body, "--- "):
+			p.FromFile = strings.TrimSpace(strings.SplitN(body[4:], "\t", 2)[0])
+		case hunk == nil && strings.HasPrefix(body, "+++ "):
+			p.ToFile = strings.TrimSpace(strings.SplitN(body[4:], "\t", 2)[0])
+		case strings.HasPrefix(body, "@@"):
+			h, err := parseHunkHeader(body)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			p.Hunks = append(p.Hunks, h)
+			hunk = &p.Hunks[len(p.Hunks)-1]
+		case hunk == nil:
+			// Preamble such as "diff --git" lines
+		case strings.HasPrefix(body, "\\"):
+			// "\ No newline at end of file" applies to the previous line
+			if k := len(hunk.Lines); k > 0 {
+				hunk.Lines[k-1] = strings.TrimRight(hunk.Lines[k-1], "\r\n")
+			}
+		case body == "":
+			// Some editors strip the space from empty context lines
+			hunk.Lines = append(hunk.Lines, " \n")
+		case body[0] == ' ' || body[0] == '-' || body[0] == '+':
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			hunk.Lines = append(hunk.Lines, line)
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q in hunk", n+1, body)
+		}
+	}
+
+	if len(p.Hunks) == 0 {
+		return nil, fmt.Errorf("patch contains no hunks")
+	}
+	return p, nil
+}
+
+func parseHunkHeader(header string) (Hunk, error) {
+	var h Hunk
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return h, fmt.Errorf("malformed hunk header %q", header)
+	}
+
+	parse := func(r string) (int, int, error) {
+		start, count, found := strings.Cut(r, ",")
+		s, err := strconv.Atoi(start)
+		if err != nil {
+			return 0, 0, fmt.Errorf("malformed hunk range %q", r)
+		}
+		c := 1
+		if found {
+			if c, err = strconv.Atoi(count); err != nil {
+				return 0, 0, fmt.Errorf("malformed hunk range %q", r)
+			}
+		}
+		if s < 0 || c < 0 || s > patchMaxLine || c > patchMaxLine {
+			return 0, 0, fmt.Errorf("hunk range %q out of bounds", r)
+		}
+		return s, c, nil
+	}
+
+	var err error
+	if h.OldStart, h.OldLines, err = parse(fields[1][1:]); err != nil {
+		return h, err
+	}
+	if h.NewStart, h.NewLines, err = parse(fields[2][1:]); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+// Most context lines ApplyPatch may ignore at each end of a hunk
+const patchMaxFuzz = 2
+
+// Largest line number or line count accepted in a hunk header
+const patchMaxLine = 1 << 30
+
+// ApplyPatch applies a unified diff to text. Hunks are located near their
+// recorded line numbers even if earlier edits shifted them; a hunk whose
+// context no longer matches exactly is retried ignoring up to two context
+// lines at each end and then ignoring whitespace differences. An error
+// names the first hunk that cannot be placed.
+func ApplyPatch(text, patch string) (string, error) {
+	p, err := ParsePatch(patch)
+	if err != nil {
+		return "", err
+	}
+
+	lines := splitLinesKeepEnds(text)
+	offset := 0 // shift between recorded and actual line numbers
+	floor := 0  // hunks cannot overlap earlier ones
+
+	for n, h := range p.Hunks {
+		var old []string
+		for _, l := range h.Lines {
+			if l[0] != '+' {
+				old = append(old, l[1:])
+			}
+		}
+		leading, trailing := 0, 0
+		for leading < len(h.Lines) && h.Lines[leading][0] == ' ' {
+			leading++
+		}
+		for trailing < len(h.Lines)-leading && h.Lines[len(h.Lines)-1-trailing][0] == ' ' {
+			trailing++
+		}
+
+		// A hunk that only inserts records the line it follows
+		recorded := h.OldStart - 1
+		if h.OldLines == 0 {
+			recorded = h.OldStart
+		}
+		expected := recorded + offset
+
+		at, fuzzStart, fuzzEnd, ok := locateHunk(lines, old, expected, floor, leading, trailing)
+		if !ok {
+			return "", fmt.Errorf("hunk %d (@@ -%d,%d) does not apply", n+1, h.OldStart, h.OldLines)
+		}
+
+		// Context comes from the text, so whitespace that only matched
+		// loosely is left alone
+		var newLines []string
+		p := at
+		for _, l := range h.Lines[fuzzStart : len(h.Lines)-fuzzEnd] {
+			switch l[0] {
+			case ' ':
+				newLines = append(newLines, lines[p])
+				p++
+			case '-':
+				p++
+			case '+':
+				newLines = append(newLines, l[1:])
+			}
+		}
+		oldCount := p - at
+		merged := append([]string{}, lines[:at]...)
+		merged = append(merged, newLines...)
+		merged = append(merged, lines[at+oldCount:]...)
+		lines = merged
+
+		offset = at - fuzzStart - recorded + len(newLines) - oldCount
+		floor = at + len(newLines)
+	}
+
+	return strings.Join(lines, ""), nil
+}
+
+// locateHunk finds where old occurs in lines, searching outward from
+// expected. It returns the match position and how many leading and trailing
+// context lines had to be ignored.
+func locateHunk(lines, old []string, expected, floor, leading, trailing int) (at, fuzzStart, fuzzEnd int, ok bool) {
+	for _, loose := range []bool{false, true} {
+		for fuzz := 0; fuzz <= patchMaxFuzz; fuzz++ {
+			fs, fe := min(fuzz, leading), min(fuzz, trailing)
+			if fuzz > 0 && fs < fuzz && fe < fuzz {
+				continue // nothing more to drop
+			}
+			want := old[fs : len(old)-fe]
+			if len(want) == 0 && len(old) > 0 {
+				continue
+			}
+
+			// Search outward from the recorded position, clamped so a far
+			// off line number cannot spin through positions that don't exist
+			start := max(floor, min(expected+fs, len(lines)))
+			for delta := 0; start+delta <= len(lines) || start-delta >= floor; delta++ {
+				for _, pos := range []int{start + delta, start - delta} {
+					if pos >= floor && pos+len(want) <= len(lines) && linesMatch(lines[pos:pos+len(want)], want, loose) {
+						return pos, fs, fe, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+func linesMatch(got, want []string, loose bool) bool {
+	for i := range want {
+		a, b := strings.TrimRight(got[i], "\r\n"), strings.TrimRight(want[i], "\r\n")
+		if loose {
+			a, b = strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " ")
+		}
+		if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+// MergeOptions configures MergeThreeWay
+type MergeOptions struct {
+	OursLabel   string // Label after "<<<<<<<" (default "ours")
+	BaseLabel   string // Label after "|||||||" (default "base")
+	TheirsLabel string // Label after ">>>>>>>" (default "theirs")
+	ShowBase    bool   // Include the base text in conflicts, diff3 style
+	Algorithm   string // Line diff algorithm, "myers" (default) or "patience"
+	LinesOnly   bool   // Report every overlapping line change as a conflict
+}
+
+// MergeConflict is a region that both sides changed in different ways
+type MergeConflict struct {
+	Line   int // Line of the "<<<<<<<" marker in the merged text (1-based)
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+// MergeResult is the outcome of a three-way merge
+type MergeResult struct {
+	Text      string
+	Conflicts []MergeConflict
+	Resolved  int // Overlapping line changes merged at sentence or word level
+}
+
+// mergeChunk is a run of the three texts: stable where all agree, otherwise
+// the differing parts of each
+type mergeChunk struct {
+	stable             bool
+	base, ours, theirs []string
+}
+
+// MergeThreeWay combines the changes made to base in ours and in theirs.
+// Changes to different lines are merged as in diff3. When both sides touch
+// the same lines, the region is realigned by sentence and then by word, and
+// if the edits no longer overlap they are combined; otherwise the region is
+// written with conflict markers.
+func MergeThreeWay(base, ours, theirs string, opts MergeOptions) *MergeResult {
+	oursLabel, baseLabel, theirsLabel := opts.OursLabel, opts.BaseLabel, opts.TheirsLabel
+	if oursLabel == "" {
+		oursLabel = "ours"
+	}
+	if baseLabel == "" {
+		baseLabel = "base"
+	}
+	if theirsLabel == "" {
+		theirsLabel = "theirs"
+	}
+
+	result := &MergeResult{Conflicts: []MergeConflict{}}
+	var sb strings.Builder
+	line := 1
+
+	write := func(s string) {
+		sb.WriteString(s)
+		line += strings.Count(s, "\n")
+	}
+	section := func(s string) {
+		write(s)
+		if s != "" && !strings.HasSuffix(s, "\n") {
+			write("\n")
+		}
+	}
+
+	chunks := mergeUnits(splitLinesKeepEnds(base), splitLinesKeepEnds(ours), splitLinesKeepEnds(theirs), opts.Algorithm)
+	for _, c := range chunks {
+		if text, ok := c.resolve(); ok {
+			write(text)
+			continue
+		}
+
+		b, o, t := strings.Join(c.base, ""), strings.Join(c.ours, ""), strings.Join(c.theirs, "")
+		if !opts.LinesOnly {
+			if text, ok := mergeFiner(b, o, t); ok {
+				write(text)
+				result.Resolved++
+				continue
+			}
+		}
+
+		result.Conflicts = append(result.Conflicts, MergeConflict{Line: line, Base: b, Ours: o, Theirs: t})
+		write("<<<<<<< " + oursLabel + "\n")
+		section(o)
+		if opts.ShowBase {
+			write("||||||| " + baseLabel + "\n")
+			section(b)
+		}
+		write("=======\n")
+		section(t)
+		write(">>>>>>> " + theirsLabel + "\n")
+	}
+
+	result.Text = sb.String()
+	return result
+}
+
+// resolve returns the merged text of a chunk unless both sides changed it
+// differently
+func (c mergeChunk) resolve() (string, bool) {
+	b, o, t := strings.Join(c.base, ""), strings.Join(c.ours, ""), strings.Join(c.theirs, "")
+	switch {
+	case c.stable:
+		return b, true
+	case o == b:
+		return t, true
+	case t == b, o == t:
+		return o, true
+	}
+	return "", false
+}
+
+// mergeFiner retries a conflicting region with sentence units and then word
+// units, succeeding only if every chunk at that level resolves
+func mergeFiner(base, ours, theirs string) (string, bool) {
+	for _, split := range []func(string) []string{sentenceUnits, wordUnits} {
+		var sb strings.Builder
+		clean := true
+		for _, c := range mergeUnits(split(base), split(ours), split(theirs), "") {
+			text, ok := c.resolve()
+			if !ok {
+				clean = false
+				break
+			}
+			sb.WriteString(text)
+		}
+		if clean {
+			return sb.String(), true
+		}
+	}
+	return "", false
+}
+
+// sentenceUnits splits text after each sentence so the pieces, trailing
+// whitespace included, concatenate back to text
+func sentenceUnits(text string) []string {
+	var units []string
+	start := 0
+	for _, sent := range sentenceTokens(text) {
+		if sent.Position.End > start {
+			units = append(units, text[start:sent.Position.End])
+			start = sent.Position.End
+		}
+	}
+	if start < len(text) {
+		units = append(units, text[start:])
+	}
+	return units
+}
+
+// wordUnits splits text at UAX #29 word boundaries
+func wordUnits(text string) []string {
+	tokens := SegmentWords(text)
+	units := make([]string, len(tokens))
+	for i, tok := range tokens {
+		units[i] = tok.Text
+	}
+	return units
+}
+
+// mergeUnits aligns ours and theirs against base and cuts the three
+// sequences into stable chunks, where both sides kept the base unit, and the
+// unstable runs between them
+func mergeUnits(base, ours, theirs []string, algorithm string) []mergeChunk {
+	matches := func(other []string) []int {
+		m := make([]int, len(base))
+		for i := range m {
+			m[i] = -1
+		}
+		for _, e := range diffUnits(base, other, algorithm) {
+			if e.op == '=' {
+				m[e.a] = e.b
+			}
+		}
+		return m
+	}
+	inOurs, inTheirs := matches(ours), matches(theirs)
+
+	var chunks []mergeChunk
+	i, j, k := 0, 0, 0
+	for {
+		// Next base unit that both sides kept
+		next := i
+		for next < len(base) && (inOurs[next] < 0 || inTheirs[next] < 0) {
+			next++
+		}
+		nj, nk := len(ours), len(theirs)
+		if next < len(base) {
+			nj, nk = inOurs[next], inTheirs[next]
+		}
+
+		if next > i || nj > j || nk > k {
+			chunks = append(chunks, mergeChunk{base: base[i:next], ours: ours[j:nj], theirs: theirs[k:nk]})
+		}
+		if next == len(base) {
+			break
+		}
+
+		if n := len(chunks); n > 0 && chunks[n-1].stable {
+			chunks[n-1].base = base[next-len(chunks[n-1].base) : next+1]
+		} else {
+			chunks = append(chunks, mergeChunk{stable: true, base: base[next : next+1]})
+		}
+		i, j, k = next+1, nj+1, nk+1
+	}
+	return chunks
+}
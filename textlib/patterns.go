@@ -78,7 +78,7 @@ type TemporalPattern struct {
 }
 
 type TextAnomaly struct {
-	Type         string // "style_shift", "vocabulary_spike", "structure_break"
+	Type         string // "style_shift", "vocabulary_spike", "structure_break"; log timelines add "error_burst", "new_template", "gap"
 	Location     Position
 	Description  string
 	Severity     float64
@@ -331,54 +331,19 @@ func detectStructuralRepetitions(text string) []StructuralPattern {
 }
 
 func extractPOSPattern(sentence string) string {
-	// Simplified POS tagging
-	words := strings.Fields(sentence)
-	if len(words) < 3 || len(words) > 8 {
-		return ""
+	tokens := TagPartsOfSpeech(sentence)
+	tags := []string{}
+	for _, tok := range tokens {
+		if isWordTag(tok.Tag) {
+			tags = append(tags, tok.Universal)
+		}
 	}
 	
-	pattern := ""
-	for _, word := range words {
-		tag := simplePOSTag(word)
-		if tag != "" {
-			pattern += tag + " "
-		}
+	if len(tags) < 3 || len(tags) > 8 {
+		return ""
 	}
 	
-	return strings.TrimSpace(pattern)
-}
-
-func simplePOSTag(word string) string {
-	lower := strings.ToLower(strings.Trim(word, ".,!?;:"))
-	
-	// Very simplified POS tagging
-	if isLikelyVerb(lower) {
-		return "VERB"
-	} else if isPronoun(lower) {
-		return "PRON"
-	} else if isPreposition(lower) {
-		return "PREP"
-	} else if isConjunction(lower) {
-		return "CONJ"
-	} else if strings.HasSuffix(lower, "ly") {
-		return "ADV"
-	} else if strings.HasSuffix(lower, "ing") || strings.HasSuffix(lower, "ed") {
-		return "ADJ"
-	} else if unicode.IsUpper([]rune(word)[0]) {
-		return "NOUN"
-	}
-	
-	return "WORD"
-}
-
-func isPreposition(word string) bool {
-	prepositions := map[string]bool{
-		"in": true, "on": true, "at": true, "by": true, "for": true,
-		"with": true, "about": true, "against": true, "between": true,
-		"into": true, "through": true, "during": true, "before": true,
-		"after": true, "above": true, "below": true, "to": true, "from": true,
-	}
-	return prepositions[word]
+	return strings.Join(tags, " ")
 }
 
 func detectSyntacticPatterns(text string) []SyntacticPattern {
@@ -1336,60 +1301,3 @@ func calculateMeanStdDevFloat(values []float64) (mean, stdDev float64) {
 	
 	return mean, stdDev
 }
-
-// Helper functions for pattern analysis
-
-func isLikelyVerb(word string) bool {
-	word = strings.ToLower(strings.Trim(word, ".,!?;:"))
-	
-	// Common verb patterns and endings
-	verbPatterns := []string{
-		"ed", "ing", "s", "es", "ies", "ied",
-	}
-	
-	for _, pattern := range verbPatterns {
-		if strings.HasSuffix(word, pattern) {
-			return true
-		}
-	}
-	
-	// Common irregular verbs
-	irregularVerbs := []string{
-		"is", "are", "was", "were", "be", "been", "being",
-		"have", "has", "had", "do", "does", "did", "will",
-		"would", "could", "should", "can", "may", "might",
-		"go", "went", "come", "came", "see", "saw", "get", "got",
-		"make", "made", "take", "took", "give", "gave", "know", "knew",
-	}
-	
-	for _, verb := range irregularVerbs {
-		if word == verb {
-			return true
-		}
-	}
-	
-	return false
-}
-
-func isConjunction(word string) bool {
-	word = strings.ToLower(strings.Trim(word, ".,!?;:"))
-	
-	conjunctions := []string{
-		// Coordinating conjunctions
-		"and", "but", "or", "nor", "for", "yet", "so",
-		// Subordinating conjunctions
-		"because", "since", "although", "though", "while", "when",
-		"where", "if", "unless", "until", "after", "before", "as",
-		"that", "which", "who", "whom", "whose",
-		// Correlative conjunctions
-		"either", "neither", "both", "not", "whether",
-	}
-	
-	for _, conj := range conjunctions {
-		if word == conj {
-			return true
-		}
-	}
-	
-	return false
-}
\ No newline at end of file
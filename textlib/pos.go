@@ -0,0 +1,553 @@
+package textlib
+
+import (
+	_ "embed"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// TaggedToken represents a word annotated with its part of speech
+type TaggedToken struct {
+	Text      string
+	Tag       string // Penn Treebank tag, e.g. "NN", "VBD", "JJ"
+	Universal string // Universal tag, e.g. "NOUN", "VERB", "ADJ"
+	Position  Position
+}
+
+// POSTagger is an averaged perceptron part-of-speech tagger
+type POSTagger struct {
+	weights map[string]map[string]float64
+	tags    []string
+	tagDict map[string]string
+
+	// Training state for weight averaging
+	totals    map[string]map[string]float64
+	stamps    map[string]map[string]int
+	instances int
+}
+
+//go:embed data/pos_train.txt
+var posTrainingCorpus string
+
+var (
+	defaultTagger     *POSTagger
+	defaultTaggerOnce sync.Once
+
+	posTokenPattern = regexp.MustCompile(`\p{L}+(?:[-'’.]\p{L}+)*\.?|\p{N}+(?:[.,:]\p{N}+)*|'[a-zA-Z]+|\S`)
+)
+
+// Closed-class words whose tag does not depend on context
+var posClosedClass = map[string]string{
+	"the": "DT", "a": "DT", "an": "DT", "every": "DT", "each": "DT",
+	"these": "DT", "those": "DT",
+	"i": "PRP", "you": "PRP", "he": "PRP", "she": "PRP", "it": "PRP",
+	"we": "PRP", "they": "PRP", "me": "PRP", "him": "PRP", "us": "PRP", "them": "PRP",
+	"my": "PRP$", "your": "PRP$", "his": "PRP$", "its": "PRP$", "our": "PRP$", "their": "PRP$",
+	"of": "IN", "in": "IN", "on": "IN", "at": "IN", "with": "IN", "from": "IN",
+	"into": "IN", "through": "IN", "during": "IN", "between": "IN", "against": "IN",
+	"because": "IN", "although": "IN", "though": "IN", "unless": "IN", "whether": "IN",
+	"to": "TO",
+	"and": "CC", "but": "CC", "or": "CC", "nor": "CC",
+	"will": "MD", "would": "MD", "could": "MD", "should": "MD", "can": "MD",
+	"may": "MD", "might": "MD", "must": "MD", "shall": "MD", "ca": "MD", "wo": "MD",
+	"is": "VBZ", "was": "VBD", "were": "VBD", "am": "VBP", "are": "VBP",
+	"been": "VBN", "being": "VBG", "be": "VB",
+	"has": "VBZ", "does": "VBZ", "did": "VBD", "had": "VBD",
+	"not": "RB", "n't": "RB", "very": "RB",
+	"who": "WP", "whom": "WP", "what": "WP", "whose": "WP$",
+	"where": "WRB", "when": "WRB", "why": "WRB", "how": "WRB",
+}
+
+// TagPartsOfSpeech tags every token in text with its part of speech
+func TagPartsOfSpeech(text string) []TaggedToken {
+	return DefaultPOSTagger().Tag(text)
+}
+
+// DefaultPOSTagger returns the shared tagger trained on the embedded corpus
+func DefaultPOSTagger() *POSTagger {
+	defaultTaggerOnce.Do(func() {
+		defaultTagger = NewPOSTagger()
+		defaultTagger.Train(parseTaggedCorpus(posTrainingCorpus), 8)
+	})
+	return defaultTagger
+}
+
+// NewPOSTagger creates an untrained tagger
+func NewPOSTagger() *POSTagger {
+	return &POSTagger{
+		weights: make(map[string]map[string]float64),
+		tags:    []string{},
+		tagDict: make(map[string]string),
+		totals:  make(map[string]map[string]float64),
+		stamps:  make(map[string]map[string]int),
+	}
+}
+
+// Tag tokenizes text and assigns a Penn Treebank tag to each token
+func (t *POSTagger) Tag(text string) []TaggedToken {
+	tokens := tokenizeForTagging(text)
+	if len(tokens) == 0 {
+		return []TaggedToken{}
+	}
+
+	words := make([]string, len(tokens))
+	for i, tok := range tokens {
+		words[i] = tok.Text
+	}
+
+	tags := t.TagWords(words)
+	for i := range tokens {
+		tokens[i].Tag = tags[i]
+		tokens[i].Universal = UniversalTag(tags[i])
+	}
+
+	return tokens
+}
+
+// TagWords assigns a Penn Treebank tag to each pre-tokenized word
+func (t *POSTagger) TagWords(words []string) []string {
+	tags := make([]string, len(words))
+	context := buildTaggingContext(words)
+	prev, prev2 := "-START-", "-START2-"
+
+	for i, word := range words {
+		tag, ok := t.lookupTag(word)
+		if !ok {
+			features := extractTaggingFeatures(i+2, word, context, prev, prev2)
+			tag = t.predict(features)
+		}
+		tags[i] = tag
+		prev2 = prev
+		prev = tag
+	}
+
+	return tags
+}
+
+// Train fits the perceptron on tagged sentences for the given number of passes
+func (t *POSTagger) Train(sentences [][]TaggedToken, iterations int) {
+	t.buildTagDict(sentences)
+
+	// Shuffle with a fixed seed so training is deterministic
+	rng := rand.New(rand.NewSource(1))
+	order := make([]int, len(sentences))
+	for i := range order {
+		order[i] = i
+	}
+
+	for iter := 0; iter < iterations; iter++ {
+		for _, idx := range order {
+			sentence := sentences[idx]
+			words := make([]string, len(sentence))
+			for i, tok := range sentence {
+				words[i] = tok.Text
+			}
+
+			context := buildTaggingContext(words)
+			prev, prev2 := "-START-", "-START2-"
+
+			for i, tok := range sentence {
+				guess, ok := t.lookupTag(tok.Text)
+				if !ok {
+					features := extractTaggingFeatures(i+2, tok.Text, context, prev, prev2)
+					guess = t.predict(features)
+					t.update(tok.Tag, guess, features)
+				}
+				prev2 = prev
+				prev = guess
+			}
+		}
+
+		rng.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+	}
+
+	t.averageWeights()
+}
+
+func (t *POSTagger) lookupTag(word string) (string, bool) {
+	if tag, ok := posClosedClass[strings.ToLower(word)]; ok {
+		return tag, true
+	}
+	if tag, ok := t.tagDict[word]; ok {
+		return tag, true
+	}
+	if isPunctuationToken(word) {
+		return punctuationTag(word), true
+	}
+	return "", false
+}
+
+func (t *POSTagger) predict(features map[string]int) string {
+	scores := make(map[string]float64)
+	for feat, value := range features {
+		weights, exists := t.weights[feat]
+		if !exists || value == 0 {
+			continue
+		}
+		for tag, weight := range weights {
+			scores[tag] += float64(value) * weight
+		}
+	}
+
+	// Break ties alphabetically so prediction is deterministic
+	best := "NN"
+	bestScore := 0.0
+	first := true
+	for _, tag := range t.tags {
+		score := scores[tag]
+		if first || score > bestScore {
+			best = tag
+			bestScore = score
+			first = false
+		}
+	}
+
+	return best
+}
+
+func (t *POSTagger) update(truth, guess string, features map[string]int) {
+	t.instances++
+	if truth == guess {
+		return
+	}
+
+	for feat := range features {
+		if t.weights[feat] == nil {
+			t.weights[feat] = make(map[string]float64)
+			t.totals[feat] = make(map[string]float64)
+			t.stamps[feat] = make(map[string]int)
+		}
+		t.updateWeight(feat, truth, 1.0)
+		t.updateWeight(feat, guess, -1.0)
+	}
+}
+
+func (t *POSTagger) updateWeight(feat, tag string, delta float64) {
+	weight := t.weights[feat][tag]
+	t.totals[feat][tag] += float64(t.instances-t.stamps[feat][tag]) * weight
+	t.stamps[feat][tag] = t.instances
+	t.weights[feat][tag] = weight + delta
+}
+
+func (t *POSTagger) averageWeights() {
+	if t.instances == 0 {
+		return
+	}
+
+	for feat, weights := range t.weights {
+		for tag, weight := range weights {
+			total := t.totals[feat][tag] + float64(t.instances-t.stamps[feat][tag])*weight
+			averaged := total / float64(t.instances)
+			if averaged == 0 {
+				delete(weights, tag)
+			} else {
+				weights[tag] = averaged
+			}
+		}
+	}
+
+	t.totals = make(map[string]map[string]float64)
+	t.stamps = make(map[string]map[string]int)
+}
+
+func (t *POSTagger) buildTagDict(sentences [][]TaggedToken) {
+	counts := make(map[string]map[string]int)
+	tagSet := make(map[string]bool)
+
+	for _, sentence := range sentences {
+		for _, tok := range sentence {
+			if counts[tok.Text] == nil {
+				counts[tok.Text] = make(map[string]int)
+			}
+			counts[tok.Text][tok.Tag]++
+			tagSet[tok.Tag] = true
+		}
+	}
+
+	t.tags = t.tags[:0]
+	for tag := range tagSet {
+		t.tags = append(t.tags, tag)
+	}
+	sort.Strings(t.tags)
+
+	// Only words that are frequent and unambiguous go into the dictionary
+	for word, tagCounts := range counts {
+		total := 0
+		bestTag, bestCount := "", 0
+		for tag, count := range tagCounts {
+			total += count
+			if count > bestCount || (count == bestCount && tag < bestTag) {
+				bestTag, bestCount = tag, count
+			}
+		}
+		if total >= 3 && bestCount == total {
+			t.tagDict[word] = bestTag
+		}
+	}
+}
+
+// Helper functions
+
+func tokenizeForTagging(text string) []TaggedToken {
+	tokens := []TaggedToken{}
+
+	for _, match := range posTokenPattern.FindAllStringIndex(text, -1) {
+		start, end := match[0], match[1]
+		word := text[start:end]
+
+		// Keep abbreviations like "Dr." whole, otherwise detach the trailing period
+		if len(word) > 1 && strings.HasSuffix(word, ".") && !isAbbreviationToken(word) {
+			tokens = append(tokens, TaggedToken{Text: word[:len(word)-1], Position: Position{Start: start, End: end - 1}})
+			tokens = append(tokens, TaggedToken{Text: ".", Position: Position{Start: end - 1, End: end}})
+			continue
+		}
+
+		// Split contractions the way the Penn Treebank does
+		lower := strings.ToLower(word)
+		if len(word) > 3 && strings.HasSuffix(lower, "n't") {
+			split := end - 3
+			tokens = append(tokens, TaggedToken{Text: text[start:split], Position: Position{Start: start, End: split}})
+			tokens = append(tokens, TaggedToken{Text: text[split:end], Position: Position{Start: split, End: end}})
+			continue
+		}
+		if idx := strings.LastIndexAny(word, "'’"); idx > 0 && idx < len(word)-1 {
+			split := start + idx
+			tokens = append(tokens, TaggedToken{Text: text[start:split], Position: Position{Start: start, End: split}})
+			tokens = append(tokens, TaggedToken{Text: text[split:end], Position: Position{Start: split, End: end}})
+			continue
+		}
+
+		tokens = append(tokens, TaggedToken{Text: word, Position: Position{Start: start, End: end}})
+	}
+
+	return tokens
+}
+
+func isAbbreviationToken(word string) bool {
+	abbreviations := map[string]bool{
+		"mr.": true, "mrs.": true, "ms.": true, "dr.": true, "prof.": true,
+		"sr.": true, "jr.": true, "vs.": true, "etc.": true, "inc.": true,
+		"corp.": true, "ltd.": true, "co.": true, "st.": true,
+	}
+	lower := strings.ToLower(word)
+	if abbreviations[lower] {
+		return true
+	}
+
+	// Dotted initialisms such as "U.S." or "e.g."
+	return strings.Count(word, ".") > 1
+}
+
+func parseTaggedCorpus(corpus string) [][]TaggedToken {
+	sentences := [][]TaggedToken{}
+
+	for _, line := range strings.Split(corpus, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sentence := []TaggedToken{}
+		for _, pair := range strings.Fields(line) {
+			idx := strings.LastIndex(pair, "/")
+			if idx <= 0 || idx == len(pair)-1 {
+				continue
+			}
+			tag := pair[idx+1:]
+			sentence = append(sentence, TaggedToken{
+				Text:      pair[:idx],
+				Tag:       tag,
+				Universal: UniversalTag(tag),
+			})
+		}
+
+		if len(sentence) > 0 {
+			sentences = append(sentences, sentence)
+		}
+	}
+
+	return sentences
+}
+
+func buildTaggingContext(words []string) []string {
+	context := make([]string, 0, len(words)+4)
+	context = append(context, "-START-", "-START2-")
+	for _, word := range words {
+		context = append(context, normalizeTaggingWord(word))
+	}
+	context = append(context, "-END-", "-END2-")
+	return context
+}
+
+func normalizeTaggingWord(word string) string {
+	switch {
+	case strings.Contains(word, "-") && !strings.HasPrefix(word, "-"):
+		return "!HYPHEN"
+	case len(word) == 4 && isAllDigits(word):
+		return "!YEAR"
+	case len(word) > 0 && unicode.IsDigit([]rune(word)[0]):
+		return "!DIGITS"
+	default:
+		return strings.ToLower(word)
+	}
+}
+
+func extractTaggingFeatures(i int, word string, context []string, prev, prev2 string) map[string]int {
+	features := make(map[string]int)
+	add := func(name string, args ...string) {
+		features[name+" "+strings.Join(args, " ")]++
+	}
+
+	lower := context[i]
+	add("bias")
+	add("i suffix", lastRunes(lower, 3))
+	add("i suffix2", lastRunes(lower, 2))
+	add("i pref1", firstRunes(lower, 1))
+	add("i-1 tag", prev)
+	add("i-2 tag", prev2)
+	add("i tag+i-2 tag", prev, prev2)
+	add("i word", lower)
+	add("i-1 tag+i word", prev, lower)
+	add("i-1 word", context[i-1])
+	add("i-1 suffix", lastRunes(context[i-1], 3))
+	add("i-2 word", context[i-2])
+	add("i+1 word", context[i+1])
+	add("i+1 suffix", lastRunes(context[i+1], 3))
+	add("i+2 word", context[i+2])
+
+	// Word shape helps with unseen proper nouns and numbers
+	runes := []rune(word)
+	if len(runes) > 0 && unicode.IsUpper(runes[0]) {
+		if prev == "-START-" {
+			add("i shape", "Title-initial")
+		} else {
+			add("i shape", "Title")
+		}
+	}
+	if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+		add("i shape", "digit")
+	}
+
+	return features
+}
+
+func lastRunes(word string, n int) string {
+	runes := []rune(word)
+	if len(runes) <= n {
+		return word
+	}
+	return string(runes[len(runes)-n:])
+}
+
+func firstRunes(word string, n int) string {
+	runes := []rune(word)
+	if len(runes) <= n {
+		return word
+	}
+	return string(runes[:n])
+}
+
+func isAllDigits(word string) bool {
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return word != ""
+}
+
+func isPunctuationToken(word string) bool {
+	for _, r := range word {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return word != "" && word != "$" && word != "%"
+}
+
+func punctuationTag(word string) string {
+	switch word {
+	case ".", "!", "?":
+		return "."
+	case ",":
+		return ","
+	case ":", ";", "-", "--", "...":
+		return ":"
+	case "(", "[", "{":
+		return "("
+	case ")", "]", "}":
+		return ")"
+	case "\"", "“", "”", "`", "'":
+		return "''"
+	case "#":
+		return "#"
+	default:
+		return "SYM"
+	}
+}
+
+// UniversalTag maps a Penn Treebank tag to its Universal POS equivalent
+func UniversalTag(tag string) string {
+	switch {
+	case tag == "NNP" || tag == "NNPS":
+		return "PROPN"
+	case tag == "NN" || tag == "NNS":
+		return "NOUN"
+	case strings.HasPrefix(tag, "VB"):
+		return "VERB"
+	case tag == "MD":
+		return "AUX"
+	case strings.HasPrefix(tag, "JJ"):
+		return "ADJ"
+	case strings.HasPrefix(tag, "RB") || tag == "WRB":
+		return "ADV"
+	case tag == "PRP" || tag == "WP" || tag == "EX":
+		return "PRON"
+	case tag == "DT" || tag == "PDT" || tag == "WDT" || tag == "PRP$" || tag == "WP$":
+		return "DET"
+	case tag == "IN" || tag == "TO":
+		return "ADP"
+	case tag == "CC":
+		return "CCONJ"
+	case tag == "CD":
+		return "NUM"
+	case tag == "RP" || tag == "POS":
+		return "PART"
+	case tag == "UH":
+		return "INTJ"
+	case tag == "$" || tag == "%" || tag == "SYM" || tag == "#":
+		return "SYM"
+	case tag == "." || tag == "," || tag == ":" || tag == "(" || tag == ")" || tag == "''" || tag == "``":
+		return "PUNCT"
+	default:
+		return "X"
+	}
+}
+
+// Tag predicates shared by the grammar, pattern and entity analyzers
+
+func isVerbTag(tag string) bool {
+	return strings.HasPrefix(tag, "VB") || tag == "MD"
+}
+
+func isFiniteVerbTag(tag string) bool {
+	return tag == "VBD" || tag == "VBZ" || tag == "VBP" || tag == "MD"
+}
+
+func isNounTag(tag string) bool {
+	return strings.HasPrefix(tag, "NN")
+}
+
+func isSubjectTag(tag string) bool {
+	return isNounTag(tag) || tag == "PRP" || tag == "EX" || tag == "WP"
+}
+
+func isWordTag(tag string) bool {
+	return UniversalTag(tag) != "PUNCT"
+}
@@ -0,0 +1,149 @@
+package textlib
+
+import (
+	"testing"
+)
+
+func TestTagPartsOfSpeech(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			name:     "Simple past",
+			text:     "The cat sat on the mat.",
+			expected: []string{"DT", "NN", "VBD", "IN", "DT", "NN", "."},
+		},
+		{
+			name:     "Gerund fragment",
+			text:     "Walking to the store.",
+			expected: []string{"VBG", "TO", "DT", "NN", "."},
+		},
+		{
+			name:     "Contraction",
+			text:     "I don't know.",
+			expected: []string{"PRP", "VBP", "RB", "VB", "."},
+		},
+		{
+			name:     "Imperative",
+			text:     "Go!",
+			expected: []string{"VB", "."},
+		},
+		{
+			name:     "Adverb before irregular past",
+			text:     "She quickly ran to the station.",
+			expected: []string{"PRP", "RB", "VBD", "TO", "DT", "NN", "."},
+		},
+		{
+			name:     "Number word and plural",
+			text:     "Two dogs slept under the table.",
+			expected: []string{"CD", "NNS", "VBD", "IN", "DT", "NN", "."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := TagPartsOfSpeech(tt.text)
+
+			if len(tokens) != len(tt.expected) {
+				t.Fatalf("Expected %d tokens, got %d: %v", len(tt.expected), len(tokens), tokens)
+			}
+
+			for i, tok := range tokens {
+				if tok.Tag != tt.expected[i] {
+					t.Errorf("Token %q: expected tag %s, got %s", tok.Text, tt.expected[i], tok.Tag)
+				}
+			}
+		})
+	}
+}
+
+func TestTaggedTokenPositions(t *testing.T) {
+	text := "Dr. Smith didn't deploy the build."
+	tokens := TagPartsOfSpeech(text)
+
+	if len(tokens) == 0 {
+		t.Fatal("Expected tokens")
+	}
+
+	for _, tok := range tokens {
+		if text[tok.Position.Start:tok.Position.End] != tok.Text {
+			t.Errorf("Position %v maps to %q, expected %q",
+				tok.Position, text[tok.Position.Start:tok.Position.End], tok.Text)
+		}
+		if tok.Universal != UniversalTag(tok.Tag) {
+			t.Errorf("Token %q: universal tag %s does not match %s", tok.Text, tok.Universal, tok.Tag)
+		}
+	}
+
+	if tokens[0].Text != "Dr." {
+		t.Errorf("Expected abbreviation to stay whole, got %q", tokens[0].Text)
+	}
+}
+
+func TestUniversalTag(t *testing.T) {
+	tests := map[string]string{
+		"NN":   "NOUN",
+		"NNPS": "PROPN",
+		"VBZ":  "VERB",
+		"MD":   "AUX",
+		"JJR":  "ADJ",
+		"PRP$": "DET",
+		"IN":   "ADP",
+		"CC":   "CCONJ",
+		".":    "PUNCT",
+		"???":  "X",
+	}
+
+	for penn, expected := range tests {
+		if got := UniversalTag(penn); got != expected {
+			t.Errorf("UniversalTag(%q): expected %s, got %s", penn, expected, got)
+		}
+	}
+}
+
+func TestPOSTaggerTrain(t *testing.T) {
+	corpus := parseTaggedCorpus(`
+Dogs/NNS bark/VBP loudly/RB ./.
+Cats/NNS sleep/VBP quietly/RB ./.
+Birds/NNS sing/VBP sweetly/RB ./.
+`)
+
+	tagger := NewPOSTagger()
+	tagger.Train(corpus, 5)
+
+	tags := tagger.TagWords([]string{"Cows", "graze", "slowly", "."})
+	expected := []string{"NNS", "VBP", "RB", "."}
+
+	for i := range expected {
+		if tags[i] != expected[i] {
+			t.Errorf("Word %d: expected %s, got %s", i, expected[i], tags[i])
+		}
+	}
+}
+
+func TestAnalyzersShareTagger(t *testing.T) {
+	if tense := identifyTense("The team deployed the service."); tense != "past" {
+		t.Errorf("Expected past tense, got %q", tense)
+	}
+	if tense := identifyTense("The service will restart."); tense != "future" {
+		t.Errorf("Expected future tense, got %q", tense)
+	}
+
+	if !isSentenceFragment("Walking to the store.") {
+		t.Error("Expected gerund phrase to be a fragment")
+	}
+	if isSentenceFragment("Close the door.") {
+		t.Error("Expected imperative not to be a fragment")
+	}
+
+	// "test" is a noun here, "deployed" is a verb
+	actions := extractActionEntities("We deployed the test.")
+	if len(actions) != 1 || actions[0].Text != "deployed" {
+		t.Fatalf("Expected one action entity for 'deployed', got %v", actions)
+	}
+	if actions[0].Attributes["tense"] != "past" {
+		t.Errorf("Expected past tense action, got %s", actions[0].Attributes["tense"])
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 Caia Tech
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textlib
+
+import (
+	"math"
+	"math/rand"
+)
+
+// BanditArm holds the reward statistics of one strategy in one context
+type BanditArm struct {
+	Strategy    string  `json:"strategy"`
+	Pulls       float64 `json:"pulls"`
+	TotalReward float64 `json:"total_reward"`
+}
+
+// MeanReward returns the average reward, or 0 for an arm never pulled
+func (a BanditArm) MeanReward() float64 {
+	if a.Pulls == 0 {
+		return 0
+	}
+	return a.TotalReward / a.Pulls
+}
+
+// record adds one observation with a reward in [0, 1]
+func (a *BanditArm) record(reward float64) {
+	a.Pulls++
+	a.TotalReward += math.Max(0, math.Min(reward, 1))
+}
+
+// BanditPolicy picks which arm to play next. Implementations must not keep
+// state between calls; the selector owns the statistics and the random
+// source.
+type BanditPolicy interface {
+	Name() string
+	Select(arms []BanditArm, rng *rand.Rand) int
+}
+
+// EpsilonGreedyPolicy plays the arm with the best mean reward, except that
+// with probability Epsilon it explores a random arm. Epsilon 0 is purely
+// greedy.
+type EpsilonGreedyPolicy struct {
+	Epsilon float64
+}
+
+// Name returns "epsilon-greedy"
+func (p EpsilonGreedyPolicy) Name() string { return "epsilon-greedy" }
+
+// Select returns the index of the arm to play
+func (p EpsilonGreedyPolicy) Select(arms []BanditArm, rng *rand.Rand) int {
+	if rng.Float64() < p.Epsilon {
+		return rng.Intn(len(arms))
+	}
+	return argmaxArm(arms, rng, func(a BanditArm) float64 { return a.MeanReward() })
+}
+
+// UCB1Policy plays the arm with the highest upper confidence bound,
+// mean + C*sqrt(ln N / n), trying every arm once first. C defaults to
+// sqrt(2).
+type UCB1Policy struct {
+	C float64
+}
+
+// Name returns "ucb1"
+func (p UCB1Policy) Name() string { return "ucb1" }
+
+// Select returns the index of the arm to play
+func (p UCB1Policy) Select(arms []BanditArm, rng *rand.Rand) int {
+	c := p.C
+	if c <= 0 {
+		c = math.Sqrt2
+	}
+
+	total := 0.0
+	for _, a := range arms {
+		total += a.Pulls
+	}
+	return argmaxArm(arms, rng, func(a BanditArm) float64 {
+		if a.Pulls == 0 {
+			return math.Inf(1)
+		}
+		return a.MeanReward() + c*math.Sqrt(math.Log(total)/a.Pulls)
+	})
+}
+
+// ThompsonSamplingPolicy draws a plausible mean for each arm from its
+// Beta(1+reward, 1+pulls-reward) posterior and plays the highest draw
+type ThompsonSamplingPolicy struct{}
+
+// Name returns "thompson"
+func (p ThompsonSamplingPolicy) Name() string { return "thompson" }
+
+// Select returns the index of the arm to play
+func (p ThompsonSamplingPolicy) Select(arms []BanditArm, rng *rand.Rand) int {
+	return argmaxArm(arms, rng, func(a BanditArm) float64 {
+		return sampleBeta(rng, 1+a.TotalReward, 1+a.Pulls-a.TotalReward)
+	})
+}
+
+// argmaxArm returns the arm with the highest score, breaking ties at random
+func argmaxArm(arms []BanditArm, rng *rand.Rand, score func(BanditArm) float64) int {
+	best, bestScore, ties := 0, math.Inf(-1), 0
+	for i, a := range arms {
+		s := score(a)
+		switch {
+		case s > bestScore:
+			best, bestScore, ties = i, s, 1
+		case s == bestScore:
+			ties++
+			if rng.Intn(ties) == 0 {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// sampleBeta draws from Beta(a, b) as the ratio of two gamma variates
+func sampleBeta(rng *rand.Rand, a, b float64) float64 {
+	x, y := sampleGamma(rng, a), sampleGamma(rng, b)
+	if x+y == 0 {
+		return 0.5
+	}
+	return x / (x + y)
+}
+
+// sampleGamma draws from Gamma(shape, 1) with the Marsaglia-Tsang method
+func sampleGamma(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		return sampleGamma(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2025 Caia Tech
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textlib
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Bumped whenever cached result types change shape, so stale disk entries
+// are never decoded into the new types
+const resultCacheVersion = 1
+
+// DefaultResultCacheEntries is the memory tier capacity used when
+// ResultCacheOptions.MaxEntries is zero
+const DefaultResultCacheEntries = 1024
+
+// DefaultResultCacheBytes bounds the encoded results the global cache used
+// by the RL analysis functions keeps in memory
+const DefaultResultCacheBytes = 16 << 20
+
+// ResultCacheOptions configures a ResultCache
+type ResultCacheOptions struct {
+	MaxEntries int           // memory tier capacity; 0 uses DefaultResultCacheEntries
+	MaxBytes   int64         // memory tier limit on encoded result size; 0 means no limit
+	Dir        string        // directory for the disk tier; empty keeps results in memory only
+	TTL        time.Duration // entries older than this are ignored; 0 keeps them until evicted
+}
+
+// ResultCacheStats reports cache activity since the cache was created or cleared
+type ResultCacheStats struct {
+	Hits      int64 `json:"hits"`
+	DiskHits  int64 `json:"disk_hits"` // hits served from disk, included in Hits
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// ResultCache stores algorithm results keyed by ResultCacheKey. Recently
+// used results are kept in an in-memory LRU; with a directory configured,
+// every result is also written to disk so it survives restarts and memory
+// evictions. Results are stored as JSON, so callers always receive a copy.
+// It is safe for concurrent use.
+type ResultCache struct {
+	opts ResultCacheOptions
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+	bytes   int64
+	stats   ResultCacheStats
+}
+
+type resultCacheEntry struct {
+	key    string
+	data   []byte
+	stored time.Time
+}
+
+// diskCacheEntry is the on-disk form of a cached result
+type diskCacheEntry struct {
+	Stored time.Time       `json:"stored"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// NewResultCache creates a cache, creating the disk tier directory if needed
+func NewResultCache(opts ResultCacheOptions) (*ResultCache, error) {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultResultCacheEntries
+	}
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+	return &ResultCache{
+		opts:    opts,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}, nil
+}
+
+// ResultCacheKey derives the cache key for running algorithm with params on
+// text. Params are encoded as JSON with sorted keys, so map order does not
+// matter.
+func ResultCacheKey(algorithm string, params map[string]interface{}, text string) string {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		encoded = []byte(fmt.Sprintf("%v", params))
+	}
+	textHash := sha256.Sum256([]byte(text))
+
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00%s\x00", resultCacheVersion, algorithm)
+	h.Write(encoded)
+	h.Write([]byte{0})
+	h.Write(textHash[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get decodes the result stored under key into out, which must be a pointer.
+// It reports whether the result was found.
+func (c *ResultCache) Get(key string, out interface{}) bool {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*resultCacheEntry)
+		if !c.expired(entry.stored) && json.Unmarshal(entry.data, out) == nil {
+			c.order.MoveToFront(elem)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return true
+		}
+		c.remove(elem)
+	}
+	c.mu.Unlock()
+
+	if c.opts.Dir != "" {
+		if entry, ok := c.readDisk(key); ok && json.Unmarshal(entry.Value, out) == nil {
+			c.mu.Lock()
+			c.insert(key, entry.Value, entry.Stored)
+			c.stats.Hits++
+			c.stats.DiskHits++
+			c.mu.Unlock()
+			return true
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Misses++
+	c.mu.Unlock()
+	return false
+}
+
+// Set stores value under key, replacing any previous result
+func (c *ResultCache) Set(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached result: %w", err)
+	}
+	stored := time.Now()
+
+	c.mu.Lock()
+	c.insert(key, data, stored)
+	c.mu.Unlock()
+
+	if c.opts.Dir == "" {
+		return nil
+	}
+	encoded, err := json.Marshal(diskCacheEntry{Stored: stored, Value: data})
+	if err != nil {
+		return err
+	}
+	path := c.diskPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, encoded)
+}
+
+// Delete removes the result stored under key from both tiers
+func (c *ResultCache) Delete(key string) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.mu.Unlock()
+
+	if c.opts.Dir != "" {
+		os.Remove(c.diskPath(key))
+	}
+}
+
+// Clear removes every result from both tiers and resets the statistics
+func (c *ResultCache) Clear() error {
+	c.mu.Lock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.bytes = 0
+	c.stats = ResultCacheStats{}
+	c.mu.Unlock()
+
+	if c.opts.Dir == "" {
+		return nil
+	}
+	shards, err := filepath.Glob(filepath.Join(c.opts.Dir, "[0-9a-f][0-9a-f]"))
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if err := os.RemoveAll(shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the cache statistics
+func (c *ResultCache) Stats() ResultCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// insert adds or refreshes an entry and evicts from the back until the
+// memory tier fits its limits. The caller must hold c.mu.
+func (c *ResultCache) insert(key string, data []byte, stored time.Time) {
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&resultCacheEntry{key: key, data: data, stored: stored})
+	c.bytes += int64(len(data))
+
+	for c.order.Len() > c.opts.MaxEntries || (c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes && c.order.Len() > 1) {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops an entry from the memory tier. The caller must hold c.mu.
+func (c *ResultCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*resultCacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.data))
+}
+
+func (c *ResultCache) expired(stored time.Time) bool {
+	return c.opts.TTL > 0 && time.Since(stored) > c.opts.TTL
+}
+
+// diskPath shards entries by the first byte of the key to keep directories small
+func (c *ResultCache) diskPath(key string) string {
+	return filepath.Join(c.opts.Dir, key[:2], key+".json")
+}
+
+func (c *ResultCache) readDisk(key string) (diskCacheEntry, bool) {
+	var entry diskCacheEntry
+	if len(key) < 2 {
+		return entry, false
+	}
+	data, err := os.ReadFile(c.diskPath(key))
+	if err != nil {
+		return entry, false
+	}
+	if json.Unmarshal(data, &entry) != nil || c.expired(entry.Stored) {
+		os.Remove(c.diskPath(key))
+		return entry, false
+	}
+	return entry, true
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Global result cache consulted by the RL analysis functions
+var (
+	resultCache, _ = NewResultCache(ResultCacheOptions{MaxBytes: DefaultResultCacheBytes})
+	resultCacheMu  sync.RWMutex
+)
+
+// SetResultCache replaces the cache used by ExtractSentiment, SummarizeText,
+// ClassifyTopics and AnalyzeTextComplexity. A nil cache disables caching.
+// The initial cache holds at most DefaultResultCacheEntries results and
+// DefaultResultCacheBytes of encoded results.
+func SetResultCache(cache *ResultCache) {
+	resultCacheMu.Lock()
+	defer resultCacheMu.Unlock()
+	resultCache = cache
+}
+
+// GetResultCache returns the cache used by the RL analysis functions, or nil
+// when caching is disabled
+func GetResultCache() *ResultCache {
+	resultCacheMu.RLock()
+	defer resultCacheMu.RUnlock()
+	return resultCache
+}
+
+// loadCachedResult decodes a cached result into out and records the hit
+func loadCachedResult(key string, out interface{}, collector *MetricsCollector) bool {
+	cache := GetResultCache()
+	if cache == nil || !cache.Get(key, out) {
+		return false
+	}
+	collector.RecordCacheHit()
+	return true
+}
+
+// storeCachedResult caches a freshly computed result. Caching is best
+// effort, so encoding and disk errors are ignored.
+func storeCachedResult(key string, value interface{}) {
+	if cache := GetResultCache(); cache != nil {
+		cache.Set(key, value)
+	}
+}
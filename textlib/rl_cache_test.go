@@ -0,0 +1,215 @@
+// Copyright 2025 Caia Tech
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textlib
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResultCacheKey(t *testing.T) {
+	a := ResultCacheKey("algo", map[string]interface{}{"x": 1, "y": "z"}, "text")
+	b := ResultCacheKey("algo", map[string]interface{}{"y": "z", "x": 1}, "text")
+	if a != b {
+		t.Error("Expected key to ignore map order")
+	}
+
+	for _, other := range []string{
+		ResultCacheKey("other", map[string]interface{}{"x": 1, "y": "z"}, "text"),
+		ResultCacheKey("algo", map[string]interface{}{"x": 2, "y": "z"}, "text"),
+		ResultCacheKey("algo", map[string]interface{}{"x": 1, "y": "z"}, "text!"),
+	} {
+		if other == a {
+			t.Error("Expected different inputs to produce different keys")
+		}
+	}
+}
+
+func TestResultCacheLRU(t *testing.T) {
+	cache, err := NewResultCache(ResultCacheOptions{MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Set("a", SummaryResult{Summary: "A"})
+	cache.Set("b", SummaryResult{Summary: "B"})
+
+	var got SummaryResult
+	if !cache.Get("a", &got) || got.Summary != "A" {
+		t.Fatalf("Expected a to be cached, got %+v", got)
+	}
+	cache.Set("c", SummaryResult{Summary: "C"}) // evicts b, the least recently used
+
+	if cache.Get("b", &got) {
+		t.Error("Expected b to be evicted")
+	}
+	if !cache.Get("c", &got) || got.Summary != "C" {
+		t.Errorf("Expected c to be cached, got %+v", got)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Results are copies, so mutating one does not change the cache
+	var first, second TopicResult
+	cache.Set("topics", TopicResult{Topics: []Topic{{Name: "go"}}})
+	cache.Get("topics", &first)
+	first.Topics[0].Name = "changed"
+	cache.Get("topics", &second)
+	if second.Topics[0].Name != "go" {
+		t.Error("Expected cached results to be isolated from callers")
+	}
+
+	if err := cache.Clear(); err != nil || cache.Stats().Entries != 0 {
+		t.Errorf("Expected Clear to empty the cache, got %+v (%v)", cache.Stats(), err)
+	}
+}
+
+func TestResultCacheLimits(t *testing.T) {
+	cache, _ := NewResultCache(ResultCacheOptions{MaxBytes: 40})
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprint(i), fmt.Sprintf("value-%d", i)) // 9 bytes of JSON each
+	}
+	if stats := cache.Stats(); stats.Entries != 4 || stats.Bytes > 40 {
+		t.Errorf("Expected the byte limit to hold 4 entries, got %+v", stats)
+	}
+
+	cache, _ = NewResultCache(ResultCacheOptions{TTL: 10 * time.Millisecond})
+	cache.Set("k", 1)
+	time.Sleep(20 * time.Millisecond)
+	var n int
+	if cache.Get("k", &n) {
+		t.Error("Expected the entry to expire")
+	}
+}
+
+func TestResultCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	key := ResultCacheKey("ExtractSentiment", map[string]interface{}{"accuracy": 0.7}, "good")
+	want := SentimentResult{
+		OverallSentiment: Sentiment{Polarity: 0.5, Label: "positive"},
+		Method:           "lexicon-based",
+	}
+
+	cache, err := NewResultCache(ResultCacheOptions{MaxEntries: 1, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(key, want); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	cache.Set("ff00", "evicts the first entry from memory")
+
+	var got SentimentResult
+	if !cache.Get(key, &got) || !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v from disk, got %+v", want, got)
+	}
+	if stats := cache.Stats(); stats.DiskHits != 1 {
+		t.Errorf("Expected a disk hit, got %+v", stats)
+	}
+
+	// A new cache over the same directory sees earlier results
+	reopened, _ := NewResultCache(ResultCacheOptions{Dir: dir})
+	got = SentimentResult{}
+	if !reopened.Get(key, &got) || got.Method != want.Method {
+		t.Errorf("Expected result to survive a restart, got %+v", got)
+	}
+
+	reopened.Delete(key)
+	fresh, _ := NewResultCache(ResultCacheOptions{Dir: dir})
+	if fresh.Get(key, &got) {
+		t.Error("Expected Delete to remove the disk entry")
+	}
+}
+
+func TestResultCacheConcurrency(t *testing.T) {
+	cache, _ := NewResultCache(ResultCacheOptions{MaxEntries: 8, Dir: t.TempDir()})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := ResultCacheKey("algo", nil, fmt.Sprint(i%12))
+				var v int
+				if !cache.Get(key, &v) {
+					cache.Set(key, i%12)
+				} else if ResultCacheKey("algo", nil, fmt.Sprint(v)) != key {
+					t.Errorf("Got value %d for the wrong key", v)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestDefaultResultCacheIsBounded(t *testing.T) {
+	cache := GetResultCache()
+	if cache == nil || cache.opts.MaxEntries != DefaultResultCacheEntries || cache.opts.MaxBytes != DefaultResultCacheBytes {
+		t.Fatalf("Expected the global cache to be bounded, got %+v", cache)
+	}
+}
+
+func TestAnalysisFunctionsUseResultCache(t *testing.T) {
+	previous := GetResultCache()
+	cache, _ := NewResultCache(ResultCacheOptions{})
+	SetResultCache(cache)
+	defer SetResultCache(previous)
+
+	text := "The new release is wonderful. Performance improved and users are happy with the faster search."
+
+	tests := []struct {
+		name string
+		run  func() bool
+	}{
+		{"ExtractSentiment", func() bool { return ExtractSentiment(text, 0.8).CacheUsed }},
+		{"SummarizeText", func() bool { return SummarizeText(text, 50).CacheUsed }},
+		{"ClassifyTopics", func() bool { return ClassifyTopics(text, 3).CacheUsed }},
+		{"AnalyzeTextComplexity", func() bool { return AnalyzeTextComplexity(text, 2).CacheUsed }},
+	}
+
+	for _, tt := range tests {
+		if tt.run() {
+			t.Errorf("%s: expected the first call to miss the cache", tt.name)
+		}
+		if !tt.run() {
+			t.Errorf("%s: expected the second call to hit the cache", tt.name)
+		}
+	}
+
+	if hits := cache.Stats().Hits; hits != int64(len(tests)) {
+		t.Errorf("Expected %d hits, got %d", len(tests), hits)
+	}
+	if ExtractSentiment(text, 0.95).CacheUsed {
+		t.Error("Expected different parameters to miss the cache")
+	}
+
+	first := ExtractSentiment(text, 0.7)
+	second := ExtractSentiment(text, 0.7)
+	if first.Method != second.Method || first.OverallSentiment != second.OverallSentiment {
+		t.Errorf("Expected cached result to match, got %+v and %+v", first, second)
+	}
+
+	SetResultCache(nil)
+	if ExtractSentiment(text, 0.8).CacheUsed {
+		t.Error("Expected no caching once disabled")
+	}
+}
@@ -35,23 +35,32 @@ func AnalyzeTextComplexity(text string, depth int) ComplexityReport {
 		depth = 2 // Default to balanced
 	}
 	
-	// Initialize report
-	report := ComplexityReport{
-		ReadabilityScores: make(map[string]float64),
-		AlgorithmUsed:     fmt.Sprintf("complexity-depth-%d", depth),
-	}
-	
-	// Always perform basic analysis (Depth 1)
-	performBasicAnalysis(text, &report, collector)
-	
-	// Depth 2: Add structural analysis
-	if depth >= 2 {
-		performStructuralAnalysis(text, &report, collector)
-	}
-	
-	// Depth 3: Add deep semantic analysis
-	if depth >= 3 {
-		performSemanticAnalysis(text, &report, collector)
+	var report ComplexityReport
+	cacheKey := ResultCacheKey("AnalyzeTextComplexity", map[string]interface{}{"depth": depth}, text)
+	
+	if loadCachedResult(cacheKey, &report, collector) {
+		report.CacheUsed = true
+	} else {
+		// Initialize report
+		report = ComplexityReport{
+			ReadabilityScores: make(map[string]float64),
+			AlgorithmUsed:     fmt.Sprintf("complexity-depth-%d", depth),
+		}
+		
+		// Always perform basic analysis (Depth 1)
+		performBasicAnalysis(text, &report, collector)
+		
+		// Depth 2: Add structural analysis
+		if depth >= 2 {
+			performStructuralAnalysis(text, &report, collector)
+		}
+		
+		// Depth 3: Add deep semantic analysis
+		if depth >= 3 {
+			performSemanticAnalysis(text, &report, collector)
+		}
+		
+		storeCachedResult(cacheKey, report)
 	}
 	
 	// Calculate final metrics
@@ -145,7 +154,7 @@ func performBasicAnalysis(text string, report *ComplexityReport, collector *Metr
 		avgSyllablesPerWord,
 	)
 	
-	report.ReadabilityScores["gunning-fog"] = calculateGunningFog(
+	report.ReadabilityScores["gunning-fog"] = gunningFogIndex(
 		avgWordsPerSentence,
 		float64(complexWords)/float64(totalWords),
 	)
@@ -167,7 +176,7 @@ func performStructuralAnalysis(text string, report *ComplexityReport, collector
 	}
 	
 	// Calculate syntactic complexity based on sentence variety
-	report.SyntacticComplexity = calculateSyntacticComplexity(sentenceLengths)
+	report.SyntacticComplexity = sentenceVarietyComplexity(sentenceLengths)
 	
 	// Additional readability metrics
 	words := strings.Fields(text)
@@ -220,7 +229,7 @@ func performSemanticAnalysis(text string, report *ComplexityReport, collector *M
 	}
 	
 	// Calculate semantic complexity
-	report.SemanticComplexity = calculateSemanticComplexity(
+	report.SemanticComplexity = semanticDensityComplexity(
 		typeTokenRatio,
 		float64(semanticConnections)/float64(len(words)),
 		float64(len(uniqueWords)),
@@ -253,8 +262,8 @@ func calculateLexicalComplexity(avgCharsPerWord, avgSyllablesPerWord, complexWor
 	return 0.3*charComplexity + 0.4*syllableComplexity + 0.3*complexWordRatio
 }
 
-// calculateSyntacticComplexity calculates syntactic complexity from sentence lengths
-func calculateSyntacticComplexity(sentenceLengths []int) float64 {
+// sentenceVarietyComplexity calculates syntactic complexity from sentence lengths
+func sentenceVarietyComplexity(sentenceLengths []int) float64 {
 	if len(sentenceLengths) == 0 {
 		return 0
 	}
@@ -283,8 +292,8 @@ func calculateSyntacticComplexity(sentenceLengths []int) float64 {
 	return 0.6*normalizedVariance + 0.4*normalizedLength
 }
 
-// calculateSemanticComplexity calculates semantic complexity
-func calculateSemanticComplexity(typeTokenRatio, transitionRatio, vocabularySize float64) float64 {
+// semanticDensityComplexity calculates semantic complexity
+func semanticDensityComplexity(typeTokenRatio, transitionRatio, vocabularySize float64) float64 {
 	// Normalize vocabulary size (assuming 1000 unique words is very complex)
 	normalizedVocab := math.Min(vocabularySize/1000.0, 1.0)
 	
@@ -304,8 +313,8 @@ func calculateFleschKincaid(avgWordsPerSentence, avgSyllablesPerWord float64) fl
 	return 0.39*avgWordsPerSentence + 11.8*avgSyllablesPerWord - 15.59
 }
 
-// calculateGunningFog calculates the Gunning Fog Index
-func calculateGunningFog(avgWordsPerSentence, complexWordRatio float64) float64 {
+// gunningFogIndex calculates the Gunning Fog Index
+func gunningFogIndex(avgWordsPerSentence, complexWordRatio float64) float64 {
 	return 0.4 * (avgWordsPerSentence + 100*complexWordRatio)
 }
 
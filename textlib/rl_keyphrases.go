@@ -27,6 +27,13 @@ import (
 // 11-50: Enhanced statistical methods
 // 51+: Deep NLP analysis
 func ExtractKeyPhrases(text string, maxPhrases int) []KeyPhrase {
+	return ExtractKeyPhrasesWithOptions(text, maxPhrases, NormalizationOptions{})
+}
+
+// ExtractKeyPhrasesWithOptions extracts key phrases with words normalized by
+// opts before counting, so "run", "runs" and "running" score as one term.
+// Phrases are reported in the first surface form seen in the text.
+func ExtractKeyPhrasesWithOptions(text string, maxPhrases int, opts NormalizationOptions) []KeyPhrase {
 	// Start metrics collection
 	collector := StartMetricsCollection()
 	
@@ -41,15 +48,15 @@ func ExtractKeyPhrases(text string, maxPhrases int) []KeyPhrase {
 	
 	if maxPhrases <= 10 {
 		// Fast TF-IDF approach
-		phrases = extractKeyPhrasesTFIDF(text, maxPhrases, collector)
+		phrases = extractKeyPhrasesTFIDF(text, maxPhrases, opts, collector)
 		algorithm = "tf-idf"
 	} else if maxPhrases <= 50 {
 		// Enhanced statistical methods
-		phrases = extractKeyPhrasesStatistical(text, maxPhrases, collector)
+		phrases = extractKeyPhrasesStatistical(text, maxPhrases, opts, collector)
 		algorithm = "statistical"
 	} else {
 		// Deep NLP analysis
-		phrases = extractKeyPhrasesDeep(text, maxPhrases, collector)
+		phrases = extractKeyPhrasesDeep(text, maxPhrases, opts, collector)
 		algorithm = "deep-nlp"
 	}
 	
@@ -59,6 +66,7 @@ func ExtractKeyPhrases(text string, maxPhrases int) []KeyPhrase {
 		"maxPhrases":  maxPhrases,
 		"textLength":  len(text),
 		"algorithm":   algorithm,
+		"normalize":   opts.Mode,
 	}
 	
 	// Calculate quality based on algorithm
@@ -74,26 +82,35 @@ func ExtractKeyPhrases(text string, maxPhrases int) []KeyPhrase {
 }
 
 // extractKeyPhrasesTFIDF uses TF-IDF for fast key phrase extraction
-func extractKeyPhrasesTFIDF(text string, maxPhrases int, collector *MetricsCollector) []KeyPhrase {
+func extractKeyPhrasesTFIDF(text string, maxPhrases int, opts NormalizationOptions, collector *MetricsCollector) []KeyPhrase {
 	collector.RecordProcessingTime("tfidf_start")
 	
 	// Tokenize and clean
 	words := strings.Fields(strings.ToLower(text))
-	
-	// Calculate term frequency
-	termFreq := make(map[string]float64)
 	totalWords := float64(len(words))
 	
+	var candidates []string
 	for _, word := range words {
 		cleanWord := strings.Trim(word, ".,!?;:\"'")
-		if len(cleanWord) > 2 && !isStopWord(cleanWord) {
-			termFreq[cleanWord]++
+		if len(cleanWord) > 2 && !isRLStopWord(cleanWord) {
+			candidates = append(candidates, cleanWord)
+		}
+	}
+	
+	// Calculate term frequency, grouping words by their normalized form
+	counts := make(map[string]float64)
+	surface := make(map[string]string)
+	for i, term := range NormalizeTokens(candidates, opts) {
+		counts[term]++
+		if _, exists := surface[term]; !exists {
+			surface[term] = candidates[i]
 		}
 	}
 	
 	// Normalize term frequency
-	for term := range termFreq {
-		termFreq[term] /= totalWords
+	termFreq := make(map[string]float64, len(counts))
+	for term, count := range counts {
+		termFreq[surface[term]] = count / totalWords
 	}
 	
 	// Extract phrases (unigrams and bigrams)
@@ -104,7 +121,7 @@ func extractKeyPhrasesTFIDF(text string, maxPhrases int, collector *MetricsColle
 }
 
 // extractKeyPhrasesStatistical uses enhanced statistical methods
-func extractKeyPhrasesStatistical(text string, maxPhrases int, collector *MetricsCollector) []KeyPhrase {
+func extractKeyPhrasesStatistical(text string, maxPhrases int, opts NormalizationOptions, collector *MetricsCollector) []KeyPhrase {
 	collector.RecordProcessingTime("statistical_start")
 	
 	// Get sentences for context
@@ -115,20 +132,21 @@ func extractKeyPhrasesStatistical(text string, maxPhrases int, collector *Metric
 	
 	for i, sentence := range sentences {
 		words := strings.Fields(strings.ToLower(sentence))
+		terms := NormalizeTokens(words, opts)
 		
 		// Extract n-grams
 		for n := 1; n <= 3; n++ {
 			for j := 0; j <= len(words)-n; j++ {
-				ngram := strings.Join(words[j:j+n], " ")
+				ngram := strings.Join(terms[j:j+n], " ")
 				
 				// Skip if contains stop words at edges
-				if n > 1 && (isStopWord(words[j]) || isStopWord(words[j+n-1])) {
+				if n > 1 && (isRLStopWord(words[j]) || isRLStopWord(words[j+n-1])) {
 					continue
 				}
 				
 				if _, exists := ngramStats[ngram]; !exists {
 					ngramStats[ngram] = &ngramInfo{
-						text:      ngram,
+						text:      strings.Join(words[j:j+n], " "),
 						frequency: 0,
 						positions: []int{},
 						contexts:  []string{},
@@ -159,11 +177,11 @@ func extractKeyPhrasesStatistical(text string, maxPhrases int, collector *Metric
 }
 
 // extractKeyPhrasesDeep uses deep NLP analysis
-func extractKeyPhrasesDeep(text string, maxPhrases int, collector *MetricsCollector) []KeyPhrase {
+func extractKeyPhrasesDeep(text string, maxPhrases int, opts NormalizationOptions, collector *MetricsCollector) []KeyPhrase {
 	collector.RecordProcessingTime("deep_start")
 	
 	// First get statistical phrases as a base
-	basePhrases := extractKeyPhrasesStatistical(text, maxPhrases*2, collector)
+	basePhrases := extractKeyPhrasesStatistical(text, maxPhrases*2, opts, collector)
 	
 	// Enhance with additional analysis
 	sentences := SplitIntoSentences(text)
@@ -195,8 +213,8 @@ type ngramInfo struct {
 	score     float64
 }
 
-// isStopWord checks if a word is a common stop word
-func isStopWord(word string) bool {
+// isRLStopWord checks if a word is a common stop word
+func isRLStopWord(word string) bool {
 	stopWords := map[string]bool{
 		"the": true, "is": true, "at": true, "which": true, "on": true,
 		"a": true, "an": true, "and": true, "or": true, "but": true,
@@ -238,7 +256,7 @@ func extractPhrasesFromFrequencies(text string, termFreq map[string]float64, max
 			Score:      scored[i].score,
 			Position:   RLPosition{Start: pos, End: pos + len(scored[i].term)},
 			Category:   "term",
-			Context:    extractContext(text, pos, 20),
+			Context:    extractPhraseContext(text, pos, 20),
 			Confidence: 0.7 + scored[i].score*0.3,
 		}
 		phrases = append(phrases, phrase)
@@ -338,7 +356,7 @@ func extractPatternBasedPhrases(sentences []string) []KeyPhrase {
 			check: func(words []string) (string, bool) {
 				// Simple pattern: adjective + noun
 				if len(words) >= 2 {
-					if isAdjective(words[0]) && isNoun(words[1]) {
+					if isAdjective(words[0]) && isRLNoun(words[1]) {
 						return words[0] + " " + words[1], true
 					}
 				}
@@ -384,7 +402,7 @@ func isAdjective(word string) bool {
 	return false
 }
 
-func isNoun(word string) bool {
+func isRLNoun(word string) bool {
 	// Common noun endings
 	nounEndings := []string{"tion", "ment", "ness", "ity", "er", "or", "ism", "ist"}
 	for _, ending := range nounEndings {
@@ -393,7 +411,7 @@ func isNoun(word string) bool {
 		}
 	}
 	// Also check if it's not a stop word and longer than 3 chars
-	return len(word) > 3 && !isStopWord(word)
+	return len(word) > 3 && !isRLStopWord(word)
 }
 
 // mergePhrases merges two sets of phrases, removing duplicates
@@ -508,8 +526,8 @@ func selectDiversePhrases(phrases []KeyPhrase, maxPhrases int) []KeyPhrase {
 	return selected
 }
 
-// extractContext extracts context around a position
-func extractContext(text string, position, contextSize int) string {
+// extractPhraseContext extracts context around a position
+func extractPhraseContext(text string, position, contextSize int) string {
 	if position < 0 {
 		return ""
 	}
@@ -534,18 +552,3 @@ func extractContext(text string, position, contextSize int) string {
 	
 	return strings.TrimSpace(context)
 }
-
-// Helper functions
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
\ No newline at end of file
@@ -167,7 +167,7 @@ func TestKeyPhraseRanking(t *testing.T) {
 	}
 }
 
-func TestAlgorithmSelection(t *testing.T) {
+func TestKeyPhraseAlgorithmSelection(t *testing.T) {
 	text := "Test text for algorithm selection based on maxPhrases parameter"
 	
 	// Test TF-IDF selection
@@ -197,7 +197,7 @@ func TestStopWordFiltering(t *testing.T) {
 	// Check that stop words are not included as standalone phrases
 	for _, phrase := range phrases {
 		singleWord := !strings.Contains(phrase.Text, " ")
-		if singleWord && isStopWord(phrase.Text) {
+		if singleWord && isRLStopWord(phrase.Text) {
 			t.Errorf("Stop word '%s' included as key phrase", phrase.Text)
 		}
 	}
@@ -248,7 +248,7 @@ func TestPhraseDiversity(t *testing.T) {
 	}
 }
 
-func TestLongTextPerformance(t *testing.T) {
+func TestKeyPhraseLongTextPerformance(t *testing.T) {
 	// Generate a long document
 	sentences := []string{
 		"Artificial intelligence transforms business operations.",
@@ -305,7 +305,7 @@ func TestContextExtraction(t *testing.T) {
 		{35, 10, "lazy dog"},  // Near end position
 	}
 	for _, tc := range testCases {
-		context := extractContext(text, tc.position, tc.contextSize)
+		context := extractPhraseContext(text, tc.position, tc.contextSize)
 		if !strings.Contains(context, strings.TrimPrefix(strings.TrimSuffix(tc.expected, "..."), "...")) {
 			t.Errorf("Context extraction failed: got %q, expected to contain %q", context, tc.expected)
 		}
@@ -337,4 +337,26 @@ func BenchmarkExtractKeyPhrasesDeep(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		ExtractKeyPhrases(text, 60)
 	}
-}
\ No newline at end of file
+}
+func TestExtractKeyPhrasesWithOptions(t *testing.T) {
+	text := "The model runs nightly. Each run takes an hour. Running the model is cheap."
+
+	phrases := ExtractKeyPhrasesWithOptions(text, 5, NormalizationOptions{Mode: "stem"})
+	if len(phrases) == 0 {
+		t.Fatal("Expected key phrases")
+	}
+
+	// "runs", "run" and "running" collapse into one term reported as "runs"
+	count := 0
+	for _, phrase := range phrases {
+		if phrase.Text == "run" || phrase.Text == "running" {
+			t.Errorf("Expected inflections to be merged, found %q", phrase.Text)
+		}
+		if phrase.Text == "runs" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected a single merged phrase for 'runs', got %d", count)
+	}
+}
@@ -82,6 +82,13 @@ type languageProfile struct {
 	TypicalChars string
 }
 
+func init() {
+	// Resolve NormalizationOptions{Language: "auto"} with the heuristic detector
+	SetLanguageDetector(func(text string) string {
+		return DetectLanguage(text, 0.5).Language
+	})
+}
+
 // DetectLanguage detects language with configurable confidence threshold
 // confidence: 0.5 = fast heuristics, 0.95 = comprehensive analysis
 func DetectLanguage(text string, confidence float64) LanguageResult {
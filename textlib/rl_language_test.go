@@ -347,7 +347,7 @@ func TestEdgeCases(t *testing.T) {
 	}
 }
 
-func TestLongTextPerformance(t *testing.T) {
+func TestLanguageLongTextPerformance(t *testing.T) {
 	// Generate long texts in different languages
 	texts := map[string]string{
 		"en": strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100),
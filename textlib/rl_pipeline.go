@@ -0,0 +1,817 @@
+// Copyright 2025 Caia Tech
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textlib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PipelineSpec declares a DAG of registered algorithms. Values that start
+// with "$" are references: "$inputs.<name>" or "$steps.<id>", optionally
+// followed by a dotted path into the value (map keys, struct fields or JSON
+// tags, list indices). "$$" escapes a literal dollar sign.
+type PipelineSpec struct {
+	Name        string            `json:"name"`
+	Inputs      map[string]string `json:"inputs"`  // Input name to type
+	Outputs     map[string]string `json:"outputs"` // Output name to reference
+	Steps       []PipelineStep    `json:"steps"`
+	MaxParallel int               `json:"max_parallel"` // Steps run at once (default GOMAXPROCS)
+}
+
+// PipelineStep runs one registered algorithm
+type PipelineStep struct {
+	ID         string                 `json:"id"`
+	Algorithm  string                 `json:"algorithm"`
+	Input      string                 `json:"input"`       // Reference passed to Process
+	Params     map[string]interface{} `json:"params"`      // May contain references
+	InputType  string                 `json:"input_type"`  // "string", "number", "bool", "list", "map" or "any" (default)
+	OutputType string                 `json:"output_type"` // Same types as InputType
+	When       string                 `json:"when"`        // Condition; the step is skipped when false
+	DependsOn  []string               `json:"depends_on"`  // Ordering beyond the references
+	Optional   bool                   `json:"optional"`    // A failure skips dependents instead of stopping the run
+}
+
+// Step statuses in a PipelineResult
+const (
+	StepSucceeded = "succeeded"
+	StepSkipped   = "skipped"
+	StepFailed    = "failed"
+)
+
+// StepResult is the outcome of one step
+type StepResult struct {
+	Status  string            `json:"status"`
+	Output  interface{}       `json:"output,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Metrics ProcessingMetrics `json:"metrics"`
+}
+
+// PipelineResult is the outcome of a pipeline run
+type PipelineResult struct {
+	Outputs map[string]interface{} `json:"outputs"`
+	Steps   map[string]StepResult  `json:"steps"`
+	Metrics ProcessingMetrics      `json:"metrics"`
+}
+
+// Pipeline is a validated PipelineSpec bound to registry algorithms
+type Pipeline struct {
+	spec       PipelineSpec
+	algorithms map[string]Algorithm
+	deps       map[string][]string
+	order      []string // Step IDs in a topological order
+}
+
+var pipelineTypes = map[string]bool{"": true, "any": true, "string": true, "number": true, "bool": true, "list": true, "map": true}
+
+// ParsePipeline parses a pipeline spec written in JSON or YAML and
+// validates it against the algorithm registry
+func ParsePipeline(data []byte) (*Pipeline, error) {
+	var spec PipelineSpec
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("invalid pipeline JSON: %w", err)
+		}
+		return NewPipeline(spec)
+	}
+
+	doc, err := parseYAMLSubset(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline YAML: %w", err)
+	}
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &spec); err != nil {
+		return nil, fmt.Errorf("invalid pipeline YAML: %w", err)
+	}
+	return NewPipeline(spec)
+}
+
+// LoadPipeline reads a pipeline spec from a .json, .yaml or .yml file
+func LoadPipeline(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePipeline(data)
+}
+
+// NewPipeline validates spec: step IDs must be unique, algorithms
+// registered, references resolvable, declared types compatible and the
+// dependency graph acyclic
+func NewPipeline(spec PipelineSpec) (*Pipeline, error) {
+	if len(spec.Steps) == 0 {
+		return nil, fmt.Errorf("pipeline %q has no steps", spec.Name)
+	}
+	for name, typ := range spec.Inputs {
+		if !pipelineTypes[typ] {
+			return nil, fmt.Errorf("input %s: unknown type %q", name, typ)
+		}
+	}
+
+	p := &Pipeline{
+		spec:       spec,
+		algorithms: make(map[string]Algorithm),
+		deps:       make(map[string][]string),
+	}
+	steps := make(map[string]*PipelineStep)
+	for i := range spec.Steps {
+		step := &spec.Steps[i]
+		if step.ID == "" {
+			return nil, fmt.Errorf("step %d has no id", i+1)
+		}
+		if _, dup := steps[step.ID]; dup {
+			return nil, fmt.Errorf("duplicate step id %s", step.ID)
+		}
+		if !pipelineTypes[step.InputType] || !pipelineTypes[step.OutputType] {
+			return nil, fmt.Errorf("step %s: unknown type", step.ID)
+		}
+		algo, err := GetAlgorithm(step.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		p.algorithms[step.ID] = algo
+		steps[step.ID] = step
+	}
+
+	for _, step := range spec.Steps {
+		refs := []string{}
+		if step.Input != "" {
+			if !isPipelineRef(step.Input) {
+				return nil, fmt.Errorf("step %s: input %q is not a reference", step.ID, step.Input)
+			}
+			refs = append(refs, step.Input)
+		}
+		refs = append(refs, collectPipelineRefs(step.Params)...)
+		if step.When != "" {
+			cond, err := parsePipelineCondition(step.When)
+			if err != nil {
+				return nil, fmt.Errorf("step %s: %w", step.ID, err)
+			}
+			refs = append(refs, cond.refs()...)
+		}
+
+		deps := map[string]bool{}
+		for _, d := range step.DependsOn {
+			if _, ok := steps[d]; !ok {
+				return nil, fmt.Errorf("step %s depends on unknown step %s", step.ID, d)
+			}
+			deps[d] = true
+		}
+		for _, ref := range refs {
+			scope, name, _ := splitPipelineRef(ref)
+			switch scope {
+			case "inputs":
+				if _, ok := spec.Inputs[name]; !ok {
+					return nil, fmt.Errorf("step %s: unknown input %s", step.ID, name)
+				}
+			case "steps":
+				if _, ok := steps[name]; !ok {
+					return nil, fmt.Errorf("step %s: unknown step %s", step.ID, name)
+				}
+				deps[name] = true
+			default:
+				return nil, fmt.Errorf("step %s: invalid reference %s", step.ID, ref)
+			}
+		}
+		if deps[step.ID] {
+			return nil, fmt.Errorf("step %s depends on itself", step.ID)
+		}
+		for d := range deps {
+			p.deps[step.ID] = append(p.deps[step.ID], d)
+		}
+		sort.Strings(p.deps[step.ID])
+
+		// Whole-value references can be type checked up front
+		if step.Input != "" && step.InputType != "" && step.InputType != "any" {
+			scope, name, path := splitPipelineRef(step.Input)
+			produced := ""
+			if path == "" && scope == "inputs" {
+				produced = spec.Inputs[name]
+			} else if path == "" && scope == "steps" {
+				produced = steps[name].OutputType
+			}
+			if produced != "" && produced != "any" && produced != step.InputType {
+				return nil, fmt.Errorf("step %s expects %s input but %s produces %s", step.ID, step.InputType, step.Input, produced)
+			}
+		}
+
+		if static := collectPipelineRefs(step.Params); len(static) == 0 {
+			if err := p.algorithms[step.ID].ValidateParams(step.Params); err != nil {
+				return nil, fmt.Errorf("step %s: %w", step.ID, err)
+			}
+		}
+	}
+
+	for name, ref := range spec.Outputs {
+		scope, id, _ := splitPipelineRef(ref)
+		if _, ok := steps[id]; scope == "steps" && ok {
+			continue
+		}
+		if _, ok := spec.Inputs[id]; scope == "inputs" && ok {
+			continue
+		}
+		return nil, fmt.Errorf("output %s: invalid reference %s", name, ref)
+	}
+
+	order, err := topologicalOrder(spec.Steps, p.deps)
+	if err != nil {
+		return nil, err
+	}
+	p.order = order
+	return p, nil
+}
+
+// topologicalOrder sorts steps so that each follows its dependencies,
+// keeping declaration order where possible
+func topologicalOrder(steps []PipelineStep, deps map[string][]string) ([]string, error) {
+	state := map[string]int{} // 1 visiting, 2 done
+	var order []string
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case 1:
+			return fmt.Errorf("pipeline has a cycle: %s", strings.Join(append(path, id), " -> "))
+		case 2:
+			return nil
+		}
+		state[id] = 1
+		for _, d := range deps[id] {
+			if err := visit(d, append(path, id)); err != nil {
+				return err
+			}
+		}
+		state[id] = 2
+		order = append(order, id)
+		return nil
+	}
+	for _, step := range steps {
+		if err := visit(step.ID, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Steps returns the step IDs in execution order
+func (p *Pipeline) Steps() []string {
+	return append([]string(nil), p.order...)
+}
+
+// Run executes the pipeline. Independent steps run in parallel; a step
+// starts once every step it references has finished. A failing step stops
+// the run unless it is optional, and steps that depend on a skipped or
+// failed step are skipped.
+func (p *Pipeline) Run(ctx context.Context, inputs map[string]interface{}) (*PipelineResult, error) {
+	for name, typ := range p.spec.Inputs {
+		value, ok := inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("missing pipeline input %s", name)
+		}
+		if !matchesPipelineType(value, typ) {
+			return nil, fmt.Errorf("input %s: expected %s, got %T", name, typ, value)
+		}
+	}
+
+	collector := StartMetricsCollection()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := &PipelineResult{
+		Outputs: make(map[string]interface{}),
+		Steps:   make(map[string]StepResult),
+	}
+	steps := make(map[string]PipelineStep)
+	for _, step := range p.spec.Steps {
+		steps[step.ID] = step
+	}
+
+	parallel := p.spec.MaxParallel
+	if parallel <= 0 {
+		parallel = runtime.GOMAXPROCS(0)
+	}
+
+	type finished struct {
+		id     string
+		result StepResult
+	}
+	done := make(chan finished)
+	running := 0
+	pending := p.order
+	var runErr error
+
+	for {
+		// One pass in topological order settles every step whose
+		// dependencies have finished; only this goroutine touches
+		// result.Steps
+		var waiting []string
+		for _, id := range pending {
+			ready, skip := true, false
+			for _, d := range p.deps[id] {
+				r, ok := result.Steps[d]
+				if !ok {
+					ready = false
+					break
+				}
+				if r.Status != StepSucceeded {
+					skip = true
+				}
+			}
+
+			switch {
+			case !ready:
+				waiting = append(waiting, id)
+			case runErr != nil:
+				result.Steps[id] = StepResult{Status: StepSkipped, Error: "pipeline stopped"}
+			case skip:
+				result.Steps[id] = StepResult{Status: StepSkipped}
+			case running >= parallel:
+				waiting = append(waiting, id)
+			default:
+				step := steps[id]
+				input, params, run, err := p.prepareStep(step, pipelineEnv{inputs: inputs, steps: result.Steps})
+				if err != nil {
+					result.Steps[id] = StepResult{Status: StepFailed, Error: err.Error()}
+					if !step.Optional {
+						runErr = fmt.Errorf("step %s failed: %v", id, err)
+						cancel()
+					}
+					continue
+				}
+				if !run {
+					result.Steps[id] = StepResult{Status: StepSkipped}
+					continue
+				}
+				running++
+				go func() {
+					done <- finished{step.ID, p.runStep(ctx, step, input, params)}
+				}()
+			}
+		}
+		pending = waiting
+
+		if running == 0 {
+			break
+		}
+
+		f := <-done
+		running--
+		collector.IncrementAlgorithmSteps()
+		result.Steps[f.id] = f.result
+		if f.result.Status == StepFailed && !steps[f.id].Optional && runErr == nil {
+			runErr = fmt.Errorf("step %s failed: %s", f.id, f.result.Error)
+			cancel()
+		}
+	}
+
+	env := pipelineEnv{inputs: inputs, steps: result.Steps}
+	for name, ref := range p.spec.Outputs {
+		value, err := env.resolve(ref)
+		if err == nil {
+			result.Outputs[name] = value
+		}
+	}
+	result.Metrics = collector.GetMetrics()
+
+	if runErr == nil {
+		runErr = ctx.Err()
+	}
+	return result, runErr
+}
+
+// prepareStep resolves a step's input and parameters and evaluates its
+// condition
+func (p *Pipeline) prepareStep(step PipelineStep, env pipelineEnv) (interface{}, map[string]interface{}, bool, error) {
+	if step.When != "" {
+		cond, err := parsePipelineCondition(step.When)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		ok, err := cond.eval(env)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("condition: %w", err)
+		}
+		if !ok {
+			return nil, nil, false, nil
+		}
+	}
+
+	var input interface{}
+	if step.Input != "" {
+		value, err := env.resolve(step.Input)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		input = value
+	}
+	if !matchesPipelineType(input, step.InputType) {
+		return nil, nil, false, fmt.Errorf("expected %s input, got %T", step.InputType, input)
+	}
+
+	params, err := env.resolveValue(step.Params)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	paramMap, _ := params.(map[string]interface{})
+	if paramMap == nil {
+		paramMap = make(map[string]interface{})
+	}
+	return input, paramMap, true, nil
+}
+
+// runStep executes one algorithm with its own metrics collector
+func (p *Pipeline) runStep(ctx context.Context, step PipelineStep, input interface{}, params map[string]interface{}) (r StepResult) {
+	if err := ctx.Err(); err != nil {
+		return StepResult{Status: StepSkipped, Error: err.Error()}
+	}
+
+	collector := StartMetricsCollection()
+	defer func() {
+		if rec := recover(); rec != nil {
+			r = StepResult{Status: StepFailed, Error: fmt.Sprintf("panic: %v", rec), Metrics: collector.GetMetrics()}
+		}
+	}()
+
+	algo := p.algorithms[step.ID]
+	if err := algo.ValidateParams(params); err != nil {
+		return StepResult{Status: StepFailed, Error: err.Error()}
+	}
+
+	output, err := algo.Process(input, params)
+	collector.IncrementAlgorithmSteps()
+	collector.RecordProcessingTime(step.ID)
+	metrics := collector.GetMetrics()
+	RecordFunctionCall(step.Algorithm, params, metrics, nil)
+
+	if err != nil {
+		return StepResult{Status: StepFailed, Error: err.Error(), Metrics: metrics}
+	}
+	if !matchesPipelineType(output, step.OutputType) {
+		return StepResult{Status: StepFailed, Error: fmt.Sprintf("expected %s output, got %T", step.OutputType, output), Metrics: metrics}
+	}
+	return StepResult{Status: StepSucceeded, Output: output, Metrics: metrics}
+}
+
+// matchesPipelineType reports whether value has the declared type
+func matchesPipelineType(value interface{}, typ string) bool {
+	if typ == "" || typ == "any" {
+		return true
+	}
+	if value == nil {
+		return false
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	switch typ {
+	case "string":
+		return v.Kind() == reflect.String
+	case "bool":
+		return v.Kind() == reflect.Bool
+	case "number":
+		_, ok := pipelineNumber(v.Interface())
+		return ok
+	case "list":
+		return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+	case "map":
+		return v.Kind() == reflect.Map || v.Kind() == reflect.Struct
+	}
+	return false
+}
+
+func pipelineNumber(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func isPipelineRef(s string) bool {
+	return strings.HasPrefix(s, "$") && !strings.HasPrefix(s, "$$")
+}
+
+// splitPipelineRef splits "$steps.id.a.b" into "steps", "id" and "a.b"
+func splitPipelineRef(ref string) (scope, name, path string) {
+	parts := strings.SplitN(strings.TrimPrefix(ref, "$"), ".", 3)
+	scope = parts[0]
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 2 {
+		path = parts[2]
+	}
+	return scope, name, path
+}
+
+// collectPipelineRefs finds the references in a parameter value
+func collectPipelineRefs(value interface{}) []string {
+	var refs []string
+	switch v := value.(type) {
+	case string:
+		if isPipelineRef(v) {
+			refs = append(refs, v)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			refs = append(refs, collectPipelineRefs(item)...)
+		}
+	case []interface{}:
+		for _, item := range v {
+			refs = append(refs, collectPipelineRefs(item)...)
+		}
+	}
+	return refs
+}
+
+// pipelineEnv resolves references against the inputs and finished steps
+type pipelineEnv struct {
+	inputs map[string]interface{}
+	steps  map[string]StepResult
+}
+
+func (env pipelineEnv) resolve(ref string) (interface{}, error) {
+	scope, name, path := splitPipelineRef(ref)
+
+	var value interface{}
+	switch scope {
+	case "inputs":
+		v, ok := env.inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown input %s", name)
+		}
+		value = v
+	case "steps":
+		r, ok := env.steps[name]
+		if !ok || r.Status != StepSucceeded {
+			return nil, fmt.Errorf("step %s has no output", name)
+		}
+		value = r.Output
+	default:
+		return nil, fmt.Errorf("invalid reference %s", ref)
+	}
+
+	if path == "" {
+		return value, nil
+	}
+	for _, field := range strings.Split(path, ".") {
+		next, err := pipelineField(value, field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		value = next
+	}
+	return value, nil
+}
+
+// resolveValue copies a parameter value with its references resolved
+func (env pipelineEnv) resolveValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, "$$") {
+			return v[1:], nil
+		}
+		if isPipelineRef(v) {
+			return env.resolve(v)
+		}
+		return v, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			resolved, err := env.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := env.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// pipelineField looks up a map key, struct field (by name or JSON tag) or
+// list index
+func pipelineField(value interface{}, field string) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, fmt.Errorf("field %s of nil", field)
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		item := v.MapIndex(reflect.ValueOf(field).Convert(v.Type().Key()))
+		if !item.IsValid() {
+			return nil, fmt.Errorf("no key %s", field)
+		}
+		return item.Interface(), nil
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if f.IsExported() && (f.Name == field || tag == field) {
+				return v.Field(i).Interface(), nil
+			}
+		}
+		return nil, fmt.Errorf("no field %s", field)
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 0 || i >= v.Len() {
+			return nil, fmt.Errorf("index %s out of range", field)
+		}
+		return v.Index(i).Interface(), nil
+	}
+	return nil, fmt.Errorf("cannot look up %s in %T", field, value)
+}
+
+// pipelineCondition is a disjunction of conjunctions of comparisons, such
+// as "$steps.lang.code == 'en' && $steps.stats.words > 100 || $inputs.force"
+type pipelineCondition [][]pipelineComparison
+
+type pipelineComparison struct {
+	negate      bool
+	left, right string
+	op          string // "" tests the left operand for truth
+}
+
+var pipelineOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+func parsePipelineCondition(expr string) (pipelineCondition, error) {
+	var cond pipelineCondition
+	for _, disjunct := range strings.Split(expr, "||") {
+		var terms []pipelineComparison
+		for _, term := range strings.Split(disjunct, "&&") {
+			term = strings.TrimSpace(term)
+			if term == "" {
+				return nil, fmt.Errorf("empty term in condition %q", expr)
+			}
+			c := pipelineComparison{left: term}
+			for _, op := range pipelineOperators {
+				if i := strings.Index(term, op); i >= 0 {
+					c = pipelineComparison{left: strings.TrimSpace(term[:i]), op: op, right: strings.TrimSpace(term[i+len(op):])}
+					break
+				}
+			}
+			if c.op == "" && strings.HasPrefix(c.left, "!") {
+				c.negate = true
+				c.left = strings.TrimSpace(c.left[1:])
+			}
+			if c.left == "" || (c.op != "" && c.right == "") {
+				return nil, fmt.Errorf("incomplete comparison %q", term)
+			}
+			terms = append(terms, c)
+		}
+		cond = append(cond, terms)
+	}
+	return cond, nil
+}
+
+func (cond pipelineCondition) refs() []string {
+	var refs []string
+	for _, terms := range cond {
+		for _, c := range terms {
+			for _, operand := range []string{c.left, c.right} {
+				if isPipelineRef(operand) {
+					refs = append(refs, operand)
+				}
+			}
+		}
+	}
+	return refs
+}
+
+func (cond pipelineCondition) eval(env pipelineEnv) (bool, error) {
+	for _, terms := range cond {
+		all := true
+		for _, c := range terms {
+			ok, err := c.eval(env)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c pipelineComparison) eval(env pipelineEnv) (bool, error) {
+	operand := func(s string) (interface{}, error) {
+		if isPipelineRef(s) {
+			return env.resolve(s)
+		}
+		return yamlPlainScalar(s)
+	}
+
+	left, err := operand(c.left)
+	if err != nil {
+		return false, err
+	}
+	if c.op == "" {
+		return pipelineTruthy(left) != c.negate, nil
+	}
+	right, err := operand(c.right)
+	if err != nil {
+		return false, err
+	}
+
+	ln, lok := pipelineNumber(left)
+	rn, rok := pipelineNumber(right)
+	if lok && rok {
+		switch c.op {
+		case "==":
+			return ln == rn, nil
+		case "!=":
+			return ln != rn, nil
+		case ">=":
+			return ln >= rn, nil
+		case "<=":
+			return ln <= rn, nil
+		case ">":
+			return ln > rn, nil
+		case "<":
+			return ln < rn, nil
+		}
+	}
+
+	switch c.op {
+	case "==":
+		return fmt.Sprint(left) == fmt.Sprint(right), nil
+	case "!=":
+		return fmt.Sprint(left) != fmt.Sprint(right), nil
+	}
+	return false, fmt.Errorf("cannot compare %T %s %T", left, c.op, right)
+}
+
+// pipelineTruthy treats nil, false, zero, empty strings and empty
+// collections as false
+func pipelineTruthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if n, ok := pipelineNumber(value); ok {
+		return n != 0
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil()
+	}
+	return true
+}
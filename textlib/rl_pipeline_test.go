@@ -0,0 +1,321 @@
+// Copyright 2025 Caia Tech
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textlib
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var registerPipelineTestAlgorithms sync.Once
+
+// Tracks how many "test-slow" steps run at once
+var slowRunning, slowPeak int32
+
+func setupPipelineTestAlgorithms() {
+	registerPipelineTestAlgorithms.Do(func() {
+		add := func(name string, fn func(interface{}, map[string]interface{}) (interface{}, error)) {
+			RegisterAlgorithm(name, NewBaseAlgorithm(name, name, fn, ComplexityEstimate{TimeComplexity: "O(n)"}), AlgorithmMetadata{Category: "test"})
+		}
+
+		add("test-words", func(input interface{}, params map[string]interface{}) (interface{}, error) {
+			words := strings.Fields(input.(string))
+			return map[string]interface{}{"count": len(words), "words": words}, nil
+		})
+		add("test-upper", func(input interface{}, params map[string]interface{}) (interface{}, error) {
+			return strings.ToUpper(input.(string)), nil
+		})
+		add("test-join", func(input interface{}, params map[string]interface{}) (interface{}, error) {
+			return fmt.Sprintf("%v%v%v", params["prefix"], params["sep"], params["value"]), nil
+		})
+		add("test-fail", func(input interface{}, params map[string]interface{}) (interface{}, error) {
+			return nil, fmt.Errorf("deliberate failure")
+		})
+		add("test-slow", func(input interface{}, params map[string]interface{}) (interface{}, error) {
+			n := atomic.AddInt32(&slowRunning, 1)
+			for {
+				peak := atomic.LoadInt32(&slowPeak)
+				if n <= peak || atomic.CompareAndSwapInt32(&slowPeak, peak, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&slowRunning, -1)
+			return input, nil
+		})
+	})
+}
+
+const testPipelineYAML = `
+name: word-report
+inputs:
+  text: string
+outputs:
+  count: $steps.words.count
+  shout: $steps.shout
+  summary: $steps.summary
+steps:
+  - id: words
+    algorithm: test-words
+    input: $inputs.text
+    input_type: string
+    output_type: map
+  - id: shout      # only for long texts
+    algorithm: test-upper
+    input: $inputs.text
+    input_type: string
+    when: $steps.words.count > 3
+  - id: summary
+    algorithm: test-join
+    params:
+      prefix: "words"
+      sep: '='
+      value: $steps.words.count
+`
+
+func TestPipelineYAML(t *testing.T) {
+	setupPipelineTestAlgorithms()
+
+	p, err := ParsePipeline([]byte(testPipelineYAML))
+	if err != nil {
+		t.Fatalf("Failed to parse pipeline: %v", err)
+	}
+	if got := p.Steps(); got[0] != "words" {
+		t.Errorf("Expected words to run first, got %v", got)
+	}
+
+	tests := []struct {
+		text      string
+		shout     interface{}
+		shoutStep string
+	}{
+		{"one two three four five", "ONE TWO THREE FOUR FIVE", StepSucceeded},
+		{"too short", nil, StepSkipped},
+	}
+
+	for _, tt := range tests {
+		result, err := p.Run(context.Background(), map[string]interface{}{"text": tt.text})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.Outputs["shout"] != tt.shout {
+			t.Errorf("%q: expected shout %v, got %v", tt.text, tt.shout, result.Outputs["shout"])
+		}
+		if status := result.Steps["shout"].Status; status != tt.shoutStep {
+			t.Errorf("%q: expected shout step %s, got %s", tt.text, tt.shoutStep, status)
+		}
+		count := len(strings.Fields(tt.text))
+		if result.Outputs["count"] != count || result.Outputs["summary"] != fmt.Sprintf("words=%d", count) {
+			t.Errorf("%q: unexpected outputs %v", tt.text, result.Outputs)
+		}
+		if result.Steps["words"].Metrics.AlgorithmSteps != 1 {
+			t.Errorf("Expected per-step metrics, got %+v", result.Steps["words"].Metrics)
+		}
+	}
+}
+
+func TestPipelineJSONParallel(t *testing.T) {
+	setupPipelineTestAlgorithms()
+
+	spec := `{
+		"name": "fan-out",
+		"inputs": {"text": "string"},
+		"max_parallel": 2,
+		"steps": [
+			{"id": "a", "algorithm": "test-slow", "input": "$inputs.text"},
+			{"id": "b", "algorithm": "test-slow", "input": "$inputs.text"},
+			{"id": "c", "algorithm": "test-slow", "input": "$inputs.text"},
+			{"id": "d", "algorithm": "test-join", "params": {"prefix": "$steps.a", "sep": "+", "value": "$steps.c"}}
+		],
+		"outputs": {"joined": "$steps.d"}
+	}`
+	p, err := ParsePipeline([]byte(spec))
+	if err != nil {
+		t.Fatalf("Failed to parse pipeline: %v", err)
+	}
+
+	atomic.StoreInt32(&slowPeak, 0)
+	result, err := p.Run(context.Background(), map[string]interface{}{"text": "x"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Outputs["joined"] != "x+x" {
+		t.Errorf("Expected joined output x+x, got %v", result.Outputs["joined"])
+	}
+	if peak := atomic.LoadInt32(&slowPeak); peak != 2 {
+		t.Errorf("Expected 2 independent steps at once, got %d", peak)
+	}
+}
+
+func TestPipelineFailures(t *testing.T) {
+	setupPipelineTestAlgorithms()
+
+	t.Run("required step", func(t *testing.T) {
+		p, err := NewPipeline(PipelineSpec{
+			Inputs: map[string]string{"text": "string"},
+			Steps: []PipelineStep{
+				{ID: "bad", Algorithm: "test-fail", Input: "$inputs.text"},
+				{ID: "after", Algorithm: "test-upper", Input: "$steps.bad"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to build pipeline: %v", err)
+		}
+		result, err := p.Run(context.Background(), map[string]interface{}{"text": "x"})
+		if err == nil || !strings.Contains(err.Error(), "deliberate failure") {
+			t.Errorf("Expected the step error, got %v", err)
+		}
+		if result.Steps["bad"].Status != StepFailed || result.Steps["after"].Status != StepSkipped {
+			t.Errorf("Unexpected step statuses %+v", result.Steps)
+		}
+	})
+
+	t.Run("optional step", func(t *testing.T) {
+		p, err := NewPipeline(PipelineSpec{
+			Inputs: map[string]string{"text": "string"},
+			Steps: []PipelineStep{
+				{ID: "bad", Algorithm: "test-fail", Input: "$inputs.text", Optional: true},
+				{ID: "after", Algorithm: "test-upper", Input: "$steps.bad"},
+				{ID: "other", Algorithm: "test-upper", Input: "$inputs.text"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to build pipeline: %v", err)
+		}
+		result, err := p.Run(context.Background(), map[string]interface{}{"text": "x"})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if result.Steps["after"].Status != StepSkipped || result.Steps["other"].Output != "X" {
+			t.Errorf("Unexpected step results %+v", result.Steps)
+		}
+	})
+
+	t.Run("output type", func(t *testing.T) {
+		p, err := NewPipeline(PipelineSpec{
+			Inputs: map[string]string{"text": "string"},
+			Steps:  []PipelineStep{{ID: "up", Algorithm: "test-upper", Input: "$inputs.text", OutputType: "number"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to build pipeline: %v", err)
+		}
+		if _, err := p.Run(context.Background(), map[string]interface{}{"text": "x"}); err == nil {
+			t.Error("Expected an output type error")
+		}
+		if _, err := p.Run(context.Background(), map[string]interface{}{"text": 5}); err == nil {
+			t.Error("Expected an input type error")
+		}
+	})
+}
+
+func TestPipelineValidation(t *testing.T) {
+	setupPipelineTestAlgorithms()
+
+	tests := []struct {
+		name  string
+		spec  PipelineSpec
+		error string
+	}{
+		{"no steps", PipelineSpec{}, "no steps"},
+		{"unknown algorithm", PipelineSpec{Steps: []PipelineStep{{ID: "a", Algorithm: "missing"}}}, "not found"},
+		{"duplicate id", PipelineSpec{Steps: []PipelineStep{{ID: "a", Algorithm: "test-upper"}, {ID: "a", Algorithm: "test-upper"}}}, "duplicate"},
+		{"unknown input", PipelineSpec{Steps: []PipelineStep{{ID: "a", Algorithm: "test-upper", Input: "$inputs.nope"}}}, "unknown input"},
+		{
+			"cycle",
+			PipelineSpec{Steps: []PipelineStep{
+				{ID: "a", Algorithm: "test-upper", Input: "$steps.b"},
+				{ID: "b", Algorithm: "test-upper", Input: "$steps.a"},
+			}},
+			"cycle",
+		},
+		{
+			"type mismatch",
+			PipelineSpec{Steps: []PipelineStep{
+				{ID: "a", Algorithm: "test-words", OutputType: "map"},
+				{ID: "b", Algorithm: "test-upper", Input: "$steps.a", InputType: "string"},
+			}},
+			"expects string",
+		},
+		{"bad condition", PipelineSpec{Steps: []PipelineStep{{ID: "a", Algorithm: "test-upper", When: "$inputs.x >"}}}, "incomplete"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPipeline(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestParseYAMLSubset(t *testing.T) {
+	doc := `
+# comment
+name: "quoted # not a comment"
+plain: hello world  # trailing comment
+number: 3.5
+flag: true
+empty:
+list:
+- a
+- 'it''s'
+nested:
+  flow: [1, two, {k: v}]
+  items:
+    - id: x
+      tags: [p, q]
+    - id: y
+text: |
+  line one
+  line two
+`
+	got, err := parseYAMLSubset(doc)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"name":   "quoted # not a comment",
+		"plain":  "hello world",
+		"number": 3.5,
+		"flag":   true,
+		"empty":  nil,
+		"list":   []interface{}{"a", "it's"},
+		"nested": map[string]interface{}{
+			"flow": []interface{}{1.0, "two", map[string]interface{}{"k": "v"}},
+			"items": []interface{}{
+				map[string]interface{}{"id": "x", "tags": []interface{}{"p", "q"}},
+				map[string]interface{}{"id": "y"},
+			},
+		},
+		"text": "line one\nline two\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %#v, got %#v", want, got)
+	}
+
+	for _, bad := range []string{"a: 1\n  b: 2\n", "a: [1, 2\n", "a: 1\na: 2\n", "\tkey: v\n"} {
+		if _, err := parseYAMLSubset(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+// Copyright 2025 Caia Tech
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a significant line of a YAML document
+type yamlLine struct {
+	number int // 1-based line number for error messages
+	indent int
+	text   string // content with indentation and comments removed
+	raw    string // original line, for block scalars
+}
+
+// parseYAMLSubset parses the block-style YAML used for configuration files:
+// nested mappings and sequences, plain, quoted and block (| and >) scalars,
+// flow sequences and mappings, and comments. Anchors, tags and multiple
+// documents are not supported. Mappings decode to map[string]interface{},
+// sequences to []interface{} and numbers to float64, as encoding/json does.
+func parseYAMLSubset(data string) (interface{}, error) {
+	var lines []yamlLine
+	for n, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n+1)
+		}
+		text := strings.TrimSpace(stripYAMLComment(trimmed))
+		if text == "" || text == "---" {
+			lines = append(lines, yamlLine{number: n + 1, indent: -1, raw: raw})
+			continue
+		}
+		lines = append(lines, yamlLine{number: n + 1, indent: len(raw) - len(trimmed), text: text, raw: raw})
+	}
+
+	p := &yamlParser{lines: lines}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	value, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	return value, nil
+}
+
+// stripYAMLComment removes a trailing "# comment" that is outside quotes
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].indent < 0 {
+		p.pos++
+	}
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent || !isYAMLSequenceItem(p.lines[p.pos].text) {
+			return items, nil
+		}
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+
+		if rest == "" {
+			p.pos++
+			value, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+
+		if _, _, ok := splitYAMLKey(rest); ok {
+			// "- key: value" starts a mapping indented past the dash
+			p.lines[p.pos].indent = indent + len(line.text) - len(rest)
+			p.lines[p.pos].text = rest
+			value, err := p.parseMapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+
+		value, err := parseYAMLScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+		items = append(items, value)
+		p.pos++
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent < indent {
+			return m, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		if isYAMLSequenceItem(line.text) {
+			return m, nil
+		}
+
+		key, value, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.number)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.number, key)
+		}
+		p.pos++
+
+		switch {
+		case value == "":
+			nested, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = nested
+		case value == "|" || value == ">" || value == "|-" || value == ">-":
+			m[key] = p.parseBlockScalar(indent, value)
+		default:
+			scalar, err := parseYAMLScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.number, err)
+			}
+			m[key] = scalar
+		}
+	}
+}
+
+// parseNested parses the value of a "key:" or "-" line that continues on
+// the following lines, or returns nil if it is empty
+func (p *yamlParser) parseNested(parentIndent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	// A sequence may sit at the same indentation as its key
+	if next.indent > parentIndent || (next.indent == parentIndent && isYAMLSequenceItem(next.text)) {
+		return p.parseBlock(next.indent)
+	}
+	return nil, nil
+}
+
+// parseBlockScalar collects the more-indented lines after a | or > indicator
+func (p *yamlParser) parseBlockScalar(indent int, style string) string {
+	var body []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent >= 0 && line.indent <= indent {
+			break
+		}
+		if line.indent >= 0 && blockIndent < 0 {
+			blockIndent = line.indent
+		}
+		text := ""
+		if len(line.raw) > blockIndent && blockIndent >= 0 {
+			text = line.raw[blockIndent:]
+		}
+		body = append(body, text)
+		p.pos++
+	}
+	for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+		body = body[:len(body)-1]
+	}
+
+	sep := "\n"
+	if strings.HasPrefix(style, ">") {
+		sep = " "
+	}
+	out := strings.Join(body, sep)
+	if !strings.HasSuffix(style, "-") {
+		out += "\n"
+	}
+	return out
+}
+
+// splitYAMLKey splits "key: value" at the first colon outside quotes and
+// flow brackets that is followed by a space or the end of the line
+func splitYAMLKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			key := strings.TrimSpace(text[:i])
+			if unquoted, err := parseYAMLScalar(key); err == nil {
+				if s, ok := unquoted.(string); ok {
+					key = s
+				}
+			}
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+// parseYAMLScalar parses a plain, quoted or flow value
+func parseYAMLScalar(s string) (interface{}, error) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		f := &yamlFlow{s: s}
+		value, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos != len(f.s) {
+			return nil, fmt.Errorf("unexpected %q after flow collection", f.s[f.pos:])
+		}
+		return value, nil
+	}
+	return yamlPlainScalar(s)
+}
+
+func yamlPlainScalar(s string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "_xXoObB") {
+		return f, nil
+	}
+	return s, nil
+}
+
+// yamlFlow parses flow collections such as [a, b] and {k: v}
+type yamlFlow struct {
+	s   string
+	pos int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value() (interface{}, error) {
+	f.skipSpace()
+	if f.pos >= len(f.s) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		items := []interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return items, nil
+			}
+			item, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := map[string]interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return m, nil
+			}
+			key, err := f.scalar(":")
+			if err != nil {
+				return nil, err
+			}
+			if f.pos >= len(f.s) || f.s[f.pos] != ':' {
+				return nil, fmt.Errorf("expected ':' in flow mapping")
+			}
+			f.pos++
+			value, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(key)] = value
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f.scalar(",]}")
+}
+
+// separator consumes a comma, or leaves the closing bracket for the caller
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	if f.pos < len(f.s) && f.s[f.pos] == ',' {
+		f.pos++
+		return nil
+	}
+	if f.pos < len(f.s) && f.s[f.pos] == closing {
+		return nil
+	}
+	return fmt.Errorf("expected ',' or '%c' in flow collection", closing)
+}
+
+// scalar reads a quoted scalar or a plain one ending before any of stops
+func (f *yamlFlow) scalar(stops string) (interface{}, error) {
+	f.skipSpace()
+	start := f.pos
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		quote := f.s[f.pos]
+		f.pos++
+		for f.pos < len(f.s) && f.s[f.pos] != quote {
+			if f.s[f.pos] == '\\' && quote == '"' {
+				f.pos++
+			}
+			f.pos++
+		}
+		if f.pos >= len(f.s) {
+			return nil, fmt.Errorf("unterminated string in flow collection")
+		}
+		f.pos++
+		return yamlPlainScalar(f.s[start:f.pos])
+	}
+	for f.pos < len(f.s) && !strings.ContainsRune(stops, rune(f.s[f.pos])) {
+		f.pos++
+	}
+	return yamlPlainScalar(strings.TrimSpace(f.s[start:f.pos]))
+}
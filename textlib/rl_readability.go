@@ -70,7 +70,7 @@ func CalculateReadabilityMetrics(text string, algorithms []string) ReadabilityRe
 	for _, word := range words {
 		cleanWord := strings.Trim(word, ".,!?;:\"'")
 		totalCharacters += len(cleanWord)
-		syllables := countSyllables(cleanWord)
+		syllables := countReadabilitySyllables(cleanWord)
 		totalSyllables += syllables
 		
 		if syllables >= 3 {
@@ -113,7 +113,7 @@ func CalculateReadabilityMetrics(text string, algorithms []string) ReadabilityRe
 			collector.IncrementAlgorithmSteps()
 			
 		case "gunning-fog":
-			score := calculateGunningFog(avgWordsPerSentence, complexWordRatio)
+			score := gunningFogIndex(avgWordsPerSentence, complexWordRatio)
 			report.Scores["gunning-fog"] = score
 			collector.IncrementAlgorithmSteps()
 			
@@ -136,7 +136,7 @@ func CalculateReadabilityMetrics(text string, algorithms []string) ReadabilityRe
 			if totalSentences >= 30 {
 				polysyllables := 0
 				for _, word := range words {
-					if countSyllables(strings.Trim(word, ".,!?;:\"'")) >= 3 {
+					if countReadabilitySyllables(strings.Trim(word, ".,!?;:\"'")) >= 3 {
 						polysyllables++
 					}
 				}
@@ -145,7 +145,7 @@ func CalculateReadabilityMetrics(text string, algorithms []string) ReadabilityRe
 				collector.IncrementAlgorithmSteps()
 			} else {
 				// Estimate for shorter texts
-				score := calculateGunningFog(avgWordsPerSentence, complexWordRatio) * 1.1
+				score := gunningFogIndex(avgWordsPerSentence, complexWordRatio) * 1.1
 				report.Scores["smog"] = score
 				report.Scores["smog-estimated"] = 1.0 // Flag that it's estimated
 			}
@@ -215,18 +215,8 @@ func calculateFleschReadingEase(avgWordsPerSentence, avgSyllablesPerWord float64
 	return score
 }
 
-// calculateFleschKincaid calculates the Flesch-Kincaid Grade Level
-func calculateFleschKincaid(avgWordsPerSentence, avgSyllablesPerWord float64) float64 {
-	return 0.39*avgWordsPerSentence + 11.8*avgSyllablesPerWord - 15.59
-}
-
-// calculateGunningFog calculates the Gunning Fog Index
-func calculateGunningFog(avgWordsPerSentence, complexWordRatio float64) float64 {
-	return 0.4 * (avgWordsPerSentence + 100*complexWordRatio)
-}
-
-// countSyllables counts syllables in a word
-func countSyllables(word string) int {
+// countReadabilitySyllables counts syllables in a word
+func countReadabilitySyllables(word string) int {
 	if len(word) == 0 {
 		return 0
 	}
@@ -54,9 +54,12 @@ func ExtractSentiment(text string, accuracy float64) SentimentResult {
 	}
 
 	var result SentimentResult
+	cacheKey := ResultCacheKey("ExtractSentiment", map[string]interface{}{"accuracy": accuracy}, text)
 
 	// Choose analysis method based on accuracy requirement
-	if accuracy <= 0.75 {
+	if loadCachedResult(cacheKey, &result, collector) {
+		result.CacheUsed = true
+	} else if accuracy <= 0.75 {
 		// Fast lexicon-based analysis
 		result = extractSentimentLexicon(text, collector)
 		result.Method = "lexicon-based"
@@ -69,6 +72,9 @@ func ExtractSentiment(text string, accuracy float64) SentimentResult {
 		result = extractSentimentContextual(text, collector)
 		result.Method = "contextual-analysis"
 	}
+	if !result.CacheUsed {
+		storeCachedResult(cacheKey, result)
+	}
 
 	result.ProcessingTime = time.Since(startTime)
 
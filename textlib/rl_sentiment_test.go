@@ -423,7 +423,7 @@ func TestSentimentAccuracyProgression(t *testing.T) {
 	}
 }
 
-func TestEdgeCases(t *testing.T) {
+func TestSentimentEdgeCases(t *testing.T) {
 	// Test accuracy bounds
 	result := ExtractSentiment("Test", 0.5) // Below minimum
 	if result.Method != "lexicon-based" {
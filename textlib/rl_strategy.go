@@ -15,28 +15,45 @@
 package textlib
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"math/rand"
+	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
-// StrategySelector selects optimal processing strategies
+// StrategySelector selects optimal processing strategies. It is safe for
+// concurrent use. With a BanditPolicy it learns online from RecordOutcome
+// which strategy pays off for each kind of text.
 type StrategySelector struct {
+	mu sync.RWMutex
+	
 	// Strategy history for learning
 	history []StrategyOutcome
 	
 	// Learned preferences
 	preferences map[string]float64
+	
+	// Reward statistics per context bucket and strategy
+	arms   map[string]map[string]*BanditArm
+	policy BanditPolicy
+	rng    *rand.Rand
 }
 
 // StrategyOutcome represents the outcome of a strategy selection
 type StrategyOutcome struct {
-	Context    TextCharacteristics
-	Strategy   ProcessingStrategy
-	Metrics    OptimizationMetrics
-	Successful bool
+	Context    TextCharacteristics `json:"context"`
+	Strategy   ProcessingStrategy  `json:"strategy"`
+	Metrics    OptimizationMetrics `json:"metrics"`
+	Successful bool                `json:"successful"`
 }
 
+// strategyTypes are the arms the selector chooses between
+var strategyTypes = []string{"fast", "balanced", "comprehensive"}
+
 // TextCharacteristics describes text properties for strategy selection
 type TextCharacteristics struct {
 	Length      int     `json:"length"`
@@ -46,18 +63,47 @@ type TextCharacteristics struct {
 	Structure   string  `json:"structure"`
 }
 
-// NewStrategySelector creates a new strategy selector
+// NewStrategySelector creates a new strategy selector that picks strategies
+// with fixed rules
 func NewStrategySelector() *StrategySelector {
 	return &StrategySelector{
 		history:     make([]StrategyOutcome, 0),
 		preferences: make(map[string]float64),
+		arms:        make(map[string]map[string]*BanditArm),
+		rng:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// NewLearningStrategySelector creates a selector that picks strategies with
+// policy, learning from RecordOutcome. A seed of 0 seeds from the clock.
+func NewLearningStrategySelector(policy BanditPolicy, seed int64) *StrategySelector {
+	ss := NewStrategySelector()
+	ss.policy = policy
+	if seed != 0 {
+		ss.rng = rand.New(rand.NewSource(seed))
 	}
+	return ss
+}
+
+// SetPolicy switches the bandit policy; nil restores the fixed rules.
+// Learned statistics are kept.
+func (ss *StrategySelector) SetPolicy(policy BanditPolicy) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.policy = policy
 }
 
 // SelectStrategy selects the optimal strategy for given text characteristics
 func (ss *StrategySelector) SelectStrategy(characteristics TextCharacteristics, requirements AlgorithmRequirements) (ProcessingStrategy, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	
 	// Analyze text characteristics
 	strategyType := ss.determineStrategyType(characteristics)
+	if ss.policy != nil {
+		arms := ss.contextArms(characteristics)
+		strategyType = arms[ss.policy.Select(arms, ss.rng)].Strategy
+	}
 	
 	// Build strategy based on requirements
 	strategy := ProcessingStrategy{
@@ -203,8 +249,12 @@ func (ss *StrategySelector) applyLearnedPreferences(strategy *ProcessingStrategy
 	}
 }
 
-// RecordOutcome records the outcome of a strategy selection
+// RecordOutcome records the outcome of a strategy selection. The reward for
+// the bandit is the outcome's WeightedTotal, or 0 if it failed.
 func (ss *StrategySelector) RecordOutcome(characteristics TextCharacteristics, strategy ProcessingStrategy, metrics OptimizationMetrics, successful bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	
 	outcome := StrategyOutcome{
 		Context:    characteristics,
 		Strategy:   strategy,
@@ -217,6 +267,12 @@ func (ss *StrategySelector) RecordOutcome(characteristics TextCharacteristics, s
 	// Update preferences based on outcome
 	ss.updatePreferences(outcome)
 	
+	reward := 0.0
+	if successful {
+		reward = metrics.WeightedTotal
+	}
+	ss.contextArm(characteristics, strategy.Name).record(reward)
+	
 	// Limit history size
 	if len(ss.history) > 1000 {
 		ss.history = ss.history[len(ss.history)-1000:]
@@ -250,6 +306,9 @@ func (ss *StrategySelector) updatePreferences(outcome StrategyOutcome) {
 
 // GetRecommendations provides strategy recommendations
 func (ss *StrategySelector) GetRecommendations(characteristics TextCharacteristics) []StrategyRecommendation {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	
 	recommendations := []StrategyRecommendation{}
 	
 	// Analyze history for similar contexts
@@ -352,6 +411,9 @@ func (ss *StrategySelector) calculateSimilarity(a, b TextCharacteristics) float6
 
 // GetInsights provides insights from strategy selection history
 func (ss *StrategySelector) GetInsights() StrategyInsights {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	
 	insights := StrategyInsights{
 		TotalSelections: len(ss.history),
 		StrategyUsage:   make(map[string]int),
@@ -417,4 +479,162 @@ type StrategyInsights struct {
 	StrategyUsage     map[string]int           `json:"strategy_usage"`
 	DomainPatterns    map[string]string        `json:"domain_patterns"`
 	PerformanceTrends map[string][]float64     `json:"performance_trends"`
-}
\ No newline at end of file
+}
+
+// strategyContext buckets text characteristics so that outcomes on similar
+// texts share statistics
+func strategyContext(c TextCharacteristics) string {
+	length := "medium"
+	if c.Length < 100 {
+		length = "short"
+	} else if c.Length > 10000 {
+		length = "long"
+	}
+	
+	complexity := "low"
+	if c.Complexity > 0.8 {
+		complexity = "high"
+	} else if c.Complexity >= 0.4 {
+		complexity = "medium"
+	}
+	
+	domain := c.Domain
+	if domain == "" {
+		domain = "general"
+	}
+	return length + "|" + complexity + "|" + domain
+}
+
+// contextArm returns the statistics of one strategy in the context of c,
+// creating the context if needed
+func (ss *StrategySelector) contextArm(c TextCharacteristics, strategy string) *BanditArm {
+	key := strategyContext(c)
+	arms, ok := ss.arms[key]
+	if !ok {
+		arms = make(map[string]*BanditArm)
+		for _, name := range strategyTypes {
+			arms[name] = &BanditArm{Strategy: name}
+		}
+		// Warm start: the rule table's pick counts as one average outcome
+		arms[ss.determineStrategyType(c)].record(0.7)
+		ss.arms[key] = arms
+	}
+	arm, ok := arms[strategy]
+	if !ok {
+		arm = &BanditArm{Strategy: strategy}
+		arms[strategy] = arm
+	}
+	return arm
+}
+
+// contextArms returns copies of the selectable arms for the context of c
+func (ss *StrategySelector) contextArms(c TextCharacteristics) []BanditArm {
+	ss.contextArm(c, strategyTypes[0])
+	arms := ss.arms[strategyContext(c)]
+	
+	out := make([]BanditArm, len(strategyTypes))
+	for i, name := range strategyTypes {
+		out[i] = *arms[name]
+	}
+	return out
+}
+
+// ArmStats returns the learned reward statistics of each strategy for texts
+// like c
+func (ss *StrategySelector) ArmStats(c TextCharacteristics) []BanditArm {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.contextArms(c)
+}
+
+// strategySelectorState is the JSON form of a StrategySelector
+type strategySelectorState struct {
+	Version     int                              `json:"version"`
+	History     []StrategyOutcome                `json:"history"`
+	Preferences map[string]float64               `json:"preferences"`
+	Arms        map[string]map[string]*BanditArm `json:"arms"`
+}
+
+// MarshalJSON encodes the selector's history and learned statistics. The
+// policy and random source are configuration and are not included.
+func (ss *StrategySelector) MarshalJSON() ([]byte, error) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	
+	return json.Marshal(strategySelectorState{
+		Version:     1,
+		History:     ss.history,
+		Preferences: ss.preferences,
+		Arms:        ss.arms,
+	})
+}
+
+// UnmarshalJSON replaces the selector's history and learned statistics
+func (ss *StrategySelector) UnmarshalJSON(data []byte) error {
+	var state strategySelectorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state.Version != 1 {
+		return fmt.Errorf("unsupported strategy selector state version %d", state.Version)
+	}
+	if state.History == nil {
+		state.History = make([]StrategyOutcome, 0)
+	}
+	if state.Preferences == nil {
+		state.Preferences = make(map[string]float64)
+	}
+	if state.Arms == nil {
+		state.Arms = make(map[string]map[string]*BanditArm)
+	}
+	for key, arms := range state.Arms {
+		if arms == nil {
+			return fmt.Errorf("strategy context %q has no arms", key)
+		}
+		for name, arm := range arms {
+			if arm == nil {
+				return fmt.Errorf("strategy context %q has a null %q arm", key, name)
+			}
+			arm.Strategy = name
+		}
+		// States saved with fewer strategies start the missing ones untried
+		for _, name := range strategyTypes {
+			if _, ok := arms[name]; !ok {
+				arms[name] = &BanditArm{Strategy: name}
+			}
+		}
+	}
+	
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.history = state.History
+	ss.preferences = state.Preferences
+	ss.arms = state.Arms
+	if ss.rng == nil {
+		ss.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return nil
+}
+
+// Save writes the selector's state to path as JSON, replacing the file
+// atomically
+func (ss *StrategySelector) Save(path string) error {
+	data, err := json.MarshalIndent(ss, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Load replaces the selector's state with the JSON saved at path, keeping
+// its policy
+func (ss *StrategySelector) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, ss); err != nil {
+		return fmt.Errorf("failed to load strategy selector state: %w", err)
+	}
+	return nil
+}
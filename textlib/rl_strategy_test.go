@@ -15,6 +15,8 @@
 package textlib
 
 import (
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -420,4 +422,162 @@ func TestHistoryLimit(t *testing.T) {
 	if len(selector.history) != 1000 {
 		t.Errorf("Expected history limited to 1000, got %d", len(selector.history))
 	}
-}
\ No newline at end of file
+}
+
+func TestLearningStrategySelector(t *testing.T) {
+	// In this context "comprehensive" is clearly best, although the rules
+	// pick "balanced"
+	text := TextCharacteristics{Length: 2000, Language: "en", Domain: "legal", Complexity: 0.5}
+	rewards := map[string]float64{"fast": 0.3, "balanced": 0.5, "comprehensive": 0.9}
+
+	policies := []BanditPolicy{
+		EpsilonGreedyPolicy{Epsilon: 0.1},
+		UCB1Policy{},
+		ThompsonSamplingPolicy{},
+	}
+
+	for _, policy := range policies {
+		t.Run(policy.Name(), func(t *testing.T) {
+			selector := NewLearningStrategySelector(policy, 42)
+
+			picks := map[string]int{}
+			for i := 0; i < 300; i++ {
+				strategy, err := selector.SelectStrategy(text, AlgorithmRequirements{})
+				if err != nil {
+					t.Fatalf("Failed to select strategy: %v", err)
+				}
+				if i >= 200 {
+					picks[strategy.Name]++
+				}
+				selector.RecordOutcome(text, strategy, OptimizationMetrics{WeightedTotal: rewards[strategy.Name]}, true)
+			}
+
+			if picks["comprehensive"] < 70 {
+				t.Errorf("Expected the best strategy to dominate late picks, got %v", picks)
+			}
+
+			// Other contexts are learned separately
+			short := TextCharacteristics{Length: 50, Domain: "chat"}
+			for _, arm := range selector.ArmStats(short) {
+				if arm.Strategy == "fast" && arm.Pulls != 1 {
+					t.Errorf("Expected only the warm-start pull for a new context, got %v", arm.Pulls)
+				}
+			}
+		})
+	}
+}
+
+func TestStrategySelectorFailuresEarnNoReward(t *testing.T) {
+	selector := NewStrategySelector()
+	text := TextCharacteristics{Length: 500, Domain: "news", Complexity: 0.5}
+
+	selector.RecordOutcome(text, ProcessingStrategy{Name: "fast"}, OptimizationMetrics{WeightedTotal: 0.9}, false)
+
+	for _, arm := range selector.ArmStats(text) {
+		if arm.Strategy == "fast" && (arm.Pulls != 1 || arm.TotalReward != 0) {
+			t.Errorf("Expected one zero-reward pull, got %+v", arm)
+		}
+	}
+}
+
+func TestStrategySelectorPersistence(t *testing.T) {
+	text := TextCharacteristics{Length: 2000, Language: "en", Domain: "news", Complexity: 0.5}
+
+	selector := NewStrategySelector()
+	for i := 0; i < 5; i++ {
+		selector.RecordOutcome(text, ProcessingStrategy{Name: "comprehensive"}, OptimizationMetrics{WeightedTotal: 0.9}, true)
+	}
+
+	path := filepath.Join(t.TempDir(), "selector.json")
+	if err := selector.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored := NewLearningStrategySelector(EpsilonGreedyPolicy{}, 1)
+	if err := restored.Load(path); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(restored.history) != 5 {
+		t.Errorf("Expected 5 outcomes after load, got %d", len(restored.history))
+	}
+	if restored.preferences["comprehensive"] != selector.preferences["comprehensive"] {
+		t.Errorf("Preferences not restored: %v", restored.preferences)
+	}
+
+	// The greedy policy now prefers what the saved selector learned
+	strategy, err := restored.SelectStrategy(text, AlgorithmRequirements{})
+	if err != nil {
+		t.Fatalf("Failed to select strategy: %v", err)
+	}
+	if strategy.Name != "comprehensive" {
+		t.Errorf("Expected learned strategy comprehensive, got %s", strategy.Name)
+	}
+
+	if err := restored.Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error loading a missing file")
+	}
+	if err := restored.UnmarshalJSON([]byte(`{"version": 9}`)); err == nil {
+		t.Error("Expected an error for an unknown state version")
+	}
+}
+
+func TestStrategySelectorConcurrency(t *testing.T) {
+	selector := NewLearningStrategySelector(ThompsonSamplingPolicy{}, 7)
+	text := TextCharacteristics{Length: 3000, Domain: "news", Complexity: 0.5}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				strategy, err := selector.SelectStrategy(text, AlgorithmRequirements{})
+				if err != nil {
+					t.Errorf("Failed to select strategy: %v", err)
+					return
+				}
+				selector.RecordOutcome(text, strategy, OptimizationMetrics{WeightedTotal: 0.8}, true)
+				selector.GetInsights()
+				selector.GetRecommendations(text)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := selector.GetInsights().TotalSelections; got != 400 {
+		t.Errorf("Expected 400 recorded outcomes, got %d", got)
+	}
+}
+
+func TestStrategySelectorLoadPartialState(t *testing.T) {
+	text := TextCharacteristics{Length: 2000, Domain: "news", Complexity: 0.5}
+
+	// A context that only knows one strategy gains the others untried
+	restored := NewLearningStrategySelector(EpsilonGreedyPolicy{}, 1)
+	state := `{"version": 1, "arms": {"medium|medium|news": {"comprehensive": {"pulls": 3, "total_reward": 2.7}}}}`
+	if err := restored.UnmarshalJSON([]byte(state)); err != nil {
+		t.Fatalf("Failed to load partial state: %v", err)
+	}
+	arms := restored.ArmStats(text)
+	if len(arms) != len(strategyTypes) {
+		t.Fatalf("Expected %d arms, got %+v", len(strategyTypes), arms)
+	}
+	for _, arm := range arms {
+		if arm.Strategy == "comprehensive" && arm.Pulls != 3 || arm.Strategy != "comprehensive" && arm.Pulls != 0 {
+			t.Errorf("Unexpected arm after load: %+v", arm)
+		}
+	}
+	if _, err := restored.SelectStrategy(text, AlgorithmRequirements{}); err != nil {
+		t.Errorf("Failed to select strategy: %v", err)
+	}
+
+	for _, state := range []string{
+		`{"version": 1, "arms": {"medium|medium|news": null}}`,
+		`{"version": 1, "arms": {"medium|medium|news": {"comprehensive": null}}}`,
+	} {
+		if err := restored.UnmarshalJSON([]byte(state)); err == nil {
+			t.Errorf("Expected an error loading %s", state)
+		}
+	}
+}
@@ -43,9 +43,12 @@ func SummarizeText(text string, maxLength int) SummaryResult {
 	}
 
 	var result SummaryResult
+	cacheKey := ResultCacheKey("SummarizeText", map[string]interface{}{"max_length": maxLength}, text)
 
 	// Choose summarization method based on maxLength parameter
-	if maxLength < 100 {
+	if loadCachedResult(cacheKey, &result, collector) {
+		result.CacheUsed = true
+	} else if maxLength < 100 {
 		// Extractive: Select key sentences
 		result = summarizeExtractive(text, maxLength, collector)
 		result.Method = "extractive"
@@ -58,6 +61,9 @@ func SummarizeText(text string, maxLength int) SummaryResult {
 		result = summarizeAbstractive(text, maxLength, collector)
 		result.Method = "abstractive"
 	}
+	if !result.CacheUsed {
+		storeCachedResult(cacheKey, result)
+	}
 
 	result.ProcessingTime = time.Since(startTime)
 
@@ -278,7 +284,7 @@ func getWordFrequencies(text string) map[string]float64 {
 	// Count frequencies
 	for _, word := range words {
 		word = strings.Trim(word, ".,!?;:\"'")
-		if len(word) > 2 && !isStopWord(word) {
+		if len(word) > 2 && !isRLStopWord(word) {
 			freq[word]++
 		}
 	}
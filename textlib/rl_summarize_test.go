@@ -274,7 +274,7 @@ func TestSummaryQualityMetrics(t *testing.T) {
 	}
 }
 
-func TestEdgeCases(t *testing.T) {
+func TestSummarizeEdgeCases(t *testing.T) {
 	// Test with only stop words
 	result := SummarizeText("the and is at which on with to for of", 50)
 	// Since it's all stop words, the summary should be empty or the same as input
@@ -303,7 +303,7 @@ func TestEdgeCases(t *testing.T) {
 	}
 }
 
-func TestLongTextPerformance(t *testing.T) {
+func TestSummarizeLongTextPerformance(t *testing.T) {
 	// Generate a long text
 	paragraphs := []string{}
 	for i := 0; i < 50; i++ {
@@ -24,6 +24,13 @@ import (
 // ClassifyTopics identifies topics with adaptive algorithm selection based on max topics
 // maxTopics: ≤3 = clustering, ≤10 = statistical, >10 = comprehensive analysis
 func ClassifyTopics(text string, maxTopics int) TopicResult {
+	return ClassifyTopicsWithOptions(text, maxTopics, NormalizationOptions{})
+}
+
+// ClassifyTopicsWithOptions identifies topics with words normalized by opts
+// before term frequencies and co-occurrences are counted. Topic keywords are
+// reported in their normalized form.
+func ClassifyTopicsWithOptions(text string, maxTopics int, opts NormalizationOptions) TopicResult {
 	// Start metrics collection
 	collector := StartMetricsCollection()
 	startTime := time.Now()
@@ -43,21 +50,31 @@ func ClassifyTopics(text string, maxTopics int) TopicResult {
 	}
 
 	var result TopicResult
+	cacheKey := ResultCacheKey("ClassifyTopics", map[string]interface{}{
+		"max_topics": maxTopics,
+		"normalize":  opts.Mode,
+		"language":   opts.Language,
+	}, text)
 
 	// Choose classification method based on maxTopics parameter
-	if maxTopics <= 3 {
+	if loadCachedResult(cacheKey, &result, collector) {
+		result.CacheUsed = true
+	} else if maxTopics <= 3 {
 		// Simple clustering approach
-		result = classifyTopicsClustering(text, maxTopics, collector)
+		result = classifyTopicsClustering(text, maxTopics, opts, collector)
 		result.Method = "clustering"
 	} else if maxTopics <= 10 {
 		// Statistical keyword analysis
-		result = classifyTopicsStatistical(text, maxTopics, collector)
+		result = classifyTopicsStatistical(text, maxTopics, opts, collector)
 		result.Method = "statistical"
 	} else {
 		// Comprehensive topic modeling
-		result = classifyTopicsComprehensive(text, maxTopics, collector)
+		result = classifyTopicsComprehensive(text, maxTopics, opts, collector)
 		result.Method = "comprehensive"
 	}
+	if !result.CacheUsed {
+		storeCachedResult(cacheKey, result)
+	}
 
 	result.ProcessingTime = time.Since(startTime)
 
@@ -67,6 +84,7 @@ func ClassifyTopics(text string, maxTopics int) TopicResult {
 		"max_topics":  maxTopics,
 		"text_length": len(text),
 		"method":      result.Method,
+		"normalize":   opts.Mode,
 	}
 
 	RecordFunctionCall("ClassifyTopics", params, metrics, &result.QualityMetrics)
@@ -75,11 +93,11 @@ func ClassifyTopics(text string, maxTopics int) TopicResult {
 }
 
 // classifyTopicsClustering performs simple keyword clustering for topic identification
-func classifyTopicsClustering(text string, maxTopics int, collector *MetricsCollector) TopicResult {
+func classifyTopicsClustering(text string, maxTopics int, opts NormalizationOptions, collector *MetricsCollector) TopicResult {
 	collector.RecordProcessingTime("clustering_start")
 
 	// Extract key phrases first
-	keyPhrases := ExtractKeyPhrases(text, 20)
+	keyPhrases := ExtractKeyPhrasesWithOptions(text, 20, opts)
 	
 	// Group phrases by semantic similarity (simplified clustering)
 	clusters := clusterPhrasesForTopics(keyPhrases, maxTopics)
@@ -138,12 +156,12 @@ func classifyTopicsClustering(text string, maxTopics int, collector *MetricsColl
 }
 
 // classifyTopicsStatistical performs statistical analysis for topic identification
-func classifyTopicsStatistical(text string, maxTopics int, collector *MetricsCollector) TopicResult {
+func classifyTopicsStatistical(text string, maxTopics int, opts NormalizationOptions, collector *MetricsCollector) TopicResult {
 	collector.RecordProcessingTime("statistical_start")
 
 	// Extract frequent terms and their co-occurrences
-	termFreq := calculateTermFrequencies(text)
-	cooccurrences := calculateCooccurrences(text, termFreq)
+	termFreq := calculateTermFrequencies(text, opts)
+	cooccurrences := calculateCooccurrences(text, termFreq, opts)
 	
 	// Identify topic clusters based on term associations
 	topicClusters := identifyTopicClusters(termFreq, cooccurrences, maxTopics)
@@ -195,11 +213,11 @@ func classifyTopicsStatistical(text string, maxTopics int, collector *MetricsCol
 }
 
 // classifyTopicsComprehensive performs comprehensive topic modeling
-func classifyTopicsComprehensive(text string, maxTopics int, collector *MetricsCollector) TopicResult {
+func classifyTopicsComprehensive(text string, maxTopics int, opts NormalizationOptions, collector *MetricsCollector) TopicResult {
 	collector.RecordProcessingTime("comprehensive_start")
 
 	// Start with statistical analysis
-	statResult := classifyTopicsStatistical(text, maxTopics*2, collector) // Get more candidates
+	statResult := classifyTopicsStatistical(text, maxTopics*2, opts, collector) // Get more candidates
 	
 	// Refine using additional analysis
 	refinedTopics := refinTopicsWithContext(text, statResult.Topics, maxTopics)
@@ -311,7 +329,7 @@ func phraseSimilarity(phrase1, phrase2 string) float64 {
 	commonWords := 0
 	for _, w1 := range words1 {
 		for _, w2 := range words2 {
-			if w1 == w2 && len(w1) > 2 && !isStopWord(w1) {
+			if w1 == w2 && len(w1) > 2 && !isRLStopWord(w1) {
 				commonWords++
 				break
 			}
@@ -337,7 +355,7 @@ func generateTopicName(phrases []KeyPhrase) string {
 		words := strings.Fields(phrase.Text)
 		for _, word := range words {
 			cleanWord := strings.ToLower(strings.Trim(word, ".,!?;:\"'"))
-			if len(cleanWord) > 3 && !isStopWord(cleanWord) {
+			if len(cleanWord) > 3 && !isRLStopWord(cleanWord) {
 				return strings.Title(cleanWord) + " Related"
 			}
 		}
@@ -363,7 +381,7 @@ func extractKeywords(phrases []KeyPhrase) []string {
 		for _, word := range words {
 			// Clean word
 			word = strings.Trim(word, ".,!?;:\"'")
-			if len(word) > 2 && !isStopWord(word) && !seen[word] {
+			if len(word) > 2 && !isRLStopWord(word) && !seen[word] {
 				keywords = append(keywords, word)
 				seen[word] = true
 			}
@@ -414,18 +432,14 @@ func extractExamples(text string, phrases []KeyPhrase) []string {
 	return examples
 }
 
-func calculateTermFrequencies(text string) map[string]float64 {
-	words := strings.Fields(strings.ToLower(text))
+func calculateTermFrequencies(text string, opts NormalizationOptions) map[string]float64 {
 	freq := make(map[string]int)
 	total := 0
 
 	// Count word frequencies
-	for _, word := range words {
-		word = strings.Trim(word, ".,!?;:\"'")
-		if len(word) > 2 && !isStopWord(word) {
-			freq[word]++
-			total++
-		}
+	for _, word := range topicTerms(strings.Fields(strings.ToLower(text)), opts) {
+		freq[word]++
+		total++
 	}
 
 	// Normalize frequencies
@@ -437,7 +451,7 @@ func calculateTermFrequencies(text string) map[string]float64 {
 	return normalized
 }
 
-func calculateCooccurrences(text string, termFreq map[string]float64) map[string]map[string]float64 {
+func calculateCooccurrences(text string, termFreq map[string]float64, opts NormalizationOptions) map[string]map[string]float64 {
 	sentences := SplitIntoSentences(text)
 	cooccur := make(map[string]map[string]float64)
 
@@ -452,12 +466,9 @@ func calculateCooccurrences(text string, termFreq map[string]float64) map[string
 		cleanWords := make([]string, 0, len(words))
 
 		// Clean and filter words
-		for _, word := range words {
-			word = strings.Trim(word, ".,!?;:\"'")
-			if len(word) > 2 && !isStopWord(word) {
-				if _, exists := termFreq[word]; exists {
-					cleanWords = append(cleanWords, word)
-				}
+		for _, word := range topicTerms(words, opts) {
+			if _, exists := termFreq[word]; exists {
+				cleanWords = append(cleanWords, word)
 			}
 		}
 
@@ -487,6 +498,19 @@ func calculateCooccurrences(text string, termFreq map[string]float64) map[string
 	return cooccur
 }
 
+// topicTerms trims punctuation, drops short and stop words and normalizes
+// what remains
+func topicTerms(words []string, opts NormalizationOptions) []string {
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.Trim(word, ".,!?;:\"'")
+		if len(word) > 2 && !isRLStopWord(word) {
+			terms = append(terms, word)
+		}
+	}
+	return NormalizeTokens(terms, opts)
+}
+
 func identifyTopicClusters(termFreq map[string]float64, cooccur map[string]map[string]float64, maxTopics int) []topicCluster {
 	// Identify strongly connected word groups
 	clusters := make([]topicCluster, 0, maxTopics)
@@ -732,7 +756,7 @@ func findRelatedTerms(text string, keywords []string) []string {
 	// Simple approach: find words that appear near the keywords
 	for i, word := range words {
 		word = strings.Trim(word, ".,!?;:\"'")
-		if len(word) <= 2 || isStopWord(word) {
+		if len(word) <= 2 || isRLStopWord(word) {
 			continue
 		}
 		
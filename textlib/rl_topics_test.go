@@ -333,7 +333,7 @@ func TestTopicQualityProgression(t *testing.T) {
 	}
 }
 
-func TestEdgeCases(t *testing.T) {
+func TestTopicsEdgeCases(t *testing.T) {
 	// Test with zero maxTopics
 	result := ClassifyTopics("Some text here", 0)
 	if len(result.Topics) != 0 {
@@ -424,4 +424,21 @@ func BenchmarkClassifyTopicsComprehensive(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		ClassifyTopics(text, 15)
 	}
-}
\ No newline at end of file
+}
+func TestClassifyTopicsWithOptions(t *testing.T) {
+	text := `Databases store records. The database indexes each record.
+	         Indexing databases keeps queries fast. Queries read indexed records.`
+
+	result := ClassifyTopicsWithOptions(text, 5, NormalizationOptions{Mode: "lemma"})
+	if len(result.Topics) == 0 {
+		t.Fatal("Expected topics")
+	}
+
+	for _, topic := range result.Topics {
+		for _, keyword := range topic.Keywords {
+			if keyword == "databases" || keyword == "records" {
+				t.Errorf("Expected plural keyword %q to be lemmatized", keyword)
+			}
+		}
+	}
+}
@@ -0,0 +1,598 @@
+// Copyright 2025 Caia Tech
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TypedAlgorithm is an Algorithm with static input, output and parameter
+// types. Parameters are a struct whose fields carry `json` names and
+// optional `jsonschema` constraints, for example
+//
+//	type SummaryParams struct {
+//		Sentences int    `json:"sentences" jsonschema:"required,minimum=1,maximum=20"`
+//		Method    string `json:"method" jsonschema:"enum=textrank,enum=lead,default=textrank"`
+//	}
+//
+// Supported constraint keys are required, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, minLength, maxLength, pattern, enum
+// (repeatable), default and description.
+type TypedAlgorithm[In, Out, P any] interface {
+	Name() string
+	Description() string
+	Process(input In, params P) (Out, error)
+	EstimateComplexity(inputSize int) ComplexityEstimate
+	ValidateParams(params P) error
+}
+
+// TypedBaseAlgorithm implements TypedAlgorithm with a processing function.
+// ValidateParams checks the constraints in the parameter struct tags.
+type TypedBaseAlgorithm[In, Out, P any] struct {
+	base      *BaseAlgorithm
+	processor func(In, P) (Out, error)
+}
+
+// NewTypedBaseAlgorithm creates a typed algorithm from a processing function
+func NewTypedBaseAlgorithm[In, Out, P any](name, description string, processor func(In, P) (Out, error), complexity ComplexityEstimate) *TypedBaseAlgorithm[In, Out, P] {
+	return &TypedBaseAlgorithm[In, Out, P]{
+		base:      NewBaseAlgorithm(name, description, nil, complexity),
+		processor: processor,
+	}
+}
+
+// Name returns the algorithm name
+func (ta *TypedBaseAlgorithm[In, Out, P]) Name() string {
+	return ta.base.Name()
+}
+
+// Description returns the algorithm description
+func (ta *TypedBaseAlgorithm[In, Out, P]) Description() string {
+	return ta.base.Description()
+}
+
+// Process executes the algorithm
+func (ta *TypedBaseAlgorithm[In, Out, P]) Process(input In, params P) (Out, error) {
+	return ta.processor(input, params)
+}
+
+// EstimateComplexity returns the complexity estimate
+func (ta *TypedBaseAlgorithm[In, Out, P]) EstimateComplexity(inputSize int) ComplexityEstimate {
+	return ta.base.EstimateComplexity(inputSize)
+}
+
+// ValidateParams checks params against its `jsonschema` tag constraints
+func (ta *TypedBaseAlgorithm[In, Out, P]) ValidateParams(params P) error {
+	return validateTaggedParams(reflect.ValueOf(params), "")
+}
+
+// ParamsSchema returns the JSON Schema of the parameter struct
+func (ta *TypedBaseAlgorithm[In, Out, P]) ParamsSchema() map[string]interface{} {
+	return JSONSchemaFor[P]()
+}
+
+// SchemaProvider is implemented by algorithms that can describe their
+// parameters as a JSON Schema
+type SchemaProvider interface {
+	ParamsSchema() map[string]interface{}
+}
+
+// typedAdapter lets a TypedAlgorithm satisfy Algorithm
+type typedAdapter[In, Out, P any] struct {
+	typed TypedAlgorithm[In, Out, P]
+}
+
+// AsAlgorithm adapts a typed algorithm to the untyped Algorithm interface.
+// Inputs and parameter maps are converted to In and P, through JSON when a
+// direct conversion is not possible; unknown parameters, missing required
+// parameters and failed constraints are errors.
+func AsAlgorithm[In, Out, P any](typed TypedAlgorithm[In, Out, P]) Algorithm {
+	return &typedAdapter[In, Out, P]{typed: typed}
+}
+
+// RegisterTypedAlgorithm registers a typed algorithm in the algorithm
+// registry, where it is available to GetAlgorithm, SelectBestAlgorithm and
+// pipelines
+func RegisterTypedAlgorithm[In, Out, P any](typed TypedAlgorithm[In, Out, P], metadata AlgorithmMetadata) error {
+	return RegisterAlgorithm(typed.Name(), AsAlgorithm(typed), metadata)
+}
+
+// GetTypedAlgorithm looks up a registered algorithm with static types. An
+// algorithm registered with RegisterTypedAlgorithm is returned as is;
+// others are wrapped so that parameters are passed as a map and the output
+// is converted to Out.
+func GetTypedAlgorithm[In, Out, P any](name string) (TypedAlgorithm[In, Out, P], error) {
+	algo, err := GetAlgorithm(name)
+	if err != nil {
+		return nil, err
+	}
+	if adapter, ok := algo.(*typedAdapter[In, Out, P]); ok {
+		return adapter.typed, nil
+	}
+	if adapter, ok := algo.(interface{ typedSignature() string }); ok {
+		var in In
+		var out Out
+		var p P
+		want := fmt.Sprintf("%T -> %T (%T)", in, out, p)
+		return nil, fmt.Errorf("algorithm %s has signature %s, not %s", name, adapter.typedSignature(), want)
+	}
+	return &untypedAdapter[In, Out, P]{algo: algo}, nil
+}
+
+func (a *typedAdapter[In, Out, P]) typedSignature() string {
+	var in In
+	var out Out
+	var p P
+	return fmt.Sprintf("%T -> %T (%T)", in, out, p)
+}
+
+// Name returns the algorithm name
+func (a *typedAdapter[In, Out, P]) Name() string {
+	return a.typed.Name()
+}
+
+// Description returns the algorithm description
+func (a *typedAdapter[In, Out, P]) Description() string {
+	return a.typed.Description()
+}
+
+// Process converts input and params and runs the typed algorithm
+func (a *typedAdapter[In, Out, P]) Process(input interface{}, params map[string]interface{}) (interface{}, error) {
+	in, err := convertTo[In](input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid input: %w", a.typed.Name(), err)
+	}
+	p, err := a.decodeParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return a.typed.Process(in, p)
+}
+
+// EstimateComplexity returns the complexity estimate
+func (a *typedAdapter[In, Out, P]) EstimateComplexity(inputSize int) ComplexityEstimate {
+	return a.typed.EstimateComplexity(inputSize)
+}
+
+// ValidateParams decodes params into the parameter struct and validates it
+func (a *typedAdapter[In, Out, P]) ValidateParams(params map[string]interface{}) error {
+	_, err := a.decodeParams(params)
+	return err
+}
+
+// ParamsSchema returns the JSON Schema of the parameter struct
+func (a *typedAdapter[In, Out, P]) ParamsSchema() map[string]interface{} {
+	if provider, ok := a.typed.(SchemaProvider); ok {
+		return provider.ParamsSchema()
+	}
+	return JSONSchemaFor[P]()
+}
+
+// decodeParams fills defaults, checks required fields, decodes params
+// strictly and runs the typed validation
+func (a *typedAdapter[In, Out, P]) decodeParams(params map[string]interface{}) (P, error) {
+	var p P
+	name := a.typed.Name()
+
+	merged := make(map[string]interface{}, len(params))
+	if t := indirectType(reflect.TypeOf(p)); t != nil && t.Kind() == reflect.Struct {
+		for _, f := range schemaFields(t) {
+			if value, ok := f.tags["default"]; ok {
+				merged[f.name] = parseSchemaValue(value[0], f.typ)
+			}
+		}
+		for _, f := range schemaFields(t) {
+			if _, required := f.tags["required"]; required {
+				if _, ok := params[f.name]; !ok {
+					return p, fmt.Errorf("%s: missing required parameter %s", name, f.name)
+				}
+			}
+		}
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return p, fmt.Errorf("%s: invalid parameters: %w", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, fmt.Errorf("%s: invalid parameters: %w", name, err)
+	}
+
+	if err := a.typed.ValidateParams(p); err != nil {
+		return p, fmt.Errorf("%s: %w", name, err)
+	}
+	return p, nil
+}
+
+// untypedAdapter presents an untyped Algorithm as a TypedAlgorithm
+type untypedAdapter[In, Out, P any] struct {
+	algo Algorithm
+}
+
+func (u *untypedAdapter[In, Out, P]) Name() string        { return u.algo.Name() }
+func (u *untypedAdapter[In, Out, P]) Description() string { return u.algo.Description() }
+
+func (u *untypedAdapter[In, Out, P]) EstimateComplexity(inputSize int) ComplexityEstimate {
+	return u.algo.EstimateComplexity(inputSize)
+}
+
+func (u *untypedAdapter[In, Out, P]) ValidateParams(params P) error {
+	m, err := paramsToMap(params)
+	if err != nil {
+		return err
+	}
+	return u.algo.ValidateParams(m)
+}
+
+func (u *untypedAdapter[In, Out, P]) Process(input In, params P) (Out, error) {
+	var zero Out
+	m, err := paramsToMap(params)
+	if err != nil {
+		return zero, err
+	}
+	result, err := u.algo.Process(input, m)
+	if err != nil {
+		return zero, err
+	}
+	out, err := convertTo[Out](result)
+	if err != nil {
+		return zero, fmt.Errorf("%s: unexpected output: %w", u.algo.Name(), err)
+	}
+	return out, nil
+}
+
+// paramsToMap converts a parameter struct to the map form via JSON
+func paramsToMap(params interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if string(data) == "null" {
+		return m, nil
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parameters must encode as a JSON object: %w", err)
+	}
+	return m, nil
+}
+
+// convertTo returns value as a T, converting through JSON when it is not
+// already one (for example a float64 from a decoded document into an int)
+func convertTo[T any](value interface{}) (T, error) {
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+	var out T
+	if value == nil {
+		return out, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("cannot convert %T to %T", value, out)
+	}
+	return out, nil
+}
+
+// schemaField is a JSON-visible struct field with its parsed constraints
+type schemaField struct {
+	index []int
+	name  string
+	typ   reflect.Type
+	tags  map[string][]string
+	desc  string
+}
+
+// schemaFields lists the exported, JSON-visible fields of a struct type,
+// flattening embedded structs as encoding/json does
+func schemaFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		jsonTag := f.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name := strings.Split(jsonTag, ",")[0]
+
+		if f.Anonymous && name == "" && indirectType(f.Type).Kind() == reflect.Struct {
+			for _, inner := range schemaFields(indirectType(f.Type)) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		tags := map[string][]string{}
+		desc := f.Tag.Get("description")
+		if opts := f.Tag.Get("jsonschema"); opts != "" {
+			for _, opt := range strings.Split(opts, ",") {
+				key, value, _ := strings.Cut(opt, "=")
+				key = strings.TrimSpace(key)
+				if key == "description" {
+					desc = value
+					continue
+				}
+				tags[key] = append(tags[key], value)
+			}
+		}
+		fields = append(fields, schemaField{index: []int{i}, name: name, typ: f.Type, tags: tags, desc: desc})
+	}
+	return fields
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// parseSchemaValue converts a tag value to the JSON type of a field
+func parseSchemaValue(s string, t reflect.Type) interface{} {
+	switch indirectType(t).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// JSONSchemaFor returns a JSON Schema (draft 2020-12) describing T
+func JSONSchemaFor[T any]() map[string]interface{} {
+	var zero T
+	schema := GenerateJSONSchema(reflect.TypeOf(&zero).Elem())
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return schema
+}
+
+// GenerateJSONSchema returns a JSON Schema describing values of type t.
+// Struct fields follow their `json` tags and `jsonschema` constraints;
+// structs do not allow additional properties.
+func GenerateJSONSchema(t reflect.Type) map[string]interface{} {
+	return generateSchema(t, map[reflect.Type]bool{})
+}
+
+func generateSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	t = indirectType(t)
+	if t == nil {
+		return map[string]interface{}{}
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(time.Duration(0)):
+		return map[string]interface{}{"type": "integer", "description": "nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema := map[string]interface{}{"type": "integer"}
+		if t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64 {
+			schema["minimum"] = 0
+		}
+		return schema
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": generateSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": generateSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			// Recursive types are left open rather than expanded forever
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]interface{}{}
+		var required []string
+		for _, f := range schemaFields(t) {
+			prop := generateSchema(f.typ, seen)
+			if f.desc != "" {
+				prop["description"] = f.desc
+			}
+			for key, values := range f.tags {
+				switch key {
+				case "required":
+					required = append(required, f.name)
+				case "enum":
+					enum := make([]interface{}, len(values))
+					for i, v := range values {
+						enum[i] = parseSchemaValue(v, f.typ)
+					}
+					prop["enum"] = enum
+				case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+					if n, err := strconv.ParseFloat(values[0], 64); err == nil {
+						prop[key] = n
+					}
+				case "minLength", "maxLength":
+					if n, err := strconv.Atoi(values[0]); err == nil {
+						prop[key] = n
+					}
+				case "pattern":
+					prop[key] = values[0]
+				case "default":
+					prop[key] = parseSchemaValue(values[0], f.typ)
+				}
+			}
+			properties[f.name] = prop
+		}
+
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// validateTaggedParams checks a value against the `jsonschema` constraints
+// on its struct fields, recursing into nested structs
+func validateTaggedParams(v reflect.Value, prefix string) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || v.Type() == timeType {
+		return nil
+	}
+
+	for _, f := range schemaFields(v.Type()) {
+		field, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			continue // nil embedded pointer
+		}
+		name := prefix + f.name
+		if err := checkFieldConstraints(field, f, name); err != nil {
+			return err
+		}
+		if err := validateTaggedParams(field, name+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkFieldConstraints(field reflect.Value, f schemaField, name string) error {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+
+	number, isNumber := pipelineNumber(field.Interface())
+	for key, values := range f.tags {
+		limit, _ := strconv.ParseFloat(values[0], 64)
+		switch key {
+		case "minimum":
+			if isNumber && number < limit {
+				return fmt.Errorf("parameter %s must be at least %v, got %v", name, limit, number)
+			}
+		case "maximum":
+			if isNumber && number > limit {
+				return fmt.Errorf("parameter %s must be at most %v, got %v", name, limit, number)
+			}
+		case "exclusiveMinimum":
+			if isNumber && number <= limit {
+				return fmt.Errorf("parameter %s must be greater than %v, got %v", name, limit, number)
+			}
+		case "exclusiveMaximum":
+			if isNumber && number >= limit {
+				return fmt.Errorf("parameter %s must be less than %v, got %v", name, limit, number)
+			}
+		case "minLength":
+			if field.Kind() == reflect.String && float64(len([]rune(field.String()))) < limit {
+				return fmt.Errorf("parameter %s must be at least %v characters", name, limit)
+			}
+		case "maxLength":
+			if field.Kind() == reflect.String && float64(len([]rune(field.String()))) > limit {
+				return fmt.Errorf("parameter %s must be at most %v characters", name, limit)
+			}
+		case "pattern":
+			if field.Kind() == reflect.String {
+				re, err := compileSchemaPattern(values[0])
+				if err != nil {
+					return fmt.Errorf("parameter %s: invalid pattern: %w", name, err)
+				}
+				if !re.MatchString(field.String()) {
+					return fmt.Errorf("parameter %s must match %s", name, values[0])
+				}
+			}
+		case "enum":
+			got := fmt.Sprint(field.Interface())
+			allowed := false
+			for _, v := range values {
+				if v == got {
+					allowed = true
+					break
+				}
+			}
+			// The zero value stands for "not set" unless it is listed
+			if !allowed && !field.IsZero() {
+				return fmt.Errorf("parameter %s must be one of %s, got %s", name, strings.Join(values, ", "), got)
+			}
+		}
+	}
+	return nil
+}
+
+var schemaPatterns sync.Map // pattern -> *regexp.Regexp
+
+func compileSchemaPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := schemaPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	schemaPatterns.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Caia Tech
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textlib
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type truncateParams struct {
+	Words  int    `json:"words" jsonschema:"required,minimum=1,maximum=100"`
+	Suffix string `json:"suffix" jsonschema:"default=...,maxLength=5" description:"Appended when text is cut"`
+	Mode   string `json:"mode,omitempty" jsonschema:"enum=head,enum=tail"`
+}
+
+type truncateResult struct {
+	Text      string `json:"text"`
+	Truncated bool   `json:"truncated"`
+}
+
+func newTruncateAlgorithm(name string) *TypedBaseAlgorithm[string, truncateResult, truncateParams] {
+	return NewTypedBaseAlgorithm(name, "Keeps the first or last words of a text",
+		func(text string, p truncateParams) (truncateResult, error) {
+			words := strings.Fields(text)
+			if len(words) <= p.Words {
+				return truncateResult{Text: text}, nil
+			}
+			if p.Mode == "tail" {
+				return truncateResult{Text: p.Suffix + strings.Join(words[len(words)-p.Words:], " "), Truncated: true}, nil
+			}
+			return truncateResult{Text: strings.Join(words[:p.Words], " ") + p.Suffix, Truncated: true}, nil
+		},
+		ComplexityEstimate{TimeComplexity: "O(n)", EstimatedTimeMs: 1},
+	)
+}
+
+var registerTypedTestAlgorithms sync.Once
+
+func setupTypedTestAlgorithms() {
+	registerTypedTestAlgorithms.Do(func() {
+		RegisterTypedAlgorithm[string, truncateResult, truncateParams](newTruncateAlgorithm("typed-truncate"), AlgorithmMetadata{
+			Category: "typed-test", QualityScore: 0.9, SpeedScore: 0.9, MemoryScore: 0.9,
+		})
+		RegisterAlgorithm("untyped-length", NewBaseAlgorithm("untyped-length", "Counts bytes",
+			func(input interface{}, params map[string]interface{}) (interface{}, error) {
+				return float64(len(input.(string))), nil
+			}, ComplexityEstimate{}), AlgorithmMetadata{Category: "typed-test", QualityScore: 0.5})
+	})
+}
+
+func TestTypedAlgorithmDirect(t *testing.T) {
+	algo := newTruncateAlgorithm("truncate")
+
+	out, err := algo.Process("one two three four", truncateParams{Words: 2, Suffix: "…"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if out.Text != "one two…" || !out.Truncated {
+		t.Errorf("Unexpected result %+v", out)
+	}
+
+	tests := []struct {
+		params  truncateParams
+		wantErr string
+	}{
+		{truncateParams{Words: 5}, ""},
+		{truncateParams{Words: 0}, "at least 1"},
+		{truncateParams{Words: 101}, "at most 100"},
+		{truncateParams{Words: 5, Suffix: "toolong"}, "at most 5 characters"},
+		{truncateParams{Words: 5, Mode: "middle"}, "one of head, tail"},
+	}
+	for _, tt := range tests {
+		err := algo.ValidateParams(tt.params)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%+v: unexpected error %v", tt.params, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%+v: expected error containing %q, got %v", tt.params, tt.wantErr, err)
+		}
+	}
+}
+
+func TestTypedAlgorithmRegistry(t *testing.T) {
+	setupTypedTestAlgorithms()
+
+	algo, err := GetAlgorithm("typed-truncate")
+	if err != nil {
+		t.Fatalf("Typed algorithm not registered: %v", err)
+	}
+
+	// Map parameters decoded from JSON arrive as float64; defaults apply
+	out, err := algo.Process("a b c d", map[string]interface{}{"words": 3.0})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if res := out.(truncateResult); res.Text != "a b c..." {
+		t.Errorf("Expected default suffix, got %+v", res)
+	}
+
+	for _, params := range []map[string]interface{}{
+		{},
+		{"words": 3, "unknown": true},
+		{"words": "three"},
+		{"words": 0},
+	} {
+		if err := algo.ValidateParams(params); err == nil {
+			t.Errorf("Expected %v to be rejected", params)
+		}
+	}
+	if _, err := algo.Process(42, map[string]interface{}{"words": 1}); err == nil {
+		t.Error("Expected an input type error")
+	}
+
+	best, err := SelectBestAlgorithm("typed-test", AlgorithmRequirements{})
+	if err != nil || best != "typed-truncate" {
+		t.Errorf("Expected SelectBestAlgorithm to pick typed-truncate, got %q (%v)", best, err)
+	}
+
+	typed, err := GetTypedAlgorithm[string, truncateResult, truncateParams]("typed-truncate")
+	if err != nil {
+		t.Fatalf("GetTypedAlgorithm failed: %v", err)
+	}
+	if res, _ := typed.Process("x y", truncateParams{Words: 1, Suffix: "!"}); res.Text != "x!" {
+		t.Errorf("Unexpected typed result %+v", res)
+	}
+
+	if _, err := GetTypedAlgorithm[int, int, struct{}]("typed-truncate"); err == nil {
+		t.Error("Expected a signature mismatch error")
+	}
+
+	// Untyped algorithms can be used through the typed interface
+	length, err := GetTypedAlgorithm[string, int, struct{}]("untyped-length")
+	if err != nil {
+		t.Fatalf("GetTypedAlgorithm failed: %v", err)
+	}
+	if n, err := length.Process("hello", struct{}{}); err != nil || n != 5 {
+		t.Errorf("Expected 5, got %d (%v)", n, err)
+	}
+}
+
+func TestJSONSchemaFor(t *testing.T) {
+	schema := JSONSchemaFor[truncateParams]()
+
+	if schema["$schema"] != "https://json-schema.org/draft/2020-12/schema" || schema["type"] != "object" {
+		t.Errorf("Unexpected schema header %v", schema)
+	}
+	if !reflect.DeepEqual(schema["required"], []string{"words"}) {
+		t.Errorf("Expected words to be required, got %v", schema["required"])
+	}
+	if schema["additionalProperties"] != false {
+		t.Error("Expected additionalProperties false")
+	}
+
+	props := schema["properties"].(map[string]interface{})
+	want := map[string]interface{}{
+		"words":  map[string]interface{}{"type": "integer", "minimum": 1.0, "maximum": 100.0},
+		"suffix": map[string]interface{}{"type": "string", "default": "...", "maxLength": 5, "description": "Appended when text is cut"},
+		"mode":   map[string]interface{}{"type": "string", "enum": []interface{}{"head", "tail"}},
+	}
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("Expected properties %v, got %v", want, props)
+	}
+
+	type node struct {
+		Name     string            `json:"name"`
+		Children []node            `json:"children"`
+		Labels   map[string]uint   `json:"labels"`
+		Data     []byte            `json:"data"`
+		Skip     string            `json:"-"`
+		Extra    map[string]string `json:"extra,omitempty"`
+	}
+	nested := GenerateJSONSchema(reflect.TypeOf(node{}))
+	nprops := nested["properties"].(map[string]interface{})
+	if _, ok := nprops["Skip"]; ok {
+		t.Error("Expected json:\"-\" fields to be omitted")
+	}
+	children := nprops["children"].(map[string]interface{})
+	if children["type"] != "array" || children["items"].(map[string]interface{})["type"] != "object" {
+		t.Errorf("Unexpected recursive schema %v", children)
+	}
+	labels := nprops["labels"].(map[string]interface{})["additionalProperties"].(map[string]interface{})
+	if labels["minimum"] != 0 {
+		t.Errorf("Expected unsigned values to have minimum 0, got %v", labels)
+	}
+	if nprops["data"].(map[string]interface{})["contentEncoding"] != "base64" {
+		t.Error("Expected []byte to be a base64 string")
+	}
+
+	if provider, ok := AsAlgorithm[string, truncateResult, truncateParams](newTruncateAlgorithm("t")).(SchemaProvider); !ok || provider.ParamsSchema()["type"] != "object" {
+		t.Error("Expected the adapter to provide a params schema")
+	}
+}
@@ -60,6 +60,7 @@ type ComplexityReport struct {
 	MemoryUsed          int64              `json:"memory_used"`
 	AlgorithmUsed       string             `json:"algorithm_used"`
 	QualityMetrics      QualityMetrics     `json:"quality_metrics"`
+	CacheUsed           bool               `json:"cache_used"`
 }
 
 // KeyPhrase represents an extracted key phrase with metadata
@@ -167,6 +168,7 @@ type SummaryResult struct {
 	CompressionRatio    float64         `json:"compression_ratio"`
 	ProcessingTime      time.Duration   `json:"processing_time"`
 	QualityMetrics      QualityMetrics  `json:"quality_metrics"`
+	CacheUsed           bool            `json:"cache_used"`
 }
 
 // SentimentResult represents the output of sentiment analysis
@@ -177,6 +179,7 @@ type SentimentResult struct {
 	Method             string              `json:"method"`       // lexicon-based/rule-based/contextual-analysis
 	ProcessingTime     time.Duration       `json:"processing_time"`
 	QualityMetrics     QualityMetrics      `json:"quality_metrics"`
+	CacheUsed          bool                `json:"cache_used"`
 }
 
 // TopicResult represents the output of topic classification
@@ -185,6 +188,7 @@ type TopicResult struct {
 	Method         string         `json:"method"`         // clustering/statistical/comprehensive
 	ProcessingTime time.Duration  `json:"processing_time"`
 	QualityMetrics QualityMetrics `json:"quality_metrics"`
+	CacheUsed      bool           `json:"cache_used"`
 }
 
 // DocumentAnalysis represents comprehensive document analysis
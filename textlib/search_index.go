@@ -0,0 +1,852 @@
+package textlib
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IndexOptions configures analysis and BM25 ranking
+type IndexOptions struct {
+	K1            float64              // BM25 term frequency saturation (default 1.2)
+	B             float64              // BM25 length normalization (default 0.75)
+	Normalization NormalizationOptions // Applied to document and query terms (default lowercase)
+	KeepStopWords bool                 // Index stop words instead of skipping them
+	FieldBoosts   map[string]float64   // Score multiplier per field (default 1)
+}
+
+// SearchOptions configures a query
+type SearchOptions struct {
+	Limit         int    // Maximum hits (default 10)
+	SnippetWords  int    // Words per snippet (default 30)
+	HighlightPre  string // Inserted before matched words (default "<mark>")
+	HighlightPost string // Inserted after matched words (default "</mark>")
+}
+
+// SearchHit is a ranked search result
+type SearchHit struct {
+	ID      string
+	Score   float64
+	Field   string // Field that contributed most to the score
+	Snippet string // Excerpt of Field with matched words highlighted
+}
+
+// Index is an in-memory inverted index with BM25 ranking, phrase queries
+// and highlighted snippets. Documents have named text fields that are
+// tokenized with Tokenize and normalized with NormalizeTokens. It is safe
+// for concurrent use.
+type Index struct {
+	opts IndexOptions
+
+	mu       sync.RWMutex
+	docs     []indexDocument
+	ids      map[string]int
+	postings map[string]map[string][]indexPosting // field -> term -> postings
+	lengths  map[string]int                       // field -> total terms in live documents
+	live     int
+}
+
+type indexDocument struct {
+	id      string
+	fields  map[string]string
+	lengths map[string]int
+	deleted bool
+}
+
+type indexPosting struct {
+	doc       int32
+	positions []int32
+}
+
+// indexToken is an analyzed term with its position and source offsets
+type indexToken struct {
+	term       string
+	position   int
+	start, end int
+}
+
+// NewIndex creates an empty index
+func NewIndex(opts IndexOptions) *Index {
+	if opts.K1 <= 0 {
+		opts.K1 = 1.2
+	}
+	if opts.B <= 0 || opts.B > 1 {
+		opts.B = 0.75
+	}
+	if opts.Normalization.Mode == "" {
+		opts.Normalization.Mode = "lowercase"
+	}
+	return &Index{
+		opts:     opts,
+		ids:      map[string]int{},
+		postings: map[string]map[string][]indexPosting{},
+		lengths:  map[string]int{},
+	}
+}
+
+// Len returns the number of indexed documents
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.live
+}
+
+// Add indexes a document, replacing any document with the same ID
+func (x *Index) Add(id string, fields map[string]string) error {
+	if id == "" {
+		return errors.New("document ID is empty")
+	}
+
+	// Analyze outside the lock
+	analyzed := make(map[string][]indexToken, len(fields))
+	for name, text := range fields {
+		analyzed[name] = x.analyze(text)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.deleteLocked(id)
+
+	doc := int32(len(x.docs))
+	stored := indexDocument{id: id, fields: map[string]string{}, lengths: map[string]int{}}
+	for name, text := range fields {
+		stored.fields[name] = text
+		tokens := analyzed[name]
+		stored.lengths[name] = len(tokens)
+		x.lengths[name] += len(tokens)
+
+		positions := map[string][]int32{}
+		for _, tok := range tokens {
+			positions[tok.term] = append(positions[tok.term], int32(tok.position))
+		}
+		terms := x.postings[name]
+		if terms == nil {
+			terms = map[string][]indexPosting{}
+			x.postings[name] = terms
+		}
+		for term, pos := range positions {
+			terms[term] = append(terms[term], indexPosting{doc: doc, positions: pos})
+		}
+	}
+
+	x.docs = append(x.docs, stored)
+	x.ids[id] = int(doc)
+	x.live++
+	return nil
+}
+
+// Delete removes a document and reports whether it was indexed
+func (x *Index) Delete(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.deleteLocked(id)
+}
+
+func (x *Index) deleteLocked(id string) bool {
+	doc, ok := x.ids[id]
+	if !ok {
+		return false
+	}
+	d := &x.docs[doc]
+	d.deleted = true
+	for name, n := range d.lengths {
+		x.lengths[name] -= n
+	}
+	d.fields = nil
+	delete(x.ids, id)
+	x.live--
+	return true
+}
+
+// analyze tokenizes text into normalized terms. Stop words are skipped but
+// still advance the position, so phrases match across them.
+func (x *Index) analyze(text string) []indexToken {
+	words := Tokenize(text)
+	surface := make([]string, len(words))
+	for i, w := range words {
+		surface[i] = w.Text
+	}
+	terms := NormalizeTokens(surface, x.opts.Normalization)
+
+	tokens := make([]indexToken, 0, len(words))
+	for i, w := range words {
+		if !x.opts.KeepStopWords && isStopWord(strings.ToLower(w.Text)) {
+			continue
+		}
+		tokens = append(tokens, indexToken{term: terms[i], position: i, start: w.Position.Start, end: w.Position.End})
+	}
+	return tokens
+}
+
+// queryClause is one term, phrase or field restriction of a parsed query
+type queryClause struct {
+	field    string
+	terms    []indexToken
+	required bool
+	excluded bool
+}
+
+// parseQuery splits a query into clauses. Quoted text is a required phrase,
+// +term is required, -term is excluded and field:term searches one field.
+func (x *Index) parseQuery(query string) []queryClause {
+	var clauses []queryClause
+
+	for len(query) > 0 {
+		query = strings.TrimLeft(query, " \t\r\n")
+		if query == "" {
+			break
+		}
+
+		clause := queryClause{}
+		switch query[0] {
+		case '+':
+			clause.required = true
+			query = query[1:]
+		case '-':
+			clause.excluded = true
+			query = query[1:]
+		}
+
+		// field: prefix
+		if i := strings.IndexAny(query, ": \t\""); i > 0 && query[i] == ':' {
+			clause.field = query[:i]
+			query = query[i+1:]
+		}
+
+		var text string
+		if strings.HasPrefix(query, "\"") {
+			end := strings.IndexByte(query[1:], '"')
+			if end < 0 {
+				text, query = query[1:], ""
+			} else {
+				text, query = query[1:end+1], query[end+2:]
+			}
+			if !clause.excluded {
+				clause.required = true
+			}
+		} else {
+			end := strings.IndexAny(query, " \t\r\n")
+			if end < 0 {
+				end = len(query)
+			}
+			text, query = query[:end], query[end:]
+		}
+
+		clause.terms = x.analyze(text)
+		if len(clause.terms) == 0 {
+			continue
+		}
+
+		if len(clause.terms) > 1 && !strings.ContainsAny(text, " \t") {
+			// A single word that tokenizes into several terms is matched as
+			// separate terms, not a phrase
+			for _, t := range clause.terms {
+				clauses = append(clauses, queryClause{field: clause.field, terms: []indexToken{t}, required: clause.required, excluded: clause.excluded})
+			}
+			continue
+		}
+		clauses = append(clauses, clause)
+	}
+
+	return clauses
+}
+
+// Search ranks documents matching query with BM25
+func (x *Index) Search(query string, opts SearchOptions) []SearchHit {
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	if opts.SnippetWords <= 0 {
+		opts.SnippetWords = 30
+	}
+	if opts.HighlightPre == "" && opts.HighlightPost == "" {
+		opts.HighlightPre, opts.HighlightPost = "<mark>", "</mark>"
+	}
+
+	clauses := x.parseQuery(query)
+	hits := []SearchHit{}
+	if len(clauses) == 0 {
+		return hits
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	type docScore struct {
+		total    float64
+		byField  map[string]float64
+		required int
+	}
+	scores := map[int32]*docScore{}
+	excluded := map[int32]bool{}
+	requiredClauses := 0
+
+	for _, clause := range clauses {
+		if clause.required {
+			requiredClauses++
+		}
+
+		matched := map[int32]bool{}
+		for _, field := range x.clauseFields(clause) {
+			freqs := x.phraseFrequencies(field, clause.terms)
+			idf := 0.0
+			if !clause.excluded && len(freqs) > 0 {
+				idf = x.idf(field, clause.terms)
+			}
+			for doc, tf := range freqs {
+				matched[doc] = true
+				if clause.excluded {
+					continue
+				}
+
+				s := scores[doc]
+				if s == nil {
+					s = &docScore{byField: map[string]float64{}}
+					scores[doc] = s
+				}
+				score := x.bm25(field, idf, doc, tf)
+				s.total += score
+				s.byField[field] += score
+			}
+		}
+
+		for doc := range matched {
+			if clause.excluded {
+				excluded[doc] = true
+			} else if clause.required {
+				scores[doc].required++
+			}
+		}
+	}
+
+	for doc, s := range scores {
+		if excluded[doc] || s.required < requiredClauses {
+			continue
+		}
+
+		best, bestScore := "", -1.0
+		for field, score := range s.byField {
+			if score > bestScore || (score == bestScore && field < best) {
+				best, bestScore = field, score
+			}
+		}
+		hits = append(hits, SearchHit{ID: x.docs[doc].id, Score: s.total, Field: best})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return x.ids[hits[i].ID] < x.ids[hits[j].ID]
+	})
+	if len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+
+	for i := range hits {
+		doc := x.docs[x.ids[hits[i].ID]]
+		hits[i].Snippet = x.snippet(doc.fields[hits[i].Field], clauses, hits[i].Field, opts)
+	}
+
+	return hits
+}
+
+func (x *Index) clauseFields(clause queryClause) []string {
+	if clause.field != "" {
+		return []string{clause.field}
+	}
+	fields := make([]string, 0, len(x.postings))
+	for field := range x.postings {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// phraseFrequencies counts, for every live document, how often terms occur
+// in field at their relative positions
+func (x *Index) phraseFrequencies(field string, terms []indexToken) map[int32]int {
+	freqs := map[int32]int{}
+	index := x.postings[field]
+	if index == nil {
+		return freqs
+	}
+
+	first := index[terms[0].term]
+	if len(terms) == 1 {
+		for _, p := range first {
+			if !x.docs[p.doc].deleted {
+				freqs[p.doc] = len(p.positions)
+			}
+		}
+		return freqs
+	}
+
+	// Positions of each later term, by document
+	rest := make([]map[int32][]int32, len(terms)-1)
+	for i, t := range terms[1:] {
+		rest[i] = map[int32][]int32{}
+		for _, p := range index[t.term] {
+			rest[i][p.doc] = p.positions
+		}
+	}
+
+	for _, p := range first {
+		if x.docs[p.doc].deleted {
+			continue
+		}
+		count := 0
+		for _, start := range p.positions {
+			match := true
+			for i, t := range terms[1:] {
+				want := start + int32(t.position-terms[0].position)
+				positions := rest[i][p.doc]
+				j := sort.Search(len(positions), func(k int) bool { return positions[k] >= want })
+				if j == len(positions) || positions[j] != want {
+					match = false
+					break
+				}
+			}
+			if match {
+				count++
+			}
+		}
+		if count > 0 {
+			freqs[p.doc] = count
+		}
+	}
+	return freqs
+}
+
+// idf sums the inverse document frequencies of terms in one field, counting
+// only live documents
+func (x *Index) idf(field string, terms []indexToken) float64 {
+	n := float64(x.live)
+	idf := 0.0
+	for _, t := range terms {
+		df := 0
+		for _, p := range x.postings[field][t.term] {
+			if !x.docs[p.doc].deleted {
+				df++
+			}
+		}
+		idf += math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	}
+	return idf
+}
+
+// bm25 scores tf occurrences of a clause with the given idf in one field of
+// doc
+func (x *Index) bm25(field string, idf float64, doc int32, tf int) float64 {
+	n := float64(x.live)
+	avg := float64(x.lengths[field]) / math.Max(n, 1)
+	length := float64(x.docs[doc].lengths[field])
+	norm := 1 - x.opts.B
+	if avg > 0 {
+		norm += x.opts.B * length / avg
+	}
+
+	f := float64(tf)
+	score := idf * f * (x.opts.K1 + 1) / (f + x.opts.K1*norm)
+	if boost, ok := x.opts.FieldBoosts[field]; ok {
+		score *= boost
+	}
+	return score
+}
+
+// snippet returns the window of text with the most matched terms
+func (x *Index) snippet(text string, clauses []queryClause, field string, opts SearchOptions) string {
+	if text == "" {
+		return ""
+	}
+
+	wanted := map[string]bool{}
+	for _, c := range clauses {
+		if c.excluded || (c.field != "" && c.field != field) {
+			continue
+		}
+		for _, t := range c.terms {
+			wanted[t.term] = true
+		}
+	}
+
+	tokens := x.analyze(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+	matched := make([]bool, len(tokens))
+	for i, t := range tokens {
+		matched[i] = wanted[t.term]
+	}
+
+	// Slide a window over the tokens and keep the one with most matches
+	size := min(opts.SnippetWords, len(tokens))
+	count := 0
+	for i := 0; i < size; i++ {
+		if matched[i] {
+			count++
+		}
+	}
+	bestStart, bestCount := 0, count
+	for start := 1; start+size <= len(tokens); start++ {
+		if matched[start-1] {
+			count--
+		}
+		if matched[start+size-1] {
+			count++
+		}
+		if count > bestCount {
+			bestStart, bestCount = start, count
+		}
+	}
+
+	from, to := tokens[bestStart].start, tokens[bestStart+size-1].end
+	if bestStart == 0 {
+		from = 0
+	}
+	if bestStart+size == len(tokens) {
+		to = len(text)
+	}
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := from
+	for i := bestStart; i < bestStart+size; i++ {
+		if !matched[i] {
+			continue
+		}
+		sb.WriteString(text[pos:tokens[i].start])
+		sb.WriteString(opts.HighlightPre)
+		sb.WriteString(text[tokens[i].start:tokens[i].end])
+		sb.WriteString(opts.HighlightPost)
+		pos = tokens[i].end
+	}
+	sb.WriteString(text[pos:to])
+	if to < len(text) {
+		sb.WriteString("…")
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+// Binary index format: the magic bytes, a version, the options, the stored
+// documents and the postings of every field. Integers are uvarints and
+// strings are length-prefixed; deleted documents are dropped.
+const (
+	indexMagic   = "TLIX"
+	indexVersion = 1
+)
+
+// Save writes the index to path
+func (x *Index) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create index file: %w", err)
+	}
+	if _, err := x.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadIndex reads an index written by Save
+func LoadIndex(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index file: %w", err)
+	}
+	defer f.Close()
+	return ReadIndex(f)
+}
+
+// WriteTo writes the index in its binary format
+func (x *Index) WriteTo(w io.Writer) (int64, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	e := &indexEncoder{w: cw}
+
+	e.bytes([]byte(indexMagic))
+	e.uint(indexVersion)
+	e.float(x.opts.K1)
+	e.float(x.opts.B)
+	e.string(x.opts.Normalization.Mode)
+	e.string(x.opts.Normalization.Language)
+	if x.opts.KeepStopWords {
+		e.uint(1)
+	} else {
+		e.uint(0)
+	}
+	boosts := sortedKeys(x.opts.FieldBoosts)
+	e.uint(uint64(len(boosts)))
+	for _, field := range boosts {
+		e.string(field)
+		e.float(x.opts.FieldBoosts[field])
+	}
+
+	// Renumber live documents densely
+	renumber := make([]int32, len(x.docs))
+	e.uint(uint64(x.live))
+	next := int32(0)
+	for i, d := range x.docs {
+		if d.deleted {
+			renumber[i] = -1
+			continue
+		}
+		renumber[i] = next
+		next++
+
+		e.string(d.id)
+		names := sortedKeys(d.fields)
+		e.uint(uint64(len(names)))
+		for _, name := range names {
+			e.string(name)
+			e.string(d.fields[name])
+			e.uint(uint64(d.lengths[name]))
+		}
+	}
+
+	fields := sortedKeys(x.postings)
+	e.uint(uint64(len(fields)))
+	for _, field := range fields {
+		e.string(field)
+		terms := sortedKeys(x.postings[field])
+		e.uint(uint64(len(terms)))
+		for _, term := range terms {
+			var live []indexPosting
+			for _, p := range x.postings[field][term] {
+				if renumber[p.doc] >= 0 {
+					live = append(live, p)
+				}
+			}
+			e.string(term)
+			e.uint(uint64(len(live)))
+			prevDoc := int32(0)
+			for _, p := range live {
+				doc := renumber[p.doc]
+				e.uint(uint64(doc - prevDoc))
+				prevDoc = doc
+				e.uint(uint64(len(p.positions)))
+				prevPos := int32(0)
+				for _, pos := range p.positions {
+					e.uint(uint64(pos - prevPos))
+					prevPos = pos
+				}
+			}
+		}
+	}
+
+	if e.err == nil {
+		e.err = bw.Flush()
+	}
+	if e.err != nil {
+		return cw.n, fmt.Errorf("failed to write index: %w", e.err)
+	}
+	return cw.n, nil
+}
+
+// ReadIndex reads an index in the binary format written by WriteTo
+func ReadIndex(r io.Reader) (*Index, error) {
+	d := &indexDecoder{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(d.r, magic); err != nil || string(magic) != indexMagic {
+		return nil, errors.New("not a textlib index file")
+	}
+	if version := d.uint(); d.err == nil && version != indexVersion {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+
+	opts := IndexOptions{}
+	opts.K1 = d.float()
+	opts.B = d.float()
+	opts.Normalization.Mode = d.string()
+	opts.Normalization.Language = d.string()
+	opts.KeepStopWords = d.uint() == 1
+	if n := d.count(); n > 0 {
+		opts.FieldBoosts = map[string]float64{}
+		for i := 0; i < n && d.err == nil; i++ {
+			field := d.string()
+			opts.FieldBoosts[field] = d.float()
+		}
+	}
+	x := NewIndex(opts)
+
+	docCount := d.count()
+	for i := 0; i < docCount && d.err == nil; i++ {
+		doc := indexDocument{id: d.string(), fields: map[string]string{}, lengths: map[string]int{}}
+		fieldCount := d.count()
+		for j := 0; j < fieldCount && d.err == nil; j++ {
+			name := d.string()
+			doc.fields[name] = d.string()
+			doc.lengths[name] = d.count()
+			x.lengths[name] += doc.lengths[name]
+		}
+		x.ids[doc.id] = len(x.docs)
+		x.docs = append(x.docs, doc)
+	}
+	x.live = len(x.docs)
+
+	fieldCount := d.count()
+	for i := 0; i < fieldCount && d.err == nil; i++ {
+		field := d.string()
+		terms := map[string][]indexPosting{}
+		termCount := d.count()
+		for j := 0; j < termCount && d.err == nil; j++ {
+			term := d.string()
+			var postings []indexPosting
+			// Deltas are summed in int64 so a corrupt one can't wrap
+			// around to a negative document or position
+			doc := int64(0)
+			for k, n := 0, d.count(); k < n && d.err == nil; k++ {
+				delta := d.uint()
+				if delta > math.MaxInt32 || doc+int64(delta) >= int64(len(x.docs)) {
+					d.fail(fmt.Errorf("posting refers to a missing document (delta %d after %d)", delta, doc))
+					break
+				}
+				doc += int64(delta)
+				var positions []int32
+				pos := int64(0)
+				for p, m := 0, d.count(); p < m && d.err == nil; p++ {
+					delta := d.uint()
+					if delta > math.MaxInt32 || pos+int64(delta) > math.MaxInt32 {
+						d.fail(fmt.Errorf("term position out of range in document %d", doc))
+						break
+					}
+					pos += int64(delta)
+					positions = append(positions, int32(pos))
+				}
+				postings = append(postings, indexPosting{doc: int32(doc), positions: positions})
+			}
+			terms[term] = postings
+		}
+		x.postings[field] = terms
+	}
+
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", d.err)
+	}
+	return x, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// indexEncoder writes uvarints, floats and strings, keeping the first error
+type indexEncoder struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *indexEncoder) bytes(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *indexEncoder) uint(v uint64) {
+	n := binary.PutUvarint(e.buf[:], v)
+	e.bytes(e.buf[:n])
+}
+
+func (e *indexEncoder) float(v float64) {
+	e.uint(math.Float64bits(v))
+}
+
+func (e *indexEncoder) string(s string) {
+	e.uint(uint64(len(s)))
+	e.bytes([]byte(s))
+}
+
+// indexDecoder mirrors indexEncoder
+type indexDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+// Upper bound on lengths read from a file, to reject corrupt input early
+const indexMaxLength = 1 << 30
+
+// Strings are read in pieces of at most this size
+const indexReadChunk = 64 << 10
+
+func (d *indexDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *indexDecoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.fail(err)
+	}
+	return v
+}
+
+func (d *indexDecoder) count() int {
+	v := d.uint()
+	if v > indexMaxLength {
+		d.fail(fmt.Errorf("length %d out of range", v))
+		return 0
+	}
+	return int(v)
+}
+
+func (d *indexDecoder) float() float64 {
+	return math.Float64frombits(d.uint())
+}
+
+func (d *indexDecoder) string() string {
+	n := d.count()
+	if d.err != nil || n == 0 {
+		return ""
+	}
+	// Grow with the bytes actually read, so a corrupt length runs into the
+	// end of the input instead of allocating up to indexMaxLength
+	var b strings.Builder
+	chunk := make([]byte, min(n, indexReadChunk))
+	for b.Len() < n {
+		m, err := io.ReadFull(d.r, chunk[:min(n-b.Len(), len(chunk))])
+		b.Write(chunk[:m])
+		if err != nil {
+			d.fail(err)
+			return ""
+		}
+	}
+	return b.String()
+}
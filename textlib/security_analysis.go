@@ -2,7 +2,6 @@ package textlib
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
@@ -24,22 +23,28 @@ type SecurityThreat struct {
 
 type ExecutableInfo struct {
 	Format       string
-	Architecture string
+	Architecture string // x86, x86_64, arm, arm64, ...; comma-separated for fat Mach-O
+	Bits         int
 	Entrypoint   uint64
 	Sections     []Section
+	Libraries    []string
 	Imports      []ExecutableImport
 	Exports      []Export
 	HasDebugInfo bool
 	IsPacked     bool
 	Compiler     string
 	Timestamp    uint32
+	Hardening    ExecutableHardening
+	Slices       []ExecutableInfo // per-architecture details of a fat Mach-O binary
 }
 
 type Section struct {
 	Name         string
 	VirtualAddr  uint64
+	VirtualSize  uint64
 	RawSize      uint32
-	Permissions  string
+	Permissions  string  // "r-x" style
+	Entropy      float64 // Shannon entropy of the raw data in bits per byte
 	IsExecutable bool
 	IsWritable   bool
 	IsReadable   bool
@@ -120,6 +125,7 @@ func DetectMaliciousPatterns(filePath string) ([]SecurityThreat, error) {
 	threats = append(threats, detectNetworkPatterns(content)...)
 	threats = append(threats, detectCryptoPatterns(content)...)
 	threats = append(threats, detectAntiAnalysisPatterns(content)...)
+	threats = append(threats, detectXXEPatterns(content)...)
 	
 	return threats, nil
 }
@@ -403,6 +409,173 @@ func detectAntiAnalysisPatterns(content []byte) []SecurityThreat {
 	return threats
 }
 
+var (
+	entityDeclPattern     = regexp.MustCompile(`<!ENTITY\s+(%\s+)?([^\s>]+)\s`)
+	externalEntityPattern = regexp.MustCompile(`<!ENTITY\s+(%\s+)?[^\s>]+\s+(SYSTEM|PUBLIC)\s`)
+)
+
+// detectXXEPatterns flags XML whose DOCTYPE declares external entities or
+// an external DTD, parameter entities, or entities that expand to an
+// oversized "billion laughs" payload, and XInclude processing
+func detectXXEPatterns(content []byte) []SecurityThreat {
+	var threats []SecurityThreat
+	
+	start := bytes.Index(content, []byte("<!DOCTYPE"))
+	if start >= 0 {
+		end := doctypeEnd(content, start)
+		directive := string(content[start+2 : end])
+		line := bytes.Count(content[:start], []byte("\n")) + 1
+		
+		// Report an entity at its first declaration; parameter entities
+		// are keyed with a leading %
+		declared := make(map[string]int64)
+		for _, m := range entityDeclPattern.FindAllStringSubmatchIndex(directive, -1) {
+			key := directive[m[4]:m[5]]
+			if m[2] >= 0 {
+				key = "%" + key
+			}
+			if _, ok := declared[key]; !ok {
+				declared[key] = int64(start + 2 + m[0])
+			}
+		}
+		position := func(e *XMLEntity) int64 {
+			key := e.Name
+			if e.Parameter {
+				key = "%" + key
+			}
+			if pos, ok := declared[key]; ok {
+				return pos
+			}
+			return int64(start)
+		}
+		
+		dtd, err := parseDTD(directive, line)
+		if err != nil {
+			// Fall back to spotting external entity declarations
+			for _, loc := range externalEntityPattern.FindAllStringIndex(directive, -1) {
+				threats = append(threats, SecurityThreat{
+					Type:        "xxe",
+					Severity:    "high",
+					Description: "External entity declaration in malformed DTD",
+					Position:    int64(start + 2 + loc[0]),
+					Pattern:     directive[loc[0]:loc[1]],
+					Confidence:  0.7,
+					Mitigation:  "Disable external entity resolution when parsing this file",
+				})
+			}
+			return threats
+		}
+		
+		// HTML doctypes name an external DTD that browsers never load
+		if (dtd.SystemID != "" || dtd.PublicID != "") && !strings.EqualFold(dtd.Name, "html") {
+			threats = append(threats, SecurityThreat{
+				Type:        "xxe",
+				Severity:    "medium",
+				Description: "External DTD reference",
+				Position:    int64(start),
+				Pattern:     dtd.SystemID,
+				Confidence:  0.6,
+				Mitigation:  "Do not load external DTDs; use ValidateXML with Hardened set",
+			})
+		}
+		
+		for _, name := range sortedEntityNames(dtd.Entities) {
+			e := dtd.Entities[name]
+			switch {
+			case e.External():
+				description := "External entity declaration (XXE)"
+				if e.Parameter {
+					description = "External parameter entity declaration (XXE)"
+				}
+				threats = append(threats, SecurityThreat{
+					Type:        "xxe",
+					Severity:    "high",
+					Description: description,
+					Position:    position(e),
+					Pattern:     e.SystemID,
+					Confidence:  0.9,
+					Mitigation:  "Disable external entity resolution; use ValidateXML with Hardened set",
+				})
+			case e.Parameter:
+				threats = append(threats, SecurityThreat{
+					Type:        "xxe",
+					Severity:    "medium",
+					Description: "Parameter entity declaration",
+					Position:    position(e),
+					Pattern:     e.Name,
+					Confidence:  0.6,
+					Mitigation:  "Reject parameter entities; use ValidateXML with Hardened set",
+				})
+			}
+		}
+		
+		sizes, err := dtd.entityExpansionSizes()
+		if err != nil {
+			threats = append(threats, SecurityThreat{
+				Type:        "xml_bomb",
+				Severity:    "high",
+				Description: fmt.Sprintf("Recursive entity definition: %v", err),
+				Position:    int64(start),
+				Pattern:     "<!ENTITY",
+				Confidence:  0.9,
+				Mitigation:  "Limit entity expansion when parsing this file",
+			})
+		}
+		for _, name := range sortedEntityNames(dtd.Entities) {
+			if size := sizes[name]; size > DefaultXMLMaxEntityExpansion {
+				threats = append(threats, SecurityThreat{
+					Type:        "xml_bomb",
+					Severity:    "high",
+					Description: fmt.Sprintf("Entity %s expands to %d bytes (billion laughs)", name, size),
+					Position:    position(dtd.Entities[name]),
+					Pattern:     name,
+					Confidence:  0.95,
+					Mitigation:  "Limit entity expansion when parsing this file",
+				})
+			}
+		}
+	}
+	
+	if pos := bytes.Index(content, []byte("http://www.w3.org/2001/XInclude")); pos >= 0 {
+		threats = append(threats, SecurityThreat{
+			Type:        "xxe",
+			Severity:    "medium",
+			Description: "XInclude namespace can pull in external files",
+			Position:    int64(pos),
+			Pattern:     "XInclude",
+			Confidence:  0.7,
+			Mitigation:  "Disable XInclude processing when parsing this file",
+		})
+	}
+	
+	return threats
+}
+
+// doctypeEnd finds the '>' closing the DOCTYPE at start, skipping quoted
+// strings and the internal subset
+func doctypeEnd(content []byte, start int) int {
+	depth := 0
+	var quote byte
+	for i := start + 2; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '>' && depth <= 0:
+			return i
+		}
+	}
+	return len(content)
+}
+
 func isPrivateIP(ip string) bool {
 	privateRanges := []string{
 		"10.", "172.16.", "172.17.", "172.18.", "172.19.",
@@ -447,7 +620,7 @@ func AnalyzeExecutableHeaders(exePath string) (ExecutableInfo, error) {
 	} else if string(header[:2]) == "MZ" {
 		info.Format = "PE"
 		return analyzePEHeaders(file, info)
-	} else if string(header[:4]) == "\xFE\xED\xFA\xCE" || string(header[:4]) == "\xCE\xFA\xED\xFE" {
+	} else if isMachOMagic(header) {
 		info.Format = "Mach-O"
 		return analyzeMachOHeaders(file, info)
 	}
@@ -455,196 +628,29 @@ func AnalyzeExecutableHeaders(exePath string) (ExecutableInfo, error) {
 	return info, fmt.Errorf("unknown executable format")
 }
 
-func analyzeELFHeaders(file *os.File, info ExecutableInfo) (ExecutableInfo, error) {
-	file.Seek(0, 0)
-	
-	// Read ELF header
-	elfHeader := make([]byte, 64)
-	_, err := file.Read(elfHeader)
-	if err != nil {
-		return info, err
-	}
-	
-	// Parse ELF header
-	class := elfHeader[4] // 32-bit or 64-bit
-	if class == 1 {
-		info.Architecture = "32-bit"
-	} else if class == 2 {
-		info.Architecture = "64-bit"
-	}
-	
-	// Read entry point
-	if class == 2 { // 64-bit
-		info.Entrypoint = binary.LittleEndian.Uint64(elfHeader[24:32])
-	} else { // 32-bit
-		info.Entrypoint = uint64(binary.LittleEndian.Uint32(elfHeader[24:28]))
-	}
-	
-	// Read section headers (simplified)
-	shoff := binary.LittleEndian.Uint64(elfHeader[40:48])
-	shentsize := binary.LittleEndian.Uint16(elfHeader[58:60])
-	shnum := binary.LittleEndian.Uint16(elfHeader[60:62])
-	
-	// Read sections
-	file.Seek(int64(shoff), 0)
-	for i := 0; i < int(shnum); i++ {
-		sectionHeader := make([]byte, shentsize)
-		_, err := file.Read(sectionHeader)
-		if err != nil {
-			break
-		}
-		
-		section := Section{
-			Name:        fmt.Sprintf("section_%d", i),
-			VirtualAddr: binary.LittleEndian.Uint64(sectionHeader[16:24]),
-			RawSize:     binary.LittleEndian.Uint32(sectionHeader[32:36]),
-		}
-		
-		// Parse section flags
-		flags := binary.LittleEndian.Uint64(sectionHeader[8:16])
-		section.IsExecutable = (flags & 0x4) != 0
-		section.IsWritable = (flags & 0x1) != 0
-		section.IsReadable = true // ELF sections are generally readable
-		
-		info.Sections = append(info.Sections, section)
-	}
-	
-	return info, nil
-}
-
-func analyzePEHeaders(file *os.File, info ExecutableInfo) (ExecutableInfo, error) {
-	file.Seek(0, 0)
-	
-	// Read DOS header
-	dosHeader := make([]byte, 64)
-	_, err := file.Read(dosHeader)
-	if err != nil {
-		return info, err
-	}
-	
-	// Get PE header offset
-	peOffset := binary.LittleEndian.Uint32(dosHeader[60:64])
-	
-	// Read PE header
-	file.Seek(int64(peOffset), 0)
-	peSignature := make([]byte, 4)
-	_, err = file.Read(peSignature)
-	if err != nil {
-		return info, err
-	}
-	
-	if string(peSignature) != "PE\x00\x00" {
-		return info, fmt.Errorf("invalid PE signature")
-	}
-	
-	// Read COFF header
-	coffHeader := make([]byte, 20)
-	_, err = file.Read(coffHeader)
-	if err != nil {
-		return info, err
-	}
-	
-	machine := binary.LittleEndian.Uint16(coffHeader[0:2])
-	switch machine {
-	case 0x014c:
-		info.Architecture = "i386"
-	case 0x8664:
-		info.Architecture = "x86_64"
-	case 0x01c0:
-		info.Architecture = "ARM"
-	default:
-		info.Architecture = fmt.Sprintf("unknown(0x%x)", machine)
-	}
-	
-	numberOfSections := binary.LittleEndian.Uint16(coffHeader[2:4])
-	info.Timestamp = binary.LittleEndian.Uint32(coffHeader[4:8])
-	
-	// Read Optional header
-	optionalHeaderSize := binary.LittleEndian.Uint16(coffHeader[16:18])
-	optionalHeader := make([]byte, optionalHeaderSize)
-	_, err = file.Read(optionalHeader)
-	if err != nil {
-		return info, err
-	}
-	
-	// Get entry point
-	info.Entrypoint = uint64(binary.LittleEndian.Uint32(optionalHeader[16:20]))
-	
-	// Read sections
-	for i := 0; i < int(numberOfSections); i++ {
-		sectionHeader := make([]byte, 40)
-		_, err := file.Read(sectionHeader)
-		if err != nil {
-			break
-		}
-		
-		// Extract section name
-		nameBytes := sectionHeader[0:8]
-		name := string(bytes.TrimRight(nameBytes, "\x00"))
-		
-		section := Section{
-			Name:        name,
-			VirtualAddr: uint64(binary.LittleEndian.Uint32(sectionHeader[12:16])),
-			RawSize:     binary.LittleEndian.Uint32(sectionHeader[16:20]),
-		}
-		
-		// Parse section characteristics
-		characteristics := binary.LittleEndian.Uint32(sectionHeader[36:40])
-		section.IsExecutable = (characteristics & 0x20000000) != 0
-		section.IsWritable = (characteristics & 0x80000000) != 0
-		section.IsReadable = (characteristics & 0x40000000) != 0
-		
-		info.Sections = append(info.Sections, section)
-	}
-	
-	// Check for packing indicators
-	info.IsPacked = checkPEPacking(info.Sections)
-	
-	return info, nil
-}
-
-func analyzeMachOHeaders(file *os.File, info ExecutableInfo) (ExecutableInfo, error) {
-	file.Seek(0, 0)
-	
-	// Read Mach-O header
-	header := make([]byte, 32)
-	_, err := file.Read(header)
-	if err != nil {
-		return info, err
-	}
-	
-	magic := binary.BigEndian.Uint32(header[0:4])
-	switch magic {
-	case 0xFEEDFACE:
-		info.Architecture = "32-bit"
-	case 0xFEEDFACF:
-		info.Architecture = "64-bit"
+func checkPEPacking(sections []Section) bool {
+	// Very small number of sections
+	if len(sections) > 0 && len(sections) < 3 {
+		return true
 	}
 	
-	// Basic Mach-O analysis (simplified)
-	info.Entrypoint = 0 // Would need to parse load commands for actual entry point
-	
-	return info, nil
-}
-
-func checkPEPacking(sections []Section) bool {
 	// Check for common packing indicators
+	suspiciousNames := []string{"UPX", "ASPACK", "PECOMPACT", "FSG", "THEMIDA"}
 	for _, section := range sections {
-		// Very small number of sections
-		if len(sections) < 3 {
-			return true
-		}
-		
 		// Sections with suspicious names
-		suspiciousNames := []string{"UPX", "ASPack", "PECompact", "FSG", "Themida"}
 		for _, name := range suspiciousNames {
 			if strings.Contains(strings.ToUpper(section.Name), name) {
 				return true
 			}
 		}
 		
-		// Very high virtual size vs raw size ratio
-		if section.RawSize > 0 && float64(section.VirtualAddr)/float64(section.RawSize) > 10 {
+		// Code that unpacks into far more memory than it occupies on disk
+		if section.IsExecutable && section.RawSize > 0 && float64(section.VirtualSize)/float64(section.RawSize) > 10 {
+			return true
+		}
+		
+		// Compressed or encrypted code
+		if section.IsExecutable && section.Entropy > 7.2 {
 			return true
 		}
 	}
@@ -802,48 +808,23 @@ func CheckFilePermissions(filePath string) (PermissionReport, error) {
 func ScanForViruses(filePath string, signatures []VirusSignature) ([]Threat, error) {
 	var threats []Threat
 	
-	file, err := os.Open(filePath)
+	// Stream the file through one automaton holding every pattern, so
+	// matches that straddle read boundaries are found too
+	matches, err := signatureRuleSet(signatures).ScanFile(filePath)
 	if err != nil {
 		return threats, err
 	}
-	defer file.Close()
 	
-	// Read file in chunks for memory efficiency
-	const chunkSize = 64 * 1024
-	buffer := make([]byte, chunkSize)
-	offset := int64(0)
-	
-	for {
-		n, err := file.Read(buffer)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return threats, err
-		}
-		
-		// Scan current chunk against all signatures
-		for _, signature := range signatures {
-			pos := bytes.Index(buffer[:n], signature.Pattern)
-			if pos != -1 {
-				threat := Threat{
-					Name:        signature.Name,
-					Type:        "virus",
-					Severity:    signature.Severity,
-					Description: signature.Description,
-					Position:    offset + int64(pos),
-					Confidence:  1.0, // Exact signature match
-				}
-				threats = append(threats, threat)
-			}
-		}
-		
-		offset += int64(n)
-		
-		// If we read less than chunk size, we're at EOF
-		if n < chunkSize {
-			break
+	for _, m := range matches {
+		threat := Threat{
+			Name:        m.Rule,
+			Type:        "virus",
+			Severity:    m.Meta["severity"],
+			Description: m.Meta["description"],
+			Position:    m.Strings[0].Offset,
+			Confidence:  1.0, // Exact signature match
 		}
+		threats = append(threats, threat)
 	}
 	
 	return threats, nil
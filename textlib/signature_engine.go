@@ -0,0 +1,767 @@
+package textlib
+
+import (
+	"bytes"
+	"io"
+	"regexp/syntax"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	ruleScanChunk   = 64 << 10
+	ruleRegexLimit  = 4096    // longest regex match guaranteed across chunk boundaries, as in YARA
+	ruleMaxJump     = 1 << 16 // bound for open-ended hex jumps such as [4-]
+	ruleHeadSize    = 64 << 10
+	maxRuleMatches  = 10000 // stored per string; counts keep going
+	maxMatchData    = 512
+	maxHexVariants  = 16
+	tokenByte       = 0
+	tokenJump       = 1
+	tokenAlternates = 2
+)
+
+// patternToken is one element of a compiled text or hex string
+type patternToken struct {
+	kind     int
+	value    byte // byte tokens match when data&mask == value
+	mask     byte
+	fold     bool // compare the lowercased data byte instead
+	min, max int  // jump bounds
+	alts     [][]patternToken
+}
+
+func (t patternToken) matches(b byte) bool {
+	if t.fold {
+		return lowerASCII(b) == t.value
+	}
+	return b&t.mask == t.value
+}
+
+func lowerASCII(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+func lowerASCIIBytes(data []byte) []byte {
+	lowered := make([]byte, len(data))
+	for i, b := range data {
+		lowered[i] = lowerASCII(b)
+	}
+	return lowered
+}
+
+// tokensWidth returns the shortest and longest data the tokens can match
+func tokensWidth(tokens []patternToken) (int, int) {
+	lo, hi := 0, 0
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenByte:
+			lo++
+			hi++
+		case tokenJump:
+			lo += t.min
+			hi += t.max
+		case tokenAlternates:
+			altLo, altHi := -1, 0
+			for _, alt := range t.alts {
+				l, h := tokensWidth(alt)
+				if altLo < 0 || l < altLo {
+					altLo = l
+				}
+				if h > altHi {
+					altHi = h
+				}
+			}
+			lo += altLo
+			hi += altHi
+		}
+	}
+	return lo, hi
+}
+
+// matchTokens matches tokens against data at pos, trying the shortest jumps
+// first, and returns the end of the match
+func matchTokens(tokens []patternToken, data []byte, pos int) (int, bool) {
+	for i, t := range tokens {
+		switch t.kind {
+		case tokenByte:
+			if pos >= len(data) || !t.matches(data[pos]) {
+				return 0, false
+			}
+			pos++
+		case tokenJump:
+			for n := t.min; n <= t.max && pos+n <= len(data); n++ {
+				if end, ok := matchTokens(tokens[i+1:], data, pos+n); ok {
+					return end, true
+				}
+			}
+			return 0, false
+		case tokenAlternates:
+			for _, alt := range t.alts {
+				if p, ok := matchTokens(alt, data, pos); ok {
+					if end, ok := matchTokens(tokens[i+1:], data, p); ok {
+						return end, true
+					}
+				}
+			}
+			return 0, false
+		}
+	}
+	return pos, true
+}
+
+// patternVariant is one concrete byte pattern of a string, anchored on its
+// most selective run of fixed bytes (the atom) that the automaton looks for
+type patternVariant struct {
+	str            int
+	tokens         []patternToken
+	atom           []byte
+	wide           bool
+	preMin, preMax int // distance from the match start to the atom
+	span           int // longest match
+}
+
+// Atoms rated below this are worth expanding alternatives to avoid
+const atomMinQuality = 4
+
+// atomQuality rates how rarely an atom occurs: each distinct byte counts,
+// bytes that fill binaries (padding, NOPs, spaces) for half as much
+func atomQuality(atom []byte) int {
+	var seen [256]bool
+	q := 0
+	for _, b := range atom {
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		switch b {
+		case 0x00, 0x20, 0x90, 0xcc, 0xff:
+			q++
+		default:
+			q += 2
+		}
+	}
+	return q
+}
+
+// newPatternVariants anchors tokens on an atom, expanding top-level
+// alternatives when there is no selective fixed run outside them
+func newPatternVariants(str int, tokens []patternToken, wide bool) []patternVariant {
+	bestStart, bestLen, bestQuality := 0, 0, 0
+	for i := 0; i < len(tokens); {
+		if tokens[i].kind != tokenByte || tokens[i].mask != 0xff {
+			i++
+			continue
+		}
+		j := i
+		var run []byte
+		for j < len(tokens) && tokens[j].kind == tokenByte && tokens[j].mask == 0xff {
+			run = append(run, tokens[j].value)
+			j++
+		}
+		if q := atomQuality(run); q > bestQuality || q == bestQuality && j-i > bestLen {
+			bestStart, bestLen, bestQuality = i, j-i, q
+		}
+		i = j
+	}
+
+	// A run like a NOP sled matches all over some data, so anchor on the
+	// alternatives instead if each gives a better atom
+	if bestQuality < atomMinQuality {
+		for i, t := range tokens {
+			if t.kind != tokenAlternates {
+				continue
+			}
+			var variants []patternVariant
+			better := true
+			for _, alt := range t.alts {
+				expanded := append(append(append([]patternToken{}, tokens[:i]...), alt...), tokens[i+1:]...)
+				for _, v := range newPatternVariants(str, expanded, wide) {
+					better = better && atomQuality(v.atom) > bestQuality
+					variants = append(variants, v)
+				}
+			}
+			if bestLen == 0 || better && len(variants) <= maxHexVariants {
+				return variants
+			}
+			break
+		}
+	}
+	if bestLen == 0 {
+		return nil
+	}
+
+	v := patternVariant{str: str, tokens: tokens, wide: wide}
+	for _, t := range tokens[bestStart : bestStart+bestLen] {
+		v.atom = append(v.atom, t.value)
+	}
+	v.preMin, v.preMax = tokensWidth(tokens[:bestStart])
+	_, v.span = tokensWidth(tokens)
+	return []patternVariant{v}
+}
+
+// ahoCorasick is a multi-pattern byte automaton. Nodes are numbered in
+// breadth-first order and the shallowest ones, where a scan spends most of
+// its time, get a full transition row.
+type ahoCorasick struct {
+	nodes  []acNode
+	dense  []int32   // transitions of the first len(dense)/256 nodes
+	output []bool    // nodes where some pattern ends
+	start  [256]bool // data bytes that leave the root once lowercased
+}
+
+type acNode struct {
+	children map[byte]int32
+	fail     int32
+	out      []int32
+}
+
+// Most nodes given a dense transition row
+const acDenseNodes = 1024
+
+func newAhoCorasick(patterns map[int][]byte) *ahoCorasick {
+	trie := []acNode{{children: map[byte]int32{}}}
+	ids := make([]int, 0, len(patterns))
+	for id := range patterns {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		state := int32(0)
+		for _, b := range patterns[id] {
+			next, ok := trie[state].children[b]
+			if !ok {
+				next = int32(len(trie))
+				trie = append(trie, acNode{children: map[byte]int32{}})
+				trie[state].children[b] = next
+			}
+			state = next
+		}
+		trie[state].out = append(trie[state].out, int32(id))
+	}
+
+	// Renumber breadth-first, so failure links always point to an
+	// earlier node
+	order := []int32{0}
+	renumber := make([]int32, len(trie))
+	for i := 0; i < len(order); i++ {
+		children := trie[order[i]].children
+		for b := 0; b < 256; b++ {
+			if child, ok := children[byte(b)]; ok {
+				renumber[child] = int32(len(order))
+				order = append(order, child)
+			}
+		}
+	}
+	ac := &ahoCorasick{nodes: make([]acNode, len(trie))}
+	for i, old := range order {
+		n := &ac.nodes[i]
+		n.children = make(map[byte]int32, len(trie[old].children))
+		for b, child := range trie[old].children {
+			n.children[b] = renumber[child]
+		}
+		n.out = trie[old].out
+	}
+
+	// Failure links in breadth-first order, merging the outputs of each
+	// suffix and filling the dense rows
+	dense := min(len(ac.nodes), acDenseNodes)
+	ac.dense = make([]int32, dense*256)
+	ac.output = make([]bool, len(ac.nodes))
+	for state := range ac.nodes {
+		n := &ac.nodes[state]
+		if state > 0 {
+			n.out = append(n.out, ac.nodes[n.fail].out...)
+			for b, child := range n.children {
+				ac.nodes[child].fail = ac.step(n.fail, b)
+			}
+		}
+		ac.output[state] = len(n.out) > 0
+		if state == 0 {
+			for b := range n.children {
+				ac.start[b] = true
+				if b >= 'a' && b <= 'z' {
+					ac.start[b-'a'+'A'] = true
+				}
+			}
+		}
+		if state < dense {
+			row := ac.dense[state*256 : state*256+256]
+			for b := range row {
+				if child, ok := n.children[byte(b)]; ok {
+					row[b] = child
+				} else if state > 0 {
+					row[b] = ac.dense[int(n.fail)*256+b]
+				}
+			}
+		}
+	}
+	return ac
+}
+
+func (ac *ahoCorasick) step(state int32, b byte) int32 {
+	for {
+		if i := int(state)<<8 | int(b); i < len(ac.dense) {
+			return ac.dense[i]
+		}
+		if next, ok := ac.nodes[state].children[b]; ok {
+			return next
+		}
+		state = ac.nodes[state].fail
+	}
+}
+
+// regexLiteral is a literal every match of a regex string contains (or
+// one of several alternatives does), used as that regex's atom
+type regexLiteral struct {
+	str   int
+	text  []byte
+	reach int // most bytes of a match up to the literal's end
+}
+
+// regexLiterals returns literals one of which occurs in every match of
+// expr and the longest match expr can have, both capped at ruleRegexLimit.
+// The literals are nil when no useful set exists and the regex must run
+// over all of the data.
+func regexLiterals(expr string) ([]regexLiteral, int) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, ruleRegexLimit
+	}
+	lits := requiredLiterals(re)
+	if literalScore(lits) < 2 {
+		lits = nil
+	}
+	for i := range lits {
+		lits[i].reach = min(lits[i].reach, ruleRegexLimit)
+	}
+	return lits, regexWidth(re)
+}
+
+func requiredLiterals(re *syntax.Regexp) []regexLiteral {
+	switch re.Op {
+	case syntax.OpLiteral:
+		text := []byte(string(re.Rune))
+		if re.Flags&syntax.FoldCase == 0 {
+			return []regexLiteral{{text: text, reach: len(text)}}
+		}
+		// Unicode folding maps k and s to non-ASCII runes too, so only
+		// the longest run of other ASCII letters is a safe folded atom
+		var best regexLiteral
+		run := 0
+		for i, r := range re.Rune {
+			if r >= 0x80 || r == 'k' || r == 'K' || r == 's' || r == 'S' {
+				run = 0
+				continue
+			}
+			run++
+			if run > len(best.text) {
+				best = regexLiteral{
+					text:  bytes.ToLower([]byte(string(re.Rune[i+1-run : i+1]))),
+					reach: (i+1-run)*utf8.UTFMax + run,
+				}
+			}
+		}
+		if best.text == nil {
+			return nil
+		}
+		return []regexLiteral{best}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var best []regexLiteral
+		prefix, bestPrefix := 0, 0
+		for _, sub := range re.Sub {
+			if lits := requiredLiterals(sub); literalScore(lits) > literalScore(best) {
+				best, bestPrefix = lits, prefix
+			}
+			prefix = min(prefix+regexWidth(sub), ruleRegexLimit)
+		}
+		for i := range best {
+			best[i].reach += bestPrefix
+		}
+		return best
+	case syntax.OpAlternate:
+		var all []regexLiteral
+		for _, sub := range re.Sub {
+			lits := requiredLiterals(sub)
+			if lits == nil {
+				return nil
+			}
+			all = append(all, lits...)
+		}
+		return all
+	}
+	return nil
+}
+
+// literalScore rates a literal set by its shortest member
+func literalScore(lits []regexLiteral) int {
+	score := 0
+	for i, lit := range lits {
+		if i == 0 || len(lit.text) < score {
+			score = len(lit.text)
+		}
+	}
+	return score
+}
+
+// regexWidth bounds the bytes a match of re can span, up to ruleRegexLimit
+func regexWidth(re *syntax.Regexp) int {
+	width := 0
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 {
+				width += utf8.UTFMax
+			} else {
+				width += utf8.RuneLen(r)
+			}
+		}
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		width = utf8.UTFMax
+	case syntax.OpCapture, syntax.OpQuest:
+		width = regexWidth(re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus:
+		width = ruleRegexLimit
+	case syntax.OpRepeat:
+		if re.Max < 0 {
+			width = ruleRegexLimit
+		} else {
+			width = re.Max * regexWidth(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			width += regexWidth(sub)
+		}
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			width = max(width, regexWidth(sub))
+		}
+	}
+	return min(width, ruleRegexLimit)
+}
+
+// atomHit is an atom occurrence ending at end, waiting for verification
+type atomHit struct {
+	variant int
+	end     int64
+}
+
+// regexHit is the range of starts a regex match around a literal can have
+type regexHit struct {
+	from, to int64
+}
+
+// ruleScan holds the streaming state of one scan. Only a window of the
+// input is kept: enough before the newest byte to verify any atom that
+// may still appear, plus whatever pending hits and regexes still need.
+type ruleScan struct {
+	rs      *RuleSet
+	buf     []byte
+	base    int64 // stream offset of buf[0]
+	size    int64
+	head    []byte
+	state   int32 // of the atom automaton
+	pending []atomHit
+
+	regexNext []int64      // per string: where the next regex search starts
+	regexHits [][]regexHit // per gated regex: literal occurrences not yet searched
+	matches   [][]StringMatch
+	counts    []int
+	seen      []map[int64]bool
+}
+
+func newRuleScan(rs *RuleSet) *ruleScan {
+	return &ruleScan{
+		rs:        rs,
+		regexNext: make([]int64, len(rs.strings)),
+		regexHits: make([][]regexHit, len(rs.strings)),
+		matches:   make([][]StringMatch, len(rs.strings)),
+		counts:    make([]int, len(rs.strings)),
+		seen:      make([]map[int64]bool, len(rs.strings)),
+	}
+}
+
+// run streams r through the scan in fixed-size chunks
+func (s *ruleScan) run(r io.Reader) error {
+	chunk := make([]byte, ruleScanChunk)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			s.feed(chunk[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.process(true)
+	for i := range s.matches {
+		sort.SliceStable(s.matches[i], func(a, b int) bool { return s.matches[i][a].Offset < s.matches[i][b].Offset })
+	}
+	return nil
+}
+
+func (s *ruleScan) feed(data []byte) {
+	if len(s.head) < ruleHeadSize {
+		s.head = append(s.head, data[:min(len(data), ruleHeadSize-len(s.head))]...)
+	}
+	off := s.base + int64(len(s.buf))
+	s.buf = append(s.buf, data...)
+	s.size += int64(len(data))
+
+	if ac := s.rs.atoms; ac != nil {
+		state := s.state
+		for i, b := range data {
+			if state == 0 && !ac.start[b] {
+				continue
+			}
+			if state = ac.step(state, lowerASCII(b)); ac.output[state] {
+				for _, id := range ac.nodes[state].out {
+					s.hit(int(id), off+int64(i)+1)
+				}
+			}
+		}
+		s.state = state
+	}
+
+	s.process(false)
+	s.trim()
+}
+
+// hit queues an automaton match: string atoms for verification, regex
+// literals as places to run their regex
+func (s *ruleScan) hit(id int, end int64) {
+	if id < len(s.rs.variants) {
+		s.pending = append(s.pending, atomHit{id, end})
+		return
+	}
+	lit := &s.rs.literals[id-len(s.rs.variants)]
+	s.regexHits[lit.str] = append(s.regexHits[lit.str], regexHit{end - int64(lit.reach), end - int64(len(lit.text)) + 1})
+}
+
+// process verifies the hits whose longest possible match is buffered and
+// runs the regexes over the settled part of the window
+func (s *ruleScan) process(eof bool) {
+	end := s.base + int64(len(s.buf))
+	kept := s.pending[:0]
+	for _, hit := range s.pending {
+		v := &s.rs.variants[hit.variant]
+		atomStart := hit.end - int64(len(v.atom))
+		if !eof && atomStart-int64(v.preMin)+int64(v.span)+2 > end {
+			kept = append(kept, hit)
+			continue
+		}
+		s.verify(v, atomStart)
+	}
+	s.pending = kept
+
+	for _, idx := range s.rs.regexes {
+		if !s.rs.strings[idx].gated {
+			limit := end
+			if !eof {
+				limit -= ruleRegexLimit
+			}
+			s.searchRegex(idx, s.regexNext[idx], limit, end)
+			continue
+		}
+		// Overlapping start ranges share one search, which may read
+		// up to the regex width past the last start
+		width := int64(s.rs.strings[idx].width)
+		hits := s.regexHits[idx]
+		for len(hits) > 0 {
+			from, to, n := hits[0].from, hits[0].to, 1
+			for n < len(hits) && hits[n].from <= to {
+				from, to = min64(from, hits[n].from), max64(to, hits[n].to)
+				n++
+			}
+			if !eof && to+width > end {
+				break
+			}
+			s.searchRegex(idx, max64(from, s.base), to, min64(to+width, end))
+			hits = hits[n:]
+		}
+		s.regexHits[idx] = append(s.regexHits[idx][:0], hits...)
+	}
+}
+
+// searchRegex records the regex matches starting in [from, to), looking at
+// no data past stop
+func (s *ruleScan) searchRegex(idx int, from, to, stop int64) {
+	if from < s.regexNext[idx] {
+		from = s.regexNext[idx]
+	}
+	if from >= to {
+		return
+	}
+	str := &s.rs.strings[idx]
+	for _, loc := range str.regex.FindAllIndex(s.buf[from-s.base:stop-s.base], -1) {
+		start := from + int64(loc[0])
+		if start >= to {
+			break
+		}
+		i, j := int(start-s.base), int(from-s.base)+loc[1]
+		if j == i || (str.fullword && !isFullword(s.buf, i, j, false)) {
+			continue
+		}
+		s.record(idx, start, s.buf[i:j])
+		s.regexNext[idx] = start + int64(j-i)
+	}
+	if s.regexNext[idx] < to {
+		s.regexNext[idx] = to
+	}
+}
+
+func (s *ruleScan) verify(v *patternVariant, atomStart int64) {
+	str := &s.rs.strings[v.str]
+	for pre := v.preMin; pre <= v.preMax; pre++ {
+		start := atomStart - int64(pre)
+		if start < s.base {
+			break
+		}
+		i := int(start - s.base)
+		j, ok := matchTokens(v.tokens, s.buf, i)
+		if !ok || (str.fullword && !isFullword(s.buf, i, j, v.wide)) {
+			continue
+		}
+		s.record(v.str, start, s.buf[i:j])
+	}
+}
+
+func (s *ruleScan) record(idx int, offset int64, data []byte) {
+	if s.rs.strings[idx].dedupe {
+		if s.seen[idx] == nil {
+			s.seen[idx] = make(map[int64]bool)
+		}
+		if s.seen[idx][offset] {
+			return
+		}
+		s.seen[idx][offset] = true
+	}
+	s.counts[idx]++
+	if len(s.matches[idx]) < maxRuleMatches {
+		s.matches[idx] = append(s.matches[idx], StringMatch{
+			Identifier: s.rs.strings[idx].id,
+			Offset:     offset,
+			Length:     len(data),
+			Data:       append([]byte(nil), data[:min(len(data), maxMatchData)]...),
+		})
+	}
+}
+
+// trim drops the part of the window no future verification can reach
+func (s *ruleScan) trim() {
+	keepFrom := s.base + int64(len(s.buf)) - int64(s.rs.lookback)
+	for _, hit := range s.pending {
+		v := &s.rs.variants[hit.variant]
+		if need := hit.end - int64(len(v.atom)+v.preMax+1); need < keepFrom {
+			keepFrom = need
+		}
+	}
+	for _, idx := range s.rs.regexes {
+		need := s.regexNext[idx] - 1
+		if s.rs.strings[idx].gated {
+			need = keepFrom
+			for _, hit := range s.regexHits[idx] {
+				need = min64(need, hit.from-1)
+			}
+		}
+		if need < keepFrom {
+			keepFrom = need
+		}
+	}
+	if keepFrom <= s.base {
+		return
+	}
+	s.buf = append(s.buf[:0], s.buf[keepFrom-s.base:]...)
+	s.base = keepFrom
+	for _, seen := range s.seen {
+		for offset := range seen {
+			if offset < s.base {
+				delete(seen, offset)
+			}
+		}
+	}
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// isFullword reports whether data[i:j] is delimited by non-alphanumeric
+// characters; wide strings look at the UTF-16LE code units around them
+func isFullword(data []byte, i, j int, wide bool) bool {
+	if wide {
+		if i >= 2 && data[i-1] == 0 && isAlnumByte(data[i-2]) {
+			return false
+		}
+		return !(j+1 < len(data) && data[j+1] == 0 && isAlnumByte(data[j]))
+	}
+	if i > 0 && isAlnumByte(data[i-1]) {
+		return false
+	}
+	return !(j < len(data) && isAlnumByte(data[j]))
+}
+
+func isAlnumByte(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// readRuleInt reads a little- or big-endian integer for uint16(0) style
+// conditions, returning false past the end of data
+func readRuleInt(data io.ReaderAt, offset int64, size int, signed, bigEndian bool) (int64, bool) {
+	if offset < 0 {
+		return 0, false
+	}
+	b := make([]byte, size)
+	if n, _ := data.ReadAt(b, offset); n < size {
+		return 0, false
+	}
+	if !bigEndian {
+		for i := 0; i < size/2; i++ {
+			b[i], b[size-1-i] = b[size-1-i], b[i]
+		}
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	if signed {
+		shift := 64 - 8*uint(size)
+		return int64(v<<shift) >> shift, true
+	}
+	return int64(v), true
+}
+
+// scanRules runs rs over r and evaluates every rule at the end of the stream
+func scanRules(rs *RuleSet, r io.Reader) ([]RuleMatch, error) {
+	s := newRuleScan(rs)
+	if err := s.run(r); err != nil {
+		return nil, err
+	}
+	data, ok := r.(io.ReaderAt)
+	if !ok {
+		data = bytes.NewReader(s.head)
+	}
+	return rs.evaluate(s, data), nil
+}
@@ -0,0 +1,276 @@
+package textlib
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// smallReads returns at most n bytes per Read
+type smallReads struct {
+	r io.Reader
+	n int
+}
+
+func (s smallReads) Read(p []byte) (int, error) {
+	if len(p) > s.n {
+		p = p[:s.n]
+	}
+	return s.r.Read(p)
+}
+
+func TestAhoCorasick(t *testing.T) {
+	ac := newAhoCorasick(map[int][]byte{0: []byte("he"), 1: []byte("she"), 2: []byte("his"), 3: []byte("hers")})
+	type hit struct{ id, end int }
+	var got []hit
+	state := int32(0)
+	for i, b := range []byte("ushers ahishe") {
+		state = ac.step(state, b)
+		for _, id := range ac.nodes[state].out {
+			got = append(got, hit{int(id), i + 1})
+		}
+	}
+	sort.Slice(got, func(i, j int) bool {
+		return got[i].end < got[j].end || got[i].end == got[j].end && got[i].id < got[j].id
+	})
+	want := []hit{{0, 4}, {1, 4}, {3, 6}, {2, 11}, {0, 13}, {1, 13}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	tests := []struct {
+		hex  string
+		data string
+		end  int
+		ok   bool
+	}{
+		{"41 42 43", "ABCD", 3, true},
+		{"41 ?? 43", "AxC", 3, true},
+		{"4? 4?", "AO", 2, true},
+		{"?1 42", "qB", 2, true},
+		{"?1 42", "rB", 0, false},
+		{"41 [1-3] 44", "AxxD", 4, true},
+		{"41 [1-3] 44", "AD", 0, false},
+		{"41 [2-] 44", "AxxxxxxD", 8, true},
+		{"41 ( 42 | 43 44 ) 45", "ACDE", 4, true},
+		{"41 ( 42 | 43 44 ) 45", "ACE", 0, false},
+		{"41 ( 42 [1] | 42 ) 43", "ABC", 3, true},
+		{"41 [0-2] ( 42 | 43 ) 44", "AxCD", 4, true},
+	}
+	for _, tt := range tests {
+		tokens, err := parseHexString(tt.hex)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.hex, err)
+		}
+		end, ok := matchTokens(tokens, []byte(tt.data), 0)
+		if ok != tt.ok || end != tt.end {
+			t.Errorf("{ %s } on %q: got %d %v, want %d %v", tt.hex, tt.data, end, ok, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestPatternVariantAtoms(t *testing.T) {
+	tests := []struct {
+		hex   string
+		atoms []string
+	}{
+		{"4D 5A ?? ?? 50 45 00 00", []string{"PE\x00\x00"}},
+		{"90 90 90 90 90 90 90 90 [0-16] ( 31 C0 | 33 C0 )", []string{"1\xc0", "3\xc0"}},
+		{"90 90 90 90 ( 90 | 00 )", []string{"\x90\x90\x90\x90"}},
+		{"( 41 42 | 43 44 ) ?? 45", []string{"AB", "CD"}},
+	}
+	for _, tt := range tests {
+		tokens, err := parseHexString(tt.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var atoms []string
+		for _, v := range newPatternVariants(0, tokens, false) {
+			atoms = append(atoms, string(v.atom))
+		}
+		if !reflect.DeepEqual(atoms, tt.atoms) {
+			t.Errorf("%s: got atoms %q, want %q", tt.hex, atoms, tt.atoms)
+		}
+	}
+}
+
+func TestRegexLiterals(t *testing.T) {
+	tests := []struct {
+		expr  string
+		want  []string
+		reach []int
+		width int
+	}{
+		{`abc[0-9]+def`, []string{"abc"}, []int{3}, ruleRegexLimit},
+		{`[0-9]{2}-wq[0-9]`, []string{"-wq"}, []int{11}, 15},
+		{`(?i)Net\.WebClient`, []string{"net.webclient"}, []int{13}, 52},
+		{`(?i)invoke`, []string{"invo"}, []int{4}, 24},
+		{`(foo|barbaz)x`, []string{"foo", "barbaz"}, []int{3, 6}, 7},
+		{`(foo|[a-z])x`, nil, nil, 5},
+		{`a.b`, nil, nil, 6},
+		{`(ab)?cd*`, nil, nil, ruleRegexLimit},
+	}
+	for _, tt := range tests {
+		lits, width := regexLiterals(tt.expr)
+		var got []string
+		var reach []int
+		for _, lit := range lits {
+			got = append(got, string(lit.text))
+			reach = append(reach, lit.reach)
+		}
+		if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(reach, tt.reach) || width != tt.width {
+			t.Errorf("%s: got %q %v %d, want %q %v %d", tt.expr, got, reach, width, tt.want, tt.reach, tt.width)
+		}
+	}
+}
+
+func TestRuleScanStreaming(t *testing.T) {
+	rs, err := CompileRules(`
+rule Stream {
+    strings:
+        $text = "boundary-marker"
+        $nocase = "MiXeD" nocase
+        $wide = "wide" wide
+        $hex = { DE AD [2-6] BE EF }
+        $alt = { ( CA FE | FA CE ) 00 }
+        $re = /tok[0-9]{3}-[a-z]{4}/
+        $pre = /[0-9]{2}-wq[0-9]/
+        $bare = /[0-9]{4}/
+    condition:
+        any of them
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 3*ruleScanChunk+1234)
+	for i := range data {
+		data[i] = byte('a' + rng.Intn(20))
+	}
+	// Plant every string across each chunk boundary and at random spots
+	plant := [][]byte{
+		[]byte("boundary-marker"), []byte("mixed"), []byte("MIXED"), []byte("w\x00i\x00d\x00e\x00"),
+		{0xde, 0xad, 1, 2, 3, 0xbe, 0xef}, {0xfa, 0xce, 0}, []byte("tok123-abcd"), []byte("77-wq5"), []byte("2024"),
+	}
+	for k := 1; k <= 3; k++ {
+		for i, p := range plant {
+			copy(data[k*ruleScanChunk-len(p)/2+i*40:], p)
+			copy(data[rng.Intn(len(data)-len(p)):], p)
+		}
+	}
+
+	scan := func(r io.Reader) map[string][]int64 {
+		matches, err := rs.Scan(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets := make(map[string][]int64)
+		for _, m := range matches {
+			for _, s := range m.Strings {
+				offsets[s.Identifier] = append(offsets[s.Identifier], s.Offset)
+			}
+		}
+		return offsets
+	}
+	whole := scan(bytes.NewReader(data))
+
+	// Reference counts from a direct search
+	count := func(pattern []byte, fold bool) int {
+		n := 0
+		for i := range data {
+			j := i + len(pattern)
+			if j <= len(data) && (bytes.Equal(data[i:j], pattern) || fold && bytes.EqualFold(data[i:j], pattern)) {
+				n++
+			}
+		}
+		return n
+	}
+	want := map[string]int{
+		"$text":   count([]byte("boundary-marker"), false),
+		"$nocase": count([]byte("mixed"), true),
+		"$wide":   count([]byte("w\x00i\x00d\x00e\x00"), false),
+		"$hex":    count([]byte{0xde, 0xad, 1, 2, 3, 0xbe, 0xef}, false),
+		"$alt":    count([]byte{0xfa, 0xce, 0}, false),
+		"$re":     count([]byte("tok123-abcd"), false),
+		"$pre":    len(regexp.MustCompile(`[0-9]{2}-wq[0-9]`).FindAllIndex(data, -1)),
+		"$bare":   len(regexp.MustCompile(`[0-9]{4}`).FindAllIndex(data, -1)),
+	}
+	for id, n := range want {
+		if len(whole[id]) != n || n < 3 {
+			t.Errorf("%s: got %d matches, want %d", id, len(whole[id]), n)
+		}
+	}
+
+	for _, size := range []int{7, 1000, 4097} {
+		if got := scan(smallReads{bytes.NewReader(data), size}); !reflect.DeepEqual(got, whole) {
+			t.Errorf("%d-byte reads: got %v, want %v", size, got, whole)
+		}
+	}
+}
+
+func TestRuleScanIntReadsWithoutReaderAt(t *testing.T) {
+	rs, err := CompileRules("rule PE { condition: uint16(0) == 0x5A4D and uint32(0x3C) == 0x40 }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 200)
+	copy(data, "MZ")
+	data[0x3c] = 0x40
+	matches, err := rs.Scan(smallReads{bytes.NewReader(data), 16})
+	if err != nil || len(matches) != 1 {
+		t.Errorf("Expected a match, got %v, %v", matches, err)
+	}
+}
+
+func TestScanForVirusesAcrossChunks(t *testing.T) {
+	data := bytes.Repeat([]byte{'.'}, 2*ruleScanChunk)
+	copy(data[ruleScanChunk-5:], "SPLIT_PATTERN")
+	copy(data[ruleScanChunk+100:], "SPLIT_PATTERN")
+	path := filepath.Join(t.TempDir(), "split.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	threats, err := ScanForViruses(path, []VirusSignature{
+		{Name: "Split", Pattern: []byte("SPLIT_PATTERN"), Description: "d", Severity: "high"},
+		{Name: "Empty", Description: "an empty pattern never matches", Severity: "low"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Threat{{Name: "Split", Type: "virus", Severity: "high", Description: "d", Position: ruleScanChunk - 5, Confidence: 1}}
+	if !reflect.DeepEqual(threats, want) {
+		t.Errorf("got %+v, want %+v", threats, want)
+	}
+}
+
+func benchmarkCommonRules(b *testing.B, data []byte) {
+	rs := GetCommonVirusRules()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rs.Scan(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRuleScanRandom(b *testing.B) {
+	data := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	benchmarkCommonRules(b, data)
+}
+
+// A NOP sled would match a run of NOPs at every offset
+func BenchmarkRuleScanNOPSled(b *testing.B) {
+	benchmarkCommonRules(b, bytes.Repeat([]byte{0x90}, 1<<20))
+}
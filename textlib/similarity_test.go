@@ -0,0 +1,26 @@
+package textlib
+
+import "testing"
+
+func TestCalculateDiffOperationsReproduceTexts(t *testing.T) {
+	pairs := [][2]string{
+		{"abcabba", "cbabac"},
+		{"hello world", "world hello"},
+		{"héllo wörld", "hallo wörld!"},
+		{"", "added"},
+	}
+	for _, p := range pairs {
+		var oldText, newText string
+		for _, op := range CalculateDiff(p[0], p[1]).Operations {
+			if op.Type != "insert" {
+				oldText += op.Text
+			}
+			if op.Type != "delete" {
+				newText += op.Text
+			}
+		}
+		if oldText != p[0] || newText != p[1] {
+			t.Errorf("Operations for %q -> %q rebuild %q -> %q", p[0], p[1], oldText, newText)
+		}
+	}
+}
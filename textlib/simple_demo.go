@@ -1,3 +1,5 @@
+//go:build ignore
+
 // Simple demo of RL-optimized functions
 package main
 
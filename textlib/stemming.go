@@ -0,0 +1,323 @@
+package textlib
+
+import (
+	"strings"
+)
+
+// Stemmer reduces inflected or derived words to a common stem
+type Stemmer interface {
+	Stem(word string) string
+}
+
+// Lemmatizer maps an inflected word to its dictionary form. The Penn
+// Treebank tag is a hint for choosing between noun, verb and adjective
+// readings; an empty tag lets the lemmatizer guess.
+type Lemmatizer interface {
+	Lemmatize(word, tag string) string
+}
+
+// EnglishStemmer implements the Porter2 (Snowball English) stemming algorithm
+type EnglishStemmer struct{}
+
+var porter2Exceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli",
+	"only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe",
+	"atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+var porter2PostStep1aExceptions = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+// Stem returns the Porter2 stem of a lowercase English word
+func (EnglishStemmer) Stem(word string) string {
+	if len(word) <= 2 || !isASCIILowerWord(word) {
+		return word
+	}
+	if exception, ok := porter2Exceptions[word]; ok {
+		return exception
+	}
+
+	w := []byte(strings.TrimPrefix(word, "'"))
+	if len(w) == 0 {
+		return word
+	}
+
+	// Mark consonant y as Y so it is not treated as a vowel
+	if w[0] == 'y' {
+		w[0] = 'Y'
+	}
+	for i := 1; i < len(w); i++ {
+		if w[i] == 'y' && isPorterVowel(w[i-1]) {
+			w[i] = 'Y'
+		}
+	}
+
+	r1, r2 := porter2Regions(w)
+
+	w = porter2Step0(w)
+	w = porter2Step1a(w)
+	if porter2PostStep1aExceptions[string(w)] {
+		return string(w)
+	}
+	w = porter2Step1b(w, r1)
+	w = porter2Step1c(w)
+	w = porter2Step2(w, r1)
+	w = porter2Step3(w, r1, r2)
+	w = porter2Step4(w, r2)
+	w = porter2Step5(w, r1, r2)
+
+	return strings.ReplaceAll(string(w), "Y", "y")
+}
+
+func isASCIILowerWord(word string) bool {
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if (c < 'a' || c > 'z') && c != '\'' {
+			return false
+		}
+	}
+	return true
+}
+
+func isPorterVowel(c byte) bool {
+	return c == 'a' || c == 'e' || c == 'i' || c == 'o' || c == 'u' || c == 'y'
+}
+
+func porter2Regions(w []byte) (int, int) {
+	r1 := len(w)
+	s := string(w)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(s, prefix) {
+			r1 = len(prefix)
+			break
+		}
+	}
+	if r1 == len(w) {
+		r1 = porter2RegionStart(w, 0)
+	}
+	r2 := porter2RegionStart(w, r1)
+	return r1, r2
+}
+
+// porter2RegionStart finds the region after the first non-vowel following a vowel
+func porter2RegionStart(w []byte, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isPorterVowel(w[i]) && isPorterVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func porter2HasSuffix(w []byte, suffix string) bool {
+	return strings.HasSuffix(string(w), suffix)
+}
+
+func porter2ContainsVowel(w []byte) bool {
+	for _, c := range w {
+		if isPorterVowel(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// porter2EndsShortSyllable reports whether w ends in a short syllable
+func porter2EndsShortSyllable(w []byte) bool {
+	n := len(w)
+	if n == 2 {
+		return isPorterVowel(w[0]) && !isPorterVowel(w[1])
+	}
+	if n >= 3 {
+		c := w[n-1]
+		return !isPorterVowel(w[n-3]) && isPorterVowel(w[n-2]) &&
+			!isPorterVowel(c) && c != 'w' && c != 'x' && c != 'Y'
+	}
+	return false
+}
+
+func porter2IsShortWord(w []byte, r1 int) bool {
+	return r1 >= len(w) && porter2EndsShortSyllable(w)
+}
+
+func porter2Step0(w []byte) []byte {
+	for _, suffix := range []string{"'s'", "'s", "'"} {
+		if porter2HasSuffix(w, suffix) {
+			return w[:len(w)-len(suffix)]
+		}
+	}
+	return w
+}
+
+func porter2Step1a(w []byte) []byte {
+	switch {
+	case porter2HasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case porter2HasSuffix(w, "ied") || porter2HasSuffix(w, "ies"):
+		if len(w) > 4 {
+			return append(w[:len(w)-3], 'i')
+		}
+		return w[:len(w)-1]
+	case porter2HasSuffix(w, "us") || porter2HasSuffix(w, "ss"):
+		return w
+	case porter2HasSuffix(w, "s"):
+		// Delete if the preceding part contains a vowel not immediately before the s
+		if len(w) > 2 && porter2ContainsVowel(w[:len(w)-2]) {
+			return w[:len(w)-1]
+		}
+	}
+	return w
+}
+
+func porter2Step1b(w []byte, r1 int) []byte {
+	for _, suffix := range []string{"eedly", "eed"} {
+		if porter2HasSuffix(w, suffix) {
+			if len(w)-len(suffix) >= r1 {
+				return w[:len(w)-len(suffix)+2]
+			}
+			return w
+		}
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if !porter2HasSuffix(w, suffix) {
+			continue
+		}
+		stem := w[:len(w)-len(suffix)]
+		if !porter2ContainsVowel(stem) {
+			return w
+		}
+
+		switch {
+		case porter2HasSuffix(stem, "at"), porter2HasSuffix(stem, "bl"), porter2HasSuffix(stem, "iz"):
+			return append(stem, 'e')
+		case porter2EndsDouble(stem):
+			return stem[:len(stem)-1]
+		case porter2IsShortWord(stem, r1):
+			return append(stem, 'e')
+		}
+		return stem
+	}
+
+	return w
+}
+
+func porter2EndsDouble(w []byte) bool {
+	n := len(w)
+	if n < 2 || w[n-1] != w[n-2] {
+		return false
+	}
+	return strings.IndexByte("bdfgmnprt", w[n-1]) >= 0
+}
+
+func porter2Step1c(w []byte) []byte {
+	n := len(w)
+	if n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isPorterVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+	return w
+}
+
+var porter2Step2Suffixes = []struct {
+	suffix      string
+	replacement string
+}{
+	{"ization", "ize"}, {"ational", "ate"}, {"fulness", "ful"}, {"ousness", "ous"},
+	{"iveness", "ive"}, {"tional", "tion"}, {"biliti", "ble"}, {"lessli", "less"},
+	{"entli", "ent"}, {"ation", "ate"}, {"alism", "al"}, {"aliti", "al"},
+	{"ousli", "ous"}, {"iviti", "ive"}, {"fulli", "ful"}, {"enci", "ence"},
+	{"anci", "ance"}, {"abli", "able"}, {"izer", "ize"}, {"ator", "ate"},
+	{"alli", "al"}, {"bli", "ble"}, {"ogi", "og"}, {"li", ""},
+}
+
+func porter2Step2(w []byte, r1 int) []byte {
+	for _, rule := range porter2Step2Suffixes {
+		if !porter2HasSuffix(w, rule.suffix) {
+			continue
+		}
+		start := len(w) - len(rule.suffix)
+		if start < r1 {
+			return w
+		}
+
+		switch rule.suffix {
+		case "ogi":
+			if start == 0 || w[start-1] != 'l' {
+				return w
+			}
+		case "li":
+			if start == 0 || strings.IndexByte("cdeghkmnrt", w[start-1]) < 0 {
+				return w
+			}
+		}
+		return append(w[:start], rule.replacement...)
+	}
+	return w
+}
+
+var porter2Step3Suffixes = []struct {
+	suffix      string
+	replacement string
+}{
+	{"ational", "ate"}, {"tional", "tion"}, {"alize", "al"}, {"icate", "ic"},
+	{"iciti", "ic"}, {"ative", ""}, {"ical", "ic"}, {"ness", ""}, {"ful", ""},
+}
+
+func porter2Step3(w []byte, r1, r2 int) []byte {
+	for _, rule := range porter2Step3Suffixes {
+		if !porter2HasSuffix(w, rule.suffix) {
+			continue
+		}
+		start := len(w) - len(rule.suffix)
+		if start < r1 || (rule.suffix == "ative" && start < r2) {
+			return w
+		}
+		return append(w[:start], rule.replacement...)
+	}
+	return w
+}
+
+var porter2Step4Suffixes = []string{
+	"ement", "ance", "ence", "able", "ible", "ment", "ant", "ent",
+	"ism", "ate", "iti", "ous", "ive", "ize", "ion", "al", "er", "ic",
+}
+
+func porter2Step4(w []byte, r2 int) []byte {
+	for _, suffix := range porter2Step4Suffixes {
+		if !porter2HasSuffix(w, suffix) {
+			continue
+		}
+		start := len(w) - len(suffix)
+		if start < r2 {
+			return w
+		}
+		if suffix == "ion" && (start == 0 || (w[start-1] != 's' && w[start-1] != 't')) {
+			return w
+		}
+		return w[:start]
+	}
+	return w
+}
+
+func porter2Step5(w []byte, r1, r2 int) []byte {
+	n := len(w)
+	if n == 0 {
+		return w
+	}
+
+	switch w[n-1] {
+	case 'e':
+		if n-1 >= r2 || (n-1 >= r1 && !porter2EndsShortSyllable(w[:n-1])) {
+			return w[:n-1]
+		}
+	case 'l':
+		if n-1 >= r2 && n > 1 && w[n-2] == 'l' {
+			return w[:n-1]
+		}
+	}
+	return w
+}
@@ -0,0 +1,236 @@
+package textlib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const testStreamText = `Introduction
+
+The quick brown fox jumps over the lazy dog. Dr. Smith watched it happen.
+It was a sunny day in the park.
+
+  Indented paragraph that spans
+two lines of text.
+
+CHAPTER ONE
+
+Machine learning models need training data. Data quality matters a lot.
+
+Final words here.`
+
+func collectSegments(t *testing.T, segments <-chan Segment, errc <-chan error) []Segment {
+	t.Helper()
+	var got []Segment
+	for seg := range segments {
+		got = append(got, seg)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Unexpected stream error: %v", err)
+	}
+	return got
+}
+
+func collectChunks(t *testing.T, chunks <-chan TextChunk, errc <-chan error) []TextChunk {
+	t.Helper()
+	var got []TextChunk
+	for chunk := range chunks {
+		got = append(got, chunk)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Unexpected stream error: %v", err)
+	}
+	return got
+}
+
+func TestSegmentTextStream(t *testing.T) {
+	// A tiny read size forces boundaries to straddle reads
+	opts := StreamOptions{ReadSize: 7}
+
+	for _, method := range []string{"paragraph", "sentence"} {
+		t.Run(method, func(t *testing.T) {
+			out, errc := SegmentTextStream(context.Background(), strings.NewReader(testStreamText), method, opts)
+			got := collectSegments(t, out, errc)
+			want := SegmentText(testStreamText, method).Segments
+
+			if len(got) != len(want) {
+				t.Fatalf("Expected %d segments, got %d", len(want), len(got))
+			}
+			for i := range want {
+				if got[i].Text != want[i].Text || got[i].Start != want[i].Start || got[i].End != want[i].End {
+					t.Errorf("Segment %d: expected %q at %d-%d, got %q at %d-%d",
+						i, want[i].Text, want[i].Start, want[i].End, got[i].Text, got[i].Start, got[i].End)
+				}
+			}
+		})
+	}
+
+	for _, method := range []string{"section", "topic", "semantic", "fixed"} {
+		t.Run(method, func(t *testing.T) {
+			out, errc := SegmentTextStream(context.Background(), strings.NewReader(testStreamText), method, opts)
+			got := collectSegments(t, out, errc)
+			if len(got) == 0 {
+				t.Fatal("Expected segments")
+			}
+			for i, seg := range got {
+				if seg.Type != method {
+					t.Errorf("Segment %d: expected type %s, got %s", i, method, seg.Type)
+				}
+				if seg.Start < 0 || seg.End > len(testStreamText) || seg.Start > seg.End {
+					t.Errorf("Segment %d: bad offsets %d-%d", i, seg.Start, seg.End)
+				}
+				if method != "topic" && method != "semantic" && !strings.HasPrefix(testStreamText[seg.Start:], seg.Text) {
+					t.Errorf("Segment %d: text %q not found at offset %d", i, seg.Text, seg.Start)
+				}
+			}
+		})
+	}
+}
+
+func TestSegmentTextStreamBoundedBuffer(t *testing.T) {
+	text := strings.Repeat("word ", 200)
+	out, errc := SegmentTextStream(context.Background(), strings.NewReader(text), "paragraph", StreamOptions{ReadSize: 16, MaxBufferSize: 64})
+	got := collectSegments(t, out, errc)
+
+	if len(got) < 2 {
+		t.Fatalf("Expected the paragraph to be split, got %d segments", len(got))
+	}
+	var words []string
+	for _, seg := range got {
+		if len(seg.Text) > 64 {
+			t.Errorf("Segment of %d bytes exceeds the buffer limit", len(seg.Text))
+		}
+		if seg.Metadata["forced_split"] != true && seg.End != len(strings.TrimSpace(text)) {
+			t.Errorf("Expected segment at %d to be marked forced_split", seg.Start)
+		}
+		if text[seg.Start:seg.End] != seg.Text {
+			t.Errorf("Segment text does not match offsets %d-%d", seg.Start, seg.End)
+		}
+		words = append(words, strings.Fields(seg.Text)...)
+	}
+	if len(words) != 200 {
+		t.Errorf("Expected 200 words across segments, got %d", len(words))
+	}
+}
+
+func TestChunkTextStream(t *testing.T) {
+	opts := StreamOptions{ReadSize: 11}
+
+	tests := []struct {
+		name     string
+		strategy ChunkingStrategy
+	}{
+		{"token", ChunkingStrategy{Method: "token", MaxTokens: 8, Overlap: 2}},
+		{"sliding", ChunkingStrategy{Method: "sliding", MaxTokens: 10, Overlap: 3}},
+		{"sentence", ChunkingStrategy{Method: "sentence", MaxTokens: 20}},
+		{"paragraph", ChunkingStrategy{Method: "paragraph", MaxTokens: 20}},
+		{"semantic", ChunkingStrategy{Method: "semantic", MaxTokens: 20, SemanticThreshold: 0.3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errc := ChunkTextStream(context.Background(), strings.NewReader(testStreamText), tt.strategy, opts)
+			got := collectChunks(t, out, errc)
+			want := ChunkText(testStreamText, tt.strategy)
+
+			if len(got) != len(want) {
+				t.Fatalf("Expected %d chunks, got %d", len(want), len(got))
+			}
+			for i := range want {
+				if got[i].Content != want[i].Content || got[i].TokenCount != want[i].TokenCount {
+					t.Errorf("Chunk %d: expected %q (%d tokens), got %q (%d tokens)",
+						i, want[i].Content, want[i].TokenCount, got[i].Content, got[i].TokenCount)
+				}
+				if got[i].OverlapEnd != want[i].OverlapEnd {
+					t.Errorf("Chunk %d: expected overlap %d, got %d", i, want[i].OverlapEnd, got[i].OverlapEnd)
+				}
+
+				// Offsets are stream bytes covering the chunk's words
+				span := testStreamText[got[i].StartOffset:got[i].EndOffset]
+				if strings.Join(strings.Fields(span), " ") != strings.Join(strings.Fields(got[i].Content), " ") {
+					t.Errorf("Chunk %d: offsets %d-%d do not cover its content", i, got[i].StartOffset, got[i].EndOffset)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkTextStreamMarkdown(t *testing.T) {
+	strategy := ChunkingStrategy{Method: "markdown", MaxTokens: 12}
+	want := ChunkText(testMarkdownDoc, strategy)
+
+	for _, readSize := range []int{5, 64, 4096} {
+		out, errc := ChunkTextStream(context.Background(), strings.NewReader(testMarkdownDoc), strategy, StreamOptions{ReadSize: readSize})
+		got := collectChunks(t, out, errc)
+
+		if len(got) != len(want) {
+			t.Fatalf("Read size %d: expected %d chunks, got %d", readSize, len(want), len(got))
+		}
+		for i := range want {
+			if got[i].Content != want[i].Content {
+				t.Errorf("Read size %d, chunk %d: expected %q, got %q", readSize, i, want[i].Content, got[i].Content)
+			}
+			if got[i].StartOffset != want[i].StartOffset || got[i].EndOffset != want[i].EndOffset {
+				t.Errorf("Read size %d, chunk %d: expected offsets %d-%d, got %d-%d",
+					readSize, i, want[i].StartOffset, want[i].EndOffset, got[i].StartOffset, got[i].EndOffset)
+			}
+			if got[i].Metadata["breadcrumb"] != want[i].Metadata["breadcrumb"] {
+				t.Errorf("Read size %d, chunk %d: expected breadcrumb %q, got %q",
+					readSize, i, want[i].Metadata["breadcrumb"], got[i].Metadata["breadcrumb"])
+			}
+		}
+	}
+}
+
+func TestFindNaturalBoundariesStream(t *testing.T) {
+	out, errc := FindNaturalBoundariesStream(context.Background(), strings.NewReader(testStreamText), StreamOptions{ReadSize: 9})
+
+	var got []int
+	for b := range out {
+		got = append(got, b)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Unexpected stream error: %v", err)
+	}
+
+	if want := FindNaturalBoundaries(testStreamText); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected boundaries %v, got %v", want, got)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	t.Run("read error", func(t *testing.T) {
+		boom := errors.New("boom")
+		r := io.MultiReader(strings.NewReader("First paragraph.\n\nSecond"), iotest.ErrReader(boom))
+		out, errc := SegmentTextStream(context.Background(), r, "paragraph", StreamOptions{})
+
+		var got []Segment
+		for seg := range out {
+			got = append(got, seg)
+		}
+		if err := <-errc; !errors.Is(err, boom) {
+			t.Errorf("Expected read error, got %v", err)
+		}
+		if len(got) != 1 || got[0].Text != "First paragraph." {
+			t.Errorf("Expected the complete paragraph before the error, got %v", got)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out, errc := ChunkTextStream(ctx, strings.NewReader(strings.Repeat("word ", 1000)), ChunkingStrategy{Method: "token", MaxTokens: 5}, StreamOptions{})
+
+		<-out
+		cancel()
+		for range out {
+		}
+		if err := <-errc; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
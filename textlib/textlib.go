@@ -15,83 +15,72 @@
 package textlib
 
 import (
-	"fmt"
 	"regexp"
 	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-// Position represents a position in text
+// Position represents a span of text. Start and End are byte offsets into
+// the analyzed string unless the field using it documents another unit, such
+// as line numbers; use OffsetMap to convert to rune or grapheme offsets.
 type Position struct {
 	Start int
 	End   int
 }
 
-// SplitIntoSentences splits text into individual sentences
+// SplitIntoSentences splits text into individual sentences using the
+// UAX #29 sentence boundary rules, without breaking after common
+// abbreviations such as "Dr." or "e.g."
 func SplitIntoSentences(text string) []string {
-	if text == "" {
-		return []string{}
-	}
-	
-	// Handle common abbreviations
-	abbreviations := []string{
-		"Mr.", "Mrs.", "Ms.", "Dr.", "Prof.", "Sr.", "Jr.",
-		"vs.", "etc.", "i.e.", "e.g.", "U.S.", "U.K.", "U.N.",
-		"Inc.", "Corp.", "Ltd.", "Co.", "a.m.", "p.m.",
-	}
-	
-	// Temporarily replace abbreviations
-	replacements := make(map[string]string)
-	for i, abbr := range abbreviations {
-		placeholder := fmt.Sprintf("\x00ABBR%d\x00", i)
-		replacements[placeholder] = abbr
-		text = strings.ReplaceAll(text, abbr, placeholder)
-	}
-	
-	// Split on sentence-ending punctuation followed by whitespace and capital letter
-	sentencePattern := `([.!?]+)\s+([A-Z])`
-	re := regexp.MustCompile(sentencePattern)
-	
-	// Find all split points
-	matches := re.FindAllStringSubmatchIndex(text, -1)
-	
 	sentences := []string{}
-	lastEnd := 0
-	
-	for _, match := range matches {
-		// Split after the punctuation, including the punctuation
-		splitPoint := match[3] // End of punctuation group (after the punctuation)
-		sentence := strings.TrimSpace(text[lastEnd:splitPoint])
-		
-		if sentence != "" {
-			sentences = append(sentences, sentence)
-		}
-		
-		lastEnd = match[4] // Start of next sentence (capital letter)
+	for _, sent := range sentenceTokens(text) {
+		sentences = append(sentences, sent.Text)
 	}
-	
-	// Add the final sentence
-	if lastEnd < len(text) {
-		finalSentence := strings.TrimSpace(text[lastEnd:])
-		if finalSentence != "" {
-			sentences = append(sentences, finalSentence)
+	return sentences
+}
+
+// Abbreviations that end in a period without ending the sentence
+var sentenceAbbreviations = []string{
+	"Mr.", "Mrs.", "Ms.", "Dr.", "Prof.", "Sr.", "Jr.",
+	"vs.", "etc.", "i.e.", "e.g.", "U.S.", "U.K.", "U.N.",
+	"Inc.", "Corp.", "Ltd.", "Co.", "a.m.", "p.m.",
+}
+
+// sentenceTokens returns sentence spans with breaks after abbreviations removed
+func sentenceTokens(text string) []Token {
+	var merged []Token
+	pending := false
+	for _, sent := range SegmentSentences(text) {
+		if pending {
+			last := &merged[len(merged)-1]
+			last.Position.End = sent.Position.End
+			last.Runes.End = sent.Runes.End
+			last.Text = text[last.Position.Start:last.Position.End]
+		} else {
+			merged = append(merged, sent)
 		}
+		pending = endsWithAbbreviation(merged[len(merged)-1].Text)
 	}
-	
-	// If no sentences were found, treat the whole text as one sentence
-	if len(sentences) == 0 && strings.TrimSpace(text) != "" {
-		sentences = append(sentences, strings.TrimSpace(text))
-	}
-	
-	// Restore abbreviations
-	for i := range sentences {
-		for placeholder, abbr := range replacements {
-			sentences[i] = strings.ReplaceAll(sentences[i], placeholder, abbr)
+	return merged
+}
+
+func endsWithAbbreviation(sentence string) bool {
+	for _, abbr := range sentenceAbbreviations {
+		if !strings.HasSuffix(sentence, abbr) {
+			continue
+		}
+		rest := sentence[:len(sentence)-len(abbr)]
+		if rest == "" {
+			return true
+		}
+		r, _ := utf8.DecodeLastRuneInString(rest)
+		if !unicode.IsLetter(r) {
+			return true
 		}
 	}
-	
-	return sentences
+	return false
 }
 
 // SplitIntoParagraphs splits text into paragraphs
@@ -169,30 +158,42 @@ type Entity struct {
 	Position Position
 }
 
-// CalculateSyllableCount estimates the number of syllables in a word
+// CalculateSyllableCount estimates the number of syllables in a word.
+// Accented vowels count as vowels and non-letters are ignored.
 func CalculateSyllableCount(word string) int {
-	if len(word) == 0 {
-		return 0
+	var letters []rune
+	for _, r := range strings.ToLower(word) {
+		if unicode.IsLetter(r) {
+			letters = append(letters, r)
+		}
 	}
 	
-	word = strings.ToLower(word)
-	word = regexp.MustCompile(`[^a-z]`).ReplaceAllString(word, "")
-	
-	if len(word) == 0 {
+	if len(letters) == 0 {
 		return 0
 	}
 	
 	// Count vowel groups
-	vowelPattern := regexp.MustCompile(`[aeiouy]+`)
-	syllables := len(vowelPattern.FindAllString(word, -1))
+	syllables := 0
+	inVowel := false
+	for _, r := range letters {
+		if isVowel(r) {
+			if !inVowel {
+				syllables++
+			}
+			inVowel = true
+		} else {
+			inVowel = false
+		}
+	}
 	
 	// Adjust for silent 'e'
-	if strings.HasSuffix(word, "e") && syllables > 1 {
+	n := len(letters)
+	if letters[n-1] == 'e' && syllables > 1 {
 		syllables--
 	}
 	
 	// Adjust for 'le' ending
-	if strings.HasSuffix(word, "le") && len(word) > 2 && !isVowel(rune(word[len(word)-3])) {
+	if n > 2 && letters[n-2] == 'l' && letters[n-1] == 'e' && !isVowel(letters[n-3]) {
 		syllables++
 	}
 	
@@ -205,7 +206,7 @@ func CalculateSyllableCount(word string) int {
 }
 
 func isVowel(r rune) bool {
-	vowels := "aeiouy"
+	vowels := "aeiouyàáâãäåāăąæèéêëēĕėęěìíîïĩīĭįòóôõöøōŏőœùúûüũūŭůűųýÿ"
 	return strings.ContainsRune(vowels, unicode.ToLower(r))
 }
 
@@ -224,56 +225,23 @@ func IsCompleteSentence(text string) bool {
 		return false
 	}
 	
-	// Check for basic sentence structure (subject and predicate)
-	words := strings.Fields(text)
-	if len(words) < 2 {
+	tokens := TagPartsOfSpeech(text)
+	words := countWordTokens(tokens)
+	if words == 0 {
 		return false
 	}
 	
-	// Simple heuristic: contains a verb
-	hasVerb := false
-	for _, word := range words {
-		if isVerbSimple(word) {
-			hasVerb = true
-			break
-		}
-	}
-	
-	return hasVerb
-}
-
-func isVerbSimple(word string) bool {
-	word = strings.ToLower(strings.Trim(word, ".,!?;:"))
-	
-	// Common verbs and patterns
-	commonVerbs := []string{
-		"is", "are", "was", "were", "be", "been", "being",
-		"have", "has", "had", "do", "does", "did", "will",
-		"would", "could", "should", "can", "may", "might",
-	}
-	
-	for _, verb := range commonVerbs {
-		if word == verb {
-			return true
-		}
-	}
-	
-	// Simple patterns for regular verbs
-	if strings.HasSuffix(word, "ed") || strings.HasSuffix(word, "ing") || strings.HasSuffix(word, "s") {
+	// Exclamations ("Go!", "What a day!") stand on their own
+	if lastChar == '!' && (words >= 2 || tokens[0].Tag == "VB") {
 		return true
 	}
 	
-	return false
+	return !isSentenceFragment(text)
 }
 
 // CountWords counts the number of words in text
 func CountWords(text string) int {
-	if text == "" {
-		return 0
-	}
-	
-	words := strings.Fields(text)
-	return len(words)
+	return len(Tokenize(text))
 }
 
 // CountSentences counts the number of sentences in text
@@ -284,14 +252,11 @@ func CountSentences(text string) int {
 
 // CountSyllables counts total syllables in text
 func CountSyllables(text string) int {
-	words := strings.Fields(text)
 	total := 0
 	
-	for _, word := range words {
-		// Remove punctuation
-		cleanWord := regexp.MustCompile(`[^a-zA-Z]`).ReplaceAllString(word, "")
-		if cleanWord != "" {
-			total += CalculateSyllableCount(cleanWord)
+	for _, tok := range Tokenize(text) {
+		if tok.Kind == "word" {
+			total += CalculateSyllableCount(tok.Text)
 		}
 	}
 	
@@ -0,0 +1,198 @@
+package textlib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A tiny byte-level vocabulary: "Ġ" is the byte-level form of a space
+func testBPETokenizer() *BPETokenizer {
+	vocab := map[string]int{
+		"l": 0, "o": 1, "w": 2, "e": 3, "r": 4, "Ġ": 5, "n": 6, "s": 7, "t": 8,
+		"lo": 9, "low": 10, "er": 11, "Ġlow": 12, "Ġlower": 13, "Ġl": 14, "ne": 15, "new": 16,
+	}
+	merges := [][2]string{
+		{"Ġ", "l"}, {"Ġl", "o"}, {"Ġlo", "w"}, {"l", "o"}, {"lo", "w"},
+		{"e", "r"}, {"Ġlow", "er"}, {"n", "e"}, {"ne", "w"},
+	}
+	return NewBPETokenizer(vocab, merges, true)
+}
+
+func TestBPETokenizer(t *testing.T) {
+	tok := testBPETokenizer()
+
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"low", []string{"low"}},
+		{"low lower", []string{"low", "Ġlower"}},
+		{"newest", []string{"new", "e", "s", "t"}},
+		{"low  low", []string{"low", "Ġ", "Ġlow"}},
+	}
+
+	for _, tt := range tests {
+		got := tok.Tokens(tt.text)
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("Tokens(%q): expected %v, got %v", tt.text, tt.expected, got)
+		}
+		if n := tok.CountTokens(tt.text); n != len(tt.expected) {
+			t.Errorf("CountTokens(%q): expected %d, got %d", tt.text, len(tt.expected), n)
+		}
+	}
+
+	ids := tok.Encode("low lower")
+	if len(ids) != 2 || ids[0] != 10 || ids[1] != 13 {
+		t.Errorf("Expected ids [10 13], got %v", ids)
+	}
+
+	tok.AddSpecialToken("<|end|>", 99)
+	if got := tok.Tokens("low<|end|>low"); strings.Join(got, "|") != "low|<|end|>|low" {
+		t.Errorf("Expected special token to be kept whole, got %v", got)
+	}
+}
+
+func TestLoadBPETokenizer(t *testing.T) {
+	dir := t.TempDir()
+
+	tokenizerJSON := `{
+		"added_tokens": [{"id": 20, "content": "</s>", "special": true}],
+		"pre_tokenizer": {"type": "Metaspace", "replacement": "▁"},
+		"model": {
+			"type": "BPE",
+			"byte_fallback": true,
+			"vocab": {"▁": 0, "h": 1, "i": 2, "▁h": 3, "▁hi": 4, "<0x21>": 5},
+			"merges": [["▁", "h"], ["▁h", "i"]]
+		}
+	}`
+	path := filepath.Join(dir, "tokenizer.json")
+	if err := os.WriteFile(path, []byte(tokenizerJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := LoadBPETokenizer(path)
+	if err != nil {
+		t.Fatalf("LoadBPETokenizer failed: %v", err)
+	}
+
+	got := tok.Tokens("hi hi!</s>")
+	expected := []string{"▁hi", "▁hi", "<0x21>", "</s>"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if ids := tok.Encode("hi!"); len(ids) != 2 || ids[0] != 4 || ids[1] != 5 {
+		t.Errorf("Expected ids [4 5], got %v", ids)
+	}
+
+	mergesPath := filepath.Join(dir, "merges.txt")
+	if err := os.WriteFile(mergesPath, []byte("#version: 0.2\nl o\nlo w\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gpt2, err := LoadBPEMerges("", mergesPath)
+	if err != nil {
+		t.Fatalf("LoadBPEMerges failed: %v", err)
+	}
+	if n := gpt2.CountTokens("low"); n != 1 {
+		t.Errorf("Expected 'low' to merge into one token, got %d", n)
+	}
+
+	if _, err := LoadBPETokenizer(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestLlama3PreTokenize(t *testing.T) {
+	tokenizerJSON := `{
+		"pre_tokenizer": {"type": "Sequence", "pretokenizers": [
+			{"type": "Split", "pattern": {"Regex": "(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\\r\\n\\p{L}\\p{N}]?\\p{L}+|\\p{N}{1,3}| ?[^\\s\\p{L}\\p{N}]+[\\r\\n]*|\\s*[\\r\\n]+|\\s+(?!\\S)|\\s+"}, "behavior": "Isolated"},
+			{"type": "ByteLevel", "add_prefix_space": false, "use_regex": false}
+		]},
+		"model": {"type": "BPE", "vocab": {}, "merges": []}
+	}`
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	if err := os.WriteFile(path, []byte(tokenizerJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	llama3, err := LoadBPETokenizer(path)
+	if err != nil {
+		t.Fatalf("LoadBPETokenizer failed: %v", err)
+	}
+	gpt2 := NewBPETokenizer(nil, nil, true)
+
+	tests := []struct {
+		text   string
+		llama3 []string
+		gpt2   []string
+	}{
+		{"I'LL go", []string{"I", "'LL", " go"}, []string{"I", "'", "LL", " go"}},
+		{"12345", []string{"123", "45"}, []string{"12345"}},
+		{"a\n\nb", []string{"a", "\n\n", "b"}, []string{"a", "\n", "\n", "b"}},
+		{"(x)", []string{"(x", ")"}, []string{"(", "x", ")"}},
+		{"a  b", []string{"a", " ", " b"}, []string{"a", " ", " b"}},
+	}
+
+	for _, tt := range tests {
+		for _, c := range []struct {
+			name     string
+			tok      *BPETokenizer
+			expected []string
+		}{{"llama3", llama3, tt.llama3}, {"gpt2", gpt2, tt.gpt2}} {
+			var expected []string
+			for _, piece := range c.expected {
+				expected = append(expected, byteLevelEncode(piece))
+			}
+			got := c.tok.preTokenize(tt.text)
+			if strings.Join(got, "|") != strings.Join(expected, "|") {
+				t.Errorf("%s preTokenize(%q): expected %q, got %q", c.name, tt.text, expected, got)
+			}
+		}
+	}
+}
+
+func TestChunkTextWithTokenCounter(t *testing.T) {
+	tok := testBPETokenizer()
+	text := "newest newest newest newest newest newest"
+
+	// Each "newest" costs 4 tokens (5 with the leading space)
+	strategy := ChunkingStrategy{Method: "token", MaxTokens: 10, TokenCounter: tok}
+	chunks := ChunkText(text, strategy)
+
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if chunk.TokenCount > 10 {
+			t.Errorf("Chunk %d has %d tokens, limit is 10", chunk.Index, chunk.TokenCount)
+		}
+	}
+
+	// Without a counter MaxTokens keeps counting words
+	wordChunks := ChunkText(text, ChunkingStrategy{Method: "token", MaxTokens: 10})
+	if len(wordChunks) != 1 {
+		t.Errorf("Expected 1 word-counted chunk, got %d", len(wordChunks))
+	}
+
+	sentences := ChunkText("Low lower. New newest. Lower low.", ChunkingStrategy{
+		Method: "sentence", MaxTokens: 8, TokenCounter: tok,
+	})
+	if len(sentences) != 3 {
+		t.Errorf("Expected each sentence in its own chunk, got %d chunks", len(sentences))
+	}
+	for _, chunk := range sentences {
+		if chunk.TokenCount != tok.CountTokens(chunk.Content) {
+			t.Errorf("Chunk %q: expected %d tokens, got %d", chunk.Content, tok.CountTokens(chunk.Content), chunk.TokenCount)
+		}
+	}
+
+	result := SegmentTextWithCounter("newest newest", "sentence", tok)
+	if len(result.Segments) != 1 || result.Segments[0].TokenCount != 9 {
+		t.Errorf("Expected one segment of 9 tokens, got %+v", result.Segments)
+	}
+
+	if size := OptimalChunkSizeWithCounter(strings.Repeat("newest ", 500), 10, tok); size != 250 {
+		t.Errorf("Expected optimal chunk size of 250 tokens, got %d", size)
+	}
+}
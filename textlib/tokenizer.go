@@ -0,0 +1,763 @@
+package textlib
+
+import (
+	"unicode"
+)
+
+// Token is a segment of text found by the Unicode tokenizer. Position holds
+// byte offsets into the original string and Runes holds the same span in
+// rune (code point) offsets.
+type Token struct {
+	Text     string
+	Kind     string // "word", "number", "ideographic", "emoji", "punctuation", "space"
+	Position Position
+	Runes    Position
+}
+
+// IsWord reports whether the token carries lexical content
+func (t Token) IsWord() bool {
+	return t.Kind == "word" || t.Kind == "number" || t.Kind == "ideographic"
+}
+
+// Tokenize returns the words, numbers and ideographs of text, using the
+// UAX #29 word boundary rules so that contractions, decimals, accented
+// letters and combining marks stay within one token. As a tailoring of the
+// standard rules, hyphenated compounds such as "twenty-five" are one token.
+func Tokenize(text string) []Token {
+	segments := SegmentWords(text)
+
+	var words []Token
+	for i := 0; i < len(segments); i++ {
+		tok := segments[i]
+		if !tok.IsWord() {
+			continue
+		}
+
+		// Join word-hyphen-word runs
+		for i+2 < len(segments) && isHyphenToken(segments[i+1]) && segments[i+2].Kind == "word" && tok.Kind == "word" {
+			tok.Position.End = segments[i+2].Position.End
+			tok.Runes.End = segments[i+2].Runes.End
+			tok.Text = text[tok.Position.Start:tok.Position.End]
+			i += 2
+		}
+		words = append(words, tok)
+	}
+	return words
+}
+
+func isHyphenToken(tok Token) bool {
+	return tok.Text == "-" || tok.Text == "\u2010"
+}
+
+// SegmentWords splits text at every UAX #29 word boundary. Unlike Tokenize
+// it keeps whitespace and punctuation segments, so concatenating the
+// returned tokens reproduces the text.
+func SegmentWords(text string) []Token {
+	runes, offsets := decodeRunes(text)
+	props := make([]wordBreakProp, len(runes))
+	for i, r := range runes {
+		props[i] = wordBreakProperty(r)
+	}
+
+	var tokens []Token
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && !isWordBreak(runes, props, i) {
+			continue
+		}
+		tokens = append(tokens, newToken(text, runes[start:i], offsets, start, i))
+		start = i
+	}
+	return tokens
+}
+
+// SegmentSentences splits text at UAX #29 sentence boundaries. A single line
+// break is treated as a space so that hard-wrapped prose is not split; blank
+// lines end a sentence. Token text and positions exclude surrounding
+// whitespace.
+func SegmentSentences(text string) []Token {
+	runes, offsets := decodeRunes(text)
+	props := sentenceBreakProperties(runes)
+
+	var sentences []Token
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && !isSentenceBreak(props, i) {
+			continue
+		}
+
+		s, e := start, i
+		for s < e && unicode.IsSpace(runes[s]) {
+			s++
+		}
+		for e > s && unicode.IsSpace(runes[e-1]) {
+			e--
+		}
+		if s < e {
+			sentence := newToken(text, runes[s:e], offsets, s, e)
+			sentence.Kind = "sentence"
+			sentences = append(sentences, sentence)
+		}
+		start = i
+	}
+	return sentences
+}
+
+// Graphemes splits text into extended grapheme clusters, the units a reader
+// perceives as single characters (e.g. "é" written as e + U+0301, or a
+// family emoji joined with U+200D)
+func Graphemes(text string) []string {
+	var clusters []string
+	for _, span := range graphemeSpans(text) {
+		clusters = append(clusters, text[span.Start:span.End])
+	}
+	return clusters
+}
+
+// CountGraphemes returns the number of user-perceived characters in text
+func CountGraphemes(text string) int {
+	return len(graphemeSpans(text))
+}
+
+// OffsetMap converts between byte, rune and grapheme offsets of one string
+type OffsetMap struct {
+	runeBytes     []int // byte offset of each rune, plus len(text)
+	graphemeBytes []int // byte offset of each grapheme cluster, plus len(text)
+}
+
+// NewOffsetMap builds the offset tables for text
+func NewOffsetMap(text string) *OffsetMap {
+	m := &OffsetMap{}
+	for i := range text {
+		m.runeBytes = append(m.runeBytes, i)
+	}
+	m.runeBytes = append(m.runeBytes, len(text))
+
+	for _, span := range graphemeSpans(text) {
+		m.graphemeBytes = append(m.graphemeBytes, span.Start)
+	}
+	m.graphemeBytes = append(m.graphemeBytes, len(text))
+	return m
+}
+
+// ByteToRune returns the index of the rune containing byte offset b
+func (m *OffsetMap) ByteToRune(b int) int {
+	return offsetIndex(m.runeBytes, b)
+}
+
+// RuneToByte returns the byte offset at which rune r starts
+func (m *OffsetMap) RuneToByte(r int) int {
+	return offsetAt(m.runeBytes, r)
+}
+
+// ByteToGrapheme returns the index of the grapheme cluster containing byte offset b
+func (m *OffsetMap) ByteToGrapheme(b int) int {
+	return offsetIndex(m.graphemeBytes, b)
+}
+
+// GraphemeToByte returns the byte offset at which grapheme cluster g starts
+func (m *OffsetMap) GraphemeToByte(g int) int {
+	return offsetAt(m.graphemeBytes, g)
+}
+
+// RunePosition converts a byte-offset Position to rune offsets
+func (m *OffsetMap) RunePosition(p Position) Position {
+	return Position{Start: m.ByteToRune(p.Start), End: m.ByteToRune(p.End)}
+}
+
+func offsetIndex(starts []int, b int) int {
+	if b <= 0 {
+		return 0
+	}
+	// Binary search for the last start <= b
+	lo, hi := 0, len(starts)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if starts[mid] <= b {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+func offsetAt(starts []int, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(starts) {
+		return starts[len(starts)-1]
+	}
+	return starts[i]
+}
+
+func decodeRunes(text string) ([]rune, []int) {
+	runes := make([]rune, 0, len(text))
+	offsets := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		runes = append(runes, r)
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+	return runes, offsets
+}
+
+func newToken(text string, runes []rune, offsets []int, start, end int) Token {
+	return Token{
+		Text:     text[offsets[start]:offsets[end]],
+		Kind:     tokenKind(runes),
+		Position: Position{Start: offsets[start], End: offsets[end]},
+		Runes:    Position{Start: start, End: end},
+	}
+}
+
+func tokenKind(runes []rune) string {
+	hasLetter, hasDigit, hasIdeograph, hasPictograph, allSpace := false, false, false, false, true
+	for _, r := range runes {
+		switch {
+		case isIdeographic(r):
+			hasIdeograph = true
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case isExtendedPictographic(r) || (r >= 0x1F1E6 && r <= 0x1F1FF):
+			hasPictograph = true
+		}
+		if !unicode.IsSpace(r) {
+			allSpace = false
+		}
+	}
+
+	switch {
+	case hasIdeograph:
+		return "ideographic"
+	case hasLetter:
+		return "word"
+	case hasDigit:
+		return "number"
+	case hasPictograph:
+		return "emoji"
+	case allSpace:
+		return "space"
+	default:
+		return "punctuation"
+	}
+}
+
+func isIdeographic(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || r == 0x30FC
+}
+
+// isExtendedPictographic approximates the Extended_Pictographic property
+// with the blocks that hold emoji
+func isExtendedPictographic(r rune) bool {
+	switch {
+	case r == 0x00A9 || r == 0x00AE || r == 0x203C || r == 0x2049 || r == 0x2122 || r == 0x2139:
+		return true
+	case r >= 0x2194 && r <= 0x21AA:
+		return true
+	case r >= 0x231A && r <= 0x23FF:
+		return true
+	case r >= 0x25AA && r <= 0x27BF:
+		return true
+	case r >= 0x2934 && r <= 0x2935, r >= 0x2B05 && r <= 0x2B55:
+		return true
+	case r == 0x3030 || r == 0x303D || r == 0x3297 || r == 0x3299:
+		return true
+	case r >= 0x1F000 && r <= 0x1FAFF && !(r >= 0x1F1E6 && r <= 0x1F1FF) && !(r >= 0x1F3FB && r <= 0x1F3FF):
+		return true
+	}
+	return false
+}
+
+// Grapheme cluster boundaries (UAX #29 section 3)
+
+type graphemeProp int
+
+const (
+	gbOther graphemeProp = iota
+	gbCR
+	gbLF
+	gbControl
+	gbExtend
+	gbZWJ
+	gbRegionalIndicator
+	gbSpacingMark
+	gbL
+	gbV
+	gbT
+	gbLV
+	gbLVT
+	gbPictographic
+)
+
+func graphemeBreakProperty(r rune) graphemeProp {
+	switch {
+	case r == '\r':
+		return gbCR
+	case r == '\n':
+		return gbLF
+	case r == 0x200D:
+		return gbZWJ
+	case r == 0x200C || (r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F) || r == 0xFF9E || r == 0xFF9F:
+		return gbExtend
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return gbExtend
+	case unicode.Is(unicode.Mc, r):
+		return gbSpacingMark
+	case unicode.In(r, unicode.Cc, unicode.Zl, unicode.Zp, unicode.Cf):
+		return gbControl
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return gbRegionalIndicator
+	case (r >= 0x1100 && r <= 0x115F) || (r >= 0xA960 && r <= 0xA97C):
+		return gbL
+	case (r >= 0x1160 && r <= 0x11A7) || (r >= 0xD7B0 && r <= 0xD7C6):
+		return gbV
+	case (r >= 0x11A8 && r <= 0x11FF) || (r >= 0xD7CB && r <= 0xD7FB):
+		return gbT
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return gbLV
+		}
+		return gbLVT
+	case isExtendedPictographic(r):
+		return gbPictographic
+	}
+	return gbOther
+}
+
+func graphemeSpans(text string) []Position {
+	var spans []Position
+	start := 0
+	var prev graphemeProp
+	pictographicRun := false // inside ExtPict Extend* (ZWJ)?
+	riCount := 0
+
+	for i, r := range text {
+		prop := graphemeBreakProperty(r)
+		if i > 0 && isGraphemeBreak(prev, prop, pictographicRun, riCount) {
+			spans = append(spans, Position{Start: start, End: i})
+			start = i
+			riCount = 0
+		}
+
+		switch {
+		case prop == gbPictographic:
+			pictographicRun = true
+		case prop == gbExtend && pictographicRun, prop == gbZWJ && pictographicRun:
+		default:
+			pictographicRun = false
+		}
+		if prop == gbRegionalIndicator {
+			riCount++
+		}
+		prev = prop
+	}
+
+	if start < len(text) {
+		spans = append(spans, Position{Start: start, End: len(text)})
+	}
+	return spans
+}
+
+func isGraphemeBreak(prev, next graphemeProp, pictographicRun bool, riCount int) bool {
+	switch {
+	case prev == gbCR && next == gbLF: // GB3
+		return false
+	case prev == gbCR || prev == gbLF || prev == gbControl: // GB4
+		return true
+	case next == gbCR || next == gbLF || next == gbControl: // GB5
+		return true
+	case prev == gbL && (next == gbL || next == gbV || next == gbLV || next == gbLVT): // GB6
+		return false
+	case (prev == gbLV || prev == gbV) && (next == gbV || next == gbT): // GB7
+		return false
+	case (prev == gbLVT || prev == gbT) && next == gbT: // GB8
+		return false
+	case next == gbExtend || next == gbZWJ || next == gbSpacingMark: // GB9, GB9a
+		return false
+	case prev == gbZWJ && next == gbPictographic && pictographicRun: // GB11
+		return false
+	case prev == gbRegionalIndicator && next == gbRegionalIndicator: // GB12, GB13
+		return riCount%2 == 0
+	}
+	return true
+}
+
+// Word boundaries (UAX #29 section 4)
+
+type wordBreakProp int
+
+const (
+	wbOther wordBreakProp = iota
+	wbCR
+	wbLF
+	wbNewline
+	wbExtend
+	wbZWJ
+	wbRegionalIndicator
+	wbFormat
+	wbKatakana
+	wbHebrewLetter
+	wbALetter
+	wbSingleQuote
+	wbDoubleQuote
+	wbMidNumLet
+	wbMidLetter
+	wbMidNum
+	wbNumeric
+	wbExtendNumLet
+	wbWSegSpace
+)
+
+func wordBreakProperty(r rune) wordBreakProp {
+	switch r {
+	case '\r':
+		return wbCR
+	case '\n':
+		return wbLF
+	case '\v', '\f', 0x85, 0x2028, 0x2029:
+		return wbNewline
+	case 0x200D:
+		return wbZWJ
+	case '\'':
+		return wbSingleQuote
+	case '"':
+		return wbDoubleQuote
+	case '.', 0x2018, 0x2019, 0x2024, 0xFE52, 0xFF07, 0xFF0E:
+		return wbMidNumLet
+	case ':', 0xB7, 0x387, 0x55F, 0x5F4, 0x2027, 0xFE13, 0xFE55, 0xFF1A:
+		return wbMidLetter
+	case ',', ';', 0x37E, 0x589, 0x60C, 0x60D, 0x66C, 0x7F8, 0x2044, 0xFE10, 0xFE14, 0xFE50, 0xFE54, 0xFF0C, 0xFF1B:
+		return wbMidNum
+	case 0x202F:
+		return wbExtendNumLet
+	case 0x30FC, 0x309B, 0x309C, 0x30A0, 0xFF70:
+		return wbKatakana
+	case 0x200C, 0xFF9E, 0xFF9F:
+		return wbExtend
+	}
+
+	switch {
+	case unicode.Is(unicode.M, r) || (r >= 0x1F3FB && r <= 0x1F3FF):
+		return wbExtend
+	case unicode.Is(unicode.Cf, r):
+		return wbFormat
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return wbRegionalIndicator
+	case unicode.Is(unicode.Katakana, r) || (r >= 0x3031 && r <= 0x3035):
+		return wbKatakana
+	case unicode.Is(unicode.Hebrew, r) && unicode.IsLetter(r):
+		return wbHebrewLetter
+	case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r):
+		return wbOther
+	case unicode.IsLetter(r) || unicode.Is(unicode.Nl, r):
+		// Scripts written without spaces, such as Thai, would need a
+		// dictionary; their letters are kept together as ALetter runs
+		return wbALetter
+	case unicode.Is(unicode.Nd, r):
+		return wbNumeric
+	case unicode.Is(unicode.Pc, r):
+		return wbExtendNumLet
+	case unicode.Is(unicode.Zs, r) && r != 0xA0 && r != 0x2007:
+		return wbWSegSpace
+	}
+	return wbOther
+}
+
+func isWordIgnorable(p wordBreakProp) bool {
+	return p == wbExtend || p == wbFormat || p == wbZWJ
+}
+
+func isAHLetter(p wordBreakProp) bool {
+	return p == wbALetter || p == wbHebrewLetter
+}
+
+func isMidNumLetQ(p wordBreakProp) bool {
+	return p == wbMidNumLet || p == wbSingleQuote
+}
+
+func isLineBreakProp(p wordBreakProp) bool {
+	return p == wbCR || p == wbLF || p == wbNewline
+}
+
+// isWordBreak reports whether there is a word boundary before runes[i]
+func isWordBreak(runes []rune, props []wordBreakProp, i int) bool {
+	a, b := props[i-1], props[i]
+
+	switch {
+	case a == wbCR && b == wbLF: // WB3
+		return false
+	case isLineBreakProp(a) || isLineBreakProp(b): // WB3a, WB3b
+		return true
+	case a == wbZWJ && isExtendedPictographic(runes[i]): // WB3c
+		return false
+	case a == wbWSegSpace && b == wbWSegSpace: // WB3d
+		return false
+	case isWordIgnorable(b): // WB4
+		return false
+	}
+
+	// WB4: look through Extend, Format and ZWJ on both sides
+	prevIndex := func(j int) int {
+		for j >= 0 && isWordIgnorable(props[j]) {
+			if j > 0 && isLineBreakProp(props[j-1]) {
+				return j
+			}
+			j--
+		}
+		return j
+	}
+	nextIndex := func(j int) int {
+		for j < len(props) && isWordIgnorable(props[j]) {
+			j++
+		}
+		return j
+	}
+
+	p := prevIndex(i - 1)
+	if p < 0 {
+		return true
+	}
+	left := props[p]
+	left2 := wbOther
+	if q := prevIndex(p - 1); q >= 0 {
+		left2 = props[q]
+	}
+	right := b
+	right2 := wbOther
+	if q := nextIndex(i + 1); q < len(props) {
+		right2 = props[q]
+	}
+
+	switch {
+	case isAHLetter(left) && isAHLetter(right): // WB5
+		return false
+	case isAHLetter(left) && (right == wbMidLetter || isMidNumLetQ(right)) && isAHLetter(right2): // WB6
+		return false
+	case isAHLetter(left2) && (left == wbMidLetter || isMidNumLetQ(left)) && isAHLetter(right): // WB7
+		return false
+	case left == wbHebrewLetter && right == wbSingleQuote: // WB7a
+		return false
+	case left == wbHebrewLetter && right == wbDoubleQuote && right2 == wbHebrewLetter: // WB7b
+		return false
+	case left2 == wbHebrewLetter && left == wbDoubleQuote && right == wbHebrewLetter: // WB7c
+		return false
+	case left == wbNumeric && right == wbNumeric: // WB8
+		return false
+	case isAHLetter(left) && right == wbNumeric: // WB9
+		return false
+	case left == wbNumeric && isAHLetter(right): // WB10
+		return false
+	case left2 == wbNumeric && (left == wbMidNum || isMidNumLetQ(left)) && right == wbNumeric: // WB11
+		return false
+	case left == wbNumeric && (right == wbMidNum || isMidNumLetQ(right)) && right2 == wbNumeric: // WB12
+		return false
+	case left == wbKatakana && right == wbKatakana: // WB13
+		return false
+	case (isAHLetter(left) || left == wbNumeric || left == wbKatakana || left == wbExtendNumLet) && right == wbExtendNumLet: // WB13a
+		return false
+	case left == wbExtendNumLet && (isAHLetter(right) || right == wbNumeric || right == wbKatakana): // WB13b
+		return false
+	case left == wbRegionalIndicator && right == wbRegionalIndicator: // WB15, WB16
+		count := 0
+		for j := p; j >= 0; j = prevIndex(j - 1) {
+			if props[j] != wbRegionalIndicator {
+				break
+			}
+			count++
+		}
+		return count%2 == 0
+	}
+
+	return true // WB999
+}
+
+// Sentence boundaries (UAX #29 section 5)
+
+type sentenceBreakProp int
+
+const (
+	sbOther sentenceBreakProp = iota
+	sbCR
+	sbLF
+	sbSep
+	sbSp
+	sbLower
+	sbUpper
+	sbOLetter
+	sbNumeric
+	sbATerm
+	sbSTerm
+	sbClose
+	sbSContinue
+	sbExtend
+	sbFormat
+)
+
+func sentenceBreakProperty(r rune) sentenceBreakProp {
+	switch r {
+	case '\r':
+		return sbCR
+	case '\n':
+		return sbLF
+	case 0x85, 0x2028, 0x2029:
+		return sbSep
+	case '.', 0x2024, 0xFE52, 0xFF0E:
+		return sbATerm
+	case '!', '?', 0x589, 0x61F, 0x6D4, 0x700, 0x701, 0x702, 0x964, 0x965, 0x1803, 0x1809,
+		0x203C, 0x203D, 0x2047, 0x2048, 0x2049, 0x3002, 0xFE56, 0xFE57, 0xFF01, 0xFF1F, 0xFF61:
+		return sbSTerm
+	case ',', '-', ':', ';', 0x55D, 0x60C, 0x60D, 0x7F8, 0x1802, 0x1808, 0x2013, 0x2014,
+		0x3001, 0xFE10, 0xFE11, 0xFE13, 0xFE31, 0xFE32, 0xFE50, 0xFE51, 0xFE55, 0xFE58,
+		0xFE63, 0xFF0C, 0xFF0D, 0xFF1A, 0xFF1B, 0xFF64:
+		return sbSContinue
+	case '"', '\'', 0xAB, 0xBB:
+		return sbClose
+	case 0x200C, 0x200D:
+		return sbExtend
+	}
+
+	switch {
+	case unicode.Is(unicode.M, r):
+		return sbExtend
+	case unicode.Is(unicode.Cf, r):
+		return sbFormat
+	case unicode.IsSpace(r):
+		return sbSp
+	case unicode.IsLower(r):
+		return sbLower
+	case unicode.IsUpper(r) || unicode.IsTitle(r):
+		return sbUpper
+	case unicode.IsLetter(r) || unicode.Is(unicode.Nl, r):
+		return sbOLetter
+	case unicode.Is(unicode.Nd, r):
+		return sbNumeric
+	case unicode.In(r, unicode.Ps, unicode.Pe, unicode.Pi, unicode.Pf):
+		return sbClose
+	}
+	return sbOther
+}
+
+func isParaSep(p sentenceBreakProp) bool {
+	return p == sbSep || p == sbCR || p == sbLF
+}
+
+func isSATerm(p sentenceBreakProp) bool {
+	return p == sbATerm || p == sbSTerm
+}
+
+// sentenceBreakProperties classifies runes for sentence segmentation. SB5
+// is applied up front by giving Extend and Format the property of the
+// character they attach to, and a line break that is not part of a blank
+// line is downgraded to a space.
+func sentenceBreakProperties(runes []rune) []sentenceBreakProp {
+	props := make([]sentenceBreakProp, len(runes))
+	for i, r := range runes {
+		props[i] = sentenceBreakProperty(r)
+	}
+
+	for i, p := range props {
+		if p != sbCR && p != sbLF {
+			continue
+		}
+		if !isBlankLineBreak(runes, i) {
+			props[i] = sbSp
+		}
+	}
+
+	for i := 1; i < len(props); i++ {
+		if (props[i] == sbExtend || props[i] == sbFormat) && !isParaSep(props[i-1]) {
+			props[i] = props[i-1]
+		}
+	}
+	return props
+}
+
+// isBlankLineBreak reports whether the line break at i is adjacent to
+// another line break, ignoring horizontal whitespace between them
+func isBlankLineBreak(runes []rune, i int) bool {
+	isBreak := func(r rune) bool { return r == '\n' || r == '\r' }
+	isHorizontalSpace := func(r rune) bool { return unicode.IsSpace(r) && !isBreak(r) }
+
+	for j := i + 1; j < len(runes); j++ {
+		if runes[i] == '\r' && runes[j] == '\n' && j == i+1 {
+			continue
+		}
+		if isBreak(runes[j]) {
+			return true
+		}
+		if !isHorizontalSpace(runes[j]) {
+			break
+		}
+	}
+	for j := i - 1; j >= 0; j-- {
+		if runes[i] == '\n' && runes[j] == '\r' && j == i-1 {
+			continue
+		}
+		if isBreak(runes[j]) {
+			return true
+		}
+		if !isHorizontalSpace(runes[j]) {
+			break
+		}
+	}
+	return false
+}
+
+// isSentenceBreak reports whether there is a sentence boundary before rune i
+func isSentenceBreak(props []sentenceBreakProp, i int) bool {
+	a, b := props[i-1], props[i]
+
+	switch {
+	case a == sbCR && b == sbLF: // SB3
+		return false
+	case isParaSep(a): // SB4
+		return true
+	case a == sbATerm && b == sbNumeric: // SB6
+		return false
+	case i >= 2 && (props[i-2] == sbUpper || props[i-2] == sbLower) && a == sbATerm && b == sbUpper: // SB7
+		return false
+	}
+
+	// Look back for SATerm Close* Sp*
+	j := i - 1
+	spaces := 0
+	for j >= 0 && props[j] == sbSp {
+		j--
+		spaces++
+	}
+	for j >= 0 && props[j] == sbClose {
+		j--
+	}
+	if j < 0 || !isSATerm(props[j]) {
+		return false // SB998
+	}
+
+	if props[j] == sbATerm { // SB8
+		k := i
+		for k < len(props) {
+			p := props[k]
+			if p == sbOLetter || p == sbUpper || p == sbLower || isParaSep(p) || isSATerm(p) {
+				break
+			}
+			k++
+		}
+		if k < len(props) && props[k] == sbLower {
+			return false
+		}
+	}
+
+	switch {
+	case b == sbSContinue || isSATerm(b): // SB8a
+		return false
+	case spaces == 0 && (b == sbClose || b == sbSp || isParaSep(b)): // SB9
+		return false
+	case b == sbSp || isParaSep(b): // SB10
+		return false
+	}
+	return true // SB11
+}
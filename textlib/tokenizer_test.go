@@ -0,0 +1,159 @@
+package textlib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"Contraction", "I don't know.", []string{"I", "don't", "know"}},
+		{"Decimal", "It costs 3.50 now", []string{"It", "costs", "3.50", "now"}},
+		{"Accented", "Crème brûlée", []string{"Crème", "brûlée"}},
+		{"Combining mark", "café time", []string{"café", "time"}},
+		{"Emoji", "hello👋world", []string{"hello", "world"}},
+		{"Ideographs", "我爱你", []string{"我", "爱", "你"}},
+		{"Katakana", "カタカナ語", []string{"カタカナ", "語"}},
+		{"Hyphenated", "state-of-the-art design", []string{"state-of-the-art", "design"}},
+		{"Underscore", "snake_case name", []string{"snake_case", "name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := Tokenize(tt.text)
+			var got []string
+			for _, tok := range tokens {
+				got = append(got, tok.Text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSegmentWordsOffsets(t *testing.T) {
+	text := "Naïve 🙂 test, 日本."
+	tokens := SegmentWords(text)
+
+	var rebuilt strings.Builder
+	runes := []rune(text)
+	for _, tok := range tokens {
+		rebuilt.WriteString(tok.Text)
+		if text[tok.Position.Start:tok.Position.End] != tok.Text {
+			t.Errorf("Byte position %v does not match %q", tok.Position, tok.Text)
+		}
+		if string(runes[tok.Runes.Start:tok.Runes.End]) != tok.Text {
+			t.Errorf("Rune position %v does not match %q", tok.Runes, tok.Text)
+		}
+	}
+	if rebuilt.String() != text {
+		t.Errorf("Segments do not cover the text: %q", rebuilt.String())
+	}
+}
+
+func TestSegmentSentences(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"Basic", "Hello there. How are you?", []string{"Hello there.", "How are you?"}},
+		{"Lowercase after period", "See p. 5 for details. Then stop.", []string{"See p. 5 for details.", "Then stop."}},
+		{"Quotes", `He said "Go." She went.`, []string{`He said "Go."`, "She went."}},
+		{"CJK", "今日は晴れ。明日は雨。", []string{"今日は晴れ。", "明日は雨。"}},
+		{"Wrapped line", "This line\ncontinues here.", []string{"This line\ncontinues here."}},
+		{"Blank line", "Heading\n\nBody text.", []string{"Heading", "Body text."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, sent := range SegmentSentences(tt.text) {
+				got = append(got, sent.Text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSplitIntoSentencesAbbreviations(t *testing.T) {
+	sentences := SplitIntoSentences("Dr. Smith arrived at 9 a.m. Then he left. Café opens soon!")
+	expected := []string{"Dr. Smith arrived at 9 a.m. Then he left.", "Café opens soon!"}
+
+	if strings.Join(sentences, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, sentences)
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{"abc", 3},
+		{"é", 1},
+		{"👨‍👩‍👧", 1},
+		{"🇯🇵🇺🇸", 2},
+		{"👍🏽", 1},
+		{"\r\n", 1},
+		{"한국어", 3},
+	}
+
+	for _, tt := range tests {
+		if got := CountGraphemes(tt.text); got != tt.expected {
+			t.Errorf("CountGraphemes(%q): expected %d, got %d (%q)", tt.text, tt.expected, got, Graphemes(tt.text))
+		}
+	}
+}
+
+func TestOffsetMap(t *testing.T) {
+	text := "añb👍🏽c"
+	m := NewOffsetMap(text)
+
+	// a=0 ñ=1-2 b=3 👍=4-7 🏽=8-11 c=12
+	if got := m.ByteToRune(3); got != 2 {
+		t.Errorf("ByteToRune(3): expected 2, got %d", got)
+	}
+	if got := m.RuneToByte(5); got != 12 {
+		t.Errorf("RuneToByte(5): expected 12, got %d", got)
+	}
+	if got := m.ByteToGrapheme(12); got != 4 {
+		t.Errorf("ByteToGrapheme(12): expected 4, got %d", got)
+	}
+	if got := m.GraphemeToByte(3); got != 4 {
+		t.Errorf("GraphemeToByte(3): expected 4, got %d", got)
+	}
+	if got := m.RunePosition(Position{Start: 1, End: 4}); got != (Position{Start: 1, End: 3}) {
+		t.Errorf("RunePosition: expected {1 3}, got %v", got)
+	}
+}
+
+func TestUnicodeAwareCounts(t *testing.T) {
+	if got := CalculateSyllableCount("café"); got != 2 {
+		t.Errorf("Expected 2 syllables in café, got %d", got)
+	}
+	if got := CountSyllables("naïve résumé"); got < 4 {
+		t.Errorf("Expected at least 4 syllables, got %d", got)
+	}
+
+	text := strings.Repeat("日本語のテキスト", 10)
+	for _, seg := range segmentByFixedSize(text, 7) {
+		if !strings.HasPrefix(text[seg.Start:], seg.Text) || seg.CharCount > 7 {
+			t.Errorf("Invalid fixed segment %+v", seg)
+		}
+	}
+	var rebuilt strings.Builder
+	for _, seg := range segmentByFixedSize(text, 7) {
+		rebuilt.WriteString(seg.Text)
+	}
+	if rebuilt.String() != text {
+		t.Error("Fixed-size segments should cover the whole text")
+	}
+}
@@ -0,0 +1,689 @@
+package textlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matroska element IDs, length marker included
+const (
+	ebmlHeaderID       = 0x1A45DFA3
+	ebmlDocTypeID      = 0x4282
+	mkvSegmentID       = 0x18538067
+	mkvInfoID          = 0x1549A966
+	mkvTimestampScale  = 0x2AD7B1
+	mkvDurationID      = 0x4489
+	mkvTracksID        = 0x1654AE6B
+	mkvTrackEntryID    = 0xAE
+	mkvTrackNumberID   = 0xD7
+	mkvTrackUIDID      = 0x73C5
+	mkvTrackTypeID     = 0x83
+	mkvCodecID         = 0x86
+	mkvLanguageID      = 0x22B59C
+	mkvLanguageBCP47ID = 0x22B59D
+	mkvNameID          = 0x536E
+	mkvDefaultDuration = 0x23E383
+	mkvVideoID         = 0xE0
+	mkvPixelWidthID    = 0xB0
+	mkvPixelHeightID   = 0xBA
+	mkvAudioID         = 0xE1
+	mkvSamplingFreqID  = 0xB5
+	mkvChannelsID      = 0x9F
+	mkvTagsID          = 0x1254C367
+	mkvTagID           = 0x7373
+	mkvTargetsID       = 0x63C0
+	mkvTagTrackUIDID   = 0x63C5
+	mkvSimpleTagID     = 0x67C8
+	mkvTagNameID       = 0x45A3
+	mkvTagStringID     = 0x4487
+)
+
+// mediaCodecNames maps ISO-BMFF sample entries, Matroska codec IDs and
+// upper-cased AVI FourCCs to codec names. Audio sample entries come from
+// mp4AudioCodecs.
+var mediaCodecNames = map[string]string{
+	"avc1": "H.264", "avc3": "H.264", "hvc1": "H.265", "hev1": "H.265", "av01": "AV1",
+	"vp08": "VP8", "vp09": "VP9", "mp4v": "MPEG-4 Part 2", "s263": "H.263",
+	"jpeg": "Motion JPEG", "mjpa": "Motion JPEG", "apch": "ProRes", "apcn": "ProRes",
+	"apcs": "ProRes", "apco": "ProRes", "ap4h": "ProRes",
+	"tx3g": "Timed Text", "wvtt": "WebVTT", "stpp": "TTML", "c608": "CEA-608",
+
+	"V_MPEG4/ISO/AVC": "H.264", "V_MPEGH/ISO/HEVC": "H.265", "V_AV1": "AV1", "V_VP8": "VP8",
+	"V_VP9": "VP9", "V_MPEG4/ISO/ASP": "MPEG-4 Part 2", "V_MPEG2": "MPEG-2", "V_THEORA": "Theora",
+	"V_PRORES": "ProRes", "V_MJPEG": "Motion JPEG",
+	"A_AAC": "AAC", "A_OPUS": "Opus", "A_VORBIS": "Vorbis", "A_FLAC": "FLAC", "A_AC3": "AC-3",
+	"A_EAC3": "E-AC-3", "A_DTS": "DTS", "A_TRUEHD": "TrueHD", "A_MPEG/L3": "MP3",
+	"A_MPEG/L2": "MP2", "A_PCM": "PCM",
+	"S_TEXT/UTF8": "SubRip", "S_TEXT/ASS": "ASS", "S_TEXT/SSA": "SSA", "S_TEXT/WEBVTT": "WebVTT",
+	"S_HDMV/PGS": "PGS", "S_VOBSUB": "VobSub",
+
+	"H264": "H.264", "X264": "H.264", "AVC1": "H.264", "HEVC": "H.265", "H265": "H.265",
+	"XVID": "MPEG-4 Part 2", "DIVX": "MPEG-4 Part 2", "DX50": "MPEG-4 Part 2", "FMP4": "MPEG-4 Part 2",
+	"MP4V": "MPEG-4 Part 2", "MJPG": "Motion JPEG", "VP80": "VP8", "VP90": "VP9", "AV01": "AV1",
+	"MPG2": "MPEG-2", "WMV3": "WMV9", "WVC1": "VC-1",
+}
+
+// Track types by ISO-BMFF handler and AVI stream type
+var (
+	mp4TrackTypes = map[string]string{
+		"vide": "video", "soun": "audio", "sbtl": "subtitle", "subt": "subtitle",
+		"text": "subtitle", "clcp": "subtitle",
+	}
+	aviTrackTypes = map[string]string{
+		"vids": "video", "auds": "audio", "txts": "subtitle",
+	}
+)
+
+// mediaCodecName returns the readable name of a codec ID and whether it is
+// known. Matroska IDs fall back to their shorter prefixes, so A_AAC/MPEG4/LC
+// is AAC.
+func mediaCodecName(id string) (string, bool) {
+	for s := id; s != ""; {
+		if name, ok := mediaCodecNames[s]; ok {
+			return name, true
+		}
+		if name, ok := mp4AudioCodecs[s]; ok {
+			return name, true
+		}
+		i := strings.LastIndexByte(s, '/')
+		if i < 0 {
+			break
+		}
+		s = s[:i]
+	}
+	return id, false
+}
+
+// summarizeTracks fills the CodecInfo summary fields from its tracks. The
+// file is valid when its first video track has a known codec and a size.
+func summarizeTracks(codec *CodecInfo, size int64) {
+	for _, t := range codec.Tracks {
+		if t.Duration > codec.Duration {
+			codec.Duration = t.Duration
+		}
+		switch t.Type {
+		case "video":
+			if codec.VideoCodec == "" {
+				_, known := mediaCodecName(t.FourCC)
+				codec.VideoCodec = t.Codec
+				codec.Resolution = fmt.Sprintf("%dx%d", t.Width, t.Height)
+				codec.Framerate = t.Framerate
+				codec.IsValid = known && t.Width > 0 && t.Height > 0
+			}
+		case "audio":
+			if codec.AudioCodec == "" {
+				codec.AudioCodec = t.Codec
+			}
+		case "subtitle":
+			codec.HasSubtitles = true
+		}
+	}
+	codec.Bitrate = audioBitrate(size, codec.Duration)
+}
+
+// roundFramerate keeps three decimals, enough for 23.976 and 29.97
+func roundFramerate(fps float64) float64 {
+	return math.Round(fps*1000) / 1000
+}
+
+// isMP4BoxType reports whether a file opens with an ISO-BMFF box. Old
+// QuickTime files have no ftyp and start with moov, mdat, wide, free or skip.
+func isMP4BoxType(typ string) bool {
+	switch typ {
+	case "ftyp", "moov", "mdat", "wide", "free", "skip":
+		return true
+	}
+	return false
+}
+
+func parseMP4Container(r io.ReaderAt, size int64, codec *CodecInfo) error {
+	ftyp, moov, _ := readMP4File(r, size)
+	codec.Container = "MP4"
+	if len(ftyp) < 4 || string(ftyp[:4]) == "qt  " {
+		codec.Container = "QuickTime"
+	}
+	if moov == nil {
+		return fmt.Errorf("missing moov box")
+	}
+
+	var scale, duration uint64
+	mp4Boxes(moov, func(typ string, body []byte) {
+		switch typ {
+		case "mvhd":
+			scale, duration = mp4TimeHeader(body)
+		case "trak":
+			codec.Tracks = append(codec.Tracks, parseMP4Track(body))
+		}
+	})
+	codec.Duration = mp4Duration(duration, scale)
+	return nil
+}
+
+// parseMP4Track reads a trak box: the track ID from tkhd, timing and
+// language from mdhd, the codec from the first stsd entry, and frame and
+// byte counts from the stts and stsz sample tables
+func parseMP4Track(trak []byte) TrackInfo {
+	var t TrackInfo
+	var entry []byte
+	var scale, duration, samples, sampleBytes uint64
+	var walk func(data []byte)
+	walk = func(data []byte) {
+		mp4Boxes(data, func(typ string, body []byte) {
+			switch typ {
+			case "mdia", "minf", "stbl":
+				walk(body)
+			case "tkhd":
+				if len(body) >= 24 && body[0] == 1 {
+					t.ID = int(binary.BigEndian.Uint32(body[20:]))
+				} else if len(body) >= 16 {
+					t.ID = int(binary.BigEndian.Uint32(body[12:]))
+				}
+			case "mdhd":
+				scale, duration = mp4TimeHeader(body)
+				t.Language = mp4Language(body)
+			case "hdlr":
+				if len(body) >= 12 {
+					t.Type = mp4TrackTypes[string(body[8:12])]
+				}
+			case "stsd":
+				if len(body) >= 8 {
+					mp4Boxes(body[8:], func(typ string, body []byte) {
+						if entry == nil {
+							t.FourCC, entry = typ, body
+						}
+					})
+				}
+			case "stts":
+				for i := 8; i+8 <= len(body); i += 8 {
+					samples += uint64(binary.BigEndian.Uint32(body[i:]))
+				}
+			case "stsz":
+				if len(body) < 12 {
+					break
+				}
+				if size := uint64(binary.BigEndian.Uint32(body[4:])); size > 0 {
+					sampleBytes = size * uint64(binary.BigEndian.Uint32(body[8:]))
+					break
+				}
+				for i := 12; i+4 <= len(body); i += 4 {
+					sampleBytes += uint64(binary.BigEndian.Uint32(body[i:]))
+				}
+			}
+		})
+	}
+	walk(trak)
+	if t.Type == "" {
+		t.Type = "data"
+	}
+
+	// Visual sample entries put width and height after 24 bytes of fixed
+	// fields; audio entries hold channels and a 16.16 sample rate
+	headerLen := 0
+	switch t.Type {
+	case "video":
+		if len(entry) >= 28 {
+			t.Width = int(binary.BigEndian.Uint16(entry[24:]))
+			t.Height = int(binary.BigEndian.Uint16(entry[26:]))
+		}
+		headerLen = 78
+	case "audio":
+		if len(entry) >= 28 {
+			t.Channels = int(binary.BigEndian.Uint16(entry[16:]))
+			t.SampleRate = int(binary.BigEndian.Uint32(entry[24:]) >> 16)
+		}
+		if t.SampleRate == 0 {
+			t.SampleRate = int(scale)
+		}
+		headerLen = 28
+	}
+	// Encrypted entries keep the original format in sinf/frma
+	if (t.FourCC == "encv" || t.FourCC == "enca") && len(entry) > headerLen {
+		mp4Boxes(entry[headerLen:], func(typ string, body []byte) {
+			if typ == "sinf" {
+				mp4Boxes(body, func(typ string, body []byte) {
+					if typ == "frma" && len(body) >= 4 {
+						t.FourCC = string(body[:4])
+					}
+				})
+			}
+		})
+	}
+	t.Codec, _ = mediaCodecName(t.FourCC)
+
+	t.Duration = mp4Duration(duration, scale)
+	if t.Type == "video" && duration > 0 {
+		t.Framerate = roundFramerate(float64(samples) * float64(scale) / float64(duration))
+	}
+	t.Bitrate = audioBitrate(int64(sampleBytes), t.Duration)
+	return t
+}
+
+// mp4Language decodes the packed ISO 639-2 code of an mdhd box
+func mp4Language(mdhd []byte) string {
+	off := 20
+	if len(mdhd) > 0 && mdhd[0] == 1 {
+		off = 32
+	}
+	if len(mdhd) < off+2 {
+		return ""
+	}
+	v := binary.BigEndian.Uint16(mdhd[off:])
+	lang := string([]byte{byte(v>>10&31) + 0x60, byte(v>>5&31) + 0x60, byte(v&31) + 0x60})
+	if v == 0 || lang == "und" {
+		return ""
+	}
+	return lang
+}
+
+// ebmlVint decodes an EBML variable-length integer of up to 8 bytes,
+// returning its value and length, or a zero length when invalid. IDs keep
+// their length marker bit; sizes drop it.
+func ebmlVint(b []byte, keepMarker bool) (uint64, int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+	n := bits.LeadingZeros8(b[0]) + 1
+	if n > len(b) {
+		return 0, 0
+	}
+	value := uint64(b[0])
+	if !keepMarker {
+		value &= 0xFF >> n
+	}
+	for _, c := range b[1:n] {
+		value = value<<8 | uint64(c)
+	}
+	return value, n
+}
+
+// ebmlUnknownSize reports the reserved all-ones size of a live stream element
+func ebmlUnknownSize(size uint64, n int) bool {
+	return size == 1<<(7*n)-1
+}
+
+// ebmlElements calls fn for each element in data. An element with an
+// unknown size or one that overruns data takes the rest of it.
+func ebmlElements(data []byte, fn func(id uint64, body []byte)) {
+	for len(data) > 0 {
+		id, n := ebmlVint(data, true)
+		if n == 0 || n > 4 {
+			return
+		}
+		size, m := ebmlVint(data[n:], false)
+		if m == 0 {
+			return
+		}
+		data = data[n+m:]
+		if ebmlUnknownSize(size, m) || size > uint64(len(data)) {
+			size = uint64(len(data))
+		}
+		fn(id, data[:size])
+		data = data[size:]
+	}
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func ebmlString(b []byte) string {
+	return string(bytes.TrimRight(b, "\x00"))
+}
+
+// readEBMLHeader reads the ID and size of the element at off. size is -1
+// for an unknown size.
+func readEBMLHeader(r io.ReaderAt, off int64) (id uint64, size, headerLen int64, ok bool) {
+	b := readMediaBlock(r, off, 12)
+	id, n := ebmlVint(b, true)
+	if n == 0 || n > 4 {
+		return 0, 0, 0, false
+	}
+	sz, m := ebmlVint(b[n:], false)
+	if m == 0 {
+		return 0, 0, 0, false
+	}
+	size = int64(sz)
+	if ebmlUnknownSize(sz, m) || size < 0 {
+		size = -1
+	}
+	return id, size, int64(n + m), true
+}
+
+// parseMatroskaContainer reads the Info, Tracks and Tags elements of a
+// Matroska or WebM segment, seeking past clusters. Per-track bitrate and
+// duration come from the statistics tags muxers such as mkvmerge write.
+func parseMatroskaContainer(r io.ReaderAt, size int64, codec *CodecInfo) error {
+	codec.Container = "Matroska"
+	id, headerSize, headerLen, ok := readEBMLHeader(r, 0)
+	if !ok || id != ebmlHeaderID || headerSize < 0 {
+		return fmt.Errorf("invalid EBML header")
+	}
+	ebmlElements(readMediaBlock(r, headerLen, headerSize), func(id uint64, body []byte) {
+		if id == ebmlDocTypeID && ebmlString(body) == "webm" {
+			codec.Container = "WebM"
+		}
+	})
+
+	off := headerLen + headerSize
+	id, segSize, headerLen, ok := readEBMLHeader(r, off)
+	if !ok || id != mkvSegmentID {
+		return fmt.Errorf("missing Matroska segment")
+	}
+	segEnd := off + headerLen + segSize
+	if segSize < 0 || segEnd > size {
+		segEnd = size
+	}
+
+	timestampScale := 1e6
+	var duration float64
+	var uids []uint64
+	tags := make(map[uint64]map[string]string)
+	foundTracks := false
+	for p := off + headerLen; p < segEnd; {
+		id, elemSize, headerLen, ok := readEBMLHeader(r, p)
+		// A live stream cluster of unknown size cannot be skipped
+		if !ok || elemSize < 0 {
+			break
+		}
+		body := p + headerLen
+		switch id {
+		case mkvInfoID:
+			ebmlElements(readMediaBlock(r, body, elemSize), func(id uint64, b []byte) {
+				switch id {
+				case mkvTimestampScale:
+					timestampScale = float64(ebmlUint(b))
+				case mkvDurationID:
+					duration = ebmlFloat(b)
+				}
+			})
+		case mkvTracksID:
+			foundTracks = true
+			ebmlElements(readMediaBlock(r, body, elemSize), func(id uint64, b []byte) {
+				if id == mkvTrackEntryID {
+					t, uid := parseMatroskaTrack(b)
+					codec.Tracks = append(codec.Tracks, t)
+					uids = append(uids, uid)
+				}
+			})
+		case mkvTagsID:
+			parseMatroskaTags(readMediaBlock(r, body, elemSize), tags)
+		}
+		p = body + elemSize
+	}
+	if !foundTracks {
+		return fmt.Errorf("missing Matroska tracks")
+	}
+
+	codec.Duration = time.Duration(duration * timestampScale)
+	for i := range codec.Tracks {
+		t := &codec.Tracks[i]
+		stats := tags[uids[i]]
+		if bps, err := strconv.ParseInt(stats["BPS"], 10, 64); err == nil {
+			t.Bitrate = int(math.Round(float64(bps) / 1000))
+		}
+		if d, ok := parseMatroskaDuration(stats["DURATION"]); ok {
+			t.Duration = d
+		} else {
+			t.Duration = codec.Duration
+		}
+		if frames, err := strconv.ParseInt(stats["NUMBER_OF_FRAMES"], 10, 64); err == nil && t.Type == "video" && t.Framerate == 0 && t.Duration > 0 {
+			t.Framerate = roundFramerate(float64(frames) / t.Duration.Seconds())
+		}
+	}
+	return nil
+}
+
+// parseMatroskaTrack reads a TrackEntry, returning the track and its UID
+func parseMatroskaTrack(entry []byte) (TrackInfo, uint64) {
+	t := TrackInfo{Type: "data", Language: "eng"} // eng is the Matroska default
+	var uid uint64
+	bcp47 := ""
+	ebmlElements(entry, func(id uint64, b []byte) {
+		switch id {
+		case mkvTrackNumberID:
+			t.ID = int(ebmlUint(b))
+		case mkvTrackUIDID:
+			uid = ebmlUint(b)
+		case mkvTrackTypeID:
+			switch ebmlUint(b) {
+			case 1:
+				t.Type = "video"
+			case 2:
+				t.Type = "audio"
+			case 0x11:
+				t.Type = "subtitle"
+			}
+		case mkvCodecID:
+			t.FourCC = ebmlString(b)
+		case mkvLanguageID:
+			t.Language = ebmlString(b)
+		case mkvLanguageBCP47ID:
+			bcp47 = ebmlString(b)
+		case mkvNameID:
+			t.Name = ebmlString(b)
+		case mkvDefaultDuration:
+			if ns := ebmlUint(b); ns > 0 {
+				t.Framerate = roundFramerate(1e9 / float64(ns))
+			}
+		case mkvVideoID:
+			ebmlElements(b, func(id uint64, b []byte) {
+				switch id {
+				case mkvPixelWidthID:
+					t.Width = int(ebmlUint(b))
+				case mkvPixelHeightID:
+					t.Height = int(ebmlUint(b))
+				}
+			})
+		case mkvAudioID:
+			ebmlElements(b, func(id uint64, b []byte) {
+				switch id {
+				case mkvSamplingFreqID:
+					t.SampleRate = int(ebmlFloat(b))
+				case mkvChannelsID:
+					t.Channels = int(ebmlUint(b))
+				}
+			})
+		}
+	})
+	if bcp47 != "" {
+		t.Language = bcp47
+	}
+	if t.Language == "und" {
+		t.Language = ""
+	}
+	// Frame durations of audio tracks are packet sizes, not a frame rate
+	if t.Type != "video" {
+		t.Framerate = 0
+	}
+	t.Codec, _ = mediaCodecName(t.FourCC)
+	return t, uid
+}
+
+// parseMatroskaTags collects the simple tags targeted at each track UID
+func parseMatroskaTags(data []byte, tags map[uint64]map[string]string) {
+	ebmlElements(data, func(id uint64, tag []byte) {
+		if id != mkvTagID {
+			return
+		}
+		var uids []uint64
+		values := make(map[string]string)
+		ebmlElements(tag, func(id uint64, b []byte) {
+			switch id {
+			case mkvTargetsID:
+				ebmlElements(b, func(id uint64, b []byte) {
+					if id == mkvTagTrackUIDID {
+						uids = append(uids, ebmlUint(b))
+					}
+				})
+			case mkvSimpleTagID:
+				var name, value string
+				ebmlElements(b, func(id uint64, b []byte) {
+					switch id {
+					case mkvTagNameID:
+						name = ebmlString(b)
+					case mkvTagStringID:
+						value = ebmlString(b)
+					}
+				})
+				values[strings.ToUpper(name)] = value
+			}
+		})
+		for _, uid := range uids {
+			if tags[uid] == nil {
+				tags[uid] = make(map[string]string)
+			}
+			for k, v := range values {
+				tags[uid][k] = v
+			}
+		}
+	})
+}
+
+// parseMatroskaDuration parses the HH:MM:SS.nnnnnnnnn DURATION tag
+func parseMatroskaDuration(s string) (time.Duration, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	sec, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(math.Round(sec*1e9)), true
+}
+
+// parseAVIContainer reads the hdrl list of an AVI file for its streams and
+// the idx1 index for per-stream byte counts
+func parseAVIContainer(r io.ReaderAt, size int64, codec *CodecInfo) error {
+	codec.Container = "AVI"
+	var hdrl, idx1 []byte
+	header := make([]byte, 12)
+	for off := int64(12); off+8 <= size; {
+		if n, _ := r.ReadAt(header, off); n < 8 {
+			break
+		}
+		id, chunkSize := string(header[:4]), int64(binary.LittleEndian.Uint32(header[4:]))
+		switch {
+		case id == "LIST" && string(header[8:12]) == "hdrl":
+			hdrl = readMediaBlock(r, off+12, chunkSize-4)
+		case id == "idx1":
+			idx1 = readMediaBlock(r, off+8, chunkSize)
+		}
+		off += 8 + chunkSize + chunkSize&1
+	}
+	if hdrl == nil {
+		return fmt.Errorf("missing AVI header")
+	}
+
+	riffChunks(hdrl, func(id string, body []byte) {
+		switch {
+		case id == "avih" && len(body) >= 20:
+			// microseconds per frame times the total frame count
+			usPerFrame := int64(binary.LittleEndian.Uint32(body))
+			frames := int64(binary.LittleEndian.Uint32(body[16:]))
+			codec.Duration = time.Duration(usPerFrame*frames) * time.Microsecond
+		case id == "LIST" && len(body) >= 4 && string(body[:4]) == "strl":
+			t := parseAVIStream(body[4:])
+			t.ID = len(codec.Tracks)
+			codec.Tracks = append(codec.Tracks, t)
+		}
+	})
+
+	// Index entries are named after their stream number, e.g. 00dc or 01wb
+	streamBytes := make(map[int]int64)
+	for i := 0; i+16 <= len(idx1); i += 16 {
+		if n, err := strconv.Atoi(string(idx1[i : i+2])); err == nil {
+			streamBytes[n] += int64(binary.LittleEndian.Uint32(idx1[i+12:]))
+		}
+	}
+	for i := range codec.Tracks {
+		t := &codec.Tracks[i]
+		if b, ok := streamBytes[t.ID]; ok && t.Duration > 0 {
+			t.Bitrate = audioBitrate(b, t.Duration)
+		}
+	}
+	return nil
+}
+
+// parseAVIStream reads the strh, strf and strn chunks of one stream
+func parseAVIStream(strl []byte) TrackInfo {
+	t := TrackInfo{Type: "data"}
+	handler := ""
+	riffChunks(strl, func(id string, body []byte) {
+		switch id {
+		case "strh":
+			if len(body) < 36 {
+				return
+			}
+			if typ, ok := aviTrackTypes[string(body[:4])]; ok {
+				t.Type = typ
+			}
+			handler = strings.TrimRight(string(body[4:8]), "\x00 ")
+			scale := float64(binary.LittleEndian.Uint32(body[20:]))
+			rate := float64(binary.LittleEndian.Uint32(body[24:]))
+			length := float64(binary.LittleEndian.Uint32(body[32:]))
+			if scale > 0 && rate > 0 {
+				t.Duration = time.Duration(math.Round(length * scale / rate * 1e9))
+				if t.Type == "video" {
+					t.Framerate = roundFramerate(rate / scale)
+				}
+			}
+		case "strf":
+			switch {
+			case t.Type == "video" && len(body) >= 20:
+				// BITMAPINFOHEADER; height is negative for top-down images
+				t.Width = int(int32(binary.LittleEndian.Uint32(body[4:])))
+				t.Height = int(int32(binary.LittleEndian.Uint32(body[8:])))
+				if t.Height < 0 {
+					t.Height = -t.Height
+				}
+				t.FourCC = strings.TrimRight(string(body[16:20]), "\x00 ")
+			case t.Type == "audio" && len(body) >= 16:
+				// WAVEFORMATEX
+				tag := binary.LittleEndian.Uint16(body)
+				t.FourCC = fmt.Sprintf("0x%04X", tag)
+				t.Codec = wavCodecs[tag]
+				t.Channels = int(binary.LittleEndian.Uint16(body[2:]))
+				t.SampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+				t.Bitrate = int(binary.LittleEndian.Uint32(body[8:]) * 8 / 1000)
+			}
+		case "strn":
+			t.Name = ebmlString(body)
+		}
+	})
+
+	if t.Type == "video" {
+		if !isPrintableASCII([]byte(t.FourCC)) {
+			t.FourCC = handler
+		}
+		t.FourCC = strings.ToUpper(t.FourCC)
+		t.Codec, _ = mediaCodecName(t.FourCC)
+	}
+	if t.Codec == "" {
+		t.Codec = t.FourCC
+	}
+	return t
+}
@@ -15,6 +15,7 @@
 package textlib
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -109,7 +110,7 @@ func TestFixIndentation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := FixIndentation(tt.code)
+			result := FixIndentation(tt.code, IndentStyle{Type: "spaces", Size: 4})
 			// Just test that it doesn't panic and returns something
 			if len(result) == 0 && len(tt.code) > 0 {
 				t.Errorf("FixIndentation(%q) returned empty string for non-empty input", tt.name)
@@ -141,7 +142,11 @@ func TestGetIndentString(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := getIndentString(tt.useSpaces, tt.indentSize, tt.level)
+			style := IndentStyle{Type: "tabs", Size: tt.indentSize}
+			if tt.useSpaces {
+				style.Type = "spaces"
+			}
+			result := strings.Repeat(getIndentString(style), tt.level)
 			expectedLen := tt.level * tt.indentSize
 			if tt.useSpaces && len(result) != expectedLen {
 				t.Errorf("getIndentString(%v, %d, %d) length = %d, expected %d", 
@@ -204,7 +209,7 @@ func TestConvertTabsToSpaces(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ConvertTabsToSpaces(tt.code)
+			result := ConvertTabsToSpaces(tt.code, 4)
 			if result != tt.expected {
 				t.Errorf("ConvertTabsToSpaces(%q) = %q, expected %q", tt.code, result, tt.expected)
 			}
@@ -232,7 +237,7 @@ func TestConvertSpacesToTabs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ConvertSpacesToTabs(tt.code)
+			result := ConvertSpacesToTabs(tt.code, 4)
 			if result != tt.expected {
 				t.Errorf("ConvertSpacesToTabs(%q) = %q, expected %q", tt.code, result, tt.expected)
 			}
@@ -277,14 +282,14 @@ func TestDetectBraceStyle(t *testing.T) {
 	tests := []struct {
 		name     string
 		code     string
-		expected string
+		expected BraceStyle
 	}{
 		{
 			name: "K&R style",
 			code: `function test() {
     return true;
 }`,
-			expected: "k&r",
+			expected: SameLine,
 		},
 		{
 			name: "Allman style",
@@ -292,12 +297,12 @@ func TestDetectBraceStyle(t *testing.T) {
 {
     return true;
 }`,
-			expected: "allman",
+			expected: NextLine,
 		},
 		{
 			name:     "No braces",
 			code:     "var x = 5;",
-			expected: "none",
+			expected: SameLine,
 		},
 	}
 
@@ -305,7 +310,7 @@ func TestDetectBraceStyle(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			result := DetectBraceStyle(tt.code)
 			if result != tt.expected {
-				t.Errorf("DetectBraceStyle(%q) = %q, expected %q", tt.code, result, tt.expected)
+				t.Errorf("DetectBraceStyle(%q) = %v, expected %v", tt.code, result, tt.expected)
 			}
 		})
 	}
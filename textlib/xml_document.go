@@ -0,0 +1,1179 @@
+package textlib
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Default limits for XMLValidationOptions
+const (
+	DefaultXMLMaxEntityExpansion = 1 << 20
+	DefaultXMLMaxDepth           = 256
+)
+
+// XMLValidationOptions configures ValidateXML and ParseXMLDocument.
+// External entities and DTDs are never fetched, in any mode.
+type XMLValidationOptions struct {
+	Schema             *XMLSchema // XSD subset to validate against
+	SchemaPath         string     // XSD file to load when Schema is nil
+	DTD                bool       // validate against the document's internal DTD subset
+	Hardened           bool       // reject external entities and DTDs, parameter entities and deep nesting
+	MaxEntityExpansion int        // bytes entity references may expand to (default 1 MiB)
+	MaxDepth           int        // element nesting limit when hardened (default 256)
+}
+
+// XMLDocument is a parsed XML document
+type XMLDocument struct {
+	Root *XMLNode
+	DTD  *XMLDTD // internal DTD subset, nil without a DOCTYPE
+}
+
+// XMLNode is an element of a parsed document
+type XMLNode struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Children []*XMLNode // child elements
+	Text     string     // character data directly inside the element
+	Parent   *XMLNode
+	Line     int
+	Column   int
+
+	content []interface{} // text (string) and child elements in document order
+}
+
+// XMLDTD is a document type declaration and its internal subset
+type XMLDTD struct {
+	Name     string // document element name
+	SystemID string // external DTD, which is never loaded
+	PublicID string
+	Entities map[string]*XMLEntity
+
+	elements   map[string]*dtdElement
+	attributes map[string][]dtdAttribute
+	line       int
+}
+
+// XMLEntity is a general or parameter entity declared in a DTD
+type XMLEntity struct {
+	Name      string
+	Value     string // replacement text of internal entities
+	SystemID  string // external entities only
+	PublicID  string
+	Parameter bool
+	Line      int
+}
+
+// External reports whether the entity's text lives outside the document
+func (e *XMLEntity) External() bool {
+	return e.SystemID != "" || e.PublicID != ""
+}
+
+type dtdElement struct {
+	name    string
+	kind    string // "EMPTY", "ANY", "mixed" or "children"
+	mixed   map[string]bool
+	content *contentParticle
+	line    int
+}
+
+type dtdAttribute struct {
+	name     string
+	kind     string   // CDATA, ID, IDREF, NMTOKEN, ... or "enum"
+	values   []string // enumeration values
+	required bool
+	fixed    bool
+	value    string // default or fixed value
+}
+
+// ValidateXML checks that the XML file is well formed and, as configured,
+// valid against its internal DTD and an XSD subset. The error is non-nil
+// only when a file cannot be read or the schema is invalid.
+func ValidateXML(xmlPath string, opts XMLValidationOptions) ([]XMLError, error) {
+	data, err := os.ReadFile(xmlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := opts.Schema
+	if schema == nil && opts.SchemaPath != "" {
+		if schema, err = LoadXMLSchema(opts.SchemaPath); err != nil {
+			return nil, err
+		}
+	}
+
+	doc, errs := parseXMLDocument(data, opts)
+	if doc == nil {
+		return errs, nil
+	}
+	if opts.DTD {
+		if doc.DTD == nil {
+			errs = append(errs, XMLError{Line: 1, Column: 1, Message: "document has no DOCTYPE declaration", Type: "DTD_ERROR"})
+		} else {
+			errs = append(errs, doc.DTD.validate(doc)...)
+		}
+	}
+	if schema != nil {
+		errs = append(errs, schema.Validate(doc)...)
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return errs, nil
+}
+
+// LoadXMLDocument parses the XML file at path
+func LoadXMLDocument(path string, opts XMLValidationOptions) (*XMLDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseXMLDocument(data, opts)
+}
+
+// ParseXMLDocument parses XML into a tree, expanding internal entities.
+// Only the parsing options apply; use ValidateXML for validation.
+func ParseXMLDocument(data []byte, opts XMLValidationOptions) (*XMLDocument, error) {
+	doc, errs := parseXMLDocument(data, opts)
+	if doc == nil {
+		return nil, fmt.Errorf("line %d: %s", errs[0].Line, errs[0].Message)
+	}
+	return doc, nil
+}
+
+// parseXMLDocument builds the tree. It returns a nil document with the
+// fatal error when the XML is malformed or unsafe.
+func parseXMLDocument(data []byte, opts XMLValidationOptions) (*XMLDocument, []XMLError) {
+	maxExpansion := opts.MaxEntityExpansion
+	if maxExpansion <= 0 {
+		maxExpansion = DefaultXMLMaxEntityExpansion
+	}
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultXMLMaxDepth
+	}
+
+	fatal := func(line, col int, kind, format string, args ...interface{}) (*XMLDocument, []XMLError) {
+		return nil, []XMLError{{Line: line, Column: col, Message: fmt.Sprintf(format, args...), Type: kind}}
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	doc := &XMLDocument{}
+	cursor := &xmlCursor{data: data, line: 1, col: 1}
+	var stack []*xmlFrame
+
+	for {
+		line, col := dec.InputPos()
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errLine, errCol := dec.InputPos()
+			if syntaxErr, ok := err.(*xml.SyntaxError); ok {
+				if syntaxErr.Line != errLine {
+					errCol = 0
+				}
+				return fatal(syntaxErr.Line, errCol, "SYNTAX_ERROR", "%s", syntaxErr.Msg)
+			}
+			return fatal(errLine, errCol, "SYNTAX_ERROR", "%s", err.Error())
+		}
+
+		switch t := tok.(type) {
+		case xml.Directive:
+			if !bytes.HasPrefix(t, []byte("DOCTYPE")) {
+				continue
+			}
+			if doc.DTD != nil || doc.Root != nil {
+				return fatal(line, col, "SYNTAX_ERROR", "unexpected DOCTYPE declaration")
+			}
+			dtd, err := parseDTD(string(t), line)
+			if err != nil {
+				return fatal(line, col, "DTD_ERROR", "%v", err)
+			}
+			doc.DTD = dtd
+
+			if opts.Hardened {
+				if problems := dtd.securityProblems(); len(problems) > 0 {
+					return nil, problems
+				}
+			}
+			entities, err := dtd.expandEntities(maxExpansion)
+			if err != nil {
+				return fatal(line, col, "SECURITY_ERROR", "%v", err)
+			}
+			if total := entityReferenceSize(data, entities); total > maxExpansion {
+				return fatal(line, col, "SECURITY_ERROR", "entity references expand to %d bytes, more than the limit of %d", total, maxExpansion)
+			}
+			dec.Entity = entities
+
+		case xml.StartElement:
+			if len(stack) == 0 && doc.Root != nil {
+				return fatal(line, col, "SYNTAX_ERROR", "document has more than one root element")
+			}
+			if opts.Hardened && len(stack) >= maxDepth {
+				return fatal(line, col, "SECURITY_ERROR", "elements nest deeper than %d levels", maxDepth)
+			}
+			node := &XMLNode{Name: t.Name, Attrs: t.Copy().Attr}
+			// The offset is the end of the previous token; step past
+			// whitespace to where the element starts
+			node.Line, node.Column = cursor.skipTo(int(offset))
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.flushText()
+				node.Parent = parent.node
+				parent.node.Children = append(parent.node.Children, node)
+				parent.node.content = append(parent.node.content, node)
+			} else {
+				doc.Root = node
+			}
+			stack = append(stack, &xmlFrame{node: node})
+
+		case xml.EndElement:
+			frame := stack[len(stack)-1]
+			frame.flushText()
+			frame.node.Text = frame.text.String()
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if len(stack) == 0 {
+				if strings.TrimSpace(string(t)) != "" {
+					return fatal(line, col, "SYNTAX_ERROR", "character data outside the root element")
+				}
+				continue
+			}
+			frame := stack[len(stack)-1]
+			frame.text.Write(t)
+			frame.run.Write(t)
+		}
+	}
+
+	if doc.Root == nil {
+		return fatal(1, 1, "SYNTAX_ERROR", "document has no root element")
+	}
+	return doc, nil
+}
+
+// xmlFrame is an open element while the tree is built
+type xmlFrame struct {
+	node *XMLNode
+	text strings.Builder // all character data directly inside the element
+	run  strings.Builder // character data since the last child element
+}
+
+// flushText appends the pending text run to the node's content. CDATA
+// sections and entity references split text into several tokens; they
+// stay together as one text node.
+func (f *xmlFrame) flushText() {
+	if f.run.Len() > 0 {
+		f.node.content = append(f.node.content, f.run.String())
+		f.run.Reset()
+	}
+}
+
+// xmlCursor turns increasing byte offsets into line and column numbers,
+// moving forward from the previous position instead of rescanning
+type xmlCursor struct {
+	data      []byte
+	offset    int
+	line, col int
+}
+
+// skipTo moves the cursor to offset and then past whitespace so it points
+// at the next markup
+func (c *xmlCursor) skipTo(offset int) (int, int) {
+	for c.offset < len(c.data) {
+		b := c.data[c.offset]
+		if c.offset >= offset && b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			break
+		}
+		if b == '\n' {
+			c.line++
+			c.col = 1
+		} else {
+			c.col++
+		}
+		c.offset++
+	}
+	return c.line, c.col
+}
+
+// Attr returns the value of the attribute with the given local name
+func (n *XMLNode) Attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// StringValue returns the text of the element and all its descendants, in
+// document order
+func (n *XMLNode) StringValue() string {
+	var b strings.Builder
+	var walk func(*XMLNode)
+	walk = func(node *XMLNode) {
+		for _, part := range node.content {
+			switch p := part.(type) {
+			case string:
+				b.WriteString(p)
+			case *XMLNode:
+				walk(p)
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// Path returns a location such as /order/item[2] for error messages
+func (n *XMLNode) Path() string {
+	if n.Parent == nil {
+		return "/" + n.Name.Local
+	}
+	index, count := 0, 0
+	for _, sibling := range n.Parent.Children {
+		if sibling.Name.Local == n.Name.Local {
+			count++
+			if sibling == n {
+				index = count
+			}
+		}
+	}
+	if count > 1 {
+		return fmt.Sprintf("%s/%s[%d]", n.Parent.Path(), n.Name.Local, index)
+	}
+	return n.Parent.Path() + "/" + n.Name.Local
+}
+
+// xmlError builds a validation error located at the element
+func (n *XMLNode) xmlError(kind, format string, args ...interface{}) XMLError {
+	return XMLError{Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...), Type: kind, Path: n.Path()}
+}
+
+// childNames lists the local names of the element's children
+func (n *XMLNode) childNames() []string {
+	names := make([]string, len(n.Children))
+	for i, child := range n.Children {
+		names[i] = child.Name.Local
+	}
+	return names
+}
+
+func isXMLNamespaceAttr(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") ||
+		a.Name.Space == "http://www.w3.org/2001/XMLSchema-instance" || a.Name.Space == "http://www.w3.org/XML/1998/namespace"
+}
+
+// parseDTD parses the body of a DOCTYPE directive: the name, an optional
+// external ID and the internal subset
+func parseDTD(directive string, line int) (*XMLDTD, error) {
+	s := &dtdScanner{src: strings.TrimPrefix(directive, "DOCTYPE"), line: line}
+	dtd := &XMLDTD{
+		Entities:   make(map[string]*XMLEntity),
+		elements:   make(map[string]*dtdElement),
+		attributes: make(map[string][]dtdAttribute),
+		line:       line,
+	}
+
+	s.skipSpace()
+	if dtd.Name = s.name(); dtd.Name == "" {
+		return nil, fmt.Errorf("DOCTYPE declaration has no name")
+	}
+	s.skipSpace()
+	var err error
+	if dtd.SystemID, dtd.PublicID, err = s.externalID(); err != nil {
+		return nil, err
+	}
+	s.skipSpace()
+	if !s.consume("[") {
+		return dtd, nil
+	}
+
+	for {
+		s.skipSpace()
+		switch {
+		case s.pos >= len(s.src):
+			return nil, fmt.Errorf("unterminated internal DTD subset")
+		case s.consume("]"):
+			return dtd, nil
+		case s.consume("<!--"):
+			if !s.skipPast("-->") {
+				return nil, fmt.Errorf("unterminated comment in DTD")
+			}
+		case s.consume("<?"):
+			if !s.skipPast("?>") {
+				return nil, fmt.Errorf("unterminated processing instruction in DTD")
+			}
+		case s.consume("%"):
+			// Parameter entity reference between declarations
+			s.name()
+			s.consume(";")
+		case s.consume("<!ELEMENT"):
+			if err := s.elementDecl(dtd); err != nil {
+				return nil, err
+			}
+		case s.consume("<!ATTLIST"):
+			if err := s.attlistDecl(dtd); err != nil {
+				return nil, err
+			}
+		case s.consume("<!ENTITY"):
+			if err := s.entityDecl(dtd); err != nil {
+				return nil, err
+			}
+		case s.consume("<!NOTATION"):
+			s.skipPast(">")
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q in DTD", s.currentLine(), s.peekWord())
+		}
+	}
+}
+
+type dtdScanner struct {
+	src  string
+	pos  int
+	line int // line the directive starts on
+}
+
+func (s *dtdScanner) currentLine() int {
+	return s.line + strings.Count(s.src[:s.pos], "\n")
+}
+
+func (s *dtdScanner) skipSpace() {
+	for s.pos < len(s.src) && strings.IndexByte(" \t\r\n", s.src[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+func (s *dtdScanner) consume(prefix string) bool {
+	if strings.HasPrefix(s.src[s.pos:], prefix) {
+		s.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (s *dtdScanner) skipPast(marker string) bool {
+	i := strings.Index(s.src[s.pos:], marker)
+	if i < 0 {
+		s.pos = len(s.src)
+		return false
+	}
+	s.pos += i + len(marker)
+	return true
+}
+
+func (s *dtdScanner) peekWord() string {
+	end := s.pos
+	for end < len(s.src) && end-s.pos < 20 && !unicode.IsSpace(rune(s.src[end])) {
+		end++
+	}
+	return s.src[s.pos:end]
+}
+
+func isXMLNameByte(b byte, first bool) bool {
+	if b >= 0x80 || b == '_' || b == ':' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') {
+		return true
+	}
+	return !first && (b == '-' || b == '.' || (b >= '0' && b <= '9'))
+}
+
+func (s *dtdScanner) name() string {
+	start := s.pos
+	for s.pos < len(s.src) && isXMLNameByte(s.src[s.pos], s.pos == start) {
+		s.pos++
+	}
+	return s.src[start:s.pos]
+}
+
+func (s *dtdScanner) quoted() (string, error) {
+	if s.pos >= len(s.src) || (s.src[s.pos] != '"' && s.src[s.pos] != '\'') {
+		return "", fmt.Errorf("line %d: expected a quoted string", s.currentLine())
+	}
+	quote := s.src[s.pos]
+	end := strings.IndexByte(s.src[s.pos+1:], quote)
+	if end < 0 {
+		return "", fmt.Errorf("line %d: unterminated string", s.currentLine())
+	}
+	value := s.src[s.pos+1 : s.pos+1+end]
+	s.pos += end + 2
+	return value, nil
+}
+
+func (s *dtdScanner) externalID() (system, public string, err error) {
+	switch {
+	case s.consume("SYSTEM"):
+		s.skipSpace()
+		system, err = s.quoted()
+	case s.consume("PUBLIC"):
+		s.skipSpace()
+		if public, err = s.quoted(); err != nil {
+			return
+		}
+		s.skipSpace()
+		system, err = s.quoted()
+	}
+	return
+}
+
+// declEnd finishes a declaration at its closing '>'
+func (s *dtdScanner) declEnd(kind string) error {
+	s.skipSpace()
+	if !s.consume(">") {
+		return fmt.Errorf("line %d: malformed %s declaration", s.currentLine(), kind)
+	}
+	return nil
+}
+
+func (s *dtdScanner) elementDecl(dtd *XMLDTD) error {
+	line := s.currentLine()
+	s.skipSpace()
+	el := &dtdElement{name: s.name(), line: line}
+	if el.name == "" {
+		return fmt.Errorf("line %d: ELEMENT declaration has no name", line)
+	}
+	s.skipSpace()
+
+	switch {
+	case s.consume("EMPTY"):
+		el.kind = "EMPTY"
+	case s.consume("ANY"):
+		el.kind = "ANY"
+	default:
+		end := strings.IndexByte(s.src[s.pos:], '>')
+		if end < 0 {
+			return fmt.Errorf("line %d: malformed ELEMENT declaration", line)
+		}
+		model := strings.Join(strings.Fields(s.src[s.pos:s.pos+end]), "")
+		s.pos += end
+		if strings.HasPrefix(model, "(#PCDATA") {
+			el.kind = "mixed"
+			el.mixed = make(map[string]bool)
+			inner := strings.TrimSuffix(strings.TrimSuffix(model, "*"), ")")
+			for _, name := range strings.Split(strings.TrimPrefix(inner, "("), "|")[1:] {
+				el.mixed[name] = true
+			}
+		} else {
+			el.kind = "children"
+			p := &contentModelParser{src: model}
+			particle, err := p.parse()
+			if err != nil {
+				return fmt.Errorf("line %d: element %s: %v", line, el.name, err)
+			}
+			el.content = particle
+		}
+	}
+	dtd.elements[el.name] = el
+	return s.declEnd("ELEMENT")
+}
+
+func (s *dtdScanner) attlistDecl(dtd *XMLDTD) error {
+	line := s.currentLine()
+	s.skipSpace()
+	element := s.name()
+	for {
+		s.skipSpace()
+		if s.consume(">") {
+			return nil
+		}
+		attr := dtdAttribute{name: s.name()}
+		if attr.name == "" {
+			return fmt.Errorf("line %d: malformed ATTLIST declaration", line)
+		}
+		s.skipSpace()
+		if s.consume("(") {
+			end := strings.IndexByte(s.src[s.pos:], ')')
+			if end < 0 {
+				return fmt.Errorf("line %d: malformed enumeration", line)
+			}
+			attr.kind = "enum"
+			for _, v := range strings.Split(s.src[s.pos:s.pos+end], "|") {
+				attr.values = append(attr.values, strings.TrimSpace(v))
+			}
+			s.pos += end + 1
+		} else {
+			attr.kind = s.name()
+			if attr.kind == "NOTATION" {
+				s.skipSpace()
+				s.skipPast(")")
+			}
+		}
+		s.skipSpace()
+		switch {
+		case s.consume("#REQUIRED"):
+			attr.required = true
+		case s.consume("#IMPLIED"):
+		default:
+			if s.consume("#FIXED") {
+				attr.fixed = true
+				s.skipSpace()
+			}
+			value, err := s.quoted()
+			if err != nil {
+				return err
+			}
+			attr.value = value
+		}
+		dtd.attributes[element] = append(dtd.attributes[element], attr)
+	}
+}
+
+func (s *dtdScanner) entityDecl(dtd *XMLDTD) error {
+	line := s.currentLine()
+	s.skipSpace()
+	entity := &XMLEntity{Line: line}
+	if s.consume("%") {
+		entity.Parameter = true
+		s.skipSpace()
+	}
+	if entity.Name = s.name(); entity.Name == "" {
+		return fmt.Errorf("line %d: ENTITY declaration has no name", line)
+	}
+	s.skipSpace()
+
+	var err error
+	if s.pos < len(s.src) && (s.src[s.pos] == '"' || s.src[s.pos] == '\'') {
+		entity.Value, err = s.quoted()
+	} else {
+		entity.SystemID, entity.PublicID, err = s.externalID()
+		if err == nil && !entity.External() {
+			err = fmt.Errorf("line %d: entity %s has no value", line, entity.Name)
+		}
+		s.skipSpace()
+		if s.consume("NDATA") {
+			s.skipSpace()
+			s.name()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	key := entity.Name
+	if entity.Parameter {
+		key = "%" + key
+	}
+	// The first declaration of an entity is binding
+	if _, exists := dtd.Entities[key]; !exists {
+		dtd.Entities[key] = entity
+	}
+	return s.declEnd("ENTITY")
+}
+
+// securityProblems lists what hardened parsing rejects
+func (d *XMLDTD) securityProblems() []XMLError {
+	var problems []XMLError
+	if d.SystemID != "" || d.PublicID != "" {
+		problems = append(problems, XMLError{Line: d.line, Message: fmt.Sprintf("external DTD %q is not allowed", d.SystemID), Type: "SECURITY_ERROR"})
+	}
+	for _, name := range sortedEntityNames(d.Entities) {
+		e := d.Entities[name]
+		switch {
+		case e.External():
+			problems = append(problems, XMLError{Line: e.Line, Message: fmt.Sprintf("external entity %s (%q) is not allowed", e.Name, e.SystemID), Type: "SECURITY_ERROR"})
+		case e.Parameter:
+			problems = append(problems, XMLError{Line: e.Line, Message: fmt.Sprintf("parameter entity %s is not allowed", e.Name), Type: "SECURITY_ERROR"})
+		}
+	}
+	return problems
+}
+
+func sortedEntityNames(entities map[string]*XMLEntity) []string {
+	names := make([]string, 0, len(entities))
+	for name := range entities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var entityReferencePattern = regexp.MustCompile(`&(#x[0-9A-Fa-f]+|#[0-9]+|[A-Za-z_:][-A-Za-z0-9_.:]*);`)
+
+// entityExpansionSizes computes how many bytes each internal general entity
+// expands to without building the text, so exponential "billion laughs"
+// definitions are caught cheaply. External entities expand to nothing since
+// they are never fetched.
+func (d *XMLDTD) entityExpansionSizes() (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	visiting := make(map[string]bool)
+
+	var size func(name string) (int64, error)
+	size = func(name string) (int64, error) {
+		if n, ok := sizes[name]; ok {
+			return n, nil
+		}
+		entity := d.Entities[name]
+		if entity == nil || entity.External() {
+			return 0, nil
+		}
+		if visiting[name] {
+			return 0, fmt.Errorf("entity %s refers to itself", name)
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		total := int64(len(entity.Value))
+		for _, m := range entityReferencePattern.FindAllStringSubmatch(entity.Value, -1) {
+			if strings.HasPrefix(m[1], "#") {
+				continue
+			}
+			n, err := size(m[1])
+			if err != nil {
+				return 0, err
+			}
+			total += n - int64(len(m[0]))
+			if total > 1<<40 {
+				total = 1 << 40 // saturate; anything this large is rejected anyway
+			}
+		}
+		sizes[name] = total
+		return total, nil
+	}
+
+	for name, entity := range d.Entities {
+		if !entity.Parameter {
+			if _, err := size(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sizes, nil
+}
+
+// expandEntities returns the replacement text of every general entity, for
+// xml.Decoder.Entity
+func (d *XMLDTD) expandEntities(limit int) (map[string]string, error) {
+	sizes, err := d.entityExpansionSizes()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedEntityNames(d.Entities) {
+		if sizes[name] > int64(limit) {
+			return nil, fmt.Errorf("entity %s expands to %d bytes, more than the limit of %d", name, sizes[name], limit)
+		}
+	}
+
+	expanded := make(map[string]string)
+	var expand func(name string) string
+	expand = func(name string) string {
+		if text, ok := expanded[name]; ok {
+			return text
+		}
+		entity := d.Entities[name]
+		if entity == nil {
+			return ""
+		}
+		if entity.External() {
+			// Declared but never fetched, so references expand to nothing
+			expanded[name] = ""
+			return ""
+		}
+		text := entityReferencePattern.ReplaceAllStringFunc(entity.Value, func(ref string) string {
+			inner := ref[1 : len(ref)-1]
+			if strings.HasPrefix(inner, "#") {
+				return decodeCharReference(inner, ref)
+			}
+			if predefined, ok := xmlPredefinedEntities[inner]; ok {
+				return predefined
+			}
+			return expand(inner)
+		})
+		expanded[name] = text
+		return text
+	}
+	for name, entity := range d.Entities {
+		if !entity.Parameter {
+			expand(name)
+		}
+	}
+	return expanded, nil
+}
+
+var xmlPredefinedEntities = map[string]string{"lt": "<", "gt": ">", "amp": "&", "apos": "'", "quot": `"`}
+
+func decodeCharReference(inner, original string) string {
+	var n uint64
+	var err error
+	if strings.HasPrefix(inner, "#x") {
+		n, err = strconv.ParseUint(inner[2:], 16, 32)
+	} else {
+		n, err = strconv.ParseUint(inner[1:], 10, 32)
+	}
+	if err != nil {
+		return original
+	}
+	return string(rune(n))
+}
+
+// entityReferenceSize totals the text that references in the document body
+// expand to
+func entityReferenceSize(data []byte, entities map[string]string) int {
+	total := 0
+	for _, m := range entityReferencePattern.FindAllSubmatch(data, -1) {
+		if text, ok := entities[string(m[1])]; ok {
+			total += len(text)
+		}
+	}
+	return total
+}
+
+// validate checks the document against the element and attribute
+// declarations of the internal subset
+func (d *XMLDTD) validate(doc *XMLDocument) []XMLError {
+	var errs []XMLError
+	if doc.Root.Name.Local != d.Name {
+		errs = append(errs, doc.Root.xmlError("DTD_ERROR", "root element <%s> does not match DOCTYPE %s", doc.Root.Name.Local, d.Name))
+	}
+	if len(d.elements) == 0 && len(d.attributes) == 0 {
+		return errs
+	}
+
+	ids := make(map[string]bool)
+	type idref struct {
+		node  *XMLNode
+		value string
+	}
+	var refs []idref
+
+	var walk func(n *XMLNode)
+	walk = func(n *XMLNode) {
+		name := qualifiedXMLName(n.Name)
+		decl := d.elements[name]
+		if decl == nil {
+			errs = append(errs, n.xmlError("DTD_ERROR", "element <%s> is not declared", name))
+		} else {
+			errs = append(errs, d.validateContent(n, decl)...)
+		}
+
+		declared := make(map[string]dtdAttribute)
+		for _, attr := range d.attributes[name] {
+			declared[attr.name] = attr
+		}
+		for _, a := range n.Attrs {
+			if isXMLNamespaceAttr(a) {
+				continue
+			}
+			attrName := qualifiedXMLName(a.Name)
+			attr, ok := declared[attrName]
+			if !ok {
+				errs = append(errs, n.xmlError("DTD_ERROR", "attribute %s of <%s> is not declared", attrName, name))
+				continue
+			}
+			switch {
+			case attr.fixed && a.Value != attr.value:
+				errs = append(errs, n.xmlError("DTD_ERROR", "attribute %s of <%s> must be %q", attrName, name, attr.value))
+			case attr.kind == "enum" && !containsString(attr.values, a.Value):
+				errs = append(errs, n.xmlError("DTD_ERROR", "attribute %s of <%s> must be one of %s", attrName, name, strings.Join(attr.values, ", ")))
+			case attr.kind == "ID":
+				if ids[a.Value] {
+					errs = append(errs, n.xmlError("DTD_ERROR", "duplicate ID %q", a.Value))
+				}
+				ids[a.Value] = true
+			case attr.kind == "IDREF":
+				refs = append(refs, idref{n, a.Value})
+			case attr.kind == "IDREFS":
+				for _, v := range strings.Fields(a.Value) {
+					refs = append(refs, idref{n, v})
+				}
+			case attr.kind == "NMTOKEN" && !xsdNMTokenPattern.MatchString(a.Value):
+				errs = append(errs, n.xmlError("DTD_ERROR", "attribute %s of <%s> is not a valid NMTOKEN", attrName, name))
+			}
+		}
+		for _, attr := range d.attributes[name] {
+			if _, present := n.Attr(attr.name); attr.required && !present {
+				errs = append(errs, n.xmlError("DTD_ERROR", "<%s> is missing required attribute %s", name, attr.name))
+			}
+		}
+
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(doc.Root)
+
+	for _, ref := range refs {
+		if !ids[ref.value] {
+			errs = append(errs, ref.node.xmlError("DTD_ERROR", "IDREF %q does not match any ID", ref.value))
+		}
+	}
+	return errs
+}
+
+func (d *XMLDTD) validateContent(n *XMLNode, decl *dtdElement) []XMLError {
+	name := decl.name
+	hasText := strings.TrimSpace(n.Text) != ""
+
+	switch decl.kind {
+	case "EMPTY":
+		if len(n.Children) > 0 || n.Text != "" {
+			return []XMLError{n.xmlError("DTD_ERROR", "element <%s> must be empty", name)}
+		}
+	case "mixed":
+		for _, child := range n.Children {
+			if !decl.mixed[qualifiedXMLName(child.Name)] {
+				return []XMLError{child.xmlError("DTD_ERROR", "element <%s> is not allowed in <%s>", child.Name.Local, name)}
+			}
+		}
+	case "children":
+		if hasText {
+			return []XMLError{n.xmlError("DTD_ERROR", "element <%s> cannot contain text", name)}
+		}
+		if err := matchContentModel(n, decl.content, "DTD_ERROR"); err != nil {
+			return []XMLError{*err}
+		}
+	}
+	return nil
+}
+
+func qualifiedXMLName(name xml.Name) string {
+	// The decoder replaces known prefixes with namespace URIs; DTDs name
+	// elements as written, so fall back to the local name in that case
+	if name.Space == "" || strings.Contains(name.Space, "/") || strings.Contains(name.Space, ":") {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// contentParticle is a node of a content model shared by DTD element
+// declarations and XSD complex types
+type contentParticle struct {
+	kind  byte   // 'e' element, ',' sequence, '|' choice, '&' all, '*' any element
+	name  string // element name for 'e'
+	items []*contentParticle
+	min   int
+	max   int // negative for unbounded
+}
+
+// contentModelParser parses DTD content models such as (a,(b|c)*,d?)
+type contentModelParser struct {
+	src string
+	pos int
+}
+
+func (p *contentModelParser) parse() (*contentParticle, error) {
+	particle, err := p.particle()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.src) {
+		return nil, fmt.Errorf("unexpected %q in content model", p.src[p.pos:])
+	}
+	return particle, nil
+}
+
+func (p *contentModelParser) particle() (*contentParticle, error) {
+	var particle *contentParticle
+	if p.pos < len(p.src) && p.src[p.pos] == '(' {
+		p.pos++
+		group := &contentParticle{kind: ','}
+		for {
+			item, err := p.particle()
+			if err != nil {
+				return nil, err
+			}
+			group.items = append(group.items, item)
+			if p.pos >= len(p.src) {
+				return nil, fmt.Errorf("unterminated group in content model")
+			}
+			sep := p.src[p.pos]
+			p.pos++
+			if sep == ')' {
+				break
+			}
+			if sep != ',' && sep != '|' {
+				return nil, fmt.Errorf("unexpected %q in content model", sep)
+			}
+			if len(group.items) > 1 && group.kind != sep {
+				return nil, fmt.Errorf("mixed ',' and '|' in one group")
+			}
+			group.kind = sep
+		}
+		particle = group
+	} else {
+		start := p.pos
+		for p.pos < len(p.src) && isXMLNameByte(p.src[p.pos], p.pos == start) {
+			p.pos++
+		}
+		if p.pos == start {
+			return nil, fmt.Errorf("expected an element name in content model")
+		}
+		particle = &contentParticle{kind: 'e', name: p.src[start:p.pos]}
+	}
+
+	particle.min, particle.max = 1, 1
+	if p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '?':
+			particle.min = 0
+			p.pos++
+		case '*':
+			particle.min, particle.max = 0, -1
+			p.pos++
+		case '+':
+			particle.max = -1
+			p.pos++
+		}
+	}
+	return particle, nil
+}
+
+// particleMatcher finds where a content model can stop matching a list of
+// child element names. It tracks sets of positions, so ambiguous models
+// need no backtracking.
+type particleMatcher struct {
+	names    []string
+	furthest int // most children any path has consumed
+}
+
+func (m *particleMatcher) ends(p *contentParticle, start int) []int {
+	results := map[int]bool{}
+	if p.min == 0 {
+		results[start] = true
+	}
+	frontier := []int{start}
+	seen := map[int]bool{start: true}
+
+	for count := 1; (p.max < 0 || count <= p.max) && len(frontier) > 0; count++ {
+		nextSet := map[int]bool{}
+		for _, pos := range frontier {
+			for _, end := range m.once(p, pos) {
+				nextSet[end] = true
+			}
+		}
+		var next []int
+		for pos := range nextSet {
+			if count >= p.min {
+				results[pos] = true
+			}
+			// Unbounded repetition stops once it reaches no new positions
+			if p.max < 0 && count >= p.min && seen[pos] {
+				continue
+			}
+			seen[pos] = true
+			next = append(next, pos)
+		}
+		frontier = next
+	}
+	return sortedPositions(results)
+}
+
+// once matches a single occurrence of the particle
+func (m *particleMatcher) once(p *contentParticle, start int) []int {
+	switch p.kind {
+	case 'e', '*':
+		if start < len(m.names) && (p.kind == '*' || m.names[start] == p.name) {
+			if start+1 > m.furthest {
+				m.furthest = start + 1
+			}
+			return []int{start + 1}
+		}
+		return nil
+	case ',':
+		current := []int{start}
+		for _, item := range p.items {
+			set := map[int]bool{}
+			for _, pos := range current {
+				for _, end := range m.ends(item, pos) {
+					set[end] = true
+				}
+			}
+			if current = sortedPositions(set); len(current) == 0 {
+				return nil
+			}
+		}
+		return current
+	case '|':
+		set := map[int]bool{}
+		for _, item := range p.items {
+			for _, end := range m.ends(item, start) {
+				set[end] = true
+			}
+		}
+		return sortedPositions(set)
+	case '&':
+		// Each item at most once, in any order
+		type state struct {
+			pos  int
+			used uint64
+		}
+		set := map[int]bool{}
+		visited := map[state]bool{}
+		var visit func(s state)
+		visit = func(s state) {
+			if visited[s] {
+				return
+			}
+			visited[s] = true
+			complete := true
+			for i, item := range p.items {
+				if s.used&(1<<i) == 0 && item.min > 0 {
+					complete = false
+				}
+			}
+			if complete {
+				set[s.pos] = true
+			}
+			for i, item := range p.items {
+				if s.used&(1<<i) != 0 {
+					continue
+				}
+				single := *item
+				single.min, single.max = 1, 1
+				for _, end := range m.once(&single, s.pos) {
+					visit(state{end, s.used | 1<<i})
+				}
+			}
+		}
+		visit(state{start, 0})
+		return sortedPositions(set)
+	}
+	return nil
+}
+
+func sortedPositions(set map[int]bool) []int {
+	positions := make([]int, 0, len(set))
+	for pos := range set {
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+	return positions
+}
+
+// matchContentModel checks the element's children against a content model
+func matchContentModel(n *XMLNode, model *contentParticle, kind string) *XMLError {
+	m := &particleMatcher{names: n.childNames()}
+	for _, end := range m.ends(model, 0) {
+		if end == len(m.names) {
+			return nil
+		}
+	}
+	if m.furthest < len(n.Children) {
+		child := n.Children[m.furthest]
+		err := child.xmlError(kind, "element <%s> is not expected here in <%s>", child.Name.Local, n.Name.Local)
+		return &err
+	}
+	err := n.xmlError(kind, "element <%s> is missing required child elements", n.Name.Local)
+	return &err
+}
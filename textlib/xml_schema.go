@@ -0,0 +1,938 @@
+package textlib
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XMLSchema is a compiled subset of XML Schema (XSD 1.0): global and local
+// element declarations, named and anonymous complex and simple types,
+// sequence, choice and all groups with minOccurs/maxOccurs, xs:any,
+// attributes with use="required", simple content, complexContent extension
+// and restriction, and simple type restrictions with the enumeration,
+// pattern, length and numeric range facets over the built-in types.
+// Namespaces are ignored: elements and types match by local name.
+type XMLSchema struct {
+	elements map[string]*xsdElement
+	types    map[string]*xsdType
+
+	// Named definitions are compiled on first use so types may refer to
+	// each other in any order, and recursively
+	typeNodes map[string]*XMLNode
+	compiling map[string]bool
+}
+
+type xsdElement struct {
+	name  string
+	typ   *xsdType
+	fixed *string
+}
+
+type xsdType struct {
+	name string
+
+	// Simple types
+	simple *xsdSimpleType
+
+	// Complex types
+	attrs        []xsdAttribute
+	anyAttribute bool
+	content      *contentParticle       // nil for empty and simple content
+	elements     map[string]*xsdElement // local declarations by name
+	anyElements  bool                   // the content model contains xs:any
+	mixed        bool
+	text         *xsdSimpleType // simple content
+}
+
+type xsdAttribute struct {
+	name     string
+	typ      *xsdSimpleType
+	required bool
+	fixed    *string
+}
+
+type xsdSimpleType struct {
+	base       string // built-in type name
+	enums      []string
+	patterns   []*regexp.Regexp
+	length     int // -1 when unset, as are minLength and maxLength
+	minLength  int
+	maxLength  int
+	minInc     *big.Rat
+	maxInc     *big.Rat
+	minExc     *big.Rat
+	maxExc     *big.Rat
+	list       *xsdSimpleType   // item type of a list
+	union      []*xsdSimpleType // member types of a union
+	whitespace string
+}
+
+// LoadXMLSchema compiles the XSD file at path
+func LoadXMLSchema(path string) (*XMLSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseXMLSchema(data)
+}
+
+// ParseXMLSchema compiles an XSD document
+func ParseXMLSchema(data []byte) (*XMLSchema, error) {
+	doc, err := ParseXMLDocument(data, XMLValidationOptions{Hardened: true})
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if doc.Root.Name.Local != "schema" {
+		return nil, fmt.Errorf("invalid schema: root element is <%s>, not <schema>", doc.Root.Name.Local)
+	}
+
+	s := &XMLSchema{
+		elements:  make(map[string]*xsdElement),
+		types:     make(map[string]*xsdType),
+		typeNodes: make(map[string]*XMLNode),
+		compiling: make(map[string]bool),
+	}
+	var globals []*XMLNode
+	for _, child := range doc.Root.Children {
+		name, _ := child.Attr("name")
+		switch child.Name.Local {
+		case "complexType", "simpleType":
+			if name == "" {
+				return nil, fmt.Errorf("line %d: global %s has no name", child.Line, child.Name.Local)
+			}
+			s.typeNodes[name] = child
+		case "element":
+			if name == "" {
+				return nil, fmt.Errorf("line %d: global element has no name", child.Line)
+			}
+			globals = append(globals, child)
+		}
+	}
+
+	// Declare global elements first so refs resolve, then compile them
+	for _, node := range globals {
+		name, _ := node.Attr("name")
+		s.elements[name] = &xsdElement{name: name}
+	}
+	for _, node := range globals {
+		name, _ := node.Attr("name")
+		if err := s.compileElementType(node, s.elements[name]); err != nil {
+			return nil, err
+		}
+	}
+	for name := range s.typeNodes {
+		if _, err := s.namedType(name); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Validate checks a parsed document against the schema
+func (s *XMLSchema) Validate(doc *XMLDocument) []XMLError {
+	decl := s.elements[doc.Root.Name.Local]
+	if decl == nil {
+		return []XMLError{doc.Root.xmlError("SCHEMA_ERROR", "root element <%s> is not declared in the schema", doc.Root.Name.Local)}
+	}
+	return s.validateElement(doc.Root, decl)
+}
+
+func (s *XMLSchema) validateElement(n *XMLNode, decl *xsdElement) []XMLError {
+	var errs []XMLError
+	typ := decl.typ
+	name := n.Name.Local
+	text := n.Text
+
+	if decl.fixed != nil && strings.TrimSpace(text) != *decl.fixed {
+		errs = append(errs, n.xmlError("SCHEMA_ERROR", "element <%s> must have the value %q", name, *decl.fixed))
+	}
+	if typ == nil {
+		// xs:anyType
+		return errs
+	}
+
+	if typ.simple != nil {
+		if len(n.Children) > 0 {
+			errs = append(errs, n.xmlError("SCHEMA_ERROR", "element <%s> has a simple type and cannot contain elements", name))
+		}
+		for _, a := range n.Attrs {
+			if !isXMLNamespaceAttr(a) {
+				errs = append(errs, n.xmlError("SCHEMA_ERROR", "attribute %s is not allowed on <%s>", a.Name.Local, name))
+			}
+		}
+		if msg := typ.simple.check(text); msg != "" {
+			errs = append(errs, n.xmlError("SCHEMA_ERROR", "element <%s>: %s", name, msg))
+		}
+		return errs
+	}
+
+	// Attributes
+	declared := make(map[string]xsdAttribute)
+	for _, attr := range typ.attrs {
+		declared[attr.name] = attr
+	}
+	for _, a := range n.Attrs {
+		if isXMLNamespaceAttr(a) {
+			continue
+		}
+		attr, ok := declared[a.Name.Local]
+		if !ok {
+			if !typ.anyAttribute {
+				errs = append(errs, n.xmlError("SCHEMA_ERROR", "attribute %s is not allowed on <%s>", a.Name.Local, name))
+			}
+			continue
+		}
+		if attr.fixed != nil && a.Value != *attr.fixed {
+			errs = append(errs, n.xmlError("SCHEMA_ERROR", "attribute %s of <%s> must be %q", attr.name, name, *attr.fixed))
+		} else if msg := attr.typ.check(a.Value); msg != "" {
+			errs = append(errs, n.xmlError("SCHEMA_ERROR", "attribute %s of <%s>: %s", attr.name, name, msg))
+		}
+	}
+	for _, attr := range typ.attrs {
+		if _, present := n.Attr(attr.name); attr.required && !present {
+			errs = append(errs, n.xmlError("SCHEMA_ERROR", "<%s> is missing required attribute %s", name, attr.name))
+		}
+	}
+
+	// Simple content
+	if typ.text != nil {
+		if len(n.Children) > 0 {
+			errs = append(errs, n.xmlError("SCHEMA_ERROR", "element <%s> cannot contain elements", name))
+		} else if msg := typ.text.check(text); msg != "" {
+			errs = append(errs, n.xmlError("SCHEMA_ERROR", "element <%s>: %s", name, msg))
+		}
+		return errs
+	}
+
+	if !typ.mixed && strings.TrimSpace(text) != "" {
+		errs = append(errs, n.xmlError("SCHEMA_ERROR", "element <%s> cannot contain text", name))
+	}
+	if typ.content == nil {
+		if len(n.Children) > 0 {
+			errs = append(errs, n.Children[0].xmlError("SCHEMA_ERROR", "element <%s> is not expected here in <%s>", n.Children[0].Name.Local, name))
+		}
+		return errs
+	}
+	if err := matchContentModel(n, typ.content, "SCHEMA_ERROR"); err != nil {
+		return append(errs, *err)
+	}
+
+	for _, child := range n.Children {
+		childDecl := typ.elements[child.Name.Local]
+		if childDecl == nil {
+			// Matched xs:any; validate if the schema declares it globally
+			if childDecl = s.elements[child.Name.Local]; childDecl == nil {
+				continue
+			}
+		}
+		errs = append(errs, s.validateElement(child, childDecl)...)
+	}
+	return errs
+}
+
+// compileElementType fills in the type of an element declaration
+func (s *XMLSchema) compileElementType(node *XMLNode, decl *xsdElement) error {
+	if fixed, ok := node.Attr("fixed"); ok {
+		decl.fixed = &fixed
+	}
+	if typeName, ok := node.Attr("type"); ok {
+		typ, err := s.resolveType(typeName)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		decl.typ = typ
+		return nil
+	}
+	for _, child := range node.Children {
+		switch child.Name.Local {
+		case "complexType":
+			typ := &xsdType{elements: make(map[string]*xsdElement)}
+			decl.typ = typ
+			return s.compileComplexType(child, typ)
+		case "simpleType":
+			simple, err := s.compileSimpleType(child)
+			if err != nil {
+				return err
+			}
+			decl.typ = &xsdType{simple: simple}
+			return nil
+		}
+	}
+	// No type: anything goes
+	decl.typ = nil
+	return nil
+}
+
+// resolveType finds a named or built-in type; nil means xs:anyType
+func (s *XMLSchema) resolveType(qname string) (*xsdType, error) {
+	name := localXMLName(qname)
+	if _, ok := s.typeNodes[name]; ok {
+		return s.namedType(name)
+	}
+	if name == "anyType" {
+		return nil, nil
+	}
+	if _, ok := xsdBuiltinTypes[name]; ok {
+		return &xsdType{name: name, simple: newXSDSimpleType(name)}, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", qname)
+}
+
+func (s *XMLSchema) namedType(name string) (*xsdType, error) {
+	if typ, ok := s.types[name]; ok {
+		return typ, nil
+	}
+	node := s.typeNodes[name]
+	typ := &xsdType{name: name, elements: make(map[string]*xsdElement)}
+	s.types[name] = typ
+
+	if node.Name.Local == "simpleType" {
+		if s.compiling[name] {
+			return nil, fmt.Errorf("line %d: simple type %s is defined in terms of itself", node.Line, name)
+		}
+		s.compiling[name] = true
+		defer delete(s.compiling, name)
+		simple, err := s.compileSimpleType(node)
+		if err != nil {
+			delete(s.types, name)
+			return nil, err
+		}
+		typ.simple = simple
+		return typ, nil
+	}
+	if err := s.compileComplexType(node, typ); err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+func (s *XMLSchema) compileComplexType(node *XMLNode, typ *xsdType) error {
+	if mixed, _ := node.Attr("mixed"); mixed == "true" {
+		typ.mixed = true
+	}
+	for _, child := range node.Children {
+		switch child.Name.Local {
+		case "sequence", "choice", "all":
+			particle, err := s.compileGroup(child, typ)
+			if err != nil {
+				return err
+			}
+			typ.content = particle
+		case "attribute", "anyAttribute":
+			if err := s.compileAttribute(child, typ); err != nil {
+				return err
+			}
+		case "simpleContent":
+			if err := s.compileSimpleContent(child, typ); err != nil {
+				return err
+			}
+		case "complexContent":
+			if mixed, _ := child.Attr("mixed"); mixed == "true" {
+				typ.mixed = true
+			}
+			if err := s.compileComplexContent(child, typ); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *XMLSchema) compileComplexContent(node *XMLNode, typ *xsdType) error {
+	for _, derivation := range node.Children {
+		if derivation.Name.Local != "extension" && derivation.Name.Local != "restriction" {
+			continue
+		}
+		baseName, _ := derivation.Attr("base")
+		base, err := s.resolveType(baseName)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", derivation.Line, err)
+		}
+		if base != nil && base.simple != nil {
+			return fmt.Errorf("line %d: complexContent cannot derive from simple type %s", derivation.Line, baseName)
+		}
+
+		if derivation.Name.Local == "extension" && base != nil {
+			// Extensions keep the base type's attributes and append to
+			// its content model
+			typ.attrs = append(typ.attrs, base.attrs...)
+			typ.anyAttribute = typ.anyAttribute || base.anyAttribute
+			typ.anyElements = typ.anyElements || base.anyElements
+			for name, el := range base.elements {
+				typ.elements[name] = el
+			}
+			typ.content = base.content
+		}
+		if err := s.compileComplexType(derivation, typ); err != nil {
+			return err
+		}
+		if derivation.Name.Local == "extension" && base != nil && base.content != nil {
+			for _, child := range derivation.Children {
+				switch child.Name.Local {
+				case "sequence", "choice", "all":
+					extension := typ.content
+					typ.content = &contentParticle{kind: ',', items: []*contentParticle{base.content, extension}, min: 1, max: 1}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *XMLSchema) compileSimpleContent(node *XMLNode, typ *xsdType) error {
+	for _, derivation := range node.Children {
+		if derivation.Name.Local != "extension" && derivation.Name.Local != "restriction" {
+			continue
+		}
+		baseName, _ := derivation.Attr("base")
+		base, err := s.resolveType(baseName)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", derivation.Line, err)
+		}
+		switch {
+		case base == nil:
+			typ.text = newXSDSimpleType("anySimpleType")
+		case base.simple != nil:
+			copied := *base.simple
+			typ.text = &copied
+		case base.text != nil:
+			copied := *base.text
+			typ.text = &copied
+			typ.attrs = append(typ.attrs, base.attrs...)
+		default:
+			return fmt.Errorf("line %d: simpleContent base %s has no simple content", derivation.Line, baseName)
+		}
+		if derivation.Name.Local == "restriction" {
+			if err := s.applyFacets(derivation, typ.text); err != nil {
+				return err
+			}
+		}
+		for _, child := range derivation.Children {
+			if child.Name.Local == "attribute" || child.Name.Local == "anyAttribute" {
+				if err := s.compileAttribute(child, typ); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *XMLSchema) compileAttribute(node *XMLNode, typ *xsdType) error {
+	if node.Name.Local == "anyAttribute" {
+		typ.anyAttribute = true
+		return nil
+	}
+	name, _ := node.Attr("name")
+	if name == "" {
+		ref, _ := node.Attr("ref")
+		name = localXMLName(ref)
+	}
+	if name == "" {
+		return fmt.Errorf("line %d: attribute has no name", node.Line)
+	}
+	use, _ := node.Attr("use")
+	if use == "prohibited" {
+		return nil
+	}
+	attr := xsdAttribute{name: name, required: use == "required", typ: newXSDSimpleType("anySimpleType")}
+	if fixed, ok := node.Attr("fixed"); ok {
+		attr.fixed = &fixed
+	}
+	if typeName, ok := node.Attr("type"); ok {
+		attrType, err := s.resolveType(typeName)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if attrType != nil {
+			if attrType.simple == nil {
+				return fmt.Errorf("line %d: attribute %s must have a simple type", node.Line, name)
+			}
+			attr.typ = attrType.simple
+		}
+	}
+	for _, child := range node.Children {
+		if child.Name.Local == "simpleType" {
+			simple, err := s.compileSimpleType(child)
+			if err != nil {
+				return err
+			}
+			attr.typ = simple
+		}
+	}
+	typ.attrs = append(typ.attrs, attr)
+	return nil
+}
+
+// compileGroup compiles sequence, choice and all groups into a particle
+// and records the local element declarations on typ
+func (s *XMLSchema) compileGroup(node *XMLNode, typ *xsdType) (*contentParticle, error) {
+	kinds := map[string]byte{"sequence": ',', "choice": '|', "all": '&'}
+	particle := &contentParticle{kind: kinds[node.Name.Local]}
+	var err error
+	if particle.min, particle.max, err = xsdOccurs(node); err != nil {
+		return nil, err
+	}
+
+	for _, child := range node.Children {
+		switch child.Name.Local {
+		case "sequence", "choice", "all":
+			item, err := s.compileGroup(child, typ)
+			if err != nil {
+				return nil, err
+			}
+			particle.items = append(particle.items, item)
+		case "any":
+			item := &contentParticle{kind: '*'}
+			if item.min, item.max, err = xsdOccurs(child); err != nil {
+				return nil, err
+			}
+			typ.anyElements = true
+			particle.items = append(particle.items, item)
+		case "element":
+			decl, min, max, err := s.compileLocalElement(child)
+			if err != nil {
+				return nil, err
+			}
+			if existing, ok := typ.elements[decl.name]; ok && existing != decl && existing.typ != decl.typ {
+				// Element Declarations Consistent: one name, one type
+				if existing.typ == nil || decl.typ == nil || existing.typ.name == "" || existing.typ.name != decl.typ.name {
+					return nil, fmt.Errorf("line %d: element %s is declared with different types in one content model", child.Line, decl.name)
+				}
+			}
+			typ.elements[decl.name] = decl
+			particle.items = append(particle.items, &contentParticle{kind: 'e', name: decl.name, min: min, max: max})
+		}
+	}
+	if particle.kind == '&' && len(particle.items) > 64 {
+		return nil, fmt.Errorf("line %d: xs:all supports at most 64 elements", node.Line)
+	}
+	return particle, nil
+}
+
+// compileLocalElement returns the declaration for an element particle and
+// its occurrence bounds. References share the global declaration, whose
+// type may not be compiled yet.
+func (s *XMLSchema) compileLocalElement(node *XMLNode) (*xsdElement, int, int, error) {
+	min, max, err := xsdOccurs(node)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if ref, ok := node.Attr("ref"); ok {
+		global := s.elements[localXMLName(ref)]
+		if global == nil {
+			return nil, 0, 0, fmt.Errorf("line %d: element ref %q is not declared", node.Line, ref)
+		}
+		return global, min, max, nil
+	}
+	decl := &xsdElement{}
+	if decl.name, _ = node.Attr("name"); decl.name == "" {
+		return nil, 0, 0, fmt.Errorf("line %d: element has no name", node.Line)
+	}
+	return decl, min, max, s.compileElementType(node, decl)
+}
+
+func xsdOccurs(node *XMLNode) (int, int, error) {
+	min, max := 1, 1
+	if v, ok := node.Attr("minOccurs"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("line %d: invalid minOccurs %q", node.Line, v)
+		}
+		min = n
+	}
+	if v, ok := node.Attr("maxOccurs"); ok {
+		if v == "unbounded" {
+			max = -1
+		} else {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return 0, 0, fmt.Errorf("line %d: invalid maxOccurs %q", node.Line, v)
+			}
+			max = n
+		}
+	}
+	if max >= 0 && min > max {
+		return 0, 0, fmt.Errorf("line %d: minOccurs is greater than maxOccurs", node.Line)
+	}
+	return min, max, nil
+}
+
+func (s *XMLSchema) compileSimpleType(node *XMLNode) (*xsdSimpleType, error) {
+	for _, child := range node.Children {
+		switch child.Name.Local {
+		case "restriction":
+			baseName, _ := child.Attr("base")
+			var simple *xsdSimpleType
+			if baseName == "" {
+				// Anonymous base type
+				for _, inner := range child.Children {
+					if inner.Name.Local == "simpleType" {
+						base, err := s.compileSimpleType(inner)
+						if err != nil {
+							return nil, err
+						}
+						simple = base
+					}
+				}
+				if simple == nil {
+					return nil, fmt.Errorf("line %d: restriction has no base type", child.Line)
+				}
+			} else {
+				base, err := s.resolveType(baseName)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", child.Line, err)
+				}
+				if base != nil && base.simple == nil {
+					return nil, fmt.Errorf("line %d: simple type cannot restrict complex type %s", child.Line, baseName)
+				}
+				if base == nil {
+					simple = newXSDSimpleType("anySimpleType")
+				} else {
+					simple = base.simple
+				}
+			}
+			copied := *simple
+			copied.enums = nil // enumerations are replaced, not merged
+			copied.patterns = append([]*regexp.Regexp(nil), simple.patterns...)
+			if err := s.applyFacets(child, &copied); err != nil {
+				return nil, err
+			}
+			if len(copied.enums) == 0 {
+				copied.enums = simple.enums
+			}
+			return &copied, nil
+		case "list":
+			item := newXSDSimpleType("anySimpleType")
+			if itemType, ok := child.Attr("itemType"); ok {
+				typ, err := s.resolveType(itemType)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", child.Line, err)
+				}
+				if typ != nil && typ.simple != nil {
+					item = typ.simple
+				}
+			}
+			for _, inner := range child.Children {
+				if inner.Name.Local == "simpleType" {
+					compiled, err := s.compileSimpleType(inner)
+					if err != nil {
+						return nil, err
+					}
+					item = compiled
+				}
+			}
+			simple := newXSDSimpleType("anySimpleType")
+			simple.list = item
+			return simple, nil
+		case "union":
+			simple := newXSDSimpleType("anySimpleType")
+			members, _ := child.Attr("memberTypes")
+			for _, member := range strings.Fields(members) {
+				typ, err := s.resolveType(member)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", child.Line, err)
+				}
+				if typ == nil || typ.simple == nil {
+					return nil, fmt.Errorf("line %d: union member %s is not a simple type", child.Line, member)
+				}
+				simple.union = append(simple.union, typ.simple)
+			}
+			for _, inner := range child.Children {
+				if inner.Name.Local == "simpleType" {
+					compiled, err := s.compileSimpleType(inner)
+					if err != nil {
+						return nil, err
+					}
+					simple.union = append(simple.union, compiled)
+				}
+			}
+			return simple, nil
+		}
+	}
+	return nil, fmt.Errorf("line %d: simple type has no restriction, list or union", node.Line)
+}
+
+func (s *XMLSchema) applyFacets(node *XMLNode, simple *xsdSimpleType) error {
+	for _, facet := range node.Children {
+		value, _ := facet.Attr("value")
+		var err error
+		switch facet.Name.Local {
+		case "enumeration":
+			simple.enums = append(simple.enums, value)
+		case "pattern":
+			// XSD patterns match the whole value
+			re, compileErr := regexp.Compile("^(?:" + value + ")$")
+			if compileErr != nil {
+				return fmt.Errorf("line %d: invalid pattern %q: %v", facet.Line, value, compileErr)
+			}
+			simple.patterns = append(simple.patterns, re)
+		case "length":
+			simple.length, err = strconv.Atoi(value)
+		case "minLength":
+			simple.minLength, err = strconv.Atoi(value)
+		case "maxLength":
+			simple.maxLength, err = strconv.Atoi(value)
+		case "minInclusive":
+			simple.minInc, err = xsdFacetNumber(value)
+		case "maxInclusive":
+			simple.maxInc, err = xsdFacetNumber(value)
+		case "minExclusive":
+			simple.minExc, err = xsdFacetNumber(value)
+		case "maxExclusive":
+			simple.maxExc, err = xsdFacetNumber(value)
+		case "whiteSpace":
+			simple.whitespace = value
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: invalid %s facet %q", facet.Line, facet.Name.Local, value)
+		}
+	}
+	return nil
+}
+
+func xsdFacetNumber(value string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return nil, fmt.Errorf("not a number")
+	}
+	return r, nil
+}
+
+func localXMLName(qname string) string {
+	if i := strings.LastIndexByte(qname, ':'); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
+
+func newXSDSimpleType(base string) *xsdSimpleType {
+	return &xsdSimpleType{base: base, length: -1, minLength: -1, maxLength: -1}
+}
+
+// check validates a value, returning a description of the problem
+func (t *xsdSimpleType) check(value string) string {
+	if t.whitespace == "collapse" || (t.base != "string" && t.base != "normalizedString" && t.base != "anySimpleType") {
+		value = strings.Join(strings.Fields(value), " ")
+	}
+
+	if t.list != nil {
+		items := strings.Fields(value)
+		if msg := t.checkLength(len(items), "items"); msg != "" {
+			return msg
+		}
+		for _, item := range items {
+			if msg := t.list.check(item); msg != "" {
+				return msg
+			}
+		}
+		return t.checkEnumPattern(value)
+	}
+	if len(t.union) > 0 {
+		for _, member := range t.union {
+			if member.check(value) == "" {
+				return t.checkEnumPattern(value)
+			}
+		}
+		return fmt.Sprintf("%q does not match any member of the union", value)
+	}
+
+	if check := xsdBuiltinTypes[t.base]; check != nil && !check(value) {
+		return fmt.Sprintf("%q is not a valid %s", value, t.base)
+	}
+	if msg := t.checkLength(xsdValueLength(t.base, value), "characters"); msg != "" {
+		return msg
+	}
+	if msg := t.checkEnumPattern(value); msg != "" {
+		return msg
+	}
+
+	if t.minInc != nil || t.maxInc != nil || t.minExc != nil || t.maxExc != nil {
+		n, ok := xsdNumericValue(value)
+		if !ok {
+			return fmt.Sprintf("%q is not a number", value)
+		}
+		switch {
+		case t.minInc != nil && n.Cmp(t.minInc) < 0:
+			return fmt.Sprintf("%s is less than the minimum %s", value, t.minInc.RatString())
+		case t.maxInc != nil && n.Cmp(t.maxInc) > 0:
+			return fmt.Sprintf("%s is greater than the maximum %s", value, t.maxInc.RatString())
+		case t.minExc != nil && n.Cmp(t.minExc) <= 0:
+			return fmt.Sprintf("%s must be greater than %s", value, t.minExc.RatString())
+		case t.maxExc != nil && n.Cmp(t.maxExc) >= 0:
+			return fmt.Sprintf("%s must be less than %s", value, t.maxExc.RatString())
+		}
+	}
+	return ""
+}
+
+func (t *xsdSimpleType) checkLength(n int, unit string) string {
+	switch {
+	case t.length >= 0 && n != t.length:
+		return fmt.Sprintf("must have exactly %d %s, got %d", t.length, unit, n)
+	case t.minLength >= 0 && n < t.minLength:
+		return fmt.Sprintf("must have at least %d %s, got %d", t.minLength, unit, n)
+	case t.maxLength >= 0 && n > t.maxLength:
+		return fmt.Sprintf("must have at most %d %s, got %d", t.maxLength, unit, n)
+	}
+	return ""
+}
+
+func (t *xsdSimpleType) checkEnumPattern(value string) string {
+	if len(t.enums) > 0 && !containsString(t.enums, value) {
+		return fmt.Sprintf("%q is not one of %s", value, strings.Join(t.enums, ", "))
+	}
+	for _, re := range t.patterns {
+		if !re.MatchString(value) {
+			return fmt.Sprintf("%q does not match pattern %s", value, strings.TrimSuffix(strings.TrimPrefix(re.String(), "^(?:"), ")$"))
+		}
+	}
+	return ""
+}
+
+// xsdValueLength measures length facets: octets for binary types,
+// characters otherwise
+func xsdValueLength(base, value string) int {
+	switch base {
+	case "hexBinary":
+		return len(value) / 2
+	case "base64Binary":
+		decoded, _ := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+		return len(decoded)
+	}
+	return len([]rune(value))
+}
+
+func xsdNumericValue(value string) (*big.Rat, bool) {
+	if r, ok := new(big.Rat).SetString(value); ok {
+		return r, true
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return new(big.Rat).SetFloat64(f), true
+	}
+	return nil, false
+}
+
+var (
+	xsdDecimalPattern  = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+	xsdIntegerPattern  = regexp.MustCompile(`^[+-]?\d+$`)
+	xsdNamePattern     = regexp.MustCompile(`^[\p{L}_:][\p{L}\p{N}_:.\-]*$`)
+	xsdNCNamePattern   = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_.\-]*$`)
+	xsdNMTokenPattern  = regexp.MustCompile(`^[\p{L}\p{N}_:.\-]+$`)
+	xsdLanguagePattern = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+	xsdDurationPattern = regexp.MustCompile(`^-?P(\d+Y)?(\d+M)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?$`)
+	xsdTimezone        = `(Z|[+-]\d{2}:\d{2})?`
+	xsdDatePattern     = regexp.MustCompile(`^-?\d{4,}-\d{2}-\d{2}` + xsdTimezone + `$`)
+	xsdTimePattern     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?` + xsdTimezone + `$`)
+	xsdDateTimePattern = regexp.MustCompile(`^-?\d{4,}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?` + xsdTimezone + `$`)
+)
+
+// xsdIntegerRange builds a check for an integer type with optional bounds
+func xsdIntegerRange(min, max string) func(string) bool {
+	var lo, hi *big.Int
+	if min != "" {
+		lo, _ = new(big.Int).SetString(min, 10)
+	}
+	if max != "" {
+		hi, _ = new(big.Int).SetString(max, 10)
+	}
+	return func(v string) bool {
+		if !xsdIntegerPattern.MatchString(v) {
+			return false
+		}
+		n, _ := new(big.Int).SetString(strings.TrimPrefix(v, "+"), 10)
+		return (lo == nil || n.Cmp(lo) >= 0) && (hi == nil || n.Cmp(hi) <= 0)
+	}
+}
+
+func xsdDateTimeCheck(pattern *regexp.Regexp, layout string) func(string) bool {
+	return func(v string) bool {
+		if !pattern.MatchString(v) {
+			return false
+		}
+		// Check the calendar fields; the timezone was checked by the pattern
+		core := strings.TrimPrefix(v, "-")
+		if len(core) >= len(layout) {
+			_, err := time.Parse(layout, core[:len(layout)])
+			return err == nil
+		}
+		return false
+	}
+}
+
+func xsdFloat(v string) bool {
+	switch v {
+	case "INF", "-INF", "+INF", "NaN":
+		return true
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil && !strings.ContainsAny(v, "xXpP_") && !strings.EqualFold(v, "inf") && !strings.EqualFold(v, "infinity")
+}
+
+// xsdBuiltinTypes maps the supported built-in types to lexical checks; a
+// nil check accepts any string
+var xsdBuiltinTypes = map[string]func(string) bool{
+	"anySimpleType":      nil,
+	"string":             nil,
+	"normalizedString":   nil,
+	"token":              nil,
+	"boolean":            func(v string) bool { return v == "true" || v == "false" || v == "1" || v == "0" },
+	"decimal":            xsdDecimalPattern.MatchString,
+	"integer":            xsdIntegerRange("", ""),
+	"long":               xsdIntegerRange("-9223372036854775808", "9223372036854775807"),
+	"int":                xsdIntegerRange("-2147483648", "2147483647"),
+	"short":              xsdIntegerRange("-32768", "32767"),
+	"byte":               xsdIntegerRange("-128", "127"),
+	"nonNegativeInteger": xsdIntegerRange("0", ""),
+	"positiveInteger":    xsdIntegerRange("1", ""),
+	"nonPositiveInteger": xsdIntegerRange("", "0"),
+	"negativeInteger":    xsdIntegerRange("", "-1"),
+	"unsignedLong":       xsdIntegerRange("0", "18446744073709551615"),
+	"unsignedInt":        xsdIntegerRange("0", "4294967295"),
+	"unsignedShort":      xsdIntegerRange("0", "65535"),
+	"unsignedByte":       xsdIntegerRange("0", "255"),
+	"float":              xsdFloat,
+	"double":             xsdFloat,
+	"date":               xsdDateTimeCheck(xsdDatePattern, "2006-01-02"),
+	"dateTime":           xsdDateTimeCheck(xsdDateTimePattern, "2006-01-02T15:04:05"),
+	"time":               xsdDateTimeCheck(xsdTimePattern, "15:04:05"),
+	"duration": func(v string) bool {
+		return xsdDurationPattern.MatchString(v) && !strings.HasSuffix(v, "P") && !strings.HasSuffix(v, "T")
+	},
+	"anyURI": func(v string) bool {
+		_, err := url.Parse(v)
+		return err == nil
+	},
+	"Name":     xsdNamePattern.MatchString,
+	"NCName":   xsdNCNamePattern.MatchString,
+	"ID":       xsdNCNamePattern.MatchString,
+	"IDREF":    xsdNCNamePattern.MatchString,
+	"NMTOKEN":  xsdNMTokenPattern.MatchString,
+	"language": xsdLanguagePattern.MatchString,
+	"QName": func(v string) bool {
+		parts := strings.Split(v, ":")
+		for _, part := range parts {
+			if !xsdNCNamePattern.MatchString(part) {
+				return false
+			}
+		}
+		return len(parts) <= 2
+	},
+	"hexBinary": func(v string) bool {
+		_, err := hex.DecodeString(v)
+		return err == nil
+	},
+	"base64Binary": func(v string) bool {
+		_, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v), ""))
+		return err == nil
+	},
+}
@@ -0,0 +1,126 @@
+package textlib
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testXPathCatalog = `<catalog xmlns:x="urn:extra">
+  <shelf name="fiction">
+    <book id="b1" year="1999"><title>Dune</title><price>9.5</price></book>
+    <book id="b2" year="2005"><title>Emma</title><price>12</price><x:tag>classic</x:tag></book>
+  </shelf>
+  <shelf name="science">
+    <book id="b3" year="2011"><title>Cosmos</title><price>20</price></book>
+  </shelf>
+  <note>see <b>also</b> the index</note>
+</catalog>`
+
+func TestXPathQueryValues(t *testing.T) {
+	doc, err := ParseXMLDocument([]byte(testXPathCatalog), XMLValidationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"/catalog/shelf/@name", []string{"fiction", "science"}},
+		{"//book/title", []string{"Dune", "Emma", "Cosmos"}},
+		{"//book[2]/title", []string{"Emma"}},
+		{"(//book)[last()]/@id", []string{"b3"}},
+		{"//book[@year > 2000 and price < 15]/@id", []string{"b2"}},
+		{"//book[title = 'Dune' or title = 'Cosmos']/@id", []string{"b1", "b3"}},
+		{"//book[not(@year = 1999)][position() = 1]/@id", []string{"b2", "b3"}},
+		{"//shelf[count(book) = 2]/@name", []string{"fiction"}},
+		{"//book[starts-with(title, 'E')]/../@name", []string{"fiction"}},
+		{"//book[contains(title, 'o')]/@id", []string{"b3"}},
+		{"//tag", []string{"classic"}},
+		{"//x:tag/ancestor::shelf/@name", []string{"fiction"}},
+		{"//book[@id='b2']/preceding-sibling::book/@id", []string{"b1"}},
+		{"//book[@id='b1']/following::title", []string{"Emma", "Cosmos"}},
+		{"//title[. = 'Emma']/following-sibling::*[1]", []string{"12"}},
+		{"/catalog/note/text()", []string{"see ", " the index"}},
+		{"normalize-space(/catalog/note)", []string{"see also the index"}},
+		{"sum(//price)", []string{"41.5"}},
+		{"count(//book) * 2 - 1", []string{"5"}},
+		{"string-length(//title)", []string{"4"}},
+		{"concat(name(//book[1]), '-', local-name(//x:tag))", []string{"book-tag"}},
+		{"//shelf/book[1]/@* | //shelf[2]/@name", []string{"b1", "1999", "science", "b3", "2011"}},
+		{"//price[. > 10] | //title[. = 'Dune']", []string{"Dune", "12", "20"}},
+		{"substring(//book[1]/title, 2, 2)", []string{"un"}},
+		{"round(9.5) + floor(-1.5) + ceiling(1.2)", []string{"10"}},
+		{"10 div 4", []string{"2.5"}},
+		{"7 mod 3", []string{"1"}},
+		{"//missing", []string{}},
+		{"boolean(//missing)", []string{"false"}},
+		{"translate('abc', 'ab', 'B')", []string{"Bc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := doc.QueryValues(tt.expr)
+			if err != nil {
+				t.Fatalf("QueryValues failed: %v", err)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestXPathQuery(t *testing.T) {
+	doc, err := ParseXMLDocument([]byte(testXPathCatalog), XMLValidationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	books, err := doc.Query("//book[price >= 12]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 2 || books[0].Path() != "/catalog/shelf[1]/book[2]" || books[1].Line != 7 {
+		t.Errorf("Unexpected books %+v", books)
+	}
+
+	// Relative queries start at the node
+	shelf := doc.Root.Children[1]
+	titles, _ := shelf.QueryValues("book/title")
+	if !reflect.DeepEqual(titles, []string{"Cosmos"}) {
+		t.Errorf("Expected relative query from the shelf, got %q", titles)
+	}
+	all, _ := shelf.Query("//book")
+	if len(all) != 3 {
+		t.Errorf("Expected absolute query from a node to search the document, got %d", len(all))
+	}
+
+	if _, err := doc.Query("count(//book)"); err == nil {
+		t.Error("Expected Query to reject a non node-set result")
+	}
+
+	x, err := CompileXPath("title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, book := range []string{"Dune", "Emma", "Cosmos"} {
+		nodes, _ := doc.Query("//book")
+		got, _ := x.Values(nodes[i])
+		if len(got) != 1 || got[0] != book {
+			t.Errorf("Expected %s, got %q", book, got)
+		}
+	}
+}
+
+func TestCompileXPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"", "//", "/catalog[", "book[@id='b1'", "unknown()", "foo::bar", "count()", "'open", "$var", "1 +", "//book]",
+	} {
+		if _, err := CompileXPath(expr); err == nil {
+			t.Errorf("Expected %q to fail", expr)
+		}
+	}
+}
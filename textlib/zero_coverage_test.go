@@ -155,32 +155,20 @@ func main() {
 func TestBlocksOverlap(t *testing.T) {
 	tests := []struct {
 		name     string
-		block1   CodeBlock
-		block2   CodeBlock
+		block1   []Position
+		block2   []Position
 		expected bool
 	}{
 		{
 			name: "Overlapping blocks",
-			block1: CodeBlock{
-				StartLine: 1,
-				EndLine:   5,
-			},
-			block2: CodeBlock{
-				StartLine: 3,
-				EndLine:   7,
-			},
+			block1: []Position{{Start: 1, End: 5}},
+			block2: []Position{{Start: 3, End: 7}},
 			expected: true,
 		},
 		{
 			name: "Non-overlapping blocks",
-			block1: CodeBlock{
-				StartLine: 1,
-				EndLine:   5,
-			},
-			block2: CodeBlock{
-				StartLine: 6,
-				EndLine:   10,
-			},
+			block1: []Position{{Start: 1, End: 5}},
+			block2: []Position{{Start: 6, End: 10}},
 			expected: false,
 		},
 	}
@@ -254,7 +242,7 @@ func TestFindDeepNesting(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := FindDeepNesting(tt.code)
+			result := FindDeepNesting(tt.code, 2)
 			if len(result) < tt.expected {
 				t.Errorf("FindDeepNesting(%q) returned %d blocks, expected at least %d", tt.name, len(result), tt.expected)
 			}
@@ -347,8 +335,8 @@ func TestCalculateFileSize(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result := CalculateFileSize(tt.code)
-			if result != tt.expected {
-				t.Errorf("CalculateFileSize(%q) = %d, expected %d", tt.code, result, tt.expected)
+			if result.Characters != tt.expected {
+				t.Errorf("CalculateFileSize(%q).Characters = %d, expected %d", tt.code, result.Characters, tt.expected)
 			}
 		})
 	}
@@ -0,0 +1,415 @@
+package textlib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenCounter counts tokens the way a particular language model does, so
+// that chunk sizes match the model's context window
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// WhitespaceTokenCounter counts whitespace-separated words. It is the
+// default when no TokenCounter is configured.
+type WhitespaceTokenCounter struct{}
+
+// CountTokens returns the number of whitespace-separated words in text
+func (WhitespaceTokenCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+// BPETokenizer is a byte-pair encoding tokenizer loaded from a Hugging Face
+// tokenizer.json or a GPT-2 style vocab/merges pair. It supports byte-level
+// models (GPT-2, RoBERTa, Llama 3) and SentencePiece-style models that mark
+// spaces with "▁" (Llama 2, Mistral).
+type BPETokenizer struct {
+	vocab        map[string]int
+	ranks        map[[2]string]int
+	added        []string // added/special tokens, longest first
+	addedIDs     map[string]int
+	byteLevel    bool
+	byteFallback bool
+	pattern      *regexp.Regexp // pre-tokenization for byte-level models
+	unkID        int
+
+	mu    sync.Mutex
+	cache map[string][]string
+}
+
+const bpeCacheLimit = 50000
+
+// Pre-tokenization used by byte-level models (GPT-2). The trailing-space rule
+// of the original pattern, which needs lookahead, is handled in code.
+var bpePreTokenizePattern = regexp.MustCompile(
+	`^(?:'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+)`)
+
+// Pre-tokenization used by Llama 3, which matches contractions in any case,
+// splits numbers into groups of up to three digits and keeps runs of
+// newlines together
+var llama3PreTokenizePattern = regexp.MustCompile(
+	`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+)`)
+
+// NewBPETokenizer creates a tokenizer from a vocabulary and ranked merges.
+// byteLevel selects GPT-2 style byte-level encoding; otherwise spaces are
+// marked with "▁" as in SentencePiece models.
+func NewBPETokenizer(vocab map[string]int, merges [][2]string, byteLevel bool) *BPETokenizer {
+	t := &BPETokenizer{
+		vocab:     vocab,
+		ranks:     make(map[[2]string]int, len(merges)),
+		addedIDs:  map[string]int{},
+		byteLevel: byteLevel,
+		pattern:   bpePreTokenizePattern,
+		unkID:     -1,
+		cache:     map[string][]string{},
+	}
+	if t.vocab == nil {
+		t.vocab = map[string]int{}
+	}
+	for i, m := range merges {
+		if _, exists := t.ranks[m]; !exists {
+			t.ranks[m] = i
+		}
+	}
+	return t
+}
+
+// AddSpecialToken registers a token that is matched verbatim before BPE is
+// applied, such as "<|endoftext|>"
+func (t *BPETokenizer) AddSpecialToken(token string, id int) {
+	if _, exists := t.addedIDs[token]; !exists {
+		t.added = append(t.added, token)
+		sort.Slice(t.added, func(i, j int) bool { return len(t.added[i]) > len(t.added[j]) })
+	}
+	t.addedIDs[token] = id
+}
+
+type bpeTokenizerFile struct {
+	AddedTokens []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+	} `json:"added_tokens"`
+	Normalizer   json.RawMessage `json:"normalizer"`
+	PreTokenizer json.RawMessage `json:"pre_tokenizer"`
+	Decoder      json.RawMessage `json:"decoder"`
+	Model        struct {
+		Type         string          `json:"type"`
+		Vocab        map[string]int  `json:"vocab"`
+		Merges       json.RawMessage `json:"merges"`
+		UnkToken     *string         `json:"unk_token"`
+		ByteFallback bool            `json:"byte_fallback"`
+	} `json:"model"`
+}
+
+// LoadBPETokenizer loads a Hugging Face tokenizer.json file
+func LoadBPETokenizer(path string) (*BPETokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file bpeTokenizerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid tokenizer file: %v", err)
+	}
+	if file.Model.Type != "" && file.Model.Type != "BPE" {
+		return nil, fmt.Errorf("unsupported tokenizer model: %s", file.Model.Type)
+	}
+
+	merges, err := parseBPEMerges(file.Model.Merges)
+	if err != nil {
+		return nil, err
+	}
+
+	byteLevel := strings.Contains(string(file.PreTokenizer), `"ByteLevel"`) ||
+		strings.Contains(string(file.Decoder), `"ByteLevel"`)
+
+	t := NewBPETokenizer(file.Model.Vocab, merges, byteLevel)
+	t.byteFallback = file.Model.ByteFallback
+	if strings.Contains(string(file.PreTokenizer), `p{N}{1,3}`) {
+		t.pattern = llama3PreTokenizePattern
+	}
+	if file.Model.UnkToken != nil {
+		if id, ok := t.vocab[*file.Model.UnkToken]; ok {
+			t.unkID = id
+		}
+	}
+	for _, tok := range file.AddedTokens {
+		t.AddSpecialToken(tok.Content, tok.ID)
+	}
+	return t, nil
+}
+
+func parseBPEMerges(raw json.RawMessage) ([][2]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	// Older files store "a b" strings, newer ones store ["a", "b"] pairs
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		merges := make([][2]string, 0, len(lines))
+		for _, line := range lines {
+			if parts := strings.SplitN(line, " ", 2); len(parts) == 2 {
+				merges = append(merges, [2]string{parts[0], parts[1]})
+			}
+		}
+		return merges, nil
+	}
+
+	var pairs [][]string
+	if err := json.Unmarshal(raw, &pairs); err != nil {
+		return nil, fmt.Errorf("invalid merges: %v", err)
+	}
+	merges := make([][2]string, 0, len(pairs))
+	for _, pair := range pairs {
+		if len(pair) == 2 {
+			merges = append(merges, [2]string{pair[0], pair[1]})
+		}
+	}
+	return merges, nil
+}
+
+// LoadBPEMerges loads a GPT-2 style merges.txt and, if vocabPath is not
+// empty, the matching vocab.json. Without a vocabulary the tokenizer can
+// still count tokens but Encode returns no ids.
+func LoadBPEMerges(vocabPath, mergesPath string) (*BPETokenizer, error) {
+	var vocab map[string]int
+	if vocabPath != "" {
+		data, err := os.ReadFile(vocabPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &vocab); err != nil {
+			return nil, fmt.Errorf("invalid vocabulary file: %v", err)
+		}
+	}
+
+	file, err := os.Open(mergesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var merges [][2]string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#version") {
+			continue
+		}
+		if parts := strings.SplitN(line, " ", 2); len(parts) == 2 {
+			merges = append(merges, [2]string{parts[0], parts[1]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewBPETokenizer(vocab, merges, true), nil
+}
+
+// CountTokens returns the number of model tokens in text
+func (t *BPETokenizer) CountTokens(text string) int {
+	count := 0
+	t.walk(text, func(symbol string) { count++ })
+	return count
+}
+
+// Tokens returns the token strings for text
+func (t *BPETokenizer) Tokens(text string) []string {
+	var tokens []string
+	t.walk(text, func(symbol string) { tokens = append(tokens, symbol) })
+	return tokens
+}
+
+// Encode returns the vocabulary ids for text. Symbols missing from the
+// vocabulary map to the unknown token, or are dropped if there is none.
+func (t *BPETokenizer) Encode(text string) []int {
+	var ids []int
+	t.walk(text, func(symbol string) {
+		if id, ok := t.addedIDs[symbol]; ok {
+			ids = append(ids, id)
+		} else if id, ok := t.vocab[symbol]; ok {
+			ids = append(ids, id)
+		} else if t.unkID >= 0 {
+			ids = append(ids, t.unkID)
+		}
+	})
+	return ids
+}
+
+// walk emits every token of text in order
+func (t *BPETokenizer) walk(text string, emit func(string)) {
+	for text != "" {
+		// Split off the earliest added token, if any
+		next, match := len(text), ""
+		for _, tok := range t.added {
+			if i := strings.Index(text, tok); i >= 0 && i < next {
+				next, match = i, tok
+			}
+		}
+
+		t.walkOrdinary(text[:next], emit)
+		if match == "" {
+			return
+		}
+		emit(match)
+		text = text[next+len(match):]
+	}
+}
+
+func (t *BPETokenizer) walkOrdinary(text string, emit func(string)) {
+	if text == "" {
+		return
+	}
+
+	for _, piece := range t.preTokenize(text) {
+		for _, symbol := range t.bpe(piece) {
+			if !t.byteLevel && t.byteFallback {
+				if _, ok := t.vocab[symbol]; !ok {
+					for i := 0; i < len(symbol); i++ {
+						emit(fmt.Sprintf("<0x%02X>", symbol[i]))
+					}
+					continue
+				}
+			}
+			emit(symbol)
+		}
+	}
+}
+
+// preTokenize splits text into the pieces that BPE merges never cross
+func (t *BPETokenizer) preTokenize(text string) []string {
+	if !t.byteLevel {
+		// SentencePiece style: mark spaces and split before each word
+		text = "▁" + strings.ReplaceAll(text, " ", "▁")
+		var pieces []string
+		start := 0
+		prevMarker := false
+		for i, r := range text {
+			if r == '▁' && i > 0 && !prevMarker {
+				pieces = append(pieces, text[start:i])
+				start = i
+			}
+			prevMarker = r == '▁'
+		}
+		return append(pieces, text[start:])
+	}
+
+	var pieces []string
+	for pos := 0; pos < len(text); {
+		loc := t.pattern.FindStringIndex(text[pos:])
+		end := pos + 1
+		if loc != nil && loc[1] > 0 {
+			end = pos + loc[1]
+		}
+
+		// Whitespace before a word gives its last character to that word,
+		// except that Llama 3 keeps a run ending in a newline whole
+		piece := text[pos:end]
+		if end < len(text) && strings.TrimSpace(piece) == "" && utf8.RuneCountInString(piece) > 1 &&
+			!(t.pattern == llama3PreTokenizePattern && strings.ContainsAny(piece[len(piece)-1:], "\r\n")) {
+			r, _ := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsSpace(r) {
+				_, size := utf8.DecodeLastRuneInString(piece)
+				end -= size
+			}
+		}
+
+		pieces = append(pieces, byteLevelEncode(text[pos:end]))
+		pos = end
+	}
+	return pieces
+}
+
+// bpe applies ranked merges to one pre-tokenized piece
+func (t *BPETokenizer) bpe(piece string) []string {
+	t.mu.Lock()
+	if cached, ok := t.cache[piece]; ok {
+		t.mu.Unlock()
+		return cached
+	}
+	t.mu.Unlock()
+
+	symbols := make([]string, 0, len(piece))
+	for _, r := range piece {
+		symbols = append(symbols, string(r))
+	}
+
+	for len(symbols) > 1 {
+		best, bestRank := -1, -1
+		for i := 0; i < len(symbols)-1; i++ {
+			if rank, ok := t.ranks[[2]string{symbols[i], symbols[i+1]}]; ok && (bestRank < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		// Merge every occurrence of the best pair
+		pair := [2]string{symbols[best], symbols[best+1]}
+		merged := symbols[:0:0]
+		for i := 0; i < len(symbols); i++ {
+			if i < len(symbols)-1 && symbols[i] == pair[0] && symbols[i+1] == pair[1] {
+				merged = append(merged, pair[0]+pair[1])
+				i++
+			} else {
+				merged = append(merged, symbols[i])
+			}
+		}
+		symbols = merged
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= bpeCacheLimit {
+		t.cache = map[string][]string{}
+	}
+	t.cache[piece] = symbols
+	t.mu.Unlock()
+
+	return symbols
+}
+
+// byteToUnicode is GPT-2's reversible mapping from bytes to printable runes
+var byteToUnicode = func() [256]rune {
+	var table [256]rune
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			table[b] = rune(b)
+		} else {
+			table[b] = rune(256 + n)
+			n++
+		}
+	}
+	return table
+}()
+
+func byteLevelEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		b.WriteRune(byteToUnicode[s[i]])
+	}
+	return b.String()
+}
+
+// countTokens counts text with the strategy's TokenCounter, falling back to
+// whitespace-separated words
+func (s ChunkingStrategy) countTokens(text string) int {
+	if s.TokenCounter == nil {
+		return len(strings.Fields(text))
+	}
+	return s.TokenCounter.CountTokens(text)
+}
@@ -0,0 +1,253 @@
+package textlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func be32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func riffChunk(id string, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(body)))
+	out := append(append([]byte(id), size...), body...)
+	if len(body)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// ebmlElement encodes an element with its minimal ID and an 8-byte size
+func ebmlElement(id uint64, parts ...[]byte) []byte {
+	var out []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	body := bytes.Join(parts, nil)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01
+	return append(append(out, size...), body...)
+}
+
+func ebmlUintBytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func ebmlFloatBytes(v float64) []byte {
+	return ebmlUintBytes(math.Float64bits(v))
+}
+
+func testMP4Video() []byte {
+	fullBox := func(typ string, size int, fields map[int][]byte) []byte {
+		body := make([]byte, size)
+		for off, v := range fields {
+			copy(body[off:], v)
+		}
+		return mp4Box(typ, body)
+	}
+	trak := func(id uint32, handler string, scale, duration uint32, lang uint16, entry []byte, tables ...[]byte) []byte {
+		return mp4Box("trak",
+			fullBox("tkhd", 84, map[int][]byte{12: be32Bytes(id)}),
+			mp4Box("mdia",
+				fullBox("mdhd", 24, map[int][]byte{12: be32Bytes(scale), 16: be32Bytes(duration), 20: {byte(lang >> 8), byte(lang)}}),
+				mp4Box("hdlr", make([]byte, 8), []byte(handler), make([]byte, 13)),
+				mp4Box("minf", mp4Box("stbl", append([][]byte{mp4Box("stsd", be32Bytes(0), be32Bytes(1), entry)}, tables...)...)),
+			))
+	}
+
+	avc := make([]byte, 78)
+	binary.BigEndian.PutUint16(avc[24:], 1920)
+	binary.BigEndian.PutUint16(avc[26:], 1080)
+	mp4a := make([]byte, 28)
+	binary.BigEndian.PutUint16(mp4a[16:], 2)
+	binary.BigEndian.PutUint32(mp4a[24:], 48000<<16)
+
+	video := trak(1, "vide", 30000, 300000, 0x55C4, mp4Box("avc1", avc), // "und"
+		mp4Box("stts", be32Bytes(0), be32Bytes(1), be32Bytes(300), be32Bytes(1000)),
+		mp4Box("stsz", be32Bytes(0), be32Bytes(5000), be32Bytes(300)))
+	audio := trak(2, "soun", 48000, 480000, 0x15C7, mp4Box("mp4a", mp4a), // "eng"
+		mp4Box("stsz", be32Bytes(0), be32Bytes(0), be32Bytes(3), be32Bytes(1000), be32Bytes(2000), be32Bytes(3000)))
+	subs := trak(3, "sbtl", 1000, 10000, 0x1A41, mp4Box("tx3g", make([]byte, 8))) // "fra"
+
+	var b bytes.Buffer
+	b.Write(mp4Box("ftyp", []byte("isom\x00\x00\x02\x00")))
+	b.Write(mp4Box("moov", fullBox("mvhd", 100, map[int][]byte{12: be32Bytes(1000), 16: be32Bytes(10000)}), video, audio, subs))
+	b.Write(mp4Box("mdat", make([]byte, 2000)))
+	return b.Bytes()
+}
+
+func testWebM() []byte {
+	header := ebmlElement(ebmlHeaderID, ebmlElement(ebmlDocTypeID, []byte("webm")))
+	info := ebmlElement(mkvInfoID,
+		ebmlElement(mkvTimestampScale, ebmlUintBytes(1000000)),
+		ebmlElement(mkvDurationID, ebmlFloatBytes(5000)))
+	tracks := ebmlElement(mkvTracksID,
+		ebmlElement(mkvTrackEntryID,
+			ebmlElement(mkvTrackNumberID, ebmlUintBytes(1)),
+			ebmlElement(mkvTrackUIDID, ebmlUintBytes(11)),
+			ebmlElement(mkvTrackTypeID, ebmlUintBytes(1)),
+			ebmlElement(mkvCodecID, []byte("V_VP9")),
+			ebmlElement(mkvDefaultDuration, ebmlUintBytes(41708333)),
+			ebmlElement(mkvVideoID, ebmlElement(mkvPixelWidthID, ebmlUintBytes(1280)), ebmlElement(mkvPixelHeightID, ebmlUintBytes(720)))),
+		ebmlElement(mkvTrackEntryID,
+			ebmlElement(mkvTrackNumberID, ebmlUintBytes(2)),
+			ebmlElement(mkvTrackUIDID, ebmlUintBytes(22)),
+			ebmlElement(mkvTrackTypeID, ebmlUintBytes(2)),
+			ebmlElement(mkvCodecID, []byte("A_OPUS")),
+			ebmlElement(mkvLanguageID, []byte("ger")),
+			ebmlElement(mkvLanguageBCP47ID, []byte("de")),
+			ebmlElement(mkvDefaultDuration, ebmlUintBytes(20000000)),
+			ebmlElement(mkvAudioID, ebmlElement(mkvSamplingFreqID, ebmlFloatBytes(48000)), ebmlElement(mkvChannelsID, ebmlUintBytes(2)))),
+		ebmlElement(mkvTrackEntryID,
+			ebmlElement(mkvTrackNumberID, ebmlUintBytes(3)),
+			ebmlElement(mkvTrackTypeID, ebmlUintBytes(0x11)),
+			ebmlElement(mkvCodecID, []byte("S_TEXT/WEBVTT")),
+			ebmlElement(mkvLanguageID, []byte("und")),
+			ebmlElement(mkvNameID, []byte("Captions"))))
+	cluster := ebmlElement(0x1F43B675, make([]byte, 500))
+	tags := ebmlElement(mkvTagsID, ebmlElement(mkvTagID,
+		ebmlElement(mkvTargetsID, ebmlElement(mkvTagTrackUIDID, ebmlUintBytes(22))),
+		ebmlElement(mkvSimpleTagID, ebmlElement(mkvTagNameID, []byte("BPS")), ebmlElement(mkvTagStringID, []byte("96000"))),
+		ebmlElement(mkvSimpleTagID, ebmlElement(mkvTagNameID, []byte("DURATION")), ebmlElement(mkvTagStringID, []byte("00:00:04.500000000")))))
+
+	// A live-stream segment of unknown size runs to the end of the file
+	segment := []byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	return bytes.Join([][]byte{header, segment, info, tracks, cluster, tags}, nil)
+}
+
+func testAVI() []byte {
+	le32 := func(vs ...uint32) []byte {
+		b := make([]byte, 4*len(vs))
+		for i, v := range vs {
+			binary.LittleEndian.PutUint32(b[4*i:], v)
+		}
+		return b
+	}
+	avih := make([]byte, 56)
+	copy(avih, le32(40000))
+	copy(avih[16:], le32(250))
+	copy(avih[32:], le32(640, 480))
+
+	strh := func(typ, handler string, scale, rate, length uint32) []byte {
+		b := make([]byte, 56)
+		copy(b, typ)
+		copy(b[4:], handler)
+		copy(b[20:], le32(scale, rate))
+		copy(b[32:], le32(length))
+		return b
+	}
+	bih := make([]byte, 40)
+	copy(bih[4:], le32(640, uint32(0xFFFFFE20))) // height -480, top-down
+	copy(bih[16:], "XVID")
+	wfx := []byte{0x55, 0x00, 0x02, 0x00}
+	wfx = append(wfx, le32(44100, 16000)...)
+	wfx = append(wfx, 0, 0, 0, 0)
+
+	hdrl := riffChunk("LIST", []byte("hdrl"),
+		riffChunk("avih", avih),
+		riffChunk("LIST", []byte("strl"), riffChunk("strh", strh("vids", "xvid", 1, 25, 250)), riffChunk("strf", bih), riffChunk("strn", []byte("Video\x00"))),
+		riffChunk("LIST", []byte("strl"), riffChunk("strh", strh("auds", "\x00\x00\x00\x00", 1, 16000, 160000)), riffChunk("strf", wfx)),
+	)
+	movi := riffChunk("LIST", []byte("movi"), riffChunk("00dc", make([]byte, 100)), riffChunk("01wb", make([]byte, 50)))
+	idx1 := riffChunk("idx1",
+		[]byte("00dc"), le32(0x10, 4, 100000),
+		[]byte("01wb"), le32(0, 112, 160000))
+	return riffChunk("RIFF", []byte("AVI "), hdrl, movi, idx1)
+}
+
+func TestValidateVideoCodec(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		data     []byte
+		summary  CodecInfo
+		duration time.Duration
+		tracks   []TrackInfo
+	}{
+		{"MP4", "clip.mov", testMP4Video(), CodecInfo{
+			VideoCodec: "H.264", AudioCodec: "AAC", Container: "MP4", Resolution: "1920x1080", Framerate: 30,
+			HasSubtitles: true, IsValid: true,
+		}, 10 * time.Second, []TrackInfo{
+			{ID: 1, Type: "video", Codec: "H.264", FourCC: "avc1", Width: 1920, Height: 1080, Framerate: 30, Duration: 10 * time.Second, Bitrate: 1200},
+			{ID: 2, Type: "audio", Codec: "AAC", FourCC: "mp4a", SampleRate: 48000, Channels: 2, Duration: 10 * time.Second, Bitrate: 5, Language: "eng"},
+			{ID: 3, Type: "subtitle", Codec: "Timed Text", FourCC: "tx3g", Duration: 10 * time.Second, Language: "fra"},
+		}},
+		{"WebM", "clip.mp4", testWebM(), CodecInfo{
+			VideoCodec: "VP9", AudioCodec: "Opus", Container: "WebM", Resolution: "1280x720", Framerate: 23.976,
+			HasSubtitles: true, IsValid: true,
+		}, 5 * time.Second, []TrackInfo{
+			{ID: 1, Type: "video", Codec: "VP9", FourCC: "V_VP9", Width: 1280, Height: 720, Framerate: 23.976, Duration: 5 * time.Second, Language: "eng"},
+			{ID: 2, Type: "audio", Codec: "Opus", FourCC: "A_OPUS", SampleRate: 48000, Channels: 2, Duration: 4500 * time.Millisecond, Bitrate: 96, Language: "de"},
+			{ID: 3, Type: "subtitle", Codec: "WebVTT", FourCC: "S_TEXT/WEBVTT", Duration: 5 * time.Second, Name: "Captions"},
+		}},
+		{"AVI", "clip.avi", testAVI(), CodecInfo{
+			VideoCodec: "MPEG-4 Part 2", AudioCodec: "MP3", Container: "AVI", Resolution: "640x480", Framerate: 25,
+			IsValid: true,
+		}, 10 * time.Second, []TrackInfo{
+			{ID: 0, Type: "video", Codec: "MPEG-4 Part 2", FourCC: "XVID", Width: 640, Height: 480, Framerate: 25, Duration: 10 * time.Second, Bitrate: 80, Name: "Video"},
+			{ID: 1, Type: "audio", Codec: "MP3", FourCC: "0x0055", SampleRate: 44100, Channels: 2, Duration: 10 * time.Second, Bitrate: 128},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateVideoCodec(writeTestAudio(t, tt.file, tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Tracks, tt.tracks) {
+				t.Errorf("tracks\ngot  %+v\nwant %+v", got.Tracks, tt.tracks)
+			}
+			want := tt.summary
+			want.Duration = tt.duration
+			want.Bitrate = audioBitrate(int64(len(tt.data)), tt.duration)
+			got.Tracks = nil
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateVideoCodecErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a video", []byte("plain text that is long enough")},
+		{"MP4 without moov", mp4Box("ftyp", []byte("isom\x00\x00\x02\x00"))},
+		{"EBML without segment", ebmlElement(ebmlHeaderID, ebmlElement(ebmlDocTypeID, []byte("matroska")))},
+		{"AVI without header", riffChunk("RIFF", []byte("AVI "), riffChunk("LIST", []byte("movi")))},
+	}
+	for _, tt := range tests {
+		if _, err := ValidateVideoCodec(writeTestAudio(t, "v.mp4", tt.data)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+	if _, err := ValidateVideoCodec(filepath.Join(t.TempDir(), "missing.mp4")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	// A video track with an unknown codec parses but is not valid
+	data := bytes.Replace(testMP4Video(), []byte("avc1"), []byte("zzzz"), 1)
+	got, err := ValidateVideoCodec(writeTestAudio(t, "v.mp4", data))
+	if err != nil || got.IsValid || got.VideoCodec != "zzzz" {
+		t.Errorf("Expected an invalid result, got %+v, %v", got, err)
+	}
+}
